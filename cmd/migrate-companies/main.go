@@ -0,0 +1,53 @@
+// Command migrate-companies stores the owning company on branches, regions,
+// shelves and suppliers written before they were company scoped.
+//
+//	go run ./cmd/migrate-companies -company <id>
+//
+// Each record goes to the company whose purchases, receives, deliveries,
+// memberships or audits point at it. Records nothing points at go to the
+// -company given, if any. Run it once before deploying a server that scopes
+// these records; until then no company sees them. Records already owned by a
+// company are left alone, so it can be run again.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/joho/godotenv"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/services"
+)
+
+func main() {
+	fallback := flag.String("company", "", "company that gets the records no company points at")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: Error loading .env file: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	firebaseService, err := services.NewFirebaseService(ctx, "firebase-credentials.json")
+	if err != nil {
+		log.Fatal("Failed to initialize Firebase service:", err)
+	}
+	defer firebaseService.Close()
+
+	assigned, unassigned, err := models.AssignCompanies(ctx, firebaseService.GetFirestore(), *fallback)
+	if err != nil {
+		log.Fatalf("Failed to assign companies after updating %d records: %v", assigned, err)
+	}
+	log.Printf("%d branches, regions, shelves and suppliers assigned to a company", assigned)
+	for _, path := range unassigned {
+		log.Printf("Not assigned: %s has no single owning company", path)
+	}
+	if len(unassigned) > 0 {
+		os.Exit(1)
+	}
+}
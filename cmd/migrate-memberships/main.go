@@ -0,0 +1,41 @@
+// Command migrate-memberships creates memberships for users that joined a
+// company before memberships existed, through the company and role on their
+// profile or the company's users list.
+//
+//	go run ./cmd/migrate-memberships
+//
+// Run it once before deploying a server that only accepts memberships.
+// Existing memberships are left alone, so it can be run again.
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/joho/godotenv"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/services"
+)
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: Error loading .env file: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	firebaseService, err := services.NewFirebaseService(ctx, "firebase-credentials.json")
+	if err != nil {
+		log.Fatal("Failed to initialize Firebase service:", err)
+	}
+	defer firebaseService.Close()
+
+	created, err := models.NewMembershipFirebase(firebaseService.GetFirestore()).MigrateLegacy(ctx)
+	if err != nil {
+		log.Fatalf("Failed to migrate memberships after creating %d: %v", created, err)
+	}
+	log.Printf("%d memberships created", created)
+}
@@ -0,0 +1,41 @@
+// Command migrate-shelves backfills the shelf IDs of receive and delivery
+// details written before ShelveIDs was kept, so shelf audits find the stock
+// those records put on shelves and took off them.
+//
+//	go run ./cmd/migrate-shelves
+//
+// Run it once before deploying a server that audits shelves. Records already
+// listing their shelves are left alone, so it can be run again.
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/joho/godotenv"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/services"
+)
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: Error loading .env file: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	firebaseService, err := services.NewFirebaseService(ctx, "firebase-credentials.json")
+	if err != nil {
+		log.Fatal("Failed to initialize Firebase service:", err)
+	}
+	defer firebaseService.Close()
+
+	changed, err := models.NewShelfAuditFirebase(firebaseService.GetFirestore()).IndexShelves(ctx)
+	if err != nil {
+		log.Fatalf("Failed to index shelves after updating %d records: %v", changed, err)
+	}
+	log.Printf("%d receives and deliveries updated", changed)
+}
@@ -0,0 +1,93 @@
+// Command migrate-storage copies stored files from one storage backend to
+// another, reading the backend settings from the environment.
+//
+//	go run ./cmd/migrate-storage -from local -to s3
+//
+// Objects already present in the destination are skipped, so the command can
+// be run again after an interruption. Switch STORAGE_BACKEND once it reports
+// no failures.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/joho/godotenv"
+	"github.com/nirshpaa/godam-backend/config"
+	"github.com/nirshpaa/godam-backend/libraries/objectstore"
+	"github.com/nirshpaa/godam-backend/services"
+)
+
+func main() {
+	from := flag.String("from", objectstore.BackendLocal, "backend to copy from: local, s3 or gcs")
+	to := flag.String("to", "", "backend to copy to: local, s3 or gcs")
+	area := flag.String("area", "", "storage area to migrate, products or dataset (default all)")
+	prefix := flag.String("prefix", "", "only migrate keys starting with prefix")
+	dryRun := flag.Bool("dry-run", false, "report what would be copied without writing")
+	deleteSource := flag.Bool("delete-source", false, "delete each object from the source once it is copied")
+	verbose := flag.Bool("v", false, "log every object")
+	flag.Parse()
+
+	if *to == "" || *to == *from {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: Error loading .env file: %v", err)
+	}
+
+	areas := services.StorageAreas
+	if *area != "" {
+		areas = []string{*area}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	failed := 0
+	for _, area := range areas {
+		src, err := openStore(ctx, *from, area)
+		if err != nil {
+			log.Fatalf("Failed to open %s storage on %s: %v", area, *from, err)
+		}
+		dst, err := openStore(ctx, *to, area)
+		if err != nil {
+			log.Fatalf("Failed to open %s storage on %s: %v", area, *to, err)
+		}
+
+		result, err := objectstore.Migrate(ctx, dst, src, objectstore.MigrateOptions{
+			Prefix:       *prefix,
+			DryRun:       *dryRun,
+			DeleteSource: *deleteSource,
+			Progress: func(key string, err error) {
+				if err != nil {
+					log.Printf("%s/%s: %v", area, key, err)
+				} else if *verbose {
+					log.Printf("%s/%s", area, key)
+				}
+			},
+		})
+		if err != nil {
+			log.Fatalf("Failed to migrate %s: %v", area, err)
+		}
+		log.Printf("%s: %d copied, %d already present, %d failed", area, result.Copied, result.Skipped, result.Failed)
+		failed += result.Failed
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d objects failed to migrate\n", failed)
+		os.Exit(1)
+	}
+}
+
+// openStore opens a storage area on backend, with the other settings taken
+// from the environment
+func openStore(ctx context.Context, backend, area string) (objectstore.Store, error) {
+	cfg := *config.GetConfig()
+	cfg.StorageBackend = backend
+	return services.NewObjectStore(ctx, &cfg, area)
+}
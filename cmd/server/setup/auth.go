@@ -0,0 +1,59 @@
+package setup
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/nirshpaa/godam-backend/controllers"
+	"github.com/nirshpaa/godam-backend/libraries/token"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/services"
+)
+
+// keyRefreshInterval is how often signing keys are reloaded from Firestore
+const keyRefreshInterval = 5 * time.Minute
+
+// setupAuth chooses how bearer tokens are issued and verified. AUTH_MODE=local
+// issues RS256 tokens signed with rotating keys kept in Firestore; otherwise
+// Firebase ID tokens are used.
+func setupAuth(firebaseService *services.FirebaseService) (token.TokenVerifier, *controllers.AuthController) {
+	if os.Getenv("AUTH_MODE") != "local" {
+		return token.NewFirebaseVerifier(firebaseService.GetAuthClient()), controllers.NewFirebaseAuthController(firebaseService.GetAuthClient())
+	}
+
+	client := firebaseService.GetFirestore()
+	accessTTL := envDuration("JWT_ACCESS_TTL", 15*time.Minute)
+	refreshTTL := envDuration("JWT_REFRESH_TTL", 30*24*time.Hour)
+
+	// Keys stay published for an access token lifetime after they stop
+	// signing, plus a refresh interval in which other instances may not have
+	// picked up the new key and still sign with the old one
+	keys := token.NewKeySet(models.NewSigningKeyFirebase(client), envDuration("JWT_KEY_ROTATION", 24*time.Hour), accessTTL+keyRefreshInterval)
+	if err := keys.Refresh(context.Background()); err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+	keys.Start(context.Background(), keyRefreshInterval)
+
+	issuer := os.Getenv("JWT_ISSUER")
+	if issuer == "" {
+		issuer = "godam-backend"
+	}
+	audience := os.Getenv("JWT_AUDIENCE")
+	if audience == "" {
+		audience = "godam-api"
+	}
+
+	revocations := models.NewTokenRevocations(client)
+	local := token.NewLocalIssuer(keys, issuer, audience, accessTTL, revocations)
+	return local, controllers.NewLocalAuthController(local, models.NewLocalAccountFirebase(client), models.NewRefreshTokenFirebase(client, refreshTTL), revocations)
+}
+
+// envDuration reads a duration such as "15m" from the environment
+func envDuration(name string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(name)); err == nil && d > 0 {
+		return d
+	}
+	return fallback
+}
@@ -0,0 +1,55 @@
+package setup
+
+import (
+	"context"
+	"time"
+
+	"github.com/nirshpaa/godam-backend/config"
+	"github.com/nirshpaa/godam-backend/libraries/ratelimit"
+	"github.com/nirshpaa/godam-backend/middleware"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/services"
+)
+
+// Rate limit policies. Scanning and uploads accept large images and call out
+// to the recognition service, so they are limited more tightly than the rest.
+// Batch scans are charged one token per image, up to a full batch at once.
+// Failed authentication is limited per client IP on its own, so guessing
+// credentials is slowed down well below the IP's request limit.
+var (
+	ipRateLimit      = ratelimit.PerSecond(20, 100)
+	authFailureLimit = ratelimit.PerMinute(10, 20)
+	authRateLimit    = middleware.RateLimitPolicy{
+		Caller: ratelimit.PerMinute(10, 10),
+	}
+	apiRateLimit = middleware.RateLimitPolicy{
+		Caller:  ratelimit.PerSecond(10, 50),
+		Company: ratelimit.PerSecond(50, 200),
+	}
+	scanRateLimit = middleware.RateLimitPolicy{
+		Caller:  ratelimit.PerMinute(20, 5),
+		Company: ratelimit.PerMinute(120, 20),
+	}
+	batchScanRateLimit = middleware.RateLimitPolicy{
+		Caller:  ratelimit.PerMinute(60, services.MaxBatchImages),
+		Company: ratelimit.PerMinute(300, 2*services.MaxBatchImages),
+	}
+	uploadRateLimit = middleware.RateLimitPolicy{
+		Caller:  ratelimit.PerMinute(30, 10),
+		Company: ratelimit.PerMinute(300, 50),
+	}
+)
+
+// setupRateLimiter chooses where token buckets are kept. With
+// RATE_LIMIT_STORE=firestore they are shared by every instance, falling back
+// to memory when Firestore fails; otherwise each instance limits on its own
+// and the limits of a deployment grow with its instances.
+func setupRateLimiter(firebaseService *services.FirebaseService) *middleware.RateLimiter {
+	store := ratelimit.NewMemoryStore()
+	store.Start(context.Background(), 5*time.Minute)
+
+	if config.GetConfig().RateLimitStore == "firestore" {
+		return middleware.NewRateLimiter(models.NewRateLimitFirebase(firebaseService.GetFirestore(), store))
+	}
+	return middleware.NewRateLimiter(store)
+}
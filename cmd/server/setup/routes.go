@@ -1,12 +1,17 @@
 package setup
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/config"
 	"github.com/nirshpaa/godam-backend/handlers"
+	"github.com/nirshpaa/godam-backend/libraries/objectstore"
 	"github.com/nirshpaa/godam-backend/middleware"
 	"github.com/nirshpaa/godam-backend/models"
 	"github.com/nirshpaa/godam-backend/services"
@@ -15,18 +20,37 @@ import (
 // SetupRoutes sets up all the routes for the application
 func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService) {
 	// Initialize services
-	basePath := os.Getenv("UPLOAD_PATH")
-	if basePath == "" {
-		basePath = "./assets/products"
+	cfg := config.GetConfig()
+	productStore, err := services.NewObjectStore(context.Background(), cfg, services.StorageProducts)
+	if err != nil {
+		log.Fatalf("Failed to open product image storage: %v", err)
 	}
-	fileStorage := services.NewFileStorageService(basePath)
+	datasetStore, err := services.NewObjectStore(context.Background(), cfg, services.StorageDataset)
+	if err != nil {
+		log.Fatalf("Failed to open dataset storage: %v", err)
+	}
+	fileStorage := services.NewFileStorageService(productStore)
+	fileHandler := handlers.NewFileHandler(productStore, cfg.StorageBackend != objectstore.BackendLocal)
 
-	barcodeEndpoint := os.Getenv("BARCODE_API_ENDPOINT")
-	cnnEndpoint := os.Getenv("CNN_API_ENDPOINT")
-	imageRecognition := services.NewImageRecognitionService(firebaseService.GetFirestore(), barcodeEndpoint, cnnEndpoint)
+	imageRecognition := services.NewImageRecognitionService(firebaseService.GetFirestore(), cfg.BarcodeAPI, cfg.CNNEndpoint, productStore)
 
 	imageTrainingService := services.NewImageTrainingService()
 
+	datasetService := services.NewDatasetService(firebaseService.GetFirestore(), datasetStore)
+
+	// Training jobs call back on API_URL, the address the training API reaches this server on
+	trainingService := services.NewTrainingService(firebaseService.GetFirestore(), datasetService, os.Getenv("TRAINING_API_ENDPOINT"), cfg.APIURL)
+	trainingService.Start(context.Background(), time.Minute)
+	trainingHandler := handlers.NewTrainingHandler(
+		models.NewTrainingFirebase(firebaseService.GetFirestore()),
+		models.NewRecognitionSettingsFirebase(firebaseService.GetFirestore()),
+		trainingService,
+		datasetService,
+	)
+
+	// Record document versions for ETag / If-Match handling
+	router.Use(middleware.Versioning())
+
 	// Health check route (no auth required)
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -39,11 +63,46 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 	if companyFirebase == nil {
 		log.Fatalf("Failed to initialize company model")
 	}
-	companyHandler := handlers.NewCompanyHandler(companyFirebase)
+	memberships := models.NewMembershipFirebase(firebaseService.GetFirestore())
+	companyHandler := handlers.NewCompanyHandler(companyFirebase, memberships)
 	router.GET("/companies", companyHandler.List) // Public access to company list
 
+	// Product images are public, served from whichever backend stores them
+	router.GET("/uploads/*key", fileHandler.Serve)
+	router.HEAD("/uploads/*key", fileHandler.Serve)
+
+	// The training service authenticates with the token of the job it runs
+	router.POST("/training/callback/:id", trainingHandler.Callback)
+	router.GET("/training/callback/:id/dataset", trainingHandler.Dataset)
+
+	// Locally issued tokens are obtained and refreshed without a token;
+	// Firebase clients sign in through the Firebase SDK
+	verifier, authController := setupAuth(firebaseService)
+
+	// Client IPs are limited before authentication, so floods of bad tokens
+	// or logins are turned away before they are verified
+	rateLimiter := setupRateLimiter(firebaseService)
+	router.Use(rateLimiter.LimitIP("ip", ipRateLimit))
+	if authController.Local() {
+		authRoutes := router.Group("/auth", rateLimiter.Limit("auth", authRateLimit))
+		{
+			authRoutes.POST("/login", authController.Login)
+			authRoutes.POST("/register", authController.Register)
+			authRoutes.POST("/refresh", authController.RefreshToken)
+		}
+		router.GET("/.well-known/jwks.json", authController.JWKS)
+	}
+
+	// Security events raise alerts that are delivered by email
+	mailer := services.NewMailSenderFromEnv()
+	securityLog := setupSecurityLog(firebaseService, mailer)
+
 	// Apply auth middleware to all routes except health check
-	router.Use(middleware.AuthMiddleware(firebaseService))
+	router.Use(middleware.AuthMiddleware(firebaseService, verifier, securityLog, rateLimiter.Failures("auth", authFailureLimit)))
+	router.Use(middleware.RequestInfo())
+	router.Use(rateLimiter.Limit("api", apiRateLimit))
+
+	router.POST("/auth/logout", authController.Logout)
 
 	// Initialize Firebase models with error handling
 	initModel := func(name string, initFunc func() error) {
@@ -52,6 +111,13 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 		}
 	}
 
+	// Authorize routes against the caller's role in the current company
+	rbac := middleware.NewRBAC(models.NewPermissions(firebaseService.GetFirestore()), securityLog)
+
+	meHandler := handlers.NewMeHandler(models.NewPermissions(firebaseService.GetFirestore()), companyFirebase, memberships)
+	router.GET("/me/permissions", meHandler.Permissions)
+	router.GET("/me/companies", meHandler.Companies)
+
 	// Initialize models
 	initModel("supplier", func() error {
 		supplierFirebase := models.NewSupplierFirebase(firebaseService.GetFirestore())
@@ -61,11 +127,12 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 		supplierHandler := handlers.NewSupplierHandler(supplierFirebase)
 		suppliers := router.Group("/suppliers")
 		{
-			suppliers.GET("", supplierHandler.List)
-			suppliers.GET("/:id", supplierHandler.Get)
-			suppliers.POST("", supplierHandler.Create)
-			suppliers.PUT("/:id", supplierHandler.Update)
-			suppliers.DELETE("/:id", supplierHandler.Delete)
+			suppliers.GET("", rbac.Require("suppliers:read"), supplierHandler.List)
+			suppliers.GET("/:id", rbac.Require("suppliers:read"), supplierHandler.Get)
+			suppliers.POST("", rbac.Require("suppliers:create"), supplierHandler.Create)
+			suppliers.PUT("/:id", rbac.Require("suppliers:update"), supplierHandler.Update)
+			suppliers.PATCH("/:id", rbac.Require("suppliers:update"), supplierHandler.Patch)
+			suppliers.DELETE("/:id", rbac.Require("suppliers:delete"), supplierHandler.Delete)
 		}
 		return nil
 	})
@@ -78,21 +145,35 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 		if productFirebase == nil {
 			return fmt.Errorf("failed to create product model")
 		}
-		productHandler := handlers.NewProductHandler(productFirebase, fileStorage, imageRecognition, imageTrainingService)
-		products := router.Group("/products")
+		scans := models.NewScanFirebase(firebaseService.GetFirestore())
+		productHandler := handlers.NewProductHandler(productFirebase, fileStorage, imageRecognition, imageTrainingService, scans, datasetService)
+		recognitionHandler := handlers.NewRecognitionHandler(scans, models.NewRecognitionSettingsFirebase(firebaseService.GetFirestore()), productFirebase, datasetService)
+		products := router.Group("/products", rbac.Mask("products"))
 		{
-			products.GET("", productHandler.List)
-			products.GET("/:code", productHandler.Get)
-			products.POST("", productHandler.Create)
-			products.PUT("/:code", productHandler.Update)
-			products.DELETE("/:code", productHandler.Delete)
-			products.POST("/scan", productHandler.ScanProduct)
-			products.GET("/barcode/:barcode", productHandler.FindByBarcode)
-			products.GET("/company/:companyId", productHandler.FindByCompany)
-			products.PUT("/:code/image", productHandler.UpdateImage)
-			products.POST("/:code/image", productHandler.UploadImage)
-			products.POST("/upload", productHandler.Upload)
+			products.GET("", rbac.Require("products:read"), productHandler.List)
+			products.GET("/:code", rbac.Require("products:read"), productHandler.Get)
+			products.POST("", rbac.Require("products:create"), productHandler.Create)
+			products.PUT("/:code", rbac.Require("products:update"), productHandler.Update)
+			products.PATCH("/:code", rbac.Require("products:update"), productHandler.Patch)
+			products.DELETE("/:code", rbac.Require("products:delete"), productHandler.Delete)
+			products.POST("/scan", rateLimiter.Limit("scan", scanRateLimit), rbac.Require("products:read"), productHandler.ScanProduct)
+			products.POST("/scan/:scanId/confirm", rbac.Require("products:read"), recognitionHandler.Confirm)
+			products.GET("/scan/accuracy", rbac.Require("products:read"), recognitionHandler.Accuracy)
+			products.GET("/barcode/:barcode", rbac.Require("products:read"), productHandler.FindByBarcode)
+			products.GET("/company/:companyId", rbac.Require("products:read"), productHandler.FindByCompany)
+			products.PUT("/:code/image", rateLimiter.Limit("upload", uploadRateLimit), rbac.Require("products:update"), productHandler.UpdateImage)
+			products.POST("/:code/image", rateLimiter.Limit("upload", uploadRateLimit), rbac.Require("products:update"), productHandler.UploadImage)
+			products.POST("/upload", rateLimiter.Limit("upload", uploadRateLimit), rbac.Require("products:create"), productHandler.Upload)
+			products.POST("/recognition/index", rbac.Require("products:update"), productHandler.IndexImages)
+			products.GET("/recognition/settings", rbac.Require("products:read"), recognitionHandler.GetSettings)
+			products.PUT("/recognition/settings", rbac.Require("products:update"), recognitionHandler.UpdateSettings)
 		}
+
+		// Batch results are streamed, which masking would hold back; they carry
+		// no product fields that roles can hide
+		batchScanService := services.NewBatchScanService(imageRecognition, scans, datasetService, models.NewReceiveFirebase(firebaseService.GetFirestore()), productFirebase)
+		batchScanHandler := handlers.NewBatchScanHandler(batchScanService, rateLimiter.Charge("batch_scan", batchScanRateLimit))
+		router.POST("/products/scan/batch", rbac.Require("products:read"), batchScanHandler.Scan)
 		return nil
 	})
 
@@ -104,11 +185,12 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 		brandHandler := handlers.NewBrandHandler(brandFirebase)
 		brands := router.Group("/brands")
 		{
-			brands.GET("", brandHandler.List)
-			brands.GET("/:id", brandHandler.Get)
-			brands.POST("", brandHandler.Create)
-			brands.PUT("/:id", brandHandler.Update)
-			brands.DELETE("/:id", brandHandler.Delete)
+			brands.GET("", rbac.Require("brands:read"), brandHandler.List)
+			brands.GET("/:id", rbac.Require("brands:read"), brandHandler.Get)
+			brands.POST("", rbac.Require("brands:create"), brandHandler.Create)
+			brands.PUT("/:id", rbac.Require("brands:update"), brandHandler.Update)
+			brands.PATCH("/:id", rbac.Require("brands:update"), brandHandler.Patch)
+			brands.DELETE("/:id", rbac.Require("brands:delete"), brandHandler.Delete)
 		}
 		return nil
 	})
@@ -121,11 +203,12 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 		productCategoryHandler := handlers.NewProductCategoryHandler(productCategoryFirebase)
 		categories := router.Group("/product-categories")
 		{
-			categories.GET("", productCategoryHandler.List)
-			categories.GET("/:id", productCategoryHandler.Get)
-			categories.POST("", productCategoryHandler.Create)
-			categories.PUT("/:id", productCategoryHandler.Update)
-			categories.DELETE("/:id", productCategoryHandler.Delete)
+			categories.GET("", rbac.Require("product_categories:read"), productCategoryHandler.List)
+			categories.GET("/:id", rbac.Require("product_categories:read"), productCategoryHandler.Get)
+			categories.POST("", rbac.Require("product_categories:create"), productCategoryHandler.Create)
+			categories.PUT("/:id", rbac.Require("product_categories:update"), productCategoryHandler.Update)
+			categories.PATCH("/:id", rbac.Require("product_categories:update"), productCategoryHandler.Patch)
+			categories.DELETE("/:id", rbac.Require("product_categories:delete"), productCategoryHandler.Delete)
 		}
 		return nil
 	})
@@ -135,14 +218,15 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 		if userFirebase == nil {
 			return fmt.Errorf("failed to create user model")
 		}
-		userHandler := handlers.NewUserHandler(firebaseService, userFirebase)
+		userHandler := handlers.NewUserHandler(firebaseService, userFirebase, memberships)
 		users := router.Group("/users")
 		{
-			users.GET("", userHandler.ListUsers)
-			users.GET("/:id", userHandler.GetUser)
-			users.POST("", userHandler.CreateUser)
-			users.PUT("/:id", userHandler.UpdateUser)
-			users.DELETE("/:id", userHandler.DeleteUser)
+			users.GET("", rbac.Require("users:read"), userHandler.ListUsers)
+			users.GET("/:id", rbac.Require("users:read"), userHandler.GetUser)
+			users.POST("", rbac.Require("users:create"), userHandler.CreateUser)
+			users.PUT("/:id", rbac.Require("users:update"), userHandler.UpdateUser)
+			users.PATCH("/:id", rbac.Require("users:update"), userHandler.PatchUser)
+			users.DELETE("/:id", rbac.Require("users:delete"), userHandler.DeleteUser)
 			users.POST("/:id/join-company", userHandler.JoinCompany)
 		}
 		return nil
@@ -154,13 +238,53 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 		if companyFirebase == nil {
 			return fmt.Errorf("failed to create company model")
 		}
-		companyHandler := handlers.NewCompanyHandler(companyFirebase)
+		companyHandler := handlers.NewCompanyHandler(companyFirebase, memberships)
 		companies := router.Group("/companies")
 		{
-			companies.GET("/:id", companyHandler.Get)
+			companies.GET("/:id", rbac.Require("companies:read"), companyHandler.Get)
 			companies.POST("", companyHandler.Create)
-			companies.PUT("/:id", companyHandler.Update)
-			companies.DELETE("/:id", companyHandler.Delete)
+			companies.PUT("/:id", rbac.Require("companies:update"), companyHandler.Update)
+			companies.PATCH("/:id", rbac.Require("companies:update"), companyHandler.Patch)
+			companies.DELETE("/:id", rbac.Require("companies:delete"), companyHandler.Delete)
+		}
+		return nil
+	})
+
+	initModel("membership", func() error {
+		membershipHandler := handlers.NewMembershipHandler(memberships, models.NewPermissions(firebaseService.GetFirestore()))
+		membershipRoutes := router.Group("/memberships")
+		{
+			membershipRoutes.GET("", rbac.Require("users:read"), membershipHandler.List)
+			membershipRoutes.PUT("/:id", rbac.Require("users:update"), membershipHandler.Update)
+			membershipRoutes.DELETE("/:id", rbac.Require("users:delete"), membershipHandler.Delete)
+		}
+		return nil
+	})
+
+	initModel("invitation", func() error {
+		invitationHandler := handlers.NewInvitationHandler(models.NewInvitationFirebase(firebaseService.GetFirestore()), companyFirebase, models.NewPermissions(firebaseService.GetFirestore()), mailer)
+		invitations := router.Group("/invitations")
+		{
+			invitations.GET("", rbac.Require("users:read"), invitationHandler.List)
+			invitations.POST("", rbac.Require("users:create"), invitationHandler.Create)
+			invitations.DELETE("/:id", rbac.Require("users:delete"), invitationHandler.Revoke)
+			invitations.POST("/accept", invitationHandler.Accept)
+			invitations.POST("/decline", invitationHandler.Decline)
+		}
+		return nil
+	})
+
+	initModel("service account", func() error {
+		serviceAccountHandler := handlers.NewServiceAccountHandler(models.NewServiceAccountFirebase(firebaseService.GetFirestore()))
+		serviceAccounts := router.Group("/service-accounts")
+		{
+			serviceAccounts.GET("", rbac.Require("service_accounts:read"), serviceAccountHandler.List)
+			serviceAccounts.GET("/:id", rbac.Require("service_accounts:read"), serviceAccountHandler.Get)
+			serviceAccounts.POST("", rbac.Require("service_accounts:create"), serviceAccountHandler.Create)
+			serviceAccounts.PUT("/:id", rbac.Require("service_accounts:update"), serviceAccountHandler.Update)
+			serviceAccounts.DELETE("/:id", rbac.Require("service_accounts:delete"), serviceAccountHandler.Delete)
+			serviceAccounts.POST("/:id/keys", rbac.Require("service_accounts:update"), serviceAccountHandler.CreateKey)
+			serviceAccounts.DELETE("/:id/keys/:keyId", rbac.Require("service_accounts:update"), serviceAccountHandler.RevokeKey)
 		}
 		return nil
 	})
@@ -173,11 +297,12 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 		customerHandler := handlers.NewCustomerHandler(customerFirebase)
 		customers := router.Group("/customers")
 		{
-			customers.GET("", customerHandler.List)
-			customers.GET("/:id", customerHandler.Get)
-			customers.POST("", customerHandler.Create)
-			customers.PUT("/:id", customerHandler.Update)
-			customers.DELETE("/:id", customerHandler.Delete)
+			customers.GET("", rbac.Require("customers:read"), customerHandler.List)
+			customers.GET("/:id", rbac.Require("customers:read"), customerHandler.Get)
+			customers.POST("", rbac.Require("customers:create"), customerHandler.Create)
+			customers.PUT("/:id", rbac.Require("customers:update"), customerHandler.Update)
+			customers.PATCH("/:id", rbac.Require("customers:update"), customerHandler.Patch)
+			customers.DELETE("/:id", rbac.Require("customers:delete"), customerHandler.Delete)
 		}
 		return nil
 	})
@@ -190,11 +315,12 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 		salesmanHandler := handlers.NewSalesmanHandler(salesmanFirebase)
 		salesmen := router.Group("/salesmen")
 		{
-			salesmen.GET("", salesmanHandler.List)
-			salesmen.GET("/:id", salesmanHandler.Get)
-			salesmen.POST("", salesmanHandler.Create)
-			salesmen.PUT("/:id", salesmanHandler.Update)
-			salesmen.DELETE("/:id", salesmanHandler.Delete)
+			salesmen.GET("", rbac.Require("salesmen:read"), salesmanHandler.List)
+			salesmen.GET("/:id", rbac.Require("salesmen:read"), salesmanHandler.Get)
+			salesmen.POST("", rbac.Require("salesmen:create"), salesmanHandler.Create)
+			salesmen.PUT("/:id", rbac.Require("salesmen:update"), salesmanHandler.Update)
+			salesmen.PATCH("/:id", rbac.Require("salesmen:update"), salesmanHandler.Patch)
+			salesmen.DELETE("/:id", rbac.Require("salesmen:delete"), salesmanHandler.Delete)
 		}
 		return nil
 	})
@@ -207,11 +333,47 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 		shelveHandler := handlers.NewShelveHandler(shelveFirebase)
 		shelves := router.Group("/shelves")
 		{
-			shelves.GET("", shelveHandler.List)
-			shelves.GET("/:id", shelveHandler.Get)
-			shelves.POST("", shelveHandler.Create)
-			shelves.PUT("/:id", shelveHandler.Update)
-			shelves.DELETE("/:id", shelveHandler.Delete)
+			shelves.GET("", rbac.Require("shelves:read"), shelveHandler.List)
+			shelves.GET("/:id", rbac.Require("shelves:read"), shelveHandler.Get)
+			shelves.POST("", rbac.Require("shelves:create"), shelveHandler.Create)
+			shelves.PUT("/:id", rbac.Require("shelves:update"), shelveHandler.Update)
+			shelves.PATCH("/:id", rbac.Require("shelves:update"), shelveHandler.Patch)
+			shelves.DELETE("/:id", rbac.Require("shelves:delete"), shelveHandler.Delete)
+		}
+		return nil
+	})
+
+	initModel("label", func() error {
+		productFirebase, err := models.NewProductFirebase(firebaseService.GetFirestore())
+		if err != nil {
+			return fmt.Errorf("failed to create product model: %v", err)
+		}
+		labelHandler := handlers.NewLabelHandler(
+			models.NewBarcodeFirebase(firebaseService.GetFirestore()),
+			productFirebase,
+			models.NewShelveFirebase(firebaseService.GetFirestore()),
+		)
+		router.POST("/products/barcodes", rbac.Require("products:update"), labelHandler.AssignBarcodes)
+		labels := router.Group("/labels")
+		{
+			labels.POST("/products", rbac.Require("products:read"), labelHandler.ProductLabels)
+			labels.POST("/shelves", rbac.Require("shelves:read"), labelHandler.ShelfLabels)
+		}
+		return nil
+	})
+
+	initModel("dataset", func() error {
+		datasetHandler := handlers.NewDatasetHandler(models.NewDatasetFirebase(firebaseService.GetFirestore()), datasetService)
+		datasets := router.Group("/dataset")
+		{
+			datasets.GET("/images", rbac.Require("products:read"), datasetHandler.ListImages)
+			datasets.GET("/images/:id", rbac.Require("products:read"), datasetHandler.GetImage)
+			datasets.POST("/images", rateLimiter.Limit("upload", uploadRateLimit), rbac.Require("products:update"), datasetHandler.UploadImage)
+			datasets.PUT("/images/:id", rbac.Require("products:update"), datasetHandler.UpdateImage)
+			datasets.GET("/snapshots", rbac.Require("products:read"), datasetHandler.ListSnapshots)
+			datasets.POST("/snapshots", rbac.Require("products:update"), datasetHandler.CreateSnapshot)
+			datasets.GET("/snapshots/:id", rbac.Require("products:read"), datasetHandler.GetSnapshot)
+			datasets.GET("/snapshots/:id/export", rbac.Require("products:read"), datasetHandler.ExportSnapshot)
 		}
 		return nil
 	})
@@ -224,11 +386,12 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 		regionHandler := handlers.NewRegionHandler(regionFirebase)
 		regions := router.Group("/regions")
 		{
-			regions.GET("", regionHandler.List)
-			regions.GET("/:id", regionHandler.Get)
-			regions.POST("", regionHandler.Create)
-			regions.PUT("/:id", regionHandler.Update)
-			regions.DELETE("/:id", regionHandler.Delete)
+			regions.GET("", rbac.Require("regions:read"), regionHandler.List)
+			regions.GET("/:id", rbac.Require("regions:read"), regionHandler.Get)
+			regions.POST("", rbac.Require("regions:create"), regionHandler.Create)
+			regions.PUT("/:id", rbac.Require("regions:update"), regionHandler.Update)
+			regions.PATCH("/:id", rbac.Require("regions:update"), regionHandler.Patch)
+			regions.DELETE("/:id", rbac.Require("regions:delete"), regionHandler.Delete)
 		}
 		return nil
 	})
@@ -241,11 +404,12 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 		roleHandler := handlers.NewRoleHandler(roleFirebase)
 		roles := router.Group("/roles")
 		{
-			roles.GET("", roleHandler.List)
-			roles.GET("/:id", roleHandler.Get)
-			roles.POST("", roleHandler.Create)
-			roles.PUT("/:id", roleHandler.Update)
-			roles.DELETE("/:id", roleHandler.Delete)
+			roles.GET("", rbac.Require("roles:read"), roleHandler.List)
+			roles.GET("/:id", rbac.Require("roles:read"), roleHandler.Get)
+			roles.POST("", rbac.Require("roles:create"), roleHandler.Create)
+			roles.PUT("/:id", rbac.Require("roles:update"), roleHandler.Update)
+			roles.PATCH("/:id", rbac.Require("roles:update"), roleHandler.Patch)
+			roles.DELETE("/:id", rbac.Require("roles:delete"), roleHandler.Delete)
 		}
 		return nil
 	})
@@ -258,11 +422,12 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 		accessHandler := handlers.NewAccessHandler(accessFirebase)
 		access := router.Group("/access")
 		{
-			access.GET("", accessHandler.List)
-			access.GET("/:id", accessHandler.Get)
-			access.POST("", accessHandler.Create)
-			access.PUT("/:id", accessHandler.Update)
-			access.DELETE("/:id", accessHandler.Delete)
+			access.GET("", rbac.Require("access:read"), accessHandler.List)
+			access.GET("/:id", rbac.Require("access:read"), accessHandler.Get)
+			access.POST("", rbac.Require("access:create"), accessHandler.Create)
+			access.PUT("/:id", rbac.Require("access:update"), accessHandler.Update)
+			access.PATCH("/:id", rbac.Require("access:update"), accessHandler.Patch)
+			access.DELETE("/:id", rbac.Require("access:delete"), accessHandler.Delete)
 		}
 		return nil
 	})
@@ -275,11 +440,12 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 		branchHandler := handlers.NewBranchHandler(branchFirebase)
 		branches := router.Group("/branches")
 		{
-			branches.GET("", branchHandler.List)
-			branches.GET("/:id", branchHandler.Get)
-			branches.POST("", branchHandler.Create)
-			branches.PUT("/:id", branchHandler.Update)
-			branches.DELETE("/:id", branchHandler.Delete)
+			branches.GET("", rbac.Require("branches:read"), branchHandler.List)
+			branches.GET("/:id", rbac.Require("branches:read"), branchHandler.Get)
+			branches.POST("", rbac.Require("branches:create"), branchHandler.Create)
+			branches.PUT("/:id", rbac.Require("branches:update"), branchHandler.Update)
+			branches.PATCH("/:id", rbac.Require("branches:update"), branchHandler.Patch)
+			branches.DELETE("/:id", rbac.Require("branches:delete"), branchHandler.Delete)
 		}
 		return nil
 	})
@@ -290,13 +456,86 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 			return fmt.Errorf("failed to create purchase model")
 		}
 		purchaseHandler := handlers.NewPurchaseHandler(purchaseFirebase)
-		purchases := router.Group("/purchases")
+		purchases := router.Group("/purchases", rbac.Mask("purchases"))
+		{
+			purchases.GET("", rbac.Require("purchases:read"), purchaseHandler.List)
+			purchases.GET("/:id", rbac.Require("purchases:read"), purchaseHandler.Get)
+			purchases.POST("", rbac.Require("purchases:create"), purchaseHandler.Create)
+			purchases.PUT("/:id", rbac.Require("purchases:update"), purchaseHandler.Update)
+			purchases.PATCH("/:id", rbac.Require("purchases:update"), purchaseHandler.Patch)
+			purchases.DELETE("/:id", rbac.Require("purchases:delete"), purchaseHandler.Delete)
+		}
+		return nil
+	})
+
+	initModel("approval", func() error {
+		approvals := models.NewApprovalFirebase(firebaseService.GetFirestore())
+		approvalHandler := handlers.NewApprovalHandler(approvals)
+		rules := router.Group("/approval-rules")
 		{
-			purchases.GET("", purchaseHandler.List)
-			purchases.GET("/:id", purchaseHandler.Get)
-			purchases.POST("", purchaseHandler.Create)
-			purchases.PUT("/:id", purchaseHandler.Update)
-			purchases.DELETE("/:id", purchaseHandler.Delete)
+			rules.GET("", rbac.Require("approval_rules:read"), approvalHandler.ListRules)
+			rules.POST("", rbac.Require("approval_rules:create"), approvalHandler.CreateRule)
+			rules.PUT("/:id", rbac.Require("approval_rules:update"), approvalHandler.UpdateRule)
+			rules.DELETE("/:id", rbac.Require("approval_rules:delete"), approvalHandler.DeleteRule)
+		}
+		// Deciding also needs the permission of the approval's current level
+		approvalRoutes := router.Group("/approvals")
+		{
+			approvalRoutes.GET("", rbac.Require("approvals:read"), approvalHandler.List)
+			approvalRoutes.GET("/:id", rbac.Require("approvals:read"), approvalHandler.Get)
+			approvalRoutes.POST("/:id/approve", rbac.Require("approvals:read"), approvalHandler.Approve)
+			approvalRoutes.POST("/:id/reject", rbac.Require("approvals:read"), approvalHandler.Reject)
+		}
+
+		productFirebase, err := models.NewProductFirebase(firebaseService.GetFirestore())
+		if err != nil {
+			return fmt.Errorf("failed to create product model: %v", err)
+		}
+		stockAdjustmentHandler := handlers.NewStockAdjustmentHandler(models.NewStockAdjustmentFirebase(firebaseService.GetFirestore(), productFirebase, approvals))
+		adjustments := router.Group("/stock-adjustments", rbac.Mask("products"))
+		{
+			adjustments.GET("", rbac.Require("stock_adjustments:read"), stockAdjustmentHandler.List)
+			adjustments.POST("", rbac.Require("stock_adjustments:create"), stockAdjustmentHandler.Create)
+		}
+		return nil
+	})
+
+	initModel("stock", func() error {
+		stockHandler := handlers.NewStockHandler(models.NewStockFirebase(firebaseService.GetFirestore()))
+		router.GET("/stock", rbac.Require("products:read"), stockHandler.List)
+		return nil
+	})
+
+	initModel("shelf audit", func() error {
+		productFirebase, err := models.NewProductFirebase(firebaseService.GetFirestore())
+		if err != nil {
+			return fmt.Errorf("failed to create product model: %v", err)
+		}
+		stockAdjustments := models.NewStockAdjustmentFirebase(firebaseService.GetFirestore(), productFirebase, models.NewApprovalFirebase(firebaseService.GetFirestore()))
+		stockCounts := models.NewStockCountFirebase(firebaseService.GetFirestore(), stockAdjustments)
+		shelfAudits := models.NewShelfAuditFirebase(firebaseService.GetFirestore())
+		shelfAuditService := services.NewShelfAuditService(imageRecognition, fileStorage, models.NewShelveFirebase(firebaseService.GetFirestore()), shelfAudits, stockCounts)
+		shelfAuditHandler := handlers.NewShelfAuditHandler(shelfAudits, shelfAuditService)
+		shelves := router.Group("/shelves")
+		{
+			shelves.GET("/:id/planogram", rbac.Require("shelves:read"), shelfAuditHandler.GetPlanogram)
+			shelves.PUT("/:id/planogram", rbac.Require("shelves:update"), shelfAuditHandler.SavePlanogram)
+			shelves.GET("/:id/audits", rbac.Require("shelves:read"), shelfAuditHandler.ListAudits)
+			shelves.POST("/:id/audits", rateLimiter.Limit("scan", scanRateLimit), rbac.Require("shelves:update"), shelfAuditHandler.Audit)
+		}
+		audits := router.Group("/shelf-audits")
+		{
+			audits.GET("/:id", rbac.Require("shelves:read"), shelfAuditHandler.GetAudit)
+			audits.POST("/:id/stock-count", rbac.Require("stock_adjustments:create"), shelfAuditHandler.SeedStockCount)
+		}
+
+		stockCountHandler := handlers.NewStockCountHandler(stockCounts)
+		counts := router.Group("/stock-counts")
+		{
+			counts.GET("", rbac.Require("stock_adjustments:read"), stockCountHandler.List)
+			counts.GET("/:id", rbac.Require("stock_adjustments:read"), stockCountHandler.Get)
+			counts.PUT("/:id/counts", rbac.Require("stock_adjustments:create"), stockCountHandler.SetCounts)
+			counts.POST("/:id/complete", rbac.Require("stock_adjustments:create"), stockCountHandler.Complete)
 		}
 		return nil
 	})
@@ -313,13 +552,14 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 		salesOrderHandler := handlers.NewSalesOrderHandler(salesOrderFirebase, productFirebase)
 		salesOrders := router.Group("/sales-orders")
 		{
-			salesOrders.GET("", salesOrderHandler.List)
-			salesOrders.GET("/:id", salesOrderHandler.Get)
-			salesOrders.POST("", salesOrderHandler.Create)
-			salesOrders.PUT("/:id", salesOrderHandler.Update)
-			salesOrders.DELETE("/:id", salesOrderHandler.Delete)
-			salesOrders.GET("/stats", salesOrderHandler.Stats)
-			salesOrders.POST("/update-stock", salesOrderHandler.UpdateProductStock)
+			salesOrders.GET("", rbac.Require("sales_orders:read"), salesOrderHandler.List)
+			salesOrders.GET("/:id", rbac.Require("sales_orders:read"), salesOrderHandler.Get)
+			salesOrders.POST("", rbac.Require("sales_orders:create"), salesOrderHandler.Create)
+			salesOrders.PUT("/:id", rbac.Require("sales_orders:update"), salesOrderHandler.Update)
+			salesOrders.PATCH("/:id", rbac.Require("sales_orders:update"), salesOrderHandler.Patch)
+			salesOrders.DELETE("/:id", rbac.Require("sales_orders:delete"), salesOrderHandler.Delete)
+			salesOrders.GET("/stats", rbac.Mask("reports"), rbac.Require("sales_orders:read"), salesOrderHandler.Stats)
+			salesOrders.POST("/update-stock", rbac.Require("products:update"), salesOrderHandler.UpdateProductStock)
 		}
 		return nil
 	})
@@ -332,11 +572,12 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 		receiveHandler := handlers.NewReceiveHandler(receiveFirebase)
 		receives := router.Group("/receives")
 		{
-			receives.GET("", receiveHandler.List)
-			receives.GET("/:id", receiveHandler.Get)
-			receives.POST("", receiveHandler.Create)
-			receives.PUT("/:id", receiveHandler.Update)
-			receives.DELETE("/:id", receiveHandler.Delete)
+			receives.GET("", rbac.Require("receives:read"), receiveHandler.List)
+			receives.GET("/:id", rbac.Require("receives:read"), receiveHandler.Get)
+			receives.POST("", rbac.Require("receives:create"), receiveHandler.Create)
+			receives.PUT("/:id", rbac.Require("receives:update"), receiveHandler.Update)
+			receives.PATCH("/:id", rbac.Require("receives:update"), receiveHandler.Patch)
+			receives.DELETE("/:id", rbac.Require("receives:delete"), receiveHandler.Delete)
 		}
 		return nil
 	})
@@ -349,11 +590,12 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 		deliveryHandler := handlers.NewDeliveryHandler(deliveryFirebase)
 		deliveries := router.Group("/deliveries")
 		{
-			deliveries.GET("", deliveryHandler.List)
-			deliveries.GET("/:id", deliveryHandler.Get)
-			deliveries.POST("", deliveryHandler.Create)
-			deliveries.PUT("/:id", deliveryHandler.Update)
-			deliveries.DELETE("/:id", deliveryHandler.Delete)
+			deliveries.GET("", rbac.Require("deliveries:read"), deliveryHandler.List)
+			deliveries.GET("/:id", rbac.Require("deliveries:read"), deliveryHandler.Get)
+			deliveries.POST("", rbac.Require("deliveries:create"), deliveryHandler.Create)
+			deliveries.PUT("/:id", rbac.Require("deliveries:update"), deliveryHandler.Update)
+			deliveries.PATCH("/:id", rbac.Require("deliveries:update"), deliveryHandler.Patch)
+			deliveries.DELETE("/:id", rbac.Require("deliveries:delete"), deliveryHandler.Delete)
 		}
 		return nil
 	})
@@ -366,11 +608,12 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 		purchaseReturnHandler := handlers.NewPurchaseReturnHandler(purchaseReturnFirebase)
 		purchaseReturns := router.Group("/purchase-returns")
 		{
-			purchaseReturns.GET("", purchaseReturnHandler.List)
-			purchaseReturns.GET("/:id", purchaseReturnHandler.Get)
-			purchaseReturns.POST("", purchaseReturnHandler.Create)
-			purchaseReturns.PUT("/:id", purchaseReturnHandler.Update)
-			purchaseReturns.DELETE("/:id", purchaseReturnHandler.Delete)
+			purchaseReturns.GET("", rbac.Require("purchase_returns:read"), purchaseReturnHandler.List)
+			purchaseReturns.GET("/:id", rbac.Require("purchase_returns:read"), purchaseReturnHandler.Get)
+			purchaseReturns.POST("", rbac.Require("purchase_returns:create"), purchaseReturnHandler.Create)
+			purchaseReturns.PUT("/:id", rbac.Require("purchase_returns:update"), purchaseReturnHandler.Update)
+			purchaseReturns.PATCH("/:id", rbac.Require("purchase_returns:update"), purchaseReturnHandler.Patch)
+			purchaseReturns.DELETE("/:id", rbac.Require("purchase_returns:delete"), purchaseReturnHandler.Delete)
 		}
 		return nil
 	})
@@ -383,11 +626,12 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 		salesOrderReturnHandler := handlers.NewSalesOrderReturnHandler(salesOrderReturnFirebase)
 		salesOrderReturns := router.Group("/sales-order-returns")
 		{
-			salesOrderReturns.GET("", salesOrderReturnHandler.List)
-			salesOrderReturns.GET("/:id", salesOrderReturnHandler.Get)
-			salesOrderReturns.POST("", salesOrderReturnHandler.Create)
-			salesOrderReturns.PUT("/:id", salesOrderReturnHandler.Update)
-			salesOrderReturns.DELETE("/:id", salesOrderReturnHandler.Delete)
+			salesOrderReturns.GET("", rbac.Require("sales_order_returns:read"), salesOrderReturnHandler.List)
+			salesOrderReturns.GET("/:id", rbac.Require("sales_order_returns:read"), salesOrderReturnHandler.Get)
+			salesOrderReturns.POST("", rbac.Require("sales_order_returns:create"), salesOrderReturnHandler.Create)
+			salesOrderReturns.PUT("/:id", rbac.Require("sales_order_returns:update"), salesOrderReturnHandler.Update)
+			salesOrderReturns.PATCH("/:id", rbac.Require("sales_order_returns:update"), salesOrderReturnHandler.Patch)
+			salesOrderReturns.DELETE("/:id", rbac.Require("sales_order_returns:delete"), salesOrderReturnHandler.Delete)
 		}
 		return nil
 	})
@@ -400,11 +644,12 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 		receiveReturnHandler := handlers.NewReceiveReturnHandler(receiveReturnFirebase)
 		receiveReturns := router.Group("/receive-returns")
 		{
-			receiveReturns.GET("", receiveReturnHandler.List)
-			receiveReturns.GET("/:id", receiveReturnHandler.Get)
-			receiveReturns.POST("", receiveReturnHandler.Create)
-			receiveReturns.PUT("/:id", receiveReturnHandler.Update)
-			receiveReturns.DELETE("/:id", receiveReturnHandler.Delete)
+			receiveReturns.GET("", rbac.Require("receive_returns:read"), receiveReturnHandler.List)
+			receiveReturns.GET("/:id", rbac.Require("receive_returns:read"), receiveReturnHandler.Get)
+			receiveReturns.POST("", rbac.Require("receive_returns:create"), receiveReturnHandler.Create)
+			receiveReturns.PUT("/:id", rbac.Require("receive_returns:update"), receiveReturnHandler.Update)
+			receiveReturns.PATCH("/:id", rbac.Require("receive_returns:update"), receiveReturnHandler.Patch)
+			receiveReturns.DELETE("/:id", rbac.Require("receive_returns:delete"), receiveReturnHandler.Delete)
 		}
 		return nil
 	})
@@ -417,11 +662,12 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 		deliveryReturnHandler := handlers.NewDeliveryReturnHandler(deliveryReturnFirebase)
 		deliveryReturns := router.Group("/delivery-returns")
 		{
-			deliveryReturns.GET("", deliveryReturnHandler.List)
-			deliveryReturns.GET("/:id", deliveryReturnHandler.Get)
-			deliveryReturns.POST("", deliveryReturnHandler.Create)
-			deliveryReturns.PUT("/:id", deliveryReturnHandler.Update)
-			deliveryReturns.DELETE("/:id", deliveryReturnHandler.Delete)
+			deliveryReturns.GET("", rbac.Require("delivery_returns:read"), deliveryReturnHandler.List)
+			deliveryReturns.GET("/:id", rbac.Require("delivery_returns:read"), deliveryReturnHandler.Get)
+			deliveryReturns.POST("", rbac.Require("delivery_returns:create"), deliveryReturnHandler.Create)
+			deliveryReturns.PUT("/:id", rbac.Require("delivery_returns:update"), deliveryReturnHandler.Update)
+			deliveryReturns.PATCH("/:id", rbac.Require("delivery_returns:update"), deliveryReturnHandler.Patch)
+			deliveryReturns.DELETE("/:id", rbac.Require("delivery_returns:delete"), deliveryReturnHandler.Delete)
 		}
 		return nil
 	})
@@ -440,12 +686,69 @@ func SetupRoutes(router *gin.Engine, firebaseService *services.FirebaseService)
 			return fmt.Errorf("failed to create predictive service")
 		}
 		predictiveHandler := handlers.NewPredictiveHandler(predictiveService)
-		predictive := router.Group("/predictive")
+		predictive := router.Group("/predictive", rbac.Mask("reports"))
+		{
+			predictive.GET("/stock-recommendations/:companyId", rbac.Require("reports:read"), predictiveHandler.GetStockRecommendations)
+			predictive.GET("/sales-predictions/:companyId", rbac.Require("reports:read"), predictiveHandler.GetSalesPredictions)
+			predictive.GET("/product-history/:productCode", rbac.Require("reports:read"), predictiveHandler.GetProductHistory)
+			predictive.GET("/sales-report/:companyId", rbac.Require("reports:read"), predictiveHandler.GetSalesReport)
+		}
+		return nil
+	})
+
+	initModel("training", func() error {
+		training := router.Group("/training")
+		{
+			training.GET("/jobs", rbac.Require("products:read"), trainingHandler.ListJobs)
+			training.POST("/jobs", rbac.Require("products:update"), trainingHandler.CreateJob)
+			training.GET("/jobs/:id", rbac.Require("products:read"), trainingHandler.GetJob)
+			training.POST("/jobs/:id/cancel", rbac.Require("products:update"), trainingHandler.CancelJob)
+			training.GET("/models", rbac.Require("products:read"), trainingHandler.ListModels)
+			training.POST("/models/:id/pin", rbac.Require("products:update"), trainingHandler.PinModel)
+			training.DELETE("/models/pin", rbac.Require("products:update"), trainingHandler.UnpinModel)
+		}
+		return nil
+	})
+
+	initModel("trash", func() error {
+		trash := models.NewTrash(firebaseService.GetFirestore())
+
+		retentionDays := 30
+		if days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS")); err == nil && days > 0 {
+			retentionDays = days
+		}
+		services.NewTrashPurger(trash, time.Duration(retentionDays)*24*time.Hour, 24*time.Hour).Start(context.Background())
+
+		trashHandler := handlers.NewTrashHandler(trash)
+		trashRoutes := router.Group("/trash")
+		{
+			trashRoutes.GET("", rbac.Require("trash:read"), trashHandler.List)
+			trashRoutes.POST("/:collection/:id/restore", rbac.Require("trash:restore"), trashHandler.Restore)
+			trashRoutes.DELETE("/:collection/:id", rbac.Require("trash:delete"), trashHandler.Delete)
+		}
+		return nil
+	})
+
+	initModel("audit", func() error {
+		auditHandler := handlers.NewAuditHandler(models.NewAuditLog(firebaseService.GetFirestore()))
+		audit := router.Group("/audit")
+		{
+			audit.GET("", rbac.Require("audit:read"), auditHandler.List)
+			audit.GET("/:entity/:id", rbac.Require("audit:read"), auditHandler.History)
+		}
+		return nil
+	})
+
+	initModel("security", func() error {
+		securityHandler := handlers.NewSecurityHandler(securityLog)
+		security := router.Group("/security")
 		{
-			predictive.GET("/stock-recommendations/:companyId", predictiveHandler.GetStockRecommendations)
-			predictive.GET("/sales-predictions/:companyId", predictiveHandler.GetSalesPredictions)
-			predictive.GET("/product-history/:productCode", predictiveHandler.GetProductHistory)
-			predictive.GET("/sales-report/:companyId", predictiveHandler.GetSalesReport)
+			security.GET("/events", rbac.Require("security:manage"), securityHandler.Events)
+			security.GET("/alerts", rbac.Require("security:manage"), securityHandler.Alerts)
+			security.GET("/alert-rules", rbac.Require("security:manage"), securityHandler.ListRules)
+			security.POST("/alert-rules", rbac.Require("security:manage"), securityHandler.CreateRule)
+			security.PUT("/alert-rules/:id", rbac.Require("security:manage"), securityHandler.UpdateRule)
+			security.DELETE("/alert-rules/:id", rbac.Require("security:manage"), securityHandler.DeleteRule)
 		}
 		return nil
 	})
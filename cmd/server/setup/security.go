@@ -0,0 +1,35 @@
+package setup
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/nirshpaa/godam-backend/interfaces"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/services"
+)
+
+// Failed authentication has no verified company, so company alert rules never
+// see it. One client IP failing this often raises an alert to the addresses
+// in SECURITY_ALERT_RECIPIENTS instead.
+const (
+	authFailureAlertThreshold = 20
+	authFailureAlertWindow    = 10
+)
+
+// setupSecurityLog records security events in the background and delivers
+// their alerts by email
+func setupSecurityLog(firebaseService *services.FirebaseService, mailer interfaces.MailSender) *models.SecurityLog {
+	var recipients []string
+	for _, recipient := range strings.Split(os.Getenv("SECURITY_ALERT_RECIPIENTS"), ",") {
+		if recipient = strings.TrimSpace(recipient); recipient != "" {
+			recipients = append(recipients, recipient)
+		}
+	}
+
+	securityLog := models.NewSecurityLog(firebaseService.GetFirestore(), services.NewMailNotifier(mailer),
+		models.AuthFailureIPRule(authFailureAlertThreshold, authFailureAlertWindow, recipients))
+	securityLog.Start(context.Background())
+	return securityLog
+}
@@ -57,12 +57,10 @@ func NewServer() (*Server, error) {
 	// Serve static files from the assets directory (before auth middleware)
 	router.Static("/assets", "./assets")
 	router.Static("/images", "./public/images")
-	router.Static("/uploads", "./assets/products")
 
 	// Apply middleware in correct order
 	router.Use(gin.Recovery())
 	router.Use(middleware.Logger(logger))
-	router.Use(middleware.AuthMiddleware(firebaseService))
 	router.Use(middleware.CORS())
 
 	return &Server{
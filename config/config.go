@@ -2,14 +2,27 @@ package config
 
 import (
 	"os"
+	"strconv"
+
+	"github.com/nirshpaa/godam-backend/libraries/objectstore"
 )
 
 // Config holds the application configuration
 type Config struct {
 	StorageBucket string
 	UploadPath    string
+	DatasetPath   string
 	BarcodeAPI    string
 	CNNEndpoint   string
+	APIURL        string
+
+	// StorageBackend is local, s3 or gcs
+	StorageBackend  string
+	S3              objectstore.S3Config
+	CredentialsFile string
+
+	// RateLimitStore is memory or firestore
+	RateLimitStore string
 }
 
 var config *Config
@@ -19,10 +32,31 @@ func GetConfig() *Config {
 	if config == nil {
 		config = &Config{
 			StorageBucket: os.Getenv("STORAGE_BUCKET"),
-			UploadPath:    os.Getenv("UPLOAD_PATH"),
+			UploadPath:    getenv("UPLOAD_PATH", "./assets/products"),
+			DatasetPath:   getenv("DATASET_PATH", "./assets/dataset"),
 			BarcodeAPI:    os.Getenv("BARCODE_API_ENDPOINT"),
 			CNNEndpoint:   os.Getenv("CNN_API_ENDPOINT"),
+			APIURL:        getenv("API_URL", "http://localhost:8000"),
+
+			StorageBackend: getenv("STORAGE_BACKEND", objectstore.BackendLocal),
+			S3: objectstore.S3Config{
+				Endpoint:        os.Getenv("S3_ENDPOINT"),
+				Region:          os.Getenv("S3_REGION"),
+				Bucket:          os.Getenv("S3_BUCKET"),
+				AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+				SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			},
+			CredentialsFile: getenv("FIREBASE_CREDENTIALS_FILE", "firebase-credentials.json"),
+			RateLimitStore:  getenv("RATE_LIMIT_STORE", "memory"),
 		}
+		config.S3.PathStyle, _ = strconv.ParseBool(os.Getenv("S3_PATH_STYLE"))
 	}
 	return config
 }
+
+func getenv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
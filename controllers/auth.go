@@ -2,18 +2,28 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"path/filepath"
 
 	firebase "firebase.google.com/go/v4"
 	"firebase.google.com/go/v4/auth"
 	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/libraries/token"
+	"github.com/nirshpaa/godam-backend/models"
 	"google.golang.org/api/option"
 )
 
-// AuthController handles authentication-related operations
+// AuthController handles authentication-related operations. It is backed by
+// Firebase Authentication, or by local accounts and tokens when created with
+// NewLocalAuthController.
 type AuthController struct {
 	authClient *auth.Client
+
+	issuer        *token.LocalIssuer
+	accounts      *models.LocalAccountFirebase
+	refreshTokens *models.RefreshTokenFirebase
+	revocations   *models.TokenRevocations
 }
 
 // NewAuthController creates a new auth controller
@@ -32,11 +42,32 @@ func NewAuthController() *AuthController {
 		panic(err)
 	}
 
+	return NewFirebaseAuthController(authClient)
+}
+
+// NewFirebaseAuthController creates an auth controller backed by an existing Firebase Auth client
+func NewFirebaseAuthController(authClient *auth.Client) *AuthController {
 	return &AuthController{
 		authClient: authClient,
 	}
 }
 
+// NewLocalAuthController creates an auth controller that issues its own
+// access and refresh tokens, for deployments without Firebase Authentication
+func NewLocalAuthController(issuer *token.LocalIssuer, accounts *models.LocalAccountFirebase, refreshTokens *models.RefreshTokenFirebase, revocations *models.TokenRevocations) *AuthController {
+	return &AuthController{
+		issuer:        issuer,
+		accounts:      accounts,
+		refreshTokens: refreshTokens,
+		revocations:   revocations,
+	}
+}
+
+// Local reports whether the controller issues its own tokens
+func (a *AuthController) Local() bool {
+	return a.issuer != nil
+}
+
 // Login handles user login
 func (a *AuthController) Login(c *gin.Context) {
 	var credentials struct {
@@ -49,6 +80,26 @@ func (a *AuthController) Login(c *gin.Context) {
 		return
 	}
 
+	if a.Local() {
+		uid, email, err := a.accounts.Authenticate(c.Request.Context(), credentials.Email, credentials.Password)
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify credentials"})
+			return
+		}
+
+		refreshToken, err := a.refreshTokens.Issue(c.Request.Context(), uid, email)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		a.respondTokens(c, uid, email, refreshToken)
+		return
+	}
+
 	// Get user by email
 	user, err := a.authClient.GetUserByEmail(context.Background(), credentials.Email)
 	if err != nil {
@@ -74,6 +125,7 @@ func (a *AuthController) Register(c *gin.Context) {
 	var user struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		Name     string `json:"name"`
 	}
 
 	if err := c.ShouldBindJSON(&user); err != nil {
@@ -81,6 +133,24 @@ func (a *AuthController) Register(c *gin.Context) {
 		return
 	}
 
+	if a.Local() {
+		if len(user.Password) < 8 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Password must be at least 8 characters"})
+			return
+		}
+		uid, err := a.accounts.Register(c.Request.Context(), user.Email, user.Password, user.Name)
+		if errors.Is(err, models.ErrEmailTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"user": gin.H{"uid": uid, "email": user.Email}})
+		return
+	}
+
 	// Create user in Firebase
 	params := (&auth.UserToCreate{}).
 		Email(user.Email).
@@ -95,18 +165,100 @@ func (a *AuthController) Register(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"user": newUser})
 }
 
-// RefreshToken handles token refresh
+// RefreshToken exchanges a refresh token for a new access and refresh token.
+// Firebase clients refresh their ID tokens through the Firebase SDK instead.
 func (a *AuthController) RefreshToken(c *gin.Context) {
-	// TODO: Implement token refresh
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Token refresh not implemented"})
+	if !a.Local() {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Refresh Firebase ID tokens with the Firebase SDK"})
+		return
+	}
+
+	var body struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uid, email, refreshToken, err := a.refreshTokens.Rotate(c.Request.Context(), body.RefreshToken)
+	if errors.Is(err, models.ErrInvalidRefreshToken) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+	a.respondTokens(c, uid, email, refreshToken)
 }
 
-// Logout handles user logout
+// Logout revokes the caller's tokens. Locally issued access tokens are
+// revoked along with the given refresh token; Firebase users have every
+// refresh token revoked, which invalidates their ID tokens.
 func (a *AuthController) Logout(c *gin.Context) {
-	// TODO: Implement logout
+	value, _ := c.Get("claims")
+	claims, ok := value.(*token.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !a.Local() {
+		if err := a.authClient.RevokeRefreshTokens(c.Request.Context(), claims.UID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke tokens"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+		return
+	}
+
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	c.ShouldBindJSON(&body)
+
+	if body.RefreshToken != "" {
+		err := a.refreshTokens.Revoke(c.Request.Context(), body.RefreshToken)
+		if err != nil && !errors.Is(err, models.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke tokens"})
+			return
+		}
+	}
+	if err := a.revocations.Revoke(c.Request.Context(), claims); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke tokens"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
+// JWKS publishes the public keys of locally issued tokens
+func (a *AuthController) JWKS(c *gin.Context) {
+	if !a.Local() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tokens are issued by Firebase"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": a.issuer.Keys().JWKS()})
+}
+
+// respondTokens issues an access token and returns it with a refresh token
+func (a *AuthController) respondTokens(c *gin.Context, uid, email, refreshToken string) {
+	accessToken, _, err := a.issuer.Issue(uid, email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(a.issuer.TTL().Seconds()),
+		"user":          gin.H{"uid": uid, "email": email},
+	})
+}
+
 // CheckEmail checks if an email is available
 func (a *AuthController) CheckEmail(c *gin.Context) {
 	email := c.Query("email")
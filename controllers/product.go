@@ -151,7 +151,7 @@ func (c *ProductController) ScanProduct(ctx *gin.Context) {
 	}
 
 	// Process the image
-	result, err := c.imageRecognitionService.ProcessImage(tempFile.Name())
+	result, err := c.imageRecognitionService.ProcessImage(ctx.Request.Context(), tempFile.Name())
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process image: " + err.Error()})
 		return
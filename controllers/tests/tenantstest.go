@@ -0,0 +1,359 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// tenantPaths : company scoped resources checked for cross tenant access
+var tenantPaths = []string{
+	"/suppliers",
+	"/products",
+	"/brands",
+	"/product-categories",
+	"/users",
+	"/customers",
+	"/salesmen",
+	"/shelves",
+	"/regions",
+	"/roles",
+	"/access",
+	"/branches",
+	"/purchases",
+	"/sales-orders",
+	"/receives",
+	"/deliveries",
+	"/purchase-returns",
+	"/sales-order-returns",
+	"/receive-returns",
+	"/delivery-returns",
+	"/trash",
+	"/audit",
+	"/memberships",
+	"/approvals",
+	"/approval-rules",
+	"/security/events",
+	"/stock-adjustments",
+	"/me/permissions",
+}
+
+// tenantResource : a resource created in one company and probed from another
+type tenantResource struct {
+	path   string
+	create string
+	update string
+	// code identifies records addressed by code rather than the "id" of the response
+	code string
+}
+
+var tenantResources = []tenantResource{
+	{
+		path:   "/products",
+		create: `{"code": "TENANT-PROD-1", "name": "Tenant probe", "purchase_price": 1, "sale_price": 2, "minimum_stock": 5}`,
+		update: `{"code": "TENANT-PROD-1", "name": "Changed by another company", "purchase_price": 1, "sale_price": 2, "minimum_stock": 5}`,
+		code:   "TENANT-PROD-1",
+	},
+	{
+		path:   "/customers",
+		create: `{"name": "Tenant probe", "email": "probe@example.com", "address": "Street 1", "phone": "123"}`,
+		update: `{"name": "Changed by another company", "email": "probe@example.com", "address": "Street 1", "phone": "123"}`,
+	},
+	{
+		path:   "/brands",
+		create: `{"code": "TENANT-BRAND-1", "name": "Tenant probe"}`,
+		update: `{"code": "TENANT-BRAND-1", "name": "Changed by another company"}`,
+	},
+	{
+		path:   "/purchases",
+		create: `{"code": "TENANT-PO-1", "date": "2024-01-02T00:00:00Z", "supplier_id": "tenant-supplier", "purchase_details": [{"product_id": "TENANT-PROD-1", "price": 1, "qty": 1}]}`,
+		update: `{"code": "TENANT-PO-1", "date": "2024-01-02T00:00:00Z", "supplier_id": "changed-by-another-company", "purchase_details": [{"product_id": "TENANT-PROD-1", "price": 1, "qty": 1}]}`,
+	},
+	{
+		path:   "/sales-orders",
+		create: `{"id": "TENANT-SO-1", "code": "TENANT-SO-1", "customer_id": "tenant-customer", "company_id": "{company}", "sales_order_details": [{"product_id": "TENANT-PROD-1", "quantity": 1}]}`,
+		update: `{"id": "TENANT-SO-1", "code": "TENANT-SO-1", "customer_id": "changed-by-another-company", "company_id": "{company}", "sales_order_details": [{"product_id": "TENANT-PROD-1", "quantity": 1}]}`,
+	},
+	{
+		path:   "/receives",
+		create: `{"code": "TENANT-RCV-1", "date": "2024-01-02T00:00:00Z", "receive_details": [{"product_id": "TENANT-PROD-1", "qty": 1, "shelve_id": "tenant-shelf"}]}`,
+		update: `{"code": "TENANT-RCV-1", "date": "2024-01-02T00:00:00Z", "remark": "Changed by another company", "receive_details": [{"product_id": "TENANT-PROD-1", "qty": 1, "shelve_id": "tenant-shelf"}]}`,
+	},
+	{
+		path:   "/deliveries",
+		create: `{"code": "TENANT-DLV-1", "date": "2024-01-02T00:00:00Z", "delivery_details": [{"product_id": "TENANT-PROD-1", "qty": 1, "shelve_id": "tenant-shelf"}]}`,
+		update: `{"code": "TENANT-DLV-1", "date": "2024-01-02T00:00:00Z", "remark": "Changed by another company", "delivery_details": [{"product_id": "TENANT-PROD-1", "qty": 1, "shelve_id": "tenant-shelf"}]}`,
+	},
+	{
+		path:   "/purchase-returns",
+		create: `{"code": "TENANT-PR-1", "date": "2024-01-02T00:00:00Z", "purchase_return_details": [{"product_id": "TENANT-PROD-1", "qty": 1}]}`,
+		update: `{"code": "TENANT-PR-1", "date": "2024-01-02T00:00:00Z", "remark": "Changed by another company", "purchase_return_details": [{"product_id": "TENANT-PROD-1", "qty": 1}]}`,
+	},
+	{
+		path:   "/receive-returns",
+		create: `{"code": "TENANT-RR-1", "date": "2024-01-02T00:00:00Z", "receive_return_details": [{"product_id": "TENANT-PROD-1", "qty": 1}]}`,
+		update: `{"code": "TENANT-RR-1", "date": "2024-01-02T00:00:00Z", "remark": "Changed by another company", "receive_return_details": [{"product_id": "TENANT-PROD-1", "qty": 1}]}`,
+	},
+	{
+		path:   "/delivery-returns",
+		create: `{"code": "TENANT-DR-1", "date": "2024-01-02T00:00:00Z", "delivery_return_details": [{"product_id": "TENANT-PROD-1", "qty": 1}]}`,
+		update: `{"code": "TENANT-DR-1", "date": "2024-01-02T00:00:00Z", "remark": "Changed by another company", "delivery_return_details": [{"product_id": "TENANT-PROD-1", "qty": 1}]}`,
+	},
+	{
+		path:   "/sales-order-returns",
+		create: `{"code": "TENANT-SR-1", "date": "2024-01-02T00:00:00Z", "sales_order_return_details": [{"product_id": "TENANT-PROD-1", "qty": 1}]}`,
+		update: `{"code": "Changed by another company", "date": "2024-01-02T00:00:00Z", "sales_order_return_details": [{"product_id": "TENANT-PROD-1", "qty": 1}]}`,
+	},
+	{
+		path:   "/roles",
+		create: `{"name": "Tenant probe"}`,
+		update: `{"name": "Changed by another company"}`,
+	},
+	{
+		path:   "/access",
+		create: `{"name": "Tenant probe", "resource": "products", "action": "read"}`,
+		update: `{"name": "Changed by another company", "resource": "products", "action": "read"}`,
+	},
+	{
+		path:   "/salesmen",
+		create: `{"code": "TENANT-SM-1", "name": "Tenant probe"}`,
+		update: `{"code": "TENANT-SM-1", "name": "Changed by another company"}`,
+	},
+	{
+		path:   "/product-categories",
+		create: `{"name": "Tenant probe"}`,
+		update: `{"name": "Changed by another company"}`,
+	},
+	{
+		path:   "/branches",
+		create: `{"code": "TENANT-BR-1", "name": "Tenant probe", "type": "store"}`,
+		update: `{"code": "TENANT-BR-1", "name": "Changed by another company", "type": "store"}`,
+	},
+	{
+		path:   "/suppliers",
+		create: `{"code": "TENANT-SUP-1", "name": "Tenant probe"}`,
+		update: `{"name": "Changed by another company"}`,
+	},
+	{
+		path:   "/regions",
+		create: `{"name": "Tenant probe"}`,
+		update: `{"name": "Changed by another company"}`,
+	},
+	{
+		path:   "/shelves",
+		create: `{"name": "Tenant probe"}`,
+		update: `{"name": "Changed by another company"}`,
+	},
+}
+
+// Tenants : struct for set Tenants Dependency Injection
+type Tenants struct {
+	App   http.Handler
+	Token string
+}
+
+// Run : http handler for run cross tenant access testing
+func (u *Tenants) Run(t *testing.T) {
+	for _, path := range tenantPaths {
+		u.expect(t, path, "tenant-isolation-probe", http.StatusForbidden)
+		u.expect(t, path, "", http.StatusBadRequest)
+	}
+
+	owner, other := u.companies(t)
+	for _, resource := range tenantResources {
+		t.Run(strings.TrimPrefix(resource.path, "/"), func(t *testing.T) {
+			u.isolated(t, resource, owner, other)
+		})
+	}
+	t.Run("approvals", func(t *testing.T) {
+		u.approvalsIsolated(t, owner, other)
+	})
+	t.Run("shelf-audits", func(t *testing.T) {
+		u.shelfAuditsIsolated(t, owner, other)
+	})
+}
+
+// companies returns a company the caller belongs to and a second company it
+// creates, so records of one can be probed from the other
+func (u *Tenants) companies(t *testing.T) (string, string) {
+	resp := u.do("GET", "/me/companies", "", "")
+	var memberships []map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&memberships); err != nil || len(memberships) == 0 {
+		t.Fatalf("listing companies: status %v, %v", resp.Code, err)
+	}
+	owner, _ := memberships[0]["company_id"].(string)
+
+	resp = u.do("POST", "/companies", "", `{"name": "Tenant isolation probe"}`)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("creating company: expected status code %v, got %v", http.StatusCreated, resp.Code)
+	}
+	var company map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&company); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+	other, _ := company["id"].(string)
+	t.Cleanup(func() { u.do("DELETE", "/companies/"+other, other, "") })
+
+	return owner, other
+}
+
+// isolated creates a record in owner and checks other can neither list,
+// read, update nor delete it. "{company}" in the bodies stands for the
+// company sending them.
+func (u *Tenants) isolated(t *testing.T, resource tenantResource, owner, other string) {
+	resp := u.do("POST", resource.path, owner, strings.ReplaceAll(resource.create, "{company}", owner))
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("posting %s: expected status code %v, got %v", resource.path, http.StatusCreated, resp.Code)
+	}
+	var created map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+	key := resource.code
+	if key == "" {
+		key, _ = created["id"].(string)
+	}
+	if key == "" {
+		t.Fatalf("posting %s: response has no id", resource.path)
+	}
+	path := resource.path + "/" + key
+	defer u.do("DELETE", path, owner, "")
+
+	resp = u.do("GET", resource.path, other, "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("listing %s: expected status code %v, got %v", resource.path, http.StatusOK, resp.Code)
+	}
+	if strings.Contains(resp.Body.String(), key) {
+		t.Errorf("listing %s: another company's record %s is listed", resource.path, key)
+	}
+
+	if resp := u.do("GET", path, other, ""); resp.Code < 400 {
+		t.Errorf("getting %s from another company: got status %v", path, resp.Code)
+	}
+	if resp := u.do("PUT", path, other, strings.ReplaceAll(resource.update, "{company}", other)); resp.Code < 400 {
+		t.Errorf("updating %s from another company: got status %v", path, resp.Code)
+	}
+	if resp := u.do("DELETE", path, other, ""); resp.Code < 400 {
+		t.Errorf("deleting %s from another company: got status %v", path, resp.Code)
+	}
+
+	resp = u.do("GET", path, owner, "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("getting %s: expected status code %v, got %v", path, http.StatusOK, resp.Code)
+	}
+	if strings.Contains(resp.Body.String(), "another company") {
+		t.Errorf("getting %s: record was changed by another company", path)
+	}
+}
+
+// approvalsIsolated opens an approval on a purchase of owner and checks other
+// can neither list, read nor decide it
+func (u *Tenants) approvalsIsolated(t *testing.T, owner, other string) {
+	resp := u.do("POST", "/approval-rules", owner, `{"document_type": "purchases", "metric": "amount", "threshold": 0, "levels": [{"name": "Tenant probe", "permission": "approvals:decide"}]}`)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("posting /approval-rules: expected status code %v, got %v", http.StatusCreated, resp.Code)
+	}
+	var rule map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&rule); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+	ruleID, _ := rule["id"].(string)
+	defer u.do("DELETE", "/approval-rules/"+ruleID, owner, "")
+
+	resp = u.do("POST", "/purchases", owner, `{"code": "TENANT-PO-2", "date": "2024-01-02T00:00:00Z", "supplier_id": "tenant-supplier", "purchase_details": [{"product_id": "TENANT-PROD-1", "price": 10, "qty": 1}]}`)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("posting /purchases: expected status code %v, got %v", http.StatusCreated, resp.Code)
+	}
+	var purchase map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&purchase); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+	purchaseID, _ := purchase["id"].(string)
+	defer u.do("DELETE", "/purchases/"+purchaseID, owner, "")
+
+	resp = u.do("GET", "/approvals", owner, "")
+	var approvals []map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&approvals); err != nil {
+		t.Fatalf("listing /approvals: status %v, %v", resp.Code, err)
+	}
+	var id string
+	for _, approval := range approvals {
+		if approval["document_id"] == purchaseID {
+			id, _ = approval["id"].(string)
+		}
+	}
+	if id == "" {
+		t.Fatalf("listing /approvals: no approval for purchase %s", purchaseID)
+	}
+
+	resp = u.do("GET", "/approvals", other, "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("listing /approvals: expected status code %v, got %v", http.StatusOK, resp.Code)
+	}
+	if strings.Contains(resp.Body.String(), id) {
+		t.Errorf("listing /approvals: another company's approval %s is listed", id)
+	}
+	if resp := u.do("GET", "/approvals/"+id, other, ""); resp.Code < 400 {
+		t.Errorf("getting /approvals/%s from another company: got status %v", id, resp.Code)
+	}
+	if resp := u.do("POST", "/approvals/"+id+"/approve", other, `{}`); resp.Code < 400 {
+		t.Errorf("approving /approvals/%s from another company: got status %v", id, resp.Code)
+	}
+}
+
+// shelfAuditsIsolated saves the planogram of a shelf of owner and checks
+// other can neither read nor replace it, nor list the shelf's audits
+func (u *Tenants) shelfAuditsIsolated(t *testing.T, owner, other string) {
+	resp := u.do("POST", "/shelves", owner, `{"name": "Tenant probe"}`)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("posting /shelves: expected status code %v, got %v", http.StatusCreated, resp.Code)
+	}
+	var shelve map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&shelve); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+	path, _ := shelve["id"].(string)
+	path = "/shelves/" + path
+	defer u.do("DELETE", path, owner, "")
+
+	if resp := u.do("PUT", path+"/planogram", owner, `{"slots": [{"product_code": "TENANT-PROD-1", "facings": 2}]}`); resp.Code != http.StatusOK {
+		t.Fatalf("saving %s/planogram: expected status code %v, got %v", path, http.StatusOK, resp.Code)
+	}
+
+	if resp := u.do("GET", path+"/planogram", other, ""); resp.Code < 400 {
+		t.Errorf("getting %s/planogram from another company: got status %v", path, resp.Code)
+	}
+	if resp := u.do("PUT", path+"/planogram", other, `{"slots": []}`); resp.Code < 400 {
+		t.Errorf("saving %s/planogram from another company: got status %v", path, resp.Code)
+	}
+	if resp := u.do("GET", path+"/audits", other, ""); resp.Code != http.StatusNotFound {
+		t.Errorf("listing %s/audits from another company: expected status code %v, got %v", path, http.StatusNotFound, resp.Code)
+	}
+
+	resp = u.do("GET", path+"/planogram", owner, "")
+	if resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), "TENANT-PROD-1") {
+		t.Errorf("getting %s/planogram: got status %v, want the unchanged planogram", path, resp.Code)
+	}
+}
+
+func (u *Tenants) do(method, path, companyID, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+u.Token)
+	if companyID != "" {
+		req.Header.Set("X-Company-ID", companyID)
+	}
+	resp := httptest.NewRecorder()
+
+	u.App.ServeHTTP(resp, req)
+	return resp
+}
+
+func (u *Tenants) expect(t *testing.T, path, companyID string, status int) {
+	resp := u.do("GET", path, companyID, "")
+	if resp.Code != status {
+		t.Fatalf("getting %s: expected status code %v, got %v", path, status, resp.Code)
+	}
+}
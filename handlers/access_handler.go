@@ -44,6 +44,7 @@ func (h *AccessHandler) Get(c *gin.Context) {
 		return
 	}
 
+	setETag(c)
 	c.JSON(http.StatusOK, access)
 }
 
@@ -81,10 +82,46 @@ func (h *AccessHandler) Update(c *gin.Context) {
 
 	access.ID = id
 	if err := h.accessFirebase.Update(c.Request.Context(), id, &access); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c)
+	c.JSON(http.StatusOK, access)
+}
+
+// Patch handles PATCH requests to partially update a access
+func (h *AccessHandler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Access ID is required"})
+		return
+	}
+
+	access, err := h.accessFirebase.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := applyPatch(c, access, nil); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	access.ID = id
+	if err := h.accessFirebase.Update(c.Request.Context(), id, access); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
 	c.JSON(http.StatusOK, access)
 }
 
@@ -97,6 +134,9 @@ func (h *AccessHandler) Delete(c *gin.Context) {
 	}
 
 	if err := h.accessFirebase.Delete(c.Request.Context(), id); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
+)
+
+// ApprovalHandler handles HTTP requests for approval rules and approvals
+type ApprovalHandler struct {
+	approvals *models.ApprovalFirebase
+}
+
+// NewApprovalHandler creates a new ApprovalHandler instance
+func NewApprovalHandler(approvals *models.ApprovalFirebase) *ApprovalHandler {
+	return &ApprovalHandler{
+		approvals: approvals,
+	}
+}
+
+// ListRules handles GET requests to list the approval rules of the current company
+func (h *ApprovalHandler) ListRules(c *gin.Context) {
+	rules, err := h.approvals.ListRules(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// CreateRule handles POST requests to create an approval rule
+func (h *ApprovalHandler) CreateRule(c *gin.Context) {
+	var req request.ApprovalRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkApprovalRule(c, &req) {
+		return
+	}
+
+	rule := req.Transform()
+	if _, err := h.approvals.CreateRule(c.Request.Context(), rule); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateRule handles PUT requests to change an approval rule
+func (h *ApprovalHandler) UpdateRule(c *gin.Context) {
+	var req request.ApprovalRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkApprovalRule(c, &req) {
+		return
+	}
+
+	rule := req.Transform()
+	rule.ID = c.Param("id")
+	if err := h.approvals.UpdateRule(c.Request.Context(), rule.ID, rule); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteRule handles DELETE requests to remove an approval rule
+func (h *ApprovalHandler) DeleteRule(c *gin.Context) {
+	if err := h.approvals.DeleteRule(c.Request.Context(), c.Param("id")); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Approval rule deleted successfully"})
+}
+
+// List handles GET requests to list approvals, optionally filtered by
+// ?status=. With ?mine=true only approvals the caller can decide are listed.
+func (h *ApprovalHandler) List(c *gin.Context) {
+	state := c.Query("status")
+	mine := c.Query("mine") == "true"
+	if mine {
+		state = models.ApprovalPending
+	}
+
+	approvals, err := h.approvals.List(c.Request.Context(), state)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if mine {
+		grant := callerGrant(c)
+		decidable := []*models.FirebaseApproval{}
+		for _, approval := range approvals {
+			if approval.CanDecide(c.GetString("userID"), grant) {
+				decidable = append(decidable, approval)
+			}
+		}
+		approvals = decidable
+	}
+
+	c.JSON(http.StatusOK, approvals)
+}
+
+// Get handles GET requests for a single approval
+func (h *ApprovalHandler) Get(c *gin.Context) {
+	approval, err := h.approvals.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
+	c.JSON(http.StatusOK, approval)
+}
+
+// Approve handles POST requests to approve the current level of an approval
+func (h *ApprovalHandler) Approve(c *gin.Context) {
+	h.decide(c, true)
+}
+
+// Reject handles POST requests to reject an approval
+func (h *ApprovalHandler) Reject(c *gin.Context) {
+	h.decide(c, false)
+}
+
+func (h *ApprovalHandler) decide(c *gin.Context, approve bool) {
+	var req request.ApprovalDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !approve && strings.TrimSpace(req.Comment) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A comment is required when rejecting"})
+		return
+	}
+
+	approval, err := h.approvals.Decide(c.Request.Context(), c.Param("id"), callerGrant(c), approve, req.Comment)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrApprovalForbidden):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, models.ErrApprovalClosed), errors.Is(err, models.ErrInsufficientStock):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			if writeModelError(c, err) {
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	setETag(c)
+	c.JSON(http.StatusOK, approval)
+}
+
+// callerGrant returns the grant loaded by the RBAC middleware
+func callerGrant(c *gin.Context) *models.Grant {
+	if value, ok := c.Get("grant"); ok {
+		return value.(*models.Grant)
+	}
+	return nil
+}
+
+// checkApprovalRule responds 422 unless the rule's metric suits its document
+// type and every level names a resource:action permission
+func checkApprovalRule(c *gin.Context, req *request.ApprovalRuleRequest) bool {
+	supported := false
+	for _, metric := range models.ApprovalMetrics[req.DocumentType] {
+		if metric == req.Metric {
+			supported = true
+		}
+	}
+	if !supported {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Metric " + req.Metric + " is not supported for " + req.DocumentType})
+		return false
+	}
+
+	for _, level := range req.Levels {
+		parts := strings.Split(level.Permission, ":")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid permission " + level.Permission})
+			return false
+		}
+	}
+	return true
+}
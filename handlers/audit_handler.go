@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/models"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// AuditHandler handles HTTP requests for the audit log
+type AuditHandler struct {
+	auditLog *models.AuditLog
+}
+
+// NewAuditHandler creates a new AuditHandler instance
+func NewAuditHandler(auditLog *models.AuditLog) *AuditHandler {
+	return &AuditHandler{
+		auditLog: auditLog,
+	}
+}
+
+// List handles GET requests to search the audit log of the current company
+func (h *AuditHandler) List(c *gin.Context) {
+	filter, err := auditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.Entity = c.Query("entity")
+	filter.EntityID = c.Query("id")
+	filter.UserID = c.Query("user")
+
+	h.respond(c, filter)
+}
+
+// History handles GET requests to list the changes of a single document
+func (h *AuditHandler) History(c *gin.Context) {
+	filter, err := auditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.Entity = c.Param("entity")
+	filter.EntityID = c.Param("id")
+
+	h.respond(c, filter)
+}
+
+func (h *AuditHandler) respond(c *gin.Context, filter models.AuditFilter) {
+	events, err := h.auditLog.List(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+// auditFilter reads the company, time range and limit shared by audit queries
+func auditFilter(c *gin.Context) (models.AuditFilter, error) {
+	filter := models.AuditFilter{
+		CompanyID: c.GetString("company_id"),
+		Limit:     defaultAuditLimit,
+	}
+
+	var err error
+	if filter.From, err = parseAuditTime("from", c.Query("from"), false); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseAuditTime("to", c.Query("to"), true); err != nil {
+		return filter, err
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit <= 0 {
+			return filter, fmt.Errorf("invalid limit %q", limit)
+		}
+		if filter.Limit > maxAuditLimit {
+			filter.Limit = maxAuditLimit
+		}
+	}
+
+	return filter, nil
+}
+
+// parseAuditTime accepts RFC3339 timestamps or plain dates; a plain "to" date
+// includes the whole day
+func parseAuditTime(name, value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q, expected RFC3339 or YYYY-MM-DD", name, value)
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+	"github.com/nirshpaa/godam-backend/models"
+)
+
+func TestAuditHistory(t *testing.T) {
+	client := firestoretest.New(t)
+	brands := models.NewFirebaseModel("brands", client)
+	ctx := models.WithActor(models.WithCompany(context.Background(), "company-a"), "user-a")
+	id, err := brands.Create(ctx, map[string]interface{}{"Name": "Acme"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := brands.Update(ctx, id, map[string]interface{}{"Name": "Acme Foods"}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	handler := NewAuditHandler(models.NewAuditLog(client))
+	route := func(companyID string) *gin.Engine {
+		router := newTestRouter(companyID, "user-a")
+		router.GET("/audit", handler.List)
+		router.GET("/audit/:entity/:id", handler.History)
+		return router
+	}
+
+	w := serve(t, route("company-a"), http.MethodGet, "/audit/brands/"+id, nil)
+	var events []models.AuditEvent
+	if err := json.Unmarshal(w.Body.Bytes(), &events); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("GET history = %d %s", w.Code, w.Body)
+	}
+	if len(events) != 2 || events[0].Action != models.AuditUpdate || events[1].Action != models.AuditCreate {
+		t.Fatalf("history = %+v, want update then create", events)
+	}
+
+	w = serve(t, route("company-b"), http.MethodGet, "/audit/brands/"+id, nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &events); err != nil || len(events) != 0 {
+		t.Errorf("another company's history = %d %s, want no events", w.Code, w.Body)
+	}
+
+	w = serve(t, route("company-a"), http.MethodGet, "/audit?user=user-a&limit=1", nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &events); err != nil || len(events) != 1 {
+		t.Errorf("GET /audit limit=1 = %d %s, want one event", w.Code, w.Body)
+	}
+
+	for _, query := range []string{"from=yesterday", "to=2024-13-01", "limit=0", "limit=x"} {
+		if w := serve(t, route("company-a"), http.MethodGet, "/audit?"+query, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("GET /audit?%s = %d, want 400", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/services"
+)
+
+// maxBatchUpload limits the request body of a batch scan, and the
+// uncompressed size of the images in a zip
+const maxBatchUpload = 200 << 20
+
+// errBatchTooLarge is returned for batches over the image count or size limits
+var errBatchTooLarge = fmt.Errorf("a batch holds at most %d images and %d MB", services.MaxBatchImages, maxBatchUpload>>20)
+
+// BatchScanHandler handles batch scans of many product images
+type BatchScanHandler struct {
+	service *services.BatchScanService
+	charge  func(c *gin.Context, n int) bool
+}
+
+// NewBatchScanHandler creates a new BatchScanHandler instance. charge takes
+// one rate limit token per image and responds when they are not available;
+// nil leaves batches unlimited.
+func NewBatchScanHandler(service *services.BatchScanService, charge func(c *gin.Context, n int) bool) *BatchScanHandler {
+	return &BatchScanHandler{service: service, charge: charge}
+}
+
+// Scan handles POST /products/scan/batch. Images are sent as multipart
+// "images" files, as "archive" zip files, or as a zip request body. Results
+// stream as NDJSON, or as server-sent events when the client accepts
+// text/event-stream or passes format=sse. With receive=true a draft receive
+// of the matched products is created once all images are done.
+func (h *BatchScanHandler) Scan(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBatchUpload)
+	images, err := readBatchImages(c)
+	if err != nil {
+		status := http.StatusBadRequest
+		var maxBytes *http.MaxBytesError
+		if errors.Is(err, errBatchTooLarge) || errors.As(err, &maxBytes) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if len(images) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No images provided"})
+		return
+	}
+
+	createReceive, _ := strconv.ParseBool(batchParam(c, "receive"))
+	if createReceive && !grantAllows(c, "receives:create") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Missing permission receives:create"})
+		return
+	}
+	if h.charge != nil && !h.charge(c, len(images)) {
+		return
+	}
+
+	batchID := uuid.New().String()
+	stream := newEventStream(c)
+	stream.send("start", gin.H{"batch_id": batchID, "images": len(images)})
+
+	results := make(chan services.BatchScanResult)
+	go h.service.Scan(c.Request.Context(), batchID, c.GetString("userID"), images, results)
+
+	var finished []services.BatchScanResult
+	matched, failed := 0, 0
+	for result := range results {
+		finished = append(finished, result)
+		if result.Matched {
+			matched++
+		}
+		if result.Error != "" {
+			failed++
+		}
+		stream.send("result", result)
+	}
+
+	summary := gin.H{
+		"batch_id": batchID,
+		"images":   len(images),
+		"scanned":  len(finished),
+		"matched":  matched,
+		"failed":   failed,
+	}
+	if createReceive && c.Request.Context().Err() == nil {
+		receive, err := h.service.DraftReceive(c.Request.Context(), batchID, batchParam(c, "purchase_id"), batchParam(c, "shelve_id"), finished)
+		if err != nil {
+			summary["receive_error"] = err.Error()
+		} else {
+			summary["receive"] = receive
+		}
+	}
+	stream.send("done", summary)
+}
+
+// batchParam reads an option from the query or, for multipart requests, the form
+func batchParam(c *gin.Context, key string) string {
+	if value := c.Query(key); value != "" {
+		return value
+	}
+	return c.PostForm(key)
+}
+
+// grantAllows reports whether the grant loaded by the RBAC middleware holds permission
+func grantAllows(c *gin.Context, permission string) bool {
+	grant, ok := c.Get("grant")
+	if !ok {
+		return false
+	}
+	g, ok := grant.(*models.Grant)
+	return ok && g.Allows(permission)
+}
+
+// readBatchImages collects the images of a batch from a multipart form or a
+// zip request body
+func readBatchImages(c *gin.Context) ([]services.BatchImage, error) {
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		form, err := c.MultipartForm()
+		if err != nil {
+			return nil, err
+		}
+		batch := &batchReader{}
+		for _, file := range form.File["images"] {
+			if err := batch.addFile(file); err != nil {
+				return nil, err
+			}
+		}
+		for _, file := range form.File["archive"] {
+			src, err := file.Open()
+			if err != nil {
+				return nil, err
+			}
+			err = batch.addZip(src, file.Size)
+			src.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return batch.images, nil
+	}
+
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	batch := &batchReader{}
+	if err := batch.addZip(bytes.NewReader(data), int64(len(data))); err != nil {
+		return nil, err
+	}
+	return batch.images, nil
+}
+
+// batchReader gathers images while enforcing the batch limits
+type batchReader struct {
+	images []services.BatchImage
+	size   int64
+}
+
+func (b *batchReader) add(name string, r io.Reader) error {
+	if len(b.images) >= services.MaxBatchImages {
+		return errBatchTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxImageUpload+1))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", name, err)
+	}
+	if len(data) > maxImageUpload {
+		return fmt.Errorf("%s is larger than %d MB", name, maxImageUpload>>20)
+	}
+	b.size += int64(len(data))
+	if b.size > maxBatchUpload {
+		return errBatchTooLarge
+	}
+	b.images = append(b.images, services.BatchImage{Name: name, Data: data})
+	return nil
+}
+
+func (b *batchReader) addFile(file *multipart.FileHeader) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return b.add(file.Filename, src)
+}
+
+// addZip adds the files of a zip, skipping directories and hidden files such
+// as the resource forks macOS adds
+func (b *batchReader) addZip(r io.ReaderAt, size int64) error {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("invalid zip archive: %v", err)
+	}
+	for _, entry := range archive.File {
+		name := entry.Name
+		if entry.FileInfo().IsDir() || strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), ".") {
+			continue
+		}
+		src, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", name, err)
+		}
+		err = b.add(name, src)
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eventStream writes events as NDJSON lines or server-sent events, flushing
+// each one so clients see results as they finish
+type eventStream struct {
+	c      *gin.Context
+	sse    bool
+	failed bool
+}
+
+func newEventStream(c *gin.Context) *eventStream {
+	sse := c.Query("format") == "sse" || strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+	if sse {
+		c.Header("Content-Type", "text/event-stream")
+	} else {
+		c.Header("Content-Type", "application/x-ndjson")
+	}
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	return &eventStream{c: c, sse: sse}
+}
+
+// send writes an event. Once the client is gone events are dropped, so the
+// caller can keep draining its results.
+func (s *eventStream) send(event string, data interface{}) {
+	if s.failed {
+		return
+	}
+
+	var err error
+	if s.sse {
+		var payload []byte
+		if payload, err = json.Marshal(data); err == nil {
+			_, err = fmt.Fprintf(s.c.Writer, "event: %s\ndata: %s\n\n", event, payload)
+		}
+	} else {
+		var line []byte
+		if line, err = json.Marshal(gin.H{"event": event, "data": data}); err == nil {
+			_, err = s.c.Writer.Write(append(line, '\n'))
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to stream %s event: %v", event, err)
+		s.failed = true
+		return
+	}
+	s.c.Writer.Flush()
+}
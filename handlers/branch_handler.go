@@ -44,6 +44,7 @@ func (h *BranchHandler) Get(c *gin.Context) {
 		return
 	}
 
+	setETag(c)
 	c.JSON(http.StatusOK, branch)
 }
 
@@ -81,10 +82,46 @@ func (h *BranchHandler) Update(c *gin.Context) {
 
 	branch.ID = id
 	if err := h.branchFirebase.Update(c.Request.Context(), id, &branch); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c)
+	c.JSON(http.StatusOK, branch)
+}
+
+// Patch handles PATCH requests to partially update a branch
+func (h *BranchHandler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Branch ID is required"})
+		return
+	}
+
+	branch, err := h.branchFirebase.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := applyPatch(c, branch, nil); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	branch.ID = id
+	if err := h.branchFirebase.Update(c.Request.Context(), id, branch); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
 	c.JSON(http.StatusOK, branch)
 }
 
@@ -97,6 +134,9 @@ func (h *BranchHandler) Delete(c *gin.Context) {
 	}
 
 	if err := h.branchFirebase.Delete(c.Request.Context(), id); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
 	"github.com/nirshpaa/godam-backend/payloads/response"
 )
 
@@ -52,6 +53,7 @@ func (h *BrandHandler) Get(c *gin.Context) {
 
 	var res response.BrandResponse
 	res.Transform(brand)
+	setETag(c)
 	c.JSON(http.StatusOK, res)
 }
 
@@ -92,6 +94,9 @@ func (h *BrandHandler) Update(c *gin.Context) {
 	}
 
 	if err := h.model.Update(c.Request.Context(), brand); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		log.Printf("Error updating brand: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update brand"})
 		return
@@ -99,6 +104,38 @@ func (h *BrandHandler) Update(c *gin.Context) {
 
 	var res response.BrandResponse
 	res.Transform(brand)
+	setETag(c)
+	c.JSON(http.StatusOK, res)
+}
+
+// Patch partially updates an existing brand
+func (h *BrandHandler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	brand, err := h.model.Get(c.Request.Context(), id)
+	if err != nil {
+		log.Printf("Error getting brand: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get brand"})
+		return
+	}
+
+	if status, err := applyPatch(c, brand, &request.NewBrandRequest{}); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	brand.ID = id
+	if err := h.model.Update(c.Request.Context(), brand); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		log.Printf("Error updating brand: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update brand"})
+		return
+	}
+
+	var res response.BrandResponse
+	res.Transform(brand)
+	setETag(c)
 	c.JSON(http.StatusOK, res)
 }
 
@@ -106,6 +143,9 @@ func (h *BrandHandler) Update(c *gin.Context) {
 func (h *BrandHandler) Delete(c *gin.Context) {
 	id := c.Param("id")
 	if err := h.model.Delete(c.Request.Context(), id); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		log.Printf("Error deleting brand: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete brand"})
 		return
@@ -5,17 +5,20 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
 )
 
 // CompanyHandler handles HTTP requests for companies
 type CompanyHandler struct {
 	companyFirebase *models.CompanyFirebase
+	memberships     *models.MembershipFirebase
 }
 
 // NewCompanyHandler creates a new CompanyHandler instance
-func NewCompanyHandler(companyFirebase *models.CompanyFirebase) *CompanyHandler {
+func NewCompanyHandler(companyFirebase *models.CompanyFirebase, memberships *models.MembershipFirebase) *CompanyHandler {
 	return &CompanyHandler{
 		companyFirebase: companyFirebase,
+		memberships:     memberships,
 	}
 }
 
@@ -44,6 +47,7 @@ func (h *CompanyHandler) Get(c *gin.Context) {
 		return
 	}
 
+	setETag(c)
 	c.JSON(http.StatusOK, company)
 }
 
@@ -61,6 +65,16 @@ func (h *CompanyHandler) Create(c *gin.Context) {
 		return
 	}
 
+	// The creator becomes the first member and owner of the company
+	if err := models.SeedDefaultRoles(c.Request.Context(), h.companyFirebase.Client, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := h.memberships.Add(c.Request.Context(), id, c.GetString("userID"), models.RoleOwner); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	company.ID = id
 	c.JSON(http.StatusCreated, company)
 }
@@ -81,10 +95,46 @@ func (h *CompanyHandler) Update(c *gin.Context) {
 
 	company.ID = id
 	if err := h.companyFirebase.Update(c.Request.Context(), id, &company); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
+	c.JSON(http.StatusOK, company)
+}
+
+// Patch handles PATCH requests to partially update a company
+func (h *CompanyHandler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Company ID is required"})
+		return
+	}
+
+	company, err := h.companyFirebase.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := applyPatch(c, company, &request.NewCompanyRequest{}); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	company.ID = id
+	if err := h.companyFirebase.Update(c.Request.Context(), id, company); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c)
 	c.JSON(http.StatusOK, company)
 }
 
@@ -97,6 +147,9 @@ func (h *CompanyHandler) Delete(c *gin.Context) {
 	}
 
 	if err := h.companyFirebase.Delete(c.Request.Context(), id); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// companyParam returns the :companyId route parameter after checking that it
+// matches the company the request is scoped to, and reports whether it did
+func companyParam(c *gin.Context) (string, bool) {
+	companyID := c.Param("companyId")
+	if companyID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Company ID is required"})
+		return "", false
+	}
+	if companyID != c.GetString("company_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this company"})
+		return "", false
+	}
+	return companyID, true
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
 )
 
 // CustomerHandler handles HTTP requests for customers
@@ -44,6 +45,7 @@ func (h *CustomerHandler) Get(c *gin.Context) {
 		return
 	}
 
+	setETag(c)
 	c.JSON(http.StatusOK, customer)
 }
 
@@ -81,10 +83,46 @@ func (h *CustomerHandler) Update(c *gin.Context) {
 
 	customer.ID = id
 	if err := h.customerFirebase.Update(c.Request.Context(), id, &customer); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c)
+	c.JSON(http.StatusOK, customer)
+}
+
+// Patch handles PATCH requests to partially update a customer
+func (h *CustomerHandler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Customer ID is required"})
+		return
+	}
+
+	customer, err := h.customerFirebase.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := applyPatch(c, customer, &request.NewCustomerRequest{}); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	customer.ID = id
+	if err := h.customerFirebase.Update(c.Request.Context(), id, customer); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
 	c.JSON(http.StatusOK, customer)
 }
 
@@ -97,6 +135,9 @@ func (h *CustomerHandler) Delete(c *gin.Context) {
 	}
 
 	if err := h.customerFirebase.Delete(c.Request.Context(), id); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/libraries/dataset"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
+	"github.com/nirshpaa/godam-backend/services"
+)
+
+// maxDatasetUpload limits the size of a training image upload
+const maxDatasetUpload = 20 << 20
+
+// DatasetHandler handles HTTP requests for the training image dataset and its snapshots
+type DatasetHandler struct {
+	dataset *models.DatasetFirebase
+	service *services.DatasetService
+}
+
+// NewDatasetHandler creates a new DatasetHandler instance
+func NewDatasetHandler(dataset *models.DatasetFirebase, service *services.DatasetService) *DatasetHandler {
+	return &DatasetHandler{
+		dataset: dataset,
+		service: service,
+	}
+}
+
+// ListImages handles GET requests to list dataset images by ?product_code=,
+// ?source=, ?split= and ?include_excluded=true
+func (h *DatasetHandler) ListImages(c *gin.Context) {
+	filter := models.DatasetFilter{
+		ProductCode:     c.Query("product_code"),
+		Source:          c.Query("source"),
+		Split:           c.Query("split"),
+		IncludeExcluded: c.Query("include_excluded") == "true",
+	}
+	if filter.Split != "" && filter.Split != dataset.SplitTrain && filter.Split != dataset.SplitVal {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid split %q", filter.Split)})
+		return
+	}
+
+	images, err := h.dataset.ListImages(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, images)
+}
+
+// GetImage handles GET requests to fetch a dataset image by ID
+func (h *DatasetHandler) GetImage(c *gin.Context) {
+	image, err := h.dataset.GetImage(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
+	c.JSON(http.StatusOK, image)
+}
+
+// UploadImage handles multipart POST requests adding an image of the product
+// in product_code to the dataset, with optional labels
+func (h *DatasetHandler) UploadImage(c *gin.Context) {
+	productCode := c.PostForm("product_code")
+	if productCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "product_code is required"})
+		return
+	}
+	file, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No image file provided"})
+		return
+	}
+	if file.Size > maxDatasetUpload {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image is too large"})
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer src.Close()
+	data, err := io.ReadAll(io.LimitReader(src, maxDatasetUpload))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var labels []string
+	for _, label := range c.PostFormArray("labels") {
+		for _, part := range strings.Split(label, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				labels = append(labels, part)
+			}
+		}
+	}
+
+	image, err := h.service.AddImage(c.Request.Context(), productCode, models.DatasetSourceUpload, "", labels, data)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidImage) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, image)
+}
+
+// UpdateImage handles PUT requests to change the labels, quality flags,
+// split or exclusion of a dataset image
+func (h *DatasetHandler) UpdateImage(c *gin.Context) {
+	var req request.DatasetImageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	if err := h.dataset.UpdateImage(ctx, id, req.Transform()); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	image, err := h.dataset.GetImage(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
+	c.JSON(http.StatusOK, image)
+}
+
+// ListSnapshots handles GET requests to list the dataset snapshots, newest first
+func (h *DatasetHandler) ListSnapshots(c *gin.Context) {
+	snapshots, err := h.dataset.ListSnapshots(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, snapshots)
+}
+
+// CreateSnapshot handles POST requests to freeze the current dataset into a new version
+func (h *DatasetHandler) CreateSnapshot(c *gin.Context) {
+	var req request.DatasetSnapshotRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	snapshot, err := h.service.CreateSnapshot(c.Request.Context(), req.Note)
+	if err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, snapshot)
+}
+
+// GetSnapshot handles GET requests to fetch a snapshot with its manifest
+func (h *DatasetHandler) GetSnapshot(c *gin.Context) {
+	snapshot, manifest, err := h.service.Manifest(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"snapshot": snapshot,
+		"manifest": manifest,
+	})
+}
+
+// ExportSnapshot handles GET requests to download a snapshot as an
+// ImageFolder style zip with its manifest
+func (h *DatasetHandler) ExportSnapshot(c *gin.Context) {
+	snapshot, manifest, err := h.service.Manifest(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="dataset-v%d.zip"`, snapshot.Version))
+	c.Status(http.StatusOK)
+	if err := h.service.Export(c.Request.Context(), c.Writer, manifest); err != nil {
+		// The status is already sent; the archive stays truncated, which
+		// unzip tools reject
+		log.Printf("Failed to export dataset snapshot %s: %v", snapshot.ID, err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
 )
 
 type DeliveryHandler struct {
@@ -38,6 +39,7 @@ func (h *DeliveryHandler) Get(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
+	setETag(c)
 	c.JSON(http.StatusOK, delivery)
 }
 
@@ -50,6 +52,9 @@ func (h *DeliveryHandler) Create(c *gin.Context) {
 
 	id, err := h.deliveryFirebase.Create(c.Request.Context(), &delivery)
 	if err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -73,10 +78,46 @@ func (h *DeliveryHandler) Update(c *gin.Context) {
 
 	delivery.ID = id
 	if err := h.deliveryFirebase.Update(c.Request.Context(), id, &delivery); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c)
+	c.JSON(http.StatusOK, delivery)
+}
+
+// Patch handles PATCH requests to partially update a delivery
+func (h *DeliveryHandler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Delivery ID is required"})
+		return
+	}
+
+	delivery, err := h.deliveryFirebase.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := applyPatch(c, delivery, &request.NewDeliveryRequest{}); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	delivery.ID = id
+	if err := h.deliveryFirebase.Update(c.Request.Context(), id, delivery); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
 	c.JSON(http.StatusOK, delivery)
 }
 
@@ -88,6 +129,9 @@ func (h *DeliveryHandler) Delete(c *gin.Context) {
 	}
 
 	if err := h.deliveryFirebase.Delete(c.Request.Context(), id); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
 )
 
 type DeliveryReturnHandler struct {
@@ -38,6 +39,7 @@ func (h *DeliveryReturnHandler) Get(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
+	setETag(c)
 	c.JSON(http.StatusOK, deliveryReturn)
 }
 
@@ -50,6 +52,9 @@ func (h *DeliveryReturnHandler) Create(c *gin.Context) {
 
 	id, err := h.deliveryReturnFirebase.Create(c.Request.Context(), &deliveryReturn)
 	if err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -73,10 +78,46 @@ func (h *DeliveryReturnHandler) Update(c *gin.Context) {
 
 	deliveryReturn.ID = id
 	if err := h.deliveryReturnFirebase.Update(c.Request.Context(), id, &deliveryReturn); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c)
+	c.JSON(http.StatusOK, deliveryReturn)
+}
+
+// Patch handles PATCH requests to partially update a delivery return
+func (h *DeliveryReturnHandler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Delivery Return ID is required"})
+		return
+	}
+
+	deliveryReturn, err := h.deliveryReturnFirebase.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := applyPatch(c, deliveryReturn, &request.NewDeliveryReturnRequest{}); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	deliveryReturn.ID = id
+	if err := h.deliveryReturnFirebase.Update(c.Request.Context(), id, deliveryReturn); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
 	c.JSON(http.StatusOK, deliveryReturn)
 }
 
@@ -88,6 +129,9 @@ func (h *DeliveryReturnHandler) Delete(c *gin.Context) {
 	}
 
 	if err := h.deliveryReturnFirebase.Delete(c.Request.Context(), id); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/models"
+)
+
+// setETag copies the document version recorded by the model layer into the ETag header
+func setETag(c *gin.Context) {
+	if version := models.RecordedVersion(c.Request.Context()); version != "" {
+		c.Header("ETag", version)
+	}
+}
+
+// writeModelError responds to errors raised by the model layer's write checks
+// and reports whether it did so: 412 for a stale If-Match header, 404 for
+// records that are trashed or belong to another company, 403 for writes
+// that would move a record to another company or a branch the caller may not
+// use and 409 for posting documents that still await approval
+func writeModelError(c *gin.Context, err error) bool {
+	var mismatch *models.VersionMismatchError
+	switch {
+	case errors.As(err, &mismatch):
+		c.Header("ETag", mismatch.Current)
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error":           "Resource has been modified by another request",
+			"current_version": mismatch.Current,
+		})
+	case errors.Is(err, models.ErrNotFound), errors.Is(err, models.ErrTrashed):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrCompanyMismatch), errors.Is(err, models.ErrBranchForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrApprovalRequired):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrUnknownBranch):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		return false
+	}
+	return true
+}
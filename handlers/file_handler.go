@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/libraries/objectstore"
+)
+
+// fileURLExpiry is how long the signed URLs files are redirected to stay valid
+const fileURLExpiry = 15 * time.Minute
+
+// FileHandler serves stored files under stable URLs whatever backend holds them
+type FileHandler struct {
+	store    objectstore.Store
+	redirect bool
+}
+
+// NewFileHandler creates a new FileHandler. With redirect, requests are sent
+// to a signed URL of the backend instead of being streamed through the API.
+func NewFileHandler(store objectstore.Store, redirect bool) *FileHandler {
+	return &FileHandler{store: store, redirect: redirect}
+}
+
+// Serve handles GET requests for a stored file
+func (h *FileHandler) Serve(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if h.redirect {
+		url, err := h.store.SignedURL(c.Request.Context(), key, fileURLExpiry)
+		if err != nil {
+			writeFileError(c, err)
+			return
+		}
+		c.Redirect(http.StatusFound, url)
+		return
+	}
+
+	object, err := h.store.Stat(c.Request.Context(), key)
+	if err != nil {
+		writeFileError(c, err)
+		return
+	}
+	r, err := h.store.Get(c.Request.Context(), key)
+	if err != nil {
+		writeFileError(c, err)
+		return
+	}
+	defer r.Close()
+
+	contentType := object.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Header("Last-Modified", object.Updated.UTC().Format(http.TimeFormat))
+	c.DataFromReader(http.StatusOK, object.Size, contentType, r, nil)
+}
+
+func writeFileError(c *gin.Context, err error) {
+	if errors.Is(err, objectstore.ErrNotFound) || errors.Is(err, objectstore.ErrInvalidKey) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/middleware"
+	"github.com/nirshpaa/godam-backend/models"
+)
+
+// newTestRouter returns a router that serves requests as actor of companyID,
+// with the same versioning middleware as the server
+func newTestRouter(companyID, actor string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		ctx := models.WithActor(models.WithCompany(c.Request.Context(), companyID), actor)
+		c.Request = c.Request.WithContext(ctx)
+		c.Set("company_id", companyID)
+		c.Set("userID", actor)
+		c.Next()
+	})
+	router.Use(middleware.Versioning())
+	return router
+}
+
+// serve sends a request with an optional JSON body and headers given as
+// name, value pairs
+func serve(t *testing.T, router http.Handler, method, path string, body interface{}, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("encoding request: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
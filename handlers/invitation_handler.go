@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/interfaces"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
+)
+
+// invitationTTL is how long an invitation can be accepted
+const invitationTTL = 7 * 24 * time.Hour
+
+// InvitationHandler handles HTTP requests for company invitations
+type InvitationHandler struct {
+	invitations *models.InvitationFirebase
+	companies   *models.CompanyFirebase
+	permissions *models.Permissions
+	mailer      interfaces.MailSender
+}
+
+// NewInvitationHandler creates a new InvitationHandler instance
+func NewInvitationHandler(invitations *models.InvitationFirebase, companies *models.CompanyFirebase, permissions *models.Permissions, mailer interfaces.MailSender) *InvitationHandler {
+	return &InvitationHandler{
+		invitations: invitations,
+		companies:   companies,
+		permissions: permissions,
+		mailer:      mailer,
+	}
+}
+
+// List handles GET requests to list the open invitations of the current company
+func (h *InvitationHandler) List(c *gin.Context) {
+	invitations, err := h.invitations.ListPending(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// Create handles POST requests to invite someone to the current company and
+// emails them the invitation token
+func (h *InvitationHandler) Create(c *gin.Context) {
+	var req request.NewInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkRole(c, h.permissions, req.Role) {
+		return
+	}
+
+	company, err := h.companies.Get(c.Request.Context(), c.GetString("company_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	invitation := req.Transform()
+	token, err := h.invitations.Create(c.Request.Context(), invitation, invitationTTL)
+	if err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	mail := interfaces.Mail{
+		To:      invitation.Email,
+		Subject: fmt.Sprintf("You have been invited to join %s", company.Name),
+		Body: fmt.Sprintf("You have been invited to join %s as %s.\n\nAccept the invitation: %s\n\nThe invitation expires on %s.\n",
+			company.Name, invitation.Role, invitationLink(token), invitation.ExpiresAt.Format(time.RFC1123)),
+	}
+	emailSent := true
+	if err := h.mailer.Send(c.Request.Context(), mail); err != nil {
+		log.Printf("Error sending invitation email: %v", err)
+		emailSent = false
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"invitation": invitation,
+		"email_sent": emailSent,
+	})
+}
+
+// Revoke handles DELETE requests to withdraw an open invitation
+func (h *InvitationHandler) Revoke(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invitation ID is required"})
+		return
+	}
+
+	if err := h.invitations.Revoke(c.Request.Context(), id); err != nil {
+		if writeInvitationError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked successfully"})
+}
+
+// Accept handles POST requests from the invitee to accept an invitation
+func (h *InvitationHandler) Accept(c *gin.Context) {
+	var req request.InvitationTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	membership, err := h.invitations.Accept(c.Request.Context(), req.Token, c.GetString("userID"), c.GetString("email"))
+	if err != nil {
+		if writeInvitationError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, membership)
+}
+
+// Decline handles POST requests from the invitee to decline an invitation
+func (h *InvitationHandler) Decline(c *gin.Context) {
+	var req request.InvitationTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.invitations.Decline(c.Request.Context(), req.Token, c.GetString("email")); err != nil {
+		if writeInvitationError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation declined"})
+}
+
+// writeInvitationError responds to invitation errors and reports whether it did so
+func writeInvitationError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, models.ErrInvitationInvalid), errors.Is(err, models.ErrInvitationExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrInvitationEmail):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrMembershipExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		return writeModelError(c, err)
+	}
+	return true
+}
+
+// invitationLink builds the link to the invitation page of the frontend set in APP_URL
+func invitationLink(token string) string {
+	appURL := os.Getenv("APP_URL")
+	if appURL == "" {
+		appURL = "http://localhost:3000"
+	}
+	return appURL + "/invitations/accept?token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/interfaces"
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+	"github.com/nirshpaa/godam-backend/models"
+)
+
+// recordingMailer keeps the mails it is asked to send
+type recordingMailer struct {
+	sent []interfaces.Mail
+}
+
+func (m *recordingMailer) Send(ctx context.Context, mail interfaces.Mail) error {
+	m.sent = append(m.sent, mail)
+	return nil
+}
+
+func TestInvitationCreateRole(t *testing.T) {
+	tests := []struct {
+		caller, role string
+		want         int
+	}{
+		{models.RoleOwner, models.RoleManager, http.StatusCreated},
+		{models.RoleOwner, "auditor", http.StatusUnprocessableEntity},
+		{models.RoleManager, models.RoleClerk, http.StatusCreated},
+		{models.RoleManager, models.RoleOwner, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		client := firestoretest.New(t)
+		invitations := models.NewInvitationFirebase(client)
+		mailer := &recordingMailer{}
+		handler := NewInvitationHandler(invitations, models.NewCompanyFirebase(client), models.NewPermissions(client), mailer)
+
+		router := newTestRouter("company-a", "caller-1")
+		grant := &models.Grant{Role: tt.caller, Permissions: models.DefaultRoles[tt.caller]}
+		router.Use(func(c *gin.Context) {
+			c.Set("grant", grant)
+			c.Next()
+		})
+		router.POST("/invitations", handler.Create)
+
+		ctx := models.WithCompany(context.Background(), "company-a")
+		if _, err := client.Collection("companies").Doc("company-a").Set(ctx, map[string]interface{}{"name": "A", "company_id": "company-a"}); err != nil {
+			t.Fatalf("seeding company: %v", err)
+		}
+
+		w := serve(t, router, http.MethodPost, "/invitations", gin.H{"email": "new@example.com", "role": tt.role})
+		if w.Code != tt.want {
+			t.Errorf("%s inviting as %s: status = %d, want %d: %s", tt.caller, tt.role, w.Code, tt.want, w.Body)
+			continue
+		}
+
+		pending, err := invitations.ListPending(ctx)
+		if err != nil {
+			t.Fatalf("ListPending: %v", err)
+		}
+		sent := 0
+		if tt.want == http.StatusCreated {
+			sent = 1
+		}
+		if len(pending) != sent || len(mailer.sent) != sent {
+			t.Errorf("%s inviting as %s: %d invitations stored and %d mailed, want %d", tt.caller, tt.role, len(pending), len(mailer.sent), sent)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/libraries/barcode"
+	"github.com/nirshpaa/godam-backend/libraries/label"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
+)
+
+// shelfCodePrefix starts the content of shelf QR codes so scanners can tell
+// locations from products
+const shelfCodePrefix = "godam:shelf:"
+
+// LabelHandler handles HTTP requests for barcodes and printable labels
+type LabelHandler struct {
+	barcodes *models.BarcodeFirebase
+	products *models.ProductFirebase
+	shelves  *models.ShelveFirebase
+}
+
+// NewLabelHandler creates a new LabelHandler instance
+func NewLabelHandler(barcodes *models.BarcodeFirebase, products *models.ProductFirebase, shelves *models.ShelveFirebase) *LabelHandler {
+	return &LabelHandler{
+		barcodes: barcodes,
+		products: products,
+		shelves:  shelves,
+	}
+}
+
+// AssignBarcodes handles POST requests to give products without a barcode an
+// internal EAN-13 code. Without codes every such product is assigned.
+func (h *LabelHandler) AssignBarcodes(c *gin.Context) {
+	var req request.BarcodeAssignRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	assigned, err := h.barcodes.AssignMissing(c.Request.Context(), req.Codes)
+	if err != nil {
+		if errors.Is(err, models.ErrBarcodeRangeExhausted) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, assigned)
+}
+
+// ProductLabels handles POST requests to print product labels with name,
+// price, shelf and barcode. Products without a barcode get their code
+// printed as Code 128.
+func (h *LabelHandler) ProductLabels(c *gin.Context) {
+	var req request.ProductLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Prices are left off for roles that may not see them
+	showPrice := true
+	if grant := callerGrant(c); grant != nil {
+		for _, field := range grant.Hidden("products") {
+			if field == "sale_price" {
+				showPrice = false
+			}
+		}
+	}
+
+	ctx := c.Request.Context()
+	shelfNames := map[string]string{}
+	labels := make([]label.Label, 0, len(req.Items))
+	for _, item := range req.Items {
+		product, err := h.products.Get(ctx, item.Code)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product " + item.Code + " not found"})
+			return
+		}
+
+		l := label.Label{
+			Title:    product.Name,
+			Code:     product.BarcodeValue,
+			Quantity: item.Quantity,
+		}
+		if showPrice {
+			l.Lines = append(l.Lines, fmt.Sprintf("Price: %.2f", product.SalePrice))
+		}
+		if l.Code == "" {
+			l.Code = product.Code
+		}
+		l.Symbology = barcode.SymbologyFor(l.Code)
+
+		if item.ShelfID != "" {
+			name, ok := shelfNames[item.ShelfID]
+			if !ok {
+				shelf, err := h.shelves.Get(ctx, item.ShelfID)
+				if err != nil {
+					c.JSON(http.StatusNotFound, gin.H{"error": "Shelf " + item.ShelfID + " not found"})
+					return
+				}
+				name = shelf.Name
+				shelfNames[item.ShelfID] = name
+			}
+			l.Lines = append(l.Lines, "Shelf: "+name)
+		}
+		labels = append(labels, l)
+	}
+
+	h.render(c, req.LabelLayoutRequest, labels, "product-labels")
+}
+
+// ShelfLabels handles POST requests to print shelf and bin labels carrying a
+// QR code of the location
+func (h *LabelHandler) ShelfLabels(c *gin.Context) {
+	var req request.ShelfLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	labels := make([]label.Label, 0, len(req.Items))
+	for _, item := range req.Items {
+		shelf, err := h.shelves.Get(c.Request.Context(), item.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Shelf " + item.ID + " not found"})
+			return
+		}
+
+		labels = append(labels, label.Label{
+			Title:     shelf.Name,
+			Lines:     []string{"ID: " + shelf.ID},
+			Code:      shelfCodePrefix + shelf.ID,
+			Symbology: barcode.QRCode,
+			Quantity:  item.Quantity,
+		})
+	}
+
+	h.render(c, req.LabelLayoutRequest, labels, "shelf-labels")
+}
+
+// render responds with the labels as an A4 PDF sheet or as ZPL for thermal printers
+func (h *LabelHandler) render(c *gin.Context, layout request.LabelLayoutRequest, labels []label.Label, name string) {
+	var buf bytes.Buffer
+	var err error
+	contentType := "application/pdf"
+	if layout.Format == "zpl" {
+		media := label.DefaultMedia
+		if layout.Width > 0 {
+			media.Width = layout.Width
+		}
+		if layout.Height > 0 {
+			media.Height = layout.Height
+		}
+		err = label.RenderZPL(&buf, labels, media)
+		contentType = "text/plain; charset=utf-8"
+		name += ".zpl"
+	} else {
+		sheet := label.DefaultSheet
+		if layout.Columns > 0 {
+			sheet.Columns = layout.Columns
+		}
+		if layout.Rows > 0 {
+			sheet.Rows = layout.Rows
+		}
+		if layout.Margin != nil {
+			sheet.Margin = *layout.Margin
+		}
+		err = label.RenderPDF(&buf, labels, sheet)
+		name += ".pdf"
+	}
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
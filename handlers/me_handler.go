@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/models"
+)
+
+// MeHandler handles HTTP requests about the current user
+type MeHandler struct {
+	permissions *models.Permissions
+	companies   *models.CompanyFirebase
+	memberships *models.MembershipFirebase
+}
+
+// NewMeHandler creates a new MeHandler instance
+func NewMeHandler(permissions *models.Permissions, companies *models.CompanyFirebase, memberships *models.MembershipFirebase) *MeHandler {
+	return &MeHandler{
+		permissions: permissions,
+		companies:   companies,
+		memberships: memberships,
+	}
+}
+
+// Companies handles GET requests for the companies the caller belongs to or
+// has asked to join, so clients can offer a company switcher
+func (h *MeHandler) Companies(c *gin.Context) {
+	memberships, err := h.memberships.ListForUser(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	companies := []gin.H{}
+	for _, membership := range memberships {
+		company, err := h.companies.Get(c.Request.Context(), membership.CompanyID)
+		if err != nil {
+			continue
+		}
+		companies = append(companies, gin.H{
+			"company_id": membership.CompanyID,
+			"name":       company.Name,
+			"role":       membership.Role,
+			"branches":   membership.Branches,
+			"status":     membership.Status,
+		})
+	}
+
+	c.JSON(http.StatusOK, companies)
+}
+
+// Permissions handles GET requests for the caller's role, permissions, hidden
+// fields and branches in the current company, so clients can hide what they may not use
+func (h *MeHandler) Permissions(c *gin.Context) {
+	grant, err := h.permissions.Resolve(c.Request.Context(), c.GetString("role"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if grant.Permissions == nil {
+		grant.Permissions = []string{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"company_id":    c.GetString("company_id"),
+		"role":          grant.Role,
+		"permissions":   grant.Permissions,
+		"hidden_fields": grant.HiddenFields,
+		"branches":      models.BranchesFromContext(c.Request.Context()),
+	})
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
+)
+
+// MembershipHandler handles HTTP requests for the members of the current company
+type MembershipHandler struct {
+	memberships *models.MembershipFirebase
+	permissions *models.Permissions
+}
+
+// NewMembershipHandler creates a new MembershipHandler instance
+func NewMembershipHandler(memberships *models.MembershipFirebase, permissions *models.Permissions) *MembershipHandler {
+	return &MembershipHandler{
+		memberships: memberships,
+		permissions: permissions,
+	}
+}
+
+// List handles GET requests to list the memberships of the current company,
+// including pending requests to join it
+func (h *MembershipHandler) List(c *gin.Context) {
+	memberships, err := h.memberships.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, memberships)
+}
+
+// Update handles PUT requests to change a member's role, branches or status,
+// e.g. to activate a pending membership
+func (h *MembershipHandler) Update(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Membership ID is required"})
+		return
+	}
+
+	var req request.MembershipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkRole(c, h.permissions, req.Role) {
+		return
+	}
+
+	membership, err := h.memberships.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkMemberRole(c, h.permissions, membership.Role) {
+		return
+	}
+
+	if err := h.memberships.Update(c.Request.Context(), id, req.Transform(membership)); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
+	c.JSON(http.StatusOK, membership)
+}
+
+// Delete handles DELETE requests to remove a member from the current company
+func (h *MembershipHandler) Delete(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Membership ID is required"})
+		return
+	}
+
+	membership, err := h.memberships.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkMemberRole(c, h.permissions, membership.Role) {
+		return
+	}
+
+	if err := h.memberships.Delete(c.Request.Context(), id); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Membership deleted successfully"})
+}
+
+// checkRole responds with 422 unless role exists in the current company and
+// the caller holds every permission it grants, so members cannot be given
+// more than the caller has
+func checkRole(c *gin.Context, permissions *models.Permissions, role string) bool {
+	grant, err := permissions.Role(c.Request.Context(), role)
+	if errors.Is(err, models.ErrUnknownRole) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Unknown role " + role})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+
+	if !callerCovers(c, grant) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "You cannot grant role " + role})
+		return false
+	}
+	return true
+}
+
+// checkMemberRole responds with 403 unless the caller holds every permission
+// of a member's current role, so members cannot change or remove those above
+// them, e.g. a manager demoting an owner. Pending members have no role yet
+// and roles deleted since grant nothing.
+func checkMemberRole(c *gin.Context, permissions *models.Permissions, role string) bool {
+	if role == "" {
+		return true
+	}
+	grant, err := permissions.Role(c.Request.Context(), role)
+	if errors.Is(err, models.ErrUnknownRole) {
+		return true
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+
+	if !callerCovers(c, grant) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot change a member with role " + role})
+		return false
+	}
+	return true
+}
+
+// callerCovers reports whether the caller holds every permission of grant
+func callerCovers(c *gin.Context, grant *models.Grant) bool {
+	caller := callerGrant(c)
+	for _, permission := range grant.Permissions {
+		if caller == nil || !caller.Allows(permission) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+	"github.com/nirshpaa/godam-backend/models"
+)
+
+// newMembershipRouter serves the membership routes of company-a to a caller
+// holding the grant of role
+func newMembershipRouter(t *testing.T, role string) (*gin.Engine, *models.MembershipFirebase) {
+	client := firestoretest.New(t)
+	memberships := models.NewMembershipFirebase(client)
+	permissions := models.NewPermissions(client)
+	handler := NewMembershipHandler(memberships, permissions)
+
+	router := newTestRouter("company-a", "caller-1")
+	router.Use(func(c *gin.Context) {
+		c.Set("grant", &models.Grant{Role: role, Permissions: models.DefaultRoles[role]})
+		c.Next()
+	})
+	router.PUT("/memberships/:id", handler.Update)
+	router.DELETE("/memberships/:id", handler.Delete)
+
+	ctx := models.WithCompany(context.Background(), "company-a")
+	if _, err := client.Collection("companies").Doc("company-a").Set(ctx, map[string]interface{}{"name": "A"}); err != nil {
+		t.Fatalf("seeding company: %v", err)
+	}
+	if _, err := memberships.Request(ctx, "company-a", "user-1"); err != nil {
+		t.Fatalf("seeding membership: %v", err)
+	}
+	return router, memberships
+}
+
+func TestMembershipUpdateRole(t *testing.T) {
+	path := "/memberships/" + models.MembershipID("company-a", "user-1")
+	tests := []struct {
+		caller, role string
+		want         int
+	}{
+		{models.RoleOwner, models.RoleManager, http.StatusOK},
+		{models.RoleOwner, "auditor", http.StatusUnprocessableEntity},
+		{models.RoleManager, models.RoleClerk, http.StatusOK},
+		{models.RoleManager, models.RoleOwner, http.StatusUnprocessableEntity},
+		{models.RoleClerk, models.RoleSalesman, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		router, memberships := newMembershipRouter(t, tt.caller)
+		w := serve(t, router, http.MethodPut, path, gin.H{"role": tt.role, "status": models.MembershipActive})
+		if w.Code != tt.want {
+			t.Errorf("%s setting role %s: status = %d, want %d: %s", tt.caller, tt.role, w.Code, tt.want, w.Body)
+			continue
+		}
+
+		membership, err := memberships.Active(context.Background(), "company-a", "user-1")
+		if err != nil {
+			t.Fatalf("Active: %v", err)
+		}
+		if tt.want == http.StatusOK && (membership == nil || membership.Role != tt.role) {
+			t.Errorf("%s setting role %s: membership = %+v", tt.caller, tt.role, membership)
+		}
+		if tt.want != http.StatusOK && membership != nil {
+			t.Errorf("%s setting role %s: rejected update was stored: %+v", tt.caller, tt.role, membership)
+		}
+	}
+}
+
+func TestMembershipAboveCaller(t *testing.T) {
+	path := "/memberships/" + models.MembershipID("company-a", "owner-1")
+	ctx := models.WithCompany(context.Background(), "company-a")
+	tests := []struct {
+		caller, method string
+		want           int
+	}{
+		{models.RoleManager, http.MethodPut, http.StatusForbidden},
+		{models.RoleManager, http.MethodDelete, http.StatusForbidden},
+		{models.RoleOwner, http.MethodPut, http.StatusOK},
+	}
+	for _, tt := range tests {
+		router, memberships := newMembershipRouter(t, tt.caller)
+		if _, err := memberships.Add(ctx, "company-a", "owner-1", models.RoleOwner); err != nil {
+			t.Fatalf("seeding owner: %v", err)
+		}
+
+		w := serve(t, router, tt.method, path, gin.H{"role": models.RoleManager, "status": models.MembershipActive})
+		if w.Code != tt.want {
+			t.Errorf("%s %s of an owner: status = %d, want %d: %s", tt.caller, tt.method, w.Code, tt.want, w.Body)
+			continue
+		}
+
+		membership, err := memberships.Active(ctx, "company-a", "owner-1")
+		if err != nil {
+			t.Fatalf("Active: %v", err)
+		}
+		if tt.want != http.StatusOK && (membership == nil || membership.Role != models.RoleOwner) {
+			t.Errorf("%s %s of an owner: rejected change was stored: %+v", tt.caller, tt.method, membership)
+		}
+	}
+}
+
+func TestMembershipUpdateBranches(t *testing.T) {
+	router, memberships := newMembershipRouter(t, models.RoleOwner)
+	ctx := models.WithCompany(context.Background(), "company-a")
+	if _, err := memberships.Add(ctx, "company-a", "user-2", models.RoleClerk); err != nil {
+		t.Fatalf("seeding member: %v", err)
+	}
+	path := "/memberships/" + models.MembershipID("company-a", "user-2")
+
+	w := serve(t, router, http.MethodPut, path, gin.H{"role": models.RoleClerk, "status": models.MembershipActive, "branches": []string{"branch-9"}})
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("granting an unknown branch: status = %d, want 422: %s", w.Code, w.Body)
+	}
+	if membership, err := memberships.Active(ctx, "company-a", "user-2"); err != nil || membership == nil || len(membership.Branches) != 0 {
+		t.Errorf("membership after a rejected update = %+v, %v", membership, err)
+	}
+}
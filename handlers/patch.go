@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/nirshpaa/godam-backend/libraries/patch"
+	"github.com/nirshpaa/godam-backend/payloads/request"
+)
+
+// maxPatchSize limits the request body of a patch
+const maxPatchSize = 1 << 20
+
+// errPatchVersion is returned for patches that do not name the version they apply to
+var errPatchVersion = errors.New("If-Match header with the version being patched is required")
+
+// applyPatch applies the request body to current as a merge patch or JSON patch,
+// validates the result against payload and decodes it back into current.
+// On failure it returns the HTTP status to respond with.
+//
+// Patches are computed from the document as read, so they must carry its
+// version in If-Match; the write then fails with 412 when the document changed
+// in between rather than overwriting the other change.
+func applyPatch(c *gin.Context, current interface{}, payload interface{}) (int, error) {
+	if ifMatch := strings.TrimSpace(c.GetHeader("If-Match")); ifMatch == "" || ifMatch == "*" {
+		return http.StatusPreconditionRequired, errPatchVersion
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPatchSize))
+	if err != nil {
+		var maxBytes *http.MaxBytesError
+		if errors.As(err, &maxBytes) {
+			return http.StatusRequestEntityTooLarge, fmt.Errorf("patch document is larger than %d KB", maxPatchSize>>10)
+		}
+		return http.StatusBadRequest, fmt.Errorf("failed to read patch document: %v", err)
+	}
+
+	original, err := json.Marshal(current)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	var patched []byte
+	switch contentType := c.ContentType(); contentType {
+	case patch.JSONPatchType:
+		patched, err = patch.JSONPatch(original, body)
+	case patch.MergePatchType, binding.MIMEJSON, "":
+		patched, err = patch.MergePatch(original, body)
+	default:
+		c.Header("Accept-Patch", patch.MergePatchType+", "+patch.JSONPatchType)
+		return http.StatusUnsupportedMediaType, fmt.Errorf("unsupported patch content type %q", contentType)
+	}
+	if err != nil {
+		var malformed *patch.MalformedError
+		var conflict *patch.ConflictError
+		switch {
+		case errors.As(err, &malformed):
+			return http.StatusBadRequest, err
+		case errors.As(err, &conflict):
+			return http.StatusConflict, err
+		default:
+			return http.StatusInternalServerError, err
+		}
+	}
+
+	if payload != nil {
+		if err := request.ValidatePatched(patched, payload); err != nil {
+			return http.StatusUnprocessableEntity, err
+		}
+	}
+
+	// Start from a zero value so fields removed by the patch are cleared
+	target := reflect.ValueOf(current).Elem()
+	target.Set(reflect.Zero(target.Type()))
+
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(current); err != nil {
+		return http.StatusUnprocessableEntity, fmt.Errorf("invalid patched document: %v", err)
+	}
+
+	return http.StatusOK, nil
+}
@@ -21,9 +21,8 @@ func NewPredictiveHandler(predictiveService *services.PredictiveService) *Predic
 
 // GetStockRecommendations handles GET /api/predictive/stock-recommendations
 func (h *PredictiveHandler) GetStockRecommendations(c *gin.Context) {
-	companyID := c.Param("companyId")
-	if companyID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Company ID is required"})
+	companyID, ok := companyParam(c)
+	if !ok {
 		return
 	}
 
@@ -38,9 +37,8 @@ func (h *PredictiveHandler) GetStockRecommendations(c *gin.Context) {
 
 // GetSalesPredictions handles GET /api/predictive/sales-predictions
 func (h *PredictiveHandler) GetSalesPredictions(c *gin.Context) {
-	companyID := c.Param("companyId")
-	if companyID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Company ID is required"})
+	companyID, ok := companyParam(c)
+	if !ok {
 		return
 	}
 
@@ -95,9 +93,8 @@ func (h *PredictiveHandler) GetProductHistory(c *gin.Context) {
 
 // GetSalesReport handles GET /api/predictive/sales-report
 func (h *PredictiveHandler) GetSalesReport(c *gin.Context) {
-	companyID := c.Param("companyId")
-	if companyID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Company ID is required"})
+	companyID, ok := companyParam(c)
+	if !ok {
 		return
 	}
 
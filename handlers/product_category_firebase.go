@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
 	"github.com/nirshpaa/godam-backend/payloads/response"
 )
 
@@ -52,6 +53,7 @@ func (h *ProductCategoryHandler) Get(c *gin.Context) {
 
 	var res response.ProductCategoryResponse
 	res.Transform(category)
+	setETag(c)
 	c.JSON(http.StatusOK, res)
 }
 
@@ -92,6 +94,9 @@ func (h *ProductCategoryHandler) Update(c *gin.Context) {
 	}
 
 	if err := h.model.Update(c.Request.Context(), category); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		log.Printf("Error updating product category: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product category"})
 		return
@@ -99,6 +104,38 @@ func (h *ProductCategoryHandler) Update(c *gin.Context) {
 
 	var res response.ProductCategoryResponse
 	res.Transform(category)
+	setETag(c)
+	c.JSON(http.StatusOK, res)
+}
+
+// Patch partially updates an existing product category
+func (h *ProductCategoryHandler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	category, err := h.model.Get(c.Request.Context(), id)
+	if err != nil {
+		log.Printf("Error getting product category: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get product category"})
+		return
+	}
+
+	if status, err := applyPatch(c, category, &request.NewProductCategoryRequest{}); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	category.ID = id
+	if err := h.model.Update(c.Request.Context(), category); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		log.Printf("Error updating product category: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product category"})
+		return
+	}
+
+	var res response.ProductCategoryResponse
+	res.Transform(category)
+	setETag(c)
 	c.JSON(http.StatusOK, res)
 }
 
@@ -106,6 +143,9 @@ func (h *ProductCategoryHandler) Update(c *gin.Context) {
 func (h *ProductCategoryHandler) Delete(c *gin.Context) {
 	id := c.Param("id")
 	if err := h.model.Delete(c.Request.Context(), id); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		log.Printf("Error deleting product category: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete product category"})
 		return
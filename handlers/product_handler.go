@@ -1,26 +1,34 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/nirshpaa/godam-backend/interfaces"
+	"github.com/nirshpaa/godam-backend/libraries/imageproc"
 	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
 	"github.com/nirshpaa/godam-backend/services"
 )
 
+// maxImageUpload limits the size of a product image upload
+const maxImageUpload = 20 << 20
+
 // ProductHandler handles product-related HTTP requests
 type ProductHandler struct {
 	productModel         *models.ProductFirebase
 	fileStorage          interfaces.FileStorage
 	imageRecognition     interfaces.ImageRecognition
 	imageTrainingService *services.ImageTrainingService
+	scans                *models.ScanFirebase
+	dataset              *services.DatasetService
 }
 
 // NewProductHandler creates a new product handler
@@ -29,12 +37,16 @@ func NewProductHandler(
 	fileStorage interfaces.FileStorage,
 	imageRecognition interfaces.ImageRecognition,
 	imageTrainingService *services.ImageTrainingService,
+	scans *models.ScanFirebase,
+	dataset *services.DatasetService,
 ) *ProductHandler {
 	return &ProductHandler{
 		productModel:         productModel,
 		fileStorage:          fileStorage,
 		imageRecognition:     imageRecognition,
 		imageTrainingService: imageTrainingService,
+		scans:                scans,
+		dataset:              dataset,
 	}
 }
 
@@ -62,6 +74,7 @@ func (h *ProductHandler) Get(c *gin.Context) {
 		return
 	}
 
+	setETag(c)
 	c.JSON(http.StatusOK, product)
 }
 
@@ -87,6 +100,8 @@ func (h *ProductHandler) Create(c *gin.Context) {
 		return
 	}
 
+	h.indexImage(c, product.Code, product.ImageURL)
+
 	c.JSON(http.StatusCreated, gin.H{"id": id})
 }
 
@@ -121,10 +136,17 @@ func (h *ProductHandler) Update(c *gin.Context) {
 
 	// Update the product
 	if err := h.productModel.Update(c.Request.Context(), code, &product, h.fileStorage); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if product.ImageURL != existingProduct.ImageURL {
+		h.indexImage(c, code, product.ImageURL)
+	}
+
 	// Get the updated product to return
 	updatedProduct, err := h.productModel.Get(c.Request.Context(), code)
 	if err != nil {
@@ -132,6 +154,46 @@ func (h *ProductHandler) Update(c *gin.Context) {
 		return
 	}
 
+	setETag(c)
+	c.JSON(http.StatusOK, updatedProduct)
+}
+
+// Patch handles PATCH /products/:code
+func (h *ProductHandler) Patch(c *gin.Context) {
+	code := c.Param("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Product code is required"})
+		return
+	}
+
+	product, err := h.productModel.Get(c.Request.Context(), code)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	if status, err := applyPatch(c, product, &request.NewProductRequest{}); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The product code identifies the document and cannot be patched
+	product.Code = code
+	if err := h.productModel.Update(c.Request.Context(), code, product, h.fileStorage); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	updatedProduct, err := h.productModel.Get(c.Request.Context(), code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get updated product"})
+		return
+	}
+
+	setETag(c)
 	c.JSON(http.StatusOK, updatedProduct)
 }
 
@@ -153,6 +215,9 @@ func (h *ProductHandler) Delete(c *gin.Context) {
 	// Delete the product
 	err = h.productModel.Delete(c.Request.Context(), code)
 	if err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -173,7 +238,11 @@ func (h *ProductHandler) FindByBarcode(c *gin.Context) {
 
 // FindByCompany handles GET /products/company/:companyId
 func (h *ProductHandler) FindByCompany(c *gin.Context) {
-	companyID := c.Param("companyId")
+	companyID, ok := companyParam(c)
+	if !ok {
+		return
+	}
+
 	products, err := h.productModel.FindByCompany(c.Request.Context(), companyID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -199,7 +268,7 @@ func (h *ProductHandler) ProcessImage(c *gin.Context) {
 	defer os.Remove(tempPath)
 
 	// Process the image
-	result, err := h.productModel.ProcessImage(tempPath, h.imageRecognition)
+	result, err := h.productModel.ProcessImage(c.Request.Context(), tempPath, h.imageRecognition)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -224,7 +293,7 @@ func (h *ProductHandler) UpdateImage(c *gin.Context) {
 		return
 	}
 
-	result, err := h.productModel.ProcessImage(imagePath, h.imageRecognition)
+	result, err := h.productModel.ProcessImage(c.Request.Context(), imagePath, h.imageRecognition)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -239,6 +308,9 @@ func (h *ProductHandler) UpdateImage(c *gin.Context) {
 
 	err = h.productModel.UpdateImage(c.Request.Context(), code, imageURL, result.Data.(string), result.Data.(string))
 	if err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -318,7 +390,7 @@ func (h *ProductHandler) ScanProduct(c *gin.Context) {
 	}
 
 	// Process the image
-	result, err := h.productModel.ProcessImage(tempFile.Name(), h.imageRecognition)
+	result, err := h.productModel.ProcessImage(c.Request.Context(), tempFile.Name(), h.imageRecognition)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -331,10 +403,30 @@ func (h *ProductHandler) ScanProduct(c *gin.Context) {
 	// Log the recognition result
 	fmt.Printf("Recognition result: %+v\n", result)
 
+	// Record the scan so the user's pick can be confirmed against the candidates
+	scan := &models.FirebaseScan{
+		UserID:     c.GetString("userID"),
+		Candidates: result.Candidates,
+		Accepted:   result.Success,
+	}
+	if result.Barcode != nil {
+		scan.Barcode = result.Barcode.Value
+	}
+	scanID, err := h.scans.Create(c.Request.Context(), scan)
+	if err != nil {
+		log.Printf("Failed to record scan: %v", err)
+	} else if err := h.dataset.KeepScanImage(c.Request.Context(), scanID, tempFile.Name()); err != nil {
+		// Keep the image for the training dataset once the scan is confirmed
+		log.Printf("Failed to keep image of scan %s: %v", scanID, err)
+	}
+
 	response := gin.H{
-		"success": true,
-		"error":   nil,
-		"data":    result.Data,
+		"success":    true,
+		"error":      nil,
+		"scan_id":    scanID,
+		"data":       result.Data,
+		"barcode":    result.Barcode,
+		"candidates": result.Candidates,
 	}
 
 	// Log the response
@@ -343,55 +435,118 @@ func (h *ProductHandler) ScanProduct(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// UploadImage handles POST /products/upload
+// UploadImage handles POST /products/:code/image, storing the image with
+// its variants
 func (h *ProductHandler) UploadImage(c *gin.Context) {
-	file, err := c.FormFile("image")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	data, ok := readImageUpload(c)
+	if !ok {
 		return
 	}
 
-	// Generate a unique filename
-	filename := fmt.Sprintf("%d_%s", time.Now().Unix(), file.Filename)
-	path := "products/" + filename
-
-	// Save the file directly
-	imageURL, err := h.fileStorage.SaveFile(file, path)
+	paths, err := h.fileStorage.SaveImage(c.Request.Context(), data, "products")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeImageError(c, err)
 		return
 	}
+	h.indexImage(c, c.Param("code"), paths.Image)
 
-	c.JSON(http.StatusOK, gin.H{"image_url": imageURL})
+	c.JSON(http.StatusOK, gin.H{
+		"image_url": paths.Image,
+		"images":    paths,
+	})
 }
 
 // Upload handles POST /products/upload
 func (h *ProductHandler) Upload(c *gin.Context) {
-	file, err := c.FormFile("image")
+	data, ok := readImageUpload(c)
+	if !ok {
+		return
+	}
+
+	paths, err := h.fileStorage.SaveImage(c.Request.Context(), data, "")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No image file provided"})
+		writeImageError(c, err)
 		return
 	}
 
-	// Generate unique filename
-	filename := fmt.Sprintf("%d_%s", time.Now().Unix(), file.Filename)
-	imagePath := filepath.Join("assets", "products", filename)
+	// Return the image URLs under the path the storage root is served from
+	const prefix = "uploads/"
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"image_url": prefix + paths.Image,
+		"images": imageproc.Paths{
+			Image:         prefix + paths.Image,
+			Thumbnail:     prefix + paths.Thumbnail,
+			WebP:          prefix + paths.WebP,
+			ThumbnailWebP: prefix + paths.ThumbnailWebP,
+		},
+	})
+}
 
-	// Create directory if it doesn't exist
-	if err := os.MkdirAll(filepath.Dir(imagePath), 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create image directory"})
+// IndexImages handles POST /products/recognition/index by rebuilding the
+// local visual matcher from the product images
+func (h *ProductHandler) IndexImages(c *gin.Context) {
+	indexed, err := h.imageRecognition.ReindexProductImages(c.Request.Context())
+	if err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Save the uploaded file
-	if err := c.SaveUploadedFile(file, imagePath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
+	c.JSON(http.StatusOK, gin.H{"indexed": indexed})
+}
+
+// indexImage adds a product image to the local visual matcher. Scans fall
+// back to the other recognizers when it fails, so errors are only logged.
+func (h *ProductHandler) indexImage(c *gin.Context, code, imageURL string) {
+	if code == "" || imageURL == "" {
 		return
 	}
+	if err := h.imageRecognition.IndexProductImage(c.Request.Context(), code, imageURL); err != nil {
+		log.Printf("Failed to index image of product %s: %v", code, err)
+	}
+}
 
-	// Return the image URL
-	c.JSON(http.StatusOK, gin.H{
-		"success":   true,
-		"image_url": "assets/products/" + filename,
-	})
+// readImageUpload reads the image form file, responding with an error when
+// it is missing or too large
+func readImageUpload(c *gin.Context) ([]byte, bool) {
+	file, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No image file provided"})
+		return nil, false
+	}
+	if file.Size > maxImageUpload {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image is too large"})
+		return nil, false
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, maxImageUpload))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return data, true
+}
+
+// writeImageError responds with the status matching an image pipeline error
+func writeImageError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, imageproc.ErrUnsupportedFormat):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Image must be JPEG, PNG, GIF or WebP"})
+	case errors.Is(err, imageproc.ErrInvalidImage):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, imageproc.ErrTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
 }
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+	"github.com/nirshpaa/godam-backend/models"
+)
+
+func newProductRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	client := firestoretest.New(t)
+	products, _ := models.NewProductFirebase(client)
+	ctx := models.WithCompany(context.Background(), "company-a")
+	if _, err := products.Create(ctx, &models.FirebaseProduct{Code: "P1", Name: "Tea", MinimumStock: 4}, nil); err != nil {
+		t.Fatalf("creating product: %v", err)
+	}
+
+	handler := NewProductHandler(products, nil, nil, nil, nil, nil)
+	router := newTestRouter("company-a", "user-a")
+	router.GET("/products/:code", handler.Get)
+	router.PUT("/products/:code", handler.Update)
+	router.PATCH("/products/:code", handler.Patch)
+	router.DELETE("/products/:code", handler.Delete)
+	return router
+}
+
+func TestProductGetNotModified(t *testing.T) {
+	router := newProductRouter(t)
+
+	w := serve(t, router, http.MethodGet, "/products/P1", nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("GET = %d with ETag %q, want 200 with an ETag", w.Code, etag)
+	}
+
+	w = serve(t, router, http.MethodGet, "/products/P1", nil, "If-None-Match", etag)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("GET If-None-Match = %d with %d bytes, want 304 without a body", w.Code, w.Body.Len())
+	}
+
+	w = serve(t, router, http.MethodGet, "/products/P1", nil, "If-None-Match", `"1"`)
+	if w.Code != http.StatusOK {
+		t.Errorf("GET stale If-None-Match = %d, want 200", w.Code)
+	}
+}
+
+func TestProductWritesWithStaleVersion(t *testing.T) {
+	router := newProductRouter(t)
+	etag := serve(t, router, http.MethodGet, "/products/P1", nil).Header().Get("ETag")
+
+	update := gin.H{"name": "Green tea", "minimum_stock": 4}
+	w := serve(t, router, http.MethodPut, "/products/P1", update, "If-Match", `"1"`)
+	if w.Code != http.StatusPreconditionFailed || w.Header().Get("ETag") != etag {
+		t.Fatalf("PUT stale If-Match = %d with ETag %q, want 412 with %q", w.Code, w.Header().Get("ETag"), etag)
+	}
+
+	w = serve(t, router, http.MethodDelete, "/products/P1", nil, "If-Match", `"1"`)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("DELETE stale If-Match = %d, want 412", w.Code)
+	}
+
+	w = serve(t, router, http.MethodPut, "/products/P1", update, "If-Match", etag)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT current If-Match = %d, want 200: %s", w.Code, w.Body)
+	}
+	etag = w.Header().Get("ETag")
+
+	w = serve(t, router, http.MethodDelete, "/products/P1", nil, "If-Match", etag)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE current If-Match = %d, want 204: %s", w.Code, w.Body)
+	}
+}
+
+func TestProductPatchRequiresVersion(t *testing.T) {
+	router := newProductRouter(t)
+	etag := serve(t, router, http.MethodGet, "/products/P1", nil).Header().Get("ETag")
+	patch := gin.H{"name": "Green tea"}
+
+	if w := serve(t, router, http.MethodPatch, "/products/P1", patch); w.Code != http.StatusPreconditionRequired {
+		t.Fatalf("PATCH without If-Match = %d, want 428", w.Code)
+	}
+	if w := serve(t, router, http.MethodPatch, "/products/P1", patch, "If-Match", "*"); w.Code != http.StatusPreconditionRequired {
+		t.Fatalf("PATCH If-Match * = %d, want 428", w.Code)
+	}
+	if w := serve(t, router, http.MethodPatch, "/products/P1", patch, "If-Match", `"1"`); w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("PATCH stale If-Match = %d, want 412", w.Code)
+	}
+
+	w := serve(t, router, http.MethodPatch, "/products/P1", patch, "If-Match", etag)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PATCH current If-Match = %d, want 200: %s", w.Code, w.Body)
+	}
+	if w := serve(t, router, http.MethodPatch, "/products/P1", patch, "If-Match", etag); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("PATCH with the replaced version = %d, want 412", w.Code)
+	}
+
+	huge := gin.H{"name": strings.Repeat("a", maxPatchSize)}
+	if w := serve(t, router, http.MethodPatch, "/products/P1", huge, "If-Match", w.Header().Get("ETag")); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("PATCH larger than the limit = %d, want 413", w.Code)
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
 )
 
 // PurchaseHandler handles HTTP requests for purchases
@@ -42,6 +43,7 @@ func (h *PurchaseHandler) Get(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
+	setETag(c)
 	c.JSON(http.StatusOK, purchase)
 }
 
@@ -55,6 +57,9 @@ func (h *PurchaseHandler) Create(c *gin.Context) {
 
 	id, err := h.purchaseFirebase.Create(c.Request.Context(), &purchase)
 	if err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -79,10 +84,46 @@ func (h *PurchaseHandler) Update(c *gin.Context) {
 
 	purchase.ID = id
 	if err := h.purchaseFirebase.Update(c.Request.Context(), id, &purchase); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c)
+	c.JSON(http.StatusOK, purchase)
+}
+
+// Patch handles PATCH requests to partially update a purchase
+func (h *PurchaseHandler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Purchase ID is required"})
+		return
+	}
+
+	purchase, err := h.purchaseFirebase.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := applyPatch(c, purchase, &request.NewPurchaseRequest{}); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	purchase.ID = id
+	if err := h.purchaseFirebase.Update(c.Request.Context(), id, purchase); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
 	c.JSON(http.StatusOK, purchase)
 }
 
@@ -95,6 +136,9 @@ func (h *PurchaseHandler) Delete(c *gin.Context) {
 	}
 
 	if err := h.purchaseFirebase.Delete(c.Request.Context(), id); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
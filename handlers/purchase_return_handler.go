@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
 )
 
 type PurchaseReturnHandler struct {
@@ -38,6 +39,7 @@ func (h *PurchaseReturnHandler) Get(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
+	setETag(c)
 	c.JSON(http.StatusOK, purchaseReturn)
 }
 
@@ -50,6 +52,9 @@ func (h *PurchaseReturnHandler) Create(c *gin.Context) {
 
 	id, err := h.purchaseReturnFirebase.Create(c.Request.Context(), &purchaseReturn)
 	if err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -73,10 +78,46 @@ func (h *PurchaseReturnHandler) Update(c *gin.Context) {
 
 	purchaseReturn.ID = id
 	if err := h.purchaseReturnFirebase.Update(c.Request.Context(), id, &purchaseReturn); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c)
+	c.JSON(http.StatusOK, purchaseReturn)
+}
+
+// Patch handles PATCH requests to partially update a purchase return
+func (h *PurchaseReturnHandler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Purchase Return ID is required"})
+		return
+	}
+
+	purchaseReturn, err := h.purchaseReturnFirebase.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := applyPatch(c, purchaseReturn, &request.NewPurchaseReturnRequest{}); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	purchaseReturn.ID = id
+	if err := h.purchaseReturnFirebase.Update(c.Request.Context(), id, purchaseReturn); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
 	c.JSON(http.StatusOK, purchaseReturn)
 }
 
@@ -88,6 +129,9 @@ func (h *PurchaseReturnHandler) Delete(c *gin.Context) {
 	}
 
 	if err := h.purchaseReturnFirebase.Delete(c.Request.Context(), id); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
 )
 
 type ReceiveHandler struct {
@@ -38,6 +39,7 @@ func (h *ReceiveHandler) Get(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
+	setETag(c)
 	c.JSON(http.StatusOK, receive)
 }
 
@@ -50,6 +52,9 @@ func (h *ReceiveHandler) Create(c *gin.Context) {
 
 	id, err := h.receiveFirebase.Create(c.Request.Context(), &receive)
 	if err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -73,10 +78,46 @@ func (h *ReceiveHandler) Update(c *gin.Context) {
 
 	receive.ID = id
 	if err := h.receiveFirebase.Update(c.Request.Context(), id, &receive); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c)
+	c.JSON(http.StatusOK, receive)
+}
+
+// Patch handles PATCH requests to partially update a receive
+func (h *ReceiveHandler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Receive ID is required"})
+		return
+	}
+
+	receive, err := h.receiveFirebase.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := applyPatch(c, receive, &request.NewReceiveRequest{}); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	receive.ID = id
+	if err := h.receiveFirebase.Update(c.Request.Context(), id, receive); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
 	c.JSON(http.StatusOK, receive)
 }
 
@@ -88,6 +129,9 @@ func (h *ReceiveHandler) Delete(c *gin.Context) {
 	}
 
 	if err := h.receiveFirebase.Delete(c.Request.Context(), id); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
 )
 
 type ReceiveReturnHandler struct {
@@ -38,6 +39,7 @@ func (h *ReceiveReturnHandler) Get(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
+	setETag(c)
 	c.JSON(http.StatusOK, receiveReturn)
 }
 
@@ -50,6 +52,9 @@ func (h *ReceiveReturnHandler) Create(c *gin.Context) {
 
 	id, err := h.receiveReturnFirebase.Create(c.Request.Context(), &receiveReturn)
 	if err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -73,10 +78,46 @@ func (h *ReceiveReturnHandler) Update(c *gin.Context) {
 
 	receiveReturn.ID = id
 	if err := h.receiveReturnFirebase.Update(c.Request.Context(), id, &receiveReturn); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c)
+	c.JSON(http.StatusOK, receiveReturn)
+}
+
+// Patch handles PATCH requests to partially update a receive return
+func (h *ReceiveReturnHandler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Receive Return ID is required"})
+		return
+	}
+
+	receiveReturn, err := h.receiveReturnFirebase.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := applyPatch(c, receiveReturn, &request.NewReceiveReturnRequest{}); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	receiveReturn.ID = id
+	if err := h.receiveReturnFirebase.Update(c.Request.Context(), id, receiveReturn); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
 	c.JSON(http.StatusOK, receiveReturn)
 }
 
@@ -88,6 +129,9 @@ func (h *ReceiveReturnHandler) Delete(c *gin.Context) {
 	}
 
 	if err := h.receiveReturnFirebase.Delete(c.Request.Context(), id); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
+	"github.com/nirshpaa/godam-backend/services"
+)
+
+// RecognitionHandler handles HTTP requests for scan feedback and recognition settings
+type RecognitionHandler struct {
+	scans    *models.ScanFirebase
+	settings *models.RecognitionSettingsFirebase
+	products *models.ProductFirebase
+	dataset  *services.DatasetService
+}
+
+// NewRecognitionHandler creates a new RecognitionHandler instance
+func NewRecognitionHandler(scans *models.ScanFirebase, settings *models.RecognitionSettingsFirebase, products *models.ProductFirebase, dataset *services.DatasetService) *RecognitionHandler {
+	return &RecognitionHandler{
+		scans:    scans,
+		settings: settings,
+		products: products,
+		dataset:  dataset,
+	}
+}
+
+// Confirm handles POST /products/scan/:scanId/confirm, recording the product
+// the user picked for a scan and adding the scanned image to the training dataset
+func (h *RecognitionHandler) Confirm(c *gin.Context) {
+	var req request.ScanConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if _, err := h.products.Get(ctx, req.ProductCode); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product " + req.ProductCode + " not found"})
+		return
+	}
+
+	scan, err := h.scans.Confirm(ctx, c.Param("scanId"), req.ProductCode, c.GetString("userID"))
+	if err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := h.dataset.AddConfirmedScan(ctx, scan); err != nil {
+		log.Printf("Failed to add scan %s to the dataset: %v", scan.ID, err)
+	}
+
+	c.JSON(http.StatusOK, scan)
+}
+
+// Accuracy handles GET /products/scan/accuracy, summarizing the confirmed
+// scans between the optional from and to dates
+func (h *RecognitionHandler) Accuracy(c *gin.Context) {
+	from, err := parseAuditTime("from", c.Query("from"), false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := parseAuditTime("to", c.Query("to"), true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	accuracy, err := h.scans.Accuracy(c.Request.Context(), from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, accuracy)
+}
+
+// GetSettings handles GET /products/recognition/settings
+func (h *RecognitionHandler) GetSettings(c *gin.Context) {
+	settings, err := h.settings.Get(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateSettings handles PUT /products/recognition/settings
+func (h *RecognitionHandler) UpdateSettings(c *gin.Context) {
+	var req request.RecognitionSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if err := h.settings.Save(ctx, req.Transform()); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := h.settings.Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
+	c.JSON(http.StatusOK, settings)
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+	"github.com/nirshpaa/godam-backend/libraries/objectstore"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/services"
+	"github.com/nirshpaa/godam-backend/types"
+)
+
+func TestRecognitionConfirm(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := models.WithCompany(context.Background(), "company-a")
+	products, _ := models.NewProductFirebase(client)
+	for _, code := range []string{"P1", "P2"} {
+		if _, err := products.Create(ctx, &models.FirebaseProduct{Code: code, Name: "Product " + code}, nil); err != nil {
+			t.Fatalf("creating product: %v", err)
+		}
+	}
+	scans := models.NewScanFirebase(client)
+	scanID, err := scans.Create(ctx, &models.FirebaseScan{
+		UserID: "user-a",
+		Candidates: []types.RecognitionCandidate{
+			{ProductCode: "P1", Score: 0.7, Source: services.SourceVisual},
+			{ProductCode: "P2", Score: 0.5, Source: services.SourceCNN},
+		},
+	})
+	if err != nil {
+		t.Fatalf("creating scan: %v", err)
+	}
+
+	dataset := services.NewDatasetService(client, objectstore.NewLocal(t.TempDir(), ""))
+	handler := NewRecognitionHandler(scans, models.NewRecognitionSettingsFirebase(client), products, dataset)
+	router := newTestRouter("company-a", "user-b")
+	router.POST("/products/scan/:scanId/confirm", handler.Confirm)
+	router.GET("/products/scan/accuracy", handler.Accuracy)
+
+	if w := serve(t, router, http.MethodPost, "/products/scan/"+scanID+"/confirm", gin.H{"product_code": "P404"}); w.Code != http.StatusNotFound {
+		t.Errorf("confirming an unknown product = %d, want 404", w.Code)
+	}
+	if w := serve(t, router, http.MethodPost, "/products/scan/missing/confirm", gin.H{"product_code": "P1"}); w.Code != http.StatusNotFound {
+		t.Errorf("confirming an unknown scan = %d, want 404", w.Code)
+	}
+
+	w := serve(t, router, http.MethodPost, "/products/scan/"+scanID+"/confirm", gin.H{"product_code": "P2"})
+	var scan models.FirebaseScan
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &scan) != nil {
+		t.Fatalf("confirm = %d %s, want 200", w.Code, w.Body.String())
+	}
+	if scan.ConfirmedRank != 2 || scan.ConfirmedSource != services.SourceCNN || scan.ConfirmedBy != "user-b" {
+		t.Errorf("confirmed scan = %+v, want rank 2 from the CNN by user-b", scan)
+	}
+
+	w = serve(t, router, http.MethodGet, "/products/scan/accuracy", nil)
+	var accuracy models.RecognitionAccuracy
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &accuracy) != nil {
+		t.Fatalf("accuracy = %d %s, want 200", w.Code, w.Body.String())
+	}
+	if accuracy.Confirmed != 1 || accuracy.Top1 != 0 || accuracy.TopK != 1 {
+		t.Errorf("accuracy = %+v, want one scan confirmed as its second candidate", accuracy)
+	}
+	if w := serve(t, router, http.MethodGet, "/products/scan/accuracy?from=yesterday", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("accuracy with a bad date = %d, want 400", w.Code)
+	}
+}
+
+func TestRecognitionSettingsEndpoints(t *testing.T) {
+	client := firestoretest.New(t)
+	handler := NewRecognitionHandler(nil, models.NewRecognitionSettingsFirebase(client), nil, nil)
+	router := newTestRouter("company-a", "user-a")
+	router.GET("/products/recognition/settings", handler.GetSettings)
+	router.PUT("/products/recognition/settings", handler.UpdateSettings)
+
+	w := serve(t, router, http.MethodGet, "/products/recognition/settings", nil)
+	var settings models.RecognitionSettings
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &settings) != nil || settings.CNNThreshold != models.DefaultRecognitionSettings.CNNThreshold {
+		t.Fatalf("GET = %d %s, want the defaults", w.Code, w.Body.String())
+	}
+
+	for _, invalid := range []gin.H{
+		{"visual_threshold": 1.5, "cnn_threshold": 0.5, "candidates": 5},
+		{"visual_threshold": 0.9, "cnn_threshold": 0.5, "candidates": 50},
+		{"visual_threshold": 0.9, "candidates": 5},
+	} {
+		if w := serve(t, router, http.MethodPut, "/products/recognition/settings", invalid); w.Code != http.StatusBadRequest {
+			t.Errorf("PUT %v = %d, want 400", invalid, w.Code)
+		}
+	}
+
+	w = serve(t, router, http.MethodPut, "/products/recognition/settings", gin.H{"visual_threshold": 0.85, "cnn_threshold": 0.7, "candidates": 3})
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &settings) != nil {
+		t.Fatalf("PUT = %d %s, want 200", w.Code, w.Body.String())
+	}
+	if settings.VisualThreshold != 0.85 || settings.CNNThreshold != 0.7 || settings.Candidates != 3 || w.Header().Get("ETag") == "" {
+		t.Errorf("PUT = %+v with ETag %q, want the new settings with an ETag", settings, w.Header().Get("ETag"))
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
 )
 
 // RegionHandler handles HTTP requests for regions
@@ -44,6 +45,7 @@ func (h *RegionHandler) Get(c *gin.Context) {
 		return
 	}
 
+	setETag(c)
 	c.JSON(http.StatusOK, region)
 }
 
@@ -81,10 +83,46 @@ func (h *RegionHandler) Update(c *gin.Context) {
 
 	region.ID = id
 	if err := h.regionFirebase.Update(c.Request.Context(), id, &region); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c)
+	c.JSON(http.StatusOK, region)
+}
+
+// Patch handles PATCH requests to partially update a region
+func (h *RegionHandler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Region ID is required"})
+		return
+	}
+
+	region, err := h.regionFirebase.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := applyPatch(c, region, &request.NewRegionRequest{}); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	region.ID = id
+	if err := h.regionFirebase.Update(c.Request.Context(), id, region); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
 	c.JSON(http.StatusOK, region)
 }
 
@@ -97,6 +135,9 @@ func (h *RegionHandler) Delete(c *gin.Context) {
 	}
 
 	if err := h.regionFirebase.Delete(c.Request.Context(), id); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
 )
 
 // RoleHandler handles HTTP requests for roles
@@ -44,6 +45,7 @@ func (h *RoleHandler) Get(c *gin.Context) {
 		return
 	}
 
+	setETag(c)
 	c.JSON(http.StatusOK, role)
 }
 
@@ -81,10 +83,46 @@ func (h *RoleHandler) Update(c *gin.Context) {
 
 	role.ID = id
 	if err := h.roleFirebase.Update(c.Request.Context(), id, &role); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c)
+	c.JSON(http.StatusOK, role)
+}
+
+// Patch handles PATCH requests to partially update a role
+func (h *RoleHandler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role ID is required"})
+		return
+	}
+
+	role, err := h.roleFirebase.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := applyPatch(c, role, &request.NewRoleRequest{}); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	role.ID = id
+	if err := h.roleFirebase.Update(c.Request.Context(), id, role); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
 	c.JSON(http.StatusOK, role)
 }
 
@@ -97,6 +135,9 @@ func (h *RoleHandler) Delete(c *gin.Context) {
 	}
 
 	if err := h.roleFirebase.Delete(c.Request.Context(), id); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
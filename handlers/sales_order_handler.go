@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
 	"github.com/nirshpaa/godam-backend/types"
 )
 
@@ -50,6 +51,7 @@ func (h *SalesOrderHandler) Get(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
+	setETag(c)
 	c.JSON(http.StatusOK, salesOrder)
 }
 
@@ -62,6 +64,9 @@ func (h *SalesOrderHandler) Create(c *gin.Context) {
 
 	err := h.salesOrderModel.Create(c.Request.Context(), salesOrder)
 	if err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -84,10 +89,45 @@ func (h *SalesOrderHandler) Update(c *gin.Context) {
 
 	salesOrder.Code = id
 	if err := h.salesOrderModel.Update(c.Request.Context(), id, salesOrder); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c)
+	c.JSON(http.StatusOK, salesOrder)
+}
+
+func (h *SalesOrderHandler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sales Order ID is required"})
+		return
+	}
+
+	salesOrder, err := h.salesOrderModel.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := applyPatch(c, salesOrder, &request.SalesOrderRequest{}); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	salesOrder.ID = id
+	if err := h.salesOrderModel.Update(c.Request.Context(), id, *salesOrder); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
 	c.JSON(http.StatusOK, salesOrder)
 }
 
@@ -99,6 +139,9 @@ func (h *SalesOrderHandler) Delete(c *gin.Context) {
 	}
 
 	if err := h.salesOrderModel.Delete(c.Request.Context(), id); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -179,6 +222,9 @@ func (h *SalesOrderHandler) UpdateProductStock(c *gin.Context) {
 
 	// Save the updated product
 	if err := h.productModel.UpdateStock(c.Request.Context(), request.ProductID, product.MinimumStock); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
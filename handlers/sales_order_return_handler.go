@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
 )
 
 type SalesOrderReturnHandler struct {
@@ -38,6 +39,7 @@ func (h *SalesOrderReturnHandler) Get(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
+	setETag(c)
 	c.JSON(http.StatusOK, salesOrderReturn)
 }
 
@@ -50,6 +52,9 @@ func (h *SalesOrderReturnHandler) Create(c *gin.Context) {
 
 	id, err := h.salesOrderReturnFirebase.Create(c.Request.Context(), &salesOrderReturn)
 	if err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -73,10 +78,46 @@ func (h *SalesOrderReturnHandler) Update(c *gin.Context) {
 
 	salesOrderReturn.ID = id
 	if err := h.salesOrderReturnFirebase.Update(c.Request.Context(), id, &salesOrderReturn); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c)
+	c.JSON(http.StatusOK, salesOrderReturn)
+}
+
+// Patch handles PATCH requests to partially update a sales order return
+func (h *SalesOrderReturnHandler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sales Order Return ID is required"})
+		return
+	}
+
+	salesOrderReturn, err := h.salesOrderReturnFirebase.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := applyPatch(c, salesOrderReturn, &request.NewSalesOrderReturnRequest{}); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	salesOrderReturn.ID = id
+	if err := h.salesOrderReturnFirebase.Update(c.Request.Context(), id, salesOrderReturn); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
 	c.JSON(http.StatusOK, salesOrderReturn)
 }
 
@@ -88,6 +129,9 @@ func (h *SalesOrderReturnHandler) Delete(c *gin.Context) {
 	}
 
 	if err := h.salesOrderReturnFirebase.Delete(c.Request.Context(), id); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
 )
 
 // SalesmanHandler handles HTTP requests for salesmen
@@ -44,6 +45,7 @@ func (h *SalesmanHandler) Get(c *gin.Context) {
 		return
 	}
 
+	setETag(c)
 	c.JSON(http.StatusOK, salesman)
 }
 
@@ -81,10 +83,46 @@ func (h *SalesmanHandler) Update(c *gin.Context) {
 
 	salesman.ID = id
 	if err := h.salesmanFirebase.Update(c.Request.Context(), id, &salesman); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c)
+	c.JSON(http.StatusOK, salesman)
+}
+
+// Patch handles PATCH requests to partially update a salesman
+func (h *SalesmanHandler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Salesman ID is required"})
+		return
+	}
+
+	salesman, err := h.salesmanFirebase.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := applyPatch(c, salesman, &request.NewSalesmanRequest{}); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	salesman.ID = id
+	if err := h.salesmanFirebase.Update(c.Request.Context(), id, salesman); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
 	c.JSON(http.StatusOK, salesman)
 }
 
@@ -97,6 +135,9 @@ func (h *SalesmanHandler) Delete(c *gin.Context) {
 	}
 
 	if err := h.salesmanFirebase.Delete(c.Request.Context(), id); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
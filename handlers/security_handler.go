@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
+)
+
+// SecurityHandler handles HTTP requests for the security log and its alert rules
+type SecurityHandler struct {
+	security *models.SecurityLog
+}
+
+// NewSecurityHandler creates a new SecurityHandler instance
+func NewSecurityHandler(security *models.SecurityLog) *SecurityHandler {
+	return &SecurityHandler{
+		security: security,
+	}
+}
+
+// Events handles GET requests to search the security events of the current
+// company by ?type=, ?ip=, ?user=, ?from=, ?to= and ?limit=
+func (h *SecurityHandler) Events(c *gin.Context) {
+	base, err := auditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := models.SecurityFilter{
+		CompanyID: base.CompanyID,
+		Type:      c.Query("type"),
+		IP:        c.Query("ip"),
+		UserID:    c.Query("user"),
+		From:      base.From,
+		To:        base.To,
+		Limit:     base.Limit,
+	}
+	if filter.Type != "" && !knownSecurityEvent(filter.Type) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid type %q", filter.Type)})
+		return
+	}
+
+	events, err := h.security.List(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+// Alerts handles GET requests to list the security alerts raised for the current company
+func (h *SecurityHandler) Alerts(c *gin.Context) {
+	limit := defaultAuditLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid limit %q", value)})
+			return
+		}
+		if parsed < maxAuditLimit {
+			limit = parsed
+		} else {
+			limit = maxAuditLimit
+		}
+	}
+
+	alerts, err := h.security.ListAlerts(c.Request.Context(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, alerts)
+}
+
+// ListRules handles GET requests to list the security alert rules of the current company
+func (h *SecurityHandler) ListRules(c *gin.Context) {
+	rules, err := h.security.ListRules(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// CreateRule handles POST requests to create a security alert rule
+func (h *SecurityHandler) CreateRule(c *gin.Context) {
+	var req request.SecurityAlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := req.Transform()
+	if _, err := h.security.CreateRule(c.Request.Context(), rule); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateRule handles PUT requests to change a security alert rule
+func (h *SecurityHandler) UpdateRule(c *gin.Context) {
+	var req request.SecurityAlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := req.Transform()
+	rule.ID = c.Param("id")
+	if err := h.security.UpdateRule(c.Request.Context(), rule.ID, rule); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteRule handles DELETE requests to remove a security alert rule
+func (h *SecurityHandler) DeleteRule(c *gin.Context) {
+	if err := h.security.DeleteRule(c.Request.Context(), c.Param("id")); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Security alert rule deleted successfully"})
+}
+
+func knownSecurityEvent(eventType string) bool {
+	for _, known := range models.SecurityEventTypes {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+	"github.com/nirshpaa/godam-backend/models"
+)
+
+func TestSecurityRuleEventTypes(t *testing.T) {
+	handler := NewSecurityHandler(models.NewSecurityLog(firestoretest.New(t), nil))
+	router := newTestRouter("company-a", "user-a")
+	router.POST("/security/alert-rules", handler.CreateRule)
+
+	rule := func(eventType string) gin.H {
+		return gin.H{
+			"name":           "Repeated failures",
+			"event_type":     eventType,
+			"threshold":      5,
+			"window_minutes": 10,
+			"group_by":       "ip",
+			"recipients":     []string{"security@example.com"},
+		}
+	}
+
+	if w := serve(t, router, http.MethodPost, "/security/alert-rules", rule(models.SecurityAuthFailure)); w.Code != http.StatusBadRequest {
+		t.Errorf("company rule watching auth failures = %d, want 400", w.Code)
+	}
+	if w := serve(t, router, http.MethodPost, "/security/alert-rules", rule(models.SecurityPermissionDenied)); w.Code != http.StatusCreated {
+		t.Errorf("company rule watching permission denials = %d, want 201: %s", w.Code, w.Body)
+	}
+}
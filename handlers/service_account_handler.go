@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
+)
+
+// ServiceAccountHandler handles HTTP requests for service accounts and their API keys
+type ServiceAccountHandler struct {
+	serviceAccounts *models.ServiceAccountFirebase
+}
+
+// NewServiceAccountHandler creates a new ServiceAccountHandler instance
+func NewServiceAccountHandler(serviceAccounts *models.ServiceAccountFirebase) *ServiceAccountHandler {
+	return &ServiceAccountHandler{
+		serviceAccounts: serviceAccounts,
+	}
+}
+
+// List handles GET requests to list the service accounts of the current company
+func (h *ServiceAccountHandler) List(c *gin.Context) {
+	accounts, err := h.serviceAccounts.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, accounts)
+}
+
+// Get handles GET requests for a service account and its keys
+func (h *ServiceAccountHandler) Get(c *gin.Context) {
+	id := c.Param("id")
+	account, err := h.serviceAccounts.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	keys, err := h.serviceAccounts.ListKeys(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
+	c.JSON(http.StatusOK, gin.H{
+		"service_account": account,
+		"keys":            keys,
+	})
+}
+
+// Create handles POST requests to create a service account with its first API
+// key. The key is only returned in this response.
+func (h *ServiceAccountHandler) Create(c *gin.Context) {
+	var req request.ServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkScopes(c, req.Scopes) {
+		return
+	}
+
+	account := req.Transform()
+	id, err := h.serviceAccounts.Create(c.Request.Context(), account)
+	if err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, apiKey, err := h.serviceAccounts.CreateKey(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"service_account": account,
+		"key":             key,
+		"api_key":         apiKey,
+	})
+}
+
+// Update handles PUT requests to rename, rescope or disable a service account
+func (h *ServiceAccountHandler) Update(c *gin.Context) {
+	id := c.Param("id")
+
+	var req request.ServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkScopes(c, req.Scopes) {
+		return
+	}
+
+	account := req.Transform()
+	if err := h.serviceAccounts.Update(c.Request.Context(), id, account); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	account.ID = id
+	setETag(c)
+	c.JSON(http.StatusOK, account)
+}
+
+// Delete handles DELETE requests to revoke the keys of a service account and remove it
+func (h *ServiceAccountHandler) Delete(c *gin.Context) {
+	if err := h.serviceAccounts.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Service account deleted successfully"})
+}
+
+// CreateKey handles POST requests to issue another API key for a service
+// account. With ?rotate=true the account's other keys are revoked.
+func (h *ServiceAccountHandler) CreateKey(c *gin.Context) {
+	create := h.serviceAccounts.CreateKey
+	if c.Query("rotate") == "true" {
+		create = h.serviceAccounts.RotateKey
+	}
+
+	key, apiKey, err := create(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"key":     key,
+		"api_key": apiKey,
+	})
+}
+
+// RevokeKey handles DELETE requests to revoke an API key
+func (h *ServiceAccountHandler) RevokeKey(c *gin.Context) {
+	if err := h.serviceAccounts.RevokeKey(c.Request.Context(), c.Param("id"), c.Param("keyId")); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
+
+// checkScopes responds with 422 unless every scope is a resource:action
+// permission the caller holds, so service accounts cannot exceed their creator
+func checkScopes(c *gin.Context, scopes []string) bool {
+	grant := callerGrant(c)
+	for _, scope := range scopes {
+		parts := strings.Split(scope, ":")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid scope " + scope})
+			return false
+		}
+		if grant == nil || !grant.Allows(scope) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "You cannot grant scope " + scope})
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+	"github.com/nirshpaa/godam-backend/models"
+)
+
+func newServiceAccountRouter(t *testing.T, role string) *gin.Engine {
+	handler := NewServiceAccountHandler(models.NewServiceAccountFirebase(firestoretest.New(t)))
+	router := newTestRouter("company-a", "caller-1")
+	grant := &models.Grant{Role: role, Permissions: models.DefaultRoles[role]}
+	router.Use(func(c *gin.Context) {
+		c.Set("grant", grant)
+		c.Next()
+	})
+	router.GET("/service-accounts/:id", handler.Get)
+	router.POST("/service-accounts", handler.Create)
+	router.DELETE("/service-accounts/:id", handler.Delete)
+	return router
+}
+
+func TestServiceAccountCreateScopes(t *testing.T) {
+	router := newServiceAccountRouter(t, models.RoleClerk)
+	tests := []struct {
+		scopes []string
+		want   int
+	}{
+		{[]string{"products:read"}, http.StatusCreated},
+		{[]string{"products"}, http.StatusUnprocessableEntity},
+		{[]string{"customers:update"}, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		w := serve(t, router, http.MethodPost, "/service-accounts", gin.H{"name": "POS", "scopes": tt.scopes})
+		if w.Code != tt.want {
+			t.Errorf("scopes %v: status = %d, want %d: %s", tt.scopes, w.Code, tt.want, w.Body)
+		}
+	}
+}
+
+func TestServiceAccountDeleteWithVersion(t *testing.T) {
+	router := newServiceAccountRouter(t, models.RoleOwner)
+	w := serve(t, router, http.MethodPost, "/service-accounts", gin.H{"name": "POS", "scopes": []string{"products:read"}})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d: %s", w.Code, w.Body)
+	}
+	var created struct {
+		ServiceAccount models.FirebaseServiceAccount `json:"service_account"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	path := "/service-accounts/" + created.ServiceAccount.ID
+
+	w = serve(t, router, http.MethodGet, path, nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("get status = %d, ETag %q", w.Code, etag)
+	}
+
+	// The account's ETag applies to the account, not to the keys it revokes
+	if w := serve(t, router, http.MethodDelete, path, nil, "If-Match", etag); w.Code != http.StatusOK {
+		t.Fatalf("delete status = %d: %s", w.Code, w.Body)
+	}
+	if w := serve(t, router, http.MethodGet, path, nil); w.Code != http.StatusNotFound {
+		t.Errorf("get after delete status = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/libraries/imageproc"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
+	"github.com/nirshpaa/godam-backend/services"
+)
+
+// ShelfAuditHandler handles HTTP requests for planograms and shelf photo audits
+type ShelfAuditHandler struct {
+	audits  *models.ShelfAuditFirebase
+	service *services.ShelfAuditService
+}
+
+// NewShelfAuditHandler creates a new ShelfAuditHandler instance
+func NewShelfAuditHandler(audits *models.ShelfAuditFirebase, service *services.ShelfAuditService) *ShelfAuditHandler {
+	return &ShelfAuditHandler{
+		audits:  audits,
+		service: service,
+	}
+}
+
+// GetPlanogram handles GET requests for the planogram of a shelf
+func (h *ShelfAuditHandler) GetPlanogram(c *gin.Context) {
+	plan, err := h.service.GetPlanogram(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeShelfAuditError(c, err)
+		return
+	}
+	if plan == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shelve has no planogram"})
+		return
+	}
+
+	setETag(c)
+	c.JSON(http.StatusOK, plan)
+}
+
+// SavePlanogram handles PUT requests to create or replace the planogram of a shelf
+func (h *ShelfAuditHandler) SavePlanogram(c *gin.Context) {
+	var req request.PlanogramRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan := req.Transform(c.Param("id"))
+	if err := h.service.SavePlanogram(c.Request.Context(), plan); err != nil {
+		writeShelfAuditError(c, err)
+		return
+	}
+
+	setETag(c)
+	c.JSON(http.StatusOK, plan)
+}
+
+// Audit handles POST requests with a multipart "image" photo of a shelf. The
+// photo is split into "columns" by "rows" tiles, one facing each.
+func (h *ShelfAuditHandler) Audit(c *gin.Context) {
+	columns, err := gridParam(c, "columns", services.DefaultAuditColumns)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rows, err := gridParam(c, "rows", services.DefaultAuditRows)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	data, ok := readImageUpload(c)
+	if !ok {
+		return
+	}
+
+	audit, err := h.service.Audit(c.Request.Context(), c.Param("id"), data, columns, rows)
+	if err != nil {
+		writeShelfAuditError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"audit":         audit,
+		"discrepancies": audit.Discrepancies(),
+	})
+}
+
+// ListAudits handles GET requests to list the audits of a shelf
+func (h *ShelfAuditHandler) ListAudits(c *gin.Context) {
+	audits, err := h.service.ListAudits(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeShelfAuditError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, audits)
+}
+
+// GetAudit handles GET requests to fetch a shelf audit by ID
+func (h *ShelfAuditHandler) GetAudit(c *gin.Context) {
+	audit, err := h.audits.GetAudit(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeShelfAuditError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"audit":         audit,
+		"discrepancies": audit.Discrepancies(),
+	})
+}
+
+// SeedStockCount handles POST requests to open a stock count of the
+// discrepancies of an audit
+func (h *ShelfAuditHandler) SeedStockCount(c *gin.Context) {
+	count, err := h.service.SeedStockCount(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeShelfAuditError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, count)
+}
+
+// gridParam reads a grid dimension from the form or query
+func gridParam(c *gin.Context, key string, fallback int) (int, error) {
+	value := batchParam(c, key)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > services.MaxAuditGrid {
+		return 0, services.ErrAuditGrid
+	}
+	return n, nil
+}
+
+func writeShelfAuditError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAuditGrid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, imageproc.ErrUnsupportedFormat), errors.Is(err, imageproc.ErrInvalidImage), errors.Is(err, imageproc.ErrTooLarge):
+		writeImageError(c, err)
+	default:
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
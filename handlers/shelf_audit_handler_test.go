@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/services"
+)
+
+func TestShelfRoutesForeignShelf(t *testing.T) {
+	client := firestoretest.New(t)
+	shelves := models.NewShelveFirebase(client)
+	audits := models.NewShelfAuditFirebase(client)
+	products, _ := models.NewProductFirebase(client)
+
+	ctxA := models.WithCompany(context.Background(), "company-a")
+	ctxB := models.WithCompany(context.Background(), "company-b")
+	own, err := shelves.Create(ctxA, &models.ShelveFirebaseModel{Name: "Aisle 1"})
+	if err != nil {
+		t.Fatalf("creating shelf: %v", err)
+	}
+	foreign, err := shelves.Create(ctxB, &models.ShelveFirebaseModel{Name: "Aisle 9"})
+	if err != nil {
+		t.Fatalf("creating shelf: %v", err)
+	}
+	if _, err := products.Create(ctxA, &models.FirebaseProduct{Code: "P1", Name: "Tea"}, nil); err != nil {
+		t.Fatalf("creating product: %v", err)
+	}
+
+	// Both shelves have a planogram, so only the company tells them apart
+	for ctx, id := range map[context.Context]string{ctxA: own, ctxB: foreign} {
+		if err := audits.SavePlanogram(ctx, &models.FirebasePlanogram{ShelveID: id}); err != nil {
+			t.Fatalf("saving planogram: %v", err)
+		}
+	}
+
+	service := services.NewShelfAuditService(nil, nil, shelves, audits, nil)
+	shelfHandler := NewShelfAuditHandler(audits, service)
+	labelHandler := NewLabelHandler(models.NewBarcodeFirebase(client), products, shelves)
+	router := newTestRouter("company-a", "user-a")
+	router.GET("/shelves/:id/planogram", shelfHandler.GetPlanogram)
+	router.PUT("/shelves/:id/planogram", shelfHandler.SavePlanogram)
+	router.GET("/shelves/:id/audits", shelfHandler.ListAudits)
+	router.POST("/labels/shelves", labelHandler.ShelfLabels)
+	router.POST("/labels/products", labelHandler.ProductLabels)
+
+	tests := []struct {
+		name    string
+		method  string
+		request func(shelf string) (string, gin.H)
+	}{
+		{"planogram", http.MethodGet, func(shelf string) (string, gin.H) {
+			return "/shelves/" + shelf + "/planogram", nil
+		}},
+		{"saving a planogram", http.MethodPut, func(shelf string) (string, gin.H) {
+			return "/shelves/" + shelf + "/planogram", gin.H{"slots": []gin.H{{"product_code": "P1", "facings": 2}}}
+		}},
+		{"audits", http.MethodGet, func(shelf string) (string, gin.H) {
+			return "/shelves/" + shelf + "/audits", nil
+		}},
+		{"shelf labels", http.MethodPost, func(shelf string) (string, gin.H) {
+			return "/labels/shelves", gin.H{"items": []gin.H{{"id": shelf, "quantity": 1}}}
+		}},
+		{"product labels on the shelf", http.MethodPost, func(shelf string) (string, gin.H) {
+			return "/labels/products", gin.H{"items": []gin.H{{"code": "P1", "shelf_id": shelf, "quantity": 1}}}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, body := tt.request(foreign)
+			if w := serve(t, router, tt.method, path, body); w.Code != http.StatusNotFound {
+				t.Errorf("%s %s with another company's shelf = %d, want 404: %s", tt.method, path, w.Code, w.Body)
+			}
+			path, body = tt.request(own)
+			if w := serve(t, router, tt.method, path, body); w.Code != http.StatusOK {
+				t.Errorf("%s %s with the company's shelf = %d, want 200: %s", tt.method, path, w.Code, w.Body)
+			}
+		})
+	}
+}
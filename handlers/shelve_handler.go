@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
 )
 
 // ShelveHandler handles HTTP requests for shelves
@@ -44,6 +45,7 @@ func (h *ShelveHandler) Get(c *gin.Context) {
 		return
 	}
 
+	setETag(c)
 	c.JSON(http.StatusOK, shelve)
 }
 
@@ -81,10 +83,46 @@ func (h *ShelveHandler) Update(c *gin.Context) {
 
 	shelve.ID = id
 	if err := h.shelveFirebase.Update(c.Request.Context(), id, &shelve); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c)
+	c.JSON(http.StatusOK, shelve)
+}
+
+// Patch handles PATCH requests to partially update a shelve
+func (h *ShelveHandler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Shelve ID is required"})
+		return
+	}
+
+	shelve, err := h.shelveFirebase.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := applyPatch(c, shelve, &request.NewShelveRequest{}); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	shelve.ID = id
+	if err := h.shelveFirebase.Update(c.Request.Context(), id, shelve); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
 	c.JSON(http.StatusOK, shelve)
 }
 
@@ -97,6 +135,9 @@ func (h *ShelveHandler) Delete(c *gin.Context) {
 	}
 
 	if err := h.shelveFirebase.Delete(c.Request.Context(), id); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
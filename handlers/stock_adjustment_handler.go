@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
+)
+
+// StockAdjustmentHandler handles HTTP requests for stock adjustments
+type StockAdjustmentHandler struct {
+	adjustments *models.StockAdjustmentFirebase
+}
+
+// NewStockAdjustmentHandler creates a new StockAdjustmentHandler instance
+func NewStockAdjustmentHandler(adjustments *models.StockAdjustmentFirebase) *StockAdjustmentHandler {
+	return &StockAdjustmentHandler{
+		adjustments: adjustments,
+	}
+}
+
+// List handles GET requests to list the stock adjustments of the current company
+func (h *StockAdjustmentHandler) List(c *gin.Context) {
+	adjustments, err := h.adjustments.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, adjustments)
+}
+
+// Create handles POST requests to adjust the stock of a product. Adjustments
+// that need approval are accepted with 202 and posted once approved.
+func (h *StockAdjustmentHandler) Create(c *gin.Context) {
+	var req request.StockAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adjustment := req.Transform()
+	if err := h.adjustments.Create(c.Request.Context(), adjustment); err != nil {
+		if errors.Is(err, models.ErrInsufficientStock) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if adjustment.ApprovalStatus == models.DocumentPendingApproval {
+		c.JSON(http.StatusAccepted, adjustment)
+		return
+	}
+	c.JSON(http.StatusCreated, adjustment)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
+)
+
+// StockCountHandler handles HTTP requests for stock counts
+type StockCountHandler struct {
+	counts *models.StockCountFirebase
+}
+
+// NewStockCountHandler creates a new StockCountHandler instance
+func NewStockCountHandler(counts *models.StockCountFirebase) *StockCountHandler {
+	return &StockCountHandler{
+		counts: counts,
+	}
+}
+
+// List handles GET requests to list stock counts, optionally by ?status=
+func (h *StockCountHandler) List(c *gin.Context) {
+	counts, err := h.counts.List(c.Request.Context(), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, counts)
+}
+
+// Get handles GET requests to fetch a stock count by ID
+func (h *StockCountHandler) Get(c *gin.Context) {
+	count, err := h.counts.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeStockCountError(c, err)
+		return
+	}
+
+	setETag(c)
+	c.JSON(http.StatusOK, count)
+}
+
+// SetCounts handles PUT requests recording counted quantities
+func (h *StockCountHandler) SetCounts(c *gin.Context) {
+	var req request.StockCountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	count, err := h.counts.SetCounts(c.Request.Context(), c.Param("id"), req.Counts())
+	if err != nil {
+		writeStockCountError(c, err)
+		return
+	}
+
+	setETag(c)
+	c.JSON(http.StatusOK, count)
+}
+
+// Complete handles POST requests to complete a stock count and adjust the
+// stock of the counted products
+func (h *StockCountHandler) Complete(c *gin.Context) {
+	count, err := h.counts.Complete(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeStockCountError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, count)
+}
+
+func writeStockCountError(c *gin.Context, err error) {
+	if errors.Is(err, models.ErrStockCountClosed) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if writeModelError(c, err) {
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
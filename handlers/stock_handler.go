@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/models"
+)
+
+// StockHandler handles HTTP requests for the stock held in each branch
+type StockHandler struct {
+	stock *models.StockFirebase
+}
+
+// NewStockHandler creates a new StockHandler instance
+func NewStockHandler(stock *models.StockFirebase) *StockHandler {
+	return &StockHandler{
+		stock: stock,
+	}
+}
+
+// List handles GET requests to list the stock of the current company by
+// branch, optionally of one ?product_code=, with totals across the branches
+// the caller may see
+func (h *StockHandler) List(c *gin.Context) {
+	stock, err := h.stock.List(c.Request.Context(), c.Query("product_code"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"branches": stock,
+		"totals":   models.StockTotals(stock),
+	})
+}
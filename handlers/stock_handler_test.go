@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+	"github.com/nirshpaa/godam-backend/models"
+)
+
+func TestStockListByBranch(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := context.Background()
+	for _, line := range []models.FirebaseStock{
+		{CompanyID: "company-a", BranchID: "branch-1", ProductCode: "P1", Quantity: 3},
+		{CompanyID: "company-a", BranchID: "branch-2", ProductCode: "P1", Quantity: 4},
+		{CompanyID: "company-a", BranchID: "branch-3", ProductCode: "P1", Quantity: 50},
+		{CompanyID: "company-a", BranchID: "branch-1", ProductCode: "P2", Quantity: 1},
+		{CompanyID: "company-b", BranchID: "branch-1", ProductCode: "P1", Quantity: 100},
+	} {
+		id := models.StockID(line.CompanyID, line.BranchID, line.ProductCode)
+		if _, err := client.Collection("stock").Doc(id).Set(ctx, line); err != nil {
+			t.Fatalf("seeding stock: %v", err)
+		}
+	}
+
+	router := newTestRouter("company-a", "manager-1")
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(models.WithBranches(c.Request.Context(), []string{"branch-1", "branch-2"}))
+		c.Next()
+	})
+	router.GET("/stock", NewStockHandler(models.NewStockFirebase(client)).List)
+
+	w := serve(t, router, http.MethodGet, "/stock?product_code=P1", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var body struct {
+		Branches []models.FirebaseStock `json:"branches"`
+		Totals   map[string]float64     `json:"totals"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(body.Branches) != 2 || body.Totals["P1"] != 7 || len(body.Totals) != 1 {
+		t.Errorf("stock = %+v, want P1 in branch-1 and branch-2 totalling 7", body)
+	}
+}
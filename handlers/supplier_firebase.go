@@ -50,6 +50,7 @@ func (h *SupplierHandler) Get(c *gin.Context) {
 
 	var res response.SupplierResponse
 	res.Transform(supplier)
+	setETag(c)
 	c.JSON(http.StatusOK, res)
 }
 
@@ -88,12 +89,45 @@ func (h *SupplierHandler) Update(c *gin.Context) {
 
 	supplier = req.Transform(supplier)
 	if err := h.supplier.Update(c.Request.Context(), supplier); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	var res response.SupplierResponse
 	res.Transform(supplier)
+	setETag(c)
+	c.JSON(http.StatusOK, res)
+}
+
+// Patch partially updates an existing supplier
+func (h *SupplierHandler) Patch(c *gin.Context) {
+	id := c.Param("id")
+	supplier, err := h.supplier.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := applyPatch(c, supplier, &request.NewSupplierRequest{}); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	supplier.ID = id
+	if err := h.supplier.Update(c.Request.Context(), supplier); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var res response.SupplierResponse
+	res.Transform(supplier)
+	setETag(c)
 	c.JSON(http.StatusOK, res)
 }
 
@@ -101,6 +135,9 @@ func (h *SupplierHandler) Update(c *gin.Context) {
 func (h *SupplierHandler) Delete(c *gin.Context) {
 	id := c.Param("id")
 	if err := h.supplier.Delete(c.Request.Context(), id); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/payloads/request"
+	"github.com/nirshpaa/godam-backend/services"
+)
+
+// trainingTokenHeader carries the job token on requests of the training service
+const trainingTokenHeader = "X-Training-Token"
+
+// TrainingHandler handles HTTP requests for training jobs and model versions
+type TrainingHandler struct {
+	training *models.TrainingFirebase
+	settings *models.RecognitionSettingsFirebase
+	service  *services.TrainingService
+	dataset  *services.DatasetService
+}
+
+// NewTrainingHandler creates a new TrainingHandler instance
+func NewTrainingHandler(training *models.TrainingFirebase, settings *models.RecognitionSettingsFirebase, service *services.TrainingService, dataset *services.DatasetService) *TrainingHandler {
+	return &TrainingHandler{
+		training: training,
+		settings: settings,
+		service:  service,
+		dataset:  dataset,
+	}
+}
+
+// ListJobs handles GET requests to list training jobs, optionally by ?status=
+func (h *TrainingHandler) ListJobs(c *gin.Context) {
+	jobs, err := h.training.ListJobs(c.Request.Context(), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, jobs)
+}
+
+// GetJob handles GET requests to fetch a training job by ID
+func (h *TrainingHandler) GetJob(c *gin.Context) {
+	job, err := h.training.GetJob(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// CreateJob handles POST requests to queue a training job
+func (h *TrainingHandler) CreateJob(c *gin.Context) {
+	var req request.TrainingJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := h.service.Trigger(c.Request.Context(), req.SnapshotID)
+	if err != nil {
+		writeTrainingError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, job)
+}
+
+// CancelJob handles POST requests to cancel a queued or running training job
+func (h *TrainingHandler) CancelJob(c *gin.Context) {
+	job, err := h.service.Cancel(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeTrainingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// ListModels handles GET requests to list model versions with the one serving recognition
+func (h *TrainingHandler) ListModels(c *gin.Context) {
+	ctx := c.Request.Context()
+	versions, err := h.training.ListModels(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	settings, err := h.settings.Get(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"models":           versions,
+		"model_version_id": settings.ModelVersionID,
+		"model_pinned":     settings.ModelPinned,
+	})
+}
+
+// PinModel handles POST requests to serve a model version until unpinned,
+// which is also how a company rolls back
+func (h *TrainingHandler) PinModel(c *gin.Context) {
+	if err := h.service.Pin(c.Request.Context(), c.Param("id")); err != nil {
+		writeTrainingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Model version pinned"})
+}
+
+// UnpinModel handles DELETE requests to go back to serving the newest model version
+func (h *TrainingHandler) UnpinModel(c *gin.Context) {
+	if err := h.service.Unpin(c.Request.Context()); err != nil {
+		writeTrainingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Model version unpinned"})
+}
+
+// Callback handles POST requests of the training service reporting on a job
+func (h *TrainingHandler) Callback(c *gin.Context) {
+	ctx, _, err := h.service.Authenticate(c.Request.Context(), c.Param("id"), c.GetHeader(trainingTokenHeader))
+	if err != nil {
+		writeTrainingError(c, err)
+		return
+	}
+
+	var req request.TrainingCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := h.service.Callback(ctx, c.Param("id"), req.Transform())
+	if err != nil {
+		writeTrainingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// Dataset handles GET requests of the training service downloading the
+// snapshot a running job trains on
+func (h *TrainingHandler) Dataset(c *gin.Context) {
+	ctx, job, err := h.service.Authenticate(c.Request.Context(), c.Param("id"), c.GetHeader(trainingTokenHeader))
+	if err != nil {
+		writeTrainingError(c, err)
+		return
+	}
+	if job.Status != models.TrainingRunning {
+		writeTrainingError(c, models.ErrTrainingClosed)
+		return
+	}
+
+	snapshot, manifest, err := h.dataset.Manifest(ctx, job.SnapshotID)
+	if err != nil {
+		writeTrainingError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="dataset-v%d.zip"`, snapshot.Version))
+	c.Status(http.StatusOK)
+	if err := h.dataset.Export(c.Request.Context(), c.Writer, manifest); err != nil {
+		log.Printf("Failed to export dataset of training job %s: %v", job.ID, err)
+	}
+}
+
+// writeTrainingError responds with the status matching a training error
+func writeTrainingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrTrainingToken):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrTrainingInProgress), errors.Is(err, models.ErrTrainingClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEmptyDataset):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
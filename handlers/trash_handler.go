@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/models"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TrashHandler handles HTTP requests for soft deleted records
+type TrashHandler struct {
+	trash *models.Trash
+}
+
+// NewTrashHandler creates a new TrashHandler instance
+func NewTrashHandler(trash *models.Trash) *TrashHandler {
+	return &TrashHandler{
+		trash: trash,
+	}
+}
+
+// Page sizes of the trash listing
+const (
+	defaultTrashLimit = 50
+	maxTrashLimit     = 200
+)
+
+// List handles GET requests to list the trashed records of the current company,
+// newest first. Passing the deleted_at of the last record as ?before= returns
+// the next page.
+func (h *TrashHandler) List(c *gin.Context) {
+	filter := models.TrashFilter{
+		CompanyID:  c.GetString("company_id"),
+		Collection: c.Query("collection"),
+		Limit:      defaultTrashLimit,
+	}
+	if before := c.Query("before"); before != "" {
+		t, err := time.Parse(time.RFC3339Nano, before)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid before %q, expected RFC3339", before)})
+			return
+		}
+		filter.Before = t
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid limit %q", limit)})
+			return
+		}
+		filter.Limit = n
+		if filter.Limit > maxTrashLimit {
+			filter.Limit = maxTrashLimit
+		}
+	}
+
+	items, err := h.trash.List(c.Request.Context(), filter)
+	if err != nil {
+		writeTrashError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// Restore handles POST requests to take a record out of the trash
+func (h *TrashHandler) Restore(c *gin.Context) {
+	if err := h.trash.Restore(c.Request.Context(), c.Param("collection"), c.Param("id")); err != nil {
+		writeTrashError(c, err)
+		return
+	}
+
+	setETag(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Record restored successfully"})
+}
+
+// Delete handles DELETE requests to permanently delete a trashed record
+func (h *TrashHandler) Delete(c *gin.Context) {
+	if err := h.trash.Delete(c.Request.Context(), c.Param("collection"), c.Param("id")); err != nil {
+		writeTrashError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Record deleted permanently"})
+}
+
+// writeTrashError maps trash errors to HTTP responses
+func writeTrashError(c *gin.Context, err error) {
+	if writeModelError(c, err) {
+		return
+	}
+
+	var referenced *models.ReferencedError
+	switch {
+	case errors.As(err, &referenced):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "references": referenced.References})
+	case errors.Is(err, models.ErrNotTrashed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrUnknownCollection):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case status.Code(err) == codes.NotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Record not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
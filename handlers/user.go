@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -11,12 +12,14 @@ import (
 type UserHandler struct {
 	firebaseService *services.FirebaseService
 	userFirebase    *models.UserFirebase
+	memberships     *models.MembershipFirebase
 }
 
-func NewUserHandler(firebaseService *services.FirebaseService, userFirebase *models.UserFirebase) *UserHandler {
+func NewUserHandler(firebaseService *services.FirebaseService, userFirebase *models.UserFirebase, memberships *models.MembershipFirebase) *UserHandler {
 	return &UserHandler{
 		firebaseService: firebaseService,
 		userFirebase:    userFirebase,
+		memberships:     memberships,
 	}
 }
 
@@ -51,6 +54,7 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 		return
 	}
 
+	setETag(c)
 	c.JSON(http.StatusOK, user)
 }
 
@@ -86,10 +90,46 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 
 	user.ID = id
 	if err := h.userFirebase.Update(c.Request.Context(), id, &user); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	setETag(c)
+	c.JSON(http.StatusOK, user)
+}
+
+// PatchUser handles PATCH requests to partially update a user
+func (h *UserHandler) PatchUser(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+		return
+	}
+
+	user, err := h.userFirebase.Get(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := applyPatch(c, user, &CreateUserRequest{}); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	user.ID = id
+	if err := h.userFirebase.Update(c.Request.Context(), id, user); err != nil {
+		if writeModelError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setETag(c)
 	c.JSON(http.StatusOK, user)
 }
 
@@ -109,6 +149,8 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
 
+// JoinCompany handles POST requests to ask to join a company. The membership
+// stays pending until a member of the company activates it.
 func (h *UserHandler) JoinCompany(c *gin.Context) {
 	companyID := c.Param("id")
 	if companyID == "" {
@@ -123,11 +165,18 @@ func (h *UserHandler) JoinCompany(c *gin.Context) {
 		return
 	}
 
-	// Update user's company ID in Firestore
-	if err := h.firebaseService.UpdateUserCompany(c.Request.Context(), userID.(string), companyID); err != nil {
+	membership, err := h.memberships.Request(c.Request.Context(), companyID, userID.(string))
+	if err != nil {
+		if errors.Is(err, models.ErrMembershipExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if writeModelError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join company"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Successfully joined company"})
+	c.JSON(http.StatusAccepted, membership)
 }
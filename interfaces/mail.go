@@ -0,0 +1,15 @@
+package interfaces
+
+import "context"
+
+// Mail is a plain text email message
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// MailSender defines the interface for delivering email
+type MailSender interface {
+	Send(ctx context.Context, mail Mail) error
+}
@@ -0,0 +1,16 @@
+package interfaces
+
+import "context"
+
+// Notification is a message for the people responsible for a company
+type Notification struct {
+	CompanyID  string
+	Recipients []string
+	Subject    string
+	Body       string
+}
+
+// Notifier defines the interface for delivering notifications
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/nirshpaa/godam-backend/types"
+)
+
+// Recognizer is one stage of product recognition. It returns at most k
+// candidates ordered by descending score.
+type Recognizer interface {
+	Name() string
+	Recognize(ctx context.Context, scan *types.Scan, k int) ([]types.RecognitionCandidate, error)
+}
@@ -1,16 +1,24 @@
 package interfaces
 
-import "github.com/nirshpaa/godam-backend/types"
+import (
+	"context"
+
+	"github.com/nirshpaa/godam-backend/libraries/imageproc"
+	"github.com/nirshpaa/godam-backend/types"
+)
 
 // FileStorage defines the interface for saving files
 type FileStorage interface {
 	SaveBase64Image(base64Data, path string) (string, error)
 	SaveFile(file interface{}, path string) (string, error)
+	SaveImage(ctx context.Context, data []byte, path string) (*imageproc.Paths, error)
 }
 
 // ImageRecognition defines the interface for image recognition
 type ImageRecognition interface {
-	ProcessImage(imagePath string) (*types.ImageRecognitionResult, error)
+	ProcessImage(ctx context.Context, imagePath string) (*types.ImageRecognitionResult, error)
+	IndexProductImage(ctx context.Context, productCode, imageURL string) error
+	ReindexProductImages(ctx context.Context) (int, error)
 }
 
 type RecognitionResult struct {
@@ -0,0 +1,235 @@
+package barcode
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"sync"
+
+	"github.com/disintegration/imaging"
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/datamatrix"
+	"github.com/makiuchi-d/gozxing/oned"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+// ErrNotFound is returned when no supported barcode could be read from an image
+var ErrNotFound = errors.New("barcode not found")
+
+// Supported symbologies
+const (
+	EAN13      = "ean13"
+	EAN8       = "ean8"
+	UPCA       = "upca"
+	UPCE       = "upce"
+	Code128    = "code128"
+	Code39     = "code39"
+	ITF        = "itf"
+	QRCode     = "qr"
+	DataMatrix = "datamatrix"
+)
+
+var symbologies = map[gozxing.BarcodeFormat]string{
+	gozxing.BarcodeFormat_EAN_13:      EAN13,
+	gozxing.BarcodeFormat_EAN_8:       EAN8,
+	gozxing.BarcodeFormat_UPC_A:       UPCA,
+	gozxing.BarcodeFormat_UPC_E:       UPCE,
+	gozxing.BarcodeFormat_CODE_128:    Code128,
+	gozxing.BarcodeFormat_CODE_39:     Code39,
+	gozxing.BarcodeFormat_ITF:         ITF,
+	gozxing.BarcodeFormat_QR_CODE:     QRCode,
+	gozxing.BarcodeFormat_DATA_MATRIX: DataMatrix,
+}
+
+const (
+	// maxSide caps the longest side of the image a first attempt works on,
+	// since phone photos are far larger than a barcode needs
+	maxSide = 2000
+	// minSide is the longest side below which an enlarged copy is also tried
+	minSide = 1000
+	// itfLength is the length of ITF-14 carton codes. A scan line crossing an
+	// ITF code at an angle can read a shorter code that still passes its
+	// checks, so shorter ITF reads only win when nothing better is found.
+	itfLength = 14
+)
+
+// Box is an axis-aligned rectangle in pixels of the original image
+type Box struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Result is a barcode read from an image
+type Result struct {
+	Value     string `json:"value"`
+	Symbology string `json:"symbology"`
+	Bounds    Box    `json:"bounds"`
+}
+
+// Decoder reads 1D and 2D barcodes from photos. It is safe for concurrent
+// use: gozxing readers keep state between calls, so each Decode takes a set
+// of readers of its own from a pool.
+type Decoder struct {
+	readers sync.Pool
+	hints   map[gozxing.DecodeHintType]interface{}
+}
+
+// NewDecoder creates a Decoder for every supported symbology
+func NewDecoder() *Decoder {
+	formats := make([]gozxing.BarcodeFormat, 0, len(symbologies))
+	for format := range symbologies {
+		formats = append(formats, format)
+	}
+	// Listing UPC-A makes EAN-13 reads with a leading zero come back as UPC-A
+	hints := map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_TRY_HARDER:       true,
+		gozxing.DecodeHintType_POSSIBLE_FORMATS: formats,
+	}
+	return &Decoder{
+		readers: sync.Pool{
+			New: func() interface{} {
+				return []gozxing.Reader{
+					qrcode.NewQRCodeReader(),
+					datamatrix.NewDataMatrixReader(),
+					oned.NewMultiFormatUPCEANReader(hints),
+					oned.NewCode128Reader(),
+					oned.NewCode39Reader(),
+					oned.NewITFReader(),
+				}
+			},
+		},
+		hints: hints,
+	}
+}
+
+// Decode returns the first barcode found in img. The image is tried at
+// several scales and turned by 45 degrees for codes lying diagonally; the 1D
+// readers also try each image turned by 90 degrees.
+func (d *Decoder) Decode(img image.Image) (*Result, error) {
+	bounds := img.Bounds()
+	longest := math.Max(float64(bounds.Dx()), float64(bounds.Dy()))
+	if longest == 0 {
+		return nil, ErrNotFound
+	}
+
+	readers := d.readers.Get().([]gozxing.Reader)
+	defer d.readers.Put(readers)
+
+	var partial *Result
+	found := func(result *Result) bool {
+		if result.Symbology != ITF || len(result.Value) >= itfLength {
+			return true
+		}
+		if partial == nil || len(result.Value) > len(partial.Value) {
+			partial = result
+		}
+		return false
+	}
+
+	for _, scale := range scales(longest) {
+		scaled := img
+		if scale != 1 {
+			scaled = imaging.Resize(img, int(float64(bounds.Dx())*scale), 0, imaging.Lanczos)
+		}
+		toOriginal := func(x, y float64) (float64, float64) {
+			return float64(bounds.Min.X) + x/scale, float64(bounds.Min.Y) + y/scale
+		}
+
+		if result, ok := d.attempt(readers, scaled, toOriginal); ok && found(result) {
+			return result, nil
+		}
+
+		rotated := imaging.Rotate(scaled, 45, color.White)
+		toScaled := unrotate(scaled.Bounds(), rotated.Bounds(), 45)
+		if result, ok := d.attempt(readers, rotated, func(x, y float64) (float64, float64) {
+			return toOriginal(toScaled(x, y))
+		}); ok && found(result) {
+			return result, nil
+		}
+	}
+
+	if partial != nil {
+		return partial, nil
+	}
+	return nil, ErrNotFound
+}
+
+// attempt runs readers over img and maps the points of the first result back
+// to the original image
+func (d *Decoder) attempt(readers []gozxing.Reader, img image.Image, toOriginal func(x, y float64) (float64, float64)) (*Result, bool) {
+	bitmap, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return nil, false
+	}
+
+	for _, reader := range readers {
+		decoded, err := reader.Decode(bitmap, d.hints)
+		reader.Reset()
+		if err != nil {
+			continue
+		}
+		symbology, ok := symbologies[decoded.GetBarcodeFormat()]
+		if !ok {
+			continue
+		}
+		return &Result{
+			Value:     decoded.GetText(),
+			Symbology: symbology,
+			Bounds:    boundingBox(decoded.GetResultPoints(), toOriginal),
+		}, true
+	}
+	return nil, false
+}
+
+// scales lists the scale factors tried for an image whose longest side is
+// longest: a working size, half of it, and double for small images
+func scales(longest float64) []float64 {
+	base := 1.0
+	if longest > maxSide {
+		base = maxSide / longest
+	}
+
+	result := []float64{base, base / 2}
+	if longest*base < minSide {
+		result = append(result, base*2)
+	}
+	return result
+}
+
+// unrotate maps points of an image rotated counter-clockwise by angle degrees
+// with imaging.Rotate back onto the source image
+func unrotate(src, dst image.Rectangle, angle float64) func(x, y float64) (float64, float64) {
+	sin, cos := math.Sincos(math.Pi * angle / 180)
+	srcX, srcY := float64(src.Dx())/2-0.5, float64(src.Dy())/2-0.5
+	dstX, dstY := float64(dst.Dx())/2-0.5, float64(dst.Dy())/2-0.5
+	return func(x, y float64) (float64, float64) {
+		x, y = x-dstX, y-dstY
+		return x*cos - y*sin + srcX, x*sin + y*cos + srcY
+	}
+}
+
+// boundingBox returns the box around the result points. 1D readers report
+// the two ends of the scanned row, so their box is a line across the code.
+func boundingBox(points []gozxing.ResultPoint, toOriginal func(x, y float64) (float64, float64)) Box {
+	if len(points) == 0 {
+		return Box{}
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, point := range points {
+		x, y := toOriginal(point.GetX(), point.GetY())
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+
+	return Box{
+		X:      int(math.Round(minX)),
+		Y:      int(math.Round(minY)),
+		Width:  int(math.Round(maxX-minX)) + 1,
+		Height: int(math.Round(maxY-minY)) + 1,
+	}
+}
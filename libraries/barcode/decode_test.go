@@ -0,0 +1,123 @@
+package barcode
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/datamatrix"
+	"github.com/makiuchi-d/gozxing/oned"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+// render draws a barcode at (200, 150) on a white canvas
+func render(t *testing.T, writer gozxing.Writer, value string, format gozxing.BarcodeFormat, width, height int) image.Image {
+	t.Helper()
+
+	matrix, err := writer.Encode(value, format, width, height, nil)
+	if err != nil {
+		t.Fatalf("encoding %s: %v", value, err)
+	}
+	canvas := image.NewGray(image.Rect(0, 0, width+400, height+300))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(canvas, image.Rect(200, 150, 200+width, 150+height), matrix, image.Point{}, draw.Src)
+	return canvas
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		writer    gozxing.Writer
+		format    gozxing.BarcodeFormat
+		value     string
+		symbology string
+		width     int
+		height    int
+	}{
+		{oned.NewEAN13Writer(), gozxing.BarcodeFormat_EAN_13, "5901234123457", EAN13, 300, 120},
+		{oned.NewEAN8Writer(), gozxing.BarcodeFormat_EAN_8, "96385074", EAN8, 250, 100},
+		{oned.NewUPCAWriter(), gozxing.BarcodeFormat_UPC_A, "036000291452", UPCA, 300, 120},
+		{oned.NewUPCEWriter(), gozxing.BarcodeFormat_UPC_E, "01234565", UPCE, 250, 100},
+		{oned.NewCode128Writer(), gozxing.BarcodeFormat_CODE_128, "GODAM-12345", Code128, 400, 120},
+		{oned.NewCode39Writer(), gozxing.BarcodeFormat_CODE_39, "ABC-123", Code39, 400, 120},
+		{oned.NewITFWriter(), gozxing.BarcodeFormat_ITF, "12345678901234", ITF, 400, 120},
+		{qrcode.NewQRCodeWriter(), gozxing.BarcodeFormat_QR_CODE, "https://example.com/p/1", QRCode, 250, 250},
+		{datamatrix.NewDataMatrixWriter(), gozxing.BarcodeFormat_DATA_MATRIX, "DM-0001", DataMatrix, 200, 200},
+	}
+
+	decoder := NewDecoder()
+	for _, tt := range tests {
+		img := render(t, tt.writer, tt.value, tt.format, tt.width, tt.height)
+		for _, angle := range []float64{0, 90, 45} {
+			result, err := decoder.Decode(imaging.Rotate(img, angle, color.White))
+			if err != nil {
+				t.Errorf("%s at %v degrees: %v", tt.symbology, angle, err)
+				continue
+			}
+			if result.Value != tt.value || result.Symbology != tt.symbology {
+				t.Errorf("%s at %v degrees: got %s %q, want %q", tt.symbology, angle, result.Symbology, result.Value, tt.value)
+			}
+		}
+	}
+}
+
+func TestDecodeBounds(t *testing.T) {
+	img := render(t, qrcode.NewQRCodeWriter(), "GODAM", gozxing.BarcodeFormat_QR_CODE, 200, 200)
+
+	result, err := NewDecoder().Decode(imaging.Resize(img, 1200, 0, imaging.Lanczos))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The code fills the middle of the 600x500 canvas, scaled up twice
+	centerX, centerY := result.Bounds.X+result.Bounds.Width/2, result.Bounds.Y+result.Bounds.Height/2
+	if centerX < 580 || centerX > 620 || centerY < 480 || centerY > 520 {
+		t.Fatalf("bounds %+v are not centered on the code", result.Bounds)
+	}
+}
+
+func TestDecodeNothing(t *testing.T) {
+	blank := image.NewGray(image.Rect(0, 0, 300, 200))
+	draw.Draw(blank, blank.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	if _, err := NewDecoder().Decode(blank); err != ErrNotFound {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+}
+
+// TestDecodeConcurrent shares one decoder between goroutines; run it with
+// -race to catch readers shared between calls. The ITF reader keeps the bar
+// width of its last read.
+func TestDecodeConcurrent(t *testing.T) {
+	images := []struct {
+		img   image.Image
+		value string
+	}{
+		{render(t, oned.NewEAN13Writer(), "5901234123457", gozxing.BarcodeFormat_EAN_13, 300, 120), "5901234123457"},
+		{render(t, oned.NewCode128Writer(), "GODAM-12345", gozxing.BarcodeFormat_CODE_128, 400, 120), "GODAM-12345"},
+		{render(t, qrcode.NewQRCodeWriter(), "https://example.com/p/1", gozxing.BarcodeFormat_QR_CODE, 250, 250), "https://example.com/p/1"},
+		{render(t, oned.NewITFWriter(), "12345678901234", gozxing.BarcodeFormat_ITF, 400, 120), "12345678901234"},
+	}
+
+	decoder := NewDecoder()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			want := images[i%len(images)]
+			result, err := decoder.Decode(want.img)
+			if err != nil {
+				t.Errorf("decode %d: %v", i, err)
+				return
+			}
+			if result.Value != want.value {
+				t.Errorf("decode %d: got %q, want %q", i, result.Value, want.value)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,94 @@
+package barcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Internal EAN-13 codes use the restricted circulation prefix 20, which GS1
+// leaves to in-store use; other 2x prefixes carry variable weights or prices
+// in some countries. Each company gets a block of codes:
+// 20 + 4 digit block + 6 digit sequence + check digit.
+const (
+	internalPrefix = "20"
+	// MaxInternalBlock is the highest block number that can be assigned
+	MaxInternalBlock = 9999
+	// MaxInternalSequence is the highest sequence number within a block
+	MaxInternalSequence = 999999
+)
+
+// ErrInvalidEAN is returned for codes that are not digits of the expected length
+var ErrInvalidEAN = errors.New("invalid EAN code")
+
+// CheckDigit computes the GS1 check digit for the digits of an EAN-8,
+// UPC-A or EAN-13 code without its check digit
+func CheckDigit(digits string) (byte, error) {
+	if digits == "" {
+		return 0, ErrInvalidEAN
+	}
+
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := digits[len(digits)-1-i]
+		if d < '0' || d > '9' {
+			return 0, ErrInvalidEAN
+		}
+		// Weights alternate 3, 1 starting from the digit next to the check digit
+		weight := 1
+		if i%2 == 0 {
+			weight = 3
+		}
+		sum += int(d-'0') * weight
+	}
+	return byte('0' + (10-sum%10)%10), nil
+}
+
+// ValidEAN reports whether code is an EAN-8, UPC-A or EAN-13 code with a correct check digit
+func ValidEAN(code string) bool {
+	if len(code) != 8 && len(code) != 12 && len(code) != 13 {
+		return false
+	}
+	check, err := CheckDigit(code[:len(code)-1])
+	return err == nil && check == code[len(code)-1]
+}
+
+// InternalEAN13 returns the internal EAN-13 code for a sequence number within a company's block
+func InternalEAN13(block, sequence int) (string, error) {
+	if block < 0 || block > MaxInternalBlock || sequence < 0 || sequence > MaxInternalSequence {
+		return "", fmt.Errorf("internal EAN-13 block %d sequence %d out of range", block, sequence)
+	}
+
+	digits := fmt.Sprintf("%s%04d%06d", internalPrefix, block, sequence)
+	check, err := CheckDigit(digits)
+	if err != nil {
+		return "", err
+	}
+	return digits + string(check), nil
+}
+
+// SymbologyFor picks the symbology a value is best printed in: EAN or UPC
+// when it is a valid retail code, Code 128 otherwise
+func SymbologyFor(value string) string {
+	if !ValidEAN(value) {
+		return Code128
+	}
+	switch len(value) {
+	case 8:
+		return EAN8
+	case 12:
+		return UPCA
+	}
+	return EAN13
+}
+
+// Variants returns the forms a scanned value may be stored in: a UPC-A code
+// is the same symbol as the EAN-13 code with a leading zero
+func Variants(value string) []string {
+	variants := []string{value}
+	if len(value) == 12 {
+		variants = append(variants, "0"+value)
+	} else if len(value) == 13 && value[0] == '0' {
+		variants = append(variants, value[1:])
+	}
+	return variants
+}
@@ -0,0 +1,63 @@
+package barcode
+
+import "testing"
+
+func TestCheckDigit(t *testing.T) {
+	tests := []struct {
+		digits string
+		want   byte
+	}{
+		{"590123412345", '7'},
+		{"03600029145", '2'},
+		{"9638507", '4'},
+		{"200000000001", '5'},
+	}
+
+	for _, tt := range tests {
+		got, err := CheckDigit(tt.digits)
+		if err != nil || got != tt.want {
+			t.Errorf("CheckDigit(%s): got %c, %v, want %c", tt.digits, got, err, tt.want)
+		}
+	}
+
+	if _, err := CheckDigit("12a4"); err != ErrInvalidEAN {
+		t.Errorf("expected ErrInvalidEAN for non digits, got %v", err)
+	}
+}
+
+func TestInternalEAN13(t *testing.T) {
+	code, err := InternalEAN13(42, 1234)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "2000420012346" || !ValidEAN(code) {
+		t.Fatalf("got %s", code)
+	}
+
+	last, err := InternalEAN13(MaxInternalBlock, MaxInternalSequence)
+	if err != nil || last[:2] != internalPrefix || !ValidEAN(last) {
+		t.Fatalf("last code of the last block: got %s, %v", last, err)
+	}
+	if _, err := InternalEAN13(MaxInternalBlock+1, 1); err == nil {
+		t.Fatal("expected an error for a block out of range")
+	}
+	if _, err := InternalEAN13(1, MaxInternalSequence+1); err == nil {
+		t.Fatal("expected an error for a sequence out of range")
+	}
+}
+
+func TestSymbologyFor(t *testing.T) {
+	tests := map[string]string{
+		"5901234123457": EAN13,
+		"036000291452":  UPCA,
+		"96385074":      EAN8,
+		"5901234123458": Code128,
+		"PRD-001":       Code128,
+	}
+
+	for value, want := range tests {
+		if got := SymbologyFor(value); got != want {
+			t.Errorf("SymbologyFor(%s): got %s, want %s", value, got, want)
+		}
+	}
+}
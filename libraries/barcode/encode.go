@@ -0,0 +1,41 @@
+package barcode
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/datamatrix"
+	"github.com/makiuchi-d/gozxing/oned"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+var writers = map[string]struct {
+	format gozxing.BarcodeFormat
+	writer func() gozxing.Writer
+}{
+	EAN13:      {gozxing.BarcodeFormat_EAN_13, oned.NewEAN13Writer},
+	EAN8:       {gozxing.BarcodeFormat_EAN_8, oned.NewEAN8Writer},
+	UPCA:       {gozxing.BarcodeFormat_UPC_A, oned.NewUPCAWriter},
+	UPCE:       {gozxing.BarcodeFormat_UPC_E, oned.NewUPCEWriter},
+	Code128:    {gozxing.BarcodeFormat_CODE_128, oned.NewCode128Writer},
+	Code39:     {gozxing.BarcodeFormat_CODE_39, oned.NewCode39Writer},
+	ITF:        {gozxing.BarcodeFormat_ITF, oned.NewITFWriter},
+	QRCode:     {gozxing.BarcodeFormat_QR_CODE, func() gozxing.Writer { return qrcode.NewQRCodeWriter() }},
+	DataMatrix: {gozxing.BarcodeFormat_DATA_MATRIX, datamatrix.NewDataMatrixWriter},
+}
+
+// Encode renders value in symbology as a black and white image of at least
+// width by height pixels, including the quiet zone
+func Encode(value, symbology string, width, height int) (image.Image, error) {
+	w, ok := writers[symbology]
+	if !ok {
+		return nil, fmt.Errorf("unsupported symbology %q", symbology)
+	}
+
+	matrix, err := w.writer().Encode(value, w.format, width, height, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s barcode: %v", symbology, err)
+	}
+	return matrix, nil
+}
@@ -0,0 +1,114 @@
+package dataset
+
+import (
+	"hash/fnv"
+	"image"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// Dataset splits
+const (
+	SplitTrain = "train"
+	SplitVal   = "val"
+)
+
+// Quality flags. Flagged images stay in the dataset; excluding them is up to
+// whoever curates it.
+const (
+	FlagLowResolution = "low_resolution"
+	FlagBlurry        = "blurry"
+	FlagDark          = "dark"
+	FlagOverexposed   = "overexposed"
+	FlagDuplicate     = "duplicate"
+)
+
+// Flags lists the known quality flags
+var Flags = []string{FlagLowResolution, FlagBlurry, FlagDark, FlagOverexposed, FlagDuplicate}
+
+const (
+	minSide         = 128
+	blurVariance    = 100
+	darkLuminance   = 40
+	brightLuminance = 220
+	inspectSide     = 512
+)
+
+// Split assigns an image to the train or validation split. The choice only
+// depends on the ID, so an image keeps its split across snapshots.
+func Split(id string, valPercent int) string {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	if int(h.Sum32()%100) < valPercent {
+		return SplitVal
+	}
+	return SplitTrain
+}
+
+// Inspect returns the quality flags an image raises on its own: too small,
+// blurry, too dark or too bright. Blur is the variance of the Laplacian of a
+// grayscale copy.
+func Inspect(img image.Image) []string {
+	flags := []string{}
+	bounds := img.Bounds()
+	if bounds.Dx() < minSide || bounds.Dy() < minSide {
+		flags = append(flags, FlagLowResolution)
+	}
+
+	gray := imaging.Grayscale(img)
+	if bounds.Dx() > inspectSide || bounds.Dy() > inspectSide {
+		gray = imaging.Fit(gray, inspectSide, inspectSide, imaging.Box)
+	}
+	width, height := gray.Bounds().Dx(), gray.Bounds().Dy()
+	at := func(x, y int) float64 {
+		return float64(gray.Pix[y*gray.Stride+x*4])
+	}
+
+	var luminance float64
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			luminance += at(x, y)
+		}
+	}
+	luminance /= float64(width * height)
+
+	if width > 2 && height > 2 {
+		var sum, sumSquares float64
+		n := float64((width - 2) * (height - 2))
+		for y := 1; y < height-1; y++ {
+			for x := 1; x < width-1; x++ {
+				laplacian := at(x-1, y) + at(x+1, y) + at(x, y-1) + at(x, y+1) - 4*at(x, y)
+				sum += laplacian
+				sumSquares += laplacian * laplacian
+			}
+		}
+		mean := sum / n
+		if variance := sumSquares/n - mean*mean; variance < blurVariance {
+			flags = append(flags, FlagBlurry)
+		}
+	}
+
+	if luminance < darkLuminance {
+		flags = append(flags, FlagDark)
+	} else if luminance > brightLuminance {
+		flags = append(flags, FlagOverexposed)
+	}
+	return flags
+}
+
+// SafeName turns a label into a name usable as a directory in an archive
+func SafeName(label string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, label)
+	name = strings.Trim(name, ".")
+	if name == "" {
+		return "_"
+	}
+	return name
+}
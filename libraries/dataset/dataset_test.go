@@ -0,0 +1,109 @@
+package dataset
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	val := 0
+	for i := 0; i < 1000; i++ {
+		id := string(rune('a'+i%26)) + strings.Repeat("x", i/26)
+		split := Split(id, 20)
+		if split != Split(id, 20) {
+			t.Fatalf("split of %s changed", id)
+		}
+		if split == SplitVal {
+			val++
+		}
+	}
+	if val < 150 || val > 250 {
+		t.Fatalf("got %d of 1000 images in val, want about 200", val)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	dark := image.NewGray(image.Rect(0, 0, 64, 64))
+	flags := strings.Join(Inspect(dark), ",")
+	for _, flag := range []string{FlagLowResolution, FlagBlurry, FlagDark} {
+		if !strings.Contains(flags, flag) {
+			t.Errorf("dark thumbnail: got %s, want %s", flags, flag)
+		}
+	}
+
+	// A sharp checkerboard of mid gray and white raises nothing
+	sharp := image.NewGray(image.Rect(0, 0, 300, 300))
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 300; x++ {
+			c := color.Gray{Y: 90}
+			if (x/4+y/4)%2 == 0 {
+				c = color.Gray{Y: 250}
+			}
+			sharp.SetGray(x, y, c)
+		}
+	}
+	if flags := Inspect(sharp); len(flags) != 0 {
+		t.Errorf("sharp image: got %v, want no flags", flags)
+	}
+}
+
+func TestWriteZip(t *testing.T) {
+	files := map[string]string{
+		"store/a.jpg": "first image",
+		"store/b.png": "second image",
+	}
+	checksum := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	open := func(file string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(files[file])), nil
+	}
+
+	manifest := NewManifest([]Entry{
+		{ID: "b", ProductCode: "P/2", Label: "P/2", Split: SplitVal, File: "store/b.png", Checksum: checksum("second image")},
+		{ID: "a", ProductCode: "P1", Label: "P1", Split: SplitTrain, File: "store/a.jpg", Checksum: checksum("first image")},
+	}, map[string]string{"P1": "Tea", "P/2": "Coffee"})
+	manifest.Version = 3
+
+	var buf bytes.Buffer
+	if err := WriteZip(&buf, manifest, open); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	var exported Manifest
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+		if f.Name == "manifest.json" {
+			r, _ := f.Open()
+			if err := json.NewDecoder(r).Decode(&exported); err != nil {
+				t.Fatal(err)
+			}
+			r.Close()
+		}
+	}
+	if got, want := strings.Join(names, " "), "val/P_2/b.png train/P1/a.jpg manifest.json"; got != want {
+		t.Fatalf("got entries %s, want %s", got, want)
+	}
+	if exported.Version != 3 || len(exported.Classes) != 2 || exported.Classes[1].Name != "Tea" || exported.Images[1].File != "train/P1/a.jpg" {
+		t.Fatalf("unexpected manifest %+v", exported)
+	}
+
+	manifest.Images[0].Checksum = checksum("tampered")
+	if err := WriteZip(io.Discard, manifest, open); err == nil {
+		t.Fatal("expected a checksum error")
+	}
+}
@@ -0,0 +1,134 @@
+package dataset
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"time"
+)
+
+// Class is a product the dataset has images of. Its label names the class
+// directories in an export.
+type Class struct {
+	Label       string `json:"label"`
+	ProductCode string `json:"product_code"`
+	Name        string `json:"name"`
+	Train       int    `json:"train"`
+	Val         int    `json:"val"`
+}
+
+// Entry is one image of a snapshot. File is where the image is stored, or its
+// path inside an export.
+type Entry struct {
+	ID           string   `json:"id"`
+	ProductCode  string   `json:"product_code"`
+	Label        string   `json:"label"`
+	Split        string   `json:"split"`
+	Source       string   `json:"source"`
+	Labels       []string `json:"labels,omitempty"`
+	QualityFlags []string `json:"quality_flags,omitempty"`
+	File         string   `json:"file"`
+	Checksum     string   `json:"sha256"`
+}
+
+// Manifest describes a dataset snapshot completely, so training on an export
+// can be reproduced
+type Manifest struct {
+	SnapshotID string    `json:"snapshot_id"`
+	CompanyID  string    `json:"company_id"`
+	Version    int       `json:"version"`
+	Note       string    `json:"note,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	Classes    []Class   `json:"classes"`
+	Images     []Entry   `json:"images"`
+}
+
+// NewManifest builds the manifest of the given images, ordered by class and ID
+func NewManifest(images []Entry, names map[string]string) Manifest {
+	entries := append([]Entry(nil), images...)
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Label != entries[j].Label {
+			return entries[i].Label < entries[j].Label
+		}
+		return entries[i].ID < entries[j].ID
+	})
+
+	classes := map[string]*Class{}
+	for _, entry := range entries {
+		class, ok := classes[entry.Label]
+		if !ok {
+			class = &Class{Label: entry.Label, ProductCode: entry.ProductCode, Name: names[entry.ProductCode]}
+			classes[entry.Label] = class
+		}
+		if entry.Split == SplitVal {
+			class.Val++
+		} else {
+			class.Train++
+		}
+	}
+
+	manifest := Manifest{Classes: []Class{}, Images: entries}
+	for _, class := range classes {
+		manifest.Classes = append(manifest.Classes, *class)
+	}
+	sort.Slice(manifest.Classes, func(i, j int) bool {
+		return manifest.Classes[i].Label < manifest.Classes[j].Label
+	})
+	return manifest
+}
+
+// WriteZip writes a snapshot as an ImageFolder style archive: split/label/id
+// directories and a manifest.json whose files point into the archive. Every
+// image is checked against its checksum, so an export is exactly the snapshot.
+func WriteZip(w io.Writer, manifest Manifest, open func(file string) (io.ReadCloser, error)) error {
+	archive := zip.NewWriter(w)
+
+	exported := manifest
+	exported.Images = make([]Entry, 0, len(manifest.Images))
+	for _, entry := range manifest.Images {
+		name := path.Join(entry.Split, SafeName(entry.Label), SafeName(entry.ID)+path.Ext(entry.File))
+		if err := copyChecked(archive, name, entry, open); err != nil {
+			return err
+		}
+		entry.File = name
+		exported.Images = append(exported.Images, entry)
+	}
+
+	file, err := archive.Create("manifest.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(exported); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+func copyChecked(archive *zip.Writer, name string, entry Entry, open func(file string) (io.ReadCloser, error)) error {
+	src, err := open(entry.File)
+	if err != nil {
+		return fmt.Errorf("failed to open image %s: %v", entry.ID, err)
+	}
+	defer src.Close()
+
+	// Images are already compressed
+	dst, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	sum := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(dst, sum), src); err != nil {
+		return fmt.Errorf("failed to copy image %s: %v", entry.ID, err)
+	}
+	if entry.Checksum != "" && hex.EncodeToString(sum.Sum(nil)) != entry.Checksum {
+		return fmt.Errorf("image %s does not match its checksum", entry.ID)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+// Package firestoretest connects tests to the Firestore emulator
+package firestoretest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+var projects int64
+
+// New returns a client for a fresh project on the emulator named by
+// FIRESTORE_EMULATOR_HOST, skipping the test when no emulator is configured.
+// Each call uses its own project, so tests never see each other's documents.
+func New(t testing.TB) *firestore.Client {
+	t.Helper()
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST is not set")
+	}
+
+	project := fmt.Sprintf("test-%d-%d", time.Now().UnixNano(), atomic.AddInt64(&projects, 1))
+	client, err := firestore.NewClient(context.Background(), project)
+	if err != nil {
+		t.Fatalf("connecting to the Firestore emulator: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
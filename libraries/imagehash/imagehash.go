@@ -0,0 +1,143 @@
+package imagehash
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+)
+
+// Bins is the number of color histogram bins, four levels per channel
+const Bins = 64
+
+const (
+	dctSize  = 32
+	lowFreq  = 8
+	histSize = 64
+)
+
+// Fingerprint describes how an image looks: a perceptual hash of its
+// structure and a coarse color histogram
+type Fingerprint struct {
+	Hash      uint64
+	Histogram []float64
+}
+
+// Compute fingerprints an image
+func Compute(img image.Image) Fingerprint {
+	return Fingerprint{
+		Hash:      PHash(img),
+		Histogram: Histogram(img),
+	}
+}
+
+// PHash returns the 64 bit perceptual hash of an image: the signs of the
+// lowest DCT frequencies of a 32x32 grayscale copy against their median
+func PHash(img image.Image) uint64 {
+	gray := imaging.Resize(imaging.Grayscale(img), dctSize, dctSize, imaging.Lanczos)
+
+	var cosines [lowFreq][dctSize]float64
+	for u := 0; u < lowFreq; u++ {
+		for x := 0; x < dctSize; x++ {
+			cosines[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * dctSize))
+		}
+	}
+
+	// Rows first, then columns, keeping only the low frequencies
+	var rows [dctSize][lowFreq]float64
+	for y := 0; y < dctSize; y++ {
+		for u := 0; u < lowFreq; u++ {
+			var sum float64
+			for x := 0; x < dctSize; x++ {
+				sum += float64(gray.Pix[y*gray.Stride+x*4]) * cosines[u][x]
+			}
+			rows[y][u] = sum
+		}
+	}
+	coefficients := make([]float64, 0, lowFreq*lowFreq)
+	for v := 0; v < lowFreq; v++ {
+		for u := 0; u < lowFreq; u++ {
+			var sum float64
+			for y := 0; y < dctSize; y++ {
+				sum += rows[y][u] * cosines[v][y]
+			}
+			coefficients = append(coefficients, sum*alpha(u)*alpha(v))
+		}
+	}
+
+	// The DC term only says how bright the image is and stays out of the median
+	sorted := append([]float64(nil), coefficients[1:]...)
+	sort.Float64s(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+
+	var hash uint64
+	for i, c := range coefficients {
+		if c > median {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+func alpha(u int) float64 {
+	if u == 0 {
+		return math.Sqrt(1.0 / dctSize)
+	}
+	return math.Sqrt(2.0 / dctSize)
+}
+
+// Histogram returns the normalized color histogram of an image with four
+// levels per channel. Transparent pixels are left out.
+func Histogram(img image.Image) []float64 {
+	small := imaging.Resize(img, histSize, histSize, imaging.Box)
+
+	histogram := make([]float64, Bins)
+	var total float64
+	for i := 0; i < len(small.Pix); i += 4 {
+		if small.Pix[i+3] < 128 {
+			continue
+		}
+		bin := int(small.Pix[i]>>6)<<4 | int(small.Pix[i+1]>>6)<<2 | int(small.Pix[i+2]>>6)
+		histogram[bin]++
+		total++
+	}
+	if total > 0 {
+		for i := range histogram {
+			histogram[i] /= total
+		}
+	}
+	return histogram
+}
+
+// Similarity scores how alike two fingerprints are between 0 and 1. Hashes of
+// unrelated images differ in about half their bits, which scores 0.
+func Similarity(a, b Fingerprint) float64 {
+	distance := bits.OnesCount64(a.Hash ^ b.Hash)
+	hashScore := 1 - float64(distance)/32
+	if hashScore < 0 {
+		hashScore = 0
+	}
+	if len(a.Histogram) != Bins || len(b.Histogram) != Bins {
+		return hashScore
+	}
+
+	var intersection float64
+	for i := range a.Histogram {
+		intersection += math.Min(a.Histogram[i], b.Histogram[i])
+	}
+	return 0.6*hashScore + 0.4*intersection
+}
+
+// FormatHash returns a hash as 16 hex digits
+func FormatHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// ParseHash reads a hash written by FormatHash
+func ParseHash(s string) (uint64, error) {
+	return strconv.ParseUint(s, 16, 64)
+}
@@ -0,0 +1,69 @@
+package imagehash
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// scene draws a red box and a blue disc on a white background
+func scene(width, height int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			fx, fy := float64(x)/float64(width), float64(y)/float64(height)
+			c := color.NRGBA{255, 255, 255, 255}
+			if fx > 0.1 && fx < 0.45 && fy > 0.2 && fy < 0.8 {
+				c = color.NRGBA{200, 30, 30, 255}
+			}
+			if (fx-0.7)*(fx-0.7)+(fy-0.5)*(fy-0.5) < 0.04 {
+				c = color.NRGBA{30, 60, 200, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// stripes draws green vertical stripes
+func stripes(width, height int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBA{20, 20, 20, 255}
+			if (x/(width/8))%2 == 0 {
+				c = color.NRGBA{40, 180, 60, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestSimilarity(t *testing.T) {
+	reference := Compute(scene(400, 300))
+
+	if got := Similarity(reference, reference); got < 0.999 {
+		t.Fatalf("identical images scored %v", got)
+	}
+
+	// A smaller, slightly brighter and blurred photo of the same thing
+	photo := imaging.AdjustBrightness(imaging.Blur(scene(250, 190), 1), 8)
+	if got := Similarity(reference, Compute(photo)); got < 0.85 {
+		t.Fatalf("similar images scored %v", got)
+	}
+
+	if got := Similarity(reference, Compute(stripes(400, 300))); got > 0.5 {
+		t.Fatalf("different images scored %v", got)
+	}
+}
+
+func TestHashFormat(t *testing.T) {
+	hash := PHash(scene(120, 90))
+	parsed, err := ParseHash(FormatHash(hash))
+	if err != nil || parsed != hash {
+		t.Fatalf("got %x, %v, want %x", parsed, err, hash)
+	}
+}
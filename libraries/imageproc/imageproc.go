@@ -0,0 +1,202 @@
+// Package imageproc validates uploaded images and produces the variants that
+// are stored for them: the upright, size-limited image, a thumbnail and
+// lossy WebP copies of both, which are smaller than the JPEG or PNG.
+package imageproc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"path"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/nirshpaa/godam-backend/libraries/webp"
+
+	// Registers the WebP decoder with image.Decode
+	_ "golang.org/x/image/webp"
+)
+
+// Formats recognized from the leading bytes of an upload
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatGIF  = "gif"
+	FormatWebP = "webp"
+)
+
+// Variant names, also the keys of Paths in JSON
+const (
+	VariantImage         = "image"
+	VariantThumbnail     = "thumbnail"
+	VariantWebP          = "webp"
+	VariantThumbnailWebP = "thumbnail_webp"
+)
+
+// maxPixels rejects images that would take too much memory to decode
+const maxPixels = 50_000_000
+
+var (
+	// ErrUnsupportedFormat is returned for uploads that are not JPEG, PNG, GIF or WebP
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	// ErrInvalidImage is returned for uploads that cannot be decoded
+	ErrInvalidImage = errors.New("image cannot be decoded")
+	// ErrTooLarge is returned for images with more pixels than are decoded
+	ErrTooLarge = errors.New("image is too large")
+)
+
+// Options limits the stored image and sizes the thumbnail
+type Options struct {
+	MaxWidth      int
+	MaxHeight     int
+	ThumbnailSize int
+	JPEGQuality   int
+	WebPQuality   int
+}
+
+// DefaultOptions keep product photos sharp enough for recognition
+var DefaultOptions = Options{
+	MaxWidth:      1600,
+	MaxHeight:     1600,
+	ThumbnailSize: 320,
+	JPEGQuality:   85,
+	WebPQuality:   80,
+}
+
+// Variant is one encoded file produced from an upload. Suffix and Ext name
+// the file next to the others.
+type Variant struct {
+	Name   string
+	Suffix string
+	Ext    string
+	Data   []byte
+	Width  int
+	Height int
+}
+
+// Paths are the stored files of an image
+type Paths struct {
+	Image         string `json:"image"`
+	Thumbnail     string `json:"thumbnail"`
+	WebP          string `json:"webp"`
+	ThumbnailWebP string `json:"thumbnail_webp"`
+}
+
+// Sniff detects the format of an image from its leading bytes, ignoring
+// whatever name or content type it arrived with
+func Sniff(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8, 0xff}):
+		return FormatJPEG, nil
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG, nil
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return FormatGIF, nil
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return FormatWebP, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// Check validates an upload from its leading bytes and header alone, so
+// images too large to decode are turned away before their pixels are read.
+// It returns the format of the image.
+func Check(data []byte) (string, error) {
+	format, err := Sniff(data)
+	if err != nil {
+		return "", err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrInvalidImage
+	}
+	if config.Width*config.Height > maxPixels {
+		return "", fmt.Errorf("%w: %dx%d pixels", ErrTooLarge, config.Width, config.Height)
+	}
+	return format, nil
+}
+
+// Process decodes an upload, turns it upright by its EXIF orientation,
+// shrinks it to the limits and encodes its variants. Opaque images are
+// stored as JPEG, images with transparency as PNG.
+func Process(data []byte, opts Options) ([]Variant, error) {
+	if _, err := Check(data); err != nil {
+		return nil, err
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if b := img.Bounds(); b.Dx() > opts.MaxWidth || b.Dy() > opts.MaxHeight {
+		img = imaging.Fit(img, opts.MaxWidth, opts.MaxHeight, imaging.Lanczos)
+	}
+	thumbnail := imaging.Fit(img, opts.ThumbnailSize, opts.ThumbnailSize, imaging.Lanczos)
+
+	format, ext := imaging.JPEG, ".jpg"
+	if !imaging.Clone(img).Opaque() {
+		format, ext = imaging.PNG, ".png"
+	}
+
+	var variants []Variant
+	for _, v := range []struct {
+		name, suffix string
+		img          image.Image
+	}{
+		{VariantImage, "", img},
+		{VariantThumbnail, "_thumb", thumbnail},
+	} {
+		var buf bytes.Buffer
+		if err := imaging.Encode(&buf, v.img, format, imaging.JPEGQuality(opts.JPEGQuality)); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %v", v.name, err)
+		}
+		var webpBuf bytes.Buffer
+		if err := webp.Encode(&webpBuf, v.img, opts.WebPQuality); err != nil {
+			return nil, fmt.Errorf("failed to encode %s as WebP: %v", v.name, err)
+		}
+
+		b := v.img.Bounds()
+		webpName := VariantWebP
+		if v.name == VariantThumbnail {
+			webpName = VariantThumbnailWebP
+		}
+		variants = append(variants,
+			Variant{Name: v.name, Suffix: v.suffix, Ext: ext, Data: buf.Bytes(), Width: b.Dx(), Height: b.Dy()},
+			Variant{Name: webpName, Suffix: v.suffix, Ext: ".webp", Data: webpBuf.Bytes(), Width: b.Dx(), Height: b.Dy()},
+		)
+	}
+	return variants, nil
+}
+
+// VariantPaths derives the paths of the variants stored next to an image
+// saved by the pipeline. It returns nil for images stored before, whose file
+// name is not a content hash.
+func VariantPaths(imagePath string) *Paths {
+	dir, file := path.Split(imagePath)
+	ext := path.Ext(file)
+	hash := strings.TrimSuffix(file, ext)
+	if (ext != ".jpg" && ext != ".png") || !IsContentHash(hash) {
+		return nil
+	}
+	return &Paths{
+		Image:         imagePath,
+		Thumbnail:     dir + hash + "_thumb" + ext,
+		WebP:          dir + hash + ".webp",
+		ThumbnailWebP: dir + hash + "_thumb.webp",
+	}
+}
+
+// IsContentHash reports whether name is a hex SHA-256, the file name of
+// images saved by the pipeline
+func IsContentHash(name string) bool {
+	if len(name) != 64 {
+		return false
+	}
+	for _, c := range name {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,203 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"strings"
+	"testing"
+
+	xwebp "golang.org/x/image/webp"
+)
+
+func encodeJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 180
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withOrientation inserts an EXIF segment with the orientation tag after the SOI marker
+func withOrientation(data []byte, orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = append(tiff, 0, 1)                               // one IFD entry
+	tiff = append(tiff, 0x01, 0x12, 0, 3, 0, 0, 0, 1)       // orientation, SHORT, count 1
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation) // value
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)                   // padding, no next IFD
+	exif := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xff, 0xe1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(exif)+2))
+	segment = append(segment, exif...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"\xff\xd8\xff\xe0", FormatJPEG},
+		{"\x89PNG\r\n\x1a\n....", FormatPNG},
+		{"GIF89a...", FormatGIF},
+		{"RIFF\x00\x00\x00\x00WEBPVP8L", FormatWebP},
+	}
+	for _, tt := range tests {
+		if got, err := Sniff([]byte(tt.data)); err != nil || got != tt.want {
+			t.Errorf("Sniff(%q) = %q, %v, want %q", tt.data, got, err, tt.want)
+		}
+	}
+	for _, data := range []string{"", "<svg></svg>", "%PDF-1.4", "RIFF\x00\x00\x00\x00WAVE"} {
+		if _, err := Sniff([]byte(data)); err != ErrUnsupportedFormat {
+			t.Errorf("Sniff(%q) = %v, want ErrUnsupportedFormat", data, err)
+		}
+	}
+}
+
+func TestCheckRejectsLargeImages(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	if format, err := Check(buf.Bytes()); err != nil || format != FormatPNG {
+		t.Fatalf("Check = %q, %v", format, err)
+	}
+
+	// Claim 10000x10000 pixels in the header; the pixels are never read
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[16:], 10000)
+	binary.BigEndian.PutUint32(data[20:], 10000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	if _, err := Check(data); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Check of a 100MP image = %v, want ErrTooLarge", err)
+	}
+	if _, err := Process(data, DefaultOptions); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Process of a 100MP image = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestProcessOrientationAndLimits(t *testing.T) {
+	opts := Options{MaxWidth: 100, MaxHeight: 100, ThumbnailSize: 16, JPEGQuality: 80, WebPQuality: 80}
+
+	// Orientation 6 is rotated 90 degrees clockwise, so the stored image is upright and tall
+	variants, err := Process(withOrientation(encodeJPEG(t, 300, 150), 6), opts)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if len(variants) != 4 {
+		t.Fatalf("got %d variants, want 4", len(variants))
+	}
+	byName := map[string]Variant{}
+	for _, v := range variants {
+		byName[v.Name] = v
+	}
+
+	stored := byName[VariantImage]
+	if stored.Width != 50 || stored.Height != 100 || stored.Ext != ".jpg" {
+		t.Errorf("image = %dx%d %s, want 50x100 .jpg", stored.Width, stored.Height, stored.Ext)
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(stored.Data)); err != nil {
+		t.Errorf("image is not a JPEG: %v", err)
+	}
+	thumb := byName[VariantThumbnail]
+	if thumb.Width != 8 || thumb.Height != 16 || thumb.Suffix != "_thumb" {
+		t.Errorf("thumbnail = %dx%d %q, want 8x16 _thumb", thumb.Width, thumb.Height, thumb.Suffix)
+	}
+	for _, name := range []string{VariantWebP, VariantThumbnailWebP} {
+		if format, err := Sniff(byName[name].Data); err != nil || format != FormatWebP {
+			t.Errorf("%s is %q, %v", name, format, err)
+		}
+	}
+}
+
+func TestProcessKeepsTransparency(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	img.SetNRGBA(2, 2, color.NRGBA{255, 0, 0, 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	variants, err := Process(buf.Bytes(), DefaultOptions)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if variants[0].Ext != ".png" || variants[0].Width != 10 {
+		t.Errorf("image = %s %d wide, want .png 10 wide", variants[0].Ext, variants[0].Width)
+	}
+
+	if _, err := Process([]byte("\x89PNG\r\n\x1a\ntruncated"), DefaultOptions); err != ErrInvalidImage {
+		t.Errorf("Process of a broken PNG = %v, want ErrInvalidImage", err)
+	}
+}
+
+func TestProcessWebPSmaller(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, alpha := range []bool{false, true} {
+		// Shading with a little noise, like a product photo
+		img := image.NewNRGBA(image.Rect(0, 0, 400, 300))
+		for y := 0; y < 300; y++ {
+			for x := 0; x < 400; x++ {
+				noise := uint8(rng.Intn(8))
+				c := color.NRGBA{uint8(40 + x/3 + int(noise)), uint8(60 + y/2 + int(noise)), uint8(200 - x/4 - int(noise)), 255}
+				if alpha && x < 50 {
+					c.A = 0
+				}
+				img.SetNRGBA(x, y, c)
+			}
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+
+		variants, err := Process(buf.Bytes(), DefaultOptions)
+		if err != nil {
+			t.Fatalf("Process: %v", err)
+		}
+		byName := map[string]Variant{}
+		for _, v := range variants {
+			byName[v.Name] = v
+		}
+		for _, pair := range [][2]string{{VariantImage, VariantWebP}, {VariantThumbnail, VariantThumbnailWebP}} {
+			original, copied := byName[pair[0]], byName[pair[1]]
+			if len(copied.Data) >= len(original.Data) {
+				t.Errorf("with alpha %v, %s is %d bytes, want less than the %d of the %s %s", alpha, pair[1], len(copied.Data), len(original.Data), original.Ext, pair[0])
+			}
+			decoded, err := xwebp.Decode(bytes.NewReader(copied.Data))
+			if err != nil {
+				t.Fatalf("%s does not decode: %v", pair[1], err)
+			}
+			if b := decoded.Bounds(); b.Dx() != original.Width || b.Dy() != original.Height || copied.Ext != ".webp" {
+				t.Errorf("%s = %dx%d %s, want %dx%d .webp", pair[1], b.Dx(), b.Dy(), copied.Ext, original.Width, original.Height)
+			}
+		}
+	}
+}
+
+func TestVariantPaths(t *testing.T) {
+	hash := strings.Repeat("ab", 32)
+	paths := VariantPaths("products/" + hash + ".jpg")
+	if paths == nil || paths.Thumbnail != "products/"+hash+"_thumb.jpg" || paths.ThumbnailWebP != "products/"+hash+"_thumb.webp" {
+		t.Errorf("VariantPaths = %+v", paths)
+	}
+	for _, p := range []string{"", "products/1700000000_photo.jpg", "products/" + hash + ".gif"} {
+		if VariantPaths(p) != nil {
+			t.Errorf("VariantPaths(%q) is not nil", p)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package label
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nirshpaa/godam-backend/libraries/barcode"
+)
+
+// MaxLabels is the most labels, counting copies, rendered at once
+const MaxLabels = 2000
+
+// ErrNoLabels is returned when there is nothing to print
+var ErrNoLabels = errors.New("no labels to print")
+
+// ErrTooManyLabels is returned when more than MaxLabels labels are asked for
+var ErrTooManyLabels = fmt.Errorf("at most %d labels can be printed at once", MaxLabels)
+
+// Label is the content of one label, printed Quantity times
+type Label struct {
+	Title     string
+	Lines     []string
+	Code      string
+	Symbology string
+	Quantity  int
+}
+
+// matrix reports whether the label's code is a 2D symbol, which is printed
+// beside the text instead of under it
+func (l Label) matrix() bool {
+	return l.Symbology == barcode.QRCode || l.Symbology == barcode.DataMatrix
+}
+
+// count returns how many copies of a label to print
+func (l Label) count() int {
+	if l.Quantity < 1 {
+		return 1
+	}
+	return l.Quantity
+}
+
+// total returns the number of labels printed for all copies
+func total(labels []Label) int {
+	n := 0
+	for _, l := range labels {
+		n += l.count()
+	}
+	return n
+}
+
+// checkTotal returns an error when there is nothing to print or too much
+func checkTotal(labels []Label) error {
+	n := total(labels)
+	if n == 0 {
+		return ErrNoLabels
+	}
+	if n > MaxLabels {
+		return ErrTooManyLabels
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package label
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/nirshpaa/godam-backend/libraries/barcode"
+)
+
+var testLabels = []Label{
+	{Title: "Green Tea 500g", Lines: []string{"Price: 12.50", "Shelf: A-1"}, Code: "5901234123457", Symbology: barcode.EAN13, Quantity: 2},
+	{Title: "Aisle 3 / Bin 4", Lines: []string{"ID: abc"}, Code: "godam:shelf:abc", Symbology: barcode.QRCode},
+	{Title: "Loose sugar", Code: "PRD_^1", Symbology: barcode.Code128},
+}
+
+func TestRenderPDF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := RenderPDF(&buf, testLabels, DefaultSheet); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")) {
+		t.Fatal("expected a PDF document")
+	}
+
+	if err := RenderPDF(&buf, nil, DefaultSheet); err != ErrNoLabels {
+		t.Fatalf("got %v, want ErrNoLabels", err)
+	}
+	tooMany := []Label{{Title: "Bulk", Code: "PRD-1", Symbology: barcode.Code128, Quantity: MaxLabels}, {Title: "One more", Code: "PRD-2", Symbology: barcode.Code128}}
+	if err := RenderPDF(&buf, tooMany, DefaultSheet); err != ErrTooManyLabels {
+		t.Fatalf("got %v, want ErrTooManyLabels", err)
+	}
+	if err := RenderZPL(&buf, tooMany, DefaultMedia); err != ErrTooManyLabels {
+		t.Fatalf("got %v, want ErrTooManyLabels from ZPL", err)
+	}
+	if err := RenderPDF(&buf, testLabels, Sheet{Columns: 0, Rows: 8}); err == nil {
+		t.Fatal("expected an error for an empty grid")
+	}
+}
+
+func TestRenderZPL(t *testing.T) {
+	var buf bytes.Buffer
+	if err := RenderZPL(&buf, testLabels, DefaultMedia); err != nil {
+		t.Fatal(err)
+	}
+	zpl := buf.String()
+
+	for _, want := range []string{
+		"^BEN,", "^FD590123412345^FS", "^PQ2",
+		"^BQN,2,", "^FDMA,godam:shelf:abc^FS",
+		"^BCN,", "^FH_^FDPRD_5F_5E1^FS",
+	} {
+		if !strings.Contains(zpl, want) {
+			t.Errorf("expected %q in\n%s", want, zpl)
+		}
+	}
+	if got := strings.Count(zpl, "^XA"); got != len(testLabels) {
+		t.Errorf("got %d label formats, want %d", got, len(testLabels))
+	}
+}
@@ -0,0 +1,148 @@
+package label
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"io"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/nirshpaa/godam-backend/libraries/barcode"
+)
+
+// Sheet is a grid of labels on A4 paper. Sizes are in millimetres.
+type Sheet struct {
+	Columns int
+	Rows    int
+	Margin  float64
+}
+
+// DefaultSheet is the common A4 sheet of 24 labels of 70 by 37 mm
+var DefaultSheet = Sheet{Columns: 3, Rows: 8, Margin: 0}
+
+const (
+	a4Width  = 210.0
+	a4Height = 297.0
+	padding  = 2.0
+)
+
+// RenderPDF writes the labels to w as A4 pages filled row by row
+func RenderPDF(w io.Writer, labels []Label, sheet Sheet) error {
+	if err := checkTotal(labels); err != nil {
+		return err
+	}
+	if sheet.Columns < 1 || sheet.Rows < 1 || sheet.Margin < 0 || 2*sheet.Margin >= a4Height || 2*sheet.Margin >= a4Width {
+		return fmt.Errorf("invalid label sheet %dx%d with %.1f mm margin", sheet.Columns, sheet.Rows, sheet.Margin)
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+	translate := pdf.UnicodeTranslatorFromDescriptor("")
+
+	cellW := (a4Width - 2*sheet.Margin) / float64(sheet.Columns)
+	cellH := (a4Height - 2*sheet.Margin) / float64(sheet.Rows)
+	perPage := sheet.Columns * sheet.Rows
+
+	slot := 0
+	for _, l := range labels {
+		image, err := registerBarcode(pdf, l)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < l.count(); i++ {
+			if slot%perPage == 0 {
+				pdf.AddPage()
+			}
+			x := sheet.Margin + float64(slot%sheet.Columns)*cellW
+			y := sheet.Margin + float64(slot%perPage/sheet.Columns)*cellH
+			drawLabel(pdf, translate, l, image, x, y, cellW, cellH)
+			slot++
+		}
+	}
+
+	return pdf.Output(w)
+}
+
+// registerBarcode adds the label's barcode to the document once and returns
+// its image name, or "" for labels without a code
+func registerBarcode(pdf *fpdf.Fpdf, l Label) (string, error) {
+	if l.Code == "" {
+		return "", nil
+	}
+
+	width, height := 400, 120
+	if l.matrix() {
+		width, height = 300, 300
+	}
+	img, err := barcode.Encode(l.Code, l.Symbology, width, height)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", fmt.Errorf("failed to encode barcode image: %v", err)
+	}
+	name := l.Symbology + ":" + l.Code
+	pdf.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: "PNG"}, &buf)
+	return name, pdf.Error()
+}
+
+// drawLabel lays out one label in the cell at x, y. 2D codes sit on the left
+// with the text beside them; 1D codes fill the space under the text.
+func drawLabel(pdf *fpdf.Fpdf, translate func(string) string, l Label, image string, x, y, w, h float64) {
+	textX, textW := x+padding, w-2*padding
+	if image != "" && l.matrix() {
+		size := h - 2*padding
+		if size > w/2 {
+			size = w / 2
+		}
+		pdf.ImageOptions(image, x+padding, y+padding, size, size, false, fpdf.ImageOptions{}, 0, "")
+		textX, textW = x+2*padding+size, w-3*padding-size
+	}
+
+	lineY := y + padding
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetXY(textX, lineY)
+	pdf.CellFormat(textW, 4, fit(pdf, translate, l.Title, textW), "", 0, "L", false, 0, "")
+	lineY += 4.5
+
+	pdf.SetFont("Helvetica", "", 7)
+	for _, line := range l.Lines {
+		pdf.SetXY(textX, lineY)
+		pdf.CellFormat(textW, 3, fit(pdf, translate, line, textW), "", 0, "L", false, 0, "")
+		lineY += 3.5
+	}
+
+	if image == "" || l.matrix() {
+		return
+	}
+
+	// The code keeps its aspect ratio and its value is printed underneath
+	barH := y + h - padding - 3 - lineY
+	barW := w - 2*padding
+	if barH*400/120 < barW {
+		barW = barH * 400 / 120
+	}
+	if barH <= 0 {
+		return
+	}
+	barX := x + (w-barW)/2
+	pdf.ImageOptions(image, barX, lineY, barW, barH, false, fpdf.ImageOptions{}, 0, "")
+	pdf.SetXY(x+padding, lineY+barH)
+	pdf.CellFormat(w-2*padding, 3, l.Code, "", 0, "C", false, 0, "")
+}
+
+// fit shortens text with an ellipsis until it fits width and converts it to
+// the encoding of the core fonts
+func fit(pdf *fpdf.Fpdf, translate func(string) string, text string, width float64) string {
+	if pdf.GetStringWidth(translate(text)) <= width {
+		return translate(text)
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(translate(string(runes)+"...")) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return translate(string(runes) + "...")
+}
@@ -0,0 +1,111 @@
+package label
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nirshpaa/godam-backend/libraries/barcode"
+)
+
+// Media is the size of a thermal label in printer dots
+type Media struct {
+	Width  int
+	Height int
+}
+
+// DefaultMedia is a 2 by 1 inch label on a 203 dpi printer
+var DefaultMedia = Media{Width: 406, Height: 203}
+
+const zplMargin = 16
+
+// RenderZPL writes the labels to w as ZPL, one format per label with its
+// quantity left to the printer
+func RenderZPL(w io.Writer, labels []Label, media Media) error {
+	if err := checkTotal(labels); err != nil {
+		return err
+	}
+	if media.Width < 100 || media.Height < 50 {
+		return fmt.Errorf("invalid label media %dx%d dots", media.Width, media.Height)
+	}
+
+	out := bufio.NewWriter(w)
+	for _, l := range labels {
+		writeZPL(out, l, media)
+	}
+	return out.Flush()
+}
+
+func writeZPL(w *bufio.Writer, l Label, media Media) {
+	fmt.Fprintf(w, "^XA\n^CI28\n^PW%d\n^LL%d\n", media.Width, media.Height)
+
+	textX, textW := zplMargin, media.Width-2*zplMargin
+	if l.Code != "" && l.matrix() {
+		// Modules of 4 dots keep small codes readable by handheld scanners
+		size := media.Height - 2*zplMargin
+		fmt.Fprintf(w, "^FO%d,%d%s^FS\n", zplMargin, zplMargin, zplMatrix(l, size))
+		textX, textW = size+2*zplMargin, media.Width-size-3*zplMargin
+	}
+
+	y := zplMargin
+	fmt.Fprintf(w, "^FO%d,%d^A0N,28,28^FB%d,1,0,L^FH_^FD%s^FS\n", textX, y, textW, zplEscape(l.Title))
+	y += 32
+	for _, line := range l.Lines {
+		fmt.Fprintf(w, "^FO%d,%d^A0N,20,20^FB%d,1,0,L^FH_^FD%s^FS\n", textX, y, textW, zplEscape(line))
+		y += 22
+	}
+
+	if l.Code != "" && !l.matrix() {
+		// The printer adds the human readable line under the bars
+		height := media.Height - y - zplMargin - 24
+		if height > 20 {
+			fmt.Fprintf(w, "^FO%d,%d^BY2%s^FS\n", zplMargin, y+4, zplLinear(l, height))
+		}
+	}
+
+	fmt.Fprintf(w, "^PQ%d\n^XZ\n", l.count())
+}
+
+// zplLinear returns the barcode command and data for a 1D code. ZPL computes
+// EAN and UPC check digits itself, so they are left off the data.
+func zplLinear(l Label, height int) string {
+	switch {
+	case l.Symbology == barcode.EAN13 && barcode.ValidEAN(l.Code):
+		return fmt.Sprintf("^BEN,%d,Y,N^FD%s", height, l.Code[:12])
+	case l.Symbology == barcode.EAN8 && barcode.ValidEAN(l.Code):
+		return fmt.Sprintf("^B8N,%d,Y,N^FD%s", height, l.Code[:7])
+	case l.Symbology == barcode.UPCA && barcode.ValidEAN(l.Code):
+		return fmt.Sprintf("^BUN,%d,Y,N,Y^FD%s", height, l.Code[:11])
+	case l.Symbology == barcode.Code39:
+		return fmt.Sprintf("^B3N,N,%d,Y,N^FH_^FD%s", height, zplEscape(l.Code))
+	}
+	return fmt.Sprintf("^BCN,%d,Y,N,N^FH_^FD%s", height, zplEscape(l.Code))
+}
+
+// zplMatrix returns the barcode command and data for a 2D code about size dots wide
+func zplMatrix(l Label, size int) string {
+	if l.Symbology == barcode.DataMatrix {
+		module := size / 24
+		if module < 2 {
+			module = 2
+		}
+		return fmt.Sprintf("^BXN,%d,200^FH_^FD%s", module, zplEscape(l.Code))
+	}
+
+	// QR codes take a magnification of 1 to 10 and an error correction level
+	// and input mode before the data
+	magnification := size / 40
+	if magnification < 1 {
+		magnification = 1
+	} else if magnification > 10 {
+		magnification = 10
+	}
+	return fmt.Sprintf("^BQN,2,%d^FDMA,%s", magnification, strings.NewReplacer("^", " ", "~", " ").Replace(l.Code))
+}
+
+// zplEscape hex encodes the characters ZPL treats as commands, for fields
+// preceded by ^FH_
+func zplEscape(s string) string {
+	return strings.NewReplacer("_", "_5F", "^", "_5E", "~", "_7E", "\n", " ", "\r", " ").Replace(s)
+}
@@ -0,0 +1,132 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// GCS stores objects in a Google Cloud Storage bucket
+type GCS struct {
+	bucket *storage.BucketHandle
+}
+
+// NewGCS creates a store for a bucket
+func NewGCS(bucket *storage.BucketHandle) *GCS {
+	return &GCS{bucket: bucket}
+}
+
+// OpenGCS connects to a bucket with the service account in credentialsFile,
+// or the default credentials when it is empty
+func OpenGCS(ctx context.Context, bucket, credentialsFile string) (*GCS, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("GCS storage needs a bucket")
+	}
+	var opts []option.ClientOption
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %v", err)
+	}
+	return NewGCS(client.Bucket(bucket)), nil
+}
+
+func (g *GCS) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	w := g.bucket.Object(key).NewWriter(ctx)
+	w.ContentType = contentType
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return fmt.Errorf("failed to write object: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to write object: %v", err)
+	}
+	return nil
+}
+
+func (g *GCS) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	r, err := g.bucket.Object(key).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, ErrNotFound
+	}
+	return r, err
+}
+
+func (g *GCS) Stat(ctx context.Context, key string) (*Object, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := g.bucket.Object(key).Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return gcsObject(attrs), nil
+}
+
+func (g *GCS) Delete(ctx context.Context, key string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	if err := g.bucket.Object(key).Delete(ctx); err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (g *GCS) List(ctx context.Context, prefix string) ([]Object, error) {
+	objects := []Object{}
+	it := g.bucket.Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, *gcsObject(attrs))
+	}
+}
+
+// SignedURL signs a V4 URL with the credentials of the client
+func (g *GCS) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return g.bucket.SignedURL(key, &storage.SignedURLOptions{
+		Method:  "GET",
+		Expires: time.Now().Add(expiry),
+		Scheme:  storage.SigningSchemeV4,
+	})
+}
+
+func gcsObject(attrs *storage.ObjectAttrs) *Object {
+	return &Object{
+		Key:         attrs.Name,
+		Size:        attrs.Size,
+		ContentType: attrs.ContentType,
+		Updated:     attrs.Updated,
+	}
+}
@@ -0,0 +1,83 @@
+package objectstore
+
+import (
+	"context"
+	"fmt"
+)
+
+// MigrateOptions control Migrate
+type MigrateOptions struct {
+	// Prefix limits the migration to keys starting with it
+	Prefix string
+	// DryRun reports what would be copied without writing
+	DryRun bool
+	// DeleteSource removes each object from the source once it is copied
+	DeleteSource bool
+	// Progress is called after each object with the error it failed with, if any
+	Progress func(key string, err error)
+}
+
+// MigrateResult counts the objects handled by Migrate
+type MigrateResult struct {
+	Copied  int `json:"copied"`
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+}
+
+// Migrate copies the objects of src to dst. Objects already in dst with the
+// same size are skipped, so an interrupted migration can be run again.
+// Failures of single objects are counted and the migration continues.
+func Migrate(ctx context.Context, dst, src Store, opts MigrateOptions) (MigrateResult, error) {
+	var result MigrateResult
+	objects, err := src.List(ctx, opts.Prefix)
+	if err != nil {
+		return result, fmt.Errorf("failed to list source objects: %v", err)
+	}
+
+	for _, object := range objects {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		copied, err := migrateObject(ctx, dst, src, object, opts)
+		switch {
+		case err != nil:
+			result.Failed++
+		case copied:
+			result.Copied++
+		default:
+			result.Skipped++
+		}
+		if opts.Progress != nil {
+			opts.Progress(object.Key, err)
+		}
+	}
+	return result, nil
+}
+
+func migrateObject(ctx context.Context, dst, src Store, object Object, opts MigrateOptions) (bool, error) {
+	existing, err := dst.Stat(ctx, object.Key)
+	if err != nil && err != ErrNotFound {
+		return false, err
+	}
+	copied := existing == nil || existing.Size != object.Size
+	if opts.DryRun {
+		return copied, nil
+	}
+
+	if copied {
+		data, err := ReadAll(ctx, src, object.Key)
+		if err != nil {
+			return false, err
+		}
+		if err := dst.Put(ctx, object.Key, data, object.ContentType); err != nil {
+			return false, err
+		}
+	}
+	if opts.DeleteSource {
+		if err := src.Delete(ctx, object.Key); err != nil {
+			return copied, err
+		}
+	}
+	return copied, nil
+}
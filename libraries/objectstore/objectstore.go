@@ -0,0 +1,216 @@
+// Package objectstore stores files as objects under slash separated keys,
+// on the local disk, in an S3 compatible bucket or in Google Cloud Storage.
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Backends selectable in Config
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+	BackendGCS   = "gcs"
+)
+
+var (
+	// ErrNotFound is returned for keys with no object
+	ErrNotFound = errors.New("object not found")
+	// ErrInvalidKey is returned for empty keys and keys leaving their prefix
+	ErrInvalidKey = errors.New("invalid object key")
+	// ErrNotSupported is returned by backends that cannot sign URLs
+	ErrNotSupported = errors.New("operation not supported by the storage backend")
+)
+
+// Object describes a stored object
+type Object struct {
+	Key         string    `json:"key"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type,omitempty"`
+	Updated     time.Time `json:"updated"`
+}
+
+// Store is implemented by each storage backend
+type Store interface {
+	// Put stores data under key, replacing any object already there
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get opens the object under key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Stat describes the object under key
+	Stat(ctx context.Context, key string) (*Object, error)
+	// Delete removes the object under key. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// List returns the objects whose keys start with prefix, ordered by key
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// SignedURL returns a URL the object can be downloaded from until expiry
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// Config selects and configures a backend
+type Config struct {
+	Backend string
+
+	// LocalRoot is the directory local objects are stored in, and LocalURL
+	// the public URL it is served under
+	LocalRoot string
+	LocalURL  string
+
+	S3 S3Config
+
+	GCSBucket          string
+	GCSCredentialsFile string
+
+	// Prefix is prepended to the keys of the remote backends, so several
+	// stores can share a bucket
+	Prefix string
+}
+
+// Open creates the store selected by cfg
+func Open(ctx context.Context, cfg Config) (Store, error) {
+	var store Store
+	switch cfg.Backend {
+	case "", BackendLocal:
+		return NewLocal(cfg.LocalRoot, cfg.LocalURL), nil
+	case BackendS3:
+		s3, err := NewS3(cfg.S3)
+		if err != nil {
+			return nil, err
+		}
+		store = s3
+	case BackendGCS:
+		gcs, err := OpenGCS(ctx, cfg.GCSBucket, cfg.GCSCredentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		store = gcs
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+	return WithPrefix(store, cfg.Prefix), nil
+}
+
+// ReadAll reads the whole object under key
+func ReadAll(ctx context.Context, store Store, key string) ([]byte, error) {
+	r, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// CleanKey validates a key, returning it without leading slashes
+func CleanKey(key string) (string, error) {
+	key = strings.TrimLeft(key, "/")
+	if key == "" || strings.HasSuffix(key, "/") || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", ErrInvalidKey
+		}
+	}
+	return key, nil
+}
+
+// cleanPrefix validates a List prefix like CleanKey, except that it may be
+// empty, end in a slash or stop partway through a name
+func cleanPrefix(prefix string) (string, error) {
+	prefix = strings.TrimLeft(prefix, "/")
+	if prefix == "" {
+		return "", nil
+	}
+	if strings.Contains(prefix, "\\") {
+		return "", ErrInvalidKey
+	}
+	segments := strings.Split(prefix, "/")
+	for i, segment := range segments {
+		if segment == "." || segment == ".." || (segment == "" && i < len(segments)-1) {
+			return "", ErrInvalidKey
+		}
+	}
+	return prefix, nil
+}
+
+// WithPrefix returns a store that keeps its objects under prefix in store
+func WithPrefix(store Store, prefix string) Store {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return store
+	}
+	return &prefixed{store: store, prefix: prefix + "/"}
+}
+
+type prefixed struct {
+	store  Store
+	prefix string
+}
+
+func (p *prefixed) key(key string) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return p.prefix + key, nil
+}
+
+func (p *prefixed) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	key, err := p.key(key)
+	if err != nil {
+		return err
+	}
+	return p.store.Put(ctx, key, data, contentType)
+}
+
+func (p *prefixed) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	key, err := p.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return p.store.Get(ctx, key)
+}
+
+func (p *prefixed) Stat(ctx context.Context, key string) (*Object, error) {
+	key, err := p.key(key)
+	if err != nil {
+		return nil, err
+	}
+	object, err := p.store.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	object.Key = strings.TrimPrefix(object.Key, p.prefix)
+	return object, nil
+}
+
+func (p *prefixed) Delete(ctx context.Context, key string) error {
+	key, err := p.key(key)
+	if err != nil {
+		return err
+	}
+	return p.store.Delete(ctx, key)
+}
+
+func (p *prefixed) List(ctx context.Context, prefix string) ([]Object, error) {
+	objects, err := p.store.List(ctx, p.prefix+strings.TrimLeft(prefix, "/"))
+	if err != nil {
+		return nil, err
+	}
+	for i := range objects {
+		objects[i].Key = strings.TrimPrefix(objects[i].Key, p.prefix)
+	}
+	return objects, nil
+}
+
+func (p *prefixed) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	key, err := p.key(key)
+	if err != nil {
+		return "", err
+	}
+	return p.store.SignedURL(ctx, key, expiry)
+}
@@ -0,0 +1,327 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Operation is a single RFC 6902 JSON Patch operation
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// JSONPatch applies an RFC 6902 JSON patch to the original document
+func JSONPatch(original, patch []byte) ([]byte, error) {
+	doc, err := decode(original)
+	if err != nil {
+		return nil, fmt.Errorf("invalid original document: %v", err)
+	}
+
+	var ops []Operation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, &MalformedError{Err: err}
+	}
+
+	for _, op := range ops {
+		doc, err = applyOperation(doc, op)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(doc)
+}
+
+// applyOperation applies one operation and returns the new document
+func applyOperation(doc interface{}, op Operation) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, &MalformedError{Err: err}
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			return nil, &MalformedError{Err: fmt.Errorf("%s operation at %q requires a value", op.Op, op.Path)}
+		}
+		value, err := decode(op.Value)
+		if err != nil {
+			return nil, &MalformedError{Err: err}
+		}
+		switch op.Op {
+		case "add":
+			return add(doc, path, value, op)
+		case "replace":
+			return replace(doc, path, value, op)
+		default:
+			current, err := get(doc, path, op)
+			if err != nil {
+				return nil, err
+			}
+			if !equal(current, value) {
+				return nil, &ConflictError{Op: op.Op, Path: op.Path, Msg: "value does not match"}
+			}
+			return doc, nil
+		}
+	case "remove":
+		return remove(doc, path, op)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, &MalformedError{Err: err}
+		}
+		value, err := get(doc, from, op)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if strings.HasPrefix(op.Path+"/", op.From+"/") && op.Path != op.From {
+				return nil, &ConflictError{Op: op.Op, Path: op.Path, Msg: "cannot move a value into one of its children"}
+			}
+			if doc, err = remove(doc, from, op); err != nil {
+				return nil, err
+			}
+		} else {
+			value = deepCopy(value)
+		}
+		return add(doc, path, value, op)
+	default:
+		return nil, &MalformedError{Err: fmt.Errorf("unknown operation %q", op.Op)}
+	}
+}
+
+// parsePointer splits an RFC 6901 JSON pointer into unescaped reference tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		token = strings.ReplaceAll(token, "~1", "/")
+		tokens[i] = strings.ReplaceAll(token, "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex resolves an array reference token; "-" and len are only allowed when appending
+func arrayIndex(token string, length int, appending bool, op Operation) (int, error) {
+	if token == "-" && appending {
+		return length, nil
+	}
+
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, &ConflictError{Op: op.Op, Path: op.Path, Msg: fmt.Sprintf("invalid array index %q", token)}
+	}
+
+	limit := length - 1
+	if appending {
+		limit = length
+	}
+	if index > limit {
+		return 0, &ConflictError{Op: op.Op, Path: op.Path, Msg: fmt.Sprintf("array index %d out of range", index)}
+	}
+	return index, nil
+}
+
+// update walks to the parent of the last token and lets leaf modify it
+func update(doc interface{}, tokens []string, op Operation, leaf func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return leaf(doc, tokens[0])
+	}
+
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		child, ok := container[tokens[0]]
+		if !ok {
+			return nil, &ConflictError{Op: op.Op, Path: op.Path, Msg: "path not found"}
+		}
+		updated, err := update(child, tokens[1:], op, leaf)
+		if err != nil {
+			return nil, err
+		}
+		container[tokens[0]] = updated
+		return container, nil
+	case []interface{}:
+		index, err := arrayIndex(tokens[0], len(container), false, op)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := update(container[index], tokens[1:], op, leaf)
+		if err != nil {
+			return nil, err
+		}
+		container[index] = updated
+		return container, nil
+	default:
+		return nil, &ConflictError{Op: op.Op, Path: op.Path, Msg: "path not found"}
+	}
+}
+
+func get(doc interface{}, tokens []string, op Operation) (interface{}, error) {
+	for _, token := range tokens {
+		switch container := doc.(type) {
+		case map[string]interface{}:
+			value, ok := container[token]
+			if !ok {
+				return nil, &ConflictError{Op: op.Op, Path: op.Path, Msg: "path not found"}
+			}
+			doc = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(container), false, op)
+			if err != nil {
+				return nil, err
+			}
+			doc = container[index]
+		default:
+			return nil, &ConflictError{Op: op.Op, Path: op.Path, Msg: "path not found"}
+		}
+	}
+	return doc, nil
+}
+
+func add(doc interface{}, tokens []string, value interface{}, op Operation) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	return update(doc, tokens, op, func(container interface{}, token string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			c[token] = value
+			return c, nil
+		case []interface{}:
+			index, err := arrayIndex(token, len(c), true, op)
+			if err != nil {
+				return nil, err
+			}
+			c = append(c, nil)
+			copy(c[index+1:], c[index:])
+			c[index] = value
+			return c, nil
+		default:
+			return nil, &ConflictError{Op: op.Op, Path: op.Path, Msg: "parent is not a container"}
+		}
+	})
+}
+
+func remove(doc interface{}, tokens []string, op Operation) (interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, &ConflictError{Op: op.Op, Path: op.Path, Msg: "cannot remove the whole document"}
+	}
+
+	return update(doc, tokens, op, func(container interface{}, token string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			if _, ok := c[token]; !ok {
+				return nil, &ConflictError{Op: op.Op, Path: op.Path, Msg: "path not found"}
+			}
+			delete(c, token)
+			return c, nil
+		case []interface{}:
+			index, err := arrayIndex(token, len(c), false, op)
+			if err != nil {
+				return nil, err
+			}
+			return append(c[:index], c[index+1:]...), nil
+		default:
+			return nil, &ConflictError{Op: op.Op, Path: op.Path, Msg: "parent is not a container"}
+		}
+	})
+}
+
+func replace(doc interface{}, tokens []string, value interface{}, op Operation) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	return update(doc, tokens, op, func(container interface{}, token string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			if _, ok := c[token]; !ok {
+				return nil, &ConflictError{Op: op.Op, Path: op.Path, Msg: "path not found"}
+			}
+			c[token] = value
+			return c, nil
+		case []interface{}:
+			index, err := arrayIndex(token, len(c), false, op)
+			if err != nil {
+				return nil, err
+			}
+			c[index] = value
+			return c, nil
+		default:
+			return nil, &ConflictError{Op: op.Op, Path: op.Path, Msg: "parent is not a container"}
+		}
+	})
+}
+
+// equal compares two decoded JSON values, treating numbers by value
+func equal(a, b interface{}) bool {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for key, value := range av {
+			other, ok := bv[key]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !equal(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		bv, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		af, aerr := av.Float64()
+		bf, berr := bv.Float64()
+		if aerr != nil || berr != nil {
+			return av == bv
+		}
+		return af == bf
+	default:
+		return a == b
+	}
+}
+
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			copied[key] = deepCopy(child)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, child := range v {
+			copied[i] = deepCopy(child)
+		}
+		return copied
+	default:
+		return v
+	}
+}
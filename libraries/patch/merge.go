@@ -0,0 +1,88 @@
+// Package patch applies JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902)
+// documents to JSON encoded resources.
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Media types accepted for patch documents
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+// MergePatch applies an RFC 7396 merge patch to the original document
+func MergePatch(original, patch []byte) ([]byte, error) {
+	doc, err := decode(original)
+	if err != nil {
+		return nil, fmt.Errorf("invalid original document: %v", err)
+	}
+
+	patchDoc, err := decode(patch)
+	if err != nil {
+		return nil, &MalformedError{Err: err}
+	}
+
+	return json.Marshal(mergeValue(doc, patchDoc))
+}
+
+// mergeValue merges a patch value into a target value
+func mergeValue(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergeValue(targetObject[key], value)
+	}
+
+	return targetObject
+}
+
+// decode unmarshals a JSON document keeping numbers exact
+func decode(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// MalformedError reports a patch document that could not be parsed
+type MalformedError struct {
+	Err error
+}
+
+// Error implements the error interface
+func (e *MalformedError) Error() string {
+	return fmt.Sprintf("malformed patch document: %v", e.Err)
+}
+
+// ConflictError reports a patch that cannot be applied to the current document,
+// such as a missing path or a failed test operation
+type ConflictError struct {
+	Op   string
+	Path string
+	Msg  string
+}
+
+// Error implements the error interface
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("cannot apply %s at %q: %s", e.Op, e.Path, e.Msg)
+}
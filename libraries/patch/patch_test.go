@@ -0,0 +1,116 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func assertJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("decoding result: %v", err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("decoding expectation: %v", err)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		patch    string
+		want     string
+	}{
+		{"replace field", `{"name":"Tea","sale_price":10}`, `{"sale_price":12}`, `{"name":"Tea","sale_price":12}`},
+		{"keep untouched fields", `{"name":"Tea","image_recognition_data":"x"}`, `{"name":"Green Tea"}`, `{"name":"Green Tea","image_recognition_data":"x"}`},
+		{"null removes", `{"a":1,"b":2}`, `{"b":null}`, `{"a":1}`},
+		{"nested object", `{"category":{"id":"1","name":"Drinks"}}`, `{"category":{"name":"Beverages"}}`, `{"category":{"id":"1","name":"Beverages"}}`},
+		{"arrays replaced", `{"details":[1,2,3]}`, `{"details":[4]}`, `{"details":[4]}`},
+		{"large numbers kept", `{"qty":9007199254740993}`, `{"name":"x"}`, `{"qty":9007199254740993,"name":"x"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.original), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertJSON(t, got, tt.want)
+		})
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	original := `{"code":"PO-1","purchase_details":[{"id":"a","qty":1},{"id":"b","qty":2}]}`
+
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+	}{
+		{"replace detail field", `[{"op":"replace","path":"/purchase_details/1/qty","value":5}]`,
+			`{"code":"PO-1","purchase_details":[{"id":"a","qty":1},{"id":"b","qty":5}]}`},
+		{"append detail", `[{"op":"add","path":"/purchase_details/-","value":{"id":"c","qty":3}}]`,
+			`{"code":"PO-1","purchase_details":[{"id":"a","qty":1},{"id":"b","qty":2},{"id":"c","qty":3}]}`},
+		{"insert detail", `[{"op":"add","path":"/purchase_details/0","value":{"id":"z","qty":9}}]`,
+			`{"code":"PO-1","purchase_details":[{"id":"z","qty":9},{"id":"a","qty":1},{"id":"b","qty":2}]}`},
+		{"remove detail", `[{"op":"remove","path":"/purchase_details/0"}]`,
+			`{"code":"PO-1","purchase_details":[{"id":"b","qty":2}]}`},
+		{"test then replace", `[{"op":"test","path":"/code","value":"PO-1"},{"op":"replace","path":"/code","value":"PO-2"}]`,
+			`{"code":"PO-2","purchase_details":[{"id":"a","qty":1},{"id":"b","qty":2}]}`},
+		{"move detail", `[{"op":"move","from":"/purchase_details/0","path":"/purchase_details/-"}]`,
+			`{"code":"PO-1","purchase_details":[{"id":"b","qty":2},{"id":"a","qty":1}]}`},
+		{"copy field", `[{"op":"copy","from":"/code","path":"/remark"}]`,
+			`{"code":"PO-1","remark":"PO-1","purchase_details":[{"id":"a","qty":1},{"id":"b","qty":2}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JSONPatch([]byte(original), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertJSON(t, got, tt.want)
+		})
+	}
+}
+
+func TestJSONPatchErrors(t *testing.T) {
+	original := `{"code":"PO-1","purchase_details":[{"id":"a","qty":1}]}`
+
+	conflicts := []string{
+		`[{"op":"test","path":"/code","value":"PO-9"}]`,
+		`[{"op":"replace","path":"/missing","value":1}]`,
+		`[{"op":"remove","path":"/purchase_details/3"}]`,
+		`[{"op":"add","path":"/purchase_details/01","value":1}]`,
+	}
+	for _, p := range conflicts {
+		_, err := JSONPatch([]byte(original), []byte(p))
+		var conflict *ConflictError
+		if !errors.As(err, &conflict) {
+			t.Errorf("patch %s: expected conflict error, got %v", p, err)
+		}
+	}
+
+	malformed := []string{
+		`{"op":"add"}`,
+		`[{"op":"frobnicate","path":"/code"}]`,
+		`[{"op":"add","path":"code","value":1}]`,
+		`[{"op":"replace","path":"/code"}]`,
+	}
+	for _, p := range malformed {
+		_, err := JSONPatch([]byte(original), []byte(p))
+		var bad *MalformedError
+		if !errors.As(err, &bad) {
+			t.Errorf("patch %s: expected malformed error, got %v", p, err)
+		}
+	}
+}
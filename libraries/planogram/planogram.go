@@ -0,0 +1,133 @@
+// Package planogram compares the products detected on a shelf photo with the
+// stock the shelf should hold and the planogram laying it out.
+package planogram
+
+import (
+	"image"
+	"sort"
+)
+
+// Issues reported for a product on a shelf
+const (
+	// IssueMissing: the product should be on the shelf but was not detected
+	IssueMissing = "missing"
+	// IssueMisplaced: the product was detected but the planogram does not place it on the shelf
+	IssueMisplaced = "misplaced"
+	// IssueShortFacings: the product was detected in fewer regions than its planned facings
+	IssueShortFacings = "short_facings"
+	// IssueCountMismatch: the detected count differs from the expected stock
+	IssueCountMismatch = "count_mismatch"
+)
+
+// Slot places a product on a shelf with the number of facings it should show
+type Slot struct {
+	ProductCode string `firestore:"product_code" json:"product_code"`
+	Facings     int    `firestore:"facings" json:"facings"`
+}
+
+// Line is the comparison for one product. Difference is the detected count
+// less the expected stock.
+type Line struct {
+	ProductCode string   `firestore:"product_code" json:"product_code"`
+	Detected    int      `firestore:"detected" json:"detected"`
+	Expected    float64  `firestore:"expected" json:"expected"`
+	Planned     int      `firestore:"planned" json:"planned"`
+	InPlanogram bool     `firestore:"in_planogram" json:"in_planogram"`
+	Difference  float64  `firestore:"difference" json:"difference"`
+	Issues      []string `firestore:"issues" json:"issues"`
+}
+
+// Compare builds a line for every product that was detected, is expected on
+// the shelf or is in the planogram, sorted by product code. Without a
+// planogram no product is reported misplaced.
+func Compare(detected map[string]int, expected map[string]float64, slots []Slot) []Line {
+	planned := map[string]int{}
+	for _, slot := range slots {
+		planned[slot.ProductCode] += slot.Facings
+	}
+
+	codes := map[string]bool{}
+	for code := range detected {
+		codes[code] = true
+	}
+	for code, qty := range expected {
+		if qty != 0 {
+			codes[code] = true
+		}
+	}
+	for code := range planned {
+		codes[code] = true
+	}
+
+	lines := make([]Line, 0, len(codes))
+	for code := range codes {
+		_, inPlanogram := planned[code]
+		line := Line{
+			ProductCode: code,
+			Detected:    detected[code],
+			Expected:    expected[code],
+			Planned:     planned[code],
+			InPlanogram: inPlanogram,
+			Issues:      []string{},
+		}
+		line.Difference = float64(line.Detected) - line.Expected
+
+		switch {
+		case line.Detected == 0 && (line.InPlanogram || line.Expected > 0):
+			line.Issues = append(line.Issues, IssueMissing)
+		case line.Detected > 0 && len(slots) > 0 && !line.InPlanogram:
+			line.Issues = append(line.Issues, IssueMisplaced)
+		}
+		if line.Detected > 0 && line.Detected < line.Planned {
+			line.Issues = append(line.Issues, IssueShortFacings)
+		}
+		if line.Difference != 0 {
+			line.Issues = append(line.Issues, IssueCountMismatch)
+		}
+		lines = append(lines, line)
+	}
+
+	sort.Slice(lines, func(i, j int) bool { return lines[i].ProductCode < lines[j].ProductCode })
+	return lines
+}
+
+// Discrepancies returns the lines that have an issue
+func Discrepancies(lines []Line) []Line {
+	found := []Line{}
+	for _, line := range lines {
+		if len(line.Issues) > 0 {
+			found = append(found, line)
+		}
+	}
+	return found
+}
+
+// Tiles splits bounds into a grid of cols by rows regions, left to right and
+// top to bottom. The last column and row take up the remaining pixels.
+func Tiles(bounds image.Rectangle, cols, rows int) []image.Rectangle {
+	if cols < 1 {
+		cols = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	width, height := bounds.Dx()/cols, bounds.Dy()/rows
+
+	tiles := make([]image.Rectangle, 0, cols*rows)
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			tile := image.Rect(
+				bounds.Min.X+col*width, bounds.Min.Y+row*height,
+				bounds.Min.X+(col+1)*width, bounds.Min.Y+(row+1)*height,
+			)
+			if col == cols-1 {
+				tile.Max.X = bounds.Max.X
+			}
+			if row == rows-1 {
+				tile.Max.Y = bounds.Max.Y
+			}
+			tiles = append(tiles, tile)
+		}
+	}
+	return tiles
+}
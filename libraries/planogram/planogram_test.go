@@ -0,0 +1,65 @@
+package planogram
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	detected := map[string]int{"A": 3, "B": 1, "C": 2}
+	expected := map[string]float64{"A": 3, "B": 4, "D": 5}
+	slots := []Slot{{ProductCode: "A", Facings: 2}, {ProductCode: "B", Facings: 2}, {ProductCode: "E", Facings: 1}}
+
+	lines := Compare(detected, expected, slots)
+	issues := map[string][]string{}
+	for _, line := range lines {
+		issues[line.ProductCode] = line.Issues
+	}
+
+	want := map[string][]string{
+		"A": {},
+		"B": {IssueShortFacings, IssueCountMismatch},
+		"C": {IssueMisplaced, IssueCountMismatch},
+		"D": {IssueMissing, IssueCountMismatch},
+		"E": {IssueMissing},
+	}
+	if !reflect.DeepEqual(issues, want) {
+		t.Fatalf("issues = %v, want %v", issues, want)
+	}
+	if lines[1].Difference != -3 {
+		t.Errorf("difference of B = %v, want -3", lines[1].Difference)
+	}
+	if got := len(Discrepancies(lines)); got != 4 {
+		t.Errorf("%d discrepancies, want 4", got)
+	}
+}
+
+func TestCompareWithoutPlanogram(t *testing.T) {
+	lines := Compare(map[string]int{"A": 1}, nil, nil)
+	if len(lines) != 1 || !reflect.DeepEqual(lines[0].Issues, []string{IssueCountMismatch}) {
+		t.Fatalf("lines = %+v, want only a count mismatch", lines)
+	}
+}
+
+func TestTiles(t *testing.T) {
+	bounds := image.Rect(10, 20, 110, 91)
+	tiles := Tiles(bounds, 3, 2)
+	if len(tiles) != 6 {
+		t.Fatalf("%d tiles, want 6", len(tiles))
+	}
+	if tiles[0] != image.Rect(10, 20, 43, 55) {
+		t.Errorf("first tile = %v", tiles[0])
+	}
+	if tiles[5] != image.Rect(76, 55, 110, 91) {
+		t.Errorf("last tile = %v", tiles[5])
+	}
+
+	area := 0
+	for _, tile := range tiles {
+		area += tile.Dx() * tile.Dy()
+	}
+	if area != bounds.Dx()*bounds.Dy() {
+		t.Errorf("tiles cover %d pixels, want %d", area, bounds.Dx()*bounds.Dy())
+	}
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit is a token bucket: Requests tokens are added every Per, up to Burst
+type Limit struct {
+	Requests int
+	Per      time.Duration
+	Burst    int
+}
+
+// PerMinute is a limit of n requests a minute with the given burst
+func PerMinute(n, burst int) Limit {
+	return Limit{Requests: n, Per: time.Minute, Burst: burst}
+}
+
+// PerSecond is a limit of n requests a second with the given burst
+func PerSecond(n, burst int) Limit {
+	return Limit{Requests: n, Per: time.Second, Burst: burst}
+}
+
+// Rate returns the number of tokens added per second
+func (l Limit) Rate() float64 {
+	if l.Per <= 0 {
+		return 0
+	}
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// Enabled reports whether the limit restricts anything
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Per > 0 && l.Burst > 0
+}
+
+// Result is the outcome of taking tokens
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Store keeps token buckets
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, n int) (Result, error)
+}
+
+// Bucket is the state of a token bucket
+type Bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// Take refills the bucket for the time passed since it was last updated and
+// takes n tokens if that many are available; otherwise it takes none. With n
+// of 0 it only checks that a token is left. Stores use it to share the
+// arithmetic.
+func (b *Bucket) Take(limit Limit, now time.Time, n int) Result {
+	b.Refill(limit, now)
+
+	need := math.Max(float64(n), 1)
+	if b.Tokens >= need {
+		b.Tokens -= float64(n)
+		return Result{Allowed: true, Remaining: int(b.Tokens)}
+	}
+
+	wait := (need - b.Tokens) / limit.Rate()
+	return Result{RetryAfter: time.Duration(wait * float64(time.Second))}
+}
+
+// Refill adds the tokens gained since the bucket was last updated. A bucket
+// never updated starts full.
+func (b *Bucket) Refill(limit Limit, now time.Time) {
+	if b.Updated.IsZero() {
+		b.Tokens = float64(limit.Burst)
+	} else if elapsed := now.Sub(b.Updated).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(float64(limit.Burst), b.Tokens+elapsed*limit.Rate())
+	}
+	b.Updated = now
+}
+
+// Full reports whether the bucket has refilled completely by now, so a store can forget it
+func (b *Bucket) Full(limit Limit, now time.Time) bool {
+	return b.Tokens+now.Sub(b.Updated).Seconds()*limit.Rate() >= float64(limit.Burst)
+}
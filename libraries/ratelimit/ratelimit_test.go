@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBucketTake(t *testing.T) {
+	limit := PerSecond(2, 3)
+	now := time.Now()
+
+	var bucket Bucket
+	for i := 0; i < 3; i++ {
+		if result := bucket.Take(limit, now, 1); !result.Allowed {
+			t.Fatalf("request %d: expected burst to allow it", i+1)
+		}
+	}
+
+	result := bucket.Take(limit, now, 1)
+	if result.Allowed {
+		t.Fatal("expected empty bucket to reject")
+	}
+	if result.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("retry after: got %v, want 500ms", result.RetryAfter)
+	}
+
+	if result := bucket.Take(limit, now.Add(500*time.Millisecond), 1); !result.Allowed {
+		t.Fatal("expected a token after refilling")
+	}
+	if !bucket.Full(limit, now.Add(10*time.Second)) {
+		t.Fatal("expected bucket to be full after a long pause")
+	}
+}
+
+func TestBucketTakeMany(t *testing.T) {
+	limit := PerSecond(2, 5)
+	now := time.Now()
+
+	var bucket Bucket
+	if result := bucket.Take(limit, now, 4); !result.Allowed || result.Remaining != 1 {
+		t.Fatalf("expected 4 of 5 tokens to be taken, got %+v", result)
+	}
+
+	result := bucket.Take(limit, now, 3)
+	if result.Allowed {
+		t.Fatal("expected taking more tokens than are left to reject")
+	}
+	if result.RetryAfter != time.Second {
+		t.Fatalf("retry after: got %v, want 1s", result.RetryAfter)
+	}
+	if result := bucket.Take(limit, now, 0); !result.Allowed || result.Remaining != 1 {
+		t.Fatalf("expected checking to leave the last token, got %+v", result)
+	}
+	if result := bucket.Take(limit, now, 1); !result.Allowed {
+		t.Fatal("expected a rejected take to leave the tokens in the bucket")
+	}
+	if result := bucket.Take(limit, now, 0); result.Allowed {
+		t.Fatal("expected checking an empty bucket to reject")
+	}
+}
+
+func TestMemoryStoreKeys(t *testing.T) {
+	store := NewMemoryStore()
+	limit := PerMinute(1, 1)
+
+	if result, _ := store.Take(context.Background(), "a", limit, 1); !result.Allowed {
+		t.Fatal("expected first request for a to be allowed")
+	}
+	if result, _ := store.Take(context.Background(), "a", limit, 1); result.Allowed {
+		t.Fatal("expected second request for a to be rejected")
+	}
+	if result, _ := store.Take(context.Background(), "b", limit, 1); !result.Allowed {
+		t.Fatal("expected b to have its own bucket")
+	}
+}
@@ -0,0 +1,41 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+// FirebaseVerifier verifies Firebase ID tokens, including revocation of the
+// user's refresh tokens
+type FirebaseVerifier struct {
+	client *auth.Client
+}
+
+// NewFirebaseVerifier creates a new FirebaseVerifier
+func NewFirebaseVerifier(client *auth.Client) *FirebaseVerifier {
+	return &FirebaseVerifier{
+		client: client,
+	}
+}
+
+// Verify checks a Firebase ID token
+func (v *FirebaseVerifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	decoded, err := v.client.VerifyIDTokenAndCheckRevoked(ctx, token)
+	if auth.IsIDTokenRevoked(err) || auth.IsUserDisabled(err) {
+		return nil, ErrTokenRevoked
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	email, _ := decoded.Claims["email"].(string)
+	return &Claims{
+		UID:       decoded.UID,
+		Email:     email,
+		IssuedAt:  time.Unix(decoded.IssuedAt, 0),
+		ExpiresAt: time.Unix(decoded.Expires, 0),
+	}, nil
+}
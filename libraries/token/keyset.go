@@ -0,0 +1,232 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+)
+
+// rsaKeyBits is the size of generated signing keys
+const rsaKeyBits = 2048
+
+// minLookupRefresh limits how often an unknown key ID reloads the keys, so
+// tokens with made up key IDs cannot hammer the store
+const minLookupRefresh = 30 * time.Second
+
+// SigningKey is an RSA key used to sign local tokens, identified by its key ID
+type SigningKey struct {
+	ID         string
+	PrivateKey *rsa.PrivateKey
+	CreatedAt  time.Time
+}
+
+// KeyStore persists signing keys so every instance signs and verifies with the same keys
+type KeyStore interface {
+	Load(ctx context.Context) ([]*SigningKey, error)
+	Save(ctx context.Context, key *SigningKey) error
+	Delete(ctx context.Context, id string) error
+}
+
+// JWK is the public half of a signing key in JSON Web Key format
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+// KeySet holds the signing keys of the local token issuer. A new key is
+// generated every rotation period; older keys remain published until every
+// token they signed has expired.
+type KeySet struct {
+	store     KeyStore
+	rotation  time.Duration
+	retention time.Duration
+
+	mu   sync.RWMutex
+	keys []*SigningKey
+
+	// refreshMu serializes reloads on unknown key IDs
+	refreshMu     sync.Mutex
+	lookupRefresh time.Time
+}
+
+// NewKeySet creates a new KeySet. Keys are kept for rotation plus retention,
+// where retention must cover the lifetime of the tokens they sign.
+func NewKeySet(store KeyStore, rotation, retention time.Duration) *KeySet {
+	return &KeySet{
+		store:     store,
+		rotation:  rotation,
+		retention: retention,
+	}
+}
+
+// Refresh reloads the keys from the store, generating a key when the newest
+// one is due for rotation and dropping keys past retention
+func (k *KeySet) Refresh(ctx context.Context) error {
+	keys, err := k.store.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load signing keys: %v", err)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+
+	if len(keys) == 0 || time.Since(keys[0].CreatedAt) >= k.rotation {
+		key, err := generateSigningKey()
+		if err != nil {
+			return err
+		}
+		if err := k.store.Save(ctx, key); err != nil {
+			return fmt.Errorf("failed to save signing key: %v", err)
+		}
+		keys = append([]*SigningKey{key}, keys...)
+	}
+
+	live := keys[:0]
+	for _, key := range keys {
+		if time.Since(key.CreatedAt) > k.rotation+k.retention {
+			if err := k.store.Delete(ctx, key.ID); err != nil {
+				log.Printf("Error deleting expired signing key %s: %v", key.ID, err)
+			}
+			continue
+		}
+		live = append(live, key)
+	}
+
+	k.mu.Lock()
+	k.keys = live
+	k.mu.Unlock()
+	return nil
+}
+
+// Start refreshes the keys in the background until ctx is cancelled, so
+// rotations made by other instances are picked up
+func (k *KeySet) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := k.Refresh(ctx); err != nil {
+					log.Printf("Error refreshing signing keys: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Current returns the newest key, used for signing
+func (k *KeySet) Current() (*SigningKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if len(k.keys) == 0 {
+		return nil, errors.New("no signing key available")
+	}
+	return k.keys[0], nil
+}
+
+// Lookup returns the public key with the given key ID. An unknown key ID
+// reloads the keys once, at most every minLookupRefresh, so keys rotated in
+// by another instance are found before the next background refresh.
+func (k *KeySet) Lookup(ctx context.Context, id string) (*rsa.PublicKey, bool) {
+	if key, ok := k.find(id); ok {
+		return key, true
+	}
+
+	k.refreshMu.Lock()
+	defer k.refreshMu.Unlock()
+	// Another lookup may have reloaded the keys while this one waited
+	if key, ok := k.find(id); ok {
+		return key, true
+	}
+	if time.Since(k.lookupRefresh) < minLookupRefresh {
+		return nil, false
+	}
+	k.lookupRefresh = time.Now()
+	if err := k.Refresh(ctx); err != nil {
+		log.Printf("Error refreshing signing keys for key %q: %v", id, err)
+		return nil, false
+	}
+	return k.find(id)
+}
+
+func (k *KeySet) find(id string) (*rsa.PublicKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for _, key := range k.keys {
+		if key.ID == id {
+			return &key.PrivateKey.PublicKey, true
+		}
+	}
+	return nil, false
+}
+
+// JWKS returns the published public keys
+func (k *KeySet) JWKS() []JWK {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	jwks := make([]JWK, 0, len(k.keys))
+	for _, key := range k.keys {
+		public := key.PrivateKey.PublicKey
+		jwks = append(jwks, JWK{
+			KeyType:   "RSA",
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: "RS256",
+			Modulus:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		})
+	}
+	return jwks
+}
+
+// EncodePrivateKey renders a private key as PKCS#1 PEM for storage
+func EncodePrivateKey(key *rsa.PrivateKey) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+}
+
+// DecodePrivateKey parses a PKCS#1 PEM private key
+func DecodePrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("invalid private key PEM")
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+func generateSigningKey() (*SigningKey, error) {
+	private, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %v", err)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	return &SigningKey{
+		ID:         hex.EncodeToString(id),
+		PrivateKey: private,
+		CreatedAt:  time.Now(),
+	}, nil
+}
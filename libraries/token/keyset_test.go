@@ -0,0 +1,203 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// memoryKeyStore is a KeyStore shared by the key sets of several instances
+type memoryKeyStore struct {
+	mu    sync.Mutex
+	keys  map[string]*SigningKey
+	loads int
+}
+
+func newMemoryKeyStore() *memoryKeyStore {
+	return &memoryKeyStore{keys: map[string]*SigningKey{}}
+}
+
+func (s *memoryKeyStore) Load(ctx context.Context) ([]*SigningKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loads++
+	var keys []*SigningKey
+	for _, key := range s.keys {
+		copied := *key
+		keys = append(keys, &copied)
+	}
+	return keys, nil
+}
+
+func (s *memoryKeyStore) Save(ctx context.Context, key *SigningKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.ID] = key
+	return nil
+}
+
+func (s *memoryKeyStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, id)
+	return nil
+}
+
+func (s *memoryKeyStore) loadCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loads
+}
+
+// revokedIDs is a RevocationChecker of revoked token IDs
+type revokedIDs map[string]bool
+
+func (r revokedIDs) IsRevoked(ctx context.Context, claims *Claims) (bool, error) {
+	return r[claims.ID], nil
+}
+
+func newIssuer(t *testing.T, store KeyStore, rotation time.Duration, revocations RevocationChecker) *LocalIssuer {
+	t.Helper()
+	keys := NewKeySet(store, rotation, time.Hour)
+	if err := keys.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	return NewLocalIssuer(keys, "godam-backend", "godam-api", time.Minute, revocations)
+}
+
+func TestLocalIssuerVerify(t *testing.T) {
+	ctx := context.Background()
+	revoked := revokedIDs{}
+	issuer := newIssuer(t, newMemoryKeyStore(), time.Hour, revoked)
+
+	signed, issued, err := issuer.Issue("user-1", "user@example.com")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	claims, err := issuer.Verify(ctx, signed)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if claims.UID != "user-1" || claims.Email != "user@example.com" || claims.ID != issued.ID {
+		t.Errorf("claims = %+v, want those issued %+v", claims, issued)
+	}
+
+	if _, err := issuer.Verify(ctx, signed[:len(signed)-2]+"xx"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify with a tampered signature = %v, want ErrInvalidToken", err)
+	}
+
+	other := NewLocalIssuer(issuer.Keys(), "godam-backend", "other-api", time.Minute, nil)
+	if _, err := other.Verify(ctx, signed); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify for another audience = %v, want ErrInvalidToken", err)
+	}
+
+	expired := NewLocalIssuer(issuer.Keys(), "godam-backend", "godam-api", -time.Minute, nil)
+	stale, _, err := expired.Issue("user-1", "user@example.com")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if _, err := issuer.Verify(ctx, stale); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify of an expired token = %v, want ErrInvalidToken", err)
+	}
+
+	hs256, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{Subject: "user-1"}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("signing: %v", err)
+	}
+	if _, err := issuer.Verify(ctx, hs256); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify of an HS256 token = %v, want ErrInvalidToken", err)
+	}
+
+	revoked[issued.ID] = true
+	if _, err := issuer.Verify(ctx, signed); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Verify of a revoked token = %v, want ErrTokenRevoked", err)
+	}
+}
+
+func TestKeySetRotationAcrossInstances(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryKeyStore()
+	first := newIssuer(t, store, time.Hour, nil)
+	second := newIssuer(t, store, time.Hour, nil)
+
+	before, _, err := first.Issue("user-1", "")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	// The first instance rotates; the second has not refreshed since
+	if err := NewKeySet(store, time.Nanosecond, time.Hour).Refresh(ctx); err != nil {
+		t.Fatalf("rotating: %v", err)
+	}
+	if err := first.Keys().Refresh(ctx); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	after, _, err := first.Issue("user-1", "")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	loads := store.loadCount()
+	if _, err := second.Verify(ctx, after); err != nil {
+		t.Errorf("Verify of a token signed with a rotated key = %v, want the key set to reload", err)
+	}
+	if store.loadCount() != loads+1 {
+		t.Errorf("unknown key reloaded the store %d times, want once", store.loadCount()-loads)
+	}
+	if _, err := second.Verify(ctx, before); err != nil {
+		t.Errorf("Verify of a token signed before rotating = %v", err)
+	}
+	if len(second.Keys().JWKS()) != 2 {
+		t.Errorf("published %d keys, want the old and the new one", len(second.Keys().JWKS()))
+	}
+
+	// Unknown key IDs reload the store at most every minLookupRefresh
+	loads = store.loadCount()
+	for i := 0; i < 5; i++ {
+		if _, ok := second.Keys().Lookup(ctx, "made-up"); ok {
+			t.Fatal("found a made up key")
+		}
+	}
+	if store.loadCount() != loads {
+		t.Errorf("made up key IDs reloaded the store %d times, want none so soon after a reload", store.loadCount()-loads)
+	}
+}
+
+func TestKeySetRetention(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryKeyStore()
+	old, err := generateSigningKey()
+	if err != nil {
+		t.Fatalf("generateSigningKey: %v", err)
+	}
+	old.CreatedAt = time.Now().Add(-3 * time.Hour)
+	recent, err := generateSigningKey()
+	if err != nil {
+		t.Fatalf("generateSigningKey: %v", err)
+	}
+	recent.CreatedAt = time.Now().Add(-90 * time.Minute)
+	store.Save(ctx, old)
+	store.Save(ctx, recent)
+
+	keys := NewKeySet(store, time.Hour, time.Hour)
+	if err := keys.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if _, ok := keys.Lookup(ctx, old.ID); ok {
+		t.Error("key past rotation and retention is still published")
+	}
+	if _, ok := keys.Lookup(ctx, recent.ID); !ok {
+		t.Error("key within retention is no longer published")
+	}
+	current, err := keys.Current()
+	if err != nil || current.ID == recent.ID || current.ID == old.ID {
+		t.Errorf("Current = %v, %v, want a newly generated key", current, err)
+	}
+	if _, ok := store.keys[old.ID]; ok {
+		t.Error("expired key was not deleted from the store")
+	}
+}
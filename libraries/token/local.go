@@ -0,0 +1,132 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// localClaims are the JWT claims of locally issued access tokens
+type localClaims struct {
+	Email string `json:"email,omitempty"`
+	jwt.StandardClaims
+}
+
+// LocalIssuer issues and verifies RS256 access tokens signed with a rotating
+// key set, for deployments that do not use Firebase Authentication
+type LocalIssuer struct {
+	keys        *KeySet
+	issuer      string
+	audience    string
+	ttl         time.Duration
+	revocations RevocationChecker
+}
+
+// NewLocalIssuer creates a new LocalIssuer. Revocations may be nil.
+func NewLocalIssuer(keys *KeySet, issuer, audience string, ttl time.Duration, revocations RevocationChecker) *LocalIssuer {
+	return &LocalIssuer{
+		keys:        keys,
+		issuer:      issuer,
+		audience:    audience,
+		ttl:         ttl,
+		revocations: revocations,
+	}
+}
+
+// Issue signs an access token for a user
+func (l *LocalIssuer) Issue(uid, email string) (string, *Claims, error) {
+	key, err := l.keys.Current()
+	if err != nil {
+		return "", nil, err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		ID:        hex.EncodeToString(id),
+		UID:       uid,
+		Email:     email,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(l.ttl),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, localClaims{
+		Email: email,
+		StandardClaims: jwt.StandardClaims{
+			Id:        claims.ID,
+			Subject:   uid,
+			Issuer:    l.issuer,
+			Audience:  l.audience,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: claims.ExpiresAt.Unix(),
+		},
+	})
+	token.Header["kid"] = key.ID
+
+	signed, err := token.SignedString(key.PrivateKey)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to sign token: %v", err)
+	}
+	return signed, claims, nil
+}
+
+// Verify checks the signature, issuer, audience, expiry and revocation of a local access token
+func (l *LocalIssuer) Verify(ctx context.Context, token string) (*Claims, error) {
+	parsed, err := jwt.ParseWithClaims(token, &localClaims{}, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		kid, _ := t.Header["kid"].(string)
+		key, ok := l.keys.Lookup(ctx, kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	})
+	if err != nil || !parsed.Valid {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	standard := parsed.Claims.(*localClaims)
+	if !standard.VerifyIssuer(l.issuer, true) || !standard.VerifyAudience(l.audience, true) || standard.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+
+	claims := &Claims{
+		ID:        standard.Id,
+		UID:       standard.Subject,
+		Email:     standard.Email,
+		IssuedAt:  time.Unix(standard.IssuedAt, 0),
+		ExpiresAt: time.Unix(standard.ExpiresAt, 0),
+	}
+
+	if l.revocations != nil {
+		revoked, err := l.revocations.IsRevoked(ctx, claims)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+
+	return claims, nil
+}
+
+// Keys returns the key set used for signing
+func (l *LocalIssuer) Keys() *KeySet {
+	return l.keys
+}
+
+// TTL returns the lifetime of issued access tokens
+func (l *LocalIssuer) TTL() time.Duration {
+	return l.ttl
+}
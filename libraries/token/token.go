@@ -1,72 +0,0 @@
-package token
-
-import (
-	"net/http"
-	"os"
-	"strings"
-	"time"
-
-	jwt "github.com/dgrijalva/jwt-go"
-	"github.com/nirshpaa/godam-backend/libraries/handlers"
-)
-
-type MyCustomClaims struct {
-	Username string `json:"username"`
-	jwt.StandardClaims
-}
-
-var mySigningKey = []byte(os.Getenv("TOKEN_SALT"))
-
-func ValidateToken(myToken string) (bool, string) {
-	token, err := jwt.ParseWithClaims(myToken, &MyCustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(mySigningKey), nil
-	})
-
-	if err != nil {
-		return false, ""
-	}
-
-	if claims, ok := token.Claims.(*MyCustomClaims); ok && token.Valid {
-		return true, claims.Username
-	}
-	return false, ""
-}
-
-func ClaimToken(username string) (string, error) {
-	claims := MyCustomClaims{
-		username,
-		jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * 24).Unix(),
-			IssuedAt:  time.Now().Unix(),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(mySigningKey)
-}
-
-// AuthMiddleware is a middleware that validates JWT tokens
-func AuthMiddleware(next handlers.Handler) handlers.Handler {
-	return func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Authorization header required", http.StatusUnauthorized)
-			return
-		}
-
-		// Extract token from Bearer header
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
-			return
-		}
-
-		valid, _ := ValidateToken(tokenString)
-		if !valid {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
-		}
-
-		next(w, r)
-	}
-}
@@ -0,0 +1,33 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrInvalidToken is returned for tokens that are malformed, expired or wrongly signed
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenRevoked is returned for tokens that were revoked before they expired
+	ErrTokenRevoked = errors.New("token has been revoked")
+)
+
+// Claims are the verified facts about the caller carried by a token
+type Claims struct {
+	ID        string
+	UID       string
+	Email     string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// TokenVerifier checks a bearer token and returns its claims
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (*Claims, error)
+}
+
+// RevocationChecker reports whether verified claims have been revoked
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, claims *Claims) (bool, error)
+}
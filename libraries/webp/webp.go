@@ -0,0 +1,64 @@
+// Package webp encodes images as lossy WebP through libwebp, which has to be
+// installed for cgo to build it (libwebp-dev on Debian and Ubuntu). Images
+// with transparency keep it in a lossless alpha plane.
+package webp
+
+// #cgo pkg-config: libwebp
+// #include <webp/encode.h>
+import "C"
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+	"unsafe"
+)
+
+// maxDimension is the largest width or height WebP can store
+const maxDimension = 16383
+
+var (
+	// ErrTooLarge is returned for images wider or taller than WebP allows
+	ErrTooLarge = errors.New("webp: image is too large")
+	// ErrEmpty is returned for images without pixels
+	ErrEmpty = errors.New("webp: image is empty")
+)
+
+// Encode writes img to w as a lossy WebP file. quality runs from 0, the
+// smallest file, to 100, the best picture, like JPEG quality.
+func Encode(w io.Writer, img image.Image, quality int) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return ErrEmpty
+	}
+	if width > maxDimension || height > maxDimension {
+		return ErrTooLarge
+	}
+
+	nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+
+	// libwebp only reads the pixels during the call, and they hold no Go
+	// pointers, so they are passed without copying
+	var output *C.uint8_t
+	var size C.size_t
+	if nrgba.Opaque() {
+		rgb := make([]byte, 0, 3*width*height)
+		for i := 0; i < len(nrgba.Pix); i += 4 {
+			rgb = append(rgb, nrgba.Pix[i:i+3]...)
+		}
+		size = C.WebPEncodeRGB((*C.uint8_t)(unsafe.Pointer(&rgb[0])), C.int(width), C.int(height), C.int(3*width), C.float(quality), &output)
+	} else {
+		pix := (*C.uint8_t)(unsafe.Pointer(&nrgba.Pix[0]))
+		size = C.WebPEncodeRGBA(pix, C.int(width), C.int(height), C.int(nrgba.Stride), C.float(quality), &output)
+	}
+	if size == 0 {
+		return errors.New("webp: libwebp failed to encode the image")
+	}
+	defer C.WebPFree(unsafe.Pointer(output))
+
+	_, err := w.Write(C.GoBytes(unsafe.Pointer(output), C.int(size)))
+	return err
+}
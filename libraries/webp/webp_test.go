@@ -0,0 +1,128 @@
+package webp
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"testing"
+
+	xwebp "golang.org/x/image/webp"
+)
+
+// photo draws smooth shading with a little sensor noise, like a product photo
+func photo(width, height int, alpha bool) *image.NRGBA {
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			noise := rng.Intn(9) - 4
+			c := color.NRGBA{
+				R: uint8(clamp(60 + x*150/width + noise)),
+				G: uint8(clamp(90 + y*120/height + noise)),
+				B: uint8(clamp(200 - (x+y)*100/(width+height) + noise)),
+				A: 255,
+			}
+			if alpha && (x-width/2)*(x-width/2)+(y-height/2)*(y-height/2) > width*height/6 {
+				c.A = 0
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func clamp(v int) int {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return v
+}
+
+func encode(t *testing.T, img image.Image, quality int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Encode(&buf, img, quality); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestEncodeDecodes(t *testing.T) {
+	for _, alpha := range []bool{false, true} {
+		img := photo(67, 45, alpha)
+		decoded, err := xwebp.Decode(bytes.NewReader(encode(t, img, 85)))
+		if err != nil {
+			t.Fatalf("Decode with alpha %v: %v", alpha, err)
+		}
+		if decoded.Bounds().Dx() != 67 || decoded.Bounds().Dy() != 45 {
+			t.Fatalf("size = %v, want 67x45", decoded.Bounds())
+		}
+
+		// Lossy colours stay close, give or take the noise smoothed away; the
+		// alpha plane is kept exactly
+		diff, pixels := 0, 0
+		for y := 0; y < 45; y++ {
+			for x := 0; x < 67; x++ {
+				want := img.NRGBAAt(x, y)
+				got := color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA)
+				if got.A != want.A {
+					t.Fatalf("alpha at (%d, %d) = %d, want %d", x, y, got.A, want.A)
+				}
+				if want.A == 0 {
+					continue
+				}
+				diff += abs(int(got.R)-int(want.R)) + abs(int(got.G)-int(want.G)) + abs(int(got.B)-int(want.B))
+				pixels += 3
+			}
+		}
+		if mean := float64(diff) / float64(pixels); mean > 8 {
+			t.Errorf("with alpha %v, colours are off by %.1f on average, want at most 8", alpha, mean)
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func TestEncodeSmallerThanJPEGAndPNG(t *testing.T) {
+	opaque := photo(320, 240, false)
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, opaque, &jpeg.Options{Quality: 85}); err != nil {
+		t.Fatal(err)
+	}
+	if size := len(encode(t, opaque, 85)); size >= jpg.Len() {
+		t.Errorf("WebP of a photo is %d bytes, want less than the %d of the JPEG", size, jpg.Len())
+	}
+
+	transparent := photo(320, 240, true)
+	var pngBuf bytes.Buffer
+	if err := png.Encode(&pngBuf, transparent); err != nil {
+		t.Fatal(err)
+	}
+	if size := len(encode(t, transparent, 85)); size >= pngBuf.Len()/2 {
+		t.Errorf("WebP of a transparent photo is %d bytes, want less than half the %d of the PNG", size, pngBuf.Len())
+	}
+
+	if low, high := len(encode(t, opaque, 50)), len(encode(t, opaque, 95)); low >= high {
+		t.Errorf("quality 50 gives %d bytes and 95 gives %d, want fewer at the lower quality", low, high)
+	}
+}
+
+func TestEncodeRejectsSizes(t *testing.T) {
+	if err := Encode(&bytes.Buffer{}, image.NewGray(image.Rect(0, 0, maxDimension+1, 1)), 85); err != ErrTooLarge {
+		t.Errorf("Encode too wide = %v, want ErrTooLarge", err)
+	}
+	if err := Encode(&bytes.Buffer{}, image.NewGray(image.Rect(0, 0, 0, 5)), 85); err != ErrEmpty {
+		t.Errorf("Encode empty = %v, want ErrEmpty", err)
+	}
+}
@@ -0,0 +1,54 @@
+// Package workpool runs a fixed number of tasks on a bounded number of
+// goroutines.
+package workpool
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Run calls task for each index below n with at most workers calls running
+// at once. Each call gets a context cancelled after timeout, or never when
+// timeout is zero. Once ctx is done no further calls are started. Run
+// returns when every started call has returned.
+func Run(ctx context.Context, n, workers int, timeout time.Duration, task func(ctx context.Context, i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				run(ctx, timeout, i, task)
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+func run(ctx context.Context, timeout time.Duration, i int, task func(ctx context.Context, i int)) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	task(ctx, i)
+}
@@ -0,0 +1,62 @@
+package workpool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunBoundsConcurrency(t *testing.T) {
+	var running, peak int32
+	var mu sync.Mutex
+	done := map[int]bool{}
+
+	Run(context.Background(), 20, 3, 0, func(ctx context.Context, i int) {
+		current := atomic.AddInt32(&running, 1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if current <= old || atomic.CompareAndSwapInt32(&peak, old, current) {
+				break
+			}
+		}
+		time.Sleep(2 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+
+		mu.Lock()
+		done[i] = true
+		mu.Unlock()
+	})
+
+	if len(done) != 20 {
+		t.Errorf("ran %d tasks, want 20", len(done))
+	}
+	if peak > 3 {
+		t.Errorf("%d tasks ran at once, want at most 3", peak)
+	}
+}
+
+func TestRunTimeoutAndCancel(t *testing.T) {
+	var timedOut int32
+	Run(context.Background(), 2, 2, 5*time.Millisecond, func(ctx context.Context, i int) {
+		<-ctx.Done()
+		if ctx.Err() == context.DeadlineExceeded {
+			atomic.AddInt32(&timedOut, 1)
+		}
+	})
+	if timedOut != 2 {
+		t.Errorf("%d tasks timed out, want 2", timedOut)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var started int32
+	Run(ctx, 10, 1, 0, func(ctx context.Context, i int) {
+		if atomic.AddInt32(&started, 1) == 2 {
+			cancel()
+		}
+	})
+	if started > 3 {
+		t.Errorf("%d tasks started after cancelling, want at most 3", started)
+	}
+}
@@ -95,4 +95,10 @@ func TestMain(t *testing.T) {
 		products := apiTest.Products{App: router, Token: token}
 		t.Run("APiProductsCrud", products.Run)
 	}
+
+	// api test for cross tenant access
+	{
+		tenants := apiTest.Tenants{App: router, Token: token}
+		t.Run("APiTenantsIsolation", tenants.Run)
+	}
 }
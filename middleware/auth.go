@@ -2,10 +2,13 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/libraries/token"
+	"github.com/nirshpaa/godam-backend/models"
 	"github.com/nirshpaa/godam-backend/services"
 )
 
@@ -18,44 +21,77 @@ const UserIDKey contextKey = "userID"
 // CompanyIDKey is the key used to store the company ID in the context
 const CompanyIDKey contextKey = "company_id"
 
-// AuthMiddleware validates Firebase ID tokens and extracts company ID
-func AuthMiddleware(firebaseService *services.FirebaseService) gin.HandlerFunc {
+// AuthMiddleware validates bearer tokens with the given verifier, verifies that
+// the caller is a member of the requested company and scopes the request to
+// that company. Service accounts authenticate with "ApiKey <key>" instead and
+// are scoped to the company and permissions of their account. Failed
+// authentication, company switches to a company the caller is not a member
+// of and API key usage are recorded in the security log. Client IPs that
+// fail too often are turned away by failures before their credentials are
+// checked.
+func AuthMiddleware(firebaseService *services.FirebaseService, verifier token.TokenVerifier, security *models.SecurityLog, failures *FailureLimit) gin.HandlerFunc {
+	memberships := models.NewMembershipFirebase(firebaseService.GetFirestore())
+	serviceAccounts := models.NewServiceAccountFirebase(firebaseService.GetFirestore())
+	return authMiddleware(memberships, serviceAccounts, verifier, security, failures)
+}
+
+func authMiddleware(memberships *models.MembershipFirebase, serviceAccounts *models.ServiceAccountFirebase, verifier token.TokenVerifier, security *models.SecurityLog, failures *FailureLimit) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Skip auth for health check endpoint
 		if c.Request.URL.Path == "/health" {
 			c.Next()
 			return
 		}
+		if !failures.Check(c) {
+			return
+		}
 
 		// Get the Authorization header
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
+			recordAuthFailure(c, security, failures, "missing authorization header")
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
 			c.Abort()
 			return
 		}
 
-		// Check if the header is in the format "Bearer <token>"
+		// Check if the header is in the format "Bearer <token>" or "ApiKey <key>"
 		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
+		if len(parts) != 2 || (parts[0] != "Bearer" && parts[0] != "ApiKey") {
+			recordAuthFailure(c, security, failures, "invalid authorization header format")
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
 			c.Abort()
 			return
 		}
+		if parts[0] == "ApiKey" {
+			authenticateAPIKey(c, serviceAccounts, security, failures, parts[1])
+			return
+		}
 
-		// Verify the Firebase ID token using a background context
-		token := parts[1]
-		ctx := context.Background()
-		decodedToken, err := firebaseService.GetAuthClient().VerifyIDToken(ctx, token)
+		// Verify the bearer token, including revocation
+		claims, err := verifier.Verify(c.Request.Context(), parts[1])
+		if errors.Is(err, token.ErrTokenRevoked) {
+			recordAuthFailure(c, security, failures, err.Error())
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
 		if err != nil {
+			recordAuthFailure(c, security, failures, "invalid token: "+err.Error())
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token: " + err.Error()})
 			c.Abort()
 			return
 		}
 
-		// Skip company ID requirement for companies endpoint
-		if c.Request.URL.Path == "/companies" {
-			c.Set("userID", decodedToken.UID)
+		// Record the caller on the request context for the model layer
+		c.Request = c.Request.WithContext(models.WithActor(c.Request.Context(), claims.UID))
+
+		c.Set("claims", claims)
+		c.Set("email", claims.Email)
+
+		// Skip company ID requirement for creating, joining and switching companies
+		if companyOptional(c) {
+			c.Set("userID", claims.UID)
 			c.Next()
 			return
 		}
@@ -68,9 +104,28 @@ func AuthMiddleware(firebaseService *services.FirebaseService) gin.HandlerFunc {
 			return
 		}
 
-		// Set the user ID and company ID in the context
-		c.Set("userID", decodedToken.UID)
+		// Only active members may act on behalf of a company
+		membership, err := memberships.Active(c.Request.Context(), companyID, claims.UID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify company membership"})
+			c.Abort()
+			return
+		}
+		if membership == nil {
+			// The caller is verified, so the company sees the attempt
+			recordSecurity(c, security, models.SecurityCompanyDenied, companyID, claims.UID, "not a member of company "+companyID)
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not a member of this company"})
+			c.Abort()
+			return
+		}
+
+		// Set the user ID, company ID and the role in that company in the context
+		c.Set("userID", claims.UID)
 		c.Set("company_id", companyID)
+		c.Set("role", membership.Role)
+		c.Set("membership", membership)
+		scoped := models.WithBranches(models.WithCompany(c.Request.Context(), companyID), membership.Branches)
+		c.Request = c.Request.WithContext(scoped)
 		c.Next()
 	}
 }
@@ -96,3 +151,59 @@ func GetCompanyID(ctx context.Context) (string, bool) {
 	companyID, ok := ctx.Value(CompanyIDKey).(string)
 	return companyID, ok
 }
+
+// companyOptional reports whether a route may be called without selecting a
+// company: creating, joining and switching companies, answering invitations
+// and logging out
+func companyOptional(c *gin.Context) bool {
+	switch c.FullPath() {
+	case "/companies", "/me/companies", "/users/:id/join-company", "/invitations/accept", "/invitations/decline", "/auth/logout":
+		return true
+	}
+	return false
+}
+
+// authenticateAPIKey scopes a request made with a service account API key to
+// the account's company and grants it the account's scopes
+func authenticateAPIKey(c *gin.Context, serviceAccounts *models.ServiceAccountFirebase, security *models.SecurityLog, failures *FailureLimit, apiKey string) {
+	account, key, err := serviceAccounts.Authenticate(c.Request.Context(), apiKey)
+	if errors.Is(err, models.ErrInvalidAPIKey) {
+		recordAuthFailure(c, security, failures, "invalid API key")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify API key"})
+		c.Abort()
+		return
+	}
+
+	if companyOptional(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API keys cannot be used for this endpoint"})
+		c.Abort()
+		return
+	}
+	actor := "service-account:" + account.ID
+	if companyID := c.GetHeader("X-Company-ID"); companyID != "" && companyID != account.CompanyID {
+		recordSecurity(c, security, models.SecurityCompanyDenied, account.CompanyID, actor, "API key of company "+account.CompanyID+" used for company "+companyID)
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key belongs to a different company"})
+		c.Abort()
+		return
+	}
+
+	c.Set("userID", actor)
+	c.Set("company_id", account.CompanyID)
+	c.Set("service_account", account)
+	c.Set("api_key_prefix", key.Prefix)
+	c.Set("grant", &models.Grant{Permissions: account.Scopes})
+
+	ctx := models.WithCompany(models.WithActor(c.Request.Context(), actor), account.CompanyID)
+	c.Request = c.Request.WithContext(ctx)
+
+	// Usage is recorded as often as the key's last used time is refreshed
+	if key.UsageDue() {
+		recordSecurity(c, security, models.SecurityAPIKeyUsed, account.CompanyID, actor, "API key "+key.Prefix)
+	}
+	c.Next()
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+	"github.com/nirshpaa/godam-backend/libraries/ratelimit"
+	"github.com/nirshpaa/godam-backend/libraries/token"
+	"github.com/nirshpaa/godam-backend/models"
+)
+
+// rejectingVerifier rejects every token and counts the tokens it checked
+type rejectingVerifier struct {
+	calls int
+}
+
+func (v *rejectingVerifier) Verify(ctx context.Context, raw string) (*token.Claims, error) {
+	v.calls++
+	return nil, errors.New("bad signature")
+}
+
+func TestAuthFailures(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	security := models.NewSecurityLog(client, nil)
+	security.Start(ctx)
+
+	verifier := &rejectingVerifier{}
+	failures := NewRateLimiter(ratelimit.NewMemoryStore()).Failures("auth", ratelimit.PerMinute(1, 2))
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(authMiddleware(models.NewMembershipFirebase(client), models.NewServiceAccountFirebase(client), verifier, security, failures))
+	router.GET("/products", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	request := func(ip, authorization string) int {
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set("Authorization", authorization)
+		req.Header.Set("X-Company-ID", "company-a")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := request("10.0.0.1", "Bearer forged"); code != http.StatusUnauthorized {
+		t.Fatalf("forged token = %d, want 401", code)
+	}
+	if code := request("10.0.0.1", "ApiKey gdm_missing.secret"); code != http.StatusUnauthorized {
+		t.Fatalf("unknown API key = %d, want 401", code)
+	}
+	if code := request("10.0.0.1", "Bearer forged"); code != http.StatusTooManyRequests {
+		t.Fatalf("third failure from the IP = %d, want 429", code)
+	}
+	if verifier.calls != 1 {
+		t.Fatalf("verifier called %d times, want the limited request turned away before it", verifier.calls)
+	}
+	if code := request("10.0.0.2", "Bearer forged"); code != http.StatusUnauthorized {
+		t.Fatalf("other IP = %d, want 401", code)
+	}
+
+	security.Flush()
+	events, err := client.Collection("security_events").Documents(ctx).GetAll()
+	if err != nil {
+		t.Fatalf("getting security events: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("got %d security events, want 3 failures", len(events))
+	}
+	for _, doc := range events {
+		if companyID := doc.Data()["company_id"]; companyID != "" {
+			t.Errorf("failure recorded against unverified company %v", companyID)
+		}
+	}
+}
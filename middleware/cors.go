@@ -39,10 +39,10 @@ func CORS() gin.HandlerFunc {
 
 		// Set CORS headers
 		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Company-ID")
-		c.Header("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Company-ID, If-Match, If-None-Match, X-Request-ID")
+		c.Header("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
 		c.Header("Access-Control-Max-Age", "86400") // 24 hours
-		c.Header("Access-Control-Expose-Headers", "Content-Length, Content-Range")
+		c.Header("Access-Control-Expose-Headers", "Content-Length, Content-Range, ETag, X-Request-ID")
 
 		// Add additional headers for image handling
 		c.Header("Cross-Origin-Resource-Policy", "cross-origin")
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCORSAllowsVersionHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CORS())
+	router.GET("/products/:code", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodOptions, "/products/P1", nil)
+	req.Header.Set("Origin", "http://localhost:8081")
+	req.Header.Set("Access-Control-Request-Headers", "if-none-match")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("preflight = %d, want 204", w.Code)
+	}
+	allowed := strings.Split(w.Header().Get("Access-Control-Allow-Headers"), ", ")
+	for _, header := range []string{"If-Match", "If-None-Match"} {
+		found := false
+		for _, name := range allowed {
+			found = found || strings.EqualFold(name, header)
+		}
+		if !found {
+			t.Errorf("Access-Control-Allow-Headers = %v, missing %s", allowed, header)
+		}
+	}
+	if exposed := w.Header().Get("Access-Control-Expose-Headers"); !strings.Contains(exposed, "ETag") {
+		t.Errorf("Access-Control-Expose-Headers = %q, want ETag exposed", exposed)
+	}
+}
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/libraries/ratelimit"
+)
+
+// RateLimitPolicy limits a group of routes per caller and per company. The
+// caller is the user or API key, or the client IP before authentication.
+type RateLimitPolicy struct {
+	Caller  ratelimit.Limit
+	Company ratelimit.Limit
+}
+
+// RateLimiter applies rate limit policies backed by a bucket store
+type RateLimiter struct {
+	store ratelimit.Store
+}
+
+// NewRateLimiter creates a new RateLimiter
+func NewRateLimiter(store ratelimit.Store) *RateLimiter {
+	return &RateLimiter{
+		store: store,
+	}
+}
+
+// Limit enforces a policy under name, responding 429 with Retry-After once a
+// bucket is empty. Routes with different names have separate buckets. When
+// the store fails requests are let through rather than rejected.
+func (r *RateLimiter) Limit(name string, policy RateLimitPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !r.takePolicy(c, name, policy, 1) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// LimitIP enforces limit per client IP under name, whoever the caller is. It
+// runs before authentication, so it also holds back requests with bad tokens.
+func (r *RateLimiter) LimitIP(name string, limit ratelimit.Limit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !r.take(c, name+":ip:"+c.ClientIP(), limit, 1) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// Charge returns a function taking n tokens of a policy under name, for
+// handlers whose cost is only known once the request is read, such as a
+// batch of images. The function responds 429 and returns false when the
+// tokens are not available.
+func (r *RateLimiter) Charge(name string, policy RateLimitPolicy) func(c *gin.Context, n int) bool {
+	return func(c *gin.Context, n int) bool {
+		return r.takePolicy(c, name, policy, n)
+	}
+}
+
+// FailureLimit turns away client IPs that failed too often, before the work
+// of checking them again
+type FailureLimit struct {
+	limiter *RateLimiter
+	name    string
+	limit   ratelimit.Limit
+}
+
+// Failures returns a limit of limit failures per client IP under name
+func (r *RateLimiter) Failures(name string, limit ratelimit.Limit) *FailureLimit {
+	return &FailureLimit{limiter: r, name: name, limit: limit}
+}
+
+// Check responds 429 and returns false when the client IP has no failures
+// left. A nil FailureLimit allows everything.
+func (f *FailureLimit) Check(c *gin.Context) bool {
+	if f == nil {
+		return true
+	}
+	return f.limiter.take(c, f.key(c), f.limit, 0)
+}
+
+// Fail counts a failure of the client IP
+func (f *FailureLimit) Fail(c *gin.Context) {
+	if f == nil || !f.limit.Enabled() {
+		return
+	}
+	if _, err := f.limiter.store.Take(c.Request.Context(), f.key(c), f.limit, 1); err != nil {
+		log.Printf("Error counting failure: %v", err)
+	}
+}
+
+func (f *FailureLimit) key(c *gin.Context) string {
+	return f.name + ":failures:" + c.ClientIP()
+}
+
+// takePolicy takes n tokens from the caller's and the company's buckets
+func (r *RateLimiter) takePolicy(c *gin.Context, name string, policy RateLimitPolicy, n int) bool {
+	if !r.take(c, name+":caller:"+rateLimitCaller(c), policy.Caller, n) {
+		return false
+	}
+	if companyID := c.GetString("company_id"); companyID != "" {
+		if !r.take(c, name+":company:"+companyID, policy.Company, n) {
+			return false
+		}
+	}
+	return true
+}
+
+// rateLimitCaller identifies the caller: the API key, the user or the client IP
+func rateLimitCaller(c *gin.Context) string {
+	if prefix := c.GetString("api_key_prefix"); prefix != "" {
+		return "key:" + prefix
+	}
+	if userID := c.GetString("userID"); userID != "" {
+		return userID
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// take takes n tokens from a bucket and reports whether the request may continue
+func (r *RateLimiter) take(c *gin.Context, key string, limit ratelimit.Limit, n int) bool {
+	if !limit.Enabled() {
+		return true
+	}
+
+	result, err := r.store.Take(c.Request.Context(), key, limit, n)
+	if err != nil {
+		log.Printf("Error checking rate limit: %v", err)
+		return true
+	}
+
+	c.Header("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	if result.Allowed {
+		return true
+	}
+
+	retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Rate limit exceeded",
+		"retry_after": retryAfter,
+	})
+	c.Abort()
+	return false
+}
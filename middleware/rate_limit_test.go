@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/libraries/ratelimit"
+)
+
+// limitedRouter serves requests as the caller set by the X-Test-* headers
+func limitedRouter(handlers ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if userID := c.GetHeader("X-Test-User"); userID != "" {
+			c.Set("userID", userID)
+		}
+		if prefix := c.GetHeader("X-Test-Key"); prefix != "" {
+			c.Set("api_key_prefix", prefix)
+		}
+		c.Next()
+	})
+	router.GET("/", append(handlers, func(c *gin.Context) { c.Status(http.StatusNoContent) })...)
+	return router
+}
+
+func limitedRequest(router *gin.Engine, ip string, headers map[string]string) int {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = ip + ":1234"
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestLimitIP(t *testing.T) {
+	limiter := NewRateLimiter(ratelimit.NewMemoryStore())
+	router := limitedRouter(limiter.LimitIP("ip", ratelimit.PerMinute(1, 2)))
+
+	for i := 0; i < 2; i++ {
+		if code := limitedRequest(router, "10.0.0.1", map[string]string{"X-Test-User": "user-" + string(rune('a'+i))}); code != http.StatusNoContent {
+			t.Fatalf("request %d = %d, want 204", i+1, code)
+		}
+	}
+	if code := limitedRequest(router, "10.0.0.1", map[string]string{"X-Test-User": "user-c"}); code != http.StatusTooManyRequests {
+		t.Fatalf("third request from the IP = %d, want 429 whoever the caller is", code)
+	}
+	if code := limitedRequest(router, "10.0.0.2", nil); code != http.StatusNoContent {
+		t.Fatalf("other IP = %d, want 204", code)
+	}
+}
+
+func TestLimitAPIKeys(t *testing.T) {
+	limiter := NewRateLimiter(ratelimit.NewMemoryStore())
+	router := limitedRouter(limiter.Limit("api", RateLimitPolicy{Caller: ratelimit.PerMinute(1, 1)}))
+
+	account := map[string]string{"X-Test-User": "service-account:sa1", "X-Test-Key": "gdm_aaaa"}
+	if code := limitedRequest(router, "10.0.0.1", account); code != http.StatusNoContent {
+		t.Fatalf("first key = %d, want 204", code)
+	}
+	if code := limitedRequest(router, "10.0.0.1", account); code != http.StatusTooManyRequests {
+		t.Fatalf("first key again = %d, want 429", code)
+	}
+	account["X-Test-Key"] = "gdm_bbbb"
+	if code := limitedRequest(router, "10.0.0.1", account); code != http.StatusNoContent {
+		t.Fatalf("second key of the account = %d, want its own bucket", code)
+	}
+}
+
+func TestCharge(t *testing.T) {
+	limiter := NewRateLimiter(ratelimit.NewMemoryStore())
+	charge := limiter.Charge("batch", RateLimitPolicy{Caller: ratelimit.PerMinute(1, 5)})
+	images := 0
+	router := limitedRouter(func(c *gin.Context) {
+		if !charge(c, images) {
+			return
+		}
+		c.Next()
+	})
+
+	user := map[string]string{"X-Test-User": "u1"}
+	images = 4
+	if code := limitedRequest(router, "10.0.0.1", user); code != http.StatusNoContent {
+		t.Fatalf("batch of 4 = %d, want 204", code)
+	}
+	images = 2
+	if code := limitedRequest(router, "10.0.0.1", user); code != http.StatusTooManyRequests {
+		t.Fatalf("batch of 2 with 1 token left = %d, want 429", code)
+	}
+	images = 1
+	if code := limitedRequest(router, "10.0.0.1", user); code != http.StatusNoContent {
+		t.Fatalf("batch of 1 = %d, want the last token", code)
+	}
+}
@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/models"
+)
+
+// RBAC authorizes routes against the permissions of the caller's role in the
+// current company. Denials are recorded in the security log.
+type RBAC struct {
+	permissions *models.Permissions
+	security    *models.SecurityLog
+}
+
+// NewRBAC creates a new RBAC instance
+func NewRBAC(permissions *models.Permissions, security *models.SecurityLog) *RBAC {
+	return &RBAC{
+		permissions: permissions,
+		security:    security,
+	}
+}
+
+// Require only lets the request through when the caller holds permission,
+// e.g. rbac.Require("purchases:approve")
+func (r *RBAC) Require(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		grant, err := r.Load(c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load permissions"})
+			c.Abort()
+			return
+		}
+
+		if !grant.Allows(permission) {
+			recordSecurity(c, r.security, models.SecurityPermissionDenied, c.GetString("company_id"), c.GetString("userID"), "missing permission "+permission)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Missing permission " + permission})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Mask strips the fields the caller's role may not see about resource from
+// JSON responses, wherever they appear in the body
+func (r *RBAC) Mask(resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		grant, err := r.Load(c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load permissions"})
+			c.Abort()
+			return
+		}
+
+		hidden := grant.Hidden(resource)
+		if len(hidden) == 0 {
+			c.Next()
+			return
+		}
+
+		writer := &maskingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		body := writer.body.Bytes()
+		if strings.Contains(writer.Header().Get("Content-Type"), "application/json") {
+			body = maskFields(body, hidden)
+		}
+		writer.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		writer.ResponseWriter.Write(body)
+	}
+}
+
+// Load returns the caller's grant, resolving it once per request
+func (r *RBAC) Load(c *gin.Context) (*models.Grant, error) {
+	if grant, ok := c.Get("grant"); ok {
+		return grant.(*models.Grant), nil
+	}
+
+	grant, err := r.permissions.Resolve(c.Request.Context(), c.GetString("role"))
+	if err != nil {
+		return nil, err
+	}
+
+	c.Set("grant", grant)
+	return grant, nil
+}
+
+// maskingWriter holds the response body back so fields can be removed before it is sent
+type maskingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *maskingWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *maskingWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// maskFields removes the named keys from every object in a JSON document.
+// Bodies that are not valid JSON are returned unchanged.
+func maskFields(body []byte, hidden []string) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return body
+	}
+
+	fields := make(map[string]bool, len(hidden))
+	for _, field := range hidden {
+		fields[field] = true
+	}
+	stripFields(value, fields)
+
+	masked, err := json.Marshal(value)
+	if err != nil {
+		return body
+	}
+	return masked
+}
+
+func stripFields(value interface{}, fields map[string]bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if fields[key] {
+				delete(v, key)
+				continue
+			}
+			stripFields(child, fields)
+		}
+	case []interface{}:
+		for _, child := range v {
+			stripFields(child, fields)
+		}
+	}
+}
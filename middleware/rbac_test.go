@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/models"
+)
+
+// grantRouter serves requests as a caller holding grant
+func grantRouter(grant *models.Grant) (*gin.Engine, *RBAC) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("grant", grant)
+		c.Next()
+	})
+	return router, NewRBAC(nil, nil)
+}
+
+func TestRequire(t *testing.T) {
+	router, rbac := grantRouter(&models.Grant{Role: models.RoleClerk, Permissions: []string{"products:*", "*:read"}})
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	router.GET("/products", rbac.Require("products:read"), ok)
+	router.DELETE("/products/:code", rbac.Require("products:delete"), ok)
+	router.GET("/purchases", rbac.Require("purchases:read"), ok)
+	router.POST("/purchases/:id/approve", rbac.Require("purchases:approve"), ok)
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/products", http.StatusNoContent},
+		{http.MethodDelete, "/products/P1", http.StatusNoContent},
+		{http.MethodGet, "/purchases", http.StatusNoContent},
+		{http.MethodPost, "/purchases/1/approve", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, w.Code, tt.want)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	grant := &models.Grant{Role: models.RoleSalesman, HiddenFields: models.DefaultHiddenFields[models.RoleSalesman]}
+	router, rbac := grantRouter(grant)
+	router.GET("/products", rbac.Mask("products"), func(c *gin.Context) {
+		c.JSON(http.StatusOK, []gin.H{
+			{"code": "P1", "sale_price": 2, "purchase_price": 1},
+			{"code": "P2", "sale_price": 3, "purchase_price": 2, "supplier": gin.H{"name": "Acme"}},
+		})
+	})
+	router.GET("/purchases/:id", rbac.Mask("purchases"), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"id":              "1",
+			"additional_disc": 5,
+			"purchase_details": []gin.H{
+				{"product_id": "P1", "price": 1, "qty": 2, "product": gin.H{"code": "P1", "purchase_price": 1}},
+			},
+		})
+	})
+	router.GET("/products/export", rbac.Mask("products"), func(c *gin.Context) {
+		c.String(http.StatusOK, "code,purchase_price\nP1,1\n")
+	})
+
+	tests := []struct {
+		path, want string
+	}{
+		{"/products", `[{"code":"P1","sale_price":2},{"code":"P2","sale_price":3}]`},
+		{"/purchases/1", `{"id":"1","purchase_details":[{"product":{"code":"P1"},"product_id":"P1","qty":2}]}`},
+		{"/products/export", "code,purchase_price\nP1,1\n"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Body.String() != tt.want {
+			t.Errorf("GET %s = %s, want %s", tt.path, w.Body, tt.want)
+		}
+		if w.Header().Get("Content-Length") != strconv.Itoa(len(tt.want)) {
+			t.Errorf("GET %s Content-Length = %s, want %d", tt.path, w.Header().Get("Content-Length"), len(tt.want))
+		}
+	}
+}
+
+func TestMaskWithoutHiddenFields(t *testing.T) {
+	router, rbac := grantRouter(&models.Grant{Role: models.RoleOwner})
+	router.GET("/products", rbac.Mask("products"), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"code": "P1", "purchase_price": 1})
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/products", nil))
+	if want := `{"code":"P1","purchase_price":1}`; w.Body.String() != want {
+		t.Errorf("GET /products = %s, want %s", w.Body, want)
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nirshpaa/godam-backend/models"
+)
+
+// RequestIDHeader carries the request ID between clients, proxies and the API
+const RequestIDHeader = "X-Request-ID"
+
+// RequestInfo attaches the request ID, client IP and company to the request
+// context so the model layer can record them in audit events
+func RequestInfo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" {
+			requestID = uuid.New().String()
+		}
+		c.Header(RequestIDHeader, requestID)
+
+		ctx := models.WithRequestInfo(c.Request.Context(), models.RequestInfo{
+			RequestID: requestID,
+			IP:        c.ClientIP(),
+			CompanyID: c.GetString("company_id"),
+		})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/models"
+)
+
+// recordAuthFailure records a failed authentication and counts it against the
+// client IP. The company the caller asked for is not verified, so the event
+// has none.
+func recordAuthFailure(c *gin.Context, security *models.SecurityLog, failures *FailureLimit, detail string) {
+	failures.Fail(c)
+	recordSecurity(c, security, models.SecurityAuthFailure, "", "", detail)
+}
+
+// recordSecurity records a security event against companyID, which must only
+// be given once the caller is verified
+func recordSecurity(c *gin.Context, security *models.SecurityLog, eventType, companyID, userID, detail string) {
+	if security == nil {
+		return
+	}
+	security.Record(c.Request.Context(), models.SecurityEvent{
+		Type:      eventType,
+		CompanyID: companyID,
+		UserID:    userID,
+		IP:        c.ClientIP(),
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		Detail:    detail,
+		RequestID: c.GetHeader(RequestIDHeader),
+	})
+}
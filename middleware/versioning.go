@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nirshpaa/godam-backend/models"
+)
+
+// Versioning records document versions for ETag responses, passes the
+// If-Match header of write requests down to the model layer and answers
+// reads whose If-None-Match still holds the version with 304 Not Modified
+func Versioning() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, _ := models.WithVersionRecorder(c.Request.Context())
+
+		ifMatch := c.GetHeader("If-Match")
+		if ifMatch != "" {
+			switch c.Request.Method {
+			case http.MethodPut, http.MethodPatch, http.MethodDelete:
+				ctx = models.WithExpectedVersion(ctx, ifMatch)
+			}
+		}
+
+		ifNoneMatch := c.GetHeader("If-None-Match")
+		if ifNoneMatch != "" && (c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead) {
+			c.Writer = &notModifiedWriter{ResponseWriter: c.Writer, ifNoneMatch: ifNoneMatch}
+		}
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// notModifiedWriter turns a 200 response whose ETag is listed in If-None-Match
+// into a 304 without a body
+type notModifiedWriter struct {
+	gin.ResponseWriter
+	ifNoneMatch string
+	discard     bool
+}
+
+func (w *notModifiedWriter) WriteHeader(code int) {
+	etag := w.Header().Get("ETag")
+	if code == http.StatusOK && etag != "" &&
+		(strings.TrimSpace(w.ifNoneMatch) == "*" || models.VersionMatches(w.ifNoneMatch, etag)) {
+		w.discard = true
+		code = http.StatusNotModified
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *notModifiedWriter) Write(data []byte) (int, error) {
+	if w.discard {
+		w.writeNotModified()
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *notModifiedWriter) WriteString(s string) (int, error) {
+	if w.discard {
+		w.writeNotModified()
+		return len(s), nil
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *notModifiedWriter) writeNotModified() {
+	w.Header().Del("Content-Type")
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeaderNow()
+}
@@ -9,8 +9,11 @@ import (
 
 // AccessFirebaseModel represents an access in the system for Firebase
 type AccessFirebaseModel struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Resource  string `json:"resource"`
+	Action    string `json:"action"`
+	CompanyID string `json:"company_id"`
 }
 
 // AccessFirebase represents the Firestore client for access
@@ -28,13 +31,17 @@ func NewAccessFirebase(client *firestore.Client) *AccessFirebase {
 // List retrieves all access records
 func (a *AccessFirebase) List(ctx context.Context) ([]*AccessFirebaseModel, error) {
 	var access []*AccessFirebaseModel
-	docs, err := a.client.Collection("access").Documents(ctx).GetAll()
+	docs, err := scopedQuery(ctx, a.client.Collection("access")).Documents(ctx).GetAll()
 	if err != nil {
 		log.Printf("Error getting access: %v", err)
 		return nil, err
 	}
 
 	for _, doc := range docs {
+		if IsTrashed(doc) {
+			continue
+		}
+
 		var acc AccessFirebaseModel
 		if err := doc.DataTo(&acc); err != nil {
 			log.Printf("Error converting access data: %v", err)
@@ -54,6 +61,9 @@ func (a *AccessFirebase) Get(ctx context.Context, id string) (*AccessFirebaseMod
 		log.Printf("Error getting access: %v", err)
 		return nil, err
 	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
 
 	var access AccessFirebaseModel
 	if err := doc.DataTo(&access); err != nil {
@@ -61,13 +71,15 @@ func (a *AccessFirebase) Get(ctx context.Context, id string) (*AccessFirebaseMod
 		return nil, err
 	}
 	access.ID = doc.Ref.ID
+	recordVersion(ctx, doc.UpdateTime)
 
 	return &access, nil
 }
 
 // Create creates a new access record
 func (a *AccessFirebase) Create(ctx context.Context, access *AccessFirebaseModel) (string, error) {
-	docRef, _, err := a.client.Collection("access").Add(ctx, access)
+	docRef := a.client.Collection("access").NewDoc()
+	err := createDocument(ctx, a.client, docRef, access)
 	if err != nil {
 		log.Printf("Error creating access: %v", err)
 		return "", err
@@ -77,7 +89,7 @@ func (a *AccessFirebase) Create(ctx context.Context, access *AccessFirebaseModel
 
 // Update updates an existing access record
 func (a *AccessFirebase) Update(ctx context.Context, id string, access *AccessFirebaseModel) error {
-	_, err := a.client.Collection("access").Doc(id).Set(ctx, access)
+	err := setDocument(ctx, a.client, a.client.Collection("access").Doc(id), access)
 	if err != nil {
 		log.Printf("Error updating access: %v", err)
 		return err
@@ -87,7 +99,7 @@ func (a *AccessFirebase) Update(ctx context.Context, id string, access *AccessFi
 
 // Delete deletes an access record
 func (a *AccessFirebase) Delete(ctx context.Context, id string) error {
-	_, err := a.client.Collection("access").Doc(id).Delete(ctx)
+	err := trashDocument(ctx, a.client, a.client.Collection("access").Doc(id))
 	if err != nil {
 		log.Printf("Error deleting access: %v", err)
 		return err
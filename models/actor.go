@@ -0,0 +1,17 @@
+package models
+
+import "context"
+
+// actorContextKey is a custom type for the actor context key
+type actorContextKey struct{}
+
+// WithActor returns a context carrying the ID of the user performing a request
+func WithActor(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, userID)
+}
+
+// ActorFromContext returns the ID of the user performing a request
+func ActorFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(actorContextKey{}).(string)
+	return userID
+}
@@ -0,0 +1,554 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Approval statuses
+const (
+	ApprovalPending   = "pending"
+	ApprovalApproved  = "approved"
+	ApprovalRejected  = "rejected"
+	ApprovalCancelled = "cancelled"
+)
+
+// Approval states of documents that need approval. Documents no rule applies
+// to keep an empty approval status and can be posted right away.
+const (
+	DocumentPendingApproval = "pending_approval"
+	DocumentApproved        = "approved"
+	DocumentRejected        = "rejected"
+)
+
+// Documents approval rules apply to
+const (
+	ApprovalPurchases        = "purchases"
+	ApprovalSalesOrders      = "sales_orders"
+	ApprovalStockAdjustments = "stock_adjustments"
+)
+
+// Measures approval rules compare against their threshold
+const (
+	MetricAmount          = "amount"
+	MetricDiscountPercent = "discount_percent"
+	MetricValue           = "value"
+)
+
+// ApprovalMetrics lists the measures each document type can be ruled on
+var ApprovalMetrics = map[string][]string{
+	ApprovalPurchases:        {MetricAmount},
+	ApprovalSalesOrders:      {MetricAmount, MetricDiscountPercent},
+	ApprovalStockAdjustments: {MetricValue},
+}
+
+// approvalStatusFields names the field holding the approval status in each
+// document type. Collections written from untagged structs store Go field names.
+var approvalStatusFields = map[string]string{
+	ApprovalPurchases:        "approval_status",
+	ApprovalSalesOrders:      "ApprovalStatus",
+	ApprovalStockAdjustments: "approval_status",
+}
+
+var (
+	// ErrApprovalRequired is returned when posting a document that is not approved
+	ErrApprovalRequired = errors.New("document must be approved before it can be posted")
+	// ErrApprovalClosed is returned when deciding an approval that is no longer pending
+	ErrApprovalClosed = errors.New("approval has already been decided")
+	// ErrApprovalForbidden is returned when the caller may not decide the current approval level
+	ErrApprovalForbidden = errors.New("you may not decide this approval")
+)
+
+// ApprovalLevel is one step of an approval, decided by anyone holding its permission
+type ApprovalLevel struct {
+	Name       string `firestore:"name" json:"name"`
+	Permission string `firestore:"permission" json:"permission"`
+}
+
+// FirebaseApprovalRule requires approval for documents of a type whose metric
+// exceeds a threshold. When several rules match, the one with the highest
+// threshold applies.
+type FirebaseApprovalRule struct {
+	ID           string          `firestore:"-" json:"id"`
+	CompanyID    string          `firestore:"company_id" json:"company_id"`
+	DocumentType string          `firestore:"document_type" json:"document_type"`
+	Metric       string          `firestore:"metric" json:"metric"`
+	Threshold    float64         `firestore:"threshold" json:"threshold"`
+	Levels       []ApprovalLevel `firestore:"levels" json:"levels"`
+	CreatedAt    time.Time       `firestore:"created_at" json:"created_at"`
+}
+
+// ApprovalDecision records one approver's answer
+type ApprovalDecision struct {
+	Level     int       `firestore:"level" json:"level"`
+	UserID    string    `firestore:"user_id" json:"user_id"`
+	Decision  string    `firestore:"decision" json:"decision"`
+	Comment   string    `firestore:"comment" json:"comment"`
+	DecidedAt time.Time `firestore:"decided_at" json:"decided_at"`
+}
+
+// FirebaseApproval tracks a document through the levels of the rule it matched
+type FirebaseApproval struct {
+	ID           string             `firestore:"-" json:"id"`
+	CompanyID    string             `firestore:"company_id" json:"company_id"`
+	DocumentType string             `firestore:"document_type" json:"document_type"`
+	DocumentID   string             `firestore:"document_id" json:"document_id"`
+	RuleID       string             `firestore:"rule_id" json:"rule_id"`
+	Metric       string             `firestore:"metric" json:"metric"`
+	Value        float64            `firestore:"value" json:"value"`
+	Threshold    float64            `firestore:"threshold" json:"threshold"`
+	Status       string             `firestore:"status" json:"status"`
+	Level        int                `firestore:"level" json:"level"`
+	Levels       []ApprovalLevel    `firestore:"levels" json:"levels"`
+	Decisions    []ApprovalDecision `firestore:"decisions" json:"decisions"`
+	RequestedBy  string             `firestore:"requested_by" json:"requested_by"`
+	CreatedAt    time.Time          `firestore:"created_at" json:"created_at"`
+}
+
+// CurrentLevel returns the level awaiting a decision, or nil once the approval is closed
+func (a *FirebaseApproval) CurrentLevel() *ApprovalLevel {
+	if a.Status != ApprovalPending || a.Level >= len(a.Levels) {
+		return nil
+	}
+	return &a.Levels[a.Level]
+}
+
+// CanDecide reports whether a user holding grant may decide the current level.
+// Requesters may not approve their own documents and nobody decides two levels.
+func (a *FirebaseApproval) CanDecide(userID string, grant *Grant) bool {
+	level := a.CurrentLevel()
+	if level == nil || grant == nil || !grant.Allows(level.Permission) || userID == a.RequestedBy {
+		return false
+	}
+	for _, decision := range a.Decisions {
+		if decision.UserID == userID {
+			return false
+		}
+	}
+	return true
+}
+
+// ApprovalFirebase represents the Firestore client for approval rules and approvals
+type ApprovalFirebase struct {
+	client *firestore.Client
+}
+
+// NewApprovalFirebase creates a new ApprovalFirebase instance
+func NewApprovalFirebase(client *firestore.Client) *ApprovalFirebase {
+	return &ApprovalFirebase{
+		client: client,
+	}
+}
+
+// ListRules retrieves the approval rules of the company in the context
+func (a *ApprovalFirebase) ListRules(ctx context.Context) ([]*FirebaseApprovalRule, error) {
+	docs, err := scopedQuery(ctx, a.client.Collection("approval_rules")).Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("Error getting approval rules: %v", err)
+		return nil, err
+	}
+
+	rules := []*FirebaseApprovalRule{}
+	for _, doc := range docs {
+		var rule FirebaseApprovalRule
+		if err := doc.DataTo(&rule); err != nil {
+			log.Printf("Error converting approval rule data: %v", err)
+			continue
+		}
+		rule.ID = doc.Ref.ID
+		rules = append(rules, &rule)
+	}
+
+	return rules, nil
+}
+
+// CreateRule stores a new approval rule for the company in the context
+func (a *ApprovalFirebase) CreateRule(ctx context.Context, rule *FirebaseApprovalRule) (string, error) {
+	rule.CreatedAt = time.Now()
+
+	ref := a.client.Collection("approval_rules").NewDoc()
+	if err := createDocument(ctx, a.client, ref, rule); err != nil {
+		log.Printf("Error creating approval rule: %v", err)
+		return "", err
+	}
+	rule.ID = ref.ID
+
+	return ref.ID, nil
+}
+
+// UpdateRule changes the threshold and levels of an approval rule. Approvals
+// already in progress keep the levels they started with.
+func (a *ApprovalFirebase) UpdateRule(ctx context.Context, id string, rule *FirebaseApprovalRule) error {
+	err := updateDocument(ctx, a.client, a.client.Collection("approval_rules").Doc(id), []firestore.Update{
+		{Path: "document_type", Value: rule.DocumentType},
+		{Path: "metric", Value: rule.Metric},
+		{Path: "threshold", Value: rule.Threshold},
+		{Path: "levels", Value: rule.Levels},
+	})
+	if err != nil {
+		log.Printf("Error updating approval rule: %v", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteRule removes an approval rule
+func (a *ApprovalFirebase) DeleteRule(ctx context.Context, id string) error {
+	err := deleteDocument(ctx, a.client, a.client.Collection("approval_rules").Doc(id))
+	if err != nil {
+		log.Printf("Error deleting approval rule: %v", err)
+		return err
+	}
+	return nil
+}
+
+// Match returns the rule a document with the given metrics falls under and
+// the measured value, or nil when the document needs no approval
+func (a *ApprovalFirebase) Match(ctx context.Context, documentType string, metrics map[string]float64) (*FirebaseApprovalRule, float64, error) {
+	docs, err := scopedQuery(ctx, a.client.Collection("approval_rules")).Where("document_type", "==", documentType).Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("Error getting approval rules: %v", err)
+		return nil, 0, err
+	}
+
+	var match *FirebaseApprovalRule
+	var value float64
+	for _, doc := range docs {
+		var rule FirebaseApprovalRule
+		if err := doc.DataTo(&rule); err != nil {
+			log.Printf("Error converting approval rule data: %v", err)
+			continue
+		}
+		measured, ok := metrics[rule.Metric]
+		if !ok || measured <= rule.Threshold || len(rule.Levels) == 0 {
+			continue
+		}
+		if match == nil || rule.Threshold > match.Threshold {
+			rule.ID = doc.Ref.ID
+			match, value = &rule, measured
+		}
+	}
+
+	return match, value, nil
+}
+
+// Open starts an approval of a document under a rule, cancelling any approval
+// of the document still in progress
+func (a *ApprovalFirebase) Open(ctx context.Context, documentType, documentID string, rule *FirebaseApprovalRule, value float64) (*FirebaseApproval, error) {
+	if err := a.Cancel(ctx, documentType, documentID); err != nil {
+		return nil, err
+	}
+
+	approval := newApproval(ctx, documentType, documentID, rule, value)
+	ref := a.client.Collection("approvals").NewDoc()
+	if err := createDocument(ctx, a.client, ref, approval); err != nil {
+		log.Printf("Error creating approval: %v", err)
+		return nil, err
+	}
+	approval.ID = ref.ID
+
+	return approval, nil
+}
+
+// openIn starts the approval of a document created in the same transaction.
+// The caller records the returned approval with auditCreated once tx commits.
+func (a *ApprovalFirebase) openIn(ctx context.Context, tx *firestore.Transaction, documentType, documentID string, rule *FirebaseApprovalRule, value float64) (*firestore.DocumentRef, error) {
+	approval := newApproval(ctx, documentType, documentID, rule, value)
+	if err := stampCompany(ctx, "approvals", approval); err != nil {
+		return nil, err
+	}
+	ref := a.client.Collection("approvals").NewDoc()
+	return ref, tx.Set(ref, approval)
+}
+
+func newApproval(ctx context.Context, documentType, documentID string, rule *FirebaseApprovalRule, value float64) *FirebaseApproval {
+	return &FirebaseApproval{
+		DocumentType: documentType,
+		DocumentID:   documentID,
+		RuleID:       rule.ID,
+		Metric:       rule.Metric,
+		Value:        value,
+		Threshold:    rule.Threshold,
+		Status:       ApprovalPending,
+		Levels:       rule.Levels,
+		Decisions:    []ApprovalDecision{},
+		RequestedBy:  ActorFromContext(ctx),
+		CreatedAt:    time.Now(),
+	}
+}
+
+// reviewIn works out the approval status of a document changed in tx. An
+// approved document stays approved while its measured value does not exceed
+// the approved one; otherwise the approvals in progress are cancelled and a
+// new one is opened under rule, the match of the changed document. It reads,
+// so it must run before the other writes of tx. The caller records the
+// returned approval, if any, with auditCreated once tx commits.
+func (a *ApprovalFirebase) reviewIn(ctx context.Context, tx *firestore.Transaction, documentType, documentID string, rule *FirebaseApprovalRule, value float64) (string, *firestore.DocumentRef, error) {
+	doc, err := tx.Get(a.client.Collection(documentType).Doc(documentID))
+	if err != nil {
+		return "", nil, err
+	}
+	current, _ := doc.Data()[approvalStatusFields[documentType]].(string)
+
+	query := scopedQuery(ctx, a.client.Collection("approvals")).
+		Where("document_type", "==", documentType).
+		Where("document_id", "==", documentID)
+	docs, err := tx.Documents(query).GetAll()
+	if err != nil {
+		return "", nil, err
+	}
+
+	var pending []*firestore.DocumentRef
+	for _, doc := range docs {
+		var approval FirebaseApproval
+		if err := doc.DataTo(&approval); err != nil {
+			return "", nil, err
+		}
+		if rule != nil && current == DocumentApproved && approval.Status == ApprovalApproved &&
+			approval.Metric == rule.Metric && value <= approval.Value {
+			return DocumentApproved, nil, nil
+		}
+		if approval.Status == ApprovalPending {
+			pending = append(pending, doc.Ref)
+		}
+	}
+
+	for _, ref := range pending {
+		if err := tx.Update(ref, []firestore.Update{{Path: "status", Value: ApprovalCancelled}}); err != nil {
+			return "", nil, err
+		}
+	}
+	if rule == nil {
+		return "", nil, nil
+	}
+	ref, err := a.openIn(ctx, tx, documentType, documentID, rule, value)
+	if err != nil {
+		return "", nil, err
+	}
+	return DocumentPendingApproval, ref, nil
+}
+
+// Cancel closes the approvals of a document still in progress, such as when
+// it is changed. The expected version in the context belongs to the document,
+// so it is not checked against its approvals.
+func (a *ApprovalFirebase) Cancel(ctx context.Context, documentType, documentID string) error {
+	ctx = WithExpectedVersion(ctx, "")
+	approvals, err := a.ForDocument(ctx, documentType, documentID)
+	if err != nil {
+		return err
+	}
+
+	for _, approval := range approvals {
+		if approval.Status != ApprovalPending {
+			continue
+		}
+		ref := a.client.Collection("approvals").Doc(approval.ID)
+		if err := updateDocument(ctx, a.client, ref, []firestore.Update{{Path: "status", Value: ApprovalCancelled}}); err != nil {
+			log.Printf("Error cancelling approval: %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// cancelIn closes the approvals of a document still in progress inside tx,
+// such as when it is deleted. It reads, so it must run before the other
+// writes of tx.
+func (a *ApprovalFirebase) cancelIn(ctx context.Context, tx *firestore.Transaction, documentType, documentID string) error {
+	query := scopedQuery(ctx, a.client.Collection("approvals")).
+		Where("document_type", "==", documentType).
+		Where("document_id", "==", documentID).
+		Where("status", "==", ApprovalPending)
+	docs, err := tx.Documents(query).GetAll()
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		if err := tx.Update(doc.Ref, []firestore.Update{{Path: "status", Value: ApprovalCancelled}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List retrieves the approvals of the company in the context, optionally limited to a status
+func (a *ApprovalFirebase) List(ctx context.Context, state string) ([]*FirebaseApproval, error) {
+	query := scopedQuery(ctx, a.client.Collection("approvals"))
+	if state != "" {
+		query = query.Where("status", "==", state)
+	}
+	return a.query(ctx, query)
+}
+
+// ForDocument retrieves every approval of a document
+func (a *ApprovalFirebase) ForDocument(ctx context.Context, documentType, documentID string) ([]*FirebaseApproval, error) {
+	query := scopedQuery(ctx, a.client.Collection("approvals")).
+		Where("document_type", "==", documentType).
+		Where("document_id", "==", documentID)
+	return a.query(ctx, query)
+}
+
+// Get retrieves a single approval by ID
+func (a *ApprovalFirebase) Get(ctx context.Context, id string) (*FirebaseApproval, error) {
+	doc, err := a.client.Collection("approvals").Doc(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Printf("Error getting approval: %v", err)
+		return nil, err
+	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
+
+	var approval FirebaseApproval
+	if err := doc.DataTo(&approval); err != nil {
+		log.Printf("Error converting approval data: %v", err)
+		return nil, err
+	}
+	approval.ID = doc.Ref.ID
+	recordVersion(ctx, doc.UpdateTime)
+
+	return &approval, nil
+}
+
+// Decide records the caller's decision on the current level of an approval.
+// A rejection closes the approval; approving the last level approves the
+// document and posts it when approval is what it was waiting for.
+func (a *ApprovalFirebase) Decide(ctx context.Context, id string, grant *Grant, approve bool, comment string) (*FirebaseApproval, error) {
+	ref := a.client.Collection("approvals").Doc(id)
+	userID := ActorFromContext(ctx)
+
+	var approval FirebaseApproval
+	var documentRef *firestore.DocumentRef
+	var documentBefore map[string]interface{}
+	err := writeDocument(ctx, a.client, ref, AuditUpdate, func(tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		if err := doc.DataTo(&approval); err != nil {
+			return err
+		}
+		approval.ID = doc.Ref.ID
+		if approval.Status != ApprovalPending {
+			return ErrApprovalClosed
+		}
+		if !approval.CanDecide(userID, grant) {
+			return ErrApprovalForbidden
+		}
+
+		documentRef = a.client.Collection(approval.DocumentType).Doc(approval.DocumentID)
+		document, err := tx.Get(documentRef)
+		if status.Code(err) == codes.NotFound {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := checkReadable(ctx, document); err != nil {
+			return err
+		}
+		documentBefore = document.Data()
+
+		decision := ApprovalRejected
+		if approve {
+			decision = ApprovalApproved
+		}
+		approval.Decisions = append(approval.Decisions, ApprovalDecision{
+			Level:     approval.Level,
+			UserID:    userID,
+			Decision:  decision,
+			Comment:   comment,
+			DecidedAt: time.Now(),
+		})
+
+		documentStatus := ""
+		switch {
+		case !approve:
+			approval.Status = ApprovalRejected
+			documentStatus = DocumentRejected
+		case approval.Level == len(approval.Levels)-1:
+			approval.Status = ApprovalApproved
+			documentStatus = DocumentApproved
+			if approval.DocumentType == ApprovalStockAdjustments {
+				// Reads the product, so it must run before any write below
+				if err := postStockAdjustment(ctx, tx, a.client, document); err != nil {
+					return err
+				}
+			}
+		default:
+			approval.Level++
+		}
+
+		if documentStatus != "" {
+			field := approvalStatusFields[approval.DocumentType]
+			if err := tx.Update(documentRef, []firestore.Update{{Path: field, Value: documentStatus}}); err != nil {
+				return err
+			}
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "status", Value: approval.Status},
+			{Path: "level", Value: approval.Level},
+			{Path: "decisions", Value: approval.Decisions},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if approval.Status != ApprovalPending {
+		if doc, err := documentRef.Get(ctx); err == nil {
+			recordAudit(ctx, a.client, AuditUpdate, documentRef, documentBefore, doc.Data())
+		}
+	}
+	return &approval, nil
+}
+
+func (a *ApprovalFirebase) query(ctx context.Context, query firestore.Query) ([]*FirebaseApproval, error) {
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("Error getting approvals: %v", err)
+		return nil, err
+	}
+
+	approvals := []*FirebaseApproval{}
+	for _, doc := range docs {
+		var approval FirebaseApproval
+		if err := doc.DataTo(&approval); err != nil {
+			log.Printf("Error converting approval data: %v", err)
+			continue
+		}
+		approval.ID = doc.Ref.ID
+		approvals = append(approvals, &approval)
+	}
+
+	return approvals, nil
+}
+
+// requireApproved rejects posting a document that awaits or failed approval
+func requireApproved(ctx context.Context, client *firestore.Client, documentType, documentID string) error {
+	doc, err := client.Collection(documentType).Doc(documentID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return err
+	}
+
+	state, _ := doc.Data()[approvalStatusFields[documentType]].(string)
+	if state != "" && state != DocumentApproved {
+		return ErrApprovalRequired
+	}
+	return nil
+}
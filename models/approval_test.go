@@ -0,0 +1,244 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/firestore"
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+	"github.com/nirshpaa/godam-backend/types"
+)
+
+func approvalContext(t *testing.T, client *firestore.Client, documentType, metric string, threshold float64) context.Context {
+	t.Helper()
+	ctx := WithActor(WithCompany(context.Background(), "company-a"), "user-a")
+	rule := &FirebaseApprovalRule{
+		DocumentType: documentType,
+		Metric:       metric,
+		Threshold:    threshold,
+		Levels:       []ApprovalLevel{{Name: "manager", Permission: "approvals:decide"}},
+	}
+	if _, err := NewApprovalFirebase(client).CreateRule(ctx, rule); err != nil {
+		t.Fatalf("CreateRule: %v", err)
+	}
+	return ctx
+}
+
+func approvalsOf(t *testing.T, ctx context.Context, client *firestore.Client, documentType, documentID string) []*FirebaseApproval {
+	t.Helper()
+	approvals, err := NewApprovalFirebase(client).ForDocument(ctx, documentType, documentID)
+	if err != nil {
+		t.Fatalf("ForDocument: %v", err)
+	}
+	return approvals
+}
+
+func TestPurchaseCreateOpensApproval(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := approvalContext(t, client, ApprovalPurchases, MetricAmount, 100)
+	purchases := NewPurchaseFirebase(client)
+
+	id, err := purchases.Create(ctx, &FirebasePurchase{
+		Code:            "PO-1",
+		PurchaseDetails: []FirebasePurchaseDetail{{ProductID: "P1", Price: 50, Qty: 3}},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	purchase, err := purchases.Get(ctx, id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if purchase.ApprovalStatus != DocumentPendingApproval {
+		t.Errorf("approval status = %q, want %q", purchase.ApprovalStatus, DocumentPendingApproval)
+	}
+	approvals := approvalsOf(t, ctx, client, ApprovalPurchases, id)
+	if len(approvals) != 1 || approvals[0].Status != ApprovalPending {
+		t.Fatalf("approvals = %+v, want one pending", approvals)
+	}
+}
+
+func TestPurchaseDeleteWithVersionCancelsApproval(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := approvalContext(t, client, ApprovalPurchases, MetricAmount, 100)
+	purchases := NewPurchaseFirebase(client)
+
+	id, err := purchases.Create(ctx, &FirebasePurchase{
+		Code:            "PO-1",
+		PurchaseDetails: []FirebasePurchaseDetail{{ProductID: "P1", Price: 500, Qty: 1}},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	doc, err := client.Collection("purchases").Doc(id).Get(ctx)
+	if err != nil {
+		t.Fatalf("reading purchase: %v", err)
+	}
+
+	// The version is the purchase's, not its approval's
+	if err := purchases.Delete(WithExpectedVersion(ctx, DocumentVersion(doc.UpdateTime)), id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	approvals := approvalsOf(t, ctx, client, ApprovalPurchases, id)
+	if len(approvals) != 1 || approvals[0].Status != ApprovalCancelled {
+		t.Fatalf("approvals = %+v, want one cancelled", approvals)
+	}
+}
+
+func TestPurchaseDeleteWithStaleVersionKeepsApproval(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := approvalContext(t, client, ApprovalPurchases, MetricAmount, 100)
+	purchases := NewPurchaseFirebase(client)
+
+	id, err := purchases.Create(ctx, &FirebasePurchase{
+		Code:            "PO-1",
+		PurchaseDetails: []FirebasePurchaseDetail{{ProductID: "P1", Price: 500, Qty: 1}},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	err = purchases.Delete(WithExpectedVersion(ctx, `"1"`), id)
+	var mismatch *VersionMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Delete error = %v, want a version mismatch", err)
+	}
+	if _, err := purchases.Get(ctx, id); err != nil {
+		t.Errorf("purchase was trashed: %v", err)
+	}
+	approvals := approvalsOf(t, ctx, client, ApprovalPurchases, id)
+	if len(approvals) != 1 || approvals[0].Status != ApprovalPending {
+		t.Fatalf("approvals = %+v, want one pending", approvals)
+	}
+}
+
+func TestPurchaseUpdateReviewsApproval(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := approvalContext(t, client, ApprovalPurchases, MetricAmount, 100)
+	purchases := NewPurchaseFirebase(client)
+
+	id, err := purchases.Create(ctx, &FirebasePurchase{
+		Code:            "PO-1",
+		PurchaseDetails: []FirebasePurchaseDetail{{ProductID: "P1", Price: 500, Qty: 1}},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	changed := &FirebasePurchase{
+		Code:            "PO-1",
+		PurchaseDetails: []FirebasePurchaseDetail{{ProductID: "P1", Price: 600, Qty: 1}},
+	}
+
+	err = purchases.Update(WithExpectedVersion(ctx, `"1"`), id, changed)
+	var mismatch *VersionMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Update error = %v, want a version mismatch", err)
+	}
+	approvals := approvalsOf(t, ctx, client, ApprovalPurchases, id)
+	if len(approvals) != 1 || approvals[0].Status != ApprovalPending || approvals[0].Value != 500 {
+		t.Fatalf("approvals after a stale update = %+v, want the first one pending", approvals)
+	}
+
+	if err := purchases.Update(ctx, id, changed); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	approvals = approvalsOf(t, ctx, client, ApprovalPurchases, id)
+	pending := 0
+	for _, approval := range approvals {
+		if approval.Status == ApprovalPending {
+			pending++
+			if approval.Value != 600 {
+				t.Errorf("pending approval value = %v, want 600", approval.Value)
+			}
+		}
+	}
+	if len(approvals) != 2 || pending != 1 {
+		t.Errorf("approvals after updating = %+v, want the first cancelled and a second pending", approvals)
+	}
+	if purchase, err := purchases.Get(ctx, id); err != nil || purchase.ApprovalStatus != DocumentPendingApproval {
+		t.Errorf("purchase after updating = %+v, %v, want it pending approval", purchase, err)
+	}
+}
+
+func TestSalesOrderUpdateWithStaleVersionKeepsApproval(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := approvalContext(t, client, ApprovalSalesOrders, MetricAmount, 100)
+	orders := NewSalesOrderFirebase(client)
+
+	order := types.SalesOrder{ID: "SO-1", CustomerID: "C1", CompanyID: "company-a", TotalAmount: 500,
+		SalesOrderDetails: []types.SalesOrderDetail{{ProductID: "P1", Quantity: 1, UnitPrice: 500}}}
+	if err := orders.Create(ctx, order); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	order.TotalAmount = 50
+	err := orders.Update(WithExpectedVersion(ctx, `"1"`), order.ID, order)
+	var mismatch *VersionMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Update error = %v, want a version mismatch", err)
+	}
+	approvals := approvalsOf(t, ctx, client, ApprovalSalesOrders, order.ID)
+	if len(approvals) != 1 || approvals[0].Status != ApprovalPending {
+		t.Fatalf("approvals after a stale update = %+v, want one pending", approvals)
+	}
+
+	if err := orders.Update(ctx, order.ID, order); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	approvals = approvalsOf(t, ctx, client, ApprovalSalesOrders, order.ID)
+	if len(approvals) != 1 || approvals[0].Status != ApprovalCancelled {
+		t.Errorf("approvals once no rule applies = %+v, want one cancelled", approvals)
+	}
+}
+
+func TestStockAdjustmentCreatePostsStock(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := WithCompany(context.Background(), "company-a")
+	products, _ := NewProductFirebase(client)
+	adjustments := NewStockAdjustmentFirebase(client, products, NewApprovalFirebase(client))
+	seedProduct(t, ctx, products, &FirebaseProduct{Code: "P1", MinimumStock: 5, PurchasePrice: 2})
+
+	adjustment := &FirebaseStockAdjustment{ProductCode: "P1", Quantity: -3, Reason: "damaged"}
+	if err := adjustments.Create(ctx, adjustment); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if adjustment.PostedAt == nil {
+		t.Error("adjustment was not posted")
+	}
+	product, err := products.Get(ctx, "P1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if product.MinimumStock != 2 {
+		t.Errorf("stock = %v, want 2", product.MinimumStock)
+	}
+}
+
+func TestStockAdjustmentCreateWithApprovalLeavesStock(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := approvalContext(t, client, ApprovalStockAdjustments, MetricValue, 1)
+	products, _ := NewProductFirebase(client)
+	adjustments := NewStockAdjustmentFirebase(client, products, NewApprovalFirebase(client))
+	seedProduct(t, ctx, products, &FirebaseProduct{Code: "P1", MinimumStock: 5, PurchasePrice: 2})
+
+	adjustment := &FirebaseStockAdjustment{ProductCode: "P1", Quantity: -3, Reason: "damaged"}
+	if err := adjustments.Create(ctx, adjustment); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if adjustment.PostedAt != nil || adjustment.ApprovalStatus != DocumentPendingApproval {
+		t.Errorf("adjustment = %+v, want pending and unposted", adjustment)
+	}
+	approvals := approvalsOf(t, ctx, client, ApprovalStockAdjustments, adjustment.ID)
+	if len(approvals) != 1 {
+		t.Fatalf("approvals = %+v, want one", approvals)
+	}
+	product, err := products.Get(ctx, "P1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if product.MinimumStock != 5 {
+		t.Errorf("stock = %v, want 5", product.MinimumStock)
+	}
+}
@@ -0,0 +1,211 @@
+package models
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+// Audit actions
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+)
+
+// auditCollection is the collection audit events are stored in
+const auditCollection = "audit_log"
+
+// auditIgnoredFields are bookkeeping fields left out of diffs
+var auditIgnoredFields = map[string]bool{
+	"updated_at": true,
+}
+
+// RequestInfo describes the request that triggered a write
+type RequestInfo struct {
+	RequestID string
+	IP        string
+	CompanyID string
+}
+
+// requestInfoContextKey is a custom type for the request info context key
+type requestInfoContextKey struct{}
+
+// WithRequestInfo returns a context carrying request details for audit events
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoContextKey{}, info)
+}
+
+func requestInfo(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestInfoContextKey{}).(RequestInfo)
+	return info
+}
+
+// FieldChange is the before and after value of a single field
+type FieldChange struct {
+	Field  string      `json:"field" firestore:"field"`
+	Before interface{} `json:"before" firestore:"before"`
+	After  interface{} `json:"after" firestore:"after"`
+}
+
+// AuditEvent records a single write to a document
+type AuditEvent struct {
+	ID        string        `json:"id" firestore:"-"`
+	Action    string        `json:"action" firestore:"action"`
+	Entity    string        `json:"entity" firestore:"entity"`
+	EntityID  string        `json:"entity_id" firestore:"entity_id"`
+	UserID    string        `json:"user_id" firestore:"user_id"`
+	CompanyID string        `json:"company_id" firestore:"company_id"`
+	RequestID string        `json:"request_id" firestore:"request_id"`
+	IP        string        `json:"ip" firestore:"ip"`
+	Changes   []FieldChange `json:"changes" firestore:"changes"`
+	Timestamp time.Time     `json:"timestamp" firestore:"timestamp"`
+}
+
+// AuditFilter narrows an audit log query
+type AuditFilter struct {
+	CompanyID string
+	Entity    string
+	EntityID  string
+	UserID    string
+	From      time.Time
+	To        time.Time
+	Limit     int
+}
+
+// AuditLog reads recorded audit events
+type AuditLog struct {
+	client *firestore.Client
+}
+
+// NewAuditLog creates a new AuditLog instance
+func NewAuditLog(client *firestore.Client) *AuditLog {
+	return &AuditLog{
+		client: client,
+	}
+}
+
+// List returns audit events matching the filter, newest first
+func (a *AuditLog) List(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	query := a.client.Collection(auditCollection).Query
+	if filter.CompanyID != "" {
+		query = query.Where("company_id", "==", filter.CompanyID)
+	}
+	if filter.Entity != "" {
+		query = query.Where("entity", "==", filter.Entity)
+	}
+	if filter.EntityID != "" {
+		query = query.Where("entity_id", "==", filter.EntityID)
+	}
+	if filter.UserID != "" {
+		query = query.Where("user_id", "==", filter.UserID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("timestamp", ">=", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("timestamp", "<=", filter.To)
+	}
+	query = query.OrderBy("timestamp", firestore.Desc)
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("Error getting audit events: %v", err)
+		return nil, err
+	}
+
+	events := make([]AuditEvent, 0, len(docs))
+	for _, doc := range docs {
+		var event AuditEvent
+		if err := doc.DataTo(&event); err != nil {
+			log.Printf("Error converting audit event document: %v", err)
+			continue
+		}
+		event.ID = doc.Ref.ID
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// recordAudit stores an audit event for a write. The write has already been
+// committed, so failures are logged instead of returned.
+func recordAudit(ctx context.Context, client *firestore.Client, action string, ref *firestore.DocumentRef, before, after map[string]interface{}) {
+	changes := diffFields("", before, after)
+	if action == AuditUpdate && len(changes) == 0 {
+		return
+	}
+
+	info := requestInfo(ctx)
+	event := AuditEvent{
+		Action:    action,
+		Entity:    ref.Parent.ID,
+		EntityID:  ref.ID,
+		UserID:    ActorFromContext(ctx),
+		CompanyID: documentCompany(info.CompanyID, after, before),
+		RequestID: info.RequestID,
+		IP:        info.IP,
+		Changes:   changes,
+		Timestamp: time.Now(),
+	}
+
+	if _, _, err := client.Collection(auditCollection).Add(ctx, event); err != nil {
+		log.Printf("Error recording audit event for %s/%s: %v", event.Entity, event.EntityID, err)
+	}
+}
+
+// diffFields compares two documents field by field, descending into nested maps
+func diffFields(prefix string, before, after map[string]interface{}) []FieldChange {
+	keys := make(map[string]bool, len(before)+len(after))
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+
+	names := make([]string, 0, len(keys))
+	for key := range keys {
+		if !auditIgnoredFields[prefix+key] {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []FieldChange{}
+	for _, key := range names {
+		oldValue, newValue := before[key], after[key]
+		oldMap, oldIsMap := oldValue.(map[string]interface{})
+		newMap, newIsMap := newValue.(map[string]interface{})
+		if oldIsMap && newIsMap {
+			changes = append(changes, diffFields(prefix+key+".", oldMap, newMap)...)
+			continue
+		}
+		if !reflect.DeepEqual(oldValue, newValue) {
+			changes = append(changes, FieldChange{Field: prefix + key, Before: oldValue, After: newValue})
+		}
+	}
+
+	return changes
+}
+
+// documentCompany returns the company a document belongs to, falling back to the request company
+func documentCompany(fallback string, docs ...map[string]interface{}) string {
+	for _, data := range docs {
+		for _, field := range []string{"company_id", "CompanyID"} {
+			if companyID, ok := data[field].(string); ok && companyID != "" {
+				return companyID
+			}
+		}
+	}
+	return fallback
+}
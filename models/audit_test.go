@@ -0,0 +1,90 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+)
+
+func TestDiffFields(t *testing.T) {
+	before := map[string]interface{}{
+		"name":       "Tea",
+		"price":      2.0,
+		"updated_at": "yesterday",
+		"address":    map[string]interface{}{"city": "Kathmandu", "zip": "44600"},
+	}
+	after := map[string]interface{}{
+		"name":       "Tea",
+		"price":      2.5,
+		"updated_at": "today",
+		"address":    map[string]interface{}{"city": "Pokhara", "zip": "44600"},
+		"brand_id":   "B1",
+	}
+
+	changes := diffFields("", before, after)
+	want := []FieldChange{
+		{Field: "address.city", Before: "Kathmandu", After: "Pokhara"},
+		{Field: "brand_id", Before: nil, After: "B1"},
+		{Field: "price", Before: 2.0, After: 2.5},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("changes = %+v, want %+v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d = %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+}
+
+func TestAuditRecordsWrites(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := WithActor(WithCompany(context.Background(), "company-a"), "user-a")
+	ctx = WithRequestInfo(ctx, RequestInfo{RequestID: "req-1", IP: "10.0.0.1", CompanyID: "company-a"})
+	brands := NewFirebaseModel("brands", client)
+
+	id, err := brands.Create(ctx, map[string]interface{}{"Name": "Acme"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := brands.Update(ctx, id, map[string]interface{}{"Name": "Acme Foods"}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	// An update that changes nothing but the timestamp is not recorded
+	if err := brands.Update(ctx, id, map[string]interface{}{"Name": "Acme Foods"}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := brands.Delete(ctx, id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	events, err := NewAuditLog(client).List(context.Background(), AuditFilter{CompanyID: "company-a", Entity: "brands", EntityID: id})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("events = %+v, want create, update and delete", events)
+	}
+
+	// Newest first
+	actions := []string{events[2].Action, events[1].Action, events[0].Action}
+	if actions[0] != AuditCreate || actions[1] != AuditUpdate || actions[2] != AuditDelete {
+		t.Errorf("actions = %v, want create, update, delete", actions)
+	}
+	update := events[1]
+	if update.UserID != "user-a" || update.RequestID != "req-1" || update.IP != "10.0.0.1" {
+		t.Errorf("update event = %+v, want the actor and request details", update)
+	}
+	if len(update.Changes) != 1 || update.Changes[0].Field != "Name" || update.Changes[0].Before != "Acme" || update.Changes[0].After != "Acme Foods" {
+		t.Errorf("update changes = %+v, want Name from Acme to Acme Foods", update.Changes)
+	}
+
+	others, err := NewAuditLog(client).List(context.Background(), AuditFilter{CompanyID: "company-b"})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(others) != 0 {
+		t.Errorf("company-b sees %d events of company-a", len(others))
+	}
+}
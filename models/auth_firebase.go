@@ -0,0 +1,307 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/uuid"
+	"github.com/nirshpaa/godam-backend/libraries/token"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrInvalidCredentials is returned for unknown emails and wrong passwords
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrEmailTaken is returned when registering an email that already has an account
+	ErrEmailTaken = errors.New("email is already registered")
+	// ErrInvalidRefreshToken is returned for unknown, expired, revoked or reused refresh tokens
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+)
+
+// The collections below hold authentication bookkeeping. Their expires_at
+// fields are suitable for Firestore TTL policies.
+
+// LocalAccountFirebase stores email and password credentials for deployments
+// that do not use Firebase Authentication
+type LocalAccountFirebase struct {
+	client *firestore.Client
+}
+
+// NewLocalAccountFirebase creates a new LocalAccountFirebase instance
+func NewLocalAccountFirebase(client *firestore.Client) *LocalAccountFirebase {
+	return &LocalAccountFirebase{
+		client: client,
+	}
+}
+
+// Register creates credentials and a user profile and returns the new user ID
+func (a *LocalAccountFirebase) Register(ctx context.Context, email, password, name string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	uid := uuid.New().String()
+	err = a.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		existing, err := tx.Documents(a.client.Collection("credentials").Where("email", "==", email).Limit(1)).GetAll()
+		if err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			return ErrEmailTaken
+		}
+		return tx.Create(a.client.Collection("credentials").Doc(uid), map[string]interface{}{
+			"email":         email,
+			"password_hash": string(hash),
+			"created_at":    time.Now(),
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	user := &FirebaseUser{ID: uid, Email: email, Name: name}
+	if err := createDocument(ctx, a.client, a.client.Collection("users").Doc(uid), user); err != nil {
+		log.Printf("Error creating user profile: %v", err)
+		return "", err
+	}
+	return uid, nil
+}
+
+// Authenticate checks an email and password and returns the user ID and stored email
+func (a *LocalAccountFirebase) Authenticate(ctx context.Context, email, password string) (string, string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	doc, err := a.client.Collection("credentials").Where("email", "==", email).Limit(1).Documents(ctx).Next()
+	if err == iterator.Done {
+		// Hash anyway so unknown emails take as long as wrong passwords
+		bcrypt.CompareHashAndPassword([]byte("$2a$10$7EqJtq98hPqEX7fNZaFWoOhi5BWX4Z2J7QFQ8aRgmuv1qGH5v5bku"), []byte(password))
+		return "", "", ErrInvalidCredentials
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	hash, _ := doc.Data()["password_hash"].(string)
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return "", "", ErrInvalidCredentials
+	}
+	return doc.Ref.ID, email, nil
+}
+
+// RefreshTokenFirebase stores refresh tokens for local authentication. Tokens
+// are single use: refreshing replaces a token with a new one of the same
+// family, and presenting a replaced token revokes the whole family.
+type RefreshTokenFirebase struct {
+	client *firestore.Client
+	ttl    time.Duration
+}
+
+// NewRefreshTokenFirebase creates a new RefreshTokenFirebase instance
+func NewRefreshTokenFirebase(client *firestore.Client, ttl time.Duration) *RefreshTokenFirebase {
+	return &RefreshTokenFirebase{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+// Issue creates a refresh token for a user, starting a new family
+func (r *RefreshTokenFirebase) Issue(ctx context.Context, uid, email string) (string, error) {
+	refreshToken, data, err := r.newToken(uid, email, uuid.New().String())
+	if err != nil {
+		return "", err
+	}
+	if _, err := r.client.Collection("refresh_tokens").Doc(hashRefreshToken(refreshToken)).Set(ctx, data); err != nil {
+		return "", err
+	}
+	return refreshToken, nil
+}
+
+// Rotate exchanges a refresh token for a new one and returns the user it belongs to
+func (r *RefreshTokenFirebase) Rotate(ctx context.Context, refreshToken string) (string, string, string, error) {
+	ref := r.client.Collection("refresh_tokens").Doc(hashRefreshToken(refreshToken))
+
+	var uid, email, next, reusedFamily string
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if status.Code(err) == codes.NotFound {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+
+		data := doc.Data()
+		family, _ := data["family_id"].(string)
+		if revoked, _ := data["revoked"].(bool); revoked {
+			reusedFamily = family
+			return ErrInvalidRefreshToken
+		}
+		if expiresAt, _ := data["expires_at"].(time.Time); time.Now().After(expiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		uid, _ = data["user_id"].(string)
+		email, _ = data["email"].(string)
+		var nextData map[string]interface{}
+		next, nextData, err = r.newToken(uid, email, family)
+		if err != nil {
+			return err
+		}
+		if err := tx.Update(ref, []firestore.Update{{Path: "revoked", Value: true}}); err != nil {
+			return err
+		}
+		return tx.Set(r.client.Collection("refresh_tokens").Doc(hashRefreshToken(next)), nextData)
+	})
+	if reusedFamily != "" {
+		log.Printf("Refresh token reuse detected, revoking token family %s", reusedFamily)
+		if err := r.revokeFamily(ctx, reusedFamily); err != nil {
+			log.Printf("Error revoking refresh token family: %v", err)
+		}
+	}
+	if err != nil {
+		return "", "", "", err
+	}
+	return uid, email, next, nil
+}
+
+// Revoke revokes a refresh token and every token of its family
+func (r *RefreshTokenFirebase) Revoke(ctx context.Context, refreshToken string) error {
+	doc, err := r.client.Collection("refresh_tokens").Doc(hashRefreshToken(refreshToken)).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return err
+	}
+	family, _ := doc.Data()["family_id"].(string)
+	return r.revokeFamily(ctx, family)
+}
+
+func (r *RefreshTokenFirebase) revokeFamily(ctx context.Context, family string) error {
+	docs, err := r.client.Collection("refresh_tokens").Where("family_id", "==", family).Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		if _, err := doc.Ref.Update(ctx, []firestore.Update{{Path: "revoked", Value: true}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RefreshTokenFirebase) newToken(uid, email, family string) (string, map[string]interface{}, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), map[string]interface{}{
+		"user_id":    uid,
+		"email":      email,
+		"family_id":  family,
+		"revoked":    false,
+		"created_at": time.Now(),
+		"expires_at": time.Now().Add(r.ttl),
+	}, nil
+}
+
+// TokenRevocations records access tokens revoked before they expire, such as on logout
+type TokenRevocations struct {
+	client *firestore.Client
+}
+
+// NewTokenRevocations creates a new TokenRevocations instance
+func NewTokenRevocations(client *firestore.Client) *TokenRevocations {
+	return &TokenRevocations{
+		client: client,
+	}
+}
+
+// Revoke records an access token as revoked until it expires
+func (t *TokenRevocations) Revoke(ctx context.Context, claims *token.Claims) error {
+	_, err := t.client.Collection("revoked_tokens").Doc(claims.ID).Set(ctx, map[string]interface{}{
+		"user_id":    claims.UID,
+		"expires_at": claims.ExpiresAt,
+	})
+	return err
+}
+
+// IsRevoked reports whether an access token has been revoked
+func (t *TokenRevocations) IsRevoked(ctx context.Context, claims *token.Claims) (bool, error) {
+	if claims.ID == "" {
+		return false, nil
+	}
+	_, err := t.client.Collection("revoked_tokens").Doc(claims.ID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SigningKeyFirebase keeps the signing keys of the local token issuer in Firestore
+type SigningKeyFirebase struct {
+	client *firestore.Client
+}
+
+// NewSigningKeyFirebase creates a new SigningKeyFirebase instance
+func NewSigningKeyFirebase(client *firestore.Client) *SigningKeyFirebase {
+	return &SigningKeyFirebase{
+		client: client,
+	}
+}
+
+// Load returns every stored signing key
+func (s *SigningKeyFirebase) Load(ctx context.Context) ([]*token.SigningKey, error) {
+	docs, err := s.client.Collection("signing_keys").Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*token.SigningKey
+	for _, doc := range docs {
+		pem, _ := doc.Data()["private_key"].(string)
+		private, err := token.DecodePrivateKey(pem)
+		if err != nil {
+			log.Printf("Error decoding signing key %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		createdAt, _ := doc.Data()["created_at"].(time.Time)
+		keys = append(keys, &token.SigningKey{ID: doc.Ref.ID, PrivateKey: private, CreatedAt: createdAt})
+	}
+	return keys, nil
+}
+
+// Save stores a signing key
+func (s *SigningKeyFirebase) Save(ctx context.Context, key *token.SigningKey) error {
+	_, err := s.client.Collection("signing_keys").Doc(key.ID).Set(ctx, map[string]interface{}{
+		"private_key": token.EncodePrivateKey(key.PrivateKey),
+		"created_at":  key.CreatedAt,
+	})
+	return err
+}
+
+// Delete removes a signing key
+func (s *SigningKeyFirebase) Delete(ctx context.Context, id string) error {
+	_, err := s.client.Collection("signing_keys").Doc(id).Delete(ctx)
+	return err
+}
+
+func hashRefreshToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
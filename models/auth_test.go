@@ -0,0 +1,62 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+)
+
+func TestRefreshTokenRotation(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := context.Background()
+	tokens := NewRefreshTokenFirebase(client, time.Hour)
+
+	first, err := tokens.Issue(ctx, "user-1", "user@example.com")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	uid, email, second, err := tokens.Rotate(ctx, first)
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if uid != "user-1" || email != "user@example.com" || second == first {
+		t.Errorf("Rotate = %q, %q, %q", uid, email, second)
+	}
+	if _, _, _, err := tokens.Rotate(ctx, "unknown"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Rotate of an unknown token = %v, want ErrInvalidRefreshToken", err)
+	}
+
+	// Presenting the replaced token again revokes the whole family
+	if _, _, _, err := tokens.Rotate(ctx, first); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("reusing a refresh token = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, _, _, err := tokens.Rotate(ctx, second); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Rotate of the family's newest token after reuse = %v, want ErrInvalidRefreshToken", err)
+	}
+
+	// Other families are left alone
+	other, err := tokens.Issue(ctx, "user-1", "user@example.com")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if _, _, _, err := tokens.Rotate(ctx, other); err != nil {
+		t.Errorf("Rotate of another family = %v", err)
+	}
+}
+
+func TestRefreshTokenExpiry(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := context.Background()
+	tokens := NewRefreshTokenFirebase(client, -time.Minute)
+
+	expired, err := tokens.Issue(ctx, "user-1", "")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if _, _, _, err := tokens.Rotate(ctx, expired); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Rotate of an expired token = %v, want ErrInvalidRefreshToken", err)
+	}
+}
@@ -0,0 +1,193 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/nirshpaa/godam-backend/libraries/barcode"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrBarcodeRangeExhausted is returned when a company has used every internal barcode of its block
+var ErrBarcodeRangeExhausted = errors.New("internal barcode range exhausted")
+
+// AssignedBarcode is an internal barcode given to a product
+type AssignedBarcode struct {
+	Code         string `json:"code"`
+	BarcodeValue string `json:"barcode_value"`
+}
+
+// BarcodeFirebase hands out internal EAN-13 barcodes. Every company owns a
+// block of codes in barcode_ranges; blocks are numbered by a shared counter.
+// Both are bookkeeping and bypass versioning and the audit log.
+type BarcodeFirebase struct {
+	client *firestore.Client
+}
+
+// NewBarcodeFirebase creates a new BarcodeFirebase instance
+func NewBarcodeFirebase(client *firestore.Client) *BarcodeFirebase {
+	return &BarcodeFirebase{
+		client: client,
+	}
+}
+
+// Reserve allocates n unused internal barcodes for the company in the
+// context. Codes some product already carries, entered by hand or handed out
+// under an earlier numbering, are skipped.
+func (b *BarcodeFirebase) Reserve(ctx context.Context, n int) ([]string, error) {
+	companyID := CompanyFromContext(ctx)
+	if companyID == "" {
+		return nil, ErrCompanyMismatch
+	}
+
+	values := make([]string, 0, n)
+	for len(values) < n {
+		reserved, err := b.reserve(ctx, companyID, n-len(values))
+		if err != nil {
+			return nil, err
+		}
+		free, err := b.unused(ctx, reserved)
+		if err != nil {
+			log.Printf("Error checking reserved barcodes: %v", err)
+			return nil, err
+		}
+		values = append(values, free...)
+	}
+	return values, nil
+}
+
+// reserve takes the next n sequence numbers of the company's block
+func (b *BarcodeFirebase) reserve(ctx context.Context, companyID string, n int) ([]string, error) {
+	rangeRef := b.client.Collection("barcode_ranges").Doc(companyID)
+	counterRef := b.client.Collection("counters").Doc("barcode_blocks")
+
+	var block, first int
+	err := b.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(rangeRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+
+		if err == nil {
+			block = int(toFloat64(doc.Data()["block"]))
+			first = int(toFloat64(doc.Data()["next_sequence"]))
+		} else {
+			// First use: take the next free block
+			counter, err := tx.Get(counterRef)
+			if err != nil && status.Code(err) != codes.NotFound {
+				return err
+			}
+			block, first = 0, 1
+			if err == nil {
+				block = int(toFloat64(counter.Data()["next"]))
+			}
+			if block > barcode.MaxInternalBlock {
+				return ErrBarcodeRangeExhausted
+			}
+			if err := tx.Set(counterRef, map[string]interface{}{"next": block + 1}); err != nil {
+				return err
+			}
+		}
+
+		if first+n-1 > barcode.MaxInternalSequence {
+			return ErrBarcodeRangeExhausted
+		}
+		return tx.Set(rangeRef, map[string]interface{}{
+			"company_id":    companyID,
+			"block":         block,
+			"next_sequence": first + n,
+			"updated_at":    time.Now(),
+		})
+	})
+	if err != nil {
+		log.Printf("Error reserving barcodes: %v", err)
+		return nil, err
+	}
+
+	values := make([]string, 0, n)
+	for sequence := first; sequence < first+n; sequence++ {
+		value, err := barcode.InternalEAN13(block, sequence)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// unused returns the values no product of any company carries
+func (b *BarcodeFirebase) unused(ctx context.Context, values []string) ([]string, error) {
+	taken := make(map[string]bool)
+	for start := 0; start < len(values); start += maxBranchFilter {
+		end := start + maxBranchFilter
+		if end > len(values) {
+			end = len(values)
+		}
+		docs, err := b.client.Collection("products").Where("barcode_value", "in", values[start:end]).Documents(ctx).GetAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			value, _ := doc.Data()["barcode_value"].(string)
+			taken[value] = true
+		}
+	}
+
+	free := make([]string, 0, len(values))
+	for _, value := range values {
+		if !taken[value] {
+			free = append(free, value)
+		}
+	}
+	return free, nil
+}
+
+// AssignMissing gives the products with the given codes that have no barcode
+// value an internal EAN-13 code. Without codes every product of the company
+// that lacks one is assigned.
+func (b *BarcodeFirebase) AssignMissing(ctx context.Context, productCodes []string) ([]AssignedBarcode, error) {
+	wanted := make(map[string]bool, len(productCodes))
+	for _, code := range productCodes {
+		wanted[code] = true
+	}
+
+	docs, err := scopedQuery(ctx, b.client.Collection("products")).Where("barcode_value", "==", "").Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("Error getting products without barcodes: %v", err)
+		return nil, err
+	}
+
+	var missing []*firestore.DocumentSnapshot
+	for _, doc := range docs {
+		code, _ := doc.Data()["code"].(string)
+		if IsTrashed(doc) || (len(wanted) > 0 && !wanted[code]) {
+			continue
+		}
+		missing = append(missing, doc)
+	}
+
+	values, err := b.Reserve(ctx, len(missing))
+	if err != nil {
+		return nil, err
+	}
+
+	assigned := make([]AssignedBarcode, 0, len(missing))
+	for i, doc := range missing {
+		err := updateDocument(ctx, b.client, doc.Ref, []firestore.Update{
+			{Path: "barcode_value", Value: values[i]},
+			{Path: "updated_at", Value: time.Now()},
+		})
+		if err != nil {
+			return assigned, fmt.Errorf("failed to assign barcode: %w", err)
+		}
+		code, _ := doc.Data()["code"].(string)
+		assigned = append(assigned, AssignedBarcode{Code: code, BarcodeValue: values[i]})
+	}
+
+	return assigned, nil
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nirshpaa/godam-backend/libraries/barcode"
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+)
+
+func TestBarcodeReserveSkipsTaken(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := context.Background()
+	barcodes := NewBarcodeFirebase(client)
+
+	// Another company already labelled a product with the second code of
+	// the first block, for example under an earlier numbering
+	taken, err := barcode.InternalEAN13(0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := client.Collection("products").Add(ctx, map[string]interface{}{
+		"company_id":    "company-b",
+		"code":          "B-1",
+		"barcode_value": taken,
+	}); err != nil {
+		t.Fatalf("seeding product: %v", err)
+	}
+
+	values, err := barcodes.Reserve(WithCompany(ctx, "company-a"), 3)
+	if err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	want := []string{}
+	for _, sequence := range []int{1, 3, 4} {
+		value, _ := barcode.InternalEAN13(0, sequence)
+		want = append(want, value)
+	}
+	if len(values) != len(want) {
+		t.Fatalf("Reserve = %v, want %v", values, want)
+	}
+	for i := range want {
+		if values[i] != want[i] {
+			t.Fatalf("Reserve = %v, want %v", values, want)
+		}
+	}
+
+	// The next reservation continues after the skipped code
+	next, err := barcodes.Reserve(WithCompany(ctx, "company-a"), 1)
+	if err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	if want, _ := barcode.InternalEAN13(0, 5); len(next) != 1 || next[0] != want {
+		t.Fatalf("second Reserve = %v, want [%s]", next, want)
+	}
+}
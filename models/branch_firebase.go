@@ -9,11 +9,12 @@ import (
 
 // BranchFirebaseModel represents a branch in the system for Firebase
 type BranchFirebaseModel struct {
-	ID      string `json:"id"`
-	Code    string `json:"code"`
-	Name    string `json:"name"`
-	Type    string `json:"type"`
-	Address string `json:"address,omitempty"`
+	ID        string `json:"id"`
+	Code      string `json:"code"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Address   string `json:"address,omitempty"`
+	CompanyID string `json:"company_id"`
 }
 
 // BranchFirebase represents the Firestore client for branch
@@ -31,13 +32,17 @@ func NewBranchFirebase(client *firestore.Client) *BranchFirebase {
 // List retrieves all branch records
 func (b *BranchFirebase) List(ctx context.Context) ([]*BranchFirebaseModel, error) {
 	var branches []*BranchFirebaseModel
-	docs, err := b.client.Collection("branches").Documents(ctx).GetAll()
+	docs, err := scopedQuery(ctx, b.client.Collection("branches")).Documents(ctx).GetAll()
 	if err != nil {
 		log.Printf("Error getting branches: %v", err)
 		return nil, err
 	}
 
 	for _, doc := range docs {
+		if IsTrashed(doc) || !inContextBranches(ctx, doc) {
+			continue
+		}
+
 		var branch BranchFirebaseModel
 		if err := doc.DataTo(&branch); err != nil {
 			log.Printf("Error converting branch data: %v", err)
@@ -57,6 +62,9 @@ func (b *BranchFirebase) Get(ctx context.Context, id string) (*BranchFirebaseMod
 		log.Printf("Error getting branch: %v", err)
 		return nil, err
 	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
 
 	var branch BranchFirebaseModel
 	if err := doc.DataTo(&branch); err != nil {
@@ -64,13 +72,15 @@ func (b *BranchFirebase) Get(ctx context.Context, id string) (*BranchFirebaseMod
 		return nil, err
 	}
 	branch.ID = doc.Ref.ID
+	recordVersion(ctx, doc.UpdateTime)
 
 	return &branch, nil
 }
 
 // Create creates a new branch record
 func (b *BranchFirebase) Create(ctx context.Context, branch *BranchFirebaseModel) (string, error) {
-	docRef, _, err := b.client.Collection("branches").Add(ctx, branch)
+	docRef := b.client.Collection("branches").NewDoc()
+	err := createDocument(ctx, b.client, docRef, branch)
 	if err != nil {
 		log.Printf("Error creating branch: %v", err)
 		return "", err
@@ -80,7 +90,7 @@ func (b *BranchFirebase) Create(ctx context.Context, branch *BranchFirebaseModel
 
 // Update updates an existing branch record
 func (b *BranchFirebase) Update(ctx context.Context, id string, branch *BranchFirebaseModel) error {
-	_, err := b.client.Collection("branches").Doc(id).Set(ctx, branch)
+	err := setDocument(ctx, b.client, b.client.Collection("branches").Doc(id), branch)
 	if err != nil {
 		log.Printf("Error updating branch: %v", err)
 		return err
@@ -90,7 +100,7 @@ func (b *BranchFirebase) Update(ctx context.Context, id string, branch *BranchFi
 
 // Delete deletes a branch record
 func (b *BranchFirebase) Delete(ctx context.Context, id string) error {
-	_, err := b.client.Collection("branches").Doc(id).Delete(ctx)
+	err := trashDocument(ctx, b.client, b.client.Collection("branches").Doc(id))
 	if err != nil {
 		log.Printf("Error deleting branch: %v", err)
 		return err
@@ -0,0 +1,212 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+)
+
+var (
+	// ErrBranchForbidden is returned for writes to a branch the caller may not use
+	ErrBranchForbidden = errors.New("branch is outside your permitted branches")
+	// ErrUnknownBranch is returned when granting a branch the company does not have
+	ErrUnknownBranch = errors.New("unknown branch")
+)
+
+// branchFields names the field holding the branch in each branch bearing collection
+var branchFields = map[string]string{
+	"deliveries":          "branch_id",
+	"delivery_returns":    "branch_id",
+	"purchases":           "branch_id",
+	"purchase_returns":    "branch_id",
+	"receives":            "branch_id",
+	"receive_returns":     "branch_id",
+	"sales_order_returns": "branch_id",
+	"stock":               "branch_id",
+	"stock_adjustments":   "branch_id",
+	"stock_counts":        "branch_id",
+}
+
+// maxBranchFilter is the most values Firestore accepts in one "in" filter
+const maxBranchFilter = 30
+
+// branchesContextKey is a custom type for the branches context key
+type branchesContextKey struct{}
+
+// WithBranches returns a context that limits branch bearing records to the
+// given branches. An empty list leaves every branch of the company visible.
+func WithBranches(ctx context.Context, branches []string) context.Context {
+	return context.WithValue(ctx, branchesContextKey{}, branches)
+}
+
+// BranchesFromContext returns the branches the request is limited to, or nil when it is not limited
+func BranchesFromContext(ctx context.Context) []string {
+	branches, _ := ctx.Value(branchesContextKey{}).([]string)
+	return branches
+}
+
+// BranchAllowed reports whether the request may use a branch
+func BranchAllowed(ctx context.Context, branchID string) bool {
+	branches := BranchesFromContext(ctx)
+	if len(branches) == 0 {
+		return true
+	}
+	for _, branch := range branches {
+		if branch == branchID {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeBranches limits a query on a branch bearing collection to the branches in the context.
+// Firestore rejects the query when there are more than maxBranchFilter
+// branches; use scopedDocuments to read those.
+func scopeBranches(ctx context.Context, collection string, query firestore.Query) firestore.Query {
+	if field, branches := branchFields[collection], BranchesFromContext(ctx); field != "" && len(branches) > 0 {
+		query = query.Where(field, "in", branches)
+	}
+	return query
+}
+
+// branchQueries limits a query on a branch bearing collection to the branches
+// in the context with one query per maxBranchFilter branches
+func branchQueries(ctx context.Context, collection string, query firestore.Query) []firestore.Query {
+	field, branches := branchFields[collection], BranchesFromContext(ctx)
+	if field == "" || len(branches) == 0 {
+		return []firestore.Query{query}
+	}
+
+	var queries []firestore.Query
+	for start := 0; start < len(branches); start += maxBranchFilter {
+		end := start + maxBranchFilter
+		if end > len(branches) {
+			end = len(branches)
+		}
+		queries = append(queries, query.Where(field, "in", branches[start:end]))
+	}
+	return queries
+}
+
+// inContextBranches reports whether a document is visible to the branches in the context.
+// Branches are matched on their own ID.
+func inContextBranches(ctx context.Context, doc *firestore.DocumentSnapshot) bool {
+	if len(BranchesFromContext(ctx)) == 0 || !doc.Exists() {
+		return true
+	}
+
+	collection := doc.Ref.Parent.ID
+	if collection == "branches" {
+		return BranchAllowed(ctx, doc.Ref.ID)
+	}
+
+	field := branchFields[collection]
+	if field == "" {
+		return true
+	}
+	branchID, _ := doc.Data()[field].(string)
+	return BranchAllowed(ctx, branchID)
+}
+
+// stampBranch checks the branch of new or replaced data against the context.
+// A caller limited to a single branch gets it filled in when none is given.
+func stampBranch(ctx context.Context, collection string, data interface{}) error {
+	branches, field := BranchesFromContext(ctx), branchFields[collection]
+	if len(branches) == 0 || field == "" {
+		return nil
+	}
+
+	if m, ok := data.(map[string]interface{}); ok {
+		branchID, _ := m[field].(string)
+		if branchID == "" && len(branches) == 1 {
+			m[field] = branches[0]
+			return nil
+		}
+		if !BranchAllowed(ctx, branchID) {
+			return ErrBranchForbidden
+		}
+		return nil
+	}
+
+	value := reflect.ValueOf(data)
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for i := 0; i < value.NumField(); i++ {
+		if storedFieldName(value.Type().Field(i)) != field || value.Field(i).Kind() != reflect.String {
+			continue
+		}
+		branchID := value.Field(i).String()
+		if branchID == "" && len(branches) == 1 && value.Field(i).CanSet() {
+			value.Field(i).SetString(branches[0])
+			return nil
+		}
+		if !BranchAllowed(ctx, branchID) {
+			return ErrBranchForbidden
+		}
+		return nil
+	}
+	return nil
+}
+
+// checkBranchUpdates rejects field updates that move a document to a branch outside the context
+func checkBranchUpdates(ctx context.Context, collection string, updates []firestore.Update) error {
+	field := branchFields[collection]
+	if field == "" {
+		return nil
+	}
+
+	for _, update := range updates {
+		if update.Path != field {
+			continue
+		}
+		branchID, _ := update.Value.(string)
+		if !BranchAllowed(ctx, branchID) {
+			return ErrBranchForbidden
+		}
+	}
+	return nil
+}
+
+// checkGrantedBranches returns an error unless every branch granted to a
+// member is a branch of the company in the context that the caller may use.
+// Granting no branches grants them all, which callers limited to some
+// branches may not do.
+func checkGrantedBranches(ctx context.Context, client *firestore.Client, branches []string) error {
+	if len(branches) == 0 {
+		if len(BranchesFromContext(ctx)) > 0 {
+			return ErrBranchForbidden
+		}
+		return nil
+	}
+
+	refs := make([]*firestore.DocumentRef, 0, len(branches))
+	for _, branchID := range branches {
+		if branchID == "" || strings.Contains(branchID, "/") {
+			return fmt.Errorf("%w %q", ErrUnknownBranch, branchID)
+		}
+		if !BranchAllowed(ctx, branchID) {
+			return ErrBranchForbidden
+		}
+		refs = append(refs, client.Collection("branches").Doc(branchID))
+	}
+
+	docs, err := client.GetAll(ctx, refs)
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		if !doc.Exists() || checkReadable(ctx, doc) != nil {
+			return fmt.Errorf("%w %q", ErrUnknownBranch, doc.Ref.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+)
+
+func TestStampBranch(t *testing.T) {
+	single := WithBranches(context.Background(), []string{"branch-1"})
+	many := WithBranches(context.Background(), []string{"branch-1", "branch-2"})
+
+	count := &FirebaseStockCount{}
+	if err := stampBranch(single, "stock_counts", count); err != nil || count.BranchID != "branch-1" {
+		t.Errorf("struct without a branch = %q, %v, want branch-1 filled in", count.BranchID, err)
+	}
+	count = &FirebaseStockCount{BranchID: "branch-3"}
+	if err := stampBranch(many, "stock_counts", count); !errors.Is(err, ErrBranchForbidden) {
+		t.Errorf("struct in another branch = %v, want ErrBranchForbidden", err)
+	}
+	count = &FirebaseStockCount{}
+	if err := stampBranch(many, "stock_counts", count); !errors.Is(err, ErrBranchForbidden) {
+		t.Errorf("struct without a branch for many branches = %v, want ErrBranchForbidden", err)
+	}
+	if err := stampBranch(many, "stock_counts", &FirebaseStockCount{BranchID: "branch-2"}); err != nil {
+		t.Errorf("struct in a permitted branch = %v", err)
+	}
+
+	data := map[string]interface{}{}
+	if err := stampBranch(single, "receives", data); err != nil || data["branch_id"] != "branch-1" {
+		t.Errorf("map without a branch = %v, %v, want branch-1 filled in", data["branch_id"], err)
+	}
+	if err := stampBranch(many, "receives", map[string]interface{}{"branch_id": "branch-3"}); !errors.Is(err, ErrBranchForbidden) {
+		t.Errorf("map in another branch = %v, want ErrBranchForbidden", err)
+	}
+	if err := stampBranch(context.Background(), "stock_counts", &FirebaseStockCount{BranchID: "branch-3"}); err != nil {
+		t.Errorf("unrestricted caller = %v", err)
+	}
+}
+
+func TestScopedDocumentsManyBranches(t *testing.T) {
+	client := firestoretest.New(t)
+	company := WithActor(WithCompany(context.Background(), "company-a"), "user-a")
+	counts := NewStockCountFirebase(client, nil)
+
+	var branches []string
+	for i := 0; i < 2*maxBranchFilter+5; i++ {
+		branches = append(branches, fmt.Sprintf("branch-%02d", i))
+	}
+	for _, branch := range append([]string{"outside"}, branches...) {
+		if err := counts.Create(company, &FirebaseStockCount{BranchID: branch}); err != nil {
+			t.Fatalf("Create in %s: %v", branch, err)
+		}
+	}
+
+	listed, err := counts.List(WithBranches(company, branches), StockCountOpen)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(listed) != len(branches) {
+		t.Errorf("listed %d counts, want %d", len(listed), len(branches))
+	}
+	for _, count := range listed {
+		if count.BranchID == "outside" {
+			t.Error("listed a count outside the permitted branches")
+		}
+	}
+
+	if listed, err := counts.List(company, ""); err != nil || len(listed) != len(branches)+1 {
+		t.Errorf("unrestricted List = %d counts, %v, want %d", len(listed), err, len(branches)+1)
+	}
+}
+
+func TestBranchStock(t *testing.T) {
+	client := firestoretest.New(t)
+	company := WithActor(WithCompany(context.Background(), "company-a"), "user-a")
+	products, err := NewProductFirebase(client)
+	if err != nil {
+		t.Fatalf("NewProductFirebase: %v", err)
+	}
+	seedProduct(t, company, products, &FirebaseProduct{Code: "P1", Name: "Product", PurchasePrice: 2, MinimumStock: 10})
+	adjustments := NewStockAdjustmentFirebase(client, products, NewApprovalFirebase(client))
+	stock := NewStockFirebase(client)
+
+	branch1 := WithBranches(company, []string{"branch-1"})
+	branch2 := WithBranches(company, []string{"branch-2"})
+	for _, adjust := range []struct {
+		ctx      context.Context
+		quantity float64
+	}{{branch1, 5}, {branch1, -2}, {branch2, 4}} {
+		if err := adjustments.Create(adjust.ctx, &FirebaseStockAdjustment{ProductCode: "P1", Quantity: adjust.quantity, Reason: "count"}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	if err := adjustments.Create(branch1, &FirebaseStockAdjustment{BranchID: "branch-2", ProductCode: "P1", Quantity: 1, Reason: "count"}); !errors.Is(err, ErrBranchForbidden) {
+		t.Errorf("adjusting another branch = %v, want ErrBranchForbidden", err)
+	}
+
+	product, err := products.Get(company, "P1")
+	if err != nil || product.MinimumStock != 17 {
+		t.Fatalf("product stock = %v, %v, want 17", product, err)
+	}
+
+	listed, err := stock.List(branch1, "P1")
+	if err != nil || len(listed) != 1 || listed[0].BranchID != "branch-1" || listed[0].Quantity != 3 {
+		t.Errorf("branch-1 stock = %+v, %v, want 3 in branch-1 only", listed, err)
+	}
+	if adjusted, err := adjustments.List(branch2); err != nil || len(adjusted) != 1 || adjusted[0].BranchID != "branch-2" {
+		t.Errorf("branch-2 adjustments = %+v, %v, want only its own", adjusted, err)
+	}
+
+	listed, err = stock.List(WithBranches(company, []string{"branch-1", "branch-2"}), "")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if totals := StockTotals(listed); totals["P1"] != 7 {
+		t.Errorf("consolidated stock = %v, want 7 of P1", totals)
+	}
+	if listed, err := stock.List(WithCompany(context.Background(), "company-b"), ""); err != nil || len(listed) != 0 {
+		t.Errorf("another company's stock = %+v, %v, want none", listed, err)
+	}
+}
@@ -32,7 +32,7 @@ func (b *BrandFirebase) List(ctx context.Context) ([]BrandFirebaseModel, error)
 	var brands []BrandFirebaseModel
 
 	// Get all brands from Firestore
-	iter := b.client.Collection("brands").Documents(ctx)
+	iter := scopedQuery(ctx, b.client.Collection("brands")).Documents(ctx)
 	docs, err := iter.GetAll()
 	if err != nil {
 		log.Printf("Error getting brands: %v", err)
@@ -41,6 +41,10 @@ func (b *BrandFirebase) List(ctx context.Context) ([]BrandFirebaseModel, error)
 
 	// Convert Firestore documents to BrandFirebaseModel structs
 	for _, doc := range docs {
+		if IsTrashed(doc) {
+			continue
+		}
+
 		var brand BrandFirebaseModel
 		if err := doc.DataTo(&brand); err != nil {
 			log.Printf("Error converting brand document: %v", err)
@@ -60,6 +64,9 @@ func (b *BrandFirebase) Get(ctx context.Context, id string) (*BrandFirebaseModel
 		log.Printf("Error getting brand: %v", err)
 		return nil, err
 	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
 
 	var brand BrandFirebaseModel
 	if err := doc.DataTo(&brand); err != nil {
@@ -67,6 +74,7 @@ func (b *BrandFirebase) Get(ctx context.Context, id string) (*BrandFirebaseModel
 		return nil, err
 	}
 	brand.ID = doc.Ref.ID
+	recordVersion(ctx, doc.UpdateTime)
 
 	return &brand, nil
 }
@@ -76,7 +84,7 @@ func (b *BrandFirebase) Create(ctx context.Context, brand *BrandFirebaseModel) e
 	docRef := b.client.Collection("brands").NewDoc()
 	brand.ID = docRef.ID
 
-	_, err := docRef.Set(ctx, brand)
+	err := createDocument(ctx, b.client, docRef, brand)
 	if err != nil {
 		log.Printf("Error creating brand: %v", err)
 		return err
@@ -87,7 +95,7 @@ func (b *BrandFirebase) Create(ctx context.Context, brand *BrandFirebaseModel) e
 
 // Update updates an existing brand
 func (b *BrandFirebase) Update(ctx context.Context, brand *BrandFirebaseModel) error {
-	_, err := b.client.Collection("brands").Doc(brand.ID).Set(ctx, brand)
+	err := setDocument(ctx, b.client, b.client.Collection("brands").Doc(brand.ID), brand)
 	if err != nil {
 		log.Printf("Error updating brand: %v", err)
 		return err
@@ -98,7 +106,7 @@ func (b *BrandFirebase) Update(ctx context.Context, brand *BrandFirebaseModel) e
 
 // Delete deletes a brand
 func (b *BrandFirebase) Delete(ctx context.Context, id string) error {
-	_, err := b.client.Collection("brands").Doc(id).Delete(ctx)
+	err := trashDocument(ctx, b.client, b.client.Collection("brands").Doc(id))
 	if err != nil {
 		log.Printf("Error deleting brand: %v", err)
 		return err
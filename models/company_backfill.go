@@ -0,0 +1,148 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+)
+
+// ownerSources lists, for the collections that were shared before they were
+// company scoped, the fields of company scoped records pointing at them. A
+// record written before it had a company belongs to the company of the
+// records pointing at it.
+var ownerSources = map[string][]reference{
+	"branches": {
+		{"deliveries", "branch_id"},
+		{"delivery_returns", "branch_id"},
+		{"invitations", "branches"},
+		{"memberships", "branches"},
+		{"purchases", "branch_id"},
+		{"purchase_returns", "branch_id"},
+		{"receives", "branch_id"},
+		{"receive_returns", "branch_id"},
+		{"sales_order_returns", "branch_id"},
+		{"stock", "branch_id"},
+		{"stock_adjustments", "branch_id"},
+		{"stock_counts", "branch_id"},
+	},
+	"regions": nil,
+	"shelves": {
+		{"deliveries", "delivery_details.shelve_id"},
+		{"receives", "receive_details.shelve_id"},
+		{"shelf_audits", "shelve_id"},
+		{"stock_counts", "shelve_id"},
+	},
+	"suppliers": {
+		{"purchases", "supplier_id"},
+	},
+}
+
+// AssignCompanies stores the owning company on branches, regions, shelves and
+// suppliers written before they were company scoped, which no company can
+// see until then. Records only referenced by one company go to it; the others
+// go to fallback when it is set and no company references them. Records
+// referenced by several companies are left for an administrator to split.
+// Records that already have a company are left alone, so it is safe to run
+// again. It returns the number of records assigned and the paths of those
+// left unassigned.
+func AssignCompanies(ctx context.Context, client *firestore.Client, fallback string) (int, []string, error) {
+	assigned, unassigned := 0, []string{}
+
+	collections := make([]string, 0, len(ownerSources))
+	for collection := range ownerSources {
+		collections = append(collections, collection)
+	}
+	sort.Strings(collections)
+
+	for _, collection := range collections {
+		field := companyFields[collection]
+		docs, err := client.Collection(collection).Documents(ctx).GetAll()
+		if err != nil {
+			return assigned, unassigned, fmt.Errorf("failed to list %s: %w", collection, err)
+		}
+
+		legacy := map[string]*firestore.DocumentSnapshot{}
+		for _, doc := range docs {
+			if owner, _ := doc.Data()[field].(string); owner == "" {
+				legacy[doc.Ref.ID] = doc
+			}
+		}
+		if len(legacy) == 0 {
+			continue
+		}
+
+		owners, err := referencingCompanies(ctx, client, ownerSources[collection], legacy)
+		if err != nil {
+			return assigned, unassigned, err
+		}
+
+		for id, doc := range legacy {
+			owner := fallback
+			if len(owners[id]) > 0 {
+				owner = ""
+			}
+			if len(owners[id]) == 1 {
+				for companyID := range owners[id] {
+					owner = companyID
+				}
+			}
+			if owner == "" {
+				unassigned = append(unassigned, collection+"/"+id)
+				continue
+			}
+
+			_, err := doc.Ref.Update(ctx, []firestore.Update{{Path: field, Value: owner}}, firestore.LastUpdateTime(doc.UpdateTime))
+			if err != nil {
+				return assigned, unassigned, fmt.Errorf("failed to assign %s/%s: %w", collection, id, err)
+			}
+			assigned++
+		}
+	}
+
+	sort.Strings(unassigned)
+	return assigned, unassigned, nil
+}
+
+// referencingCompanies returns the companies of the records in sources that
+// point at each of ids
+func referencingCompanies(ctx context.Context, client *firestore.Client, sources []reference, ids map[string]*firestore.DocumentSnapshot) (map[string]map[string]bool, error) {
+	owners := map[string]map[string]bool{}
+	for _, source := range sources {
+		docs, err := client.Collection(source.collection).Documents(ctx).GetAll()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", source.collection, err)
+		}
+
+		path := strings.Split(source.field, ".")
+		for _, doc := range docs {
+			owner, _ := doc.Data()[companyFields[source.collection]].(string)
+			if owner == "" {
+				continue
+			}
+
+			var keys []interface{}
+			for _, value := range lookupField(doc.Data(), path) {
+				// Lists of IDs, such as the branches of a membership
+				if list, ok := value.([]interface{}); ok {
+					keys = append(keys, list...)
+				} else {
+					keys = append(keys, value)
+				}
+			}
+			for _, key := range keys {
+				id, _ := key.(string)
+				if _, ok := ids[id]; !ok {
+					continue
+				}
+				if owners[id] == nil {
+					owners[id] = map[string]bool{}
+				}
+				owners[id][owner] = true
+			}
+		}
+	}
+	return owners, nil
+}
@@ -24,12 +24,15 @@ type CompanyFirebase struct {
 // List returns all companies
 func (u *CompanyFirebase) List(ctx context.Context) ([]FirebaseCompany, error) {
 	var companies []FirebaseCompany
-	iter := u.Client.Collection("companies").Documents(ctx)
+	iter := scopedQuery(ctx, u.Client.Collection("companies")).Documents(ctx)
 	for {
 		doc, err := iter.Next()
 		if err != nil {
 			break
 		}
+		if IsTrashed(doc) {
+			continue
+		}
 		var company FirebaseCompany
 		if err := doc.DataTo(&company); err != nil {
 			return nil, err
@@ -46,33 +49,44 @@ func (u *CompanyFirebase) Get(ctx context.Context, id string) (*FirebaseCompany,
 	if err != nil {
 		return nil, err
 	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
 	var company FirebaseCompany
 	if err := doc.DataTo(&company); err != nil {
 		return nil, err
 	}
 	company.ID = doc.Ref.ID
+	recordVersion(ctx, doc.UpdateTime)
 	return &company, nil
 }
 
 // Create creates a new company
 func (u *CompanyFirebase) Create(ctx context.Context, company *FirebaseCompany) (string, error) {
-	doc, _, err := u.Client.Collection("companies").Add(ctx, company)
+	doc := u.Client.Collection("companies").NewDoc()
+	err := createDocument(ctx, u.Client, doc, company)
 	if err != nil {
 		return "", err
 	}
 	return doc.ID, nil
 }
 
-// Update updates a company
+// Update updates a company. Only the profile fields are written so the
+// member list is kept intact.
 func (u *CompanyFirebase) Update(ctx context.Context, id string, company *FirebaseCompany) error {
-	_, err := u.Client.Collection("companies").Doc(id).Set(ctx, company)
-	return err
+	return updateDocument(ctx, u.Client, u.Client.Collection("companies").Doc(id), []firestore.Update{
+		{Path: "id", Value: id},
+		{Path: "name", Value: company.Name},
+		{Path: "address", Value: company.Address},
+		{Path: "phone", Value: company.Phone},
+		{Path: "email", Value: company.Email},
+		{Path: "description", Value: company.Description},
+	})
 }
 
 // Delete deletes a company
 func (u *CompanyFirebase) Delete(ctx context.Context, id string) error {
-	_, err := u.Client.Collection("companies").Doc(id).Delete(ctx)
-	return err
+	return trashDocument(ctx, u.Client, u.Client.Collection("companies").Doc(id))
 }
 
 // NewCompanyFirebase creates a new CompanyFirebase instance
@@ -84,11 +98,10 @@ func NewCompanyFirebase(client *firestore.Client) *CompanyFirebase {
 
 // Join joins a company
 func (u *CompanyFirebase) Join(ctx context.Context, id string, userID string) error {
-	_, err := u.Client.Collection("companies").Doc(id).Update(ctx, []firestore.Update{
+	return updateDocument(ctx, u.Client, u.Client.Collection("companies").Doc(id), []firestore.Update{
 		{
 			Path:  "users",
 			Value: firestore.ArrayUnion(userID),
 		},
 	})
-	return err
 }
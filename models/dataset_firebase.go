@@ -0,0 +1,259 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Sources of dataset images
+const (
+	DatasetSourceUpload  = "upload"
+	DatasetSourceScan    = "scan"
+	DatasetSourceProduct = "product_image"
+)
+
+// FirebaseDatasetImage is a training image of a product. Images are never
+// deleted, only excluded, so snapshots keep pointing at their files.
+type FirebaseDatasetImage struct {
+	ID           string    `firestore:"-" json:"id"`
+	CompanyID    string    `firestore:"company_id" json:"company_id"`
+	ProductCode  string    `firestore:"product_code" json:"product_code"`
+	Source       string    `firestore:"source" json:"source"`
+	ScanID       string    `firestore:"scan_id,omitempty" json:"scan_id,omitempty"`
+	Labels       []string  `firestore:"labels" json:"labels"`
+	QualityFlags []string  `firestore:"quality_flags" json:"quality_flags"`
+	Split        string    `firestore:"split" json:"split"`
+	Excluded     bool      `firestore:"excluded" json:"excluded"`
+	File         string    `firestore:"file" json:"file"`
+	Checksum     string    `firestore:"checksum" json:"checksum"`
+	Hash         string    `firestore:"hash" json:"hash"`
+	Width        int       `firestore:"width" json:"width"`
+	Height       int       `firestore:"height" json:"height"`
+	CreatedBy    string    `firestore:"created_by" json:"created_by"`
+	CreatedAt    time.Time `firestore:"created_at" json:"created_at"`
+	UpdatedAt    time.Time `firestore:"updated_at" json:"updated_at"`
+}
+
+// DatasetFilter narrows a dataset image listing; empty fields match everything
+type DatasetFilter struct {
+	ProductCode     string
+	ScanID          string
+	Source          string
+	Split           string
+	IncludeExcluded bool
+}
+
+// FirebaseDatasetSnapshot is a frozen version of a company's dataset. The
+// image list lives in the manifest file so snapshots of any size fit.
+type FirebaseDatasetSnapshot struct {
+	ID        string    `firestore:"-" json:"id"`
+	CompanyID string    `firestore:"company_id" json:"company_id"`
+	Version   int       `firestore:"version" json:"version"`
+	Note      string    `firestore:"note" json:"note"`
+	Images    int       `firestore:"images" json:"images"`
+	Classes   int       `firestore:"classes" json:"classes"`
+	Train     int       `firestore:"train" json:"train"`
+	Val       int       `firestore:"val" json:"val"`
+	Manifest  string    `firestore:"manifest" json:"manifest"`
+	Checksum  string    `firestore:"checksum" json:"checksum"`
+	CreatedBy string    `firestore:"created_by" json:"created_by"`
+	CreatedAt time.Time `firestore:"created_at" json:"created_at"`
+}
+
+// DatasetFirebase represents the Firestore client for dataset images and snapshots
+type DatasetFirebase struct {
+	client *firestore.Client
+}
+
+// NewDatasetFirebase creates a new DatasetFirebase instance
+func NewDatasetFirebase(client *firestore.Client) *DatasetFirebase {
+	return &DatasetFirebase{
+		client: client,
+	}
+}
+
+// ListImages retrieves the dataset images of the company in the context
+func (d *DatasetFirebase) ListImages(ctx context.Context, filter DatasetFilter) ([]*FirebaseDatasetImage, error) {
+	query := scopedQuery(ctx, d.client.Collection("dataset_images"))
+	if filter.ProductCode != "" {
+		query = query.Where("product_code", "==", filter.ProductCode)
+	}
+	if filter.ScanID != "" {
+		query = query.Where("scan_id", "==", filter.ScanID)
+	}
+	if filter.Source != "" {
+		query = query.Where("source", "==", filter.Source)
+	}
+	if filter.Split != "" {
+		query = query.Where("split", "==", filter.Split)
+	}
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("Error getting dataset images: %v", err)
+		return nil, err
+	}
+
+	images := []*FirebaseDatasetImage{}
+	for _, doc := range docs {
+		var image FirebaseDatasetImage
+		if err := doc.DataTo(&image); err != nil {
+			log.Printf("Error converting dataset image data: %v", err)
+			continue
+		}
+		if image.Excluded && !filter.IncludeExcluded {
+			continue
+		}
+		image.ID = doc.Ref.ID
+		images = append(images, &image)
+	}
+	return images, nil
+}
+
+// GetImage retrieves a dataset image by ID
+func (d *DatasetFirebase) GetImage(ctx context.Context, id string) (*FirebaseDatasetImage, error) {
+	doc, err := d.client.Collection("dataset_images").Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
+	recordVersion(ctx, doc.UpdateTime)
+
+	var image FirebaseDatasetImage
+	if err := doc.DataTo(&image); err != nil {
+		return nil, fmt.Errorf("failed to parse dataset image: %v", err)
+	}
+	image.ID = doc.Ref.ID
+	return &image, nil
+}
+
+// NewImageID reserves the ID of a dataset image, which names its file
+func (d *DatasetFirebase) NewImageID() string {
+	return d.client.Collection("dataset_images").NewDoc().ID
+}
+
+// CreateImage stores a dataset image under the ID reserved for it
+func (d *DatasetFirebase) CreateImage(ctx context.Context, image *FirebaseDatasetImage) error {
+	image.CreatedBy = ActorFromContext(ctx)
+	image.CreatedAt = time.Now()
+	image.UpdatedAt = image.CreatedAt
+
+	if err := createDocument(ctx, d.client, d.client.Collection("dataset_images").Doc(image.ID), image); err != nil {
+		log.Printf("Error creating dataset image: %v", err)
+		return err
+	}
+	return nil
+}
+
+// UpdateImage changes the curation fields of a dataset image
+func (d *DatasetFirebase) UpdateImage(ctx context.Context, id string, image *FirebaseDatasetImage) error {
+	err := updateDocument(ctx, d.client, d.client.Collection("dataset_images").Doc(id), []firestore.Update{
+		{Path: "labels", Value: image.Labels},
+		{Path: "quality_flags", Value: image.QualityFlags},
+		{Path: "split", Value: image.Split},
+		{Path: "excluded", Value: image.Excluded},
+		{Path: "updated_at", Value: time.Now()},
+	})
+	if err != nil {
+		log.Printf("Error updating dataset image: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ListSnapshots retrieves the dataset snapshots of the company in the context, newest first
+func (d *DatasetFirebase) ListSnapshots(ctx context.Context) ([]*FirebaseDatasetSnapshot, error) {
+	docs, err := scopedQuery(ctx, d.client.Collection("dataset_snapshots")).
+		OrderBy("version", firestore.Desc).
+		Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("Error getting dataset snapshots: %v", err)
+		return nil, err
+	}
+
+	snapshots := []*FirebaseDatasetSnapshot{}
+	for _, doc := range docs {
+		var snapshot FirebaseDatasetSnapshot
+		if err := doc.DataTo(&snapshot); err != nil {
+			log.Printf("Error converting dataset snapshot data: %v", err)
+			continue
+		}
+		snapshot.ID = doc.Ref.ID
+		snapshots = append(snapshots, &snapshot)
+	}
+	return snapshots, nil
+}
+
+// GetSnapshot retrieves a dataset snapshot by ID
+func (d *DatasetFirebase) GetSnapshot(ctx context.Context, id string) (*FirebaseDatasetSnapshot, error) {
+	doc, err := d.client.Collection("dataset_snapshots").Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
+
+	var snapshot FirebaseDatasetSnapshot
+	if err := doc.DataTo(&snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse dataset snapshot: %v", err)
+	}
+	snapshot.ID = doc.Ref.ID
+	return &snapshot, nil
+}
+
+// NewSnapshotID reserves the ID of the next snapshot
+func (d *DatasetFirebase) NewSnapshotID() string {
+	return d.client.Collection("dataset_snapshots").NewDoc().ID
+}
+
+// NextSnapshotVersion reserves the next snapshot version number of the
+// company in the context
+func (d *DatasetFirebase) NextSnapshotVersion(ctx context.Context) (int, error) {
+	companyID := CompanyFromContext(ctx)
+	if companyID == "" {
+		return 0, ErrCompanyMismatch
+	}
+
+	ref := d.client.Collection("counters").Doc("dataset_snapshots_" + companyID)
+	var version int
+	err := d.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		version = 1
+		if err == nil {
+			version = int(toFloat64(doc.Data()["next"]))
+		}
+		return tx.Set(ref, map[string]interface{}{"next": version + 1})
+	})
+	if err != nil {
+		log.Printf("Error reserving snapshot version: %v", err)
+		return 0, err
+	}
+	return version, nil
+}
+
+// CreateSnapshot stores a snapshot under the ID reserved for it
+func (d *DatasetFirebase) CreateSnapshot(ctx context.Context, snapshot *FirebaseDatasetSnapshot) error {
+	snapshot.CreatedBy = ActorFromContext(ctx)
+	if err := createDocument(ctx, d.client, d.client.Collection("dataset_snapshots").Doc(snapshot.ID), snapshot); err != nil {
+		log.Printf("Error creating dataset snapshot: %v", err)
+		return err
+	}
+	return nil
+}
@@ -31,6 +31,8 @@ type FirebaseDelivery struct {
 	CompanyID       string                   `json:"company_id"`
 	BranchID        string                   `json:"branch_id"`
 	DeliveryDetails []FirebaseDeliveryDetail `json:"delivery_details"`
+	// ShelveIDs are the shelves of the details, set on every write
+	ShelveIDs []string `json:"shelve_ids"`
 }
 
 // FirebaseDeliveryDetail represents a delivery detail in Firebase
@@ -43,6 +45,10 @@ type FirebaseDeliveryDetail struct {
 	Product   FirebaseProduct `json:"product"`
 }
 
+func (d *FirebaseDelivery) shelves() []string {
+	return distinctShelves(len(d.DeliveryDetails), func(i int) string { return d.DeliveryDetails[i].ShelveID })
+}
+
 // List retrieves all deliveries
 func (d *DeliveryFirebase) List(ctx context.Context) ([]FirebaseDelivery, error) {
 	var deliveries []FirebaseDelivery
@@ -63,11 +69,13 @@ func (d *DeliveryFirebase) Get(ctx context.Context, id string) (*FirebaseDeliver
 
 // Create creates a new delivery
 func (d *DeliveryFirebase) Create(ctx context.Context, delivery *FirebaseDelivery) (string, error) {
+	delivery.ShelveIDs = delivery.shelves()
 	return d.FirebaseModel.Create(ctx, delivery)
 }
 
 // Update updates an existing delivery
 func (d *DeliveryFirebase) Update(ctx context.Context, id string, delivery *FirebaseDelivery) error {
+	delivery.ShelveIDs = delivery.shelves()
 	return d.FirebaseModel.Update(ctx, id, delivery)
 }
 
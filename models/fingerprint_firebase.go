@@ -0,0 +1,128 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/nirshpaa/godam-backend/libraries/imagehash"
+)
+
+// FirebaseFingerprint is the perceptual fingerprint of a product reference image
+type FirebaseFingerprint struct {
+	ID          string    `json:"id"`
+	CompanyID   string    `json:"company_id"`
+	ProductCode string    `json:"product_code"`
+	ProductName string    `json:"product_name"`
+	ImageURL    string    `json:"image_url"`
+	Hash        string    `json:"hash"`
+	Histogram   []float64 `json:"histogram"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Fingerprint returns the stored hash and histogram
+func (f *FirebaseFingerprint) Fingerprint() (imagehash.Fingerprint, error) {
+	hash, err := imagehash.ParseHash(f.Hash)
+	if err != nil {
+		return imagehash.Fingerprint{}, fmt.Errorf("invalid fingerprint hash %q: %v", f.Hash, err)
+	}
+	return imagehash.Fingerprint{Hash: hash, Histogram: f.Histogram}, nil
+}
+
+// FingerprintFirebase stores the fingerprints the local visual matcher
+// compares scans against. They are derived from product images and bypass
+// versioning and the audit log.
+type FingerprintFirebase struct {
+	client *firestore.Client
+}
+
+// NewFingerprintFirebase creates a new FingerprintFirebase instance
+func NewFingerprintFirebase(client *firestore.Client) *FingerprintFirebase {
+	return &FingerprintFirebase{
+		client: client,
+	}
+}
+
+// List returns the fingerprints of the company in the context
+func (f *FingerprintFirebase) List(ctx context.Context) ([]FirebaseFingerprint, error) {
+	docs, err := scopedQuery(ctx, f.client.Collection("product_fingerprints")).Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("Error listing fingerprints: %v", err)
+		return nil, err
+	}
+
+	fingerprints := make([]FirebaseFingerprint, 0, len(docs))
+	for _, doc := range docs {
+		data := doc.Data()
+		fingerprint := FirebaseFingerprint{ID: doc.Ref.ID}
+		fingerprint.CompanyID, _ = data["company_id"].(string)
+		fingerprint.ProductCode, _ = data["product_code"].(string)
+		fingerprint.ProductName, _ = data["product_name"].(string)
+		fingerprint.ImageURL, _ = data["image_url"].(string)
+		fingerprint.Hash, _ = data["hash"].(string)
+		fingerprint.CreatedAt, _ = data["created_at"].(time.Time)
+		if histogram, ok := data["histogram"].([]interface{}); ok {
+			for _, v := range histogram {
+				fingerprint.Histogram = append(fingerprint.Histogram, toFloat64(v))
+			}
+		}
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	return fingerprints, nil
+}
+
+// Replace swaps the fingerprints of a product for the given ones
+func (f *FingerprintFirebase) Replace(ctx context.Context, productCode string, fingerprints []FirebaseFingerprint) error {
+	companyID := CompanyFromContext(ctx)
+	if companyID == "" {
+		return ErrCompanyMismatch
+	}
+
+	docs, err := scopedQuery(ctx, f.client.Collection("product_fingerprints")).
+		Where("product_code", "==", productCode).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return fmt.Errorf("failed to query fingerprints: %v", err)
+	}
+	for _, doc := range docs {
+		if _, err := doc.Ref.Delete(ctx); err != nil {
+			return fmt.Errorf("failed to delete fingerprint: %v", err)
+		}
+	}
+
+	for _, fingerprint := range fingerprints {
+		_, _, err := f.client.Collection("product_fingerprints").Add(ctx, map[string]interface{}{
+			"company_id":   companyID,
+			"product_code": productCode,
+			"product_name": fingerprint.ProductName,
+			"image_url":    fingerprint.ImageURL,
+			"hash":         fingerprint.Hash,
+			"histogram":    fingerprint.Histogram,
+			"created_at":   time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add fingerprint: %v", err)
+		}
+	}
+	return nil
+}
+
+// DeleteAll removes every fingerprint of the company in the context
+func (f *FingerprintFirebase) DeleteAll(ctx context.Context) error {
+	if CompanyFromContext(ctx) == "" {
+		return ErrCompanyMismatch
+	}
+
+	docs, err := scopedQuery(ctx, f.client.Collection("product_fingerprints")).Documents(ctx).GetAll()
+	if err != nil {
+		return fmt.Errorf("failed to query fingerprints: %v", err)
+	}
+	for _, doc := range docs {
+		if _, err := doc.Ref.Delete(ctx); err != nil {
+			return fmt.Errorf("failed to delete fingerprint: %v", err)
+		}
+	}
+	return nil
+}
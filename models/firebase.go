@@ -29,6 +29,12 @@ func NewFirebaseModel(path string, client *firestore.Client) *FirebaseModel {
 
 // Create creates a new record in Firestore
 func (m *FirebaseModel) Create(ctx context.Context, data interface{}) (string, error) {
+	return m.createWith(ctx, m.ref.NewDoc(), data, nil)
+}
+
+// createWith creates a record under ref in one transaction with the writes of
+// also; see createDocumentWith
+func (m *FirebaseModel) createWith(ctx context.Context, docRef *firestore.DocumentRef, data interface{}, also func(tx *firestore.Transaction) error) (string, error) {
 	// Convert data to map if it's not already
 	var dataMap map[string]interface{}
 	switch v := data.(type) {
@@ -50,10 +56,8 @@ func (m *FirebaseModel) Create(ctx context.Context, data interface{}) (string, e
 	dataMap["created_at"] = now
 	dataMap["updated_at"] = now
 
-	// Create new document
-	docRef, _, err := m.ref.Add(ctx, dataMap)
-	if err != nil {
-		return "", fmt.Errorf("failed to create document: %v", err)
+	if err := createDocumentWith(ctx, m.client, docRef, dataMap, also); err != nil {
+		return "", fmt.Errorf("failed to create document: %w", err)
 	}
 
 	return docRef.ID, nil
@@ -65,12 +69,16 @@ func (m *FirebaseModel) Get(ctx context.Context, id string, result interface{})
 	if err != nil {
 		return fmt.Errorf("failed to get record: %v", err)
 	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return err
+	}
 
 	// Get the data directly without the data/metadata wrapper
 	data := doc.Data()
 	if data == nil {
 		return fmt.Errorf("no data found for record: %s", id)
 	}
+	recordVersion(ctx, doc.UpdateTime)
 
 	// Convert the data to JSON and then to the result type
 	jsonData, err := json.Marshal(data)
@@ -83,31 +91,64 @@ func (m *FirebaseModel) Get(ctx context.Context, id string, result interface{})
 
 // Update updates an existing record
 func (m *FirebaseModel) Update(ctx context.Context, id string, data interface{}) error {
+	updates, err := m.updates(ctx, data)
+	if err != nil {
+		return err
+	}
+
+	// Use Update instead of Set to prevent document duplication
+	return updateDocument(ctx, m.client, m.ref.Doc(id), updates)
+}
+
+// updateWith updates an existing record in one transaction with the writes
+// of also, such as reviewing its approval. also runs first, so it may read
+// and fill in fields of data.
+func (m *FirebaseModel) updateWith(ctx context.Context, id string, data interface{}, also func(tx *firestore.Transaction) error) error {
 	docRef := m.ref.Doc(id)
+	return writeDocument(ctx, m.client, docRef, AuditUpdate, func(tx *firestore.Transaction) error {
+		if err := also(tx); err != nil {
+			return err
+		}
+		updates, err := m.updates(ctx, data)
+		if err != nil {
+			return err
+		}
+		if err := checkCompanyUpdates(ctx, m.ref.ID, updates); err != nil {
+			return err
+		}
+		if err := checkBranchUpdates(ctx, m.ref.ID, updates); err != nil {
+			return err
+		}
+		return tx.Update(docRef, updates)
+	})
+}
 
+// updates converts data to the field updates of a record
+func (m *FirebaseModel) updates(ctx context.Context, data interface{}) ([]firestore.Update, error) {
 	// Convert data to map
 	var dataMap map[string]interface{}
-
-	// Try to convert struct to map
-	if product, ok := data.(*FirebaseProduct); ok {
-		// Convert struct to map
-		jsonData, err := json.Marshal(product)
+	if mapData, ok := data.(map[string]interface{}); ok {
+		dataMap = mapData
+	} else {
+		// Convert struct to map through JSON so the stored keys match the json tags
+		jsonData, err := json.Marshal(data)
 		if err != nil {
-			return fmt.Errorf("failed to marshal product: %v", err)
+			return nil, fmt.Errorf("failed to marshal data: %v", err)
 		}
 
 		if err := json.Unmarshal(jsonData, &dataMap); err != nil {
-			return fmt.Errorf("failed to unmarshal product: %v", err)
+			return nil, fmt.Errorf("failed to unmarshal data: %v", err)
 		}
-	} else if mapData, ok := data.(map[string]interface{}); ok {
-		dataMap = mapData
-	} else {
-		return fmt.Errorf("data must be either a FirebaseProduct struct or a map")
 	}
 
 	// Add updated timestamp in RFC3339 format
 	dataMap["updated_at"] = time.Now().Format(time.RFC3339)
 
+	// Payloads usually leave the company out, which would clear it
+	if err := stampCompany(ctx, m.ref.ID, dataMap); err != nil {
+		return nil, err
+	}
+
 	// Convert map to updates
 	updates := make([]firestore.Update, 0, len(dataMap))
 	for k, v := range dataMap {
@@ -116,22 +157,17 @@ func (m *FirebaseModel) Update(ctx context.Context, id string, data interface{})
 			Value: v,
 		})
 	}
-
-	// Use Update instead of Set to prevent document duplication
-	_, err := docRef.Update(ctx, updates)
-	return err
+	return updates, nil
 }
 
 // Delete removes a record
 func (m *FirebaseModel) Delete(ctx context.Context, id string) error {
-	docRef := m.ref.Doc(id)
-	_, err := docRef.Delete(ctx)
-	return err
+	return trashDocument(ctx, m.client, m.ref.Doc(id))
 }
 
 // List retrieves all records
 func (m *FirebaseModel) List(ctx context.Context, result interface{}) error {
-	docs, err := m.ref.Documents(ctx).GetAll()
+	docs, err := scopedDocuments(ctx, m.ref.ID, m.ref.Query)
 	if err != nil {
 		return fmt.Errorf("failed to list records: %v", err)
 	}
@@ -142,6 +178,10 @@ func (m *FirebaseModel) List(ctx context.Context, result interface{}) error {
 	results := make([]map[string]interface{}, 0, len(docs))
 
 	for _, doc := range docs {
+		if IsTrashed(doc) {
+			continue
+		}
+
 		log.Printf("Processing document %s", doc.Ref.ID)
 
 		// Get raw data from document
@@ -229,13 +269,17 @@ func (m *FirebaseModel) List(ctx context.Context, result interface{}) error {
 
 // Query retrieves records based on a query
 func (m *FirebaseModel) Query(ctx context.Context, query *firestore.Query, result interface{}) error {
-	docs, err := query.Documents(ctx).GetAll()
+	docs, err := scopedDocuments(ctx, m.ref.ID, *query)
 	if err != nil {
 		return fmt.Errorf("failed to query records: %v", err)
 	}
 
 	var results []map[string]interface{}
 	for _, doc := range docs {
+		if IsTrashed(doc) {
+			continue
+		}
+
 		data := doc.Data()
 		if data == nil {
 			continue
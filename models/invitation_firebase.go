@@ -0,0 +1,247 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Invitation statuses
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+	InvitationRevoked  = "revoked"
+)
+
+var (
+	// ErrInvitationInvalid is returned for unknown tokens and invitations that were already answered or revoked
+	ErrInvitationInvalid = errors.New("invitation is invalid or has already been used")
+	// ErrInvitationExpired is returned for invitations past their expiry
+	ErrInvitationExpired = errors.New("invitation has expired")
+	// ErrInvitationEmail is returned when someone other than the invitee answers an invitation
+	ErrInvitationEmail = errors.New("invitation was sent to a different email address")
+)
+
+// FirebaseInvitation invites someone by email to join a company with a role.
+// Only a hash of the invitation token is stored.
+type FirebaseInvitation struct {
+	ID          string    `firestore:"-" json:"id"`
+	CompanyID   string    `firestore:"company_id" json:"company_id"`
+	Email       string    `firestore:"email" json:"email"`
+	Role        string    `firestore:"role" json:"role"`
+	Branches    []string  `firestore:"branches" json:"branches"`
+	Status      string    `firestore:"status" json:"status"`
+	TokenHash   string    `firestore:"token_hash" json:"-"`
+	InvitedBy   string    `firestore:"invited_by" json:"invited_by"`
+	ExpiresAt   time.Time `firestore:"expires_at" json:"expires_at"`
+	CreatedAt   time.Time `firestore:"created_at" json:"created_at"`
+	RespondedBy string    `firestore:"responded_by" json:"responded_by,omitempty"`
+}
+
+// InvitationFirebase represents the Firestore client for invitations
+type InvitationFirebase struct {
+	client *firestore.Client
+}
+
+// NewInvitationFirebase creates a new InvitationFirebase instance
+func NewInvitationFirebase(client *firestore.Client) *InvitationFirebase {
+	return &InvitationFirebase{
+		client: client,
+	}
+}
+
+// ListPending retrieves the open invitations of the company in the context
+func (m *InvitationFirebase) ListPending(ctx context.Context) ([]*FirebaseInvitation, error) {
+	docs, err := scopedQuery(ctx, m.client.Collection("invitations")).Where("status", "==", InvitationPending).Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("Error getting invitations: %v", err)
+		return nil, err
+	}
+
+	invitations := []*FirebaseInvitation{}
+	for _, doc := range docs {
+		var invitation FirebaseInvitation
+		if err := doc.DataTo(&invitation); err != nil {
+			log.Printf("Error converting invitation data: %v", err)
+			continue
+		}
+		if time.Now().After(invitation.ExpiresAt) {
+			continue
+		}
+		invitation.ID = doc.Ref.ID
+		invitations = append(invitations, &invitation)
+	}
+
+	return invitations, nil
+}
+
+// Create stores a new invitation for the company in the context and returns
+// the token to send to the invitee
+func (m *InvitationFirebase) Create(ctx context.Context, invitation *FirebaseInvitation, ttl time.Duration) (string, error) {
+	if err := checkGrantedBranches(ctx, m.client, invitation.Branches); err != nil {
+		return "", err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	invitation.Email = strings.ToLower(strings.TrimSpace(invitation.Email))
+	invitation.Status = InvitationPending
+	invitation.TokenHash = hashInvitationToken(token)
+	invitation.InvitedBy = ActorFromContext(ctx)
+	invitation.CreatedAt = time.Now()
+	invitation.ExpiresAt = invitation.CreatedAt.Add(ttl)
+
+	ref := m.client.Collection("invitations").NewDoc()
+	if err := createDocument(ctx, m.client, ref, invitation); err != nil {
+		log.Printf("Error creating invitation: %v", err)
+		return "", err
+	}
+	invitation.ID = ref.ID
+
+	return token, nil
+}
+
+// Revoke withdraws a pending invitation of the company in the context
+func (m *InvitationFirebase) Revoke(ctx context.Context, id string) error {
+	ref := m.client.Collection("invitations").Doc(id)
+	return m.respond(ctx, ref, InvitationRevoked, func(tx *firestore.Transaction, invitation *FirebaseInvitation) error {
+		return nil
+	})
+}
+
+// Accept answers an invitation for the invitee and activates their
+// membership in the company with the invited role. It returns
+// ErrMembershipExists when the invitee is already an active or suspended
+// member, leaving the invitation pending.
+func (m *InvitationFirebase) Accept(ctx context.Context, token, userID, email string) (*FirebaseMembership, error) {
+	ref, err := m.findByToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	var membership *FirebaseMembership
+	var membershipRef *firestore.DocumentRef
+	var before map[string]interface{}
+	err = m.respond(ctx, ref, InvitationAccepted, func(tx *firestore.Transaction, invitation *FirebaseInvitation) error {
+		if !strings.EqualFold(invitation.Email, email) {
+			return ErrInvitationEmail
+		}
+
+		membership = &FirebaseMembership{
+			ID:        MembershipID(invitation.CompanyID, userID),
+			UserID:    userID,
+			CompanyID: invitation.CompanyID,
+			Role:      invitation.Role,
+			Branches:  invitation.Branches,
+			Status:    MembershipActive,
+			CreatedAt: time.Now(),
+		}
+		membershipRef = m.client.Collection("memberships").Doc(membership.ID)
+
+		// Members keep their role, and suspended members stay suspended; a
+		// pending request to join is answered by the invitation
+		existing, err := tx.Get(membershipRef)
+		if status.Code(err) == codes.NotFound {
+			before = nil
+			return tx.Create(membershipRef, membership)
+		}
+		if err != nil {
+			return err
+		}
+		if current, _ := existing.Data()["status"].(string); current != MembershipPending {
+			return ErrMembershipExists
+		}
+		before = existing.Data()
+		if created, ok := before["created_at"].(time.Time); ok {
+			membership.CreatedAt = created
+		}
+		return tx.Set(membershipRef, membership)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if before == nil {
+		auditCreated(ctx, m.client, membershipRef)
+	} else {
+		auditUpdated(ctx, m.client, membershipRef, before)
+	}
+	return membership, nil
+}
+
+// Decline answers an invitation for the invitee without joining the company
+func (m *InvitationFirebase) Decline(ctx context.Context, token, email string) error {
+	ref, err := m.findByToken(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	return m.respond(ctx, ref, InvitationDeclined, func(tx *firestore.Transaction, invitation *FirebaseInvitation) error {
+		if !strings.EqualFold(invitation.Email, email) {
+			return ErrInvitationEmail
+		}
+		return nil
+	})
+}
+
+// respond moves a pending, unexpired invitation to status, running extra in
+// the same transaction so each invitation can only be answered once
+func (m *InvitationFirebase) respond(ctx context.Context, ref *firestore.DocumentRef, status string, extra func(tx *firestore.Transaction, invitation *FirebaseInvitation) error) error {
+	return writeDocument(ctx, m.client, ref, AuditUpdate, func(tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+
+		var invitation FirebaseInvitation
+		if err := doc.DataTo(&invitation); err != nil {
+			return err
+		}
+		if invitation.Status != InvitationPending {
+			return ErrInvitationInvalid
+		}
+		if time.Now().After(invitation.ExpiresAt) {
+			return ErrInvitationExpired
+		}
+
+		if err := extra(tx, &invitation); err != nil {
+			return err
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "status", Value: status},
+			{Path: "responded_by", Value: ActorFromContext(ctx)},
+		})
+	})
+}
+
+func (m *InvitationFirebase) findByToken(ctx context.Context, token string) (*firestore.DocumentRef, error) {
+	doc, err := m.client.Collection("invitations").Where("token_hash", "==", hashInvitationToken(token)).Limit(1).Documents(ctx).Next()
+	if err == iterator.Done {
+		return nil, ErrInvitationInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.Ref, nil
+}
+
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
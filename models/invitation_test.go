@@ -0,0 +1,196 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+)
+
+// invite creates an invitation to branch-1 of company-a and returns its token
+func invite(t *testing.T, invitations *InvitationFirebase, email string, ttl time.Duration) (*FirebaseInvitation, string) {
+	t.Helper()
+	ctx := WithActor(WithCompany(context.Background(), "company-a"), "owner-1")
+	if _, err := invitations.client.Collection("branches").Doc("branch-1").Set(ctx, &BranchFirebaseModel{Name: "Main", CompanyID: "company-a"}); err != nil {
+		t.Fatalf("seeding branch: %v", err)
+	}
+	invitation := &FirebaseInvitation{Email: email, Role: RoleClerk, Branches: []string{"branch-1"}}
+	token, err := invitations.Create(ctx, invitation, ttl)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return invitation, token
+}
+
+func TestInvitationAccept(t *testing.T) {
+	client := firestoretest.New(t)
+	invitations := NewInvitationFirebase(client)
+	memberships := NewMembershipFirebase(client)
+	ctx := context.Background()
+	if _, err := client.Collection("companies").Doc("company-a").Set(ctx, map[string]interface{}{"name": "A"}); err != nil {
+		t.Fatalf("seeding company: %v", err)
+	}
+
+	_, token := invite(t, invitations, " New@Example.com ", time.Hour)
+	invitee := WithActor(ctx, "user-1")
+
+	if _, err := invitations.Accept(invitee, token, "user-1", "other@example.com"); !errors.Is(err, ErrInvitationEmail) {
+		t.Errorf("Accept by another email = %v, want ErrInvitationEmail", err)
+	}
+	if _, err := invitations.Accept(invitee, "not-a-token", "user-1", "new@example.com"); !errors.Is(err, ErrInvitationInvalid) {
+		t.Errorf("Accept with an unknown token = %v, want ErrInvitationInvalid", err)
+	}
+
+	membership, err := invitations.Accept(invitee, token, "user-1", "NEW@example.com")
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	if membership.Role != RoleClerk || membership.Status != MembershipActive {
+		t.Errorf("accepted membership = %+v", membership)
+	}
+	active, err := memberships.Active(ctx, "company-a", "user-1")
+	if err != nil || active == nil || active.Role != RoleClerk || len(active.Branches) != 1 {
+		t.Errorf("Active after accepting = %+v, %v", active, err)
+	}
+
+	if _, err := invitations.Accept(invitee, token, "user-1", "new@example.com"); !errors.Is(err, ErrInvitationInvalid) {
+		t.Errorf("second Accept = %v, want ErrInvitationInvalid", err)
+	}
+}
+
+func TestInvitationAcceptExistingMember(t *testing.T) {
+	client := firestoretest.New(t)
+	invitations := NewInvitationFirebase(client)
+	memberships := NewMembershipFirebase(client)
+	ctx := context.Background()
+	scoped := WithCompany(ctx, "company-a")
+	if _, err := client.Collection("companies").Doc("company-a").Set(ctx, map[string]interface{}{"name": "A"}); err != nil {
+		t.Fatalf("seeding company: %v", err)
+	}
+
+	if _, err := memberships.Add(scoped, "company-a", "owner-2", RoleOwner); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	_, token := invite(t, invitations, "owner@example.com", time.Hour)
+	if _, err := invitations.Accept(ctx, token, "owner-2", "owner@example.com"); !errors.Is(err, ErrMembershipExists) {
+		t.Errorf("Accept by an active member = %v, want ErrMembershipExists", err)
+	}
+	if active, err := memberships.Active(ctx, "company-a", "owner-2"); err != nil || active == nil || active.Role != RoleOwner {
+		t.Errorf("Active after accepting = %+v, %v, want the owner kept", active, err)
+	}
+
+	suspended, err := memberships.Add(scoped, "company-a", "user-2", RoleClerk)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	suspended.Status = MembershipSuspended
+	if err := memberships.Update(scoped, suspended.ID, suspended); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	_, token = invite(t, invitations, "suspended@example.com", time.Hour)
+	if _, err := invitations.Accept(ctx, token, "user-2", "suspended@example.com"); !errors.Is(err, ErrMembershipExists) {
+		t.Errorf("Accept by a suspended member = %v, want ErrMembershipExists", err)
+	}
+	if active, err := memberships.Active(ctx, "company-a", "user-2"); err != nil || active != nil {
+		t.Errorf("Active after accepting = %+v, %v, want the member still suspended", active, err)
+	}
+
+	if _, err := memberships.Request(ctx, "company-a", "user-3"); err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	_, token = invite(t, invitations, "pending@example.com", time.Hour)
+	if _, err := invitations.Accept(ctx, token, "user-3", "pending@example.com"); err != nil {
+		t.Fatalf("Accept with a pending request: %v", err)
+	}
+	if active, err := memberships.Active(ctx, "company-a", "user-3"); err != nil || active == nil || active.Role != RoleClerk {
+		t.Errorf("Active after accepting = %+v, %v, want the invited role", active, err)
+	}
+}
+
+func TestInvitationBranches(t *testing.T) {
+	client := firestoretest.New(t)
+	invitations := NewInvitationFirebase(client)
+	memberships := NewMembershipFirebase(client)
+	ctx := WithActor(WithCompany(context.Background(), "company-a"), "owner-1")
+	for id, companyID := range map[string]string{"branch-1": "company-a", "branch-2": "company-a", "branch-b": "company-b"} {
+		if _, err := client.Collection("branches").Doc(id).Set(ctx, &BranchFirebaseModel{Name: id, CompanyID: companyID}); err != nil {
+			t.Fatalf("seeding branch: %v", err)
+		}
+	}
+	limited := WithBranches(ctx, []string{"branch-1"})
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		branches []string
+		want     error
+	}{
+		{"unrestricted caller granting every branch", ctx, nil, nil},
+		{"unrestricted caller granting a branch", ctx, []string{"branch-2"}, nil},
+		{"limited caller granting its branch", limited, []string{"branch-1"}, nil},
+		{"limited caller granting every branch", limited, nil, ErrBranchForbidden},
+		{"limited caller granting another branch", limited, []string{"branch-1", "branch-2"}, ErrBranchForbidden},
+		{"branch of another company", ctx, []string{"branch-b"}, ErrUnknownBranch},
+		{"missing branch", ctx, []string{"branch-9"}, ErrUnknownBranch},
+	}
+	for _, tt := range tests {
+		_, err := invitations.Create(tt.ctx, &FirebaseInvitation{Email: "new@example.com", Role: RoleClerk, Branches: tt.branches}, time.Hour)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: invitation Create = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	membership, err := memberships.Add(ctx, "company-a", "user-1", RoleClerk)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	for _, tt := range tests {
+		membership.Branches = tt.branches
+		err := memberships.Update(tt.ctx, membership.ID, membership)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: membership Update = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestInvitationDeclineRevokeExpire(t *testing.T) {
+	client := firestoretest.New(t)
+	invitations := NewInvitationFirebase(client)
+	ctx := context.Background()
+	scoped := WithCompany(ctx, "company-a")
+
+	_, declined := invite(t, invitations, "declined@example.com", time.Hour)
+	if err := invitations.Decline(ctx, declined, "declined@example.com"); err != nil {
+		t.Fatalf("Decline: %v", err)
+	}
+	if _, err := invitations.Accept(ctx, declined, "user-1", "declined@example.com"); !errors.Is(err, ErrInvitationInvalid) {
+		t.Errorf("Accept after declining = %v, want ErrInvitationInvalid", err)
+	}
+
+	revoked, revokedToken := invite(t, invitations, "revoked@example.com", time.Hour)
+	if err := invitations.Revoke(WithCompany(ctx, "company-b"), revoked.ID); err == nil {
+		t.Error("another company revoked the invitation")
+	}
+	if err := invitations.Revoke(scoped, revoked.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, err := invitations.Accept(ctx, revokedToken, "user-2", "revoked@example.com"); !errors.Is(err, ErrInvitationInvalid) {
+		t.Errorf("Accept after revoking = %v, want ErrInvitationInvalid", err)
+	}
+
+	_, expired := invite(t, invitations, "late@example.com", -time.Minute)
+	if _, err := invitations.Accept(ctx, expired, "user-3", "late@example.com"); !errors.Is(err, ErrInvitationExpired) {
+		t.Errorf("Accept after expiry = %v, want ErrInvitationExpired", err)
+	}
+
+	open, _ := invite(t, invitations, "open@example.com", time.Hour)
+	pending, err := invitations.ListPending(scoped)
+	if err != nil {
+		t.Fatalf("ListPending: %v", err)
+	}
+	if len(pending) != 1 || pending[0].ID != open.ID {
+		t.Errorf("ListPending = %+v, want only the open invitation", pending)
+	}
+}
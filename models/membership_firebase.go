@@ -0,0 +1,276 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Membership statuses
+const (
+	MembershipPending   = "pending"
+	MembershipActive    = "active"
+	MembershipSuspended = "suspended"
+)
+
+// ErrMembershipExists is returned when a user already belongs to or has asked to join a company
+var ErrMembershipExists = errors.New("membership already exists")
+
+// FirebaseMembership links a user to a company with a role in it
+type FirebaseMembership struct {
+	ID        string    `firestore:"-" json:"id"`
+	UserID    string    `firestore:"user_id" json:"user_id"`
+	CompanyID string    `firestore:"company_id" json:"company_id"`
+	Role      string    `firestore:"role" json:"role"`
+	Branches  []string  `firestore:"branches" json:"branches"`
+	Status    string    `firestore:"status" json:"status"`
+	CreatedAt time.Time `firestore:"created_at" json:"created_at"`
+}
+
+// MembershipFirebase represents the Firestore client for memberships
+type MembershipFirebase struct {
+	client *firestore.Client
+}
+
+// NewMembershipFirebase creates a new MembershipFirebase instance
+func NewMembershipFirebase(client *firestore.Client) *MembershipFirebase {
+	return &MembershipFirebase{
+		client: client,
+	}
+}
+
+// MembershipID returns the document ID of a user's membership in a company
+func MembershipID(companyID, userID string) string {
+	return companyID + "_" + userID
+}
+
+// List retrieves the memberships of the company in the context
+func (m *MembershipFirebase) List(ctx context.Context) ([]*FirebaseMembership, error) {
+	docs, err := scopedQuery(ctx, m.client.Collection("memberships")).Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("Error getting memberships: %v", err)
+		return nil, err
+	}
+	return membershipsFromDocs(docs), nil
+}
+
+// ListForUser retrieves every membership of a user across companies
+func (m *MembershipFirebase) ListForUser(ctx context.Context, userID string) ([]*FirebaseMembership, error) {
+	docs, err := m.client.Collection("memberships").Where("user_id", "==", userID).Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("Error getting user memberships: %v", err)
+		return nil, err
+	}
+	return membershipsFromDocs(docs), nil
+}
+
+// Get retrieves a single membership by ID
+func (m *MembershipFirebase) Get(ctx context.Context, id string) (*FirebaseMembership, error) {
+	doc, err := m.client.Collection("memberships").Doc(id).Get(ctx)
+	if err != nil {
+		log.Printf("Error getting membership: %v", err)
+		return nil, err
+	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
+
+	var membership FirebaseMembership
+	if err := doc.DataTo(&membership); err != nil {
+		log.Printf("Error converting membership data: %v", err)
+		return nil, err
+	}
+	membership.ID = doc.Ref.ID
+	recordVersion(ctx, doc.UpdateTime)
+
+	return &membership, nil
+}
+
+// Active returns the active membership of a user in a company, or nil when
+// there is none
+func (m *MembershipFirebase) Active(ctx context.Context, companyID, userID string) (*FirebaseMembership, error) {
+	company, err := m.client.Collection("companies").Doc(companyID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if IsTrashed(company) {
+		return nil, nil
+	}
+
+	doc, err := m.client.Collection("memberships").Doc(MembershipID(companyID, userID)).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var membership FirebaseMembership
+	if err := doc.DataTo(&membership); err != nil {
+		return nil, err
+	}
+	membership.ID = doc.Ref.ID
+	if membership.Status != MembershipActive {
+		return nil, nil
+	}
+	return &membership, nil
+}
+
+// MigrateLegacy creates active memberships for users that joined a company
+// before memberships existed, through the company and role on their profile
+// or the company's users list. Existing memberships are left alone, so it is
+// safe to run again. It returns the number of memberships created.
+func (m *MembershipFirebase) MigrateLegacy(ctx context.Context) (int, error) {
+	legacy := map[string]*FirebaseMembership{}
+
+	users, err := m.client.Collection("users").Where("company_id", ">", "").Documents(ctx).GetAll()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	for _, user := range users {
+		companyID, _ := user.Data()["company_id"].(string)
+		role, _ := user.Data()["role"].(string)
+		legacy[MembershipID(companyID, user.Ref.ID)] = &FirebaseMembership{UserID: user.Ref.ID, CompanyID: companyID, Role: role}
+	}
+
+	companies, err := m.client.Collection("companies").Documents(ctx).GetAll()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list companies: %w", err)
+	}
+	for _, company := range companies {
+		if IsTrashed(company) {
+			continue
+		}
+		members, _ := company.Data()["users"].([]interface{})
+		for _, member := range members {
+			userID, ok := member.(string)
+			if !ok || userID == "" {
+				continue
+			}
+			id := MembershipID(company.Ref.ID, userID)
+			if _, ok := legacy[id]; !ok {
+				legacy[id] = &FirebaseMembership{UserID: userID, CompanyID: company.Ref.ID}
+			}
+		}
+	}
+
+	created := 0
+	for id, membership := range legacy {
+		membership.Status = MembershipActive
+		membership.CreatedAt = time.Now()
+		_, err := m.client.Collection("memberships").Doc(id).Create(ctx, membership)
+		if status.Code(err) == codes.AlreadyExists {
+			continue
+		}
+		if err != nil {
+			return created, fmt.Errorf("failed to create membership %s: %w", id, err)
+		}
+		created++
+	}
+	return created, nil
+}
+
+// Request creates a pending membership for a user asking to join a company
+func (m *MembershipFirebase) Request(ctx context.Context, companyID, userID string) (*FirebaseMembership, error) {
+	company, err := m.client.Collection("companies").Doc(companyID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if IsTrashed(company) {
+		return nil, ErrNotFound
+	}
+
+	membership := &FirebaseMembership{
+		UserID:    userID,
+		CompanyID: companyID,
+		Status:    MembershipPending,
+	}
+	if err := m.create(ctx, membership); err != nil {
+		log.Printf("Error requesting membership: %v", err)
+		return nil, err
+	}
+	return membership, nil
+}
+
+// Add creates an active membership with a role, e.g. for a company's creator
+func (m *MembershipFirebase) Add(ctx context.Context, companyID, userID, role string) (*FirebaseMembership, error) {
+	membership := &FirebaseMembership{
+		UserID:    userID,
+		CompanyID: companyID,
+		Role:      role,
+		Status:    MembershipActive,
+	}
+	if err := m.create(ctx, membership); err != nil {
+		log.Printf("Error adding membership: %v", err)
+		return nil, err
+	}
+	return membership, nil
+}
+
+func (m *MembershipFirebase) create(ctx context.Context, membership *FirebaseMembership) error {
+	membership.ID = MembershipID(membership.CompanyID, membership.UserID)
+	membership.CreatedAt = time.Now()
+
+	ref := m.client.Collection("memberships").Doc(membership.ID)
+	if _, err := ref.Get(ctx); err == nil {
+		return ErrMembershipExists
+	} else if status.Code(err) != codes.NotFound {
+		return err
+	}
+	return createDocument(ctx, m.client, ref, membership)
+}
+
+// Update changes the role, branches or status of a membership. Branches must
+// be ones the caller may use.
+func (m *MembershipFirebase) Update(ctx context.Context, id string, membership *FirebaseMembership) error {
+	if err := checkGrantedBranches(ctx, m.client, membership.Branches); err != nil {
+		return err
+	}
+
+	err := updateDocument(ctx, m.client, m.client.Collection("memberships").Doc(id), []firestore.Update{
+		{Path: "role", Value: membership.Role},
+		{Path: "branches", Value: membership.Branches},
+		{Path: "status", Value: membership.Status},
+	})
+	if err != nil {
+		log.Printf("Error updating membership: %v", err)
+		return err
+	}
+	return nil
+}
+
+// Delete removes a user from a company
+func (m *MembershipFirebase) Delete(ctx context.Context, id string) error {
+	err := deleteDocument(ctx, m.client, m.client.Collection("memberships").Doc(id))
+	if err != nil {
+		log.Printf("Error deleting membership: %v", err)
+		return err
+	}
+	return nil
+}
+
+func membershipsFromDocs(docs []*firestore.DocumentSnapshot) []*FirebaseMembership {
+	var memberships []*FirebaseMembership
+	for _, doc := range docs {
+		var membership FirebaseMembership
+		if err := doc.DataTo(&membership); err != nil {
+			log.Printf("Error converting membership data: %v", err)
+			continue
+		}
+		membership.ID = doc.Ref.ID
+		memberships = append(memberships, &membership)
+	}
+	return memberships
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+)
+
+func TestMembershipActive(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := context.Background()
+	memberships := NewMembershipFirebase(client)
+	if _, err := client.Collection("companies").Doc("company-a").Set(ctx, map[string]interface{}{"name": "A"}); err != nil {
+		t.Fatalf("seeding company: %v", err)
+	}
+
+	scoped := WithCompany(ctx, "company-a")
+	if _, err := memberships.Add(scoped, "company-a", "owner-1", RoleOwner); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if _, err := memberships.Request(scoped, "company-a", "pending-1"); err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+
+	membership, err := memberships.Active(ctx, "company-a", "owner-1")
+	if err != nil || membership == nil || membership.Role != RoleOwner {
+		t.Fatalf("Active(owner-1) = %+v, %v", membership, err)
+	}
+	for _, user := range []string{"pending-1", "stranger"} {
+		if membership, err := memberships.Active(ctx, "company-a", user); err != nil || membership != nil {
+			t.Errorf("Active(%s) = %+v, %v, want none", user, membership, err)
+		}
+	}
+	if membership, err := memberships.Active(ctx, "company-b", "owner-1"); err != nil || membership != nil {
+		t.Errorf("Active in a missing company = %+v, %v, want none", membership, err)
+	}
+}
+
+func TestMigrateLegacyMemberships(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := context.Background()
+	memberships := NewMembershipFirebase(client)
+
+	if _, err := client.Collection("companies").Doc("company-a").Set(ctx, map[string]interface{}{
+		"name":  "A",
+		"users": []interface{}{"listed-1", "profile-1"},
+	}); err != nil {
+		t.Fatalf("seeding company: %v", err)
+	}
+	if _, err := client.Collection("users").Doc("profile-1").Set(ctx, map[string]interface{}{
+		"company_id": "company-a",
+		"role":       RoleManager,
+	}); err != nil {
+		t.Fatalf("seeding user: %v", err)
+	}
+	if _, err := client.Collection("users").Doc("unaffiliated").Set(ctx, map[string]interface{}{"name": "U"}); err != nil {
+		t.Fatalf("seeding user: %v", err)
+	}
+
+	// Legacy users are no longer members until they are migrated
+	if membership, err := memberships.Active(ctx, "company-a", "profile-1"); err != nil || membership != nil {
+		t.Fatalf("Active before migrating = %+v, %v, want none", membership, err)
+	}
+
+	created, err := memberships.MigrateLegacy(ctx)
+	if err != nil || created != 2 {
+		t.Fatalf("MigrateLegacy = %d, %v, want 2 created", created, err)
+	}
+
+	membership, err := memberships.Active(ctx, "company-a", "profile-1")
+	if err != nil || membership == nil || membership.Role != RoleManager {
+		t.Errorf("migrated profile member = %+v, %v, want a manager", membership, err)
+	}
+	membership, err = memberships.Active(ctx, "company-a", "listed-1")
+	if err != nil || membership == nil || membership.Role != "" {
+		t.Errorf("migrated listed member = %+v, %v, want an active member without a role", membership, err)
+	}
+	if membership, err := memberships.Active(ctx, "company-a", "unaffiliated"); err != nil || membership != nil {
+		t.Errorf("Active(unaffiliated) = %+v, %v, want none", membership, err)
+	}
+
+	if created, err := memberships.MigrateLegacy(ctx); err != nil || created != 0 {
+		t.Errorf("second MigrateLegacy = %d, %v, want nothing created", created, err)
+	}
+}
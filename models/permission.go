@@ -0,0 +1,280 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+)
+
+// Permission actions granted by access entries
+const (
+	ActionRead    = "read"
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionApprove = "approve"
+	ActionRestore = "restore"
+	// ActionManage is not granted by any default role but the owner's wildcard
+	ActionManage = "manage"
+)
+
+// PermissionWildcard matches any resource or action
+const PermissionWildcard = "*"
+
+// Default role names seeded for every company
+const (
+	RoleOwner    = "owner"
+	RoleManager  = "manager"
+	RoleClerk    = "clerk"
+	RoleSalesman = "salesman"
+)
+
+// ErrUnknownRole is returned for a role that is neither seeded in the company
+// nor one of the default roles
+var ErrUnknownRole = errors.New("unknown role")
+
+// Permission joins a resource and an action, e.g. purchases:approve
+func Permission(resource, action string) string {
+	return resource + ":" + action
+}
+
+// PermissionAllows reports whether a granted permission covers a required one.
+// Either half of the granted permission may be a wildcard.
+func PermissionAllows(granted, required string) bool {
+	grantedResource, grantedAction := splitPermission(granted)
+	resource, action := splitPermission(required)
+	return (grantedResource == PermissionWildcard || grantedResource == resource) &&
+		(grantedAction == PermissionWildcard || grantedAction == action)
+}
+
+// HasPermission reports whether any granted permission covers the required one
+func HasPermission(granted []string, required string) bool {
+	for _, permission := range granted {
+		if PermissionAllows(permission, required) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitPermission(permission string) (string, string) {
+	parts := strings.SplitN(permission, ":", 2)
+	if len(parts) != 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// inventoryResources are managed day to day by managers
+var inventoryResources = []string{
+	"branches", "brands", "customers", "deliveries", "delivery_returns", "product_categories",
+	"products", "purchases", "purchase_returns", "receives", "receive_returns", "regions",
+	"sales_orders", "sales_order_returns", "salesmen", "shelves", "stock_adjustments", "suppliers",
+}
+
+// DefaultRoles lists the permissions of the roles seeded for every company
+var DefaultRoles = map[string][]string{
+	RoleOwner: {Permission(PermissionWildcard, PermissionWildcard)},
+	RoleManager: append(resourcePermissions(inventoryResources, PermissionWildcard),
+		Permission(PermissionWildcard, ActionRead),
+		Permission("trash", ActionRestore),
+	),
+	RoleClerk: append(resourcePermissions([]string{
+		"brands", "deliveries", "delivery_returns", "product_categories", "products",
+		"purchases", "purchase_returns", "receives", "receive_returns", "shelves", "stock_adjustments", "suppliers",
+	}, ActionRead, ActionCreate, ActionUpdate),
+		Permission("branches", ActionRead),
+		Permission("customers", ActionRead),
+		Permission("regions", ActionRead),
+	),
+	RoleSalesman: append(resourcePermissions([]string{"customers", "sales_orders"}, ActionRead, ActionCreate, ActionUpdate),
+		Permission("sales_order_returns", ActionRead),
+		Permission("sales_order_returns", ActionCreate),
+		Permission("brands", ActionRead),
+		Permission("product_categories", ActionRead),
+		Permission("products", ActionRead),
+		Permission("reports", ActionRead),
+	),
+}
+
+// DefaultHiddenFields lists the response fields each default role may not see,
+// keyed by resource. Fields under PermissionWildcard are hidden everywhere.
+var DefaultHiddenFields = map[string]map[string][]string{
+	RoleSalesman: {
+		PermissionWildcard: {"purchase_price", "profit_margin", "supplier_id", "supplier"},
+		"purchases":        {"price", "disc", "additional_disc"},
+	},
+}
+
+func resourcePermissions(resources []string, actions ...string) []string {
+	var permissions []string
+	for _, resource := range resources {
+		for _, action := range actions {
+			permissions = append(permissions, Permission(resource, action))
+		}
+	}
+	return permissions
+}
+
+// Grant is what a user's role allows in a company
+type Grant struct {
+	Role         string              `json:"role"`
+	Permissions  []string            `json:"permissions"`
+	HiddenFields map[string][]string `json:"hidden_fields"`
+}
+
+// Allows reports whether the grant covers a permission
+func (g *Grant) Allows(permission string) bool {
+	return HasPermission(g.Permissions, permission)
+}
+
+// Hidden returns the response fields hidden from the grant for a resource
+func (g *Grant) Hidden(resource string) []string {
+	hidden := append([]string{}, g.HiddenFields[PermissionWildcard]...)
+	if resource != PermissionWildcard {
+		hidden = append(hidden, g.HiddenFields[resource]...)
+	}
+	return hidden
+}
+
+// Permissions resolves what a user may do in a company
+type Permissions struct {
+	client *firestore.Client
+}
+
+// NewPermissions creates a new Permissions instance
+func NewPermissions(client *firestore.Client) *Permissions {
+	return &Permissions{
+		client: client,
+	}
+}
+
+// Resolve returns the grant of a role in the company in the context, with the
+// permissions given by the role's access entries and its hidden fields
+func (p *Permissions) Resolve(ctx context.Context, role string) (*Grant, error) {
+	if role == "" {
+		return &Grant{}, nil
+	}
+	grant, err := p.roleGrant(ctx, role)
+	if errors.Is(err, ErrUnknownRole) {
+		return &Grant{Role: role}, nil
+	}
+	return grant, err
+}
+
+// Role returns the grant of a role that exists in the company in the context,
+// or ErrUnknownRole
+func (p *Permissions) Role(ctx context.Context, name string) (*Grant, error) {
+	if name == "" {
+		return nil, ErrUnknownRole
+	}
+	return p.roleGrant(ctx, name)
+}
+
+// roleGrant loads a named role in the company in the context.
+// Default roles that have not been seeded fall back to their built in settings;
+// any other role is unknown.
+func (p *Permissions) roleGrant(ctx context.Context, name string) (*Grant, error) {
+	docs, err := scopedQuery(ctx, p.client.Collection("roles")).Where("Name", "==", name).Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("Error getting role: %v", err)
+		return nil, err
+	}
+
+	var role *RoleFirebaseModel
+	for _, doc := range docs {
+		if IsTrashed(doc) {
+			continue
+		}
+		role = &RoleFirebaseModel{}
+		if err := doc.DataTo(role); err != nil {
+			log.Printf("Error converting role data: %v", err)
+			return nil, err
+		}
+		break
+	}
+	if role == nil {
+		permissions, ok := DefaultRoles[name]
+		if !ok {
+			return nil, ErrUnknownRole
+		}
+		return &Grant{Role: name, Permissions: permissions, HiddenFields: DefaultHiddenFields[name]}, nil
+	}
+
+	grant := &Grant{Role: name, HiddenFields: role.HiddenFields}
+
+	var refs []*firestore.DocumentRef
+	for _, id := range role.AccessIDs {
+		refs = append(refs, p.client.Collection("access").Doc(id))
+	}
+	if len(refs) == 0 {
+		return grant, nil
+	}
+
+	accessDocs, err := p.client.GetAll(ctx, refs)
+	if err != nil {
+		log.Printf("Error getting role access: %v", err)
+		return nil, err
+	}
+
+	for _, doc := range accessDocs {
+		if !doc.Exists() || checkReadable(ctx, doc) != nil {
+			continue
+		}
+		var access AccessFirebaseModel
+		if err := doc.DataTo(&access); err != nil {
+			log.Printf("Error converting access data: %v", err)
+			continue
+		}
+		if access.Resource == "" || access.Action == "" {
+			continue
+		}
+		grant.Permissions = append(grant.Permissions, Permission(access.Resource, access.Action))
+	}
+	sort.Strings(grant.Permissions)
+
+	return grant, nil
+}
+
+// SeedDefaultRoles creates the default roles and their access entries for a
+// company. Seeded records use stable IDs, so seeding again restores them.
+func SeedDefaultRoles(ctx context.Context, client *firestore.Client, companyID string) error {
+	names := make([]string, 0, len(DefaultRoles))
+	for name := range DefaultRoles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		seeded := map[string]bool{}
+		for _, name := range names {
+			role := RoleFirebaseModel{Name: name, CompanyID: companyID, HiddenFields: DefaultHiddenFields[name]}
+			for _, permission := range DefaultRoles[name] {
+				accessID := companyID + "_" + strings.ReplaceAll(permission, ":", "_")
+				role.AccessIDs = append(role.AccessIDs, accessID)
+				if seeded[accessID] {
+					continue
+				}
+				seeded[accessID] = true
+
+				resource, action := splitPermission(permission)
+				access := AccessFirebaseModel{Name: permission, Resource: resource, Action: action, CompanyID: companyID}
+				if err := tx.Set(client.Collection("access").Doc(accessID), access); err != nil {
+					return err
+				}
+			}
+			if err := tx.Set(client.Collection("roles").Doc(companyID+"_"+name), role); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error seeding default roles: %v", err)
+	}
+	return err
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+)
+
+func TestPermissionAllows(t *testing.T) {
+	tests := []struct {
+		granted, required string
+		want              bool
+	}{
+		{"products:read", "products:read", true},
+		{"products:read", "products:update", false},
+		{"products:*", "products:delete", true},
+		{"*:read", "purchases:read", true},
+		{"*:read", "purchases:approve", false},
+		{"*:*", "trash:restore", true},
+		{"products", "products:read", false},
+	}
+	for _, tt := range tests {
+		if got := PermissionAllows(tt.granted, tt.required); got != tt.want {
+			t.Errorf("PermissionAllows(%q, %q) = %v, want %v", tt.granted, tt.required, got, tt.want)
+		}
+	}
+}
+
+func TestResolveDefaultRoles(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := WithCompany(context.Background(), "company-a")
+	permissions := NewPermissions(client)
+
+	// Companies that were never seeded fall back to the built in roles
+	grant, err := permissions.Resolve(ctx, RoleSalesman)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if !grant.Allows("sales_orders:create") || grant.Allows("purchases:read") {
+		t.Errorf("unseeded salesman grant = %v", grant.Permissions)
+	}
+
+	if err := SeedDefaultRoles(context.Background(), client, "company-a"); err != nil {
+		t.Fatalf("SeedDefaultRoles: %v", err)
+	}
+	for role, want := range DefaultRoles {
+		grant, err := permissions.Resolve(ctx, role)
+		if err != nil {
+			t.Fatalf("Resolve(%s): %v", role, err)
+		}
+		want = append([]string{}, want...)
+		sort.Strings(want)
+		if !reflect.DeepEqual(grant.Permissions, want) {
+			t.Errorf("seeded %s permissions = %v, want %v", role, grant.Permissions, want)
+		}
+	}
+
+	if grant, err := permissions.Resolve(ctx, ""); err != nil || len(grant.Permissions) != 0 {
+		t.Errorf("Resolve without a role = %+v, %v, want no permissions", grant, err)
+	}
+}
+
+func TestResolveCustomRole(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := WithCompany(context.Background(), "company-a")
+	accessModel := NewAccessFirebase(client)
+	roles := NewRoleFirebase(client)
+
+	readID, err := accessModel.Create(ctx, &AccessFirebaseModel{Name: "read products", Resource: "products", Action: ActionRead})
+	if err != nil {
+		t.Fatalf("creating access: %v", err)
+	}
+	approveID, err := accessModel.Create(ctx, &AccessFirebaseModel{Name: "approve purchases", Resource: "purchases", Action: ActionApprove})
+	if err != nil {
+		t.Fatalf("creating access: %v", err)
+	}
+	// Access entries of another company never count
+	foreignID, err := accessModel.Create(WithCompany(context.Background(), "company-b"), &AccessFirebaseModel{Name: "all", Resource: "*", Action: "*"})
+	if err != nil {
+		t.Fatalf("creating access: %v", err)
+	}
+	if _, err := roles.Create(ctx, &RoleFirebaseModel{Name: "auditor", AccessIDs: []string{readID, approveID, foreignID}}); err != nil {
+		t.Fatalf("creating role: %v", err)
+	}
+	// Trashed access entries are ignored as well
+	if err := accessModel.Delete(ctx, approveID); err != nil {
+		t.Fatalf("trashing access: %v", err)
+	}
+
+	grant, err := NewPermissions(client).Resolve(ctx, "auditor")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if !reflect.DeepEqual(grant.Permissions, []string{"products:read"}) {
+		t.Errorf("auditor permissions = %v, want [products:read]", grant.Permissions)
+	}
+}
+
+func TestGrantHidden(t *testing.T) {
+	grant := &Grant{HiddenFields: DefaultHiddenFields[RoleSalesman]}
+
+	hidden := grant.Hidden("purchases")
+	for _, field := range []string{"purchase_price", "supplier_id", "price", "additional_disc"} {
+		if !containsString(hidden, field) {
+			t.Errorf("Hidden(purchases) = %v, missing %s", hidden, field)
+		}
+	}
+	if hidden := grant.Hidden("products"); containsString(hidden, "price") || !containsString(hidden, "purchase_price") {
+		t.Errorf("Hidden(products) = %v, want only the fields hidden everywhere", hidden)
+	}
+	if hidden := (&Grant{}).Hidden("products"); len(hidden) != 0 {
+		t.Errorf("Hidden without hidden fields = %v, want none", hidden)
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRoleUnknown(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := WithCompany(context.Background(), "company-a")
+	permissions := NewPermissions(client)
+
+	if _, err := permissions.Role(ctx, "auditor"); err != ErrUnknownRole {
+		t.Errorf("Role(auditor) error = %v, want ErrUnknownRole", err)
+	}
+	if _, err := permissions.Role(ctx, ""); err != ErrUnknownRole {
+		t.Errorf("Role(\"\") error = %v, want ErrUnknownRole", err)
+	}
+	if grant, err := permissions.Role(ctx, RoleClerk); err != nil || !grant.Allows("products:create") {
+		t.Errorf("Role(clerk) = %v, %v", grant, err)
+	}
+
+	// Members left with a role that no longer exists keep an empty grant
+	grant, err := permissions.Resolve(ctx, "auditor")
+	if err != nil || len(grant.Permissions) != 0 {
+		t.Errorf("Resolve(auditor) = %v, %v, want an empty grant", grant, err)
+	}
+}
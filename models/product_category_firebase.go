@@ -38,7 +38,7 @@ func (pc *ProductCategoryFirebase) List(ctx context.Context) ([]ProductCategoryF
 	var categories []ProductCategoryFirebaseModel
 
 	// Get all product categories from Firestore
-	iter := pc.client.Collection("product_categories").Documents(ctx)
+	iter := scopedQuery(ctx, pc.client.Collection("product_categories")).Documents(ctx)
 	docs, err := iter.GetAll()
 	if err != nil {
 		log.Printf("Error getting product categories: %v", err)
@@ -47,6 +47,10 @@ func (pc *ProductCategoryFirebase) List(ctx context.Context) ([]ProductCategoryF
 
 	// Convert Firestore documents to ProductCategoryFirebaseModel structs
 	for _, doc := range docs {
+		if IsTrashed(doc) {
+			continue
+		}
+
 		var category ProductCategoryFirebaseModel
 		if err := doc.DataTo(&category); err != nil {
 			log.Printf("Error converting product category document: %v", err)
@@ -66,6 +70,9 @@ func (pc *ProductCategoryFirebase) Get(ctx context.Context, id string) (*Product
 		log.Printf("Error getting product category: %v", err)
 		return nil, err
 	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
 
 	var category ProductCategoryFirebaseModel
 	if err := doc.DataTo(&category); err != nil {
@@ -73,6 +80,7 @@ func (pc *ProductCategoryFirebase) Get(ctx context.Context, id string) (*Product
 		return nil, err
 	}
 	category.ID = doc.Ref.ID
+	recordVersion(ctx, doc.UpdateTime)
 
 	return &category, nil
 }
@@ -82,7 +90,7 @@ func (pc *ProductCategoryFirebase) Create(ctx context.Context, category *Product
 	docRef := pc.client.Collection("product_categories").NewDoc()
 	category.ID = docRef.ID
 
-	_, err := docRef.Set(ctx, category)
+	err := createDocument(ctx, pc.client, docRef, category)
 	if err != nil {
 		log.Printf("Error creating product category: %v", err)
 		return err
@@ -93,7 +101,7 @@ func (pc *ProductCategoryFirebase) Create(ctx context.Context, category *Product
 
 // Update updates an existing product category
 func (pc *ProductCategoryFirebase) Update(ctx context.Context, category *ProductCategoryFirebaseModel) error {
-	_, err := pc.client.Collection("product_categories").Doc(category.ID).Set(ctx, category)
+	err := setDocument(ctx, pc.client, pc.client.Collection("product_categories").Doc(category.ID), category)
 	if err != nil {
 		log.Printf("Error updating product category: %v", err)
 		return err
@@ -104,7 +112,7 @@ func (pc *ProductCategoryFirebase) Update(ctx context.Context, category *Product
 
 // Delete deletes a product category
 func (pc *ProductCategoryFirebase) Delete(ctx context.Context, id string) error {
-	_, err := pc.client.Collection("product_categories").Doc(id).Delete(ctx)
+	err := trashDocument(ctx, pc.client, pc.client.Collection("product_categories").Doc(id))
 	if err != nil {
 		log.Printf("Error deleting product category: %v", err)
 		return err
@@ -9,6 +9,8 @@ import (
 
 	"cloud.google.com/go/firestore"
 	"github.com/nirshpaa/godam-backend/interfaces"
+	"github.com/nirshpaa/godam-backend/libraries/barcode"
+	"github.com/nirshpaa/godam-backend/libraries/imageproc"
 	"github.com/nirshpaa/godam-backend/types"
 )
 
@@ -39,6 +41,8 @@ type FirebaseProduct struct {
 	ProductCategoryID    string    `json:"product_category_id"`
 	CreatedAt            time.Time `json:"created_at"`
 	UpdatedAt            time.Time `json:"updated_at"`
+	// Images are the variants of ImageURL, derived from it and never stored
+	Images *imageproc.Paths `json:"images,omitempty"`
 }
 
 // UnmarshalJSON implements custom JSON unmarshaling for FirebaseProduct
@@ -66,7 +70,7 @@ func (p *FirebaseProduct) UnmarshalJSON(data []byte) error {
 			p.CreatedAt = time.Unix(int64(v), 0)
 		}
 		if err != nil {
-			return fmt.Errorf("failed to parse created_at: %v", err)
+			return fmt.Errorf("failed to parse created_at: %w", err)
 		}
 	}
 
@@ -78,7 +82,7 @@ func (p *FirebaseProduct) UnmarshalJSON(data []byte) error {
 			p.UpdatedAt = time.Unix(int64(v), 0)
 		}
 		if err != nil {
-			return fmt.Errorf("failed to parse updated_at: %v", err)
+			return fmt.Errorf("failed to parse updated_at: %w", err)
 		}
 	}
 
@@ -105,7 +109,7 @@ type RecognitionResult struct {
 // Get retrieves a product by code
 func (p *ProductFirebase) Get(ctx context.Context, code string) (*FirebaseProduct, error) {
 	// Query the products collection
-	iter := p.client.Collection("products").
+	iter := scopedQuery(ctx, p.client.Collection("products")).
 		Where("code", "==", code).
 		Limit(1).
 		Documents(ctx)
@@ -115,6 +119,11 @@ func (p *ProductFirebase) Get(ctx context.Context, code string) (*FirebaseProduc
 	if err != nil {
 		return nil, fmt.Errorf("no product found with code: %s", code)
 	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
+
+	recordVersion(ctx, doc.UpdateTime)
 
 	// Map the document to a FirebaseProduct
 	return mapFirebaseProduct(doc)
@@ -142,6 +151,8 @@ func mapFirebaseProduct(doc *firestore.DocumentSnapshot) (*FirebaseProduct, erro
 		ProductCategoryID:    data["product_category_id"].(string),
 	}
 
+	product.Images = imageproc.VariantPaths(product.ImageURL)
+
 	// Handle timestamps
 	if createdAt, ok := data["created_at"].(time.Time); ok {
 		product.CreatedAt = createdAt
@@ -157,13 +168,21 @@ func mapFirebaseProduct(doc *firestore.DocumentSnapshot) (*FirebaseProduct, erro
 func (p *ProductFirebase) List(ctx context.Context) ([]FirebaseProduct, error) {
 	var products []FirebaseProduct
 	err := p.FirebaseModel.List(ctx, &products)
+	withImages(products)
 	return products, err
 }
 
+// withImages derives the image variants of listed products
+func withImages(products []FirebaseProduct) {
+	for i := range products {
+		products[i].Images = imageproc.VariantPaths(products[i].ImageURL)
+	}
+}
+
 // Create creates a new product
 func (p *ProductFirebase) Create(ctx context.Context, product *FirebaseProduct, fileStorage interfaces.FileStorage) (string, error) {
 	// Check for duplicate code
-	iter := p.client.Collection("products").
+	iter := scopedQuery(ctx, p.client.Collection("products")).
 		Where("code", "==", product.Code).
 		Limit(1).
 		Documents(ctx)
@@ -173,13 +192,14 @@ func (p *ProductFirebase) Create(ctx context.Context, product *FirebaseProduct,
 		return "", fmt.Errorf("product with code %s already exists", product.Code)
 	}
 
+	product.Images = nil
 	return p.FirebaseModel.Create(ctx, product)
 }
 
 // Update updates an existing product
 func (p *ProductFirebase) Update(ctx context.Context, code string, product *FirebaseProduct, fileStorage interfaces.FileStorage) error {
 	// Query the products collection
-	iter := p.client.Collection("products").
+	iter := scopedQuery(ctx, p.client.Collection("products")).
 		Where("code", "==", code).
 		Limit(1).
 		Documents(ctx)
@@ -191,13 +211,14 @@ func (p *ProductFirebase) Update(ctx context.Context, code string, product *Fire
 	}
 
 	// Update the product using the document ID
+	product.Images = nil
 	return p.FirebaseModel.Update(ctx, doc.Ref.ID, product)
 }
 
 // Delete deletes a product by code
 func (p *ProductFirebase) Delete(ctx context.Context, code string) error {
 	// Query the products collection
-	iter := p.client.Collection("products").
+	iter := scopedQuery(ctx, p.client.Collection("products")).
 		Where("code", "==", code).
 		Limit(1).
 		Documents(ctx)
@@ -209,9 +230,9 @@ func (p *ProductFirebase) Delete(ctx context.Context, code string) error {
 	}
 
 	// Delete the document
-	_, err = doc.Ref.Delete(ctx)
+	err = trashDocument(ctx, p.client, doc.Ref)
 	if err != nil {
-		return fmt.Errorf("failed to delete product: %v", err)
+		return fmt.Errorf("failed to delete product: %w", err)
 	}
 
 	return nil
@@ -222,7 +243,7 @@ func (p *ProductFirebase) UpdateImage(ctx context.Context, code string, imageURL
 	// First get the product to ensure it exists
 	product, err := p.Get(ctx, code)
 	if err != nil {
-		return fmt.Errorf("failed to get product with code %s: %v", code, err)
+		return fmt.Errorf("failed to get product with code %s: %w", code, err)
 	}
 
 	// Update the image-related fields
@@ -231,66 +252,109 @@ func (p *ProductFirebase) UpdateImage(ctx context.Context, code string, imageURL
 	product.ImageRecognitionData = recognitionData
 
 	// Get the document reference
-	docRef := p.client.Collection("products").Where("code", "==", code).Limit(1)
+	docRef := scopedQuery(ctx, p.client.Collection("products")).Where("code", "==", code).Limit(1)
 	docs, err := docRef.Documents(ctx).GetAll()
 	if err != nil {
-		return fmt.Errorf("failed to get document reference: %v", err)
+		return fmt.Errorf("failed to get document reference: %w", err)
 	}
 	if len(docs) == 0 {
 		return fmt.Errorf("no document found for product with code: %s", code)
 	}
 
 	// Update the document
-	_, err = docs[0].Ref.Update(ctx, []firestore.Update{
+	err = updateDocument(ctx, p.client, docs[0].Ref, []firestore.Update{
 		{Path: "image_url", Value: imageURL},
 		{Path: "barcode_value", Value: barcodeValue},
 		{Path: "image_recognition_data", Value: recognitionData},
 		{Path: "updated_at", Value: time.Now()},
 	})
 	if err != nil {
-		return fmt.Errorf("failed to update product image: %v", err)
+		return fmt.Errorf("failed to update product image: %w", err)
 	}
 
 	return nil
 }
 
-// FindByBarcode finds a product by its barcode
-func (p *ProductFirebase) FindByBarcode(ctx context.Context, barcode string) (*FirebaseProduct, error) {
-	query := p.ref.Where("data.barcode_value", "==", barcode).Limit(1)
+// FindByBarcode finds a product by its barcode. UPC-A and EAN-13 forms of
+// the same code match each other.
+func (p *ProductFirebase) FindByBarcode(ctx context.Context, value string) (*FirebaseProduct, error) {
+	docs, err := scopedQuery(ctx, p.client.Collection("products")).
+		Where("barcode_value", "in", barcode.Variants(value)).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query products: %w", err)
+	}
 
-	var products []FirebaseProduct
-	err := p.FirebaseModel.Query(ctx, &query, &products)
+	for _, doc := range docs {
+		if !IsTrashed(doc) {
+			return mapFirebaseProduct(doc)
+		}
+	}
+	return nil, fmt.Errorf("no product found with barcode: %s", value)
+}
+
+// FindByName finds a product by its exact name
+func (p *ProductFirebase) FindByName(ctx context.Context, name string) (*FirebaseProduct, error) {
+	docs, err := scopedQuery(ctx, p.client.Collection("products")).
+		Where("name", "==", name).
+		Documents(ctx).GetAll()
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to query products: %w", err)
 	}
 
-	if len(products) == 0 {
-		return nil, fmt.Errorf("no product found with barcode: %s", barcode)
+	for _, doc := range docs {
+		if !IsTrashed(doc) {
+			return mapFirebaseProduct(doc)
+		}
 	}
+	return nil, fmt.Errorf("no product found with name: %s", name)
+}
 
-	return &products[0], nil
+// IDsByCode maps product codes to the document IDs that receive and
+// delivery details refer to products by. Unknown codes are left out.
+func (p *ProductFirebase) IDsByCode(ctx context.Context, codes []string) (map[string]string, error) {
+	ids := make(map[string]string, len(codes))
+	for start := 0; start < len(codes); start += maxBranchFilter {
+		end := start + maxBranchFilter
+		if end > len(codes) {
+			end = len(codes)
+		}
+		docs, err := scopedQuery(ctx, p.client.Collection("products")).
+			Where("code", "in", codes[start:end]).
+			Documents(ctx).GetAll()
+		if err != nil {
+			return nil, fmt.Errorf("failed to query products: %w", err)
+		}
+		for _, doc := range docs {
+			if code, _ := doc.Data()["code"].(string); code != "" && !IsTrashed(doc) {
+				ids[code] = doc.Ref.ID
+			}
+		}
+	}
+	return ids, nil
 }
 
 // FindByCompany retrieves all products for a specific company
 func (p *ProductFirebase) FindByCompany(ctx context.Context, companyID string) ([]FirebaseProduct, error) {
-	query := p.ref.Where("data.company_id", "==", companyID)
+	query := p.ref.Where("company_id", "==", companyID)
 
 	var products []FirebaseProduct
 	err := p.FirebaseModel.Query(ctx, &query, &products)
+	withImages(products)
 	return products, err
 }
 
 // ProcessImage processes an image for product recognition
-func (p *ProductFirebase) ProcessImage(imagePath string, imageRecognition interfaces.ImageRecognition) (*types.ImageRecognitionResult, error) {
+func (p *ProductFirebase) ProcessImage(ctx context.Context, imagePath string, imageRecognition interfaces.ImageRecognition) (*types.ImageRecognitionResult, error) {
 	// Check if the image file exists
 	if _, err := os.Stat(imagePath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("image file not found: %s", imagePath)
 	}
 
 	// Process the image
-	result, err := imageRecognition.ProcessImage(imagePath)
+	result, err := imageRecognition.ProcessImage(ctx, imagePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to process image: %v", err)
+		return nil, fmt.Errorf("failed to process image: %w", err)
 	}
 
 	// Validate the result
@@ -306,29 +370,29 @@ func (p *ProductFirebase) UpdateStock(ctx context.Context, code string, newStock
 	// First get the product to ensure it exists
 	product, err := p.Get(ctx, code)
 	if err != nil {
-		return fmt.Errorf("failed to get product with code %s: %v", code, err)
+		return fmt.Errorf("failed to get product with code %s: %w", code, err)
 	}
 
 	// Update the stock field
 	product.MinimumStock = newStock
 
 	// Get the document reference
-	docRef := p.client.Collection("products").Where("code", "==", code).Limit(1)
+	docRef := scopedQuery(ctx, p.client.Collection("products")).Where("code", "==", code).Limit(1)
 	docs, err := docRef.Documents(ctx).GetAll()
 	if err != nil {
-		return fmt.Errorf("failed to get document reference: %v", err)
+		return fmt.Errorf("failed to get document reference: %w", err)
 	}
 	if len(docs) == 0 {
 		return fmt.Errorf("no document found for product with code: %s", code)
 	}
 
 	// Update the document
-	_, err = docs[0].Ref.Update(ctx, []firestore.Update{
+	err = updateDocument(ctx, p.client, docs[0].Ref, []firestore.Update{
 		{Path: "minimum_stock", Value: newStock},
 		{Path: "updated_at", Value: time.Now()},
 	})
 	if err != nil {
-		return fmt.Errorf("failed to update product stock: %v", err)
+		return fmt.Errorf("failed to update product stock: %w", err)
 	}
 
 	return nil
@@ -10,7 +10,8 @@ import (
 // PurchaseFirebase represents a purchase in Firebase
 type PurchaseFirebase struct {
 	*FirebaseModel
-	client *firestore.Client
+	client    *firestore.Client
+	approvals *ApprovalFirebase
 }
 
 // NewPurchaseFirebase creates a new Firebase purchase model
@@ -18,6 +19,7 @@ func NewPurchaseFirebase(client *firestore.Client) *PurchaseFirebase {
 	return &PurchaseFirebase{
 		FirebaseModel: NewFirebaseModel("purchases", client),
 		client:        client,
+		approvals:     NewApprovalFirebase(client),
 	}
 }
 
@@ -30,9 +32,19 @@ type FirebasePurchase struct {
 	SupplierID      string                   `json:"supplier_id"`
 	CompanyID       string                   `json:"company_id"`
 	BranchID        string                   `json:"branch_id"`
+	ApprovalStatus  string                   `json:"approval_status"`
 	PurchaseDetails []FirebasePurchaseDetail `json:"purchase_details"`
 }
 
+// Amount returns the purchase total after line and additional discounts
+func (p *FirebasePurchase) Amount() float64 {
+	amount := -p.AdditionalDisc
+	for _, detail := range p.PurchaseDetails {
+		amount += detail.Price*float64(detail.Qty) - detail.Disc
+	}
+	return amount
+}
+
 // FirebasePurchaseDetail represents a purchase detail in Firebase
 type FirebasePurchaseDetail struct {
 	ID        string          `json:"id"`
@@ -61,19 +73,71 @@ func (p *PurchaseFirebase) Get(ctx context.Context, id string) (*FirebasePurchas
 	return &purchase, nil
 }
 
-// Create creates a new purchase
+// Create creates a new purchase, putting it in pending approval when an approval rule applies
 func (p *PurchaseFirebase) Create(ctx context.Context, purchase *FirebasePurchase) (string, error) {
-	return p.FirebaseModel.Create(ctx, purchase)
+	rule, value, err := p.approvals.Match(ctx, ApprovalPurchases, map[string]float64{MetricAmount: purchase.Amount()})
+	if err != nil {
+		return "", err
+	}
+	purchase.ApprovalStatus = ""
+	if rule != nil {
+		purchase.ApprovalStatus = DocumentPendingApproval
+	}
+
+	if rule == nil {
+		return p.FirebaseModel.Create(ctx, purchase)
+	}
+
+	// The purchase and its approval are stored together, so no purchase
+	// waits for an approval that was never opened
+	ref := p.ref.NewDoc()
+	var approvalRef *firestore.DocumentRef
+	id, err := p.createWith(ctx, ref, purchase, func(tx *firestore.Transaction) error {
+		var err error
+		approvalRef, err = p.approvals.openIn(ctx, tx, ApprovalPurchases, ref.ID, rule, value)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	auditCreated(ctx, p.client, approvalRef)
+	return id, nil
 }
 
-// Update updates an existing purchase
+// Update updates an existing purchase. Changes that raise the amount of an
+// approved purchase above what was approved send it back for approval.
 func (p *PurchaseFirebase) Update(ctx context.Context, id string, purchase *FirebasePurchase) error {
-	return p.FirebaseModel.Update(ctx, id, purchase)
+	if _, err := p.Get(ctx, id); err != nil {
+		return err
+	}
+
+	rule, value, err := p.approvals.Match(ctx, ApprovalPurchases, map[string]float64{MetricAmount: purchase.Amount()})
+	if err != nil {
+		return err
+	}
+
+	// Changes may need approval again, reviewed with the purchase so a
+	// rejected write leaves its approvals alone
+	var approvalRef *firestore.DocumentRef
+	err = p.FirebaseModel.updateWith(ctx, id, purchase, func(tx *firestore.Transaction) error {
+		var err error
+		purchase.ApprovalStatus, approvalRef, err = p.approvals.reviewIn(ctx, tx, ApprovalPurchases, id, rule, value)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if approvalRef != nil {
+		auditCreated(ctx, p.client, approvalRef)
+	}
+	return nil
 }
 
-// Delete removes a purchase
+// Delete removes a purchase and cancels its pending approval
 func (p *PurchaseFirebase) Delete(ctx context.Context, id string) error {
-	return p.FirebaseModel.Delete(ctx, id)
+	return trashDocumentWith(ctx, p.client, p.ref.Doc(id), func(tx *firestore.Transaction) error {
+		return p.approvals.cancelIn(ctx, tx, ApprovalPurchases, id)
+	})
 }
 
 // FindByCompany retrieves all purchases for a specific company
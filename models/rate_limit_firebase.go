@@ -0,0 +1,140 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/nirshpaa/godam-backend/libraries/ratelimit"
+)
+
+// A Firestore document sustains about one write a second, so buckets with a
+// higher rate are split into shards that each hold a share of the tokens
+const (
+	rateLimitShardRate = 1.0
+	maxRateLimitShards = 16
+	rateLimitAttempts  = 3
+)
+
+// RateLimitFirebase keeps token buckets in Firestore so rate limits are
+// shared by every instance. Tokens are taken in a transaction, so concurrent
+// requests never spend the same token. Buckets are bookkeeping and bypass
+// versioning and the audit log; their expires_at field suits a Firestore TTL
+// policy. When Firestore fails, tokens are taken from fallback instead, so
+// limits hold per instance rather than letting every request through.
+type RateLimitFirebase struct {
+	client   *firestore.Client
+	fallback ratelimit.Store
+}
+
+// NewRateLimitFirebase creates a new RateLimitFirebase instance
+func NewRateLimitFirebase(client *firestore.Client, fallback ratelimit.Store) *RateLimitFirebase {
+	return &RateLimitFirebase{
+		client:   client,
+		fallback: fallback,
+	}
+}
+
+// Take takes n tokens from the bucket of key. A single token comes from one
+// shard picked at random; more are taken across all shards at once.
+func (r *RateLimitFirebase) Take(ctx context.Context, key string, limit ratelimit.Limit, n int) (ratelimit.Result, error) {
+	shards := rateLimitShards(limit)
+	indexes := make([]int, shards)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	if n <= 1 {
+		indexes = indexes[rand.Intn(shards):][:1]
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	id := hex.EncodeToString(sum[:])
+	refs := make([]*firestore.DocumentRef, len(indexes))
+	for i, index := range indexes {
+		refs[i] = r.client.Collection("rate_limits").Doc(id + "-" + strconv.Itoa(index))
+	}
+	refill := time.Duration(float64(limit.Burst) / limit.Rate() * float64(time.Second))
+
+	var result ratelimit.Result
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		docs, err := tx.GetAll(refs)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		buckets := make([]ratelimit.Bucket, len(docs))
+		available := 0.0
+		for i, doc := range docs {
+			if doc.Exists() {
+				buckets[i].Tokens, _ = doc.Data()["tokens"].(float64)
+				buckets[i].Updated, _ = doc.Data()["updated_at"].(time.Time)
+			}
+			buckets[i].Refill(shardLimit(limit, shards, indexes[i]), now)
+			available += buckets[i].Tokens
+		}
+
+		// The shards read refill at their share of the rate
+		need := math.Max(float64(n), 1)
+		if available < need {
+			rate := limit.Rate() * float64(len(refs)) / float64(shards)
+			result = ratelimit.Result{RetryAfter: time.Duration((need - available) / rate * float64(time.Second))}
+			return nil
+		}
+
+		left := float64(n)
+		for i := range buckets {
+			taken := math.Min(left, buckets[i].Tokens)
+			buckets[i].Tokens -= taken
+			left -= taken
+
+			err := tx.Set(docs[i].Ref, map[string]interface{}{
+				"tokens":     buckets[i].Tokens,
+				"updated_at": now,
+				"expires_at": now.Add(refill),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		result = ratelimit.Result{Allowed: true, Remaining: int(available - float64(n))}
+		return nil
+	}, firestore.MaxAttempts(rateLimitAttempts))
+	if err != nil {
+		log.Printf("Error taking rate limit tokens from Firestore, limiting this instance only: %v", err)
+		return r.fallback.Take(ctx, key, limit, n)
+	}
+	return result, nil
+}
+
+// rateLimitShards returns the number of shards a bucket of limit is split
+// into: enough to spread its writes, with at least a token of burst each
+func rateLimitShards(limit ratelimit.Limit) int {
+	shards := int(math.Ceil(limit.Rate() / rateLimitShardRate))
+	if shards > maxRateLimitShards {
+		shards = maxRateLimitShards
+	}
+	if shards > limit.Burst {
+		shards = limit.Burst
+	}
+	if shards < 1 {
+		shards = 1
+	}
+	return shards
+}
+
+// shardLimit returns the share of limit held by shard i of shards. The
+// shards' rates and bursts add up to those of limit.
+func shardLimit(limit ratelimit.Limit, shards, i int) ratelimit.Limit {
+	burst := limit.Burst / shards
+	if i < limit.Burst%shards {
+		burst++
+	}
+	return ratelimit.Limit{Requests: limit.Requests, Per: limit.Per * time.Duration(shards), Burst: burst}
+}
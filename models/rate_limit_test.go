@@ -0,0 +1,99 @@
+package models
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+	"github.com/nirshpaa/godam-backend/libraries/ratelimit"
+)
+
+// deniedStore rejects every take and counts them
+type deniedStore struct {
+	mu    sync.Mutex
+	takes int
+}
+
+func (s *deniedStore) Take(ctx context.Context, key string, limit ratelimit.Limit, n int) (ratelimit.Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.takes++
+	return ratelimit.Result{}, nil
+}
+
+func TestRateLimitFirebaseConcurrentTakes(t *testing.T) {
+	fallback := &deniedStore{}
+	store := NewRateLimitFirebase(firestoretest.New(t), fallback)
+	limit := ratelimit.PerMinute(1, 5)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := store.Take(context.Background(), "caller", limit, 1)
+			if err != nil {
+				t.Errorf("Take: %v", err)
+			}
+			if result.Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed > limit.Burst {
+		t.Fatalf("%d concurrent takes allowed, want at most the burst of %d", allowed, limit.Burst)
+	}
+	if allowed+fallback.takes < limit.Burst {
+		t.Fatalf("%d takes allowed and %d left to the fallback, want the burst of %d", allowed, fallback.takes, limit.Burst)
+	}
+	if result, _ := store.Take(context.Background(), "caller", limit, 1); allowed == limit.Burst && result.Allowed {
+		t.Error("take after the burst was spent allowed, want it rejected")
+	}
+}
+
+func TestRateLimitFirebaseShards(t *testing.T) {
+	store := NewRateLimitFirebase(firestoretest.New(t), &deniedStore{})
+	limit := ratelimit.PerSecond(10, 25)
+
+	shards := rateLimitShards(limit)
+	if shards != 10 {
+		t.Fatalf("%d shards, want 10 for 10 tokens a second", shards)
+	}
+	burst := 0
+	for i := 0; i < shards; i++ {
+		burst += shardLimit(limit, shards, i).Burst
+	}
+	if burst != limit.Burst {
+		t.Fatalf("shard bursts add up to %d, want %d", burst, limit.Burst)
+	}
+
+	ctx := context.Background()
+	if result, err := store.Take(ctx, "company", limit, 20); err != nil || !result.Allowed || result.Remaining != 5 {
+		t.Fatalf("taking 20 of 25 tokens = %+v, %v; want allowed with 5 left", result, err)
+	}
+	if result, err := store.Take(ctx, "company", limit, 10); err != nil || result.Allowed || result.RetryAfter <= 0 {
+		t.Fatalf("taking 10 of the 5 tokens left = %+v, %v; want rejected with a retry", result, err)
+	}
+	if result, err := store.Take(ctx, "other", limit, 1); err != nil || !result.Allowed {
+		t.Fatalf("taking from another key = %+v, %v; want allowed", result, err)
+	}
+}
+
+func TestRateLimitFirebaseFallsBack(t *testing.T) {
+	fallback := &deniedStore{}
+	store := NewRateLimitFirebase(firestoretest.New(t), fallback)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := store.Take(ctx, "caller", ratelimit.PerMinute(1, 5), 1)
+	if err != nil || result.Allowed || fallback.takes != 1 {
+		t.Fatalf("take with Firestore failing = %+v, %v after %d fallback takes; want the fallback's rejection", result, err, fallback.takes)
+	}
+}
@@ -21,16 +21,23 @@ func NewReceiveFirebase(client *firestore.Client) *ReceiveFirebase {
 	}
 }
 
+// ReceiveDraft marks receives prepared from batch scans that still need to
+// be reviewed
+const ReceiveDraft = "draft"
+
 // FirebaseReceive represents a receive in Firebase
 type FirebaseReceive struct {
 	ID             string                  `json:"id"`
 	Code           string                  `json:"code"`
 	Date           time.Time               `json:"date"`
 	Remark         string                  `json:"remark"`
+	Status         string                  `json:"status,omitempty"`
 	PurchaseID     string                  `json:"purchase_id"`
 	CompanyID      string                  `json:"company_id"`
 	BranchID       string                  `json:"branch_id"`
 	ReceiveDetails []FirebaseReceiveDetail `json:"receive_details"`
+	// ShelveIDs are the shelves of the details, set on every write
+	ShelveIDs []string `json:"shelve_ids"`
 }
 
 // FirebaseReceiveDetail represents a receive detail in Firebase
@@ -44,6 +51,10 @@ type FirebaseReceiveDetail struct {
 	Shelve    ShelveFirebaseModel `json:"shelve"`
 }
 
+func (r *FirebaseReceive) shelves() []string {
+	return distinctShelves(len(r.ReceiveDetails), func(i int) string { return r.ReceiveDetails[i].ShelveID })
+}
+
 // List retrieves all receives
 func (r *ReceiveFirebase) List(ctx context.Context) ([]FirebaseReceive, error) {
 	var receives []FirebaseReceive
@@ -62,13 +73,20 @@ func (r *ReceiveFirebase) Get(ctx context.Context, id string) (*FirebaseReceive,
 	return &receive, nil
 }
 
-// Create creates a new receive
+// Create creates a new receive. Goods can only be received against approved purchases.
 func (r *ReceiveFirebase) Create(ctx context.Context, receive *FirebaseReceive) (string, error) {
+	if receive.PurchaseID != "" {
+		if err := requireApproved(ctx, r.client, ApprovalPurchases, receive.PurchaseID); err != nil {
+			return "", err
+		}
+	}
+	receive.ShelveIDs = receive.shelves()
 	return r.FirebaseModel.Create(ctx, receive)
 }
 
 // Update updates an existing receive
 func (r *ReceiveFirebase) Update(ctx context.Context, id string, receive *FirebaseReceive) error {
+	receive.ShelveIDs = receive.shelves()
 	return r.FirebaseModel.Update(ctx, id, receive)
 }
 
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RecognitionSettings tunes product recognition for a company. A scan is
+// matched without asking the user when the visual matcher or the CNN scores
+// its best product at or above the threshold.
+type RecognitionSettings struct {
+	CompanyID       string  `json:"company_id"`
+	VisualThreshold float64 `json:"visual_threshold"`
+	CNNThreshold    float64 `json:"cnn_threshold"`
+	Candidates      int     `json:"candidates"`
+	// ModelVersionID is the trained model serving the CNN, empty for the
+	// default endpoint. A pinned model is kept when new models are trained.
+	ModelVersionID string    `json:"model_version_id"`
+	ModelPinned    bool      `json:"model_pinned"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// DefaultRecognitionSettings applies to companies that have not changed them
+var DefaultRecognitionSettings = RecognitionSettings{
+	VisualThreshold: 0.9,
+	CNNThreshold:    0.5,
+	Candidates:      5,
+}
+
+// RecognitionSettingsFirebase stores one RecognitionSettings document per
+// company, keyed by the company ID
+type RecognitionSettingsFirebase struct {
+	client *firestore.Client
+}
+
+// NewRecognitionSettingsFirebase creates a new RecognitionSettingsFirebase instance
+func NewRecognitionSettingsFirebase(client *firestore.Client) *RecognitionSettingsFirebase {
+	return &RecognitionSettingsFirebase{
+		client: client,
+	}
+}
+
+// Get returns the settings of the company in the context, falling back to
+// the defaults
+func (r *RecognitionSettingsFirebase) Get(ctx context.Context) (*RecognitionSettings, error) {
+	settings := DefaultRecognitionSettings
+	companyID := CompanyFromContext(ctx)
+	settings.CompanyID = companyID
+	if companyID == "" {
+		return &settings, nil
+	}
+
+	doc, err := r.client.Collection("recognition_settings").Doc(companyID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return &settings, nil
+		}
+		log.Printf("Error getting recognition settings: %v", err)
+		return nil, err
+	}
+
+	data := doc.Data()
+	if v := toFloat64(data["visual_threshold"]); v > 0 {
+		settings.VisualThreshold = v
+	}
+	if v := toFloat64(data["cnn_threshold"]); v > 0 {
+		settings.CNNThreshold = v
+	}
+	if v := int(toFloat64(data["candidates"])); v > 0 {
+		settings.Candidates = v
+	}
+	settings.ModelVersionID, _ = data["model_version_id"].(string)
+	settings.ModelPinned, _ = data["model_pinned"].(bool)
+	settings.UpdatedAt, _ = data["updated_at"].(time.Time)
+	recordVersion(ctx, doc.UpdateTime)
+	return &settings, nil
+}
+
+// Save stores the thresholds of the company in the context
+func (r *RecognitionSettingsFirebase) Save(ctx context.Context, settings *RecognitionSettings) error {
+	return r.write(ctx, map[string]interface{}{
+		"visual_threshold": settings.VisualThreshold,
+		"cnn_threshold":    settings.CNNThreshold,
+		"candidates":       settings.Candidates,
+	})
+}
+
+// SetModel selects the model version serving the CNN for the company in the context
+func (r *RecognitionSettingsFirebase) SetModel(ctx context.Context, modelVersionID string, pinned bool) error {
+	return r.write(ctx, map[string]interface{}{
+		"model_version_id": modelVersionID,
+		"model_pinned":     pinned,
+	})
+}
+
+// write creates the settings document of the company in the context with the
+// defaults or changes fields of the existing one
+func (r *RecognitionSettingsFirebase) write(ctx context.Context, fields map[string]interface{}) error {
+	companyID := CompanyFromContext(ctx)
+	if companyID == "" {
+		return ErrCompanyMismatch
+	}
+	fields["updated_at"] = time.Now()
+
+	ref := r.client.Collection("recognition_settings").Doc(companyID)
+	_, err := ref.Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		defaults := DefaultRecognitionSettings
+		data := map[string]interface{}{
+			"company_id":       companyID,
+			"visual_threshold": defaults.VisualThreshold,
+			"cnn_threshold":    defaults.CNNThreshold,
+			"candidates":       defaults.Candidates,
+			"model_version_id": "",
+			"model_pinned":     false,
+		}
+		for field, value := range fields {
+			data[field] = value
+		}
+		return createDocument(ctx, r.client, ref, data)
+	}
+	if err != nil {
+		return err
+	}
+
+	updates := make([]firestore.Update, 0, len(fields))
+	for field, value := range fields {
+		updates = append(updates, firestore.Update{Path: field, Value: value})
+	}
+	return updateDocument(ctx, r.client, ref, updates)
+}
@@ -9,8 +9,9 @@ import (
 
 // RegionFirebaseModel represents a region in the system for Firebase
 type RegionFirebaseModel struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	CompanyID string `json:"company_id"`
 }
 
 // RegionFirebase represents the Firestore client for region
@@ -28,13 +29,17 @@ func NewRegionFirebase(client *firestore.Client) *RegionFirebase {
 // List retrieves all region records
 func (r *RegionFirebase) List(ctx context.Context) ([]*RegionFirebaseModel, error) {
 	var regions []*RegionFirebaseModel
-	docs, err := r.client.Collection("regions").Documents(ctx).GetAll()
+	docs, err := scopedQuery(ctx, r.client.Collection("regions")).Documents(ctx).GetAll()
 	if err != nil {
 		log.Printf("Error getting regions: %v", err)
 		return nil, err
 	}
 
 	for _, doc := range docs {
+		if IsTrashed(doc) {
+			continue
+		}
+
 		var region RegionFirebaseModel
 		if err := doc.DataTo(&region); err != nil {
 			log.Printf("Error converting region data: %v", err)
@@ -54,6 +59,9 @@ func (r *RegionFirebase) Get(ctx context.Context, id string) (*RegionFirebaseMod
 		log.Printf("Error getting region: %v", err)
 		return nil, err
 	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
 
 	var region RegionFirebaseModel
 	if err := doc.DataTo(&region); err != nil {
@@ -61,13 +69,15 @@ func (r *RegionFirebase) Get(ctx context.Context, id string) (*RegionFirebaseMod
 		return nil, err
 	}
 	region.ID = doc.Ref.ID
+	recordVersion(ctx, doc.UpdateTime)
 
 	return &region, nil
 }
 
 // Create creates a new region record
 func (r *RegionFirebase) Create(ctx context.Context, region *RegionFirebaseModel) (string, error) {
-	doc, _, err := r.client.Collection("regions").Add(ctx, region)
+	doc := r.client.Collection("regions").NewDoc()
+	err := createDocument(ctx, r.client, doc, region)
 	if err != nil {
 		log.Printf("Error creating region: %v", err)
 		return "", err
@@ -78,7 +88,7 @@ func (r *RegionFirebase) Create(ctx context.Context, region *RegionFirebaseModel
 
 // Update updates an existing region record
 func (r *RegionFirebase) Update(ctx context.Context, id string, region *RegionFirebaseModel) error {
-	_, err := r.client.Collection("regions").Doc(id).Set(ctx, region)
+	err := setDocument(ctx, r.client, r.client.Collection("regions").Doc(id), region)
 	if err != nil {
 		log.Printf("Error updating region: %v", err)
 		return err
@@ -89,7 +99,7 @@ func (r *RegionFirebase) Update(ctx context.Context, id string, region *RegionFi
 
 // Delete deletes a region record
 func (r *RegionFirebase) Delete(ctx context.Context, id string) error {
-	_, err := r.client.Collection("regions").Doc(id).Delete(ctx)
+	err := trashDocument(ctx, r.client, r.client.Collection("regions").Doc(id))
 	if err != nil {
 		log.Printf("Error deleting region: %v", err)
 		return err
@@ -9,8 +9,12 @@ import (
 
 // RoleFirebaseModel represents a role in the system for Firebase
 type RoleFirebaseModel struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	CompanyID string   `json:"company_id"`
+	AccessIDs []string `json:"access_ids"`
+	// HiddenFields lists response fields members of the role may not see, keyed by resource
+	HiddenFields map[string][]string `json:"hidden_fields"`
 }
 
 // RoleFirebase represents the Firestore client for role
@@ -28,13 +32,17 @@ func NewRoleFirebase(client *firestore.Client) *RoleFirebase {
 // List retrieves all role records
 func (r *RoleFirebase) List(ctx context.Context) ([]*RoleFirebaseModel, error) {
 	var roles []*RoleFirebaseModel
-	docs, err := r.client.Collection("roles").Documents(ctx).GetAll()
+	docs, err := scopedQuery(ctx, r.client.Collection("roles")).Documents(ctx).GetAll()
 	if err != nil {
 		log.Printf("Error getting roles: %v", err)
 		return nil, err
 	}
 
 	for _, doc := range docs {
+		if IsTrashed(doc) {
+			continue
+		}
+
 		var role RoleFirebaseModel
 		if err := doc.DataTo(&role); err != nil {
 			log.Printf("Error converting role data: %v", err)
@@ -54,6 +62,9 @@ func (r *RoleFirebase) Get(ctx context.Context, id string) (*RoleFirebaseModel,
 		log.Printf("Error getting role: %v", err)
 		return nil, err
 	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
 
 	var role RoleFirebaseModel
 	if err := doc.DataTo(&role); err != nil {
@@ -61,13 +72,15 @@ func (r *RoleFirebase) Get(ctx context.Context, id string) (*RoleFirebaseModel,
 		return nil, err
 	}
 	role.ID = doc.Ref.ID
+	recordVersion(ctx, doc.UpdateTime)
 
 	return &role, nil
 }
 
 // Create creates a new role record
 func (r *RoleFirebase) Create(ctx context.Context, role *RoleFirebaseModel) (string, error) {
-	doc, _, err := r.client.Collection("roles").Add(ctx, role)
+	doc := r.client.Collection("roles").NewDoc()
+	err := createDocument(ctx, r.client, doc, role)
 	if err != nil {
 		log.Printf("Error creating role: %v", err)
 		return "", err
@@ -78,7 +91,7 @@ func (r *RoleFirebase) Create(ctx context.Context, role *RoleFirebaseModel) (str
 
 // Update updates an existing role record
 func (r *RoleFirebase) Update(ctx context.Context, id string, role *RoleFirebaseModel) error {
-	_, err := r.client.Collection("roles").Doc(id).Set(ctx, role)
+	err := setDocument(ctx, r.client, r.client.Collection("roles").Doc(id), role)
 	if err != nil {
 		log.Printf("Error updating role: %v", err)
 		return err
@@ -89,7 +102,7 @@ func (r *RoleFirebase) Update(ctx context.Context, id string, role *RoleFirebase
 
 // Delete deletes a role record
 func (r *RoleFirebase) Delete(ctx context.Context, id string) error {
-	_, err := r.client.Collection("roles").Doc(id).Delete(ctx)
+	err := trashDocument(ctx, r.client, r.client.Collection("roles").Doc(id))
 	if err != nil {
 		log.Printf("Error deleting role: %v", err)
 		return err
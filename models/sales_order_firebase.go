@@ -24,24 +24,47 @@ type SalesOrderFirebase struct {
 	Status            string                   `json:"status"`
 	SalesOrderDetails []types.SalesOrderDetail `json:"sales_order_details"`
 	client            *firestore.Client
+	approvals         *ApprovalFirebase
 }
 
 // NewSalesOrderFirebase creates a new Firebase sales order model
 func NewSalesOrderFirebase(client *firestore.Client) *SalesOrderFirebase {
 	return &SalesOrderFirebase{
-		client: client,
+		client:    client,
+		approvals: NewApprovalFirebase(client),
 	}
 }
 
+// salesOrderMetrics measures a sales order for approval rules. The discount
+// percentage covers line, order and additional discounts against the gross amount.
+func salesOrderMetrics(order types.SalesOrder) map[string]float64 {
+	var gross, discount float64
+	for _, detail := range order.SalesOrderDetails {
+		gross += detail.Quantity * detail.UnitPrice
+		discount += detail.Discount
+	}
+	discount += order.Discount + order.AdditionalDisc
+
+	metrics := map[string]float64{MetricAmount: order.TotalAmount}
+	if gross > 0 {
+		metrics[MetricDiscountPercent] = discount / gross * 100
+	}
+	return metrics
+}
+
 // List retrieves all sales orders
 func (s *SalesOrderFirebase) List(ctx context.Context) ([]types.SalesOrder, error) {
-	docs, err := s.client.Collection("sales_orders").Documents(ctx).GetAll()
+	docs, err := scopedQuery(ctx, s.client.Collection("sales_orders")).Documents(ctx).GetAll()
 	if err != nil {
 		return nil, err
 	}
 
 	var orders []types.SalesOrder
 	for _, doc := range docs {
+		if IsTrashed(doc) {
+			continue
+		}
+
 		var order types.SalesOrder
 		if err := doc.DataTo(&order); err != nil {
 			return nil, err
@@ -58,6 +81,10 @@ func (s *SalesOrderFirebase) GetByID(ctx context.Context, id string) (*types.Sal
 	if err != nil {
 		return nil, err
 	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
+	recordVersion(ctx, doc.UpdateTime)
 
 	var order types.SalesOrder
 	if err := doc.DataTo(&order); err != nil {
@@ -102,8 +129,35 @@ func (s *SalesOrderFirebase) Create(ctx context.Context, order types.SalesOrder)
 		order.Status = "pending"
 	}
 
-	_, err := s.client.Collection("sales_orders").Doc(order.ID).Set(ctx, order)
-	return err
+	rule, value, err := s.approvals.Match(ctx, ApprovalSalesOrders, salesOrderMetrics(order))
+	if err != nil {
+		return err
+	}
+	order.ApprovalStatus = ""
+	if rule != nil {
+		order.ApprovalStatus = DocumentPendingApproval
+		if order.Status == "completed" {
+			return ErrApprovalRequired
+		}
+	}
+
+	if rule == nil {
+		return createDocument(ctx, s.client, s.client.Collection("sales_orders").Doc(order.ID), &order)
+	}
+
+	// The order and its approval are stored together, so no order waits
+	// for an approval that was never opened
+	var approvalRef *firestore.DocumentRef
+	err = createDocumentWith(ctx, s.client, s.client.Collection("sales_orders").Doc(order.ID), &order, func(tx *firestore.Transaction) error {
+		var err error
+		approvalRef, err = s.approvals.openIn(ctx, tx, ApprovalSalesOrders, order.ID, rule, value)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	auditCreated(ctx, s.client, approvalRef)
+	return nil
 }
 
 // Update updates an existing sales order
@@ -111,7 +165,12 @@ func (s *SalesOrderFirebase) Update(ctx context.Context, id string, order types.
 	// Get the existing order
 	existingOrder, err := s.GetByID(ctx, id)
 	if err != nil {
-		return fmt.Errorf("failed to get existing order: %v", err)
+		return fmt.Errorf("failed to get existing order: %w", err)
+	}
+
+	rule, value, err := s.approvals.Match(ctx, ApprovalSalesOrders, salesOrderMetrics(order))
+	if err != nil {
+		return err
 	}
 
 	// If status is changing to completed, update stock
@@ -119,20 +178,39 @@ func (s *SalesOrderFirebase) Update(ctx context.Context, id string, order types.
 		// TODO: Implement stock update logic here
 	}
 
-	// Update the order
-	_, err = s.client.Collection("sales_orders").Doc(id).Set(ctx, order)
-	return err
+	// Changes may need approval again, reviewed with the order so a rejected
+	// write leaves its approvals alone, and orders cannot complete until approved
+	var approvalRef *firestore.DocumentRef
+	err = setDocumentWith(ctx, s.client, s.client.Collection("sales_orders").Doc(id), &order, func(tx *firestore.Transaction) error {
+		var err error
+		order.ApprovalStatus, approvalRef, err = s.approvals.reviewIn(ctx, tx, ApprovalSalesOrders, id, rule, value)
+		if err != nil {
+			return err
+		}
+		if order.Status == "completed" && order.ApprovalStatus != "" && order.ApprovalStatus != DocumentApproved {
+			return ErrApprovalRequired
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if approvalRef != nil {
+		auditCreated(ctx, s.client, approvalRef)
+	}
+	return nil
 }
 
-// Delete removes a sales order
+// Delete removes a sales order and cancels its pending approval
 func (s *SalesOrderFirebase) Delete(ctx context.Context, id string) error {
-	_, err := s.client.Collection("sales_orders").Doc(id).Delete(ctx)
-	return err
+	return trashDocumentWith(ctx, s.client, s.client.Collection("sales_orders").Doc(id), func(tx *firestore.Transaction) error {
+		return s.approvals.cancelIn(ctx, tx, ApprovalSalesOrders, id)
+	})
 }
 
 // FindByCompany retrieves all sales orders for a specific company
 func (s *SalesOrderFirebase) FindByCompany(ctx context.Context, companyID string) ([]types.SalesOrder, error) {
-	query := s.client.Collection("sales_orders").Where("company_id", "==", companyID)
+	query := scopedQuery(ctx, s.client.Collection("sales_orders")).Where("company_id", "==", companyID)
 	docs, err := query.Documents(ctx).GetAll()
 	if err != nil {
 		return nil, err
@@ -140,6 +218,10 @@ func (s *SalesOrderFirebase) FindByCompany(ctx context.Context, companyID string
 
 	var orders []types.SalesOrder
 	for _, doc := range docs {
+		if IsTrashed(doc) {
+			continue
+		}
+
 		var order types.SalesOrder
 		if err := doc.DataTo(&order); err != nil {
 			return nil, err
@@ -156,7 +238,7 @@ func (s *SalesOrderFirebase) GetSalesByDateRange(ctx context.Context, companyID
 	startDateStr := startDate.Format(time.RFC3339)
 	endDateStr := endDate.Format(time.RFC3339)
 
-	query := s.client.Collection("sales_orders").
+	query := scopedQuery(ctx, s.client.Collection("sales_orders")).
 		Where("company_id", "==", companyID).
 		Where("date", ">=", startDateStr).
 		Where("date", "<=", endDateStr)
@@ -168,6 +250,10 @@ func (s *SalesOrderFirebase) GetSalesByDateRange(ctx context.Context, companyID
 
 	var orders []types.SalesOrder
 	for _, doc := range docs {
+		if IsTrashed(doc) {
+			continue
+		}
+
 		var order types.SalesOrder
 		if err := doc.DataTo(&order); err != nil {
 			return nil, err
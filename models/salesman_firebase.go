@@ -30,13 +30,17 @@ func NewSalesmanFirebase(client *firestore.Client) *SalesmanFirebase {
 // List retrieves all salesman records
 func (s *SalesmanFirebase) List(ctx context.Context) ([]*SalesmanFirebaseModel, error) {
 	var salesmen []*SalesmanFirebaseModel
-	docs, err := s.client.Collection("salesmen").Documents(ctx).GetAll()
+	docs, err := scopedQuery(ctx, s.client.Collection("salesmen")).Documents(ctx).GetAll()
 	if err != nil {
 		log.Printf("Error getting salesmen: %v", err)
 		return nil, err
 	}
 
 	for _, doc := range docs {
+		if IsTrashed(doc) {
+			continue
+		}
+
 		var salesman SalesmanFirebaseModel
 		if err := doc.DataTo(&salesman); err != nil {
 			log.Printf("Error converting salesman data: %v", err)
@@ -56,6 +60,9 @@ func (s *SalesmanFirebase) Get(ctx context.Context, id string) (*SalesmanFirebas
 		log.Printf("Error getting salesman: %v", err)
 		return nil, err
 	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
 
 	var salesman SalesmanFirebaseModel
 	if err := doc.DataTo(&salesman); err != nil {
@@ -63,13 +70,15 @@ func (s *SalesmanFirebase) Get(ctx context.Context, id string) (*SalesmanFirebas
 		return nil, err
 	}
 	salesman.ID = doc.Ref.ID
+	recordVersion(ctx, doc.UpdateTime)
 
 	return &salesman, nil
 }
 
 // Create creates a new salesman record
 func (s *SalesmanFirebase) Create(ctx context.Context, salesman *SalesmanFirebaseModel) (string, error) {
-	doc, _, err := s.client.Collection("salesmen").Add(ctx, salesman)
+	doc := s.client.Collection("salesmen").NewDoc()
+	err := createDocument(ctx, s.client, doc, salesman)
 	if err != nil {
 		log.Printf("Error creating salesman: %v", err)
 		return "", err
@@ -80,7 +89,7 @@ func (s *SalesmanFirebase) Create(ctx context.Context, salesman *SalesmanFirebas
 
 // Update updates an existing salesman record
 func (s *SalesmanFirebase) Update(ctx context.Context, id string, salesman *SalesmanFirebaseModel) error {
-	_, err := s.client.Collection("salesmen").Doc(id).Set(ctx, salesman)
+	err := setDocument(ctx, s.client, s.client.Collection("salesmen").Doc(id), salesman)
 	if err != nil {
 		log.Printf("Error updating salesman: %v", err)
 		return err
@@ -91,7 +100,7 @@ func (s *SalesmanFirebase) Update(ctx context.Context, id string, salesman *Sale
 
 // Delete deletes a salesman record
 func (s *SalesmanFirebase) Delete(ctx context.Context, id string) error {
-	_, err := s.client.Collection("salesmen").Doc(id).Delete(ctx)
+	err := trashDocument(ctx, s.client, s.client.Collection("salesmen").Doc(id))
 	if err != nil {
 		log.Printf("Error deleting salesman: %v", err)
 		return err
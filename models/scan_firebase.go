@@ -0,0 +1,271 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/nirshpaa/godam-backend/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FirebaseScan is a product scan with the candidates it returned and, once
+// the user confirmed it, the product they picked
+type FirebaseScan struct {
+	ID                   string                       `json:"id"`
+	CompanyID            string                       `json:"company_id"`
+	UserID               string                       `json:"user_id"`
+	Barcode              string                       `json:"barcode,omitempty"`
+	BatchID              string                       `json:"batch_id,omitempty"`
+	ImageFile            string                       `json:"image_file,omitempty"`
+	Candidates           []types.RecognitionCandidate `json:"candidates"`
+	Accepted             bool                         `json:"accepted"`
+	ConfirmedProductCode string                       `json:"confirmed_product_code,omitempty"`
+	ConfirmedBy          string                       `json:"confirmed_by,omitempty"`
+	ConfirmedAt          *time.Time                   `json:"confirmed_at,omitempty"`
+	// ConfirmedRank is the 1-based position of the confirmed product among
+	// the candidates, 0 when it was not offered
+	ConfirmedRank   int       `json:"confirmed_rank"`
+	ConfirmedSource string    `json:"confirmed_source,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// SourceAccuracy counts confirmed scans whose top candidate came from one recognizer
+type SourceAccuracy struct {
+	Confirmed int     `json:"confirmed"`
+	Correct   int     `json:"correct"`
+	Accuracy  float64 `json:"accuracy"`
+}
+
+// RecognitionAccuracy summarizes the feedback of confirmed scans
+type RecognitionAccuracy struct {
+	Scans     int `json:"scans"`
+	Confirmed int `json:"confirmed"`
+	// Top1 scans had the confirmed product first, TopK anywhere in the candidates
+	Top1         int     `json:"top1"`
+	TopK         int     `json:"top_k"`
+	Top1Accuracy float64 `json:"top1_accuracy"`
+	TopKAccuracy float64 `json:"top_k_accuracy"`
+	// Accepted scans were matched without asking the user; AcceptedCorrect of
+	// them were confirmed as right, AcceptedAccuracy is over the confirmed ones
+	Accepted         int                       `json:"accepted"`
+	AcceptedCorrect  int                       `json:"accepted_correct"`
+	AcceptedAccuracy float64                   `json:"accepted_accuracy"`
+	BySource         map[string]SourceAccuracy `json:"by_source"`
+}
+
+// ScanFirebase stores product scans and the feedback on them. Scans are
+// bookkeeping and bypass versioning and the audit log.
+type ScanFirebase struct {
+	client *firestore.Client
+}
+
+// NewScanFirebase creates a new ScanFirebase instance
+func NewScanFirebase(client *firestore.Client) *ScanFirebase {
+	return &ScanFirebase{
+		client: client,
+	}
+}
+
+// Create records a scan for the company in the context and returns its ID
+func (s *ScanFirebase) Create(ctx context.Context, scan *FirebaseScan) (string, error) {
+	companyID := CompanyFromContext(ctx)
+	if companyID == "" {
+		return "", ErrCompanyMismatch
+	}
+
+	candidates := make([]map[string]interface{}, 0, len(scan.Candidates))
+	for _, candidate := range scan.Candidates {
+		candidates = append(candidates, map[string]interface{}{
+			"product_code": candidate.ProductCode,
+			"name":         candidate.Name,
+			"score":        candidate.Score,
+			"source":       candidate.Source,
+		})
+	}
+
+	ref, _, err := s.client.Collection("scans").Add(ctx, map[string]interface{}{
+		"company_id":             companyID,
+		"user_id":                scan.UserID,
+		"barcode":                scan.Barcode,
+		"batch_id":               scan.BatchID,
+		"candidates":             candidates,
+		"accepted":               scan.Accepted,
+		"confirmed_product_code": "",
+		"created_at":             time.Now(),
+	})
+	if err != nil {
+		log.Printf("Error recording scan: %v", err)
+		return "", err
+	}
+	return ref.ID, nil
+}
+
+// Get retrieves a scan by ID
+func (s *ScanFirebase) Get(ctx context.Context, id string) (*FirebaseScan, error) {
+	doc, err := s.client.Collection("scans").Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
+	return mapFirebaseScan(doc), nil
+}
+
+// SetImage records where the scanned image was kept
+func (s *ScanFirebase) SetImage(ctx context.Context, id, file string) error {
+	_, err := s.client.Collection("scans").Doc(id).Update(ctx, []firestore.Update{
+		{Path: "image_file", Value: file},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update scan: %v", err)
+	}
+	return nil
+}
+
+// Confirm records the product the user picked for a scan. A later
+// confirmation replaces an earlier one.
+func (s *ScanFirebase) Confirm(ctx context.Context, id, productCode, userID string) (*FirebaseScan, error) {
+	scan, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rank, source := 0, ""
+	for i, candidate := range scan.Candidates {
+		if candidate.ProductCode == productCode {
+			rank, source = i+1, candidate.Source
+			break
+		}
+	}
+
+	now := time.Now()
+	_, err = s.client.Collection("scans").Doc(id).Update(ctx, []firestore.Update{
+		{Path: "confirmed_product_code", Value: productCode},
+		{Path: "confirmed_by", Value: userID},
+		{Path: "confirmed_at", Value: now},
+		{Path: "confirmed_rank", Value: rank},
+		{Path: "confirmed_source", Value: source},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to confirm scan: %v", err)
+	}
+
+	scan.ConfirmedProductCode = productCode
+	scan.ConfirmedBy = userID
+	scan.ConfirmedAt = &now
+	scan.ConfirmedRank = rank
+	scan.ConfirmedSource = source
+	return scan, nil
+}
+
+// Accuracy summarizes the scans of the company in the context made between
+// from and to; zero times leave the range open
+func (s *ScanFirebase) Accuracy(ctx context.Context, from, to time.Time) (*RecognitionAccuracy, error) {
+	query := scopedQuery(ctx, s.client.Collection("scans"))
+	if !from.IsZero() {
+		query = query.Where("created_at", ">=", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("created_at", "<=", to)
+	}
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("Error listing scans: %v", err)
+		return nil, err
+	}
+
+	accuracy := &RecognitionAccuracy{BySource: map[string]SourceAccuracy{}}
+	acceptedConfirmed := 0
+	for _, doc := range docs {
+		scan := mapFirebaseScan(doc)
+		accuracy.Scans++
+		if scan.Accepted {
+			accuracy.Accepted++
+		}
+		if scan.ConfirmedProductCode == "" {
+			continue
+		}
+
+		accuracy.Confirmed++
+		correct := scan.ConfirmedRank == 1
+		if correct {
+			accuracy.Top1++
+		}
+		if scan.ConfirmedRank > 0 {
+			accuracy.TopK++
+		}
+		if scan.Accepted {
+			acceptedConfirmed++
+			if correct {
+				accuracy.AcceptedCorrect++
+			}
+		}
+		if len(scan.Candidates) > 0 {
+			source := accuracy.BySource[scan.Candidates[0].Source]
+			source.Confirmed++
+			if correct {
+				source.Correct++
+			}
+			accuracy.BySource[scan.Candidates[0].Source] = source
+		}
+	}
+
+	accuracy.Top1Accuracy = ratio(accuracy.Top1, accuracy.Confirmed)
+	accuracy.TopKAccuracy = ratio(accuracy.TopK, accuracy.Confirmed)
+	accuracy.AcceptedAccuracy = ratio(accuracy.AcceptedCorrect, acceptedConfirmed)
+	for name, source := range accuracy.BySource {
+		source.Accuracy = ratio(source.Correct, source.Confirmed)
+		accuracy.BySource[name] = source
+	}
+	return accuracy, nil
+}
+
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// mapFirebaseScan maps a Firestore document to a FirebaseScan
+func mapFirebaseScan(doc *firestore.DocumentSnapshot) *FirebaseScan {
+	data := doc.Data()
+	scan := &FirebaseScan{ID: doc.Ref.ID}
+	scan.CompanyID, _ = data["company_id"].(string)
+	scan.UserID, _ = data["user_id"].(string)
+	scan.Barcode, _ = data["barcode"].(string)
+	scan.BatchID, _ = data["batch_id"].(string)
+	scan.ImageFile, _ = data["image_file"].(string)
+	scan.Accepted, _ = data["accepted"].(bool)
+	scan.ConfirmedProductCode, _ = data["confirmed_product_code"].(string)
+	scan.ConfirmedBy, _ = data["confirmed_by"].(string)
+	scan.ConfirmedRank = int(toFloat64(data["confirmed_rank"]))
+	scan.ConfirmedSource, _ = data["confirmed_source"].(string)
+	scan.CreatedAt, _ = data["created_at"].(time.Time)
+	if confirmedAt, ok := data["confirmed_at"].(time.Time); ok {
+		scan.ConfirmedAt = &confirmedAt
+	}
+
+	scan.Candidates = []types.RecognitionCandidate{}
+	candidates, _ := data["candidates"].([]interface{})
+	for _, c := range candidates {
+		m, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		candidate := types.RecognitionCandidate{Score: toFloat64(m["score"])}
+		candidate.ProductCode, _ = m["product_code"].(string)
+		candidate.Name, _ = m["name"].(string)
+		candidate.Source, _ = m["source"].(string)
+		scan.Candidates = append(scan.Candidates, candidate)
+	}
+	return scan
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+	"github.com/nirshpaa/godam-backend/types"
+)
+
+func TestScanConfirmAccuracy(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := WithCompany(context.Background(), "company-a")
+	scans := NewScanFirebase(client)
+
+	candidates := []types.RecognitionCandidate{
+		{ProductCode: "P1", Name: "Tea", Score: 0.95, Source: "visual"},
+		{ProductCode: "P2", Name: "Coffee", Score: 0.4, Source: "cnn"},
+	}
+	create := func(accepted bool) string {
+		t.Helper()
+		id, err := scans.Create(ctx, &FirebaseScan{UserID: "user-1", Candidates: candidates, Accepted: accepted})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		return id
+	}
+	right, second, missed := create(true), create(false), create(false)
+	create(true)
+
+	tests := []struct {
+		id, code   string
+		rank       int
+		wantSource string
+	}{
+		{right, "P1", 1, "visual"},
+		{second, "P2", 2, "cnn"},
+		{missed, "P9", 0, ""},
+	}
+	for _, tt := range tests {
+		scan, err := scans.Confirm(ctx, tt.id, tt.code, "user-2")
+		if err != nil {
+			t.Fatalf("Confirm(%s): %v", tt.code, err)
+		}
+		if scan.ConfirmedRank != tt.rank || scan.ConfirmedSource != tt.wantSource || scan.ConfirmedBy != "user-2" {
+			t.Errorf("Confirm(%s) = rank %d source %q by %q, want rank %d source %q", tt.code, scan.ConfirmedRank, scan.ConfirmedSource, scan.ConfirmedBy, tt.rank, tt.wantSource)
+		}
+	}
+
+	stored, err := scans.Get(ctx, second)
+	if err != nil || stored.ConfirmedProductCode != "P2" || stored.ConfirmedRank != 2 || stored.ConfirmedAt == nil {
+		t.Fatalf("Get after Confirm = %+v, %v", stored, err)
+	}
+
+	// Scans of other companies are neither readable nor counted
+	other, err := scans.Create(WithCompany(context.Background(), "company-b"), &FirebaseScan{Candidates: candidates, Accepted: true})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := scans.Confirm(ctx, other, "P1", "user-2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Confirm of another company's scan = %v, want ErrNotFound", err)
+	}
+
+	accuracy, err := scans.Accuracy(ctx, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Accuracy: %v", err)
+	}
+	if accuracy.Scans != 4 || accuracy.Confirmed != 3 || accuracy.Top1 != 1 || accuracy.TopK != 2 {
+		t.Errorf("accuracy counts = %+v", accuracy)
+	}
+	if accuracy.Accepted != 2 || accuracy.AcceptedCorrect != 1 || accuracy.AcceptedAccuracy != 1 {
+		t.Errorf("accepted = %d, correct %d, accuracy %v, want 2, 1, 1", accuracy.Accepted, accuracy.AcceptedCorrect, accuracy.AcceptedAccuracy)
+	}
+	if visual := accuracy.BySource["visual"]; visual.Confirmed != 3 || visual.Correct != 1 {
+		t.Errorf("visual source = %+v, want 1 of 3 correct", visual)
+	}
+
+	future, err := scans.Accuracy(ctx, time.Now().Add(time.Hour), time.Time{})
+	if err != nil || future.Scans != 0 {
+		t.Errorf("Accuracy from the future = %+v, %v, want no scans", future, err)
+	}
+}
+
+func TestRecognitionSettings(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := WithCompany(context.Background(), "company-a")
+	settings := NewRecognitionSettingsFirebase(client)
+
+	got, err := settings.Get(ctx)
+	if err != nil || got.VisualThreshold != DefaultRecognitionSettings.VisualThreshold || got.Candidates != DefaultRecognitionSettings.Candidates {
+		t.Fatalf("Get without settings = %+v, %v, want the defaults", got, err)
+	}
+
+	if err := settings.SetModel(ctx, "model-3", true); err != nil {
+		t.Fatalf("SetModel: %v", err)
+	}
+	if err := settings.Save(ctx, &RecognitionSettings{VisualThreshold: 0.8, CNNThreshold: 0.6, Candidates: 3}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err = settings.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.VisualThreshold != 0.8 || got.CNNThreshold != 0.6 || got.Candidates != 3 || got.ModelVersionID != "model-3" || !got.ModelPinned {
+		t.Errorf("Get = %+v, want the saved thresholds with the model kept", got)
+	}
+
+	other, err := settings.Get(WithCompany(context.Background(), "company-b"))
+	if err != nil || other.VisualThreshold != DefaultRecognitionSettings.VisualThreshold {
+		t.Errorf("Get for another company = %+v, %v, want the defaults", other, err)
+	}
+}
@@ -0,0 +1,474 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/nirshpaa/godam-backend/interfaces"
+)
+
+// Security event types
+const (
+	SecurityAuthFailure      = "auth_failure"
+	SecurityCompanyDenied    = "company_denied"
+	SecurityPermissionDenied = "permission_denied"
+	SecurityAPIKeyUsed       = "api_key_used"
+)
+
+// SecurityEventTypes lists the event types recorded
+var SecurityEventTypes = []string{SecurityAuthFailure, SecurityCompanyDenied, SecurityPermissionDenied, SecurityAPIKeyUsed}
+
+// Alert rule grouping: events are counted per client IP, per user or for the whole company
+const (
+	SecurityGroupIP      = "ip"
+	SecurityGroupUser    = "user"
+	SecurityGroupCompany = "company"
+)
+
+// SecurityEvent records a failed or sensitive access attempt. Events are
+// bookkeeping, so they bypass versioning and the audit log.
+type SecurityEvent struct {
+	ID        string    `json:"id" firestore:"-"`
+	Type      string    `json:"type" firestore:"type"`
+	CompanyID string    `json:"company_id" firestore:"company_id"`
+	UserID    string    `json:"user_id" firestore:"user_id"`
+	IP        string    `json:"ip" firestore:"ip"`
+	Method    string    `json:"method" firestore:"method"`
+	Path      string    `json:"path" firestore:"path"`
+	Detail    string    `json:"detail" firestore:"detail"`
+	RequestID string    `json:"request_id" firestore:"request_id"`
+	Timestamp time.Time `json:"timestamp" firestore:"timestamp"`
+}
+
+// SecurityFilter narrows a security event query
+type SecurityFilter struct {
+	CompanyID string
+	Type      string
+	IP        string
+	UserID    string
+	From      time.Time
+	To        time.Time
+	Limit     int
+}
+
+// FirebaseSecurityAlertRule raises an alert once Threshold events of
+// EventType happen within WindowMinutes for the same group
+type FirebaseSecurityAlertRule struct {
+	ID            string    `firestore:"-" json:"id"`
+	CompanyID     string    `firestore:"company_id" json:"company_id"`
+	Name          string    `firestore:"name" json:"name"`
+	EventType     string    `firestore:"event_type" json:"event_type"`
+	Threshold     int       `firestore:"threshold" json:"threshold"`
+	WindowMinutes int       `firestore:"window_minutes" json:"window_minutes"`
+	GroupBy       string    `firestore:"group_by" json:"group_by"`
+	Recipients    []string  `firestore:"recipients" json:"recipients"`
+	Disabled      bool      `firestore:"disabled" json:"disabled"`
+	CreatedAt     time.Time `firestore:"created_at" json:"created_at"`
+}
+
+// Window returns the period events are counted over
+func (r *FirebaseSecurityAlertRule) Window() time.Duration {
+	return time.Duration(r.WindowMinutes) * time.Minute
+}
+
+// groupKey returns the value events are grouped by for this rule
+func (r *FirebaseSecurityAlertRule) groupKey(event *SecurityEvent) (string, string) {
+	switch r.GroupBy {
+	case SecurityGroupIP:
+		return "ip", event.IP
+	case SecurityGroupUser:
+		return "user_id", event.UserID
+	}
+	return "", ""
+}
+
+// SecurityAlert is raised when an alert rule's threshold is reached
+type SecurityAlert struct {
+	ID        string    `json:"id" firestore:"-"`
+	CompanyID string    `json:"company_id" firestore:"company_id"`
+	RuleID    string    `json:"rule_id" firestore:"rule_id"`
+	RuleName  string    `json:"rule_name" firestore:"rule_name"`
+	EventType string    `json:"event_type" firestore:"event_type"`
+	GroupBy   string    `json:"group_by" firestore:"group_by"`
+	Key       string    `json:"key" firestore:"key"`
+	Count     int       `json:"count" firestore:"count"`
+	Notified  bool      `json:"notified" firestore:"notified"`
+	FiredAt   time.Time `json:"fired_at" firestore:"fired_at"`
+}
+
+// securityQueueSize is the number of events waiting to be recorded before
+// further events are dropped
+const securityQueueSize = 1024
+
+// AuthFailureIPRule raises an alert to recipients once one client IP fails to
+// authenticate threshold times within windowMinutes. Failed authentication
+// has no verified company, so the rule belongs to none.
+func AuthFailureIPRule(threshold, windowMinutes int, recipients []string) *FirebaseSecurityAlertRule {
+	return &FirebaseSecurityAlertRule{
+		ID:            "ip_auth_failures",
+		Name:          "Repeated authentication failures",
+		EventType:     SecurityAuthFailure,
+		Threshold:     threshold,
+		WindowMinutes: windowMinutes,
+		GroupBy:       SecurityGroupIP,
+		Recipients:    recipients,
+	}
+}
+
+// SecurityLog records security events and raises alerts through a notifier
+type SecurityLog struct {
+	client   *firestore.Client
+	notifier interfaces.Notifier
+	rules    []*FirebaseSecurityAlertRule
+	queue    chan securityTask
+}
+
+// securityTask is an event waiting to be recorded, or a flush waiting for
+// the events queued before it
+type securityTask struct {
+	event   SecurityEvent
+	flushed chan struct{}
+}
+
+// NewSecurityLog creates a new SecurityLog instance. Alerts are stored but
+// not delivered when notifier is nil. rules apply to events without a
+// company, which company rules never see.
+func NewSecurityLog(client *firestore.Client, notifier interfaces.Notifier, rules ...*FirebaseSecurityAlertRule) *SecurityLog {
+	return &SecurityLog{
+		client:   client,
+		notifier: notifier,
+		rules:    rules,
+		queue:    make(chan securityTask, securityQueueSize),
+	}
+}
+
+// Start records queued events in the background until ctx is cancelled
+func (s *SecurityLog) Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case task := <-s.queue:
+				if task.flushed != nil {
+					close(task.flushed)
+					continue
+				}
+				s.record(ctx, task.event)
+			}
+		}
+	}()
+}
+
+// Flush waits until the events queued so far are recorded. The log must have
+// been started.
+func (s *SecurityLog) Flush() {
+	flushed := make(chan struct{})
+	s.queue <- securityTask{flushed: flushed}
+	<-flushed
+}
+
+// Record queues a security event to be stored and checked against the alert
+// rules off the request path. Recording never fails or slows the request, so
+// events are dropped with a log line when the queue is full.
+func (s *SecurityLog) Record(ctx context.Context, event SecurityEvent) {
+	if event.RequestID == "" {
+		event.RequestID = requestInfo(ctx).RequestID
+	}
+	event.Timestamp = time.Now()
+
+	select {
+	case s.queue <- securityTask{event: event}:
+	default:
+		log.Printf("Dropping security event %s from %s: queue is full", event.Type, event.IP)
+	}
+}
+
+// record stores a security event and evaluates the alert rules of its
+// company, or the rules without a company
+func (s *SecurityLog) record(ctx context.Context, event SecurityEvent) {
+	if _, _, err := s.client.Collection("security_events").Add(ctx, event); err != nil {
+		log.Printf("Error recording security event %s: %v", event.Type, err)
+		return
+	}
+	s.evaluate(ctx, &event)
+}
+
+// List returns security events matching the filter, newest first
+func (s *SecurityLog) List(ctx context.Context, filter SecurityFilter) ([]SecurityEvent, error) {
+	query := s.client.Collection("security_events").Where("company_id", "==", filter.CompanyID)
+	if filter.Type != "" {
+		query = query.Where("type", "==", filter.Type)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip", "==", filter.IP)
+	}
+	if filter.UserID != "" {
+		query = query.Where("user_id", "==", filter.UserID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("timestamp", ">=", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("timestamp", "<=", filter.To)
+	}
+	query = query.OrderBy("timestamp", firestore.Desc)
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("Error getting security events: %v", err)
+		return nil, err
+	}
+
+	events := make([]SecurityEvent, 0, len(docs))
+	for _, doc := range docs {
+		var event SecurityEvent
+		if err := doc.DataTo(&event); err != nil {
+			log.Printf("Error converting security event document: %v", err)
+			continue
+		}
+		event.ID = doc.Ref.ID
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// ListAlerts returns the alerts raised for the company in the context, newest first
+func (s *SecurityLog) ListAlerts(ctx context.Context, limit int) ([]SecurityAlert, error) {
+	query := scopedQuery(ctx, s.client.Collection("security_alerts")).OrderBy("fired_at", firestore.Desc)
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("Error getting security alerts: %v", err)
+		return nil, err
+	}
+
+	alerts := make([]SecurityAlert, 0, len(docs))
+	for _, doc := range docs {
+		var alert SecurityAlert
+		if err := doc.DataTo(&alert); err != nil {
+			log.Printf("Error converting security alert document: %v", err)
+			continue
+		}
+		alert.ID = doc.Ref.ID
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, nil
+}
+
+// ListRules returns the security alert rules of the company in the context
+func (s *SecurityLog) ListRules(ctx context.Context) ([]*FirebaseSecurityAlertRule, error) {
+	docs, err := scopedQuery(ctx, s.client.Collection("security_alert_rules")).Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("Error getting security alert rules: %v", err)
+		return nil, err
+	}
+
+	rules := []*FirebaseSecurityAlertRule{}
+	for _, doc := range docs {
+		var rule FirebaseSecurityAlertRule
+		if err := doc.DataTo(&rule); err != nil {
+			log.Printf("Error converting security alert rule data: %v", err)
+			continue
+		}
+		rule.ID = doc.Ref.ID
+		rules = append(rules, &rule)
+	}
+
+	return rules, nil
+}
+
+// CreateRule stores a new security alert rule for the company in the context
+func (s *SecurityLog) CreateRule(ctx context.Context, rule *FirebaseSecurityAlertRule) (string, error) {
+	rule.CreatedAt = time.Now()
+
+	ref := s.client.Collection("security_alert_rules").NewDoc()
+	if err := createDocument(ctx, s.client, ref, rule); err != nil {
+		log.Printf("Error creating security alert rule: %v", err)
+		return "", err
+	}
+	rule.ID = ref.ID
+
+	return ref.ID, nil
+}
+
+// UpdateRule changes a security alert rule
+func (s *SecurityLog) UpdateRule(ctx context.Context, id string, rule *FirebaseSecurityAlertRule) error {
+	err := updateDocument(ctx, s.client, s.client.Collection("security_alert_rules").Doc(id), []firestore.Update{
+		{Path: "name", Value: rule.Name},
+		{Path: "event_type", Value: rule.EventType},
+		{Path: "threshold", Value: rule.Threshold},
+		{Path: "window_minutes", Value: rule.WindowMinutes},
+		{Path: "group_by", Value: rule.GroupBy},
+		{Path: "recipients", Value: rule.Recipients},
+		{Path: "disabled", Value: rule.Disabled},
+	})
+	if err != nil {
+		log.Printf("Error updating security alert rule: %v", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteRule removes a security alert rule
+func (s *SecurityLog) DeleteRule(ctx context.Context, id string) error {
+	err := deleteDocument(ctx, s.client, s.client.Collection("security_alert_rules").Doc(id))
+	if err != nil {
+		log.Printf("Error deleting security alert rule: %v", err)
+		return err
+	}
+	return nil
+}
+
+// evaluate raises an alert for every rule whose threshold the event reaches.
+// A rule alerts at most once per window for the same group.
+func (s *SecurityLog) evaluate(ctx context.Context, event *SecurityEvent) {
+	rules, err := s.rulesFor(ctx, event)
+	if err != nil {
+		log.Printf("Error getting security alert rules: %v", err)
+		return
+	}
+
+	for _, rule := range rules {
+		if rule.Disabled || rule.Threshold <= 0 || rule.WindowMinutes <= 0 {
+			continue
+		}
+		if err := s.check(ctx, rule, event); err != nil {
+			log.Printf("Error evaluating security alert rule %s: %v", rule.ID, err)
+		}
+	}
+}
+
+// rulesFor returns the alert rules watching the event: the rules of its
+// company, or the rules without a company for events that have none
+func (s *SecurityLog) rulesFor(ctx context.Context, event *SecurityEvent) ([]*FirebaseSecurityAlertRule, error) {
+	rules := []*FirebaseSecurityAlertRule{}
+	if event.CompanyID == "" {
+		for _, rule := range s.rules {
+			if rule.EventType == event.Type {
+				rules = append(rules, rule)
+			}
+		}
+		return rules, nil
+	}
+
+	docs, err := s.client.Collection("security_alert_rules").
+		Where("company_id", "==", event.CompanyID).
+		Where("event_type", "==", event.Type).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		var rule FirebaseSecurityAlertRule
+		if err := doc.DataTo(&rule); err != nil {
+			log.Printf("Error converting security alert rule data: %v", err)
+			continue
+		}
+		rule.ID = doc.Ref.ID
+		rules = append(rules, &rule)
+	}
+	return rules, nil
+}
+
+// check counts the events of rule's group within its window and raises an
+// alert when the threshold is reached
+func (s *SecurityLog) check(ctx context.Context, rule *FirebaseSecurityAlertRule, event *SecurityEvent) error {
+	field, key := rule.groupKey(event)
+	if field != "" && key == "" {
+		return nil
+	}
+	since := event.Timestamp.Add(-rule.Window())
+
+	query := s.client.Collection("security_events").
+		Where("company_id", "==", event.CompanyID).
+		Where("type", "==", event.Type)
+	if field != "" {
+		query = query.Where(field, "==", key)
+	}
+	events, err := query.Where("timestamp", ">=", since).Limit(rule.Threshold).Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	if len(events) < rule.Threshold {
+		return nil
+	}
+
+	fired, err := s.client.Collection("security_alerts").
+		Where("company_id", "==", event.CompanyID).
+		Where("rule_id", "==", rule.ID).
+		Where("key", "==", key).
+		Where("fired_at", ">=", since).
+		Limit(1).Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	if len(fired) > 0 {
+		return nil
+	}
+
+	alert := SecurityAlert{
+		CompanyID: event.CompanyID,
+		RuleID:    rule.ID,
+		RuleName:  rule.Name,
+		EventType: rule.EventType,
+		GroupBy:   rule.GroupBy,
+		Key:       key,
+		Count:     len(events),
+		FiredAt:   time.Now(),
+	}
+	if s.notifier != nil && len(rule.Recipients) > 0 {
+		err := s.notifier.Notify(ctx, interfaces.Notification{
+			CompanyID:  event.CompanyID,
+			Recipients: rule.Recipients,
+			Subject:    "Security alert: " + alertName(rule),
+			Body:       alertBody(rule, &alert, event),
+		})
+		if err != nil {
+			log.Printf("Error delivering security alert %s: %v", rule.ID, err)
+		} else {
+			alert.Notified = true
+		}
+	}
+
+	_, _, err = s.client.Collection("security_alerts").Add(ctx, alert)
+	return err
+}
+
+func alertName(rule *FirebaseSecurityAlertRule) string {
+	if rule.Name != "" {
+		return rule.Name
+	}
+	return strings.ReplaceAll(rule.EventType, "_", " ")
+}
+
+func alertBody(rule *FirebaseSecurityAlertRule, alert *SecurityAlert, event *SecurityEvent) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s events within %d minutes", alert.Count, rule.EventType, rule.WindowMinutes)
+	if alert.Key != "" {
+		fmt.Fprintf(&b, " from %s %s", rule.GroupBy, alert.Key)
+	}
+	b.WriteString(".\n\nLatest event:\n")
+	fmt.Fprintf(&b, "Time: %s\n", event.Timestamp.Format(time.RFC3339))
+	fmt.Fprintf(&b, "IP: %s\n", event.IP)
+	if event.UserID != "" {
+		fmt.Fprintf(&b, "User: %s\n", event.UserID)
+	}
+	fmt.Fprintf(&b, "Request: %s %s\n", event.Method, event.Path)
+	if event.Detail != "" {
+		fmt.Fprintf(&b, "Detail: %s\n", event.Detail)
+	}
+	return b.String()
+}
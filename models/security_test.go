@@ -0,0 +1,116 @@
+package models
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/nirshpaa/godam-backend/interfaces"
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+)
+
+// recordingNotifier keeps the notifications it is asked to deliver
+type recordingNotifier struct {
+	mu   sync.Mutex
+	sent []interfaces.Notification
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, notification interfaces.Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent = append(n.sent, notification)
+	return nil
+}
+
+// startSecurityLog returns a started security log stopped at the end of the test
+func startSecurityLog(t *testing.T, security *SecurityLog) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	security.Start(ctx)
+}
+
+func TestSecurityCompanyRule(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := context.Background()
+	notifier := &recordingNotifier{}
+	security := NewSecurityLog(client, notifier)
+	startSecurityLog(t, security)
+
+	_, err := security.CreateRule(WithCompany(ctx, "company-a"), &FirebaseSecurityAlertRule{
+		Name:          "Denied permissions",
+		EventType:     SecurityPermissionDenied,
+		Threshold:     2,
+		WindowMinutes: 10,
+		GroupBy:       SecurityGroupUser,
+		Recipients:    []string{"admin@a.test"},
+	})
+	if err != nil {
+		t.Fatalf("CreateRule: %v", err)
+	}
+
+	for _, event := range []SecurityEvent{
+		{Type: SecurityPermissionDenied, CompanyID: "company-a", UserID: "user-1", IP: "10.0.0.1"},
+		{Type: SecurityPermissionDenied, CompanyID: "company-b", UserID: "user-1", IP: "10.0.0.1"},
+		{Type: SecurityPermissionDenied, CompanyID: "company-a", UserID: "user-2", IP: "10.0.0.1"},
+		{Type: SecurityPermissionDenied, CompanyID: "company-a", UserID: "user-1", IP: "10.0.0.2"},
+		{Type: SecurityPermissionDenied, CompanyID: "company-a", UserID: "user-1", IP: "10.0.0.3"},
+	} {
+		security.Record(ctx, event)
+	}
+	security.Flush()
+
+	alerts, err := security.ListAlerts(WithCompany(ctx, "company-a"), 0)
+	if err != nil {
+		t.Fatalf("ListAlerts: %v", err)
+	}
+	if len(alerts) != 1 || alerts[0].Key != "user-1" || !alerts[0].Notified {
+		t.Fatalf("alerts = %+v, want one notified alert for user-1", alerts)
+	}
+	if len(notifier.sent) != 1 || notifier.sent[0].Recipients[0] != "admin@a.test" {
+		t.Fatalf("notifications = %+v, want one to the rule's recipients", notifier.sent)
+	}
+}
+
+func TestSecurityIPRuleWithoutCompany(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := context.Background()
+	notifier := &recordingNotifier{}
+	security := NewSecurityLog(client, notifier, AuthFailureIPRule(3, 10, []string{"ops@godam.test"}))
+	startSecurityLog(t, security)
+
+	// A company rule on the same event type never sees events without a company
+	_, err := security.CreateRule(WithCompany(ctx, "company-a"), &FirebaseSecurityAlertRule{
+		EventType:     SecurityAuthFailure,
+		Threshold:     1,
+		WindowMinutes: 10,
+		GroupBy:       SecurityGroupIP,
+		Recipients:    []string{"admin@a.test"},
+	})
+	if err != nil {
+		t.Fatalf("CreateRule: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		security.Record(ctx, SecurityEvent{Type: SecurityAuthFailure, IP: "10.0.0.1"})
+	}
+	security.Record(ctx, SecurityEvent{Type: SecurityAuthFailure, IP: "10.0.0.2"})
+	security.Flush()
+
+	alerts, err := security.client.Collection("security_alerts").Documents(ctx).GetAll()
+	if err != nil {
+		t.Fatalf("getting alerts: %v", err)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want one for 10.0.0.1", len(alerts))
+	}
+	var alert SecurityAlert
+	if err := alerts[0].DataTo(&alert); err != nil {
+		t.Fatalf("reading alert: %v", err)
+	}
+	if alert.CompanyID != "" || alert.RuleID != "ip_auth_failures" || alert.Key != "10.0.0.1" || alert.Count != 3 {
+		t.Fatalf("alert = %+v, want the IP rule for 10.0.0.1 without a company", alert)
+	}
+	if len(notifier.sent) != 1 || notifier.sent[0].Recipients[0] != "ops@godam.test" {
+		t.Fatalf("notifications = %+v, want one to the IP rule's recipients", notifier.sent)
+	}
+}
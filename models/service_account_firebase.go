@@ -0,0 +1,338 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// APIKeyPrefix starts every API key so they are easy to recognize in logs and secret scanners
+const APIKeyPrefix = "gdm_"
+
+// apiKeyUsageInterval limits how often the last used time of a key is written
+const apiKeyUsageInterval = time.Minute
+
+// ErrInvalidAPIKey is returned for unknown, malformed, revoked or disabled API keys
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// FirebaseServiceAccount is a non-human caller of a company, such as a POS
+// terminal or an ERP connector. Its scopes are RBAC permissions.
+type FirebaseServiceAccount struct {
+	ID        string    `firestore:"-" json:"id"`
+	CompanyID string    `firestore:"company_id" json:"company_id"`
+	Name      string    `firestore:"name" json:"name"`
+	Scopes    []string  `firestore:"scopes" json:"scopes"`
+	Disabled  bool      `firestore:"disabled" json:"disabled"`
+	CreatedBy string    `firestore:"created_by" json:"created_by"`
+	CreatedAt time.Time `firestore:"created_at" json:"created_at"`
+}
+
+// FirebaseAPIKey is a credential of a service account. The key ID is the
+// visible prefix of the key; only a hash of the secret is stored.
+type FirebaseAPIKey struct {
+	ID               string     `firestore:"-" json:"id"`
+	CompanyID        string     `firestore:"company_id" json:"company_id"`
+	ServiceAccountID string     `firestore:"service_account_id" json:"service_account_id"`
+	Prefix           string     `firestore:"prefix" json:"prefix"`
+	SecretHash       string     `firestore:"secret_hash" json:"-"`
+	CreatedAt        time.Time  `firestore:"created_at" json:"created_at"`
+	LastUsedAt       *time.Time `firestore:"last_used_at" json:"last_used_at"`
+	RevokedAt        *time.Time `firestore:"revoked_at" json:"revoked_at"`
+}
+
+// ServiceAccountFirebase represents the Firestore client for service accounts and their API keys
+type ServiceAccountFirebase struct {
+	client *firestore.Client
+}
+
+// NewServiceAccountFirebase creates a new ServiceAccountFirebase instance
+func NewServiceAccountFirebase(client *firestore.Client) *ServiceAccountFirebase {
+	return &ServiceAccountFirebase{
+		client: client,
+	}
+}
+
+// List retrieves the service accounts of the company in the context
+func (s *ServiceAccountFirebase) List(ctx context.Context) ([]*FirebaseServiceAccount, error) {
+	docs, err := scopedQuery(ctx, s.client.Collection("service_accounts")).Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("Error getting service accounts: %v", err)
+		return nil, err
+	}
+
+	accounts := []*FirebaseServiceAccount{}
+	for _, doc := range docs {
+		var account FirebaseServiceAccount
+		if err := doc.DataTo(&account); err != nil {
+			log.Printf("Error converting service account data: %v", err)
+			continue
+		}
+		account.ID = doc.Ref.ID
+		accounts = append(accounts, &account)
+	}
+
+	return accounts, nil
+}
+
+// Get retrieves a single service account by ID
+func (s *ServiceAccountFirebase) Get(ctx context.Context, id string) (*FirebaseServiceAccount, error) {
+	doc, err := s.client.Collection("service_accounts").Doc(id).Get(ctx)
+	if err != nil {
+		log.Printf("Error getting service account: %v", err)
+		return nil, err
+	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
+
+	var account FirebaseServiceAccount
+	if err := doc.DataTo(&account); err != nil {
+		log.Printf("Error converting service account data: %v", err)
+		return nil, err
+	}
+	account.ID = doc.Ref.ID
+	recordVersion(ctx, doc.UpdateTime)
+
+	return &account, nil
+}
+
+// Create creates a new service account for the company in the context
+func (s *ServiceAccountFirebase) Create(ctx context.Context, account *FirebaseServiceAccount) (string, error) {
+	account.CreatedBy = ActorFromContext(ctx)
+	account.CreatedAt = time.Now()
+
+	ref := s.client.Collection("service_accounts").NewDoc()
+	if err := createDocument(ctx, s.client, ref, account); err != nil {
+		log.Printf("Error creating service account: %v", err)
+		return "", err
+	}
+	account.ID = ref.ID
+
+	return ref.ID, nil
+}
+
+// Update changes the name, scopes or disabled flag of a service account
+func (s *ServiceAccountFirebase) Update(ctx context.Context, id string, account *FirebaseServiceAccount) error {
+	err := updateDocument(ctx, s.client, s.client.Collection("service_accounts").Doc(id), []firestore.Update{
+		{Path: "name", Value: account.Name},
+		{Path: "scopes", Value: account.Scopes},
+		{Path: "disabled", Value: account.Disabled},
+	})
+	if err != nil {
+		log.Printf("Error updating service account: %v", err)
+		return err
+	}
+	return nil
+}
+
+// Delete revokes every key of a service account and removes it in one
+// transaction. The expected version in the context applies to the account.
+func (s *ServiceAccountFirebase) Delete(ctx context.Context, id string) error {
+	var revoked []*firestore.DocumentSnapshot
+	err := deleteDocumentWith(ctx, s.client, s.client.Collection("service_accounts").Doc(id), func(tx *firestore.Transaction) error {
+		query := scopedQuery(ctx, s.client.Collection("api_keys")).Where("service_account_id", "==", id)
+		keys, err := tx.Documents(query).GetAll()
+		if err != nil {
+			return err
+		}
+
+		revoked = nil
+		now := time.Now()
+		for _, key := range keys {
+			if key.Data()["revoked_at"] != nil {
+				continue
+			}
+			if err := tx.Update(key.Ref, []firestore.Update{{Path: "revoked_at", Value: now}}); err != nil {
+				return err
+			}
+			revoked = append(revoked, key)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error deleting service account: %v", err)
+		return err
+	}
+
+	for _, key := range revoked {
+		auditUpdated(ctx, s.client, key.Ref, key.Data())
+	}
+	return nil
+}
+
+// ListKeys retrieves the API keys of a service account of the company in the context
+func (s *ServiceAccountFirebase) ListKeys(ctx context.Context, accountID string) ([]*FirebaseAPIKey, error) {
+	docs, err := scopedQuery(ctx, s.client.Collection("api_keys")).Where("service_account_id", "==", accountID).Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("Error getting API keys: %v", err)
+		return nil, err
+	}
+
+	keys := []*FirebaseAPIKey{}
+	for _, doc := range docs {
+		var key FirebaseAPIKey
+		if err := doc.DataTo(&key); err != nil {
+			log.Printf("Error converting API key data: %v", err)
+			continue
+		}
+		key.ID = doc.Ref.ID
+		keys = append(keys, &key)
+	}
+
+	return keys, nil
+}
+
+// CreateKey issues a new API key for a service account and returns the full
+// key. The secret is not stored and cannot be shown again.
+func (s *ServiceAccountFirebase) CreateKey(ctx context.Context, accountID string) (*FirebaseAPIKey, string, error) {
+	account, err := s.Get(ctx, accountID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	prefixBytes := make([]byte, 5)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return nil, "", err
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return nil, "", err
+	}
+	prefix := APIKeyPrefix + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(prefixBytes))
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+
+	key := &FirebaseAPIKey{
+		ID:               prefix,
+		CompanyID:        account.CompanyID,
+		ServiceAccountID: account.ID,
+		Prefix:           prefix,
+		SecretHash:       hashAPIKeySecret(secret),
+		CreatedAt:        time.Now(),
+	}
+	if err := createDocument(ctx, s.client, s.client.Collection("api_keys").Doc(prefix), key); err != nil {
+		log.Printf("Error creating API key: %v", err)
+		return nil, "", err
+	}
+
+	return key, prefix + "." + secret, nil
+}
+
+// RotateKey issues a new API key for a service account and revokes its other keys
+func (s *ServiceAccountFirebase) RotateKey(ctx context.Context, accountID string) (*FirebaseAPIKey, string, error) {
+	key, secret, err := s.CreateKey(ctx, accountID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	keys, err := s.ListKeys(ctx, accountID)
+	if err != nil {
+		return nil, "", err
+	}
+	// The expected version in the context is the account's, not its keys'
+	ctx = WithExpectedVersion(ctx, "")
+	for _, existing := range keys {
+		if existing.ID == key.ID || existing.RevokedAt != nil {
+			continue
+		}
+		if err := s.RevokeKey(ctx, accountID, existing.ID); err != nil {
+			return nil, "", err
+		}
+	}
+
+	return key, secret, nil
+}
+
+// RevokeKey revokes an API key of a service account
+func (s *ServiceAccountFirebase) RevokeKey(ctx context.Context, accountID, keyID string) error {
+	ref := s.client.Collection("api_keys").Doc(keyID)
+	err := writeDocument(ctx, s.client, ref, AuditUpdate, func(tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		if owner, _ := doc.Data()["service_account_id"].(string); owner != accountID {
+			return ErrNotFound
+		}
+		return tx.Update(ref, []firestore.Update{{Path: "revoked_at", Value: time.Now()}})
+	})
+	if err != nil {
+		log.Printf("Error revoking API key: %v", err)
+		return err
+	}
+	return nil
+}
+
+// UsageDue reports whether the key has not been seen within the usage interval
+func (k *FirebaseAPIKey) UsageDue() bool {
+	return k.LastUsedAt == nil || time.Since(*k.LastUsedAt) > apiKeyUsageInterval
+}
+
+// Authenticate checks a full API key and returns its service account and the
+// key as it was before this use. The last used time of the key is refreshed
+// at most once per minute.
+func (s *ServiceAccountFirebase) Authenticate(ctx context.Context, apiKey string) (*FirebaseServiceAccount, *FirebaseAPIKey, error) {
+	parts := strings.SplitN(apiKey, ".", 2)
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], APIKeyPrefix) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	keyDoc, err := s.client.Collection("api_keys").Doc(parts[0]).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var key FirebaseAPIKey
+	if err := keyDoc.DataTo(&key); err != nil {
+		return nil, nil, err
+	}
+	key.ID = keyDoc.Ref.ID
+	if key.RevokedAt != nil || subtle.ConstantTimeCompare([]byte(key.SecretHash), []byte(hashAPIKeySecret(parts[1]))) != 1 {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	accountDoc, err := s.client.Collection("service_accounts").Doc(key.ServiceAccountID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var account FirebaseServiceAccount
+	if err := accountDoc.DataTo(&account); err != nil {
+		return nil, nil, err
+	}
+	account.ID = accountDoc.Ref.ID
+	if account.Disabled || account.CompanyID != key.CompanyID {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	// Usage tracking is bookkeeping, so it bypasses versioning and the audit log
+	if key.UsageDue() {
+		if _, err := keyDoc.Ref.Update(ctx, []firestore.Update{{Path: "last_used_at", Value: time.Now()}}); err != nil {
+			log.Printf("Error recording API key usage: %v", err)
+		}
+	}
+
+	return &account, &key, nil
+}
+
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+)
+
+func TestServiceAccountKeys(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := WithActor(WithCompany(context.Background(), "company-a"), "owner-1")
+	accounts := NewServiceAccountFirebase(client)
+
+	id, err := accounts.Create(ctx, &FirebaseServiceAccount{Name: "POS", Scopes: []string{"products:read"}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	_, first, err := accounts.CreateKey(ctx, id)
+	if err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+
+	account, key, err := accounts.Authenticate(context.Background(), first)
+	if err != nil || account.ID != id || key.CompanyID != "company-a" {
+		t.Fatalf("Authenticate = %+v, %+v, %v", account, key, err)
+	}
+	if _, _, err := accounts.Authenticate(context.Background(), key.ID+".wrong"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Authenticate with a wrong secret = %v, want ErrInvalidAPIKey", err)
+	}
+
+	// Rotating under the account's version revokes the other keys
+	versioned, recorder := WithVersionRecorder(ctx)
+	if _, err := accounts.Get(versioned, id); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	_, second, err := accounts.RotateKey(WithExpectedVersion(ctx, recorder.Version), id)
+	if err != nil {
+		t.Fatalf("RotateKey: %v", err)
+	}
+	if _, _, err := accounts.Authenticate(context.Background(), first); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Authenticate with a rotated key = %v, want ErrInvalidAPIKey", err)
+	}
+	if _, _, err := accounts.Authenticate(context.Background(), second); err != nil {
+		t.Errorf("Authenticate with the new key = %v", err)
+	}
+}
+
+func TestServiceAccountDelete(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := WithActor(WithCompany(context.Background(), "company-a"), "owner-1")
+	accounts := NewServiceAccountFirebase(client)
+
+	id, err := accounts.Create(ctx, &FirebaseServiceAccount{Name: "ERP", Scopes: []string{"products:read"}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	var apiKeys []string
+	for i := 0; i < 2; i++ {
+		_, apiKey, err := accounts.CreateKey(ctx, id)
+		if err != nil {
+			t.Fatalf("CreateKey: %v", err)
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	// A stale version leaves the account and its keys untouched
+	var mismatch *VersionMismatchError
+	if err := accounts.Delete(WithExpectedVersion(ctx, `"1"`), id); !errors.As(err, &mismatch) {
+		t.Fatalf("Delete with a stale version = %v, want a version mismatch", err)
+	}
+	for _, apiKey := range apiKeys {
+		if _, _, err := accounts.Authenticate(context.Background(), apiKey); err != nil {
+			t.Errorf("key revoked by a rejected delete: %v", err)
+		}
+	}
+
+	versioned, recorder := WithVersionRecorder(ctx)
+	if _, err := accounts.Get(versioned, id); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := accounts.Delete(WithExpectedVersion(ctx, recorder.Version), id); err != nil {
+		t.Fatalf("Delete with the account's version: %v", err)
+	}
+	for _, apiKey := range apiKeys {
+		if _, _, err := accounts.Authenticate(context.Background(), apiKey); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("Authenticate after delete = %v, want ErrInvalidAPIKey", err)
+		}
+	}
+	keys, err := accounts.ListKeys(ctx, id)
+	if err != nil {
+		t.Fatalf("ListKeys: %v", err)
+	}
+	for _, key := range keys {
+		if key.RevokedAt == nil {
+			t.Errorf("key %s was not revoked", key.ID)
+		}
+	}
+	if _, err := accounts.Get(ctx, id); err == nil {
+		t.Error("service account still exists")
+	}
+}
@@ -0,0 +1,296 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/nirshpaa/godam-backend/libraries/planogram"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FirebasePlanogram lists the products a shelf should hold. It is stored
+// under the ID of its shelf.
+type FirebasePlanogram struct {
+	ShelveID  string           `firestore:"-" json:"shelve_id"`
+	CompanyID string           `firestore:"company_id" json:"company_id"`
+	Slots     []planogram.Slot `firestore:"slots" json:"slots"`
+	UpdatedBy string           `firestore:"updated_by" json:"updated_by"`
+	UpdatedAt time.Time        `firestore:"updated_at" json:"updated_at"`
+}
+
+// FirebaseShelfAudit is the outcome of counting the products on a shelf
+// photo. The photo is split into columns by rows tiles and each tile
+// recognized on its own, so Detected counts tiles rather than units.
+type FirebaseShelfAudit struct {
+	ID           string           `firestore:"-" json:"id"`
+	CompanyID    string           `firestore:"company_id" json:"company_id"`
+	ShelveID     string           `firestore:"shelve_id" json:"shelve_id"`
+	ImageFile    string           `firestore:"image_file" json:"image_file"`
+	Columns      int              `firestore:"columns" json:"columns"`
+	Rows         int              `firestore:"rows" json:"rows"`
+	Tiles        int              `firestore:"tiles" json:"tiles"`
+	Recognized   int              `firestore:"recognized" json:"recognized"`
+	Planogram    bool             `firestore:"planogram" json:"planogram"`
+	Lines        []planogram.Line `firestore:"lines" json:"lines"`
+	StockCountID string           `firestore:"stock_count_id" json:"stock_count_id,omitempty"`
+	CreatedBy    string           `firestore:"created_by" json:"created_by"`
+	CreatedAt    time.Time        `firestore:"created_at" json:"created_at"`
+}
+
+// Discrepancies returns the lines of the audit that have an issue
+func (a *FirebaseShelfAudit) Discrepancies() []planogram.Line {
+	return planogram.Discrepancies(a.Lines)
+}
+
+// ShelfAuditFirebase stores planograms and shelf audits
+type ShelfAuditFirebase struct {
+	client *firestore.Client
+}
+
+// NewShelfAuditFirebase creates a new ShelfAuditFirebase instance
+func NewShelfAuditFirebase(client *firestore.Client) *ShelfAuditFirebase {
+	return &ShelfAuditFirebase{
+		client: client,
+	}
+}
+
+// GetPlanogram retrieves the planogram of a shelf, nil when it has none
+func (s *ShelfAuditFirebase) GetPlanogram(ctx context.Context, shelveID string) (*FirebasePlanogram, error) {
+	doc, err := s.client.Collection("planograms").Doc(shelveID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		log.Printf("Error getting planogram: %v", err)
+		return nil, err
+	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
+
+	var plan FirebasePlanogram
+	if err := doc.DataTo(&plan); err != nil {
+		return nil, fmt.Errorf("failed to parse planogram: %v", err)
+	}
+	plan.ShelveID = doc.Ref.ID
+	recordVersion(ctx, doc.UpdateTime)
+	return &plan, nil
+}
+
+// SavePlanogram creates or replaces the planogram of a shelf
+func (s *ShelfAuditFirebase) SavePlanogram(ctx context.Context, plan *FirebasePlanogram) error {
+	plan.UpdatedBy = ActorFromContext(ctx)
+	plan.UpdatedAt = time.Now()
+	if plan.Slots == nil {
+		plan.Slots = []planogram.Slot{}
+	}
+
+	ref := s.client.Collection("planograms").Doc(plan.ShelveID)
+	_, err := ref.Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		err = createDocument(ctx, s.client, ref, plan)
+	} else if err == nil {
+		err = setDocument(ctx, s.client, ref, plan)
+	}
+	if err != nil {
+		log.Printf("Error saving planogram: %v", err)
+		return err
+	}
+	return nil
+}
+
+// shelfSources are the records that put stock on shelves and take it off
+var shelfSources = []struct {
+	collection, details, draftStatus string
+	sign                             float64
+}{
+	{"receives", "receive_details", ReceiveDraft, 1},
+	{"deliveries", "delivery_details", "", -1},
+}
+
+// ShelfStock returns the stock a shelf should hold by product code: what
+// receives put on it less what deliveries took from it. Draft receives are
+// left out until they are reviewed. Only the records listing the shelf in
+// shelve_ids are read.
+func (s *ShelfAuditFirebase) ShelfStock(ctx context.Context, shelveID string) (map[string]float64, error) {
+	byProduct := map[string]float64{}
+	for _, source := range shelfSources {
+		query := s.client.Collection(source.collection).Where("shelve_ids", "array-contains", shelveID)
+		docs, err := scopedDocuments(ctx, source.collection, query)
+		if err != nil {
+			log.Printf("Error getting %s: %v", source.collection, err)
+			return nil, err
+		}
+		for _, doc := range docs {
+			data := doc.Data()
+			if IsTrashed(doc) || (source.draftStatus != "" && data["status"] == source.draftStatus) {
+				continue
+			}
+			details, _ := data[source.details].([]interface{})
+			for _, detail := range details {
+				line, ok := detail.(map[string]interface{})
+				if !ok || line["shelve_id"] != shelveID {
+					continue
+				}
+				productID, _ := line["product_id"].(string)
+				if productID != "" {
+					byProduct[productID] += source.sign * toFloat64(line["qty"])
+				}
+			}
+		}
+	}
+	return s.byProductCode(ctx, byProduct)
+}
+
+// byProductCode rekeys quantities by product code. Details refer to products
+// by document ID, or by code in records written before drafts resolved it.
+func (s *ShelfAuditFirebase) byProductCode(ctx context.Context, byProduct map[string]float64) (map[string]float64, error) {
+	stock := make(map[string]float64, len(byProduct))
+	var refs []*firestore.DocumentRef
+	for id, qty := range byProduct {
+		if strings.Contains(id, "/") {
+			stock[id] += qty
+			continue
+		}
+		refs = append(refs, s.client.Collection("products").Doc(id))
+	}
+	if len(refs) == 0 {
+		return stock, nil
+	}
+
+	docs, err := s.client.GetAll(ctx, refs)
+	if err != nil {
+		log.Printf("Error getting products: %v", err)
+		return nil, err
+	}
+	for i, doc := range docs {
+		key := refs[i].ID
+		if doc.Exists() && ownedByContextCompany(ctx, doc) {
+			if code, _ := doc.Data()["code"].(string); code != "" {
+				key = code
+			}
+		}
+		stock[key] += byProduct[refs[i].ID]
+	}
+	return stock, nil
+}
+
+// IndexShelves sets shelve_ids on the receives and deliveries of every
+// company, for records written before it was kept. It returns how many
+// records it changed.
+func (s *ShelfAuditFirebase) IndexShelves(ctx context.Context) (int, error) {
+	changed := 0
+	for _, source := range shelfSources {
+		docs, err := s.client.Collection(source.collection).Documents(ctx).GetAll()
+		if err != nil {
+			return changed, fmt.Errorf("failed to list %s: %w", source.collection, err)
+		}
+		for _, doc := range docs {
+			details, _ := doc.Data()[source.details].([]interface{})
+			shelves := distinctShelves(len(details), func(i int) string {
+				line, _ := details[i].(map[string]interface{})
+				shelveID, _ := line["shelve_id"].(string)
+				return shelveID
+			})
+			if current, ok := doc.Data()["shelve_ids"].([]interface{}); ok && sameShelves(current, shelves) {
+				continue
+			}
+			if _, err := doc.Ref.Update(ctx, []firestore.Update{{Path: "shelve_ids", Value: shelves}}); err != nil {
+				return changed, fmt.Errorf("failed to index %s/%s: %w", source.collection, doc.Ref.ID, err)
+			}
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+// distinctShelves returns the sorted, distinct shelves of n details
+func distinctShelves(n int, shelve func(i int) string) []string {
+	seen := map[string]bool{}
+	shelves := []string{}
+	for i := 0; i < n; i++ {
+		if id := shelve(i); id != "" && !seen[id] {
+			seen[id] = true
+			shelves = append(shelves, id)
+		}
+	}
+	sort.Strings(shelves)
+	return shelves
+}
+
+func sameShelves(stored []interface{}, shelves []string) bool {
+	if len(stored) != len(shelves) {
+		return false
+	}
+	for i, id := range stored {
+		if id != shelves[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ListAudits retrieves the audits of a shelf, newest first
+func (s *ShelfAuditFirebase) ListAudits(ctx context.Context, shelveID string) ([]*FirebaseShelfAudit, error) {
+	docs, err := scopedQuery(ctx, s.client.Collection("shelf_audits")).
+		Where("shelve_id", "==", shelveID).
+		Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("Error getting shelf audits: %v", err)
+		return nil, err
+	}
+
+	audits := []*FirebaseShelfAudit{}
+	for _, doc := range docs {
+		var audit FirebaseShelfAudit
+		if err := doc.DataTo(&audit); err != nil {
+			log.Printf("Error converting shelf audit data: %v", err)
+			continue
+		}
+		audit.ID = doc.Ref.ID
+		audits = append(audits, &audit)
+	}
+	sort.Slice(audits, func(i, j int) bool { return audits[i].CreatedAt.After(audits[j].CreatedAt) })
+	return audits, nil
+}
+
+// GetAudit retrieves a shelf audit by ID
+func (s *ShelfAuditFirebase) GetAudit(ctx context.Context, id string) (*FirebaseShelfAudit, error) {
+	doc, err := s.client.Collection("shelf_audits").Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
+
+	var audit FirebaseShelfAudit
+	if err := doc.DataTo(&audit); err != nil {
+		return nil, fmt.Errorf("failed to parse shelf audit: %v", err)
+	}
+	audit.ID = doc.Ref.ID
+	return &audit, nil
+}
+
+// CreateAudit stores a shelf audit
+func (s *ShelfAuditFirebase) CreateAudit(ctx context.Context, audit *FirebaseShelfAudit) error {
+	audit.CreatedBy = ActorFromContext(ctx)
+	audit.CreatedAt = time.Now()
+
+	ref := s.client.Collection("shelf_audits").NewDoc()
+	if err := createDocument(ctx, s.client, ref, audit); err != nil {
+		log.Printf("Error creating shelf audit: %v", err)
+		return err
+	}
+	audit.ID = ref.ID
+	return nil
+}
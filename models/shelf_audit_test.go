@@ -0,0 +1,130 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+)
+
+func TestShelfStock(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := WithCompany(context.Background(), "company-a")
+	products, _ := NewProductFirebase(client)
+	teaID, err := products.Create(ctx, &FirebaseProduct{Code: "TEA", Name: "Tea"}, nil)
+	if err != nil {
+		t.Fatalf("creating product: %v", err)
+	}
+
+	receives := NewReceiveFirebase(client)
+	for _, receive := range []*FirebaseReceive{
+		{Code: "R1", ReceiveDetails: []FirebaseReceiveDetail{
+			{ProductID: teaID, Qty: 5, ShelveID: "shelf-1"},
+			{ProductID: teaID, Qty: 7, ShelveID: "shelf-2"},
+		}},
+		// Written before product IDs were resolved
+		{Code: "R2", ReceiveDetails: []FirebaseReceiveDetail{{ProductID: "COFFEE", Qty: 3, ShelveID: "shelf-1"}}},
+		{Code: "R3", Status: ReceiveDraft, ReceiveDetails: []FirebaseReceiveDetail{{ProductID: teaID, Qty: 9, ShelveID: "shelf-1"}}},
+	} {
+		if _, err := receives.Create(ctx, receive); err != nil {
+			t.Fatalf("creating receive: %v", err)
+		}
+	}
+	if _, err := NewDeliveryFirebase(client).Create(ctx, &FirebaseDelivery{Code: "D1", DeliveryDetails: []FirebaseDeliveryDetail{
+		{ProductID: teaID, Qty: 2, ShelveID: "shelf-1"},
+	}}); err != nil {
+		t.Fatalf("creating delivery: %v", err)
+	}
+	if _, err := receives.Create(WithCompany(context.Background(), "company-b"), &FirebaseReceive{Code: "R4", ReceiveDetails: []FirebaseReceiveDetail{
+		{ProductID: teaID, Qty: 100, ShelveID: "shelf-1"},
+	}}); err != nil {
+		t.Fatalf("creating receive: %v", err)
+	}
+
+	// A receive from before shelve_ids was kept is found once indexed
+	legacy := map[string]interface{}{
+		"code":            "R0",
+		"company_id":      "company-a",
+		"receive_details": []interface{}{map[string]interface{}{"product_id": teaID, "qty": 1, "shelve_id": "shelf-1"}},
+	}
+	if _, err := client.Collection("receives").Doc("legacy").Set(ctx, legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	audits := NewShelfAuditFirebase(client)
+	check := func(want map[string]float64) {
+		t.Helper()
+		stock, err := audits.ShelfStock(ctx, "shelf-1")
+		if err != nil {
+			t.Fatalf("ShelfStock: %v", err)
+		}
+		if len(stock) != len(want) {
+			t.Errorf("ShelfStock = %v, want %v", stock, want)
+		}
+		for code, qty := range want {
+			if stock[code] != qty {
+				t.Errorf("ShelfStock[%s] = %v, want %v", code, stock[code], qty)
+			}
+		}
+	}
+	check(map[string]float64{"TEA": 3, "COFFEE": 3})
+
+	changed, err := audits.IndexShelves(ctx)
+	if err != nil || changed != 1 {
+		t.Fatalf("IndexShelves = %d, %v, want the legacy receive updated", changed, err)
+	}
+	check(map[string]float64{"TEA": 4, "COFFEE": 3})
+	if changed, err := audits.IndexShelves(ctx); err != nil || changed != 0 {
+		t.Errorf("IndexShelves again = %d, %v, want nothing to change", changed, err)
+	}
+}
+
+func TestStockCountFromAuditOnce(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := WithCompany(context.Background(), "company-a")
+	audits := NewShelfAuditFirebase(client)
+	audit := &FirebaseShelfAudit{ShelveID: "shelf-1"}
+	if err := audits.CreateAudit(ctx, audit); err != nil {
+		t.Fatalf("CreateAudit: %v", err)
+	}
+	counts := NewStockCountFirebase(client, nil)
+
+	var wg sync.WaitGroup
+	created := make([]*FirebaseStockCount, 5)
+	errs := make([]error, len(created))
+	for i := range created {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			created[i] = &FirebaseStockCount{ShelveID: "shelf-1", AuditID: audit.ID}
+			errs[i] = counts.Create(ctx, created[i])
+		}(i)
+	}
+	wg.Wait()
+
+	var seeded *FirebaseStockCount
+	for i, err := range errs {
+		switch {
+		case err == nil && seeded == nil:
+			seeded = created[i]
+		case err == nil:
+			t.Fatal("an audit seeded two stock counts")
+		case !errors.Is(err, ErrAuditSeeded):
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	if seeded == nil {
+		t.Fatal("no stock count was seeded")
+	}
+	stored, err := audits.GetAudit(ctx, audit.ID)
+	if err != nil || stored.StockCountID != seeded.ID {
+		t.Errorf("audit = %+v, %v, want it linked to %s", stored, err, seeded.ID)
+	}
+
+	other := WithCompany(context.Background(), "company-b")
+	if err := counts.Create(other, &FirebaseStockCount{AuditID: audit.ID}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("seeding from another company's audit = %v, want ErrNotFound", err)
+	}
+}
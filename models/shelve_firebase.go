@@ -9,8 +9,9 @@ import (
 
 // ShelveFirebaseModel represents a shelve in the system for Firebase
 type ShelveFirebaseModel struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	CompanyID string `json:"company_id"`
 }
 
 // ShelveFirebase represents the Firestore client for shelve
@@ -28,13 +29,17 @@ func NewShelveFirebase(client *firestore.Client) *ShelveFirebase {
 // List retrieves all shelve records
 func (s *ShelveFirebase) List(ctx context.Context) ([]*ShelveFirebaseModel, error) {
 	var shelves []*ShelveFirebaseModel
-	docs, err := s.client.Collection("shelves").Documents(ctx).GetAll()
+	docs, err := scopedQuery(ctx, s.client.Collection("shelves")).Documents(ctx).GetAll()
 	if err != nil {
 		log.Printf("Error getting shelves: %v", err)
 		return nil, err
 	}
 
 	for _, doc := range docs {
+		if IsTrashed(doc) {
+			continue
+		}
+
 		var shelve ShelveFirebaseModel
 		if err := doc.DataTo(&shelve); err != nil {
 			log.Printf("Error converting shelve data: %v", err)
@@ -54,6 +59,9 @@ func (s *ShelveFirebase) Get(ctx context.Context, id string) (*ShelveFirebaseMod
 		log.Printf("Error getting shelve: %v", err)
 		return nil, err
 	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
 
 	var shelve ShelveFirebaseModel
 	if err := doc.DataTo(&shelve); err != nil {
@@ -61,6 +69,7 @@ func (s *ShelveFirebase) Get(ctx context.Context, id string) (*ShelveFirebaseMod
 		return nil, err
 	}
 	shelve.ID = doc.Ref.ID
+	recordVersion(ctx, doc.UpdateTime)
 
 	return &shelve, nil
 }
@@ -68,7 +77,7 @@ func (s *ShelveFirebase) Get(ctx context.Context, id string) (*ShelveFirebaseMod
 // Create creates a new shelve
 func (s *ShelveFirebase) Create(ctx context.Context, shelve *ShelveFirebaseModel) (string, error) {
 	docRef := s.client.Collection("shelves").NewDoc()
-	_, err := docRef.Set(ctx, shelve)
+	err := createDocument(ctx, s.client, docRef, shelve)
 	if err != nil {
 		log.Printf("Error creating shelve: %v", err)
 		return "", err
@@ -79,7 +88,7 @@ func (s *ShelveFirebase) Create(ctx context.Context, shelve *ShelveFirebaseModel
 // Update updates an existing shelve
 func (s *ShelveFirebase) Update(ctx context.Context, id string, shelve *ShelveFirebaseModel) error {
 	docRef := s.client.Collection("shelves").Doc(id)
-	_, err := docRef.Set(ctx, shelve)
+	err := setDocument(ctx, s.client, docRef, shelve)
 	if err != nil {
 		log.Printf("Error updating shelve: %v", err)
 		return err
@@ -90,7 +99,7 @@ func (s *ShelveFirebase) Update(ctx context.Context, id string, shelve *ShelveFi
 // Delete removes a shelve
 func (s *ShelveFirebase) Delete(ctx context.Context, id string) error {
 	docRef := s.client.Collection("shelves").Doc(id)
-	_, err := docRef.Delete(ctx)
+	err := trashDocument(ctx, s.client, docRef)
 	if err != nil {
 		log.Printf("Error deleting shelve: %v", err)
 		return err
@@ -101,7 +110,7 @@ func (s *ShelveFirebase) Delete(ctx context.Context, id string) error {
 // FindByCompany retrieves all shelves for a specific company
 func (s *ShelveFirebase) FindByCompany(ctx context.Context, companyID string) ([]*ShelveFirebaseModel, error) {
 	var shelves []*ShelveFirebaseModel
-	query := s.client.Collection("shelves").Where("company_id", "==", companyID)
+	query := scopedQuery(ctx, s.client.Collection("shelves")).Where("CompanyID", "==", companyID)
 	docs, err := query.Documents(ctx).GetAll()
 	if err != nil {
 		log.Printf("Error getting shelves: %v", err)
@@ -109,6 +118,10 @@ func (s *ShelveFirebase) FindByCompany(ctx context.Context, companyID string) ([
 	}
 
 	for _, doc := range docs {
+		if IsTrashed(doc) {
+			continue
+		}
+
 		var shelve ShelveFirebaseModel
 		if err := doc.DataTo(&shelve); err != nil {
 			log.Printf("Error converting shelve data: %v", err)
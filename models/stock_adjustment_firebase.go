@@ -0,0 +1,182 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+// ErrInsufficientStock is returned when an adjustment would take stock below zero
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// FirebaseStockAdjustment corrects the stock of a product, for example after
+// a count or for damaged goods. Its value is the quantity at purchase price;
+// adjustments matching an approval rule are posted once approved.
+type FirebaseStockAdjustment struct {
+	ID             string     `firestore:"-" json:"id"`
+	CompanyID      string     `firestore:"company_id" json:"company_id"`
+	BranchID       string     `firestore:"branch_id" json:"branch_id"`
+	ProductCode    string     `firestore:"product_code" json:"product_code"`
+	Quantity       float64    `firestore:"quantity" json:"quantity"`
+	Value          float64    `firestore:"value" json:"value"`
+	Reason         string     `firestore:"reason" json:"reason"`
+	ApprovalStatus string     `firestore:"approval_status" json:"approval_status"`
+	PostedAt       *time.Time `firestore:"posted_at" json:"posted_at"`
+	CreatedBy      string     `firestore:"created_by" json:"created_by"`
+	CreatedAt      time.Time  `firestore:"created_at" json:"created_at"`
+}
+
+// StockAdjustmentFirebase represents the Firestore client for stock adjustments
+type StockAdjustmentFirebase struct {
+	client    *firestore.Client
+	products  *ProductFirebase
+	approvals *ApprovalFirebase
+}
+
+// NewStockAdjustmentFirebase creates a new StockAdjustmentFirebase instance
+func NewStockAdjustmentFirebase(client *firestore.Client, products *ProductFirebase, approvals *ApprovalFirebase) *StockAdjustmentFirebase {
+	return &StockAdjustmentFirebase{
+		client:    client,
+		products:  products,
+		approvals: approvals,
+	}
+}
+
+// List retrieves the stock adjustments of the company in the context
+func (s *StockAdjustmentFirebase) List(ctx context.Context) ([]*FirebaseStockAdjustment, error) {
+	docs, err := scopedDocuments(ctx, "stock_adjustments", s.client.Collection("stock_adjustments").Query)
+	if err != nil {
+		log.Printf("Error getting stock adjustments: %v", err)
+		return nil, err
+	}
+
+	adjustments := []*FirebaseStockAdjustment{}
+	for _, doc := range docs {
+		var adjustment FirebaseStockAdjustment
+		if err := doc.DataTo(&adjustment); err != nil {
+			log.Printf("Error converting stock adjustment data: %v", err)
+			continue
+		}
+		adjustment.ID = doc.Ref.ID
+		adjustments = append(adjustments, &adjustment)
+	}
+
+	return adjustments, nil
+}
+
+// Create records a stock adjustment. It is posted to the product right away
+// unless an approval rule applies, in which case it waits for approval.
+func (s *StockAdjustmentFirebase) Create(ctx context.Context, adjustment *FirebaseStockAdjustment) error {
+	product, err := s.products.Get(ctx, adjustment.ProductCode)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+
+	if product.MinimumStock+adjustment.Quantity < 0 {
+		return ErrInsufficientStock
+	}
+
+	adjustment.Value = math.Abs(adjustment.Quantity) * product.PurchasePrice
+	adjustment.ApprovalStatus = ""
+	adjustment.PostedAt = nil
+	adjustment.CreatedBy = ActorFromContext(ctx)
+	adjustment.CreatedAt = time.Now()
+
+	rule, value, err := s.approvals.Match(ctx, ApprovalStockAdjustments, map[string]float64{MetricValue: adjustment.Value})
+	if err != nil {
+		return err
+	}
+	if rule != nil {
+		adjustment.ApprovalStatus = DocumentPendingApproval
+	}
+
+	// The adjustment is stored in one transaction with its approval or the
+	// stock change posting it, so it is never left half done
+	ref := s.client.Collection("stock_adjustments").NewDoc()
+	var approvalRef *firestore.DocumentRef
+	err = createDocumentWith(ctx, s.client, ref, adjustment, func(tx *firestore.Transaction) error {
+		if rule != nil {
+			var err error
+			approvalRef, err = s.approvals.openIn(ctx, tx, ApprovalStockAdjustments, ref.ID, rule, value)
+			return err
+		}
+		if err := applyStock(ctx, tx, s.client, adjustment.BranchID, adjustment.ProductCode, adjustment.Quantity); err != nil {
+			return err
+		}
+		now := time.Now()
+		adjustment.PostedAt = &now
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error creating stock adjustment: %v", err)
+		return err
+	}
+	adjustment.ID = ref.ID
+	if approvalRef != nil {
+		auditCreated(ctx, s.client, approvalRef)
+	}
+	return nil
+}
+
+// postStockAdjustment applies an adjustment to its product's stock inside tx
+// and marks it posted. It reads the product before writing.
+func postStockAdjustment(ctx context.Context, tx *firestore.Transaction, client *firestore.Client, doc *firestore.DocumentSnapshot) error {
+	var adjustment FirebaseStockAdjustment
+	if err := doc.DataTo(&adjustment); err != nil {
+		return err
+	}
+	if adjustment.PostedAt != nil {
+		return nil
+	}
+
+	if err := applyStock(ctx, tx, client, adjustment.BranchID, adjustment.ProductCode, adjustment.Quantity); err != nil {
+		return err
+	}
+	return tx.Update(doc.Ref, []firestore.Update{{Path: "posted_at", Value: time.Now()}})
+}
+
+// applyStock changes the stock of a product by quantity inside tx, and its
+// stock in branchID when given. It reads the product before writing, so it
+// must run before the other writes of tx.
+func applyStock(ctx context.Context, tx *firestore.Transaction, client *firestore.Client, branchID, productCode string, quantity float64) error {
+	query := scopedQuery(ctx, client.Collection("products")).Where("code", "==", productCode).Limit(1)
+	products, err := tx.Documents(query).GetAll()
+	if err != nil {
+		return err
+	}
+	if len(products) == 0 {
+		return ErrNotFound
+	}
+
+	stock := toFloat64(products[0].Data()["minimum_stock"]) + quantity
+	if stock < 0 {
+		return ErrInsufficientStock
+	}
+
+	var branchStock *FirebaseStock
+	if branchID != "" {
+		companyID, _ := products[0].Data()["company_id"].(string)
+		if branchStock, err = stockIn(tx, client, companyID, branchID, productCode); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	if err := tx.Update(products[0].Ref, []firestore.Update{
+		{Path: "minimum_stock", Value: stock},
+		{Path: "updated_at", Value: now},
+	}); err != nil {
+		return err
+	}
+	if branchStock == nil {
+		return nil
+	}
+	branchStock.Quantity += quantity
+	branchStock.UpdatedAt = now
+	return tx.Set(client.Collection("stock").Doc(branchStock.ID), branchStock)
+}
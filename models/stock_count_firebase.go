@@ -0,0 +1,263 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Stock count statuses
+const (
+	StockCountOpen      = "open"
+	StockCountCompleted = "completed"
+)
+
+var (
+	// ErrStockCountClosed is returned when changing a stock count that was completed
+	ErrStockCountClosed = errors.New("stock count has already been completed")
+	// ErrAuditSeeded is returned when seeding a stock count from a shelf
+	// audit that already seeded one
+	ErrAuditSeeded = errors.New("shelf audit has already seeded a stock count")
+)
+
+// FirebaseStockCount is a physical count of products, such as the ones a
+// shelf audit found discrepancies for. Completing it adjusts the stock of
+// every counted product by the difference to what was expected.
+type FirebaseStockCount struct {
+	ID          string                   `firestore:"-" json:"id"`
+	CompanyID   string                   `firestore:"company_id" json:"company_id"`
+	BranchID    string                   `firestore:"branch_id" json:"branch_id"`
+	ShelveID    string                   `firestore:"shelve_id" json:"shelve_id"`
+	AuditID     string                   `firestore:"audit_id" json:"audit_id,omitempty"`
+	Status      string                   `firestore:"status" json:"status"`
+	Lines       []FirebaseStockCountLine `firestore:"lines" json:"lines"`
+	CreatedBy   string                   `firestore:"created_by" json:"created_by"`
+	CreatedAt   time.Time                `firestore:"created_at" json:"created_at"`
+	CompletedBy string                   `firestore:"completed_by" json:"completed_by,omitempty"`
+	CompletedAt *time.Time               `firestore:"completed_at" json:"completed_at,omitempty"`
+}
+
+// FirebaseStockCountLine is the count of one product. Counted stays nil until
+// the product is counted; lines left uncounted are not adjusted.
+type FirebaseStockCountLine struct {
+	ProductCode  string   `firestore:"product_code" json:"product_code"`
+	Expected     float64  `firestore:"expected" json:"expected"`
+	Detected     int      `firestore:"detected" json:"detected"`
+	Issues       []string `firestore:"issues" json:"issues"`
+	Counted      *float64 `firestore:"counted" json:"counted"`
+	AdjustmentID string   `firestore:"adjustment_id" json:"adjustment_id,omitempty"`
+	Error        string   `firestore:"error" json:"error,omitempty"`
+}
+
+// StockCountFirebase represents the Firestore client for stock counts
+type StockCountFirebase struct {
+	client      *firestore.Client
+	adjustments *StockAdjustmentFirebase
+}
+
+// NewStockCountFirebase creates a new StockCountFirebase instance
+func NewStockCountFirebase(client *firestore.Client, adjustments *StockAdjustmentFirebase) *StockCountFirebase {
+	return &StockCountFirebase{
+		client:      client,
+		adjustments: adjustments,
+	}
+}
+
+// List retrieves the stock counts of the company in the context, newest
+// first, optionally with one status
+func (s *StockCountFirebase) List(ctx context.Context, status string) ([]*FirebaseStockCount, error) {
+	query := s.client.Collection("stock_counts").Query
+	if status != "" {
+		query = query.Where("status", "==", status)
+	}
+	docs, err := scopedDocuments(ctx, "stock_counts", query)
+	if err != nil {
+		log.Printf("Error getting stock counts: %v", err)
+		return nil, err
+	}
+
+	counts := []*FirebaseStockCount{}
+	for _, doc := range docs {
+		var count FirebaseStockCount
+		if err := doc.DataTo(&count); err != nil {
+			log.Printf("Error converting stock count data: %v", err)
+			continue
+		}
+		count.ID = doc.Ref.ID
+		counts = append(counts, &count)
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].CreatedAt.After(counts[j].CreatedAt) })
+	return counts, nil
+}
+
+// Get retrieves a stock count by ID
+func (s *StockCountFirebase) Get(ctx context.Context, id string) (*FirebaseStockCount, error) {
+	doc, err := s.client.Collection("stock_counts").Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
+	recordVersion(ctx, doc.UpdateTime)
+	return stockCountFromDoc(doc)
+}
+
+// Create opens a stock count. A count seeded from a shelf audit is linked to
+// the audit in the same transaction; an audit seeds a single count, so
+// ErrAuditSeeded is returned when it already has one.
+func (s *StockCountFirebase) Create(ctx context.Context, count *FirebaseStockCount) error {
+	count.Status = StockCountOpen
+	count.CreatedBy = ActorFromContext(ctx)
+	count.CreatedAt = time.Now()
+	if count.Lines == nil {
+		count.Lines = []FirebaseStockCountLine{}
+	}
+
+	ref := s.client.Collection("stock_counts").NewDoc()
+	var linkAudit func(tx *firestore.Transaction) error
+	if count.AuditID != "" {
+		auditRef := s.client.Collection("shelf_audits").Doc(count.AuditID)
+		linkAudit = func(tx *firestore.Transaction) error {
+			doc, err := tx.Get(auditRef)
+			if status.Code(err) == codes.NotFound {
+				return ErrNotFound
+			}
+			if err != nil {
+				return err
+			}
+			if err := checkReadable(ctx, doc); err != nil {
+				return err
+			}
+			if seeded, _ := doc.Data()["stock_count_id"].(string); seeded != "" {
+				return ErrAuditSeeded
+			}
+			return tx.Update(auditRef, []firestore.Update{{Path: "stock_count_id", Value: ref.ID}})
+		}
+	}
+	if err := createDocumentWith(ctx, s.client, ref, count, linkAudit); err != nil {
+		log.Printf("Error creating stock count: %v", err)
+		return err
+	}
+	count.ID = ref.ID
+	return nil
+}
+
+// SetCounts records counted quantities by product code. Products not on the
+// count are added to it with nothing expected.
+func (s *StockCountFirebase) SetCounts(ctx context.Context, id string, counted map[string]float64) (*FirebaseStockCount, error) {
+	ref := s.client.Collection("stock_counts").Doc(id)
+	var count *FirebaseStockCount
+	err := writeDocument(ctx, s.client, ref, AuditUpdate, func(tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		if count, err = stockCountFromDoc(doc); err != nil {
+			return err
+		}
+		if count.Status != StockCountOpen {
+			return ErrStockCountClosed
+		}
+
+		products := make([]string, 0, len(counted))
+		for code := range counted {
+			products = append(products, code)
+		}
+		sort.Strings(products)
+		for _, code := range products {
+			qty := counted[code]
+			found := false
+			for i := range count.Lines {
+				if count.Lines[i].ProductCode == code {
+					count.Lines[i].Counted = &qty
+					found = true
+				}
+			}
+			if !found {
+				count.Lines = append(count.Lines, FirebaseStockCountLine{ProductCode: code, Issues: []string{}, Counted: &qty})
+			}
+		}
+		return tx.Update(ref, []firestore.Update{{Path: "lines", Value: count.Lines}})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return count, nil
+}
+
+// Complete closes an open stock count and adjusts the stock of every counted
+// product by the counted quantity less the expected one. Adjustments that
+// fail, for example for lack of stock, are recorded on their line.
+func (s *StockCountFirebase) Complete(ctx context.Context, id string) (*FirebaseStockCount, error) {
+	ref := s.client.Collection("stock_counts").Doc(id)
+	var count *FirebaseStockCount
+	err := writeDocument(ctx, s.client, ref, AuditUpdate, func(tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		if count, err = stockCountFromDoc(doc); err != nil {
+			return err
+		}
+		if count.Status != StockCountOpen {
+			return ErrStockCountClosed
+		}
+		now := time.Now()
+		count.Status = StockCountCompleted
+		count.CompletedBy = ActorFromContext(ctx)
+		count.CompletedAt = &now
+		return tx.Update(ref, []firestore.Update{
+			{Path: "status", Value: count.Status},
+			{Path: "completed_by", Value: count.CompletedBy},
+			{Path: "completed_at", Value: now},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range count.Lines {
+		line := &count.Lines[i]
+		if line.Counted == nil || *line.Counted == line.Expected {
+			continue
+		}
+		adjustment := &FirebaseStockAdjustment{
+			BranchID:    count.BranchID,
+			ProductCode: line.ProductCode,
+			Quantity:    *line.Counted - line.Expected,
+			Reason:      fmt.Sprintf("Stock count %s", count.ID),
+		}
+		if err := s.adjustments.Create(ctx, adjustment); err != nil {
+			log.Printf("Error adjusting %s for stock count %s: %v", line.ProductCode, count.ID, err)
+			line.Error = err.Error()
+			continue
+		}
+		line.AdjustmentID = adjustment.ID
+	}
+
+	if _, err := ref.Update(ctx, []firestore.Update{{Path: "lines", Value: count.Lines}}); err != nil {
+		log.Printf("Error recording adjustments of stock count %s: %v", count.ID, err)
+		return nil, err
+	}
+	return count, nil
+}
+
+func stockCountFromDoc(doc *firestore.DocumentSnapshot) (*FirebaseStockCount, error) {
+	var count FirebaseStockCount
+	if err := doc.DataTo(&count); err != nil {
+		return nil, fmt.Errorf("failed to parse stock count: %v", err)
+	}
+	count.ID = doc.Ref.ID
+	return &count, nil
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FirebaseStock is the stock of a product in one branch, the sum of the
+// posted stock adjustments made in that branch. Stock that was never
+// adjusted in a branch only shows on the product.
+type FirebaseStock struct {
+	ID          string    `firestore:"-" json:"id"`
+	CompanyID   string    `firestore:"company_id" json:"company_id"`
+	BranchID    string    `firestore:"branch_id" json:"branch_id"`
+	ProductCode string    `firestore:"product_code" json:"product_code"`
+	Quantity    float64   `firestore:"quantity" json:"quantity"`
+	UpdatedAt   time.Time `firestore:"updated_at" json:"updated_at"`
+}
+
+// StockFirebase represents the Firestore client for branch stock
+type StockFirebase struct {
+	client *firestore.Client
+}
+
+// NewStockFirebase creates a new StockFirebase instance
+func NewStockFirebase(client *firestore.Client) *StockFirebase {
+	return &StockFirebase{
+		client: client,
+	}
+}
+
+// StockID returns the document ID of the stock of a product in a branch
+func StockID(companyID, branchID, productCode string) string {
+	return companyID + "_" + branchID + "_" + productCode
+}
+
+// List retrieves the branch stock of the company and branches in the
+// context, optionally of one product
+func (s *StockFirebase) List(ctx context.Context, productCode string) ([]*FirebaseStock, error) {
+	query := s.client.Collection("stock").Query
+	if productCode != "" {
+		query = query.Where("product_code", "==", productCode)
+	}
+	docs, err := scopedDocuments(ctx, "stock", query)
+	if err != nil {
+		log.Printf("Error getting stock: %v", err)
+		return nil, err
+	}
+
+	stock := []*FirebaseStock{}
+	for _, doc := range docs {
+		var line FirebaseStock
+		if err := doc.DataTo(&line); err != nil {
+			log.Printf("Error converting stock data: %v", err)
+			continue
+		}
+		line.ID = doc.Ref.ID
+		stock = append(stock, &line)
+	}
+	sort.Slice(stock, func(i, j int) bool {
+		if stock[i].ProductCode != stock[j].ProductCode {
+			return stock[i].ProductCode < stock[j].ProductCode
+		}
+		return stock[i].BranchID < stock[j].BranchID
+	})
+	return stock, nil
+}
+
+// StockTotals sums branch stock by product code
+func StockTotals(stock []*FirebaseStock) map[string]float64 {
+	totals := map[string]float64{}
+	for _, line := range stock {
+		totals[line.ProductCode] += line.Quantity
+	}
+	return totals
+}
+
+// stockIn reads the stock of a product in a branch inside tx, starting from
+// nothing when the branch has none yet
+func stockIn(tx *firestore.Transaction, client *firestore.Client, companyID, branchID, productCode string) (*FirebaseStock, error) {
+	id := StockID(companyID, branchID, productCode)
+	doc, err := tx.Get(client.Collection("stock").Doc(id))
+	if status.Code(err) == codes.NotFound {
+		return &FirebaseStock{ID: id, CompanyID: companyID, BranchID: branchID, ProductCode: productCode}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stock FirebaseStock
+	if err := doc.DataTo(&stock); err != nil {
+		return nil, err
+	}
+	stock.ID = id
+	return &stock, nil
+}
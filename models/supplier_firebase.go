@@ -21,10 +21,11 @@ func NewSupplierFirebase(client *firestore.Client) *SupplierFirebase {
 
 // Supplier represents a supplier in the system
 type Supplier struct {
-	ID      string `json:"id"`
-	Code    string `json:"code"`
-	Name    string `json:"name"`
-	Address string `json:"address"`
+	ID        string `json:"id"`
+	Code      string `json:"code"`
+	Name      string `json:"name"`
+	Address   string `json:"address"`
+	CompanyID string `json:"company_id"`
 }
 
 // List returns all suppliers
@@ -32,7 +33,7 @@ func (s *SupplierFirebase) List(ctx context.Context) ([]Supplier, error) {
 	var suppliers []Supplier
 
 	// Get all suppliers from Firestore
-	iter := s.client.Collection("suppliers").Documents(ctx)
+	iter := scopedQuery(ctx, s.client.Collection("suppliers")).Documents(ctx)
 	docs, err := iter.GetAll()
 	if err != nil {
 		log.Printf("Error getting suppliers: %v", err)
@@ -41,6 +42,10 @@ func (s *SupplierFirebase) List(ctx context.Context) ([]Supplier, error) {
 
 	// Convert Firestore documents to Supplier structs
 	for _, doc := range docs {
+		if IsTrashed(doc) {
+			continue
+		}
+
 		var supplier Supplier
 		if err := doc.DataTo(&supplier); err != nil {
 			log.Printf("Error converting supplier document: %v", err)
@@ -60,6 +65,9 @@ func (s *SupplierFirebase) Get(ctx context.Context, id string) (*Supplier, error
 		log.Printf("Error getting supplier: %v", err)
 		return nil, err
 	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
 
 	var supplier Supplier
 	if err := doc.DataTo(&supplier); err != nil {
@@ -67,13 +75,15 @@ func (s *SupplierFirebase) Get(ctx context.Context, id string) (*Supplier, error
 		return nil, err
 	}
 	supplier.ID = doc.Ref.ID
+	recordVersion(ctx, doc.UpdateTime)
 
 	return &supplier, nil
 }
 
 // Create creates a new supplier
 func (s *SupplierFirebase) Create(ctx context.Context, supplier *Supplier) error {
-	docRef, _, err := s.client.Collection("suppliers").Add(ctx, supplier)
+	docRef := s.client.Collection("suppliers").NewDoc()
+	err := createDocument(ctx, s.client, docRef, supplier)
 	if err != nil {
 		log.Printf("Error creating supplier: %v", err)
 		return err
@@ -84,7 +94,7 @@ func (s *SupplierFirebase) Create(ctx context.Context, supplier *Supplier) error
 
 // Update updates an existing supplier
 func (s *SupplierFirebase) Update(ctx context.Context, supplier *Supplier) error {
-	_, err := s.client.Collection("suppliers").Doc(supplier.ID).Set(ctx, supplier)
+	err := setDocument(ctx, s.client, s.client.Collection("suppliers").Doc(supplier.ID), supplier)
 	if err != nil {
 		log.Printf("Error updating supplier: %v", err)
 		return err
@@ -94,7 +104,7 @@ func (s *SupplierFirebase) Update(ctx context.Context, supplier *Supplier) error
 
 // Delete deletes a supplier by ID
 func (s *SupplierFirebase) Delete(ctx context.Context, id string) error {
-	_, err := s.client.Collection("suppliers").Doc(id).Delete(ctx)
+	err := trashDocument(ctx, s.client, s.client.Collection("suppliers").Doc(id))
 	if err != nil {
 		log.Printf("Error deleting supplier: %v", err)
 		return err
@@ -0,0 +1,210 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+)
+
+var (
+	// ErrNotFound is returned for records that do not exist or belong to another company
+	ErrNotFound = errors.New("record not found")
+	// ErrCompanyMismatch is returned when a write would move a record to another company
+	ErrCompanyMismatch = errors.New("record belongs to a different company")
+)
+
+// companyFields names the field holding the owning company in each company
+// scoped collection. Collections written from untagged structs store Go field names.
+var companyFields = map[string]string{
+	"access":               "CompanyID",
+	"api_keys":             "company_id",
+	"approval_rules":       "company_id",
+	"approvals":            "company_id",
+	"branches":             "CompanyID",
+	"brands":               "CompanyID",
+	"customers":            "company_id",
+	"dataset_images":       "company_id",
+	"dataset_snapshots":    "company_id",
+	"deliveries":           "company_id",
+	"invitations":          "company_id",
+	"memberships":          "company_id",
+	"planograms":           "company_id",
+	"model_versions":       "company_id",
+	"delivery_returns":     "company_id",
+	"product_categories":   "CompanyID",
+	"product_fingerprints": "company_id",
+	"products":             "company_id",
+	"purchases":            "company_id",
+	"purchase_returns":     "company_id",
+	"receives":             "company_id",
+	"receive_returns":      "company_id",
+	"recognition_settings": "company_id",
+	"regions":              "CompanyID",
+	"roles":                "CompanyID",
+	"sales_orders":         "CompanyID",
+	"sales_order_returns":  "company_id",
+	"salesmen":             "CompanyID",
+	"scans":                "company_id",
+	"security_alert_rules": "company_id",
+	"security_alerts":      "company_id",
+	"security_events":      "company_id",
+	"service_accounts":     "company_id",
+	"shelves":              "CompanyID",
+	"shelf_audits":         "company_id",
+	"stock":                "company_id",
+	"stock_adjustments":    "company_id",
+	"stock_counts":         "company_id",
+	"suppliers":            "CompanyID",
+	"training_jobs":        "company_id",
+	"users":                "company_id",
+}
+
+// companyContextKey is a custom type for the company context key
+type companyContextKey struct{}
+
+// WithCompany returns a context that scopes every query and write to a company
+func WithCompany(ctx context.Context, companyID string) context.Context {
+	return context.WithValue(ctx, companyContextKey{}, companyID)
+}
+
+// CompanyFromContext returns the company the request is scoped to
+func CompanyFromContext(ctx context.Context) string {
+	companyID, _ := ctx.Value(companyContextKey{}).(string)
+	return companyID
+}
+
+// scopedQuery returns a query over collection limited to the company in the context
+func scopedQuery(ctx context.Context, collection *firestore.CollectionRef) firestore.Query {
+	return scopeQuery(ctx, collection.ID, collection.Query)
+}
+
+// scopeQuery limits a query on a collection to the company and branches in the context
+func scopeQuery(ctx context.Context, collection string, query firestore.Query) firestore.Query {
+	return scopeBranches(ctx, collection, scopeCompany(ctx, collection, query))
+}
+
+// scopeCompany limits a query on a collection to the company in the context
+func scopeCompany(ctx context.Context, collection string, query firestore.Query) firestore.Query {
+	if field, companyID := companyFields[collection], CompanyFromContext(ctx); field != "" && companyID != "" {
+		query = query.Where(field, "==", companyID)
+	}
+	return query
+}
+
+// scopedDocuments reads the documents of a query on collection limited to the
+// company and branches in the context. Callers limited to many branches get
+// the results of one query per chunk of branches, so ordering and limits
+// apply within each chunk.
+func scopedDocuments(ctx context.Context, collection string, query firestore.Query) ([]*firestore.DocumentSnapshot, error) {
+	var docs []*firestore.DocumentSnapshot
+	for _, query := range branchQueries(ctx, collection, scopeCompany(ctx, collection, query)) {
+		chunk, err := query.Documents(ctx).GetAll()
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, chunk...)
+	}
+	return docs, nil
+}
+
+// ownedByContextCompany reports whether a document belongs to the company in the context.
+// Companies own themselves; collections that are not company scoped are shared.
+func ownedByContextCompany(ctx context.Context, doc *firestore.DocumentSnapshot) bool {
+	companyID := CompanyFromContext(ctx)
+	if companyID == "" || !doc.Exists() {
+		return true
+	}
+
+	collection := doc.Ref.Parent.ID
+	if collection == "companies" {
+		return doc.Ref.ID == companyID
+	}
+
+	field := companyFields[collection]
+	if field == "" {
+		return true
+	}
+	owner, _ := doc.Data()[field].(string)
+	return owner == companyID
+}
+
+// checkReadable returns an error for documents that are trashed or belong to
+// another company or to a branch outside the context
+func checkReadable(ctx context.Context, doc *firestore.DocumentSnapshot) error {
+	if !ownedByContextCompany(ctx, doc) || !inContextBranches(ctx, doc) {
+		return ErrNotFound
+	}
+	if IsTrashed(doc) {
+		return ErrTrashed
+	}
+	return nil
+}
+
+// stampCompany fills in the company of new or replaced data from the context
+// and rejects data that names a different company
+func stampCompany(ctx context.Context, collection string, data interface{}) error {
+	companyID, field := CompanyFromContext(ctx), companyFields[collection]
+	if companyID == "" || field == "" {
+		return nil
+	}
+
+	if m, ok := data.(map[string]interface{}); ok {
+		owner, _ := m[field].(string)
+		if owner != "" && owner != companyID {
+			return ErrCompanyMismatch
+		}
+		m[field] = companyID
+		return nil
+	}
+
+	value := reflect.ValueOf(data)
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for i := 0; i < value.NumField(); i++ {
+		if storedFieldName(value.Type().Field(i)) != field || value.Field(i).Kind() != reflect.String {
+			continue
+		}
+		owner := value.Field(i).String()
+		if owner != "" && owner != companyID {
+			return ErrCompanyMismatch
+		}
+		if value.Field(i).CanSet() {
+			value.Field(i).SetString(companyID)
+		} else if owner == "" {
+			return ErrCompanyMismatch
+		}
+		return nil
+	}
+	return nil
+}
+
+// checkCompanyUpdates rejects field updates that move a document to another company
+func checkCompanyUpdates(ctx context.Context, collection string, updates []firestore.Update) error {
+	companyID, field := CompanyFromContext(ctx), companyFields[collection]
+	if companyID == "" || field == "" {
+		return nil
+	}
+
+	for _, update := range updates {
+		if update.Path == field && update.Value != companyID {
+			return ErrCompanyMismatch
+		}
+	}
+	return nil
+}
+
+// storedFieldName returns the Firestore field name of a struct field
+func storedFieldName(field reflect.StructField) string {
+	if tag := strings.Split(field.Tag.Get("firestore"), ",")[0]; tag != "" {
+		return tag
+	}
+	return field.Name
+}
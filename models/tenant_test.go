@@ -0,0 +1,532 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/firestore"
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+	"github.com/nirshpaa/godam-backend/types"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	client := firestoretest.New(t)
+	owner := WithCompany(context.Background(), "company-a")
+	other := WithCompany(context.Background(), "company-b")
+
+	products, _ := NewProductFirebase(client)
+	purchases := NewPurchaseFirebase(client)
+	customers := NewCustomerFirebase(client)
+
+	seedProduct(t, owner, products, &FirebaseProduct{Code: "P1", Name: "Tea"})
+	purchaseID, err := purchases.Create(owner, &FirebasePurchase{Code: "PO-1"})
+	if err != nil {
+		t.Fatalf("creating purchase: %v", err)
+	}
+	customerID, err := customers.Create(owner, &FirebaseCustomer{Name: "Ram"})
+	if err != nil {
+		t.Fatalf("creating customer: %v", err)
+	}
+
+	t.Run("products", func(t *testing.T) {
+		if list, err := products.List(other); err != nil || len(list) != 0 {
+			t.Errorf("List = %d records, %v, want none", len(list), err)
+		}
+		if _, err := products.Get(other, "P1"); err == nil {
+			t.Error("Get succeeded from another company")
+		}
+		if err := products.Update(other, "P1", &FirebaseProduct{Code: "P1", Name: "Changed"}, nil); err == nil {
+			t.Error("Update succeeded from another company")
+		}
+		if err := products.Delete(other, "P1"); err == nil {
+			t.Error("Delete succeeded from another company")
+		}
+		if product, err := products.Get(owner, "P1"); err != nil || product.Name != "Tea" {
+			t.Errorf("owner Get = %+v, %v, want the unchanged product", product, err)
+		}
+	})
+
+	t.Run("purchases", func(t *testing.T) {
+		if list, err := purchases.List(other); err != nil || len(list) != 0 {
+			t.Errorf("List = %d records, %v, want none", len(list), err)
+		}
+		if _, err := purchases.Get(other, purchaseID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get = %v, want ErrNotFound", err)
+		}
+		if err := purchases.Update(other, purchaseID, &FirebasePurchase{Code: "Changed"}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Update = %v, want ErrNotFound", err)
+		}
+		if err := purchases.Delete(other, purchaseID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Delete = %v, want ErrNotFound", err)
+		}
+		if purchase, err := purchases.Get(owner, purchaseID); err != nil || purchase.Code != "PO-1" {
+			t.Errorf("owner Get = %+v, %v, want the unchanged purchase", purchase, err)
+		}
+	})
+
+	t.Run("customers", func(t *testing.T) {
+		if list, err := customers.List(other); err != nil || len(list) != 0 {
+			t.Errorf("List = %d records, %v, want none", len(list), err)
+		}
+		if _, err := customers.Get(other, customerID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get = %v, want ErrNotFound", err)
+		}
+		if err := customers.Update(other, customerID, &FirebaseCustomer{Name: "Changed"}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Update = %v, want ErrNotFound", err)
+		}
+		if err := customers.Delete(other, customerID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Delete = %v, want ErrNotFound", err)
+		}
+		if customer, err := customers.Get(owner, customerID); err != nil || customer.Name != "Ram" {
+			t.Errorf("owner Get = %+v, %v, want the unchanged customer", customer, err)
+		}
+	})
+
+	for _, model := range tenantModels(client) {
+		model := model
+		t.Run(model.name, func(t *testing.T) {
+			id, err := model.create(owner)
+			if err != nil {
+				t.Fatalf("creating: %v", err)
+			}
+			if n, err := model.list(other); err != nil || n != 0 {
+				t.Errorf("List = %d records, %v, want none", n, err)
+			}
+			if _, err := model.get(other, id); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get = %v, want ErrNotFound", err)
+			}
+			if err := model.update(other, id); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Update = %v, want ErrNotFound", err)
+			}
+			if err := model.remove(other, id); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Delete = %v, want ErrNotFound", err)
+			}
+			if name, err := model.get(owner, id); err != nil || name != "Probe" {
+				t.Errorf("owner Get = %q, %v, want the unchanged record", name, err)
+			}
+		})
+	}
+
+	t.Run("approvals", func(t *testing.T) {
+		approvals := NewApprovalFirebase(client)
+		rule := &FirebaseApprovalRule{Levels: []ApprovalLevel{{Name: "manager", Permission: "approvals:decide"}}}
+		approval, err := approvals.Open(owner, ApprovalPurchases, purchaseID, rule, 100)
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+
+		if list, err := approvals.List(other, ""); err != nil || len(list) != 0 {
+			t.Errorf("List = %d records, %v, want none", len(list), err)
+		}
+		if _, err := approvals.Get(other, approval.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get = %v, want ErrNotFound", err)
+		}
+		grant := &Grant{Role: RoleOwner, Permissions: []string{"*"}}
+		if _, err := approvals.Decide(WithActor(other, "user-b"), approval.ID, grant, true, ""); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Decide = %v, want ErrNotFound", err)
+		}
+		if err := approvals.Cancel(other, ApprovalPurchases, purchaseID); err != nil {
+			t.Errorf("Cancel = %v, want nothing to cancel", err)
+		}
+		if got, err := approvals.Get(owner, approval.ID); err != nil || got.Status != ApprovalPending {
+			t.Errorf("owner Get = %+v, %v, want the approval still pending", got, err)
+		}
+	})
+
+	t.Run("shelf audits", func(t *testing.T) {
+		audits := NewShelfAuditFirebase(client)
+		audit := &FirebaseShelfAudit{ShelveID: "shelf-1"}
+		if err := audits.CreateAudit(owner, audit); err != nil {
+			t.Fatalf("CreateAudit: %v", err)
+		}
+		if err := audits.SavePlanogram(owner, &FirebasePlanogram{ShelveID: "shelf-1"}); err != nil {
+			t.Fatalf("SavePlanogram: %v", err)
+		}
+
+		if list, err := audits.ListAudits(other, "shelf-1"); err != nil || len(list) != 0 {
+			t.Errorf("ListAudits = %d records, %v, want none", len(list), err)
+		}
+		if _, err := audits.GetAudit(other, audit.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetAudit = %v, want ErrNotFound", err)
+		}
+		if _, err := audits.GetPlanogram(other, "shelf-1"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetPlanogram = %v, want ErrNotFound", err)
+		}
+		if err := audits.SavePlanogram(other, &FirebasePlanogram{ShelveID: "shelf-1"}); err == nil {
+			t.Error("SavePlanogram replaced another company's planogram")
+		}
+		if plan, err := audits.GetPlanogram(owner, "shelf-1"); err != nil || plan == nil || plan.CompanyID != "company-a" {
+			t.Errorf("owner GetPlanogram = %+v, %v, want the unchanged planogram", plan, err)
+		}
+	})
+
+	t.Run("moving records", func(t *testing.T) {
+		err := customers.Update(owner, customerID, &FirebaseCustomer{Name: "Ram", CompanyID: "company-b"})
+		if !errors.Is(err, ErrCompanyMismatch) {
+			t.Errorf("Update to another company = %v, want ErrCompanyMismatch", err)
+		}
+	})
+}
+
+// tenantModel is a company scoped model probed from another company. Records
+// are created named "Probe" and updated to another name.
+type tenantModel struct {
+	name   string
+	create func(ctx context.Context) (string, error)
+	list   func(ctx context.Context) (int, error)
+	get    func(ctx context.Context, id string) (string, error)
+	update func(ctx context.Context, id string) error
+	remove func(ctx context.Context, id string) error
+}
+
+func tenantModels(client *firestore.Client) []tenantModel {
+	salesOrders := NewSalesOrderFirebase(client)
+	receives := NewReceiveFirebase(client)
+	deliveries := NewDeliveryFirebase(client)
+	purchaseReturns := NewPurchaseReturnFirebase(client)
+	receiveReturns := NewReceiveReturnFirebase(client)
+	deliveryReturns := NewDeliveryReturnFirebase(client)
+	salesOrderReturns := NewSalesOrderReturnFirebase(client)
+	roles := NewRoleFirebase(client)
+	access := NewAccessFirebase(client)
+	salesmen := NewSalesmanFirebase(client)
+	categories := NewProductCategoryFirebase(client)
+	branches := NewBranchFirebase(client)
+	suppliers := NewSupplierFirebase(client)
+	regions := NewRegionFirebase(client)
+	shelves := NewShelveFirebase(client)
+
+	return []tenantModel{
+		{
+			name: "sales orders",
+			create: func(ctx context.Context) (string, error) {
+				order := types.SalesOrder{ID: "SO-1", Code: "Probe", CustomerID: "customer-1", CompanyID: CompanyFromContext(ctx),
+					SalesOrderDetails: []types.SalesOrderDetail{{ProductID: "P1", Quantity: 1}}}
+				return order.ID, salesOrders.Create(ctx, order)
+			},
+			list: func(ctx context.Context) (int, error) { list, err := salesOrders.List(ctx); return len(list), err },
+			get: func(ctx context.Context, id string) (string, error) {
+				order, err := salesOrders.GetByID(ctx, id)
+				if err != nil {
+					return "", err
+				}
+				return order.Code, nil
+			},
+			update: func(ctx context.Context, id string) error {
+				return salesOrders.Update(ctx, id, types.SalesOrder{ID: id, Code: "Changed", CustomerID: "customer-1"})
+			},
+			remove: func(ctx context.Context, id string) error { return salesOrders.Delete(ctx, id) },
+		},
+		{
+			name: "receives",
+			create: func(ctx context.Context) (string, error) {
+				return receives.Create(ctx, &FirebaseReceive{Code: "Probe"})
+			},
+			list: func(ctx context.Context) (int, error) { list, err := receives.List(ctx); return len(list), err },
+			get: func(ctx context.Context, id string) (string, error) {
+				receive, err := receives.Get(ctx, id)
+				if err != nil {
+					return "", err
+				}
+				return receive.Code, nil
+			},
+			update: func(ctx context.Context, id string) error {
+				return receives.Update(ctx, id, &FirebaseReceive{Code: "Changed"})
+			},
+			remove: func(ctx context.Context, id string) error { return receives.Delete(ctx, id) },
+		},
+		{
+			name: "deliveries",
+			create: func(ctx context.Context) (string, error) {
+				return deliveries.Create(ctx, &FirebaseDelivery{Code: "Probe"})
+			},
+			list: func(ctx context.Context) (int, error) { list, err := deliveries.List(ctx); return len(list), err },
+			get: func(ctx context.Context, id string) (string, error) {
+				delivery, err := deliveries.Get(ctx, id)
+				if err != nil {
+					return "", err
+				}
+				return delivery.Code, nil
+			},
+			update: func(ctx context.Context, id string) error {
+				return deliveries.Update(ctx, id, &FirebaseDelivery{Code: "Changed"})
+			},
+			remove: func(ctx context.Context, id string) error { return deliveries.Delete(ctx, id) },
+		},
+		{
+			name: "purchase returns",
+			create: func(ctx context.Context) (string, error) {
+				return purchaseReturns.Create(ctx, &FirebasePurchaseReturn{Code: "Probe"})
+			},
+			list: func(ctx context.Context) (int, error) { list, err := purchaseReturns.List(ctx); return len(list), err },
+			get: func(ctx context.Context, id string) (string, error) {
+				ret, err := purchaseReturns.Get(ctx, id)
+				if err != nil {
+					return "", err
+				}
+				return ret.Code, nil
+			},
+			update: func(ctx context.Context, id string) error {
+				return purchaseReturns.Update(ctx, id, &FirebasePurchaseReturn{Code: "Changed"})
+			},
+			remove: func(ctx context.Context, id string) error { return purchaseReturns.Delete(ctx, id) },
+		},
+		{
+			name: "receive returns",
+			create: func(ctx context.Context) (string, error) {
+				return receiveReturns.Create(ctx, &FirebaseReceiveReturn{Code: "Probe"})
+			},
+			list: func(ctx context.Context) (int, error) { list, err := receiveReturns.List(ctx); return len(list), err },
+			get: func(ctx context.Context, id string) (string, error) {
+				ret, err := receiveReturns.Get(ctx, id)
+				if err != nil {
+					return "", err
+				}
+				return ret.Code, nil
+			},
+			update: func(ctx context.Context, id string) error {
+				return receiveReturns.Update(ctx, id, &FirebaseReceiveReturn{Code: "Changed"})
+			},
+			remove: func(ctx context.Context, id string) error { return receiveReturns.Delete(ctx, id) },
+		},
+		{
+			name: "delivery returns",
+			create: func(ctx context.Context) (string, error) {
+				return deliveryReturns.Create(ctx, &FirebaseDeliveryReturn{Code: "Probe"})
+			},
+			list: func(ctx context.Context) (int, error) { list, err := deliveryReturns.List(ctx); return len(list), err },
+			get: func(ctx context.Context, id string) (string, error) {
+				ret, err := deliveryReturns.Get(ctx, id)
+				if err != nil {
+					return "", err
+				}
+				return ret.Code, nil
+			},
+			update: func(ctx context.Context, id string) error {
+				return deliveryReturns.Update(ctx, id, &FirebaseDeliveryReturn{Code: "Changed"})
+			},
+			remove: func(ctx context.Context, id string) error { return deliveryReturns.Delete(ctx, id) },
+		},
+		{
+			name: "sales order returns",
+			create: func(ctx context.Context) (string, error) {
+				return salesOrderReturns.Create(ctx, &FirebaseSalesOrderReturn{Code: "Probe"})
+			},
+			list: func(ctx context.Context) (int, error) {
+				list, err := salesOrderReturns.List(ctx)
+				return len(list), err
+			},
+			get: func(ctx context.Context, id string) (string, error) {
+				ret, err := salesOrderReturns.Get(ctx, id)
+				if err != nil {
+					return "", err
+				}
+				return ret.Code, nil
+			},
+			update: func(ctx context.Context, id string) error {
+				return salesOrderReturns.Update(ctx, id, &FirebaseSalesOrderReturn{Code: "Changed"})
+			},
+			remove: func(ctx context.Context, id string) error { return salesOrderReturns.Delete(ctx, id) },
+		},
+		{
+			name:   "roles",
+			create: func(ctx context.Context) (string, error) { return roles.Create(ctx, &RoleFirebaseModel{Name: "Probe"}) },
+			list:   func(ctx context.Context) (int, error) { list, err := roles.List(ctx); return len(list), err },
+			get: func(ctx context.Context, id string) (string, error) {
+				role, err := roles.Get(ctx, id)
+				if err != nil {
+					return "", err
+				}
+				return role.Name, nil
+			},
+			update: func(ctx context.Context, id string) error {
+				return roles.Update(ctx, id, &RoleFirebaseModel{Name: "Changed"})
+			},
+			remove: func(ctx context.Context, id string) error { return roles.Delete(ctx, id) },
+		},
+		{
+			name: "access",
+			create: func(ctx context.Context) (string, error) {
+				return access.Create(ctx, &AccessFirebaseModel{Name: "Probe"})
+			},
+			list: func(ctx context.Context) (int, error) { list, err := access.List(ctx); return len(list), err },
+			get: func(ctx context.Context, id string) (string, error) {
+				item, err := access.Get(ctx, id)
+				if err != nil {
+					return "", err
+				}
+				return item.Name, nil
+			},
+			update: func(ctx context.Context, id string) error {
+				return access.Update(ctx, id, &AccessFirebaseModel{Name: "Changed"})
+			},
+			remove: func(ctx context.Context, id string) error { return access.Delete(ctx, id) },
+		},
+		{
+			name: "salesmen",
+			create: func(ctx context.Context) (string, error) {
+				return salesmen.Create(ctx, &SalesmanFirebaseModel{Name: "Probe"})
+			},
+			list: func(ctx context.Context) (int, error) { list, err := salesmen.List(ctx); return len(list), err },
+			get: func(ctx context.Context, id string) (string, error) {
+				salesman, err := salesmen.Get(ctx, id)
+				if err != nil {
+					return "", err
+				}
+				return salesman.Name, nil
+			},
+			update: func(ctx context.Context, id string) error {
+				return salesmen.Update(ctx, id, &SalesmanFirebaseModel{Name: "Changed"})
+			},
+			remove: func(ctx context.Context, id string) error { return salesmen.Delete(ctx, id) },
+		},
+		{
+			name: "categories",
+			create: func(ctx context.Context) (string, error) {
+				category := &ProductCategoryFirebaseModel{Name: "Probe"}
+				err := categories.Create(ctx, category)
+				return category.ID, err
+			},
+			list: func(ctx context.Context) (int, error) { list, err := categories.List(ctx); return len(list), err },
+			get: func(ctx context.Context, id string) (string, error) {
+				category, err := categories.Get(ctx, id)
+				if err != nil {
+					return "", err
+				}
+				return category.Name, nil
+			},
+			update: func(ctx context.Context, id string) error {
+				return categories.Update(ctx, &ProductCategoryFirebaseModel{ID: id, Name: "Changed"})
+			},
+			remove: func(ctx context.Context, id string) error { return categories.Delete(ctx, id) },
+		},
+		{
+			name: "branches",
+			create: func(ctx context.Context) (string, error) {
+				return branches.Create(ctx, &BranchFirebaseModel{Name: "Probe"})
+			},
+			list: func(ctx context.Context) (int, error) { list, err := branches.List(ctx); return len(list), err },
+			get: func(ctx context.Context, id string) (string, error) {
+				branch, err := branches.Get(ctx, id)
+				if err != nil {
+					return "", err
+				}
+				return branch.Name, nil
+			},
+			update: func(ctx context.Context, id string) error {
+				return branches.Update(ctx, id, &BranchFirebaseModel{Name: "Changed"})
+			},
+			remove: func(ctx context.Context, id string) error { return branches.Delete(ctx, id) },
+		},
+		{
+			name: "suppliers",
+			create: func(ctx context.Context) (string, error) {
+				supplier := &Supplier{Name: "Probe"}
+				err := suppliers.Create(ctx, supplier)
+				return supplier.ID, err
+			},
+			list: func(ctx context.Context) (int, error) { list, err := suppliers.List(ctx); return len(list), err },
+			get: func(ctx context.Context, id string) (string, error) {
+				supplier, err := suppliers.Get(ctx, id)
+				if err != nil {
+					return "", err
+				}
+				return supplier.Name, nil
+			},
+			update: func(ctx context.Context, id string) error {
+				return suppliers.Update(ctx, &Supplier{ID: id, Name: "Changed"})
+			},
+			remove: func(ctx context.Context, id string) error { return suppliers.Delete(ctx, id) },
+		},
+		{
+			name: "regions",
+			create: func(ctx context.Context) (string, error) {
+				return regions.Create(ctx, &RegionFirebaseModel{Name: "Probe"})
+			},
+			list: func(ctx context.Context) (int, error) { list, err := regions.List(ctx); return len(list), err },
+			get: func(ctx context.Context, id string) (string, error) {
+				region, err := regions.Get(ctx, id)
+				if err != nil {
+					return "", err
+				}
+				return region.Name, nil
+			},
+			update: func(ctx context.Context, id string) error {
+				return regions.Update(ctx, id, &RegionFirebaseModel{Name: "Changed"})
+			},
+			remove: func(ctx context.Context, id string) error { return regions.Delete(ctx, id) },
+		},
+		{
+			name: "shelves",
+			create: func(ctx context.Context) (string, error) {
+				return shelves.Create(ctx, &ShelveFirebaseModel{Name: "Probe"})
+			},
+			list: func(ctx context.Context) (int, error) { list, err := shelves.List(ctx); return len(list), err },
+			get: func(ctx context.Context, id string) (string, error) {
+				shelve, err := shelves.Get(ctx, id)
+				if err != nil {
+					return "", err
+				}
+				return shelve.Name, nil
+			},
+			update: func(ctx context.Context, id string) error {
+				return shelves.Update(ctx, id, &ShelveFirebaseModel{Name: "Changed"})
+			},
+			remove: func(ctx context.Context, id string) error { return shelves.Delete(ctx, id) },
+		},
+	}
+}
+
+func TestAssignCompanies(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := context.Background()
+	legacy := map[string]map[string]interface{}{
+		"branches/main":     {"Name": "Main"},
+		"branches/shared":   {"Name": "Shared"},
+		"regions/north":     {"Name": "North"},
+		"shelves/shelf-1":   {"Name": "Shelf 1"},
+		"suppliers/acme":    {"Name": "Acme"},
+		"suppliers/unknown": {"Name": "Unknown"},
+		"purchases/po-1":    {"company_id": "company-a", "supplier_id": "acme", "branch_id": "shared"},
+		"memberships/m-1":   {"company_id": "company-b", "branches": []interface{}{"main", "shared"}},
+		"receives/r-1":      {"company_id": "company-b", "receive_details": []interface{}{map[string]interface{}{"shelve_id": "shelf-1"}}},
+		"suppliers/owned":   {"Name": "Owned", "CompanyID": "company-c"},
+	}
+	for path, data := range legacy {
+		if _, err := client.Doc(path).Set(ctx, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assigned, unassigned, err := AssignCompanies(ctx, client, "company-a")
+	if err != nil || assigned != 5 {
+		t.Fatalf("AssignCompanies = %d, %v, want 5 records assigned", assigned, err)
+	}
+	if len(unassigned) != 1 || unassigned[0] != "branches/shared" {
+		t.Errorf("unassigned = %v, want the branch two companies point at", unassigned)
+	}
+
+	want := map[string]string{
+		"branches/main":     "company-b",
+		"branches/shared":   "",
+		"regions/north":     "company-a",
+		"shelves/shelf-1":   "company-b",
+		"suppliers/acme":    "company-a",
+		"suppliers/unknown": "company-a",
+		"suppliers/owned":   "company-c",
+	}
+	for path, owner := range want {
+		doc, err := client.Doc(path).Get(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := doc.Data()["CompanyID"].(string); got != owner {
+			t.Errorf("%s owner = %q, want %q", path, got, owner)
+		}
+	}
+
+	if assigned, _, err := AssignCompanies(ctx, client, "company-a"); err != nil || assigned != 0 {
+		t.Errorf("AssignCompanies again = %d, %v, want nothing to assign", assigned, err)
+	}
+}
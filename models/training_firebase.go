@@ -0,0 +1,394 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Training job statuses
+const (
+	TrainingQueued    = "queued"
+	TrainingRunning   = "running"
+	TrainingSucceeded = "succeeded"
+	TrainingFailed    = "failed"
+	TrainingCancelled = "cancelled"
+)
+
+var (
+	// ErrTrainingInProgress is returned when a company already has a job queued or running
+	ErrTrainingInProgress = errors.New("a training job is already queued or running")
+	// ErrTrainingClosed is returned when changing a job that has finished
+	ErrTrainingClosed = errors.New("training job has already finished")
+	// ErrTrainingToken is returned for callbacks without the job's token
+	ErrTrainingToken = errors.New("invalid training callback token")
+)
+
+// FirebaseTrainingJob trains a recognition model on a dataset snapshot. The
+// training service reports progress through a callback authenticated by a
+// token issued for the current attempt.
+type FirebaseTrainingJob struct {
+	ID             string             `firestore:"-" json:"id"`
+	CompanyID      string             `firestore:"company_id" json:"company_id"`
+	SnapshotID     string             `firestore:"snapshot_id" json:"snapshot_id"`
+	DatasetVersion int                `firestore:"dataset_version" json:"dataset_version"`
+	Status         string             `firestore:"status" json:"status"`
+	Attempts       int                `firestore:"attempts" json:"attempts"`
+	MaxAttempts    int                `firestore:"max_attempts" json:"max_attempts"`
+	NextAttemptAt  time.Time          `firestore:"next_attempt_at" json:"next_attempt_at"`
+	Error          string             `firestore:"error" json:"error,omitempty"`
+	Metrics        map[string]float64 `firestore:"metrics" json:"metrics,omitempty"`
+	ModelVersionID string             `firestore:"model_version_id" json:"model_version_id,omitempty"`
+	TokenHash      string             `firestore:"token_hash" json:"-"`
+	RequestedBy    string             `firestore:"requested_by" json:"requested_by"`
+	CreatedAt      time.Time          `firestore:"created_at" json:"created_at"`
+	StartedAt      *time.Time         `firestore:"started_at" json:"started_at,omitempty"`
+	FinishedAt     *time.Time         `firestore:"finished_at" json:"finished_at,omitempty"`
+	UpdatedAt      time.Time          `firestore:"updated_at" json:"updated_at"`
+}
+
+// Finished reports whether the job reached a final status
+func (j *FirebaseTrainingJob) Finished() bool {
+	return j.Status == TrainingSucceeded || j.Status == TrainingFailed || j.Status == TrainingCancelled
+}
+
+// TrainingReport is what the training service reports about a running job.
+// A running report only updates the metrics.
+type TrainingReport struct {
+	Status        string
+	Metrics       map[string]float64
+	ModelEndpoint string
+	Error         string
+}
+
+// FirebaseModelVersion is a model produced by a training job and the CNN
+// endpoint serving it
+type FirebaseModelVersion struct {
+	ID             string             `firestore:"-" json:"id"`
+	CompanyID      string             `firestore:"company_id" json:"company_id"`
+	Version        int                `firestore:"version" json:"version"`
+	JobID          string             `firestore:"job_id" json:"job_id"`
+	DatasetVersion int                `firestore:"dataset_version" json:"dataset_version"`
+	Endpoint       string             `firestore:"endpoint" json:"endpoint"`
+	Metrics        map[string]float64 `firestore:"metrics" json:"metrics"`
+	CreatedAt      time.Time          `firestore:"created_at" json:"created_at"`
+}
+
+// TrainingFirebase stores training jobs and the model versions they produce.
+// Job progress is bookkeeping written by the worker and the training service
+// and bypasses versioning and the audit log.
+type TrainingFirebase struct {
+	client *firestore.Client
+}
+
+// NewTrainingFirebase creates a new TrainingFirebase instance
+func NewTrainingFirebase(client *firestore.Client) *TrainingFirebase {
+	return &TrainingFirebase{
+		client: client,
+	}
+}
+
+// ListJobs retrieves the training jobs of the company in the context, newest
+// first, optionally with one status
+func (t *TrainingFirebase) ListJobs(ctx context.Context, status string) ([]*FirebaseTrainingJob, error) {
+	query := scopedQuery(ctx, t.client.Collection("training_jobs"))
+	if status != "" {
+		query = query.Where("status", "==", status)
+	}
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("Error getting training jobs: %v", err)
+		return nil, err
+	}
+
+	jobs := jobsFromDocs(docs)
+	sortJobs(jobs)
+	return jobs, nil
+}
+
+// GetJob retrieves a training job by ID
+func (t *TrainingFirebase) GetJob(ctx context.Context, id string) (*FirebaseTrainingJob, error) {
+	doc, err := t.client.Collection("training_jobs").Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
+	return jobFromDoc(doc)
+}
+
+// ActiveJob returns the queued or running job of the company in the
+// context, nil when there is none
+func (t *TrainingFirebase) ActiveJob(ctx context.Context) (*FirebaseTrainingJob, error) {
+	docs, err := scopedQuery(ctx, t.client.Collection("training_jobs")).
+		Where("status", "in", []string{TrainingQueued, TrainingRunning}).
+		Limit(1).Documents(ctx).GetAll()
+	if err != nil || len(docs) == 0 {
+		return nil, err
+	}
+	return jobFromDoc(docs[0])
+}
+
+// CreateJob queues a training job for the company in the context. The check
+// for an active job and the create run in one transaction that also writes
+// the company's training lock, so concurrent requests queue one job.
+func (t *TrainingFirebase) CreateJob(ctx context.Context, job *FirebaseTrainingJob) error {
+	companyID := CompanyFromContext(ctx)
+	if companyID == "" {
+		return ErrCompanyMismatch
+	}
+
+	job.Status = TrainingQueued
+	job.Attempts = 0
+	job.RequestedBy = ActorFromContext(ctx)
+	job.CreatedAt = time.Now()
+	job.NextAttemptAt = job.CreatedAt
+	job.UpdatedAt = job.CreatedAt
+
+	ref := t.client.Collection("training_jobs").NewDoc()
+	lockRef := t.client.Collection("training_locks").Doc(companyID)
+	err := createDocumentWith(ctx, t.client, ref, job, func(tx *firestore.Transaction) error {
+		if _, err := tx.Get(lockRef); err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		active, err := tx.Documents(scopedQuery(ctx, t.client.Collection("training_jobs")).
+			Where("status", "in", []string{TrainingQueued, TrainingRunning}).
+			Limit(1)).GetAll()
+		if err != nil {
+			return err
+		}
+		if len(active) > 0 {
+			return ErrTrainingInProgress
+		}
+		return tx.Set(lockRef, map[string]interface{}{
+			"company_id": companyID,
+			"job_id":     ref.ID,
+			"updated_at": job.CreatedAt,
+		})
+	})
+	if err != nil {
+		log.Printf("Error creating training job: %v", err)
+		return err
+	}
+	job.ID = ref.ID
+	return nil
+}
+
+// DueJobs returns the queued jobs of every company whose next attempt is due
+func (t *TrainingFirebase) DueJobs(ctx context.Context, now time.Time) ([]*FirebaseTrainingJob, error) {
+	docs, err := t.client.Collection("training_jobs").
+		Where("status", "==", TrainingQueued).
+		Where("next_attempt_at", "<=", now).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	jobs := jobsFromDocs(docs)
+	sortJobs(jobs)
+	return jobs, nil
+}
+
+// StaleJobs returns the running jobs of every company started before the cutoff
+func (t *TrainingFirebase) StaleJobs(ctx context.Context, cutoff time.Time) ([]*FirebaseTrainingJob, error) {
+	docs, err := t.client.Collection("training_jobs").
+		Where("status", "==", TrainingRunning).
+		Where("started_at", "<", cutoff).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	return jobsFromDocs(docs), nil
+}
+
+// Transition moves a job from one of the given statuses, applying change to
+// it in a transaction. It returns ErrTrainingClosed when the job is in none of them.
+func (t *TrainingFirebase) Transition(ctx context.Context, id string, from []string, change func(job *FirebaseTrainingJob)) (*FirebaseTrainingJob, error) {
+	ref := t.client.Collection("training_jobs").Doc(id)
+	var job *FirebaseTrainingJob
+	err := t.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return ErrNotFound
+			}
+			return err
+		}
+		if err := checkReadable(ctx, doc); err != nil {
+			return err
+		}
+		job, err = jobFromDoc(doc)
+		if err != nil {
+			return err
+		}
+
+		allowed := false
+		for _, status := range from {
+			allowed = allowed || job.Status == status
+		}
+		if !allowed {
+			return ErrTrainingClosed
+		}
+
+		change(job)
+		job.UpdatedAt = time.Now()
+		return tx.Set(ref, job)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Authenticate returns the job a callback token belongs to
+func (t *TrainingFirebase) Authenticate(ctx context.Context, id, token string) (*FirebaseTrainingJob, error) {
+	doc, err := t.client.Collection("training_jobs").Doc(id).Get(ctx)
+	if err != nil {
+		return nil, ErrTrainingToken
+	}
+	job, err := jobFromDoc(doc)
+	if err != nil {
+		return nil, err
+	}
+	if token == "" || subtle.ConstantTimeCompare([]byte(hashTrainingToken(token)), []byte(job.TokenHash)) != 1 {
+		return nil, ErrTrainingToken
+	}
+	return job, nil
+}
+
+// ListModels retrieves the model versions of the company in the context, newest first
+func (t *TrainingFirebase) ListModels(ctx context.Context) ([]*FirebaseModelVersion, error) {
+	docs, err := scopedQuery(ctx, t.client.Collection("model_versions")).
+		OrderBy("version", firestore.Desc).
+		Documents(ctx).GetAll()
+	if err != nil {
+		log.Printf("Error getting model versions: %v", err)
+		return nil, err
+	}
+
+	models := []*FirebaseModelVersion{}
+	for _, doc := range docs {
+		var model FirebaseModelVersion
+		if err := doc.DataTo(&model); err != nil {
+			log.Printf("Error converting model version data: %v", err)
+			continue
+		}
+		model.ID = doc.Ref.ID
+		models = append(models, &model)
+	}
+	return models, nil
+}
+
+// GetModel retrieves a model version by ID
+func (t *TrainingFirebase) GetModel(ctx context.Context, id string) (*FirebaseModelVersion, error) {
+	doc, err := t.client.Collection("model_versions").Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
+
+	var model FirebaseModelVersion
+	if err := doc.DataTo(&model); err != nil {
+		return nil, fmt.Errorf("failed to parse model version: %v", err)
+	}
+	model.ID = doc.Ref.ID
+	return &model, nil
+}
+
+// CreateModel stores the next model version of the company in the context
+func (t *TrainingFirebase) CreateModel(ctx context.Context, model *FirebaseModelVersion) error {
+	companyID := CompanyFromContext(ctx)
+	if companyID == "" {
+		return ErrCompanyMismatch
+	}
+
+	counter := t.client.Collection("counters").Doc("model_versions_" + companyID)
+	err := t.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(counter)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		model.Version = 1
+		if err == nil {
+			model.Version = int(toFloat64(doc.Data()["next"]))
+		}
+		return tx.Set(counter, map[string]interface{}{"next": model.Version + 1})
+	})
+	if err != nil {
+		return err
+	}
+
+	model.CreatedAt = time.Now()
+	ref := t.client.Collection("model_versions").NewDoc()
+	if err := createDocument(ctx, t.client, ref, model); err != nil {
+		log.Printf("Error creating model version: %v", err)
+		return err
+	}
+	model.ID = ref.ID
+	return nil
+}
+
+func jobsFromDocs(docs []*firestore.DocumentSnapshot) []*FirebaseTrainingJob {
+	jobs := []*FirebaseTrainingJob{}
+	for _, doc := range docs {
+		job, err := jobFromDoc(doc)
+		if err != nil {
+			log.Printf("Error converting training job data: %v", err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+func jobFromDoc(doc *firestore.DocumentSnapshot) (*FirebaseTrainingJob, error) {
+	var job FirebaseTrainingJob
+	if err := doc.DataTo(&job); err != nil {
+		return nil, fmt.Errorf("failed to parse training job: %v", err)
+	}
+	job.ID = doc.Ref.ID
+	return &job, nil
+}
+
+// sortJobs orders jobs newest first
+func sortJobs(jobs []*FirebaseTrainingJob) {
+	for i := 1; i < len(jobs); i++ {
+		for j := i; j > 0 && jobs[j].CreatedAt.After(jobs[j-1].CreatedAt); j-- {
+			jobs[j], jobs[j-1] = jobs[j-1], jobs[j]
+		}
+	}
+}
+
+// NewTrainingToken generates a callback token and the hash stored on the job
+func NewTrainingToken() (string, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+	return token, hashTrainingToken(token), nil
+}
+
+func hashTrainingToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+)
+
+func TestTrainingCreateJobOnePerCompany(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := WithActor(WithCompany(context.Background(), "company-a"), "user-1")
+	training := NewTrainingFirebase(client)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = training.CreateJob(ctx, &FirebaseTrainingJob{SnapshotID: "snapshot-1", MaxAttempts: 3})
+		}(i)
+	}
+	wg.Wait()
+
+	created := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, ErrTrainingInProgress):
+			t.Fatalf("CreateJob: %v", err)
+		}
+	}
+	if created != 1 {
+		t.Fatalf("%d concurrent jobs created, want 1", created)
+	}
+
+	// Other companies train independently
+	other := WithCompany(context.Background(), "company-b")
+	if err := training.CreateJob(other, &FirebaseTrainingJob{SnapshotID: "snapshot-2"}); err != nil {
+		t.Fatalf("CreateJob for another company: %v", err)
+	}
+
+	// Once the job finishes the next one can be queued
+	active, err := training.ActiveJob(ctx)
+	if err != nil || active == nil {
+		t.Fatalf("ActiveJob = %+v, %v", active, err)
+	}
+	if _, err := training.Transition(ctx, active.ID, []string{TrainingQueued}, func(job *FirebaseTrainingJob) {
+		job.Status = TrainingCancelled
+	}); err != nil {
+		t.Fatalf("Transition: %v", err)
+	}
+	if err := training.CreateJob(ctx, &FirebaseTrainingJob{SnapshotID: "snapshot-1"}); err != nil {
+		t.Fatalf("CreateJob after the active job ended: %v", err)
+	}
+}
@@ -0,0 +1,471 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+var (
+	// ErrTrashed is returned when reading or writing a document that is in the trash
+	ErrTrashed = errors.New("record is in the trash")
+	// ErrNotTrashed is returned when restoring or purging a document that is not in the trash
+	ErrNotTrashed = errors.New("record is not in the trash")
+	// ErrUnknownCollection is returned for collections that do not support the trash
+	ErrUnknownCollection = errors.New("collection does not support the trash")
+)
+
+// Reference is a record that points at another record
+type Reference struct {
+	Collection string `json:"collection"`
+	ID         string `json:"id"`
+	Field      string `json:"field"`
+}
+
+// ReferencedError is returned when a record cannot be permanently deleted
+// because other records still point at it
+type ReferencedError struct {
+	References []Reference
+}
+
+// Error implements the error interface
+func (e *ReferencedError) Error() string {
+	return fmt.Sprintf("record is still referenced by %d other record(s)", len(e.References))
+}
+
+// TrashItem is a soft deleted record
+type TrashItem struct {
+	Collection string                 `json:"collection"`
+	ID         string                 `json:"id"`
+	DeletedAt  time.Time              `json:"deleted_at"`
+	DeletedBy  string                 `json:"deleted_by"`
+	Data       map[string]interface{} `json:"data"`
+}
+
+// reference describes a field in another collection that points at a record.
+// Dotted fields walk into nested objects and array elements.
+type reference struct {
+	collection string
+	field      string
+}
+
+// trashCollection describes how records of a collection are referenced
+type trashCollection struct {
+	codeKeyed  bool
+	references []reference
+}
+
+// maxReferences limits how many references are reported for a record
+const maxReferences = 10
+
+// trashCollections lists every collection that supports soft deletion
+var trashCollections = map[string]trashCollection{
+	"access": {},
+	"branches": {references: []reference{
+		{"purchases", "branch_id"},
+		{"purchase_returns", "branch_id"},
+		{"receives", "branch_id"},
+		{"receive_returns", "branch_id"},
+		{"deliveries", "branch_id"},
+		{"delivery_returns", "branch_id"},
+		{"sales_order_returns", "branch_id"},
+	}},
+	"brands": {references: []reference{
+		{"products", "brand_id"},
+	}},
+	"companies": {references: []reference{
+		{"users", "company_id"},
+		{"customers", "company_id"},
+		{"products", "company_id"},
+	}},
+	"customers": {references: []reference{
+		{"sales_orders", "CustomerID"},
+	}},
+	"deliveries": {references: []reference{
+		{"delivery_returns", "delivery_id"},
+	}},
+	"delivery_returns": {},
+	"product_categories": {references: []reference{
+		{"products", "product_category_id"},
+	}},
+	"products": {codeKeyed: true, references: []reference{
+		{"purchases", "purchase_details.product_id"},
+		{"purchase_returns", "purchase_return_details.product_id"},
+		{"receives", "receive_details.product_id"},
+		{"receive_returns", "receive_return_details.product_id"},
+		{"deliveries", "delivery_details.product_id"},
+		{"delivery_returns", "delivery_return_details.product_id"},
+		{"sales_orders", "SalesOrderDetails.ProductID"},
+		{"sales_orders", "SalesOrderDetails.ProductCode"},
+		{"sales_order_returns", "sales_order_return_details.product_id"},
+	}},
+	"purchases": {references: []reference{
+		{"receives", "purchase_id"},
+		{"purchase_returns", "purchase_id"},
+	}},
+	"purchase_returns": {},
+	"receives": {references: []reference{
+		{"receive_returns", "receive_id"},
+	}},
+	"receive_returns": {},
+	"regions":         {},
+	"roles":           {},
+	"sales_orders": {references: []reference{
+		{"deliveries", "sales_order_id"},
+		{"sales_order_returns", "sales_order_id"},
+	}},
+	"sales_order_returns": {},
+	"salesmen": {references: []reference{
+		{"sales_orders", "SalesmanID"},
+	}},
+	"shelves": {references: []reference{
+		{"receives", "receive_details.shelve_id"},
+		{"deliveries", "delivery_details.shelve_id"},
+	}},
+	"suppliers": {references: []reference{
+		{"purchases", "supplier_id"},
+	}},
+}
+
+// IsTrashed reports whether a document has been soft deleted
+func IsTrashed(doc *firestore.DocumentSnapshot) bool {
+	if !doc.Exists() {
+		return false
+	}
+	value, err := doc.DataAt("deleted_at")
+	return err == nil && value != nil
+}
+
+// trashDocument soft deletes a document by stamping deleted_at and deleted_by,
+// honoring the expected version in the context
+func trashDocument(ctx context.Context, client *firestore.Client, ref *firestore.DocumentRef) error {
+	return trashDocumentWith(ctx, client, ref, nil)
+}
+
+// trashDocumentWith soft deletes a document in one transaction with the
+// writes of also, which runs first so it may read. The expected version in
+// the context is only checked against the trashed document.
+func trashDocumentWith(ctx context.Context, client *firestore.Client, ref *firestore.DocumentRef, also func(tx *firestore.Transaction) error) error {
+	return writeDocument(ctx, client, ref, AuditDelete, func(tx *firestore.Transaction) error {
+		if also != nil {
+			if err := also(tx); err != nil {
+				return err
+			}
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "deleted_at", Value: time.Now()},
+			{Path: "deleted_by", Value: ActorFromContext(ctx)},
+		})
+	})
+}
+
+// Trash lists, restores and purges soft deleted records
+type Trash struct {
+	client *firestore.Client
+}
+
+// NewTrash creates a new Trash instance
+func NewTrash(client *firestore.Client) *Trash {
+	return &Trash{
+		client: client,
+	}
+}
+
+// TrashFilter selects trashed records. Company scoped collections only return
+// records of CompanyID; an empty Collection lists all of them. Before pages
+// back through older records.
+type TrashFilter struct {
+	CompanyID  string
+	Collection string
+	Before     time.Time
+	Limit      int
+}
+
+// List returns trashed records, newest first
+func (t *Trash) List(ctx context.Context, filter TrashFilter) ([]TrashItem, error) {
+	names, err := trashCollectionNames(filter.Collection)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []TrashItem{}
+	for _, name := range names {
+		query := t.client.Collection(name).Query
+		if field := companyFields[name]; field != "" && filter.CompanyID != "" {
+			query = query.Where(field, "==", filter.CompanyID)
+		}
+		// Only trashed records have a deleted_at timestamp
+		if filter.Before.IsZero() {
+			query = query.Where("deleted_at", ">", time.Unix(0, 0))
+		} else {
+			query = query.Where("deleted_at", "<", filter.Before)
+		}
+		query = query.OrderBy("deleted_at", firestore.Desc)
+		if filter.Limit > 0 {
+			query = query.Limit(filter.Limit)
+		}
+
+		docs, err := query.Documents(ctx).GetAll()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %v", name, err)
+		}
+
+		for _, doc := range docs {
+			if inContextBranches(ctx, doc) {
+				items = append(items, trashItem(name, doc))
+			}
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].DeletedAt.After(items[j].DeletedAt)
+	})
+	if filter.Limit > 0 && len(items) > filter.Limit {
+		items = items[:filter.Limit]
+	}
+
+	return items, nil
+}
+
+// Restore takes a record out of the trash
+func (t *Trash) Restore(ctx context.Context, collection, id string) error {
+	if _, ok := trashCollections[collection]; !ok {
+		return ErrUnknownCollection
+	}
+
+	ref := t.client.Collection(collection).Doc(id)
+	expected := expectedVersion(ctx)
+	var before map[string]interface{}
+	err := t.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		if !ownedByContextCompany(ctx, doc) || !inContextBranches(ctx, doc) {
+			return ErrNotFound
+		}
+		if !IsTrashed(doc) {
+			return ErrNotTrashed
+		}
+		if expected != "" {
+			if err := checkVersion(doc, expected); err != nil {
+				return err
+			}
+		}
+		before = doc.Data()
+		return tx.Update(ref, []firestore.Update{
+			{Path: "deleted_at", Value: firestore.Delete},
+			{Path: "deleted_by", Value: firestore.Delete},
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	doc, err := refreshVersion(ctx, ref)
+	if err != nil {
+		return err
+	}
+	recordAudit(ctx, t.client, AuditRestore, ref, before, doc.Data())
+	return nil
+}
+
+// Delete permanently removes a trashed record that is no longer referenced
+func (t *Trash) Delete(ctx context.Context, collection, id string) error {
+	spec, ok := trashCollections[collection]
+	if !ok {
+		return ErrUnknownCollection
+	}
+
+	doc, err := t.client.Collection(collection).Doc(id).Get(ctx)
+	if err != nil {
+		return err
+	}
+	if !ownedByContextCompany(ctx, doc) || !inContextBranches(ctx, doc) {
+		return ErrNotFound
+	}
+	if !IsTrashed(doc) {
+		return ErrNotTrashed
+	}
+
+	refs, err := t.references(ctx, collection, spec, doc)
+	if err != nil {
+		return err
+	}
+	if len(refs) > 0 {
+		return &ReferencedError{References: refs}
+	}
+
+	return deleteDocument(ctx, t.client, doc.Ref)
+}
+
+// Purge permanently removes records that have been in the trash for longer
+// than retention. Records that are still referenced are kept.
+func (t *Trash) Purge(ctx context.Context, retention time.Duration) (int, error) {
+	names, _ := trashCollectionNames("")
+	cutoff := time.Now().Add(-retention)
+
+	purged := 0
+	for _, name := range names {
+		docs, err := t.client.Collection(name).Where("deleted_at", "<", cutoff).Documents(ctx).GetAll()
+		if err != nil {
+			return purged, fmt.Errorf("failed to query %s: %v", name, err)
+		}
+
+		for _, doc := range docs {
+			refs, err := t.references(ctx, name, trashCollections[name], doc)
+			if err != nil {
+				return purged, err
+			}
+			if len(refs) > 0 {
+				log.Printf("Keeping trashed %s/%s: still referenced by %d record(s)", name, doc.Ref.ID, len(refs))
+				continue
+			}
+
+			if err := deleteDocument(ctx, t.client, doc.Ref); err != nil {
+				return purged, fmt.Errorf("failed to purge %s/%s: %v", name, doc.Ref.ID, err)
+			}
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// referencePage is how many records are read at a time when references sit
+// inside arrays of objects, which Firestore cannot filter on
+const referencePage = 200
+
+// references finds records of the same company that still point at doc
+func (t *Trash) references(ctx context.Context, collection string, spec trashCollection, doc *firestore.DocumentSnapshot) ([]Reference, error) {
+	keys := []interface{}{doc.Ref.ID}
+	if code, ok := doc.Data()["code"].(string); spec.codeKeyed && ok && code != "" {
+		keys = append(keys, code)
+	}
+	companyID := recordCompany(ctx, collection, doc)
+
+	var refs []Reference
+	for _, r := range spec.references {
+		query := t.client.Collection(r.collection).Query
+		if field := companyFields[r.collection]; field != "" && companyID != "" {
+			query = query.Where(field, "==", companyID)
+		}
+
+		if !strings.Contains(r.field, ".") {
+			candidates, err := query.Where(r.field, "in", keys).Limit(maxReferences - len(refs)).Documents(ctx).GetAll()
+			if err != nil {
+				return nil, fmt.Errorf("failed to check references in %s: %v", r.collection, err)
+			}
+			for _, candidate := range candidates {
+				refs = append(refs, Reference{Collection: r.collection, ID: candidate.Ref.ID, Field: r.field})
+			}
+			if len(refs) >= maxReferences {
+				return refs, nil
+			}
+			continue
+		}
+
+		// Page through the company's records, stopping once enough are found
+		path := strings.Split(r.field, ".")
+		page := query.OrderBy(firestore.DocumentID, firestore.Asc).Limit(referencePage)
+		for {
+			candidates, err := page.Documents(ctx).GetAll()
+			if err != nil {
+				return nil, fmt.Errorf("failed to check references in %s: %v", r.collection, err)
+			}
+			for _, candidate := range candidates {
+				if !containsKey(lookupField(candidate.Data(), path), keys) {
+					continue
+				}
+				refs = append(refs, Reference{Collection: r.collection, ID: candidate.Ref.ID, Field: r.field})
+				if len(refs) >= maxReferences {
+					return refs, nil
+				}
+			}
+			if len(candidates) < referencePage {
+				break
+			}
+			page = page.StartAfter(candidates[len(candidates)-1])
+		}
+	}
+
+	return refs, nil
+}
+
+// recordCompany returns the company owning a record, falling back to the
+// context company for collections that are not company scoped
+func recordCompany(ctx context.Context, collection string, doc *firestore.DocumentSnapshot) string {
+	if collection == "companies" {
+		return doc.Ref.ID
+	}
+	if owner, _ := doc.Data()[companyFields[collection]].(string); owner != "" {
+		return owner
+	}
+	return CompanyFromContext(ctx)
+}
+
+// lookupField collects the values at a dotted path, walking into arrays
+func lookupField(value interface{}, path []string) []interface{} {
+	if len(path) == 0 {
+		return []interface{}{value}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return lookupField(v[path[0]], path[1:])
+	case []interface{}:
+		var values []interface{}
+		for _, element := range v {
+			values = append(values, lookupField(element, path)...)
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+func containsKey(values []interface{}, keys []interface{}) bool {
+	for _, value := range values {
+		for _, key := range keys {
+			if value == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// trashCollectionNames returns the requested collection, or all of them in a stable order
+func trashCollectionNames(collection string) ([]string, error) {
+	if collection != "" {
+		if _, ok := trashCollections[collection]; !ok {
+			return nil, ErrUnknownCollection
+		}
+		return []string{collection}, nil
+	}
+
+	names := make([]string, 0, len(trashCollections))
+	for name := range trashCollections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func trashItem(collection string, doc *firestore.DocumentSnapshot) TrashItem {
+	data := doc.Data()
+	item := TrashItem{
+		Collection: collection,
+		ID:         doc.Ref.ID,
+		Data:       data,
+	}
+	item.DeletedAt, _ = data["deleted_at"].(time.Time)
+	item.DeletedBy, _ = data["deleted_by"].(string)
+	return item
+}
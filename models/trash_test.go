@@ -0,0 +1,136 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+)
+
+func TestTrashListPages(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := WithCompany(context.Background(), "company-a")
+	brands := NewFirebaseModel("brands", client)
+	trash := NewTrash(client)
+
+	var ids []string
+	for _, name := range []string{"first", "second", "third"} {
+		id, err := brands.Create(ctx, map[string]interface{}{"Name": name})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if err := brands.Delete(ctx, id); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		ids = append(ids, id)
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := brands.Create(ctx, map[string]interface{}{"Name": "kept"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	other := WithCompany(context.Background(), "company-b")
+	otherID, err := brands.Create(other, map[string]interface{}{"Name": "other"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := brands.Delete(other, otherID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	page, err := trash.List(ctx, TrashFilter{CompanyID: "company-a", Collection: "brands", Limit: 2})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(page) != 2 || page[0].ID != ids[2] || page[1].ID != ids[1] {
+		t.Fatalf("first page = %+v, want the two newest of company-a", page)
+	}
+
+	page, err = trash.List(ctx, TrashFilter{CompanyID: "company-a", Collection: "brands", Limit: 2, Before: page[1].DeletedAt})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(page) != 1 || page[0].ID != ids[0] {
+		t.Fatalf("second page = %+v, want the oldest of company-a", page)
+	}
+}
+
+func TestTrashDeleteChecksCompanyReferences(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := WithCompany(context.Background(), "company-a")
+	products, _ := NewProductFirebase(client)
+	purchases := NewPurchaseFirebase(client)
+	trash := NewTrash(client)
+
+	seedProduct(t, ctx, products, &FirebaseProduct{Code: "P1", Name: "Tea"})
+	// Another company's purchase of its own P1 must not block the delete
+	other := WithCompany(context.Background(), "company-b")
+	if _, err := purchases.Create(other, &FirebasePurchase{Code: "PO-B", PurchaseDetails: []FirebasePurchaseDetail{{ProductID: "P1", Qty: 1}}}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	purchaseID, err := purchases.Create(ctx, &FirebasePurchase{Code: "PO-A", PurchaseDetails: []FirebasePurchaseDetail{{ProductID: "P1", Qty: 1}}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if err := products.Delete(ctx, "P1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	items, err := trash.List(ctx, TrashFilter{CompanyID: "company-a", Collection: "products"})
+	if err != nil || len(items) != 1 {
+		t.Fatalf("List = %+v, %v, want the product", items, err)
+	}
+	productID := items[0].ID
+
+	err = trash.Delete(ctx, "products", productID)
+	var referenced *ReferencedError
+	if !errors.As(err, &referenced) || len(referenced.References) != 1 || referenced.References[0].ID != purchaseID {
+		t.Fatalf("Delete error = %v, want a reference from %s", err, purchaseID)
+	}
+
+	if err := purchases.Delete(ctx, purchaseID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := trash.Delete(ctx, "purchases", purchaseID); err != nil {
+		t.Fatalf("purging purchase: %v", err)
+	}
+	if err := trash.Delete(ctx, "products", productID); err != nil {
+		t.Fatalf("purging product: %v", err)
+	}
+}
+
+func TestTrashRestore(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := WithCompany(context.Background(), "company-a")
+	brands := NewFirebaseModel("brands", client)
+	trash := NewTrash(client)
+
+	id, err := brands.Create(ctx, map[string]interface{}{"Name": "brand"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := trash.Restore(ctx, "brands", id); !errors.Is(err, ErrNotTrashed) {
+		t.Fatalf("Restore of a live record = %v, want ErrNotTrashed", err)
+	}
+	if err := brands.Delete(ctx, id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := trash.Restore(WithCompany(context.Background(), "company-b"), "brands", id); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Restore by another company = %v, want ErrNotFound", err)
+	}
+	if err := trash.Restore(ctx, "brands", id); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	var brand map[string]interface{}
+	if err := brands.Get(ctx, id, &brand); err != nil {
+		t.Fatalf("Get after restore: %v", err)
+	}
+}
+
+func seedProduct(t *testing.T, ctx context.Context, products *ProductFirebase, product *FirebaseProduct) {
+	t.Helper()
+	if _, err := products.FirebaseModel.Create(ctx, product); err != nil {
+		t.Fatalf("creating product: %v", err)
+	}
+}
@@ -40,7 +40,7 @@ func NewUserFirebase(client *firestore.Client, authClient *auth.Client) *UserFir
 
 // List returns all users
 func (u *UserFirebase) List(ctx context.Context) ([]*FirebaseUser, error) {
-	docs, err := u.Client.Collection("users").Documents(ctx).GetAll()
+	docs, err := scopedQuery(ctx, u.Client.Collection("users")).Documents(ctx).GetAll()
 	if err != nil {
 		return nil, err
 	}
@@ -64,12 +64,16 @@ func (u *UserFirebase) Get(ctx context.Context, id string) (*FirebaseUser, error
 	if err != nil {
 		return nil, err
 	}
+	if err := checkReadable(ctx, doc); err != nil {
+		return nil, err
+	}
 
 	var user FirebaseUser
 	if err := doc.DataTo(&user); err != nil {
 		return nil, err
 	}
 	user.ID = doc.Ref.ID
+	recordVersion(ctx, doc.UpdateTime)
 
 	return &user, nil
 }
@@ -88,7 +92,7 @@ func (u *UserFirebase) Create(ctx context.Context, user *FirebaseUser) (string,
 
 	// Create user in Firestore
 	user.ID = authUser.UID
-	_, err = u.Client.Collection("users").Doc(authUser.UID).Set(ctx, user)
+	err = createDocument(ctx, u.Client, u.Client.Collection("users").Doc(authUser.UID), user)
 	if err != nil {
 		// If Firestore creation fails, delete the Auth user
 		u.Auth.DeleteUser(ctx, authUser.UID)
@@ -100,24 +104,23 @@ func (u *UserFirebase) Create(ctx context.Context, user *FirebaseUser) (string,
 
 // Update updates a user in both Firestore and Firebase Auth
 func (u *UserFirebase) Update(ctx context.Context, id string, user *FirebaseUser) error {
+	// Update user in Firestore first so a stale version leaves Auth untouched
+	if err := setDocument(ctx, u.Client, u.Client.Collection("users").Doc(id), user); err != nil {
+		return err
+	}
+
 	// Update user in Firebase Auth
 	params := (&auth.UserToUpdate{}).
 		Email(user.Email)
 
 	_, err := u.Auth.UpdateUser(ctx, id, params)
-	if err != nil {
-		return err
-	}
-
-	// Update user in Firestore
-	_, err = u.Client.Collection("users").Doc(id).Set(ctx, user)
 	return err
 }
 
 // Delete deletes a user from both Firestore and Firebase Auth
 func (u *UserFirebase) Delete(ctx context.Context, id string) error {
 	// First delete from Firestore
-	_, err := u.Client.Collection("users").Doc(id).Delete(ctx)
+	err := deleteDocument(ctx, u.Client, u.Client.Collection("users").Doc(id))
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,290 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+// VersionMismatchError is returned when a write carries an expected version
+// that no longer matches the stored document
+type VersionMismatchError struct {
+	Current string
+}
+
+// Error implements the error interface
+func (e *VersionMismatchError) Error() string {
+	return fmt.Sprintf("document has been modified, current version is %s", e.Current)
+}
+
+// versionContextKey is a custom type for version context keys
+type versionContextKey string
+
+const (
+	expectedVersionKey versionContextKey = "expected_version"
+	versionRecorderKey versionContextKey = "version_recorder"
+)
+
+// VersionRecorder holds the version of the last document read or written
+// while serving a request
+type VersionRecorder struct {
+	Version string
+}
+
+// WithExpectedVersion returns a context whose writes only succeed when the
+// stored document still has the given version ("*" matches any existing document)
+func WithExpectedVersion(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, expectedVersionKey, version)
+}
+
+// WithVersionRecorder returns a context that records document versions
+func WithVersionRecorder(ctx context.Context) (context.Context, *VersionRecorder) {
+	recorder := &VersionRecorder{}
+	return context.WithValue(ctx, versionRecorderKey, recorder), recorder
+}
+
+// RecordedVersion returns the last version recorded in the context
+func RecordedVersion(ctx context.Context) string {
+	if recorder, ok := ctx.Value(versionRecorderKey).(*VersionRecorder); ok {
+		return recorder.Version
+	}
+	return ""
+}
+
+// DocumentVersion formats a document update time as a strong ETag
+func DocumentVersion(updateTime time.Time) string {
+	return `"` + strconv.FormatInt(updateTime.UnixNano(), 10) + `"`
+}
+
+// normalizeVersion strips the weak prefix and quotes from an ETag
+func normalizeVersion(version string) string {
+	version = strings.TrimSpace(version)
+	version = strings.TrimPrefix(version, "W/")
+	return strings.Trim(version, `"`)
+}
+
+func expectedVersion(ctx context.Context) string {
+	version, _ := ctx.Value(expectedVersionKey).(string)
+	return version
+}
+
+func recordVersion(ctx context.Context, updateTime time.Time) {
+	if recorder, ok := ctx.Value(versionRecorderKey).(*VersionRecorder); ok && !updateTime.IsZero() {
+		recorder.Version = DocumentVersion(updateTime)
+	}
+}
+
+// checkVersion compares a snapshot against the expected version
+func checkVersion(doc *firestore.DocumentSnapshot, expected string) error {
+	if strings.TrimSpace(expected) == "*" {
+		return nil
+	}
+
+	current := DocumentVersion(doc.UpdateTime)
+	if VersionMatches(expected, current) {
+		return nil
+	}
+
+	return &VersionMismatchError{Current: current}
+}
+
+// VersionMatches reports whether a comma separated list of ETags, as sent in
+// If-Match or If-None-Match, holds version
+func VersionMatches(list, version string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		if normalizeVersion(candidate) == normalizeVersion(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshVersion records the current version of a document after a write
+// and returns the stored snapshot
+func refreshVersion(ctx context.Context, ref *firestore.DocumentRef) (*firestore.DocumentSnapshot, error) {
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document version: %v", err)
+	}
+	recordVersion(ctx, doc.UpdateTime)
+	return doc, nil
+}
+
+// createDocument stores a new document and records it in the audit log
+func createDocument(ctx context.Context, client *firestore.Client, ref *firestore.DocumentRef, data interface{}) error {
+	return createDocumentWith(ctx, client, ref, data, nil)
+}
+
+// createDocumentWith stores a new document in one transaction with the
+// writes of also, such as opening its approval. also runs first, so it may
+// read; when it fails nothing is stored.
+func createDocumentWith(ctx context.Context, client *firestore.Client, ref *firestore.DocumentRef, data interface{}, also func(tx *firestore.Transaction) error) error {
+	if err := stampCompany(ctx, ref.Parent.ID, data); err != nil {
+		return err
+	}
+	if err := stampBranch(ctx, ref.Parent.ID, data); err != nil {
+		return err
+	}
+	if also == nil {
+		if _, err := ref.Set(ctx, data); err != nil {
+			return err
+		}
+	} else {
+		err := client.RunTransaction(ctx, func(_ context.Context, tx *firestore.Transaction) error {
+			if err := also(tx); err != nil {
+				return err
+			}
+			return tx.Set(ref, data)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	doc, err := refreshVersion(ctx, ref)
+	if err != nil {
+		return err
+	}
+	recordAudit(ctx, client, AuditCreate, ref, nil, doc.Data())
+	return nil
+}
+
+// auditCreated records a document created inside the transaction of another
+// write in the audit log, once that transaction has committed
+func auditCreated(ctx context.Context, client *firestore.Client, ref *firestore.DocumentRef) {
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		log.Printf("Error reading %s/%s for the audit log: %v", ref.Parent.ID, ref.ID, err)
+		return
+	}
+	recordAudit(ctx, client, AuditCreate, ref, nil, doc.Data())
+}
+
+// auditUpdated records a document changed inside the transaction of another
+// write in the audit log, once that transaction has committed
+func auditUpdated(ctx context.Context, client *firestore.Client, ref *firestore.DocumentRef, before map[string]interface{}) {
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		log.Printf("Error reading %s/%s for the audit log: %v", ref.Parent.ID, ref.ID, err)
+		return
+	}
+	recordAudit(ctx, client, AuditUpdate, ref, before, doc.Data())
+}
+
+// writeDocument runs a write inside a transaction after checking that the
+// document belongs to the context company, is not in the trash and still has
+// the expected version, if any.
+// The change is recorded in the audit log under action.
+func writeDocument(ctx context.Context, client *firestore.Client, ref *firestore.DocumentRef, action string, write func(tx *firestore.Transaction) error) error {
+	expected := expectedVersion(ctx)
+	var before map[string]interface{}
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		if err := checkReadable(ctx, doc); err != nil {
+			return err
+		}
+		if expected != "" {
+			if err := checkVersion(doc, expected); err != nil {
+				return err
+			}
+		}
+		before = doc.Data()
+		return write(tx)
+	})
+	if err != nil {
+		return err
+	}
+
+	doc, err := refreshVersion(ctx, ref)
+	if err != nil {
+		return err
+	}
+	recordAudit(ctx, client, action, ref, before, doc.Data())
+	return nil
+}
+
+// setDocument replaces a document, honoring the expected version in the context
+func setDocument(ctx context.Context, client *firestore.Client, ref *firestore.DocumentRef, data interface{}) error {
+	return setDocumentWith(ctx, client, ref, data, nil)
+}
+
+// setDocumentWith replaces a document in one transaction with the writes of
+// also, such as reviewing its approval. also runs after the checks and before
+// the document is written, so it may read and fill in fields of data.
+func setDocumentWith(ctx context.Context, client *firestore.Client, ref *firestore.DocumentRef, data interface{}, also func(tx *firestore.Transaction) error) error {
+	if err := stampCompany(ctx, ref.Parent.ID, data); err != nil {
+		return err
+	}
+	if err := stampBranch(ctx, ref.Parent.ID, data); err != nil {
+		return err
+	}
+	return writeDocument(ctx, client, ref, AuditUpdate, func(tx *firestore.Transaction) error {
+		if also != nil {
+			if err := also(tx); err != nil {
+				return err
+			}
+		}
+		return tx.Set(ref, data)
+	})
+}
+
+// updateDocument applies field updates, honoring the expected version in the context
+func updateDocument(ctx context.Context, client *firestore.Client, ref *firestore.DocumentRef, updates []firestore.Update) error {
+	if err := checkCompanyUpdates(ctx, ref.Parent.ID, updates); err != nil {
+		return err
+	}
+	if err := checkBranchUpdates(ctx, ref.Parent.ID, updates); err != nil {
+		return err
+	}
+	return writeDocument(ctx, client, ref, AuditUpdate, func(tx *firestore.Transaction) error {
+		return tx.Update(ref, updates)
+	})
+}
+
+// deleteDocument permanently removes a document, honoring the expected version in the context
+func deleteDocument(ctx context.Context, client *firestore.Client, ref *firestore.DocumentRef) error {
+	return deleteDocumentWith(ctx, client, ref, nil)
+}
+
+// deleteDocumentWith permanently removes a document in one transaction with
+// the writes of also, which runs after the checks so it may read. The
+// expected version in the context only applies to the deleted document.
+func deleteDocumentWith(ctx context.Context, client *firestore.Client, ref *firestore.DocumentRef, also func(tx *firestore.Transaction) error) error {
+	expected := expectedVersion(ctx)
+	var before map[string]interface{}
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		if !ownedByContextCompany(ctx, doc) || !inContextBranches(ctx, doc) {
+			return ErrNotFound
+		}
+		if expected != "" {
+			if err := checkVersion(doc, expected); err != nil {
+				return err
+			}
+		}
+		before = doc.Data()
+		if also != nil {
+			if err := also(tx); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(ref)
+	})
+	if err != nil {
+		return err
+	}
+
+	recordAudit(ctx, client, AuditPurge, ref, before, nil)
+	return nil
+}
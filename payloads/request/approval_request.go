@@ -0,0 +1,36 @@
+package request
+
+import "github.com/nirshpaa/godam-backend/models"
+
+// ApprovalLevelRequest : format json request for one level of an approval rule
+type ApprovalLevelRequest struct {
+	Name       string `json:"name"`
+	Permission string `json:"permission" binding:"required"`
+}
+
+// ApprovalRuleRequest : format json request for creating or changing an approval rule
+type ApprovalRuleRequest struct {
+	DocumentType string                 `json:"document_type" binding:"required,oneof=purchases sales_orders stock_adjustments"`
+	Metric       string                 `json:"metric" binding:"required"`
+	Threshold    float64                `json:"threshold" binding:"min=0"`
+	Levels       []ApprovalLevelRequest `json:"levels" binding:"required,min=1,dive"`
+}
+
+// Transform converts ApprovalRuleRequest to FirebaseApprovalRule
+func (r *ApprovalRuleRequest) Transform() *models.FirebaseApprovalRule {
+	levels := make([]models.ApprovalLevel, 0, len(r.Levels))
+	for _, level := range r.Levels {
+		levels = append(levels, models.ApprovalLevel{Name: level.Name, Permission: level.Permission})
+	}
+	return &models.FirebaseApprovalRule{
+		DocumentType: r.DocumentType,
+		Metric:       r.Metric,
+		Threshold:    r.Threshold,
+		Levels:       levels,
+	}
+}
+
+// ApprovalDecisionRequest : format json request for approving or rejecting an approval level
+type ApprovalDecisionRequest struct {
+	Comment string `json:"comment"`
+}
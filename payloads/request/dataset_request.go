@@ -0,0 +1,33 @@
+package request
+
+import "github.com/nirshpaa/godam-backend/models"
+
+// DatasetImageRequest : format json request for curating a dataset image
+type DatasetImageRequest struct {
+	Labels       []string `json:"labels" binding:"dive,required,max=100"`
+	QualityFlags []string `json:"quality_flags" binding:"dive,oneof=low_resolution blurry dark overexposed duplicate"`
+	Split        string   `json:"split" binding:"required,oneof=train val"`
+	Excluded     bool     `json:"excluded"`
+}
+
+// Transform converts DatasetImageRequest to FirebaseDatasetImage
+func (r *DatasetImageRequest) Transform() *models.FirebaseDatasetImage {
+	image := &models.FirebaseDatasetImage{
+		Labels:       r.Labels,
+		QualityFlags: r.QualityFlags,
+		Split:        r.Split,
+		Excluded:     r.Excluded,
+	}
+	if image.Labels == nil {
+		image.Labels = []string{}
+	}
+	if image.QualityFlags == nil {
+		image.QualityFlags = []string{}
+	}
+	return image
+}
+
+// DatasetSnapshotRequest : format json request for creating a dataset snapshot
+type DatasetSnapshotRequest struct {
+	Note string `json:"note" binding:"max=500"`
+}
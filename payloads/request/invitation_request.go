@@ -0,0 +1,24 @@
+package request
+
+import "github.com/nirshpaa/godam-backend/models"
+
+// NewInvitationRequest : format json request for inviting someone to a company
+type NewInvitationRequest struct {
+	Email    string   `json:"email" binding:"required,email"`
+	Role     string   `json:"role" binding:"required"`
+	Branches []string `json:"branches"`
+}
+
+// Transform converts NewInvitationRequest to FirebaseInvitation
+func (r *NewInvitationRequest) Transform() *models.FirebaseInvitation {
+	return &models.FirebaseInvitation{
+		Email:    r.Email,
+		Role:     r.Role,
+		Branches: r.Branches,
+	}
+}
+
+// InvitationTokenRequest : format json request for answering an invitation
+type InvitationTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
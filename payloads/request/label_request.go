@@ -0,0 +1,41 @@
+package request
+
+// BarcodeAssignRequest : format json request for assigning internal barcodes to products
+type BarcodeAssignRequest struct {
+	Codes []string `json:"codes"`
+}
+
+// ProductLabelItem : format json request for the labels of one product
+type ProductLabelItem struct {
+	Code     string `json:"code" binding:"required"`
+	ShelfID  string `json:"shelf_id"`
+	Quantity int    `json:"quantity" binding:"min=0,max=1000"`
+}
+
+// ShelfLabelItem : format json request for the labels of one shelf
+type ShelfLabelItem struct {
+	ID       string `json:"id" binding:"required"`
+	Quantity int    `json:"quantity" binding:"min=0,max=1000"`
+}
+
+// LabelLayoutRequest : format json request for the label format and sheet or media size
+type LabelLayoutRequest struct {
+	Format  string   `json:"format" binding:"omitempty,oneof=pdf zpl"`
+	Columns int      `json:"columns" binding:"min=0,max=10"`
+	Rows    int      `json:"rows" binding:"min=0,max=30"`
+	Margin  *float64 `json:"margin" binding:"omitempty,min=0,max=50"`
+	Width   int      `json:"width" binding:"min=0,max=2400"`
+	Height  int      `json:"height" binding:"min=0,max=2400"`
+}
+
+// ProductLabelRequest : format json request for printing product labels
+type ProductLabelRequest struct {
+	LabelLayoutRequest
+	Items []ProductLabelItem `json:"items" binding:"required,min=1,max=500,dive"`
+}
+
+// ShelfLabelRequest : format json request for printing shelf labels
+type ShelfLabelRequest struct {
+	LabelLayoutRequest
+	Items []ShelfLabelItem `json:"items" binding:"required,min=1,max=500,dive"`
+}
@@ -0,0 +1,18 @@
+package request
+
+import "github.com/nirshpaa/godam-backend/models"
+
+// MembershipRequest : format json request for changing a membership
+type MembershipRequest struct {
+	Role     string   `json:"role" binding:"required"`
+	Branches []string `json:"branches"`
+	Status   string   `json:"status" binding:"required,oneof=pending active suspended"`
+}
+
+// Transform applies MembershipRequest to a membership
+func (r *MembershipRequest) Transform(membership *models.FirebaseMembership) *models.FirebaseMembership {
+	membership.Role = r.Role
+	membership.Branches = r.Branches
+	membership.Status = r.Status
+	return membership
+}
@@ -6,26 +6,34 @@ import (
 
 // NewRoleRequest : format json request for new role
 type NewRoleRequest struct {
-	Name string `json:"name" validate:"required"`
+	Name         string              `json:"name" validate:"required"`
+	AccessIDs    []string            `json:"access_ids"`
+	HiddenFields map[string][]string `json:"hidden_fields"`
 }
 
 // Transform converts NewRoleRequest to RoleFirebaseModel
 func (r *NewRoleRequest) Transform() *models.RoleFirebaseModel {
 	return &models.RoleFirebaseModel{
-		Name: r.Name,
+		Name:         r.Name,
+		AccessIDs:    r.AccessIDs,
+		HiddenFields: r.HiddenFields,
 	}
 }
 
 // RoleRequest : format json request for role
 type RoleRequest struct {
-	ID   string `json:"id,omitempty" validate:"required"`
-	Name string `json:"name,omitempty" validate:"required"`
+	ID           string              `json:"id,omitempty" validate:"required"`
+	Name         string              `json:"name,omitempty" validate:"required"`
+	AccessIDs    []string            `json:"access_ids,omitempty"`
+	HiddenFields map[string][]string `json:"hidden_fields,omitempty"`
 }
 
 // Transform converts RoleRequest to RoleFirebaseModel
 func (r *RoleRequest) Transform() *models.RoleFirebaseModel {
 	return &models.RoleFirebaseModel{
-		ID:   r.ID,
-		Name: r.Name,
+		ID:           r.ID,
+		Name:         r.Name,
+		AccessIDs:    r.AccessIDs,
+		HiddenFields: r.HiddenFields,
 	}
 }
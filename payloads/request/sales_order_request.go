@@ -0,0 +1,58 @@
+package request
+
+import "github.com/nirshpaa/godam-backend/types"
+
+// SalesOrderRequest : format json request for sales order
+type SalesOrderRequest struct {
+	Date              string                    `json:"date" validate:"required"`
+	CustomerID        string                    `json:"customer_id" validate:"required"`
+	SalesmanID        string                    `json:"salesman_id"`
+	CompanyID         string                    `json:"company_id" validate:"required"`
+	TotalAmount       float64                   `json:"total_amount" validate:"gte=0"`
+	Discount          float64                   `json:"discount" validate:"gte=0"`
+	AdditionalDisc    float64                   `json:"additional_disc" validate:"gte=0"`
+	Status            string                    `json:"status"`
+	SalesOrderDetails []SalesOrderDetailRequest `json:"sales_order_details" validate:"required,dive"`
+}
+
+// Transform SalesOrderRequest to SalesOrder
+func (u *SalesOrderRequest) Transform() *types.SalesOrder {
+	order := &types.SalesOrder{
+		Date:           u.Date,
+		CustomerID:     u.CustomerID,
+		SalesmanID:     u.SalesmanID,
+		CompanyID:      u.CompanyID,
+		TotalAmount:    u.TotalAmount,
+		Discount:       u.Discount,
+		AdditionalDisc: u.AdditionalDisc,
+		Status:         u.Status,
+	}
+
+	for _, d := range u.SalesOrderDetails {
+		order.SalesOrderDetails = append(order.SalesOrderDetails, d.Transform())
+	}
+
+	return order
+}
+
+// SalesOrderDetailRequest : format json request for sales order detail
+type SalesOrderDetailRequest struct {
+	ProductID   string  `json:"product_id" validate:"required"`
+	ProductCode string  `json:"product_code"`
+	Quantity    float64 `json:"quantity" validate:"gt=0"`
+	UnitPrice   float64 `json:"unit_price" validate:"gte=0"`
+	TotalPrice  float64 `json:"total_price" validate:"gte=0"`
+	Discount    float64 `json:"discount" validate:"gte=0"`
+}
+
+// Transform SalesOrderDetailRequest to SalesOrderDetail
+func (u *SalesOrderDetailRequest) Transform() types.SalesOrderDetail {
+	return types.SalesOrderDetail{
+		ProductID:   u.ProductID,
+		ProductCode: u.ProductCode,
+		Quantity:    u.Quantity,
+		UnitPrice:   u.UnitPrice,
+		TotalPrice:  u.TotalPrice,
+		Discount:    u.Discount,
+	}
+}
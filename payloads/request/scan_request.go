@@ -0,0 +1,24 @@
+package request
+
+import "github.com/nirshpaa/godam-backend/models"
+
+// ScanConfirmRequest : format json request for confirming the product a scan showed
+type ScanConfirmRequest struct {
+	ProductCode string `json:"product_code" binding:"required"`
+}
+
+// RecognitionSettingsRequest : format json request for changing recognition settings
+type RecognitionSettingsRequest struct {
+	VisualThreshold float64 `json:"visual_threshold" binding:"required,gt=0,lte=1"`
+	CNNThreshold    float64 `json:"cnn_threshold" binding:"required,gt=0,lte=1"`
+	Candidates      int     `json:"candidates" binding:"required,min=1,max=20"`
+}
+
+// Transform converts RecognitionSettingsRequest to RecognitionSettings
+func (r *RecognitionSettingsRequest) Transform() *models.RecognitionSettings {
+	return &models.RecognitionSettings{
+		VisualThreshold: r.VisualThreshold,
+		CNNThreshold:    r.CNNThreshold,
+		Candidates:      r.Candidates,
+	}
+}
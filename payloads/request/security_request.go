@@ -0,0 +1,29 @@
+package request
+
+import "github.com/nirshpaa/godam-backend/models"
+
+// SecurityAlertRuleRequest : format json request for creating or changing a security alert rule.
+// Failed authentication has no verified company, so company rules cannot
+// watch auth_failure; those alerts go to SECURITY_ALERT_RECIPIENTS.
+type SecurityAlertRuleRequest struct {
+	Name          string   `json:"name"`
+	EventType     string   `json:"event_type" binding:"required,oneof=company_denied permission_denied api_key_used"`
+	Threshold     int      `json:"threshold" binding:"required,min=1"`
+	WindowMinutes int      `json:"window_minutes" binding:"required,min=1,max=1440"`
+	GroupBy       string   `json:"group_by" binding:"required,oneof=ip user company"`
+	Recipients    []string `json:"recipients" binding:"required,min=1,dive,email"`
+	Disabled      bool     `json:"disabled"`
+}
+
+// Transform converts SecurityAlertRuleRequest to FirebaseSecurityAlertRule
+func (r *SecurityAlertRuleRequest) Transform() *models.FirebaseSecurityAlertRule {
+	return &models.FirebaseSecurityAlertRule{
+		Name:          r.Name,
+		EventType:     r.EventType,
+		Threshold:     r.Threshold,
+		WindowMinutes: r.WindowMinutes,
+		GroupBy:       r.GroupBy,
+		Recipients:    r.Recipients,
+		Disabled:      r.Disabled,
+	}
+}
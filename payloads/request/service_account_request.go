@@ -0,0 +1,19 @@
+package request
+
+import "github.com/nirshpaa/godam-backend/models"
+
+// ServiceAccountRequest : format json request for creating or changing a service account
+type ServiceAccountRequest struct {
+	Name     string   `json:"name" binding:"required"`
+	Scopes   []string `json:"scopes" binding:"required,min=1"`
+	Disabled bool     `json:"disabled"`
+}
+
+// Transform converts ServiceAccountRequest to FirebaseServiceAccount
+func (r *ServiceAccountRequest) Transform() *models.FirebaseServiceAccount {
+	return &models.FirebaseServiceAccount{
+		Name:     r.Name,
+		Scopes:   r.Scopes,
+		Disabled: r.Disabled,
+	}
+}
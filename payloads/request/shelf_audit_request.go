@@ -0,0 +1,49 @@
+package request
+
+import (
+	"github.com/nirshpaa/godam-backend/libraries/planogram"
+	"github.com/nirshpaa/godam-backend/models"
+)
+
+// PlanogramSlotRequest : format json request for a product placed on a shelf
+type PlanogramSlotRequest struct {
+	ProductCode string `json:"product_code" binding:"required"`
+	Facings     int    `json:"facings" binding:"min=0,max=1000"`
+}
+
+// PlanogramRequest : format json request for the planogram of a shelf
+type PlanogramRequest struct {
+	Slots []PlanogramSlotRequest `json:"slots" binding:"max=500,dive"`
+}
+
+// Transform converts PlanogramRequest to FirebasePlanogram
+func (r *PlanogramRequest) Transform(shelveID string) *models.FirebasePlanogram {
+	plan := &models.FirebasePlanogram{
+		ShelveID: shelveID,
+		Slots:    make([]planogram.Slot, 0, len(r.Slots)),
+	}
+	for _, slot := range r.Slots {
+		plan.Slots = append(plan.Slots, planogram.Slot{ProductCode: slot.ProductCode, Facings: slot.Facings})
+	}
+	return plan
+}
+
+// StockCountLineRequest : format json request for the counted quantity of a product
+type StockCountLineRequest struct {
+	ProductCode string  `json:"product_code" binding:"required"`
+	Counted     float64 `json:"counted" binding:"min=0"`
+}
+
+// StockCountRequest : format json request for recording counted quantities
+type StockCountRequest struct {
+	Lines []StockCountLineRequest `json:"lines" binding:"required,min=1,dive"`
+}
+
+// Counts returns the counted quantities by product code
+func (r *StockCountRequest) Counts() map[string]float64 {
+	counts := make(map[string]float64, len(r.Lines))
+	for _, line := range r.Lines {
+		counts[line.ProductCode] = line.Counted
+	}
+	return counts
+}
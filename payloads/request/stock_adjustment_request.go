@@ -0,0 +1,21 @@
+package request
+
+import "github.com/nirshpaa/godam-backend/models"
+
+// StockAdjustmentRequest : format json request for adjusting the stock of a product
+type StockAdjustmentRequest struct {
+	BranchID    string  `json:"branch_id"`
+	ProductCode string  `json:"product_code" binding:"required"`
+	Quantity    float64 `json:"quantity" binding:"required"`
+	Reason      string  `json:"reason" binding:"required"`
+}
+
+// Transform converts StockAdjustmentRequest to FirebaseStockAdjustment
+func (r *StockAdjustmentRequest) Transform() *models.FirebaseStockAdjustment {
+	return &models.FirebaseStockAdjustment{
+		BranchID:    r.BranchID,
+		ProductCode: r.ProductCode,
+		Quantity:    r.Quantity,
+		Reason:      r.Reason,
+	}
+}
@@ -0,0 +1,27 @@
+package request
+
+import "github.com/nirshpaa/godam-backend/models"
+
+// TrainingJobRequest : format json request for starting a training job,
+// on a new snapshot of the dataset when snapshot_id is empty
+type TrainingJobRequest struct {
+	SnapshotID string `json:"snapshot_id"`
+}
+
+// TrainingCallbackRequest : format json request the training service reports a job with
+type TrainingCallbackRequest struct {
+	Status        string             `json:"status" binding:"required,oneof=running succeeded failed"`
+	Metrics       map[string]float64 `json:"metrics"`
+	ModelEndpoint string             `json:"model_endpoint" binding:"required_if=Status succeeded,omitempty,url"`
+	Error         string             `json:"error" binding:"max=2000"`
+}
+
+// Transform converts TrainingCallbackRequest to TrainingReport
+func (r *TrainingCallbackRequest) Transform() *models.TrainingReport {
+	return &models.TrainingReport{
+		Status:        r.Status,
+		Metrics:       r.Metrics,
+		ModelEndpoint: r.ModelEndpoint,
+		Error:         r.Error,
+	}
+}
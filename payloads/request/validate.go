@@ -0,0 +1,67 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// validators checks both tag styles used by the request payloads
+var validators = []*validator.Validate{newValidator("validate"), newValidator("binding")}
+
+func newValidator(tag string) *validator.Validate {
+	v := validator.New()
+	v.SetTagName(tag)
+	return v
+}
+
+// ValidatePatched validates a patched resource document against a request payload.
+// Only payload fields whose json key is present in the document are checked, so
+// stored fields the payload does not describe are left alone. Fields stored in a
+// different shape than the payload accepts are skipped here and caught when the
+// document is decoded back into its model.
+func ValidatePatched(doc []byte, payload interface{}) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &fields); err != nil {
+		return err
+	}
+
+	value := reflect.ValueOf(payload).Elem()
+	var present []string
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" || field.PkgPath != "" {
+			continue
+		}
+
+		raw, ok := fields[name]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(raw, value.Field(i).Addr().Interface()); err != nil {
+			continue
+		}
+		present = append(present, field.Name)
+	}
+
+	if len(present) == 0 {
+		return nil
+	}
+
+	for _, v := range validators {
+		err := v.StructPartial(payload, present...)
+		if err == nil {
+			continue
+		}
+		if verrs, ok := err.(validator.ValidationErrors); ok && len(verrs) > 0 {
+			return errors.New(verrs[0].Field() + " is " + verrs[0].Tag())
+		}
+		return err
+	}
+
+	return nil
+}
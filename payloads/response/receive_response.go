@@ -12,6 +12,7 @@ type ReceiveResponse struct {
 	Code           string                  `json:"code"`
 	Date           time.Time               `json:"date"`
 	Remark         string                  `json:"remark"`
+	Status         string                  `json:"status,omitempty"`
 	PurchaseID     string                  `json:"purchase_id"`
 	CompanyID      string                  `json:"company_id"`
 	BranchID       string                  `json:"branch_id"`
@@ -24,6 +25,7 @@ func (u *ReceiveResponse) Transform(receive *models.FirebaseReceive) {
 	u.Code = receive.Code
 	u.Date = receive.Date
 	u.Remark = receive.Remark
+	u.Status = receive.Status
 	u.PurchaseID = receive.PurchaseID
 	u.CompanyID = receive.CompanyID
 	u.BranchID = receive.BranchID
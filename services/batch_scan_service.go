@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/nirshpaa/godam-backend/interfaces"
+	"github.com/nirshpaa/godam-backend/libraries/barcode"
+	"github.com/nirshpaa/godam-backend/libraries/imageproc"
+	"github.com/nirshpaa/godam-backend/libraries/workpool"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/types"
+)
+
+// Batch scan limits. Recognition is CPU bound and may call out to the CNN,
+// so only a few images of a batch are processed at once.
+const (
+	MaxBatchImages      = 100
+	batchScanWorkers    = 4
+	batchScanTimeout    = 30 * time.Second
+	batchReceivePrefix  = "SCAN-"
+	batchReceiveCodeLen = 8
+)
+
+// ErrNothingMatched is returned when a draft receive is asked for a batch
+// in which no image matched a product
+var ErrNothingMatched = errors.New("no image of the batch matched a product")
+
+// BatchImage is one image of a batch scan
+type BatchImage struct {
+	Name string
+	Data []byte
+}
+
+// BatchScanResult is the outcome of scanning one image of a batch. Index is
+// the position of the image in the batch, as results arrive out of order.
+type BatchScanResult struct {
+	Index       int                          `json:"index"`
+	Name        string                       `json:"name"`
+	ScanID      string                       `json:"scan_id,omitempty"`
+	Matched     bool                         `json:"matched"`
+	ProductCode string                       `json:"product_code,omitempty"`
+	Barcode     *barcode.Result              `json:"barcode,omitempty"`
+	Candidates  []types.RecognitionCandidate `json:"candidates"`
+	Data        interface{}                  `json:"data,omitempty"`
+	Error       string                       `json:"error,omitempty"`
+	DurationMS  int64                        `json:"duration_ms"`
+}
+
+// BatchScanService recognizes many images at once, as taken at a receiving dock
+type BatchScanService struct {
+	recognition interfaces.ImageRecognition
+	scans       *models.ScanFirebase
+	dataset     *DatasetService
+	receives    *models.ReceiveFirebase
+	products    *models.ProductFirebase
+}
+
+// NewBatchScanService creates a new BatchScanService
+func NewBatchScanService(recognition interfaces.ImageRecognition, scans *models.ScanFirebase, dataset *DatasetService, receives *models.ReceiveFirebase, products *models.ProductFirebase) *BatchScanService {
+	return &BatchScanService{
+		recognition: recognition,
+		scans:       scans,
+		dataset:     dataset,
+		receives:    receives,
+		products:    products,
+	}
+}
+
+// Scan recognizes the images of a batch and sends each result on results as
+// soon as it is ready. Every image is recorded as a scan of the batch, so it
+// can be confirmed like a single scan. results is closed when all are done.
+func (s *BatchScanService) Scan(ctx context.Context, batchID, userID string, images []BatchImage, results chan<- BatchScanResult) {
+	defer close(results)
+	workpool.Run(ctx, len(images), batchScanWorkers, batchScanTimeout, func(ctx context.Context, i int) {
+		started := time.Now()
+		result := s.scanImage(ctx, batchID, userID, images[i])
+		result.Index = i
+		result.Name = images[i].Name
+		result.DurationMS = time.Since(started).Milliseconds()
+		if result.Candidates == nil {
+			result.Candidates = []types.RecognitionCandidate{}
+		}
+		results <- result
+	})
+}
+
+// scanImage recognizes one image. Recognition runs under ctx, so once the
+// timeout passes it stops at the next stage or request instead of running on.
+func (s *BatchScanService) scanImage(ctx context.Context, batchID, userID string, image BatchImage) BatchScanResult {
+	format, err := imageproc.Sniff(image.Data)
+	if err != nil {
+		return BatchScanResult{Error: err.Error()}
+	}
+	file, err := os.CreateTemp("", "scan-*."+format)
+	if err != nil {
+		return BatchScanResult{Error: fmt.Sprintf("failed to create temporary file: %v", err)}
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(image.Data); err != nil {
+		file.Close()
+		return BatchScanResult{Error: fmt.Sprintf("failed to save image: %v", err)}
+	}
+	file.Close()
+
+	result := s.recognize(ctx, batchID, userID, file.Name())
+	if ctx.Err() == context.DeadlineExceeded {
+		return BatchScanResult{Error: fmt.Sprintf("recognition timed out after %s", batchScanTimeout)}
+	}
+	return result
+}
+
+func (s *BatchScanService) recognize(ctx context.Context, batchID, userID, imagePath string) BatchScanResult {
+	recognized, err := s.recognition.ProcessImage(ctx, imagePath)
+	if ctx.Err() != nil {
+		return BatchScanResult{Error: ctx.Err().Error()}
+	}
+	if err != nil {
+		return BatchScanResult{Error: fmt.Sprintf("failed to process image: %v", err)}
+	}
+
+	result := BatchScanResult{
+		Barcode:    recognized.Barcode,
+		Candidates: recognized.Candidates,
+		Data:       recognized.Data,
+	}
+	if recognized.Success && len(recognized.Candidates) > 0 && recognized.Candidates[0].ProductCode != "" {
+		result.Matched = true
+		result.ProductCode = recognized.Candidates[0].ProductCode
+	}
+
+	scan := &models.FirebaseScan{
+		UserID:     userID,
+		BatchID:    batchID,
+		Candidates: recognized.Candidates,
+		Accepted:   recognized.Success,
+	}
+	if recognized.Barcode != nil {
+		scan.Barcode = recognized.Barcode.Value
+	}
+	scanID, err := s.scans.Create(ctx, scan)
+	if err != nil {
+		log.Printf("Failed to record scan of batch %s: %v", batchID, err)
+		return result
+	}
+	result.ScanID = scanID
+	if err := s.dataset.KeepScanImage(ctx, scanID, imagePath); err != nil {
+		log.Printf("Failed to keep image of scan %s: %v", scanID, err)
+	}
+	return result
+}
+
+// DraftReceive creates a draft receive with one unit of each matched product
+// per image it was matched in, for the receiving clerk to review. Like other
+// receives, its details refer to products by document ID.
+func (s *BatchScanService) DraftReceive(ctx context.Context, batchID, purchaseID, shelveID string, results []BatchScanResult) (*models.FirebaseReceive, error) {
+	quantities := map[string]uint{}
+	for _, result := range results {
+		if result.Matched {
+			quantities[result.ProductCode]++
+		}
+	}
+	if len(quantities) == 0 {
+		return nil, ErrNothingMatched
+	}
+
+	codes := make([]string, 0, len(quantities))
+	for code := range quantities {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	productIDs, err := s.products.IDsByCode(ctx, codes)
+	if err != nil {
+		return nil, err
+	}
+
+	code := batchID
+	if len(code) > batchReceiveCodeLen {
+		code = code[:batchReceiveCodeLen]
+	}
+	receive := &models.FirebaseReceive{
+		Code:       batchReceivePrefix + code,
+		Date:       time.Now(),
+		Remark:     fmt.Sprintf("Draft from batch scan %s of %d images", batchID, len(results)),
+		Status:     models.ReceiveDraft,
+		PurchaseID: purchaseID,
+	}
+	for _, productCode := range codes {
+		// Products deleted since they were matched are left out
+		productID, ok := productIDs[productCode]
+		if !ok {
+			continue
+		}
+		receive.ReceiveDetails = append(receive.ReceiveDetails, models.FirebaseReceiveDetail{
+			ProductID: productID,
+			Qty:       quantities[productCode],
+			ShelveID:  shelveID,
+		})
+	}
+	if len(receive.ReceiveDetails) == 0 {
+		return nil, ErrNothingMatched
+	}
+
+	id, err := s.receives.Create(ctx, receive)
+	if err != nil {
+		return nil, err
+	}
+	receive.ID = id
+	return receive, nil
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nirshpaa/godam-backend/libraries/firestoretest"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/types"
+)
+
+// blockingRecognition recognizes nothing until ctx is done, like a chain
+// waiting on the CNN
+type blockingRecognition struct {
+	returned chan struct{}
+}
+
+func (r *blockingRecognition) ProcessImage(ctx context.Context, imagePath string) (*types.ImageRecognitionResult, error) {
+	defer close(r.returned)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (r *blockingRecognition) IndexProductImage(ctx context.Context, productCode, imageURL string) error {
+	return nil
+}
+
+func (r *blockingRecognition) ReindexProductImages(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+func TestBatchScanImageTimeout(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	recognition := &blockingRecognition{returned: make(chan struct{})}
+	service := NewBatchScanService(recognition, nil, nil, nil, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	result := service.scanImage(ctx, "batch-1", "user-1", BatchImage{Name: "a.png", Data: buf.Bytes()})
+	if !strings.Contains(result.Error, "timed out") {
+		t.Errorf("result = %+v, want a timeout", result)
+	}
+	select {
+	case <-recognition.returned:
+	default:
+		t.Error("recognition still running after the image timed out")
+	}
+}
+
+func TestBatchDraftReceiveUsesProductIDs(t *testing.T) {
+	client := firestoretest.New(t)
+	ctx := models.WithCompany(context.Background(), "company-a")
+	products, _ := models.NewProductFirebase(client)
+	ids := map[string]string{}
+	for _, code := range []string{"P1", "P2"} {
+		id, err := products.Create(ctx, &models.FirebaseProduct{Code: code, Name: "Product " + code}, nil)
+		if err != nil {
+			t.Fatalf("creating product: %v", err)
+		}
+		ids[code] = id
+	}
+	service := NewBatchScanService(nil, nil, nil, models.NewReceiveFirebase(client), products)
+
+	receive, err := service.DraftReceive(ctx, "batch-1", "", "shelf-1", []BatchScanResult{
+		{Matched: true, ProductCode: "P2"},
+		{Matched: true, ProductCode: "P1"},
+		{Matched: true, ProductCode: "P2"},
+		{Matched: true, ProductCode: "P404"},
+		{Matched: false},
+	})
+	if err != nil {
+		t.Fatalf("DraftReceive: %v", err)
+	}
+	if receive.Status != models.ReceiveDraft || len(receive.ReceiveDetails) != 2 {
+		t.Fatalf("receive = %+v, want a draft of the two known products", receive)
+	}
+	for i, want := range []struct {
+		code string
+		qty  uint
+	}{{"P1", 1}, {"P2", 2}} {
+		detail := receive.ReceiveDetails[i]
+		if detail.ProductID != ids[want.code] || detail.Qty != want.qty || detail.ShelveID != "shelf-1" {
+			t.Errorf("detail %d = %+v, want product %s (%s) x%d", i, detail, want.code, ids[want.code], want.qty)
+		}
+	}
+
+	if _, err := service.DraftReceive(ctx, "batch-2", "", "shelf-1", []BatchScanResult{{Matched: true, ProductCode: "P404"}}); err != ErrNothingMatched {
+		t.Errorf("DraftReceive of unknown products = %v, want ErrNothingMatched", err)
+	}
+}
@@ -0,0 +1,277 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math/bits"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/nirshpaa/godam-backend/libraries/dataset"
+	"github.com/nirshpaa/godam-backend/libraries/imagehash"
+	"github.com/nirshpaa/godam-backend/libraries/objectstore"
+	"github.com/nirshpaa/godam-backend/models"
+)
+
+// DefaultValPercent is the share of dataset images assigned to validation
+const DefaultValPercent = 20
+
+// duplicateDistance is how many hash bits an image may differ from another
+// image of the same product and still count as a duplicate
+const duplicateDistance = 4
+
+// ErrInvalidImage is returned for dataset uploads that are not a decodable image
+var ErrInvalidImage = errors.New("file is not a supported image")
+
+// DatasetService keeps product images as a managed training dataset. Files
+// are stored by company and are never overwritten, so snapshots stay
+// reproducible.
+type DatasetService struct {
+	dataset  *models.DatasetFirebase
+	products *models.ProductFirebase
+	scans    *models.ScanFirebase
+	store    objectstore.Store
+}
+
+// NewDatasetService creates a new DatasetService storing files in store
+func NewDatasetService(client *firestore.Client, store objectstore.Store) *DatasetService {
+	products, _ := models.NewProductFirebase(client)
+	return &DatasetService{
+		dataset:  models.NewDatasetFirebase(client),
+		products: products,
+		scans:    models.NewScanFirebase(client),
+		store:    store,
+	}
+}
+
+// AddImage stores data as a training image of a product. Quality flags are
+// raised automatically, including for near duplicates of the product's images.
+func (s *DatasetService) AddImage(ctx context.Context, productCode, source, scanID string, labels []string, data []byte) (*models.FirebaseDatasetImage, error) {
+	if _, err := s.products.Get(ctx, productCode); err != nil {
+		return nil, models.ErrNotFound
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	flags := dataset.Inspect(img)
+	hash := imagehash.PHash(img)
+	existing, err := s.dataset.ListImages(ctx, models.DatasetFilter{ProductCode: productCode})
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range existing {
+		if otherHash, err := imagehash.ParseHash(other.Hash); err == nil && bits.OnesCount64(hash^otherHash) <= duplicateDistance {
+			flags = append(flags, dataset.FlagDuplicate)
+			break
+		}
+	}
+
+	id := s.dataset.NewImageID()
+	file := path.Join(dataset.SafeName(models.CompanyFromContext(ctx)), "images", dataset.SafeName(productCode), id+"."+format)
+	if err := s.write(ctx, file, data); err != nil {
+		return nil, err
+	}
+
+	if labels == nil {
+		labels = []string{}
+	}
+	sum := sha256.Sum256(data)
+	record := &models.FirebaseDatasetImage{
+		ID:           id,
+		ProductCode:  productCode,
+		Source:       source,
+		ScanID:       scanID,
+		Labels:       labels,
+		QualityFlags: flags,
+		Split:        dataset.Split(id, DefaultValPercent),
+		File:         file,
+		Checksum:     hex.EncodeToString(sum[:]),
+		Hash:         imagehash.FormatHash(hash),
+		Width:        img.Bounds().Dx(),
+		Height:       img.Bounds().Dy(),
+	}
+	if err := s.dataset.CreateImage(ctx, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// KeepScanImage copies a scanned image into the dataset store so it can be
+// added once the scan is confirmed
+func (s *DatasetService) KeepScanImage(ctx context.Context, scanID, imagePath string) error {
+	data, err := os.ReadFile(imagePath)
+	if err != nil {
+		return err
+	}
+
+	file := path.Join(dataset.SafeName(models.CompanyFromContext(ctx)), "scans", scanID+filepath.Ext(imagePath))
+	if err := s.write(ctx, file, data); err != nil {
+		return err
+	}
+	return s.scans.SetImage(ctx, scanID, file)
+}
+
+// AddConfirmedScan adds the image of a confirmed scan to the dataset under
+// the confirmed product. Confirming a scan again moves its image to the new
+// product by excluding the earlier one.
+func (s *DatasetService) AddConfirmedScan(ctx context.Context, scan *models.FirebaseScan) (*models.FirebaseDatasetImage, error) {
+	if scan.ImageFile == "" || scan.ConfirmedProductCode == "" {
+		return nil, nil
+	}
+
+	added, err := s.dataset.ListImages(ctx, models.DatasetFilter{ScanID: scan.ID})
+	if err != nil {
+		return nil, err
+	}
+	for _, image := range added {
+		if image.ProductCode == scan.ConfirmedProductCode {
+			return image, nil
+		}
+		image.Excluded = true
+		if err := s.dataset.UpdateImage(ctx, image.ID, image); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := s.read(ctx, scan.ImageFile)
+	if err != nil {
+		return nil, err
+	}
+	return s.AddImage(ctx, scan.ConfirmedProductCode, models.DatasetSourceScan, scan.ID, nil, data)
+}
+
+// CreateSnapshot freezes the images that are not excluded into the next
+// dataset version
+func (s *DatasetService) CreateSnapshot(ctx context.Context, note string) (*models.FirebaseDatasetSnapshot, error) {
+	images, err := s.dataset.ListImages(ctx, models.DatasetFilter{})
+	if err != nil {
+		return nil, err
+	}
+	products, err := s.products.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(products))
+	for _, product := range products {
+		names[product.Code] = product.Name
+	}
+
+	entries := make([]dataset.Entry, 0, len(images))
+	for _, image := range images {
+		entries = append(entries, dataset.Entry{
+			ID:           image.ID,
+			ProductCode:  image.ProductCode,
+			Label:        image.ProductCode,
+			Split:        image.Split,
+			Source:       image.Source,
+			Labels:       image.Labels,
+			QualityFlags: image.QualityFlags,
+			File:         image.File,
+			Checksum:     image.Checksum,
+		})
+	}
+
+	version, err := s.dataset.NextSnapshotVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	manifest := dataset.NewManifest(entries, names)
+	manifest.SnapshotID = s.dataset.NewSnapshotID()
+	manifest.CompanyID = models.CompanyFromContext(ctx)
+	manifest.Version = version
+	manifest.Note = note
+	manifest.CreatedAt = time.Now()
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %v", err)
+	}
+	file := path.Join(dataset.SafeName(manifest.CompanyID), "snapshots", fmt.Sprintf("v%d-%s.json", version, manifest.SnapshotID))
+	if err := s.write(ctx, file, data); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	snapshot := &models.FirebaseDatasetSnapshot{
+		ID:        manifest.SnapshotID,
+		Version:   version,
+		Note:      note,
+		Images:    len(manifest.Images),
+		Classes:   len(manifest.Classes),
+		Manifest:  file,
+		Checksum:  hex.EncodeToString(sum[:]),
+		CreatedAt: manifest.CreatedAt,
+	}
+	for _, class := range manifest.Classes {
+		snapshot.Train += class.Train
+		snapshot.Val += class.Val
+	}
+	if err := s.dataset.CreateSnapshot(ctx, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Manifest loads the manifest of a snapshot after checking it was not changed
+func (s *DatasetService) Manifest(ctx context.Context, snapshotID string) (*models.FirebaseDatasetSnapshot, *dataset.Manifest, error) {
+	snapshot, err := s.dataset.GetSnapshot(ctx, snapshotID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := s.read(ctx, snapshot.Manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != snapshot.Checksum {
+		return nil, nil, fmt.Errorf("manifest of snapshot %s does not match its checksum", snapshotID)
+	}
+
+	var manifest dataset.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse manifest: %v", err)
+	}
+	return snapshot, &manifest, nil
+}
+
+// Export writes a snapshot manifest and its images as an ImageFolder zip
+func (s *DatasetService) Export(ctx context.Context, w io.Writer, manifest *dataset.Manifest) error {
+	return dataset.WriteZip(w, *manifest, func(file string) (io.ReadCloser, error) {
+		return s.store.Get(ctx, file)
+	})
+}
+
+func (s *DatasetService) read(ctx context.Context, file string) ([]byte, error) {
+	data, err := objectstore.ReadAll(ctx, s.store, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dataset file: %v", err)
+	}
+	return data, nil
+}
+
+// write stores a new dataset file, refusing to replace an existing one
+func (s *DatasetService) write(ctx context.Context, file string, data []byte) error {
+	if _, err := s.store.Stat(ctx, file); err == nil {
+		return fmt.Errorf("dataset file %s already exists", file)
+	} else if err != objectstore.ErrNotFound {
+		return err
+	}
+	if err := s.store.Put(ctx, file, data, mime.TypeByExtension(path.Ext(file))); err != nil {
+		return fmt.Errorf("failed to write dataset file: %v", err)
+	}
+	return nil
+}
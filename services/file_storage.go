@@ -1,29 +1,40 @@
 package services
 
 import (
+	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"mime"
 	"mime/multipart"
-	"os"
-	"path/filepath"
+	"path"
 	"strings"
-	"time"
+
+	"github.com/nirshpaa/godam-backend/libraries/imageproc"
+	"github.com/nirshpaa/godam-backend/libraries/objectstore"
 )
 
-// FileStorageService handles local file storage operations
+// maxImageSize limits the size of a stored upload
+const maxImageSize = 20 << 20
+
+// FileStorageService stores uploaded images in an object store
 type FileStorageService struct {
-	basePath string
+	store   objectstore.Store
+	options imageproc.Options
 }
 
 // NewFileStorageService creates a new file storage service
-func NewFileStorageService(basePath string) *FileStorageService {
+func NewFileStorageService(store objectstore.Store) *FileStorageService {
 	return &FileStorageService{
-		basePath: basePath,
+		store:   store,
+		options: imageproc.DefaultOptions,
 	}
 }
 
-// SaveBase64Image saves a base64 encoded image to the local filesystem
+// SaveBase64Image saves a base64 encoded image in the object store and
+// returns the path of the stored image
 func (s *FileStorageService) SaveBase64Image(base64Data, folder string) (string, error) {
 	// Remove the data URL prefix if present
 	if len(base64Data) > 0 && base64Data[0] == 'd' {
@@ -40,29 +51,15 @@ func (s *FileStorageService) SaveBase64Image(base64Data, folder string) (string,
 		return "", fmt.Errorf("failed to decode base64 data: %v", err)
 	}
 
-	// Create a unique filename
-	filename := fmt.Sprintf("%d-%s.jpg", time.Now().Unix(), generateUniqueString(8))
-
-	// Create the full directory path
-	dirPath := filepath.Join(s.basePath, folder)
-	if err := os.MkdirAll(dirPath, 0755); err != nil {
-		return "", fmt.Errorf("failed to create directory: %v", err)
-	}
-
-	// Create the full file path
-	fullPath := filepath.Join(dirPath, filename)
-
-	// Write the file
-	if err := os.WriteFile(fullPath, imageData, 0644); err != nil {
-		return "", fmt.Errorf("failed to write image file: %v", err)
+	paths, err := s.SaveImage(context.Background(), imageData, folder)
+	if err != nil {
+		return "", err
 	}
-
-	// Return the relative path that can be used in URLs
-	relativePath := filepath.Join(folder, filename)
-	return relativePath, nil
+	return paths.Image, nil
 }
 
-// SaveFile saves a file directly to the local filesystem
+// SaveFile saves an uploaded image to the object store and returns the
+// path of the stored image
 func (s *FileStorageService) SaveFile(file interface{}, folder string) (string, error) {
 	// Type assert to *multipart.FileHeader
 	fileHeader, ok := file.(*multipart.FileHeader)
@@ -70,15 +67,6 @@ func (s *FileStorageService) SaveFile(file interface{}, folder string) (string,
 		return "", fmt.Errorf("invalid file type: expected *multipart.FileHeader")
 	}
 
-	// Create the full directory path
-	dirPath := filepath.Join(s.basePath, folder)
-	if err := os.MkdirAll(dirPath, 0755); err != nil {
-		return "", fmt.Errorf("failed to create directory: %v", err)
-	}
-
-	// Create the full file path
-	fullPath := filepath.Join(dirPath, filepath.Base(fileHeader.Filename))
-
 	// Open the source file
 	src, err := fileHeader.Open()
 	if err != nil {
@@ -86,29 +74,64 @@ func (s *FileStorageService) SaveFile(file interface{}, folder string) (string,
 	}
 	defer src.Close()
 
-	// Create the destination file
-	dst, err := os.Create(fullPath)
+	data, err := io.ReadAll(io.LimitReader(src, maxImageSize+1))
 	if err != nil {
-		return "", fmt.Errorf("failed to create destination file: %v", err)
+		return "", fmt.Errorf("failed to read file: %v", err)
 	}
-	defer dst.Close()
 
-	// Copy the file contents
-	if _, err := io.Copy(dst, src); err != nil {
-		return "", fmt.Errorf("failed to copy file: %v", err)
+	paths, err := s.SaveImage(context.Background(), data, folder)
+	if err != nil {
+		return "", err
 	}
+	return paths.Image, nil
+}
 
-	// Return the relative path that can be used in URLs
-	relativePath := filepath.Join(folder, filepath.Base(fileHeader.Filename))
-	return relativePath, nil
+// SaveImage validates an image by its content and stores it upright and
+// resized with a thumbnail and WebP variants. Files are named by the content
+// hash of the upload, so uploading the same file again reuses them.
+// The returned paths are keys in the object store.
+func (s *FileStorageService) SaveImage(ctx context.Context, data []byte, folder string) (*imageproc.Paths, error) {
+	if len(data) > maxImageSize {
+		return nil, fmt.Errorf("image is larger than %d MB", maxImageSize>>20)
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	for _, ext := range []string{".jpg", ".png"} {
+		key := path.Join(folder, hash+ext)
+		if _, err := s.store.Stat(ctx, key); err == nil {
+			return imageproc.VariantPaths(key), nil
+		} else if err != objectstore.ErrNotFound {
+			return nil, err
+		}
+	}
+
+	variants, err := imageproc.Process(data, s.options)
+	if err != nil {
+		return nil, err
+	}
+
+	// The image itself is stored last, so finding it means its variants exist
+	var image imageproc.Variant
+	for _, v := range variants {
+		if v.Name == imageproc.VariantImage {
+			image = v
+			continue
+		}
+		if err := s.put(ctx, path.Join(folder, hash+v.Suffix+v.Ext), v.Data); err != nil {
+			return nil, err
+		}
+	}
+	key := path.Join(folder, hash+image.Ext)
+	if err := s.put(ctx, key, image.Data); err != nil {
+		return nil, err
+	}
+	return imageproc.VariantPaths(key), nil
 }
 
-// generateUniqueString generates a random string of the specified length
-func generateUniqueString(length int) string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[time.Now().UnixNano()%int64(len(charset))]
+func (s *FileStorageService) put(ctx context.Context, key string, data []byte) error {
+	if err := s.store.Put(ctx, key, data, mime.TypeByExtension(path.Ext(key))); err != nil {
+		return fmt.Errorf("failed to store image: %v", err)
 	}
-	return string(b)
+	return nil
 }
@@ -2,7 +2,6 @@ package services
 
 import (
 	"context"
-	"encoding/base64"
 	"fmt"
 	"log"
 	"os"
@@ -11,10 +10,8 @@ import (
 	"time"
 
 	"cloud.google.com/go/firestore"
-	"cloud.google.com/go/storage"
 	firebase "firebase.google.com/go/v4"
 	"firebase.google.com/go/v4/auth"
-	fbstorage "firebase.google.com/go/v4/storage"
 	"google.golang.org/api/option"
 )
 
@@ -31,7 +28,6 @@ type FirebaseService struct {
 	app        *firebase.App
 	authClient *auth.Client
 	db         *firestore.Client
-	storage    *fbstorage.Client
 	mu         sync.RWMutex
 	ctx        context.Context
 	cancel     context.CancelFunc
@@ -267,74 +263,3 @@ func (s *FirebaseService) UpdateUserCompany(ctx context.Context, userID, company
 	})
 	return err
 }
-
-// UploadBase64Image uploads a base64 encoded image to Firebase Storage
-func (s *FirebaseService) UploadBase64Image(ctx context.Context, base64Data, folder, filename string) (string, error) {
-	if s.storage == nil {
-		var err error
-		s.storage, err = s.app.Storage(ctx)
-		if err != nil {
-			return "", fmt.Errorf("failed to initialize storage client: %v", err)
-		}
-	}
-
-	// Decode base64 data
-	imageData, err := base64.StdEncoding.DecodeString(base64Data)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode base64 data: %v", err)
-	}
-
-	// Create a unique filename if none provided
-	if filename == "" {
-		filename = fmt.Sprintf("%d-%s.jpg", time.Now().Unix(), generateRandomString(8))
-	}
-
-	// Create the full path including folder
-	fullPath := fmt.Sprintf("%s/%s", folder, filename)
-
-	// Get bucket handle
-	bucket, err := s.storage.DefaultBucket()
-	if err != nil {
-		return "", fmt.Errorf("failed to get default bucket: %v", err)
-	}
-
-	// Create the file in Firebase Storage
-	obj := bucket.Object(fullPath)
-	writer := obj.NewWriter(ctx)
-
-	// Set content type
-	writer.ContentType = "image/jpeg"
-
-	// Write the decoded image data
-	if _, err := writer.Write(imageData); err != nil {
-		return "", fmt.Errorf("failed to write image to storage: %v", err)
-	}
-
-	// Close the writer
-	if err := writer.Close(); err != nil {
-		return "", fmt.Errorf("failed to close writer: %v", err)
-	}
-
-	// Make the file public
-	if err := obj.ACL().Set(ctx, storage.AllUsers, storage.RoleReader); err != nil {
-		return "", fmt.Errorf("failed to make file public: %v", err)
-	}
-
-	// Get the public URL
-	attrs, err := obj.Attrs(ctx)
-	if err != nil {
-		return "", fmt.Errorf("failed to get object attributes: %v", err)
-	}
-
-	return attrs.MediaLink, nil
-}
-
-// generateRandomString generates a random string of the specified length
-func generateRandomString(length int) string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[time.Now().UnixNano()%int64(len(charset))]
-	}
-	return string(b)
-}
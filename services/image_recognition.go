@@ -1,36 +1,34 @@
 package services
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"image"
-	"image/color"
-	"image/png"
-	"io"
-	"mime/multipart"
-	"net/http"
+	"log"
 	"os"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"github.com/disintegration/imaging"
+	"github.com/nirshpaa/godam-backend/libraries/barcode"
+	"github.com/nirshpaa/godam-backend/libraries/objectstore"
+	"github.com/nirshpaa/godam-backend/libraries/workpool"
+	"github.com/nirshpaa/godam-backend/models"
 	"github.com/nirshpaa/godam-backend/types"
 )
 
-// ImageRecognitionService handles image processing and recognition
+// ImageRecognitionService handles image processing and recognition. Scans go
+// through a chain of recognizers: the barcode, then the local visual matcher
+// and only when neither is confident the remote CNN.
 type ImageRecognitionService struct {
 	Client             *firestore.Client
 	BarcodeAPIEndpoint string
 	CNNAPIEndpoint     string
-}
-
-// Product represents a product in the database
-type Product struct {
-	ID           string
-	Name         string
-	BarcodeValue string
+	barcodes           *barcode.Decoder
+	matcher            *VisualMatcher
+	chain              *RecognizerChain
+	settings           *models.RecognitionSettingsFirebase
 }
 
 // RecognitionResult represents the result of image processing
@@ -39,307 +37,179 @@ type RecognitionResult struct {
 	RecognitionData    string `json:"recognition_data"`
 }
 
-// NewImageRecognitionService creates a new image recognition service
-func NewImageRecognitionService(client *firestore.Client, barcodeEndpoint, cnnEndpoint string) *ImageRecognitionService {
+// NewImageRecognitionService creates a new image recognition service.
+// Relative product image URLs are read from images.
+func NewImageRecognitionService(client *firestore.Client, barcodeEndpoint, cnnEndpoint string, images objectstore.Store) *ImageRecognitionService {
+	products, _ := models.NewProductFirebase(client)
+	matcher := NewVisualMatcher(models.NewFingerprintFirebase(client), products, images)
+	settings := models.NewRecognitionSettingsFirebase(client)
+	defaults := models.DefaultRecognitionSettings
 	return &ImageRecognitionService{
 		Client:             client,
 		BarcodeAPIEndpoint: barcodeEndpoint,
 		CNNAPIEndpoint:     cnnEndpoint,
+		barcodes:           barcode.NewDecoder(),
+		matcher:            matcher,
+		chain: NewRecognizerChain(
+			RecognizerStage{Recognizer: NewBarcodeRecognizer(products), Threshold: 1},
+			RecognizerStage{Recognizer: matcher, Threshold: defaults.VisualThreshold},
+			RecognizerStage{Recognizer: NewCNNRecognizer(cnnEndpoint, products, settings, models.NewTrainingFirebase(client)), Threshold: defaults.CNNThreshold},
+		),
+		settings: settings,
 	}
 }
 
-// ProcessImage processes an image for product recognition
-func (s *ImageRecognitionService) ProcessImage(imagePath string) (*types.ImageRecognitionResult, error) {
-	// Try to scan barcode first
-	barcode, err := s.scanBarcode(imagePath)
-	if err == nil && barcode != "" {
-		// If barcode is found, try to find the product
-		productID, err := s.FindProductByBarcode(barcode)
-		if err == nil && productID != "" {
-			return &types.ImageRecognitionResult{
-				Success: true,
-				Data:    productID,
-			}, nil
-		}
-	}
-
-	// If barcode scanning fails, try CNN recognition
-	recognitionData, err := s.recognizeImage(imagePath)
+// ProcessImage processes an image for product recognition. A confident match
+// returns the product as JSON; otherwise the data offers creating a product
+// named after the best candidate.
+func (s *ImageRecognitionService) ProcessImage(ctx context.Context, imagePath string) (*types.ImageRecognitionResult, error) {
+	img, err := openImage(imagePath)
 	if err != nil {
 		return &types.ImageRecognitionResult{
 			Success: false,
 			Data:    "",
-		}, fmt.Errorf("failed to recognize image: %v", err)
+		}, err
 	}
 
-	// Parse the recognition data
-	var data struct {
-		Class      string  `json:"class"`
-		Confidence float64 `json:"confidence"`
+	scan := &types.Scan{Image: img}
+	if scanned, err := s.barcodes.Decode(img); err == nil {
+		scan.Barcode = scanned
 	}
-	if err := json.Unmarshal([]byte(recognitionData), &data); err != nil {
+
+	// The company's thresholds apply; the defaults do when they cannot be read
+	settings, err := s.settings.Get(ctx)
+	if err != nil {
+		settings = &models.DefaultRecognitionSettings
+	}
+	thresholds := map[string]float64{
+		SourceVisual: settings.VisualThreshold,
+		SourceCNN:    settings.CNNThreshold,
+	}
+
+	candidates, accepted, err := s.chain.Recognize(ctx, scan, settings.Candidates, thresholds)
+	if err != nil && scan.Barcode == nil {
 		return &types.ImageRecognitionResult{
 			Success: false,
-			Data:    recognitionData,
-		}, nil
+			Data:    "",
+		}, fmt.Errorf("failed to recognize image: %v", err)
 	}
 
-	// If confidence is above 0.5, try to find the product
-	if data.Confidence > 0.5 {
-		productID, err := s.FindProductByName(data.Class)
-		if err == nil && productID != "" {
-			return &types.ImageRecognitionResult{
-				Success: true,
-				Data:    productID,
-			}, nil
+	result := &types.ImageRecognitionResult{
+		Barcode:    scan.Barcode,
+		Candidates: candidates,
+	}
+	if accepted {
+		productInfo, err := json.Marshal(candidates[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal product info: %v", err)
 		}
+		result.Success = true
+		result.Data = string(productInfo)
+		return result, nil
 	}
 
 	// If no product found or confidence is low, return create product action
 	createProductData := types.CreateProductData{
-		Action:        "create_product",
-		SuggestedName: data.Class,
-		Confidence:    data.Confidence,
+		Action: "create_product",
 	}
-
-	return &types.ImageRecognitionResult{
-		Success: false,
-		Data:    createProductData,
-	}, nil
-}
-
-// scanBarcode attempts to scan a barcode from an image
-func (s *ImageRecognitionService) scanBarcode(imagePath string) (string, error) {
-	// Implementation of barcode scanning
-	return "", fmt.Errorf("barcode not found")
-}
-
-// recognizeImage uses CNN to recognize the image
-func (s *ImageRecognitionService) recognizeImage(imagePath string) (string, error) {
-	result, err := s.recognizeWithCNN(imagePath)
-	if err != nil {
-		return "", err
+	if len(candidates) > 0 {
+		createProductData.SuggestedName = candidates[0].Name
+		createProductData.Confidence = candidates[0].Score
+	}
+	if scan.Barcode != nil {
+		createProductData.Barcode = scan.Barcode.Value
 	}
-	return result.RecognitionData, nil
+	result.Data = createProductData
+	return result, nil
 }
 
-// ScanBarcode attempts to detect and read a barcode from an image
-func (s *ImageRecognitionService) ScanBarcode(filePath string) (*RecognitionResult, error) {
-	result := RecognitionResult{}
+// Regions of a shelf photo are small, so more are recognized at once than
+// images of a batch scan
+const (
+	regionWorkers = 8
+	regionTimeout = 20 * time.Second
+)
 
-	// Open the image file
-	file, err := os.Open(filePath)
+// RecognizeRegions recognizes the product in each region of an image and
+// returns the code of the accepted product per region, empty where none was
+// accepted. Regions still running when ctx is done are left empty.
+func (s *ImageRecognitionService) RecognizeRegions(ctx context.Context, img image.Image, regions []image.Rectangle) []string {
+	settings, err := s.settings.Get(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open image file: %v", err)
+		settings = &models.DefaultRecognitionSettings
 	}
-	defer file.Close()
-
-	// Decode the image
-	img, _, err := image.Decode(file)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode image: %v", err)
+	thresholds := map[string]float64{
+		SourceVisual: settings.VisualThreshold,
+		SourceCNN:    settings.CNNThreshold,
 	}
 
-	// Convert to grayscale for better barcode detection
-	bounds := img.Bounds()
-	gray := image.NewGray(bounds)
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			gray.Set(x, y, img.At(x, y))
+	codes := make([]string, len(regions))
+	workpool.Run(ctx, len(regions), regionWorkers, regionTimeout, func(ctx context.Context, i int) {
+		scan := &types.Scan{Image: imaging.Crop(img, regions[i])}
+		if scanned, err := s.barcodes.Decode(scan.Image); err == nil {
+			scan.Barcode = scanned
 		}
-	}
-
-	// Detect barcode
-	barcode, err := detectBarcode(gray)
-	if err != nil {
-		return nil, err
-	}
-
-	result.RecognitionSuccess = true
-	result.RecognitionData = fmt.Sprintf(`{"barcode":"%s"}`, barcode)
-	return &result, nil
+		candidates, accepted, err := s.chain.Recognize(ctx, scan, settings.Candidates, thresholds)
+		if err != nil {
+			log.Printf("Failed to recognize region %d: %v", i, err)
+			return
+		}
+		if accepted && ctx.Err() == nil {
+			codes[i] = candidates[0].ProductCode
+		}
+	})
+	return codes
 }
 
-// Helper function to detect barcode in grayscale image
-func detectBarcode(img *image.Gray) (string, error) {
-	// Implement barcode detection algorithm here
-	// This could be using edge detection, pattern matching, etc.
-	// For now, we'll return a placeholder
-	return "", errors.New("barcode detection not implemented")
+// IndexProductImage adds a product reference image to the local visual matcher
+func (s *ImageRecognitionService) IndexProductImage(ctx context.Context, productCode, imageURL string) error {
+	return s.matcher.Index(ctx, productCode, imageURL)
 }
 
-// recognizeWithCNN uses a CNN-based model to recognize the product in the image
-func (s *ImageRecognitionService) recognizeWithCNN(filePath string) (*RecognitionResult, error) {
-	result := RecognitionResult{}
-
-	// Open the image file
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open image file: %v", err)
-	}
-	defer file.Close()
-
-	// Decode the image
-	img, _, err := image.Decode(file)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode image: %v", err)
-	}
-
-	// Resize image to 224x224
-	resized := imaging.Resize(img, 224, 224, imaging.Lanczos)
-
-	// Convert NRGBA to RGB
-	bounds := resized.Bounds()
-	rgb := image.NewRGBA(bounds)
-
-	// Copy and convert NRGBA to RGB
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			r, g, b, _ := resized.At(x, y).RGBA()
-			rgb.Set(x, y, color.RGBA{
-				uint8(r >> 8),
-				uint8(g >> 8),
-				uint8(b >> 8),
-				255,
-			})
-		}
-	}
-
-	// Create a new buffer for the file content
-	buffer := &bytes.Buffer{}
-	writer := multipart.NewWriter(buffer)
-
-	// Create form file
-	part, err := writer.CreateFormFile("image", "processed_image.png")
-	if err != nil {
-		return nil, err
-	}
-
-	// Encode the processed image as PNG
-	if err := png.Encode(part, rgb); err != nil {
-		return nil, fmt.Errorf("failed to encode processed image: %v", err)
-	}
-
-	// Close the writer
-	writer.Close()
-
-	// Create request to Python API
-	req, err := http.NewRequest("POST", s.CNNAPIEndpoint, buffer)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-
-	// Send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	// Check response status code
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("CNN API returned non-200 status: %d", resp.StatusCode)
-	}
+// ReindexProductImages rebuilds the local visual matcher from the product images
+func (s *ImageRecognitionService) ReindexProductImages(ctx context.Context) (int, error) {
+	return s.matcher.Reindex(ctx)
+}
 
-	// Read response body
-	respBody, err := io.ReadAll(resp.Body)
+// scanBarcode decodes the first barcode in an image
+func (s *ImageRecognitionService) scanBarcode(imagePath string) (*barcode.Result, error) {
+	img, err := openImage(imagePath)
 	if err != nil {
 		return nil, err
 	}
-
-	// Parse response from Python API
-	var cnnResp struct {
-		Success bool            `json:"success"`
-		Results json.RawMessage `json:"results"`
-		Error   string          `json:"error"`
-	}
-
-	if err := json.Unmarshal(respBody, &cnnResp); err != nil {
-		return nil, err
-	}
-
-	if !cnnResp.Success {
-		return nil, errors.New(cnnResp.Error)
-	}
-
-	// Return the JSON string of recognition results
-	result.RecognitionData = string(cnnResp.Results)
-	result.RecognitionSuccess = true
-	return &result, nil
+	return s.barcodes.Decode(img)
 }
 
-// FindProductByBarcode finds a product by its barcode in Firestore
-func (s *ImageRecognitionService) FindProductByBarcode(barcode string) (string, error) {
-	ctx := context.Background()
-
-	// Query Firestore for products with matching barcode
-	iter := s.Client.Collection("products").Where("barcode_value", "==", barcode).Documents(ctx)
-	docs, err := iter.GetAll()
+// openImage decodes an image file
+func openImage(imagePath string) (image.Image, error) {
+	file, err := os.Open(imagePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to query products: %v", err)
-	}
-
-	if len(docs) == 0 {
-		return "", fmt.Errorf("no product found with barcode: %s", barcode)
-	}
-
-	// Get the first matching product
-	doc := docs[0]
-	var product Product
-	if err := doc.DataTo(&product); err != nil {
-		return "", fmt.Errorf("failed to parse product data: %v", err)
-	}
-
-	// Return product info as JSON
-	productInfo := map[string]interface{}{
-		"id":            product.ID,
-		"name":          product.Name,
-		"barcode_value": product.BarcodeValue,
+		return nil, fmt.Errorf("failed to open image file: %v", err)
 	}
+	defer file.Close()
 
-	jsonData, err := json.Marshal(productInfo)
+	img, _, err := image.Decode(file)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal product info: %v", err)
+		return nil, fmt.Errorf("failed to decode image: %v", err)
 	}
-
-	return string(jsonData), nil
+	return img, nil
 }
 
-// FindProductByName finds a product by its name in Firestore
-func (s *ImageRecognitionService) FindProductByName(name string) (string, error) {
-	ctx := context.Background()
-
-	// Query Firestore for products with matching name
-	iter := s.Client.Collection("products").Where("name", "==", name).Documents(ctx)
-	docs, err := iter.GetAll()
+// ScanBarcode attempts to detect and read a barcode from an image. The
+// recognition data holds the value, symbology and bounding box as JSON.
+func (s *ImageRecognitionService) ScanBarcode(filePath string) (*RecognitionResult, error) {
+	scanned, err := s.scanBarcode(filePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to query products: %v", err)
-	}
-
-	if len(docs) == 0 {
-		return "", fmt.Errorf("no product found with name: %s", name)
-	}
-
-	// Get the first matching product
-	doc := docs[0]
-	var product Product
-	if err := doc.DataTo(&product); err != nil {
-		return "", fmt.Errorf("failed to parse product data: %v", err)
-	}
-
-	// Return product info as JSON
-	productInfo := map[string]interface{}{
-		"product_code": product.ID,
-		"name":         product.Name,
+		return nil, err
 	}
 
-	jsonData, err := json.Marshal(productInfo)
+	data, err := json.Marshal(scanned)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal product info: %v", err)
+		return nil, fmt.Errorf("failed to marshal barcode: %v", err)
 	}
 
-	return string(jsonData), nil
+	return &RecognitionResult{
+		RecognitionSuccess: true,
+		RecognitionData:    string(data),
+	}, nil
 }
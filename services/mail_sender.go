@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nirshpaa/godam-backend/interfaces"
+)
+
+// SMTPMailSender delivers email through an SMTP server
+type SMTPMailSender struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailSender creates a new SMTPMailSender. Authentication is skipped when username is empty.
+func NewSMTPMailSender(host, port, username, password, from string) *SMTPMailSender {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPMailSender{
+		addr: host + ":" + port,
+		from: from,
+		auth: auth,
+	}
+}
+
+// Send delivers a message
+func (s *SMTPMailSender) Send(ctx context.Context, mail interfaces.Mail) error {
+	if err := smtp.SendMail(s.addr, s.auth, s.from, []string{mail.To}, formatMail(s.from, mail)); err != nil {
+		return fmt.Errorf("failed to send mail: %v", err)
+	}
+	return nil
+}
+
+// FileMailSender writes each message to a file instead of sending it, for
+// local development and tests
+type FileMailSender struct {
+	dir  string
+	from string
+}
+
+// NewFileMailSender creates a new FileMailSender writing into dir
+func NewFileMailSender(dir, from string) *FileMailSender {
+	return &FileMailSender{
+		dir:  dir,
+		from: from,
+	}
+}
+
+// Send writes a message to an .eml file
+func (s *FileMailSender) Send(ctx context.Context, mail interfaces.Mail) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create mail directory: %v", err)
+	}
+
+	name := fmt.Sprintf("%d_%s.eml", time.Now().UnixNano(), strings.NewReplacer("@", "_at_", "/", "_").Replace(mail.To))
+	if err := os.WriteFile(filepath.Join(s.dir, name), formatMail(s.from, mail), 0644); err != nil {
+		return fmt.Errorf("failed to write mail: %v", err)
+	}
+	return nil
+}
+
+// NewMailSenderFromEnv picks a mail sender from MAIL_DRIVER: "smtp" sends
+// through SMTP_HOST, anything else writes messages into MAIL_DIR
+func NewMailSenderFromEnv() interfaces.MailSender {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@godam.local"
+	}
+
+	if os.Getenv("MAIL_DRIVER") == "smtp" {
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return NewSMTPMailSender(os.Getenv("SMTP_HOST"), port, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), from)
+	}
+
+	dir := os.Getenv("MAIL_DIR")
+	if dir == "" {
+		dir = "./storage/mail"
+	}
+	return NewFileMailSender(dir, from)
+}
+
+// formatMail renders a message with its headers, dropping line breaks from header values
+func formatMail(from string, mail interfaces.Mail) []byte {
+	header := strings.NewReplacer("\r", "", "\n", " ")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", header.Replace(from))
+	fmt.Fprintf(&b, "To: %s\r\n", header.Replace(mail.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", header.Replace(mail.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
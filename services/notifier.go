@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nirshpaa/godam-backend/interfaces"
+)
+
+// MailNotifier delivers notifications by email, one message per recipient
+type MailNotifier struct {
+	mailer interfaces.MailSender
+}
+
+// NewMailNotifier creates a new MailNotifier
+func NewMailNotifier(mailer interfaces.MailSender) *MailNotifier {
+	return &MailNotifier{
+		mailer: mailer,
+	}
+}
+
+// Notify emails the notification to each of its recipients
+func (n *MailNotifier) Notify(ctx context.Context, notification interfaces.Notification) error {
+	var failed int
+	var lastErr error
+	for _, recipient := range notification.Recipients {
+		err := n.mailer.Send(ctx, interfaces.Mail{
+			To:      recipient,
+			Subject: notification.Subject,
+			Body:    notification.Body,
+		})
+		if err != nil {
+			failed++
+			lastErr = err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to notify %d of %d recipients: %v", failed, len(notification.Recipients), lastErr)
+	}
+	return nil
+}
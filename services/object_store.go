@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nirshpaa/godam-backend/config"
+	"github.com/nirshpaa/godam-backend/libraries/objectstore"
+)
+
+// Storage areas. Locally each has its own directory; in a bucket the area
+// is the key prefix.
+const (
+	StorageProducts = "products"
+	StorageDataset  = "dataset"
+)
+
+// StorageAreas lists every storage area, in the order they are migrated
+var StorageAreas = []string{StorageProducts, StorageDataset}
+
+// NewObjectStore opens the store of a storage area on the backend
+// configured in cfg
+func NewObjectStore(ctx context.Context, cfg *config.Config, area string) (objectstore.Store, error) {
+	storeConfig := objectstore.Config{
+		Backend:            cfg.StorageBackend,
+		S3:                 cfg.S3,
+		GCSBucket:          cfg.StorageBucket,
+		GCSCredentialsFile: cfg.CredentialsFile,
+		Prefix:             area,
+	}
+	switch area {
+	case StorageProducts:
+		storeConfig.LocalRoot = cfg.UploadPath
+		storeConfig.LocalURL = cfg.APIURL + "/uploads"
+	case StorageDataset:
+		storeConfig.LocalRoot = cfg.DatasetPath
+	default:
+		return nil, fmt.Errorf("unknown storage area %q", area)
+	}
+	return objectstore.Open(ctx, storeConfig)
+}
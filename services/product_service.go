@@ -2,6 +2,7 @@ package services
 
 import (
 	"cloud.google.com/go/firestore"
+	"github.com/nirshpaa/godam-backend/libraries/objectstore"
 )
 
 type ProductService struct {
@@ -9,9 +10,9 @@ type ProductService struct {
 	FileStorage      *FileStorageService
 }
 
-func NewProductService(client *firestore.Client, barcodeEndpoint, cnnEndpoint string) *ProductService {
+func NewProductService(client *firestore.Client, barcodeEndpoint, cnnEndpoint string, images objectstore.Store) *ProductService {
 	return &ProductService{
-		ImageRecognition: NewImageRecognitionService(client, barcodeEndpoint, cnnEndpoint),
-		FileStorage:      NewFileStorageService("assets"),
+		ImageRecognition: NewImageRecognitionService(client, barcodeEndpoint, cnnEndpoint, images),
+		FileStorage:      NewFileStorageService(images),
 	}
 }
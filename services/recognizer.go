@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sort"
+
+	"github.com/nirshpaa/godam-backend/interfaces"
+	"github.com/nirshpaa/godam-backend/types"
+)
+
+// Recognition sources reported on candidates
+const (
+	SourceBarcode = "barcode"
+	SourceVisual  = "visual"
+	SourceCNN     = "cnn"
+)
+
+// RecognizerStage is a recognizer with the score at which its best candidate
+// is trusted without asking the stages after it
+type RecognizerStage struct {
+	Recognizer interfaces.Recognizer
+	Threshold  float64
+}
+
+// RecognizerChain runs recognizers from cheapest to most expensive, stopping
+// at the first one that is confident about a catalog product
+type RecognizerChain struct {
+	stages []RecognizerStage
+}
+
+// NewRecognizerChain creates a chain of the given stages
+func NewRecognizerChain(stages ...RecognizerStage) *RecognizerChain {
+	return &RecognizerChain{
+		stages: stages,
+	}
+}
+
+// Recognize returns up to k candidates, keeping the best score per product,
+// and whether the first one was accepted by a stage. Thresholds override the
+// stage thresholds by recognizer name. A failing stage is skipped; its error
+// is returned only when no stage found anything. The chain stops with the
+// error of ctx once it is done.
+func (c *RecognizerChain) Recognize(ctx context.Context, scan *types.Scan, k int, thresholds map[string]float64) ([]types.RecognitionCandidate, bool, error) {
+	best := map[string]types.RecognitionCandidate{}
+	var lastErr error
+	for _, stage := range c.stages {
+		if err := ctx.Err(); err != nil {
+			return nil, false, err
+		}
+		found, err := stage.Recognizer.Recognize(ctx, scan, k)
+		if err != nil {
+			log.Printf("Recognizer %s failed: %v", stage.Recognizer.Name(), err)
+			lastErr = err
+			continue
+		}
+
+		var top *types.RecognitionCandidate
+		for i, candidate := range found {
+			key := candidateKey(candidate)
+			if existing, ok := best[key]; !ok || candidate.Score > existing.Score {
+				best[key] = candidate
+			}
+			if candidate.ProductCode != "" && (top == nil || candidate.Score > top.Score) {
+				top = &found[i]
+			}
+		}
+		threshold, ok := thresholds[stage.Recognizer.Name()]
+		if !ok {
+			threshold = stage.Threshold
+		}
+		if top != nil && top.Score >= threshold {
+			return rankCandidates(best, k, candidateKey(*top)), true, nil
+		}
+	}
+
+	ranked := rankCandidates(best, k, "")
+	if len(ranked) == 0 && lastErr != nil {
+		return nil, false, lastErr
+	}
+	return ranked, false, nil
+}
+
+// candidateKey identifies the product a candidate stands for
+func candidateKey(candidate types.RecognitionCandidate) string {
+	if candidate.ProductCode != "" {
+		return "code:" + candidate.ProductCode
+	}
+	return "name:" + candidate.Name
+}
+
+// rankCandidates orders candidates by score with the accepted one first
+func rankCandidates(best map[string]types.RecognitionCandidate, k int, accepted string) []types.RecognitionCandidate {
+	ranked := make([]types.RecognitionCandidate, 0, len(best))
+	for _, candidate := range best {
+		ranked = append(ranked, candidate)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if a, b := candidateKey(ranked[i]) == accepted, candidateKey(ranked[j]) == accepted; a != b {
+			return a
+		}
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Name < ranked[j].Name
+	})
+	if k > 0 && len(ranked) > k {
+		ranked = ranked[:k]
+	}
+	return ranked
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nirshpaa/godam-backend/types"
+)
+
+// stubRecognizer returns fixed candidates and counts its calls
+type stubRecognizer struct {
+	name       string
+	candidates []types.RecognitionCandidate
+	err        error
+	calls      int
+}
+
+func (r *stubRecognizer) Name() string {
+	return r.name
+}
+
+func (r *stubRecognizer) Recognize(ctx context.Context, scan *types.Scan, k int) ([]types.RecognitionCandidate, error) {
+	r.calls++
+	return r.candidates, r.err
+}
+
+func TestRecognizerChainRanksCandidates(t *testing.T) {
+	visual := &stubRecognizer{name: SourceVisual, candidates: []types.RecognitionCandidate{
+		{ProductCode: "P1", Name: "Tea", Score: 0.6, Source: SourceVisual},
+		{ProductCode: "P2", Name: "Coffee", Score: 0.4, Source: SourceVisual},
+	}}
+	cnn := &stubRecognizer{name: SourceCNN, candidates: []types.RecognitionCandidate{
+		{ProductCode: "P2", Name: "Coffee", Score: 0.7, Source: SourceCNN},
+		{Name: "Cocoa", Score: 0.3, Source: SourceCNN},
+	}}
+	chain := NewRecognizerChain(RecognizerStage{visual, 0.9}, RecognizerStage{cnn, 0.8})
+
+	candidates, accepted, err := chain.Recognize(context.Background(), &types.Scan{}, 5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accepted {
+		t.Error("expected no stage to be confident")
+	}
+	want := []string{"P2:cnn", "P1:visual", ":cnn"}
+	if len(candidates) != len(want) {
+		t.Fatalf("candidates = %+v, want %v", candidates, want)
+	}
+	for i, candidate := range candidates {
+		if got := candidate.ProductCode + ":" + candidate.Source; got != want[i] {
+			t.Errorf("candidate %d = %s, want %s", i+1, got, want[i])
+		}
+	}
+
+	// A company threshold lets the visual matcher accept its best match
+	// without running the CNN
+	cnn.calls = 0
+	candidates, accepted, err = chain.Recognize(context.Background(), &types.Scan{}, 1, map[string]float64{SourceVisual: 0.5})
+	if err != nil || !accepted || len(candidates) != 1 || candidates[0].ProductCode != "P1" {
+		t.Fatalf("with a lower visual threshold = %+v, %v, %v", candidates, accepted, err)
+	}
+	if cnn.calls != 0 {
+		t.Error("expected the CNN to be skipped once the visual matcher accepted")
+	}
+}
+
+func TestRecognizerChainErrors(t *testing.T) {
+	failing := &stubRecognizer{name: SourceCNN, err: errors.New("endpoint down")}
+	empty := &stubRecognizer{name: SourceVisual}
+
+	if _, _, err := NewRecognizerChain(RecognizerStage{empty, 0.9}, RecognizerStage{failing, 0.5}).Recognize(context.Background(), &types.Scan{}, 5, nil); err == nil {
+		t.Error("expected the error when no stage found anything")
+	}
+
+	found := &stubRecognizer{name: SourceVisual, candidates: []types.RecognitionCandidate{{ProductCode: "P1", Score: 0.3, Source: SourceVisual}}}
+	candidates, _, err := NewRecognizerChain(RecognizerStage{found, 0.9}, RecognizerStage{failing, 0.5}).Recognize(context.Background(), &types.Scan{}, 5, nil)
+	if err != nil || len(candidates) != 1 {
+		t.Errorf("failing stage after a match = %+v, %v, want the match", candidates, err)
+	}
+}
+
+func TestRecognizerChainStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	first := &cancellingRecognizer{cancel: cancel}
+	second := &stubRecognizer{name: SourceCNN, candidates: []types.RecognitionCandidate{{ProductCode: "P1", Score: 0.9, Source: SourceCNN}}}
+
+	if _, _, err := NewRecognizerChain(RecognizerStage{first, 0.9}, RecognizerStage{second, 0.5}).Recognize(ctx, &types.Scan{}, 5, nil); err != context.Canceled {
+		t.Errorf("Recognize after cancel = %v, want context.Canceled", err)
+	}
+	if second.calls != 0 {
+		t.Error("expected the chain to stop before the next stage")
+	}
+}
+
+// cancellingRecognizer finds nothing and cancels the scan, as a timeout would
+type cancellingRecognizer struct {
+	cancel context.CancelFunc
+}
+
+func (r *cancellingRecognizer) Name() string {
+	return SourceVisual
+}
+
+func (r *cancellingRecognizer) Recognize(ctx context.Context, scan *types.Scan, k int) ([]types.RecognitionCandidate, error) {
+	r.cancel()
+	return nil, nil
+}
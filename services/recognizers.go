@@ -0,0 +1,214 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/types"
+)
+
+// BarcodeRecognizer matches the barcode read from a scan against the catalog
+type BarcodeRecognizer struct {
+	products *models.ProductFirebase
+}
+
+// NewBarcodeRecognizer creates a new BarcodeRecognizer instance
+func NewBarcodeRecognizer(products *models.ProductFirebase) *BarcodeRecognizer {
+	return &BarcodeRecognizer{
+		products: products,
+	}
+}
+
+// Name implements interfaces.Recognizer
+func (r *BarcodeRecognizer) Name() string {
+	return SourceBarcode
+}
+
+// Recognize returns the product carrying the scanned barcode
+func (r *BarcodeRecognizer) Recognize(ctx context.Context, scan *types.Scan, k int) ([]types.RecognitionCandidate, error) {
+	if scan.Barcode == nil {
+		return nil, nil
+	}
+
+	product, err := r.products.FindByBarcode(ctx, scan.Barcode.Value)
+	if err != nil {
+		// An unknown barcode is not a failure, the product may be new
+		return nil, nil
+	}
+	return []types.RecognitionCandidate{{
+		ProductCode: product.Code,
+		Name:        product.Name,
+		Score:       1,
+		Source:      SourceBarcode,
+	}}, nil
+}
+
+// CNNRecognizer asks the remote CNN API what the scan shows. Companies with
+// a trained model use its endpoint, the others the default one. Without an
+// endpoint it finds nothing, which keeps scanning working offline.
+type CNNRecognizer struct {
+	endpoint string
+	products *models.ProductFirebase
+	settings *models.RecognitionSettingsFirebase
+	training *models.TrainingFirebase
+	client   *http.Client
+}
+
+// NewCNNRecognizer creates a new CNNRecognizer instance
+func NewCNNRecognizer(endpoint string, products *models.ProductFirebase, settings *models.RecognitionSettingsFirebase, training *models.TrainingFirebase) *CNNRecognizer {
+	return &CNNRecognizer{
+		endpoint: endpoint,
+		products: products,
+		settings: settings,
+		training: training,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name implements interfaces.Recognizer
+func (r *CNNRecognizer) Name() string {
+	return SourceCNN
+}
+
+// Recognize sends the scan to the CNN API and maps the predicted classes to
+// products by name. Classes without a product are kept as name suggestions.
+func (r *CNNRecognizer) Recognize(ctx context.Context, scan *types.Scan, k int) ([]types.RecognitionCandidate, error) {
+	endpoint := r.endpointFor(ctx)
+	if endpoint == "" {
+		return nil, nil
+	}
+
+	predictions, err := r.predict(ctx, endpoint, scan.Image)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(predictions, func(i, j int) bool {
+		return predictions[i].Confidence > predictions[j].Confidence
+	})
+	if k > 0 && len(predictions) > k {
+		predictions = predictions[:k]
+	}
+
+	candidates := make([]types.RecognitionCandidate, 0, len(predictions))
+	for _, prediction := range predictions {
+		candidate := types.RecognitionCandidate{
+			Name:   prediction.Class,
+			Score:  prediction.Confidence,
+			Source: SourceCNN,
+		}
+		if product, err := r.products.FindByName(ctx, prediction.Class); err == nil {
+			candidate.ProductCode = product.Code
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates, nil
+}
+
+// endpointFor returns the endpoint of the model version selected by the
+// company in the context, falling back to the default endpoint
+func (r *CNNRecognizer) endpointFor(ctx context.Context) string {
+	settings, err := r.settings.Get(ctx)
+	if err != nil || settings.ModelVersionID == "" {
+		return r.endpoint
+	}
+	model, err := r.training.GetModel(ctx, settings.ModelVersionID)
+	if err != nil || model.Endpoint == "" {
+		return r.endpoint
+	}
+	return model.Endpoint
+}
+
+type cnnPrediction struct {
+	Class      string  `json:"class"`
+	Confidence float64 `json:"confidence"`
+}
+
+// predict posts the image resized to 224x224 RGB and returns the predicted
+// classes. The API answers with a single prediction or a list of them.
+func (r *CNNRecognizer) predict(ctx context.Context, endpoint string, img image.Image) ([]cnnPrediction, error) {
+	resized := imaging.Resize(img, 224, 224, imaging.Lanczos)
+
+	// Convert NRGBA to RGB
+	bounds := resized.Bounds()
+	rgb := image.NewRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := resized.At(x, y).RGBA()
+			rgb.Set(x, y, color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 255})
+		}
+	}
+
+	buffer := &bytes.Buffer{}
+	writer := multipart.NewWriter(buffer)
+	part, err := writer.CreateFormFile("image", "processed_image.png")
+	if err != nil {
+		return nil, err
+	}
+	if err := png.Encode(part, rgb); err != nil {
+		return nil, fmt.Errorf("failed to encode processed image: %v", err)
+	}
+	writer.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, buffer)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CNN API returned non-200 status: %d", resp.StatusCode)
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var cnnResp struct {
+		Success bool            `json:"success"`
+		Results json.RawMessage `json:"results"`
+		Error   string          `json:"error"`
+	}
+	if err := json.Unmarshal(respBody, &cnnResp); err != nil {
+		return nil, err
+	}
+	if !cnnResp.Success {
+		return nil, errors.New(cnnResp.Error)
+	}
+
+	var predictions []cnnPrediction
+	if err := json.Unmarshal(cnnResp.Results, &predictions); err != nil {
+		var single cnnPrediction
+		if err := json.Unmarshal(cnnResp.Results, &single); err != nil {
+			return nil, fmt.Errorf("failed to parse CNN results: %v", err)
+		}
+		predictions = []cnnPrediction{single}
+	}
+
+	kept := predictions[:0]
+	for _, prediction := range predictions {
+		if prediction.Class != "" {
+			kept = append(kept, prediction)
+		}
+	}
+	return kept, nil
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/disintegration/imaging"
+	"github.com/nirshpaa/godam-backend/libraries/imageproc"
+	"github.com/nirshpaa/godam-backend/libraries/planogram"
+	"github.com/nirshpaa/godam-backend/models"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Shelf photos are split into a grid whose cells each hold about one facing
+const (
+	DefaultAuditColumns = 6
+	DefaultAuditRows    = 3
+	MaxAuditGrid        = 12
+	shelfPhotoFolder    = "shelves"
+)
+
+// ErrAuditGrid is returned for a grid with too few or too many columns or rows
+var ErrAuditGrid = fmt.Errorf("columns and rows must be between 1 and %d", MaxAuditGrid)
+
+// ShelfAuditService counts the products on shelf photos and compares them
+// with the stock and planogram of the shelf
+type ShelfAuditService struct {
+	recognition *ImageRecognitionService
+	files       *FileStorageService
+	shelves     *models.ShelveFirebase
+	audits      *models.ShelfAuditFirebase
+	counts      *models.StockCountFirebase
+}
+
+// NewShelfAuditService creates a new ShelfAuditService
+func NewShelfAuditService(recognition *ImageRecognitionService, files *FileStorageService, shelves *models.ShelveFirebase, audits *models.ShelfAuditFirebase, counts *models.StockCountFirebase) *ShelfAuditService {
+	return &ShelfAuditService{
+		recognition: recognition,
+		files:       files,
+		shelves:     shelves,
+		audits:      audits,
+		counts:      counts,
+	}
+}
+
+// GetPlanogram retrieves the planogram of a shelf of the company in the
+// context, nil when it has none
+func (s *ShelfAuditService) GetPlanogram(ctx context.Context, shelveID string) (*models.FirebasePlanogram, error) {
+	if err := s.checkShelf(ctx, shelveID); err != nil {
+		return nil, err
+	}
+	return s.audits.GetPlanogram(ctx, shelveID)
+}
+
+// ListAudits retrieves the audits of a shelf of the company in the context,
+// newest first
+func (s *ShelfAuditService) ListAudits(ctx context.Context, shelveID string) ([]*models.FirebaseShelfAudit, error) {
+	if err := s.checkShelf(ctx, shelveID); err != nil {
+		return nil, err
+	}
+	return s.audits.ListAudits(ctx, shelveID)
+}
+
+// SavePlanogram creates or replaces the planogram of a shelf
+func (s *ShelfAuditService) SavePlanogram(ctx context.Context, plan *models.FirebasePlanogram) error {
+	if err := s.checkShelf(ctx, plan.ShelveID); err != nil {
+		return err
+	}
+	return s.audits.SavePlanogram(ctx, plan)
+}
+
+// Audit recognizes the product in each of columns by rows tiles of a photo
+// of a shelf and records how the counts compare with the stock the shelf
+// should hold and its planogram, if it has one
+func (s *ShelfAuditService) Audit(ctx context.Context, shelveID string, data []byte, columns, rows int) (*models.FirebaseShelfAudit, error) {
+	if columns < 1 || rows < 1 || columns > MaxAuditGrid || rows > MaxAuditGrid {
+		return nil, ErrAuditGrid
+	}
+	if err := s.checkShelf(ctx, shelveID); err != nil {
+		return nil, err
+	}
+
+	if _, err := imageproc.Check(data); err != nil {
+		return nil, err
+	}
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, imageproc.ErrInvalidImage
+	}
+	paths, err := s.files.SaveImage(ctx, data, shelfPhotoFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	tiles := planogram.Tiles(img.Bounds(), columns, rows)
+	detected := map[string]int{}
+	recognized := 0
+	for _, code := range s.recognition.RecognizeRegions(ctx, img, tiles) {
+		if code != "" {
+			detected[code]++
+			recognized++
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	expected, err := s.audits.ShelfStock(ctx, shelveID)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.audits.GetPlanogram(ctx, shelveID)
+	if err != nil {
+		return nil, err
+	}
+	var slots []planogram.Slot
+	if plan != nil {
+		slots = plan.Slots
+	}
+
+	audit := &models.FirebaseShelfAudit{
+		ShelveID:   shelveID,
+		ImageFile:  "uploads/" + paths.Image,
+		Columns:    columns,
+		Rows:       rows,
+		Tiles:      len(tiles),
+		Recognized: recognized,
+		Planogram:  plan != nil,
+		Lines:      planogram.Compare(detected, expected, slots),
+	}
+	if err := s.audits.CreateAudit(ctx, audit); err != nil {
+		return nil, err
+	}
+	return audit, nil
+}
+
+// SeedStockCount opens a stock count of the products an audit found
+// discrepancies for. An audit seeds a single count; asking again returns it.
+func (s *ShelfAuditService) SeedStockCount(ctx context.Context, auditID string) (*models.FirebaseStockCount, error) {
+	audit, err := s.audits.GetAudit(ctx, auditID)
+	if err != nil {
+		return nil, err
+	}
+	if audit.StockCountID != "" {
+		return s.counts.Get(ctx, audit.StockCountID)
+	}
+
+	count := &models.FirebaseStockCount{
+		ShelveID: audit.ShelveID,
+		AuditID:  audit.ID,
+	}
+	for _, line := range audit.Discrepancies() {
+		count.Lines = append(count.Lines, models.FirebaseStockCountLine{
+			ProductCode: line.ProductCode,
+			Expected:    line.Expected,
+			Detected:    line.Detected,
+			Issues:      line.Issues,
+		})
+	}
+	err = s.counts.Create(ctx, count)
+	if errors.Is(err, models.ErrAuditSeeded) {
+		// Another request seeded the count since the audit was read
+		if audit, err = s.audits.GetAudit(ctx, auditID); err != nil {
+			return nil, err
+		}
+		return s.counts.Get(ctx, audit.StockCountID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return count, nil
+}
+
+// checkShelf returns ErrNotFound unless the shelf exists in the company in the context
+func (s *ShelfAuditService) checkShelf(ctx context.Context, shelveID string) error {
+	if _, err := s.shelves.Get(ctx, shelveID); err != nil {
+		if status.Code(err) == codes.NotFound {
+			return models.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,357 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/nirshpaa/godam-backend/models"
+)
+
+const (
+	// trainingMaxAttempts is how often a job is started before it fails
+	trainingMaxAttempts = 3
+	// trainingBackoff is the wait before the first retry, doubled for each later one
+	trainingBackoff = time.Minute
+	// trainingTimeout fails running jobs the training service stopped reporting on
+	trainingTimeout = 12 * time.Hour
+)
+
+// ErrEmptyDataset is returned when training on a snapshot without images
+var ErrEmptyDataset = errors.New("dataset snapshot has no images")
+
+// TrainingService runs training jobs on the training API. Jobs are queued
+// per company, started by a background worker and retried with backoff when
+// the training API cannot be reached or reports a failure.
+type TrainingService struct {
+	training  *models.TrainingFirebase
+	settings  *models.RecognitionSettingsFirebase
+	dataset   *DatasetService
+	apiURL    string
+	publicURL string
+	client    *http.Client
+}
+
+// NewTrainingService creates a new TrainingService. apiURL is the training
+// API and publicURL the address it reaches this API on for callbacks.
+func NewTrainingService(client *firestore.Client, dataset *DatasetService, apiURL, publicURL string) *TrainingService {
+	return &TrainingService{
+		training:  models.NewTrainingFirebase(client),
+		settings:  models.NewRecognitionSettingsFirebase(client),
+		dataset:   dataset,
+		apiURL:    strings.TrimRight(apiURL, "/"),
+		publicURL: strings.TrimRight(publicURL, "/"),
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Trigger queues a training job on a dataset snapshot of the company in the
+// context. Without a snapshot ID the current dataset is snapshotted first.
+func (s *TrainingService) Trigger(ctx context.Context, snapshotID string) (*models.FirebaseTrainingJob, error) {
+	// Checked first so no snapshot is taken for a job that cannot be queued;
+	// CreateJob checks again under the company's training lock
+	active, err := s.training.ActiveJob(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if active != nil {
+		return nil, models.ErrTrainingInProgress
+	}
+
+	var snapshot *models.FirebaseDatasetSnapshot
+	if snapshotID == "" {
+		snapshot, err = s.dataset.CreateSnapshot(ctx, "Created for training")
+	} else {
+		snapshot, err = s.dataset.dataset.GetSnapshot(ctx, snapshotID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if snapshot.Images == 0 {
+		return nil, ErrEmptyDataset
+	}
+
+	job := &models.FirebaseTrainingJob{
+		SnapshotID:     snapshot.ID,
+		DatasetVersion: snapshot.Version,
+		MaxAttempts:    trainingMaxAttempts,
+	}
+	if err := s.training.CreateJob(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Cancel stops a queued or running job. The training API is told to stop a
+// running one; callbacks for it are refused from then on.
+func (s *TrainingService) Cancel(ctx context.Context, id string) (*models.FirebaseTrainingJob, error) {
+	wasRunning := false
+	job, err := s.training.Transition(ctx, id, []string{models.TrainingQueued, models.TrainingRunning}, func(job *models.FirebaseTrainingJob) {
+		wasRunning = job.Status == models.TrainingRunning
+		now := time.Now()
+		job.Status = models.TrainingCancelled
+		job.FinishedAt = &now
+		job.TokenHash = ""
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if wasRunning {
+		if err := s.post(ctx, "/api/train/cancel", map[string]interface{}{"job_id": job.ID}); err != nil {
+			log.Printf("Error cancelling training job %s: %v", job.ID, err)
+		}
+	}
+	return job, nil
+}
+
+// Callback applies a report of the training service on a running job. The
+// context must be scoped to the job's company.
+func (s *TrainingService) Callback(ctx context.Context, id string, report *models.TrainingReport) (*models.FirebaseTrainingJob, error) {
+	running := []string{models.TrainingRunning}
+	switch report.Status {
+	case models.TrainingRunning:
+		return s.training.Transition(ctx, id, running, func(job *models.FirebaseTrainingJob) {
+			job.Metrics = mergeMetrics(job.Metrics, report.Metrics)
+		})
+
+	case models.TrainingFailed:
+		reason := report.Error
+		if reason == "" {
+			reason = "training failed"
+		}
+		return s.fail(ctx, id, reason)
+
+	case models.TrainingSucceeded:
+		if report.ModelEndpoint == "" {
+			return nil, errors.New("model_endpoint is required when training succeeded")
+		}
+		job, err := s.training.Transition(ctx, id, running, func(job *models.FirebaseTrainingJob) {
+			now := time.Now()
+			job.Status = models.TrainingSucceeded
+			job.Metrics = mergeMetrics(job.Metrics, report.Metrics)
+			job.Error = ""
+			job.FinishedAt = &now
+			job.TokenHash = ""
+		})
+		if err != nil {
+			return nil, err
+		}
+		return s.release(ctx, job, report.ModelEndpoint)
+
+	default:
+		return nil, fmt.Errorf("invalid training status %q", report.Status)
+	}
+}
+
+// release records the model a job produced and serves it unless the
+// company pinned another model
+func (s *TrainingService) release(ctx context.Context, job *models.FirebaseTrainingJob, endpoint string) (*models.FirebaseTrainingJob, error) {
+	model := &models.FirebaseModelVersion{
+		JobID:          job.ID,
+		DatasetVersion: job.DatasetVersion,
+		Endpoint:       endpoint,
+		Metrics:        job.Metrics,
+	}
+	if err := s.training.CreateModel(ctx, model); err != nil {
+		return nil, err
+	}
+
+	job, err := s.training.Transition(ctx, job.ID, []string{models.TrainingSucceeded}, func(job *models.FirebaseTrainingJob) {
+		job.ModelVersionID = model.ID
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := s.settings.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !settings.ModelPinned {
+		if err := s.settings.SetModel(ctx, model.ID, false); err != nil {
+			return nil, err
+		}
+	}
+	return job, nil
+}
+
+// Pin serves a model version of the company in the context until unpinned,
+// which also rolls back to an earlier model
+func (s *TrainingService) Pin(ctx context.Context, modelVersionID string) error {
+	if _, err := s.training.GetModel(ctx, modelVersionID); err != nil {
+		return err
+	}
+	return s.settings.SetModel(ctx, modelVersionID, true)
+}
+
+// Unpin goes back to serving the newest model of the company in the context
+func (s *TrainingService) Unpin(ctx context.Context) error {
+	versions, err := s.training.ListModels(ctx)
+	if err != nil {
+		return err
+	}
+	latest := ""
+	if len(versions) > 0 {
+		latest = versions[0].ID
+	}
+	return s.settings.SetModel(ctx, latest, false)
+}
+
+// Authenticate checks the token of a training service request for a job and
+// returns a context scoped to the job's company
+func (s *TrainingService) Authenticate(ctx context.Context, id, token string) (context.Context, *models.FirebaseTrainingJob, error) {
+	job, err := s.training.Authenticate(ctx, id, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.jobContext(ctx, job), job, nil
+}
+
+// Start runs the training worker in the background until ctx is cancelled
+func (s *TrainingService) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			s.run(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *TrainingService) run(ctx context.Context) {
+	now := time.Now()
+
+	stale, err := s.training.StaleJobs(ctx, now.Add(-trainingTimeout))
+	if err != nil {
+		log.Printf("Error listing stale training jobs: %v", err)
+	}
+	for _, job := range stale {
+		if _, err := s.fail(s.jobContext(ctx, job), job.ID, "training timed out"); err != nil {
+			log.Printf("Error timing out training job %s: %v", job.ID, err)
+		}
+	}
+
+	due, err := s.training.DueJobs(ctx, now)
+	if err != nil {
+		log.Printf("Error listing due training jobs: %v", err)
+		return
+	}
+	for _, job := range due {
+		s.dispatch(s.jobContext(ctx, job), job)
+	}
+}
+
+// dispatch starts an attempt of a queued job on the training API with a
+// token only valid for that attempt
+func (s *TrainingService) dispatch(ctx context.Context, job *models.FirebaseTrainingJob) {
+	token, hash, err := models.NewTrainingToken()
+	if err != nil {
+		log.Printf("Error creating training token: %v", err)
+		return
+	}
+
+	job, err = s.training.Transition(ctx, job.ID, []string{models.TrainingQueued}, func(job *models.FirebaseTrainingJob) {
+		now := time.Now()
+		job.Status = models.TrainingRunning
+		job.Attempts++
+		job.StartedAt = &now
+		job.TokenHash = hash
+	})
+	if err != nil {
+		// Cancelled or claimed by another instance in the meantime
+		if !errors.Is(err, models.ErrTrainingClosed) {
+			log.Printf("Error starting training job: %v", err)
+		}
+		return
+	}
+
+	callback := fmt.Sprintf("%s/training/callback/%s", s.publicURL, job.ID)
+	err = s.post(ctx, "/api/train/start", map[string]interface{}{
+		"job_id":          job.ID,
+		"company_id":      job.CompanyID,
+		"dataset_version": job.DatasetVersion,
+		"dataset_url":     callback + "/dataset",
+		"callback_url":    callback,
+		"token":           token,
+	})
+	if err != nil {
+		log.Printf("Error starting training job %s: %v", job.ID, err)
+		if _, err := s.fail(ctx, job.ID, err.Error()); err != nil {
+			log.Printf("Error recording training failure of job %s: %v", job.ID, err)
+		}
+	}
+}
+
+// fail ends the current attempt of a running job and queues the next one
+// after a backoff, or fails the job once its attempts are used up
+func (s *TrainingService) fail(ctx context.Context, id, reason string) (*models.FirebaseTrainingJob, error) {
+	return s.training.Transition(ctx, id, []string{models.TrainingRunning}, func(job *models.FirebaseTrainingJob) {
+		now := time.Now()
+		job.Error = reason
+		job.TokenHash = ""
+		if job.Attempts >= job.MaxAttempts {
+			job.Status = models.TrainingFailed
+			job.FinishedAt = &now
+			return
+		}
+		job.Status = models.TrainingQueued
+		job.NextAttemptAt = now.Add(trainingBackoff << (job.Attempts - 1))
+	})
+}
+
+// jobContext scopes the worker's writes to the job's company
+func (s *TrainingService) jobContext(ctx context.Context, job *models.FirebaseTrainingJob) context.Context {
+	return models.WithCompany(models.WithActor(ctx, "training:"+job.ID), job.CompanyID)
+}
+
+func (s *TrainingService) post(ctx context.Context, path string, payload interface{}) error {
+	if s.apiURL == "" {
+		return errors.New("TRAINING_API_ENDPOINT is not set")
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.apiURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request to training API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("training API returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func mergeMetrics(current, reported map[string]float64) map[string]float64 {
+	if current == nil {
+		current = map[string]float64{}
+	}
+	for name, value := range reported {
+		current[name] = value
+	}
+	return current
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/nirshpaa/godam-backend/models"
+)
+
+// TrashPurger periodically removes records that have been in the trash for
+// longer than the retention period
+type TrashPurger struct {
+	trash     *models.Trash
+	retention time.Duration
+	interval  time.Duration
+}
+
+// NewTrashPurger creates a new TrashPurger instance
+func NewTrashPurger(trash *models.Trash, retention, interval time.Duration) *TrashPurger {
+	return &TrashPurger{
+		trash:     trash,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Start runs the purge job in the background until ctx is cancelled
+func (p *TrashPurger) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			p.purge(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (p *TrashPurger) purge(ctx context.Context) {
+	purged, err := p.trash.Purge(ctx, p.retention)
+	if err != nil {
+		log.Printf("Error purging trash: %v", err)
+	}
+	if purged > 0 {
+		log.Printf("Purged %d record(s) from the trash", purged)
+	}
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nirshpaa/godam-backend/libraries/imagehash"
+	"github.com/nirshpaa/godam-backend/libraries/objectstore"
+	"github.com/nirshpaa/godam-backend/models"
+	"github.com/nirshpaa/godam-backend/types"
+)
+
+// visualIndexTTL bounds how long a company's fingerprints are cached, so
+// indexes rebuilt on another instance are picked up
+const visualIndexTTL = 5 * time.Minute
+
+// maxReferenceImageSize limits reference images read from the object store
+const maxReferenceImageSize = 20 << 20
+
+// VisualMatcher recognizes products offline by comparing the perceptual
+// fingerprint of a scan with those of the product reference images
+type VisualMatcher struct {
+	fingerprints *models.FingerprintFirebase
+	products     *models.ProductFirebase
+	store        objectstore.Store
+
+	mu    sync.Mutex
+	cache map[string]*visualIndex
+}
+
+type visualIndex struct {
+	entries []visualEntry
+	loaded  time.Time
+}
+
+type visualEntry struct {
+	code        string
+	name        string
+	fingerprint imagehash.Fingerprint
+}
+
+// NewVisualMatcher creates a new VisualMatcher reading reference images from store
+func NewVisualMatcher(fingerprints *models.FingerprintFirebase, products *models.ProductFirebase, store objectstore.Store) *VisualMatcher {
+	return &VisualMatcher{
+		fingerprints: fingerprints,
+		products:     products,
+		store:        store,
+		cache:        map[string]*visualIndex{},
+	}
+}
+
+// Name implements interfaces.Recognizer
+func (m *VisualMatcher) Name() string {
+	return SourceVisual
+}
+
+// Recognize returns the k products whose reference images look most like the
+// scan, scored by their closest image
+func (m *VisualMatcher) Recognize(ctx context.Context, scan *types.Scan, k int) ([]types.RecognitionCandidate, error) {
+	index, err := m.index(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(index.entries) == 0 {
+		return nil, nil
+	}
+
+	fingerprint := imagehash.Compute(scan.Image)
+	best := map[string]types.RecognitionCandidate{}
+	for _, entry := range index.entries {
+		score := imagehash.Similarity(fingerprint, entry.fingerprint)
+		if existing, ok := best[entry.code]; !ok || score > existing.Score {
+			best[entry.code] = types.RecognitionCandidate{
+				ProductCode: entry.code,
+				Name:        entry.name,
+				Score:       score,
+				Source:      SourceVisual,
+			}
+		}
+	}
+	return rankCandidates(best, k, ""), nil
+}
+
+// Index fingerprints the reference image of a product, replacing the ones
+// indexed before
+func (m *VisualMatcher) Index(ctx context.Context, productCode, imageURL string) error {
+	product, err := m.products.Get(ctx, productCode)
+	if err != nil {
+		return err
+	}
+
+	fingerprint, err := m.fingerprint(ctx, product, imageURL)
+	if err != nil {
+		return err
+	}
+	if err := m.fingerprints.Replace(ctx, productCode, []models.FirebaseFingerprint{fingerprint}); err != nil {
+		return err
+	}
+	m.invalidate(ctx)
+	return nil
+}
+
+// Reindex rebuilds the fingerprints of every product with an image and
+// returns how many were indexed. Images that cannot be read are skipped.
+func (m *VisualMatcher) Reindex(ctx context.Context) (int, error) {
+	products, err := m.products.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := m.fingerprints.DeleteAll(ctx); err != nil {
+		return 0, err
+	}
+	defer m.invalidate(ctx)
+
+	indexed := 0
+	for i := range products {
+		product := &products[i]
+		if product.ImageURL == "" {
+			continue
+		}
+		fingerprint, err := m.fingerprint(ctx, product, product.ImageURL)
+		if err != nil {
+			log.Printf("Skipping image of product %s: %v", product.Code, err)
+			continue
+		}
+		if err := m.fingerprints.Replace(ctx, product.Code, []models.FirebaseFingerprint{fingerprint}); err != nil {
+			return indexed, err
+		}
+		indexed++
+	}
+	return indexed, nil
+}
+
+func (m *VisualMatcher) fingerprint(ctx context.Context, product *models.FirebaseProduct, imageURL string) (models.FirebaseFingerprint, error) {
+	img, err := m.openImage(ctx, imageURL)
+	if err != nil {
+		return models.FirebaseFingerprint{}, err
+	}
+
+	fingerprint := imagehash.Compute(img)
+	return models.FirebaseFingerprint{
+		ProductCode: product.Code,
+		ProductName: product.Name,
+		ImageURL:    imageURL,
+		Hash:        imagehash.FormatHash(fingerprint.Hash),
+		Histogram:   fingerprint.Histogram,
+	}, nil
+}
+
+// openImage reads a reference image from the object store. Only images the
+// store holds are read, never outside URLs or files on the server.
+func (m *VisualMatcher) openImage(ctx context.Context, imageURL string) (image.Image, error) {
+	key, err := referenceKey(imageURL)
+	if err != nil {
+		return nil, err
+	}
+	r, err := m.store.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image %s: %v", imageURL, err)
+	}
+	defer r.Close()
+	img, _, err := image.Decode(io.LimitReader(r, maxReferenceImageSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+	return img, nil
+}
+
+// referenceKey returns the object store key of a product image URL: a key,
+// or a key under /uploads/ where the file handler serves it
+func referenceKey(imageURL string) (string, error) {
+	if strings.Contains(imageURL, "://") {
+		return "", fmt.Errorf("%w: image %s is not in the object store", objectstore.ErrInvalidKey, imageURL)
+	}
+	key, err := objectstore.CleanKey(strings.TrimPrefix(strings.TrimLeft(imageURL, "/"), "uploads/"))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, imageURL)
+	}
+	return key, nil
+}
+
+// index returns the cached fingerprints of the company in the context
+func (m *VisualMatcher) index(ctx context.Context) (*visualIndex, error) {
+	companyID := models.CompanyFromContext(ctx)
+
+	m.mu.Lock()
+	cached, ok := m.cache[companyID]
+	m.mu.Unlock()
+	if ok && time.Since(cached.loaded) < visualIndexTTL {
+		return cached, nil
+	}
+
+	stored, err := m.fingerprints.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	index := &visualIndex{loaded: time.Now()}
+	for i := range stored {
+		fingerprint, err := stored[i].Fingerprint()
+		if err != nil {
+			log.Printf("Skipping fingerprint %s: %v", stored[i].ID, err)
+			continue
+		}
+		index.entries = append(index.entries, visualEntry{
+			code:        stored[i].ProductCode,
+			name:        stored[i].ProductName,
+			fingerprint: fingerprint,
+		})
+	}
+
+	m.mu.Lock()
+	m.cache[companyID] = index
+	m.mu.Unlock()
+	return index, nil
+}
+
+func (m *VisualMatcher) invalidate(ctx context.Context) {
+	m.mu.Lock()
+	delete(m.cache, models.CompanyFromContext(ctx))
+	m.mu.Unlock()
+}
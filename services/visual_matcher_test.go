@@ -0,0 +1,62 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nirshpaa/godam-backend/libraries/objectstore"
+)
+
+func TestVisualMatcherOpensStoreImagesOnly(t *testing.T) {
+	ctx := context.Background()
+	store := objectstore.NewLocal(t.TempDir(), "")
+	matcher := NewVisualMatcher(nil, nil, store)
+
+	img := image.NewGray(image.Rect(0, 0, 8, 8))
+	img.Set(1, 1, color.White)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(ctx, "products/p1.png", buf.Bytes(), "image/png"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	for _, imageURL := range []string{"products/p1.png", "/uploads/products/p1.png"} {
+		if _, err := matcher.openImage(ctx, imageURL); err != nil {
+			t.Errorf("openImage(%q) = %v", imageURL, err)
+		}
+	}
+
+	fetched := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched = true
+		w.Write(buf.Bytes())
+	}))
+	defer server.Close()
+
+	onDisk := filepath.Join(t.TempDir(), "p1.png")
+	if err := os.WriteFile(onDisk, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, imageURL := range []string{server.URL + "/p1.png", "products/../../p1.png", "/uploads/../p1.png", ""} {
+		if _, err := matcher.openImage(ctx, imageURL); !errors.Is(err, objectstore.ErrInvalidKey) {
+			t.Errorf("openImage(%q) = %v, want ErrInvalidKey", imageURL, err)
+		}
+	}
+	if _, err := matcher.openImage(ctx, onDisk); err == nil {
+		t.Errorf("openImage(%q) read a file outside the store", onDisk)
+	}
+	if fetched {
+		t.Error("openImage fetched an outside URL")
+	}
+}
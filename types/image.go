@@ -1,16 +1,41 @@
 package types
 
+import (
+	"image"
+
+	"github.com/nirshpaa/godam-backend/libraries/barcode"
+)
+
 // CreateProductData represents the data needed to create a new product
 type CreateProductData struct {
 	Action        string  `json:"action"`
 	SuggestedName string  `json:"suggested_name"`
 	Confidence    float64 `json:"confidence"`
+	Barcode       string  `json:"barcode,omitempty"`
 }
 
 // ImageRecognitionResult represents the result of image recognition
 type ImageRecognitionResult struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data"` // Can be either string (product ID) or CreateProductData
+	Success    bool                   `json:"success"`
+	Data       interface{}            `json:"data"` // Can be either string (product ID) or CreateProductData
+	Barcode    *barcode.Result        `json:"barcode,omitempty"`
+	Candidates []RecognitionCandidate `json:"candidates,omitempty"`
+}
+
+// Scan is an image submitted for recognition with the barcode read from it, if any
+type Scan struct {
+	Image   image.Image
+	Barcode *barcode.Result
+}
+
+// RecognitionCandidate is a product a recognizer matched with a score between
+// 0 and 1. Remote models may suggest names that are not in the catalog yet,
+// which leaves ProductCode empty.
+type RecognitionCandidate struct {
+	ProductCode string  `json:"product_code,omitempty"`
+	Name        string  `json:"name"`
+	Score       float64 `json:"score"`
+	Source      string  `json:"source"`
 }
 
 // ImageTrainingRequest represents a request to train on a new image
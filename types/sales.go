@@ -24,6 +24,7 @@ type SalesOrder struct {
 	Discount          float64            `json:"discount"`
 	AdditionalDisc    float64            `json:"additional_disc"`
 	Status            string             `json:"status"`
+	ApprovalStatus    string             `json:"approval_status"`
 	SalesOrderDetails []SalesOrderDetail `json:"sales_order_details"`
 }
 